@@ -0,0 +1,57 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// The serial-vault-pkcs11 command is the key manager for the pkcs11 keystore. It is run by
+// the serial vault for each signing-key operation, and signs using the keys on the token.
+// The PKCS#11 module, token label and PIN are read from the settings file of the serial
+// vault, or passed in the environment.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/CanonicalLtd/serial-vault/config"
+	"github.com/CanonicalLtd/serial-vault/pkcs11"
+)
+
+func main() {
+	module, label, pin := os.Getenv(pkcs11.EnvModule), os.Getenv(pkcs11.EnvToken), os.Getenv(pkcs11.EnvPIN)
+	if settingsFile := os.Getenv(pkcs11.EnvSettings); len(settingsFile) > 0 {
+		settings := config.Settings{}
+		if err := config.ReadConfig(&settings, settingsFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading the settings file: %v\n", err)
+			os.Exit(1)
+		}
+		module, label, pin = settings.PKCS11Module, settings.PKCS11Token, settings.PKCS11PIN
+	}
+
+	token, err := OpenToken(module, label, pin)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening the PKCS#11 token: %v\n", err)
+		os.Exit(1)
+	}
+
+	err = pkcs11.Run(token, os.Args[1:], os.Stdin, os.Stdout)
+	token.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,505 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+// The binding of the PKCS#11 token uses cgo, so it is kept in the key manager command.
+// The private keys are created on the token as sensitive, non-extractable objects,
+// so signing is done by the token and the key material never leaves it.
+
+/*
+#cgo CFLAGS: -I/usr/include/p11-kit-1
+#cgo LDFLAGS: -ldl
+
+#include <dlfcn.h>
+#include <stdlib.h>
+#include <p11-kit/pkcs11.h>
+
+static CK_RV load_module(const char *path, void **handle, CK_FUNCTION_LIST_PTR *funcs) {
+	CK_C_GetFunctionList get_function_list;
+
+	*handle = dlopen(path, RTLD_NOW | RTLD_LOCAL);
+	if (*handle == NULL) {
+		return CKR_GENERAL_ERROR;
+	}
+	get_function_list = (CK_C_GetFunctionList)dlsym(*handle, "C_GetFunctionList");
+	if (get_function_list == NULL) {
+		dlclose(*handle);
+		*handle = NULL;
+		return CKR_GENERAL_ERROR;
+	}
+	return get_function_list(funcs);
+}
+
+static void unload_module(void *handle) {
+	dlclose(handle);
+}
+
+static CK_RV initialize(CK_FUNCTION_LIST_PTR f) {
+	return f->C_Initialize(NULL);
+}
+
+static CK_RV finalize(CK_FUNCTION_LIST_PTR f) {
+	return f->C_Finalize(NULL);
+}
+
+static CK_RV get_slot_list(CK_FUNCTION_LIST_PTR f, CK_SLOT_ID_PTR slots, CK_ULONG_PTR count) {
+	return f->C_GetSlotList(CK_TRUE, slots, count);
+}
+
+static CK_RV get_token_info(CK_FUNCTION_LIST_PTR f, CK_SLOT_ID slot, CK_TOKEN_INFO_PTR info) {
+	return f->C_GetTokenInfo(slot, info);
+}
+
+static CK_RV open_session(CK_FUNCTION_LIST_PTR f, CK_SLOT_ID slot, CK_SESSION_HANDLE_PTR session) {
+	return f->C_OpenSession(slot, CKF_SERIAL_SESSION | CKF_RW_SESSION, NULL, NULL, session);
+}
+
+static CK_RV close_session(CK_FUNCTION_LIST_PTR f, CK_SESSION_HANDLE session) {
+	return f->C_CloseSession(session);
+}
+
+static CK_RV login(CK_FUNCTION_LIST_PTR f, CK_SESSION_HANDLE session, CK_UTF8CHAR_PTR pin, CK_ULONG pin_len) {
+	return f->C_Login(session, CKU_USER, pin, pin_len);
+}
+
+static CK_RV find_objects_init(CK_FUNCTION_LIST_PTR f, CK_SESSION_HANDLE session, CK_ATTRIBUTE_PTR templ, CK_ULONG count) {
+	return f->C_FindObjectsInit(session, templ, count);
+}
+
+static CK_RV find_objects(CK_FUNCTION_LIST_PTR f, CK_SESSION_HANDLE session, CK_OBJECT_HANDLE_PTR objects, CK_ULONG max, CK_ULONG_PTR count) {
+	return f->C_FindObjects(session, objects, max, count);
+}
+
+static CK_RV find_objects_final(CK_FUNCTION_LIST_PTR f, CK_SESSION_HANDLE session) {
+	return f->C_FindObjectsFinal(session);
+}
+
+static CK_RV get_attribute_value(CK_FUNCTION_LIST_PTR f, CK_SESSION_HANDLE session, CK_OBJECT_HANDLE object, CK_ATTRIBUTE_PTR templ, CK_ULONG count) {
+	return f->C_GetAttributeValue(session, object, templ, count);
+}
+
+static CK_RV create_object(CK_FUNCTION_LIST_PTR f, CK_SESSION_HANDLE session, CK_ATTRIBUTE_PTR templ, CK_ULONG count, CK_OBJECT_HANDLE_PTR object) {
+	return f->C_CreateObject(session, templ, count, object);
+}
+
+static CK_RV sign_rsa_pkcs(CK_FUNCTION_LIST_PTR f, CK_SESSION_HANDLE session, CK_OBJECT_HANDLE key, CK_BYTE_PTR data, CK_ULONG data_len, CK_BYTE_PTR signature, CK_ULONG_PTR signature_len) {
+	CK_MECHANISM mechanism = {CKM_RSA_PKCS, NULL, 0};
+	CK_RV rv;
+
+	rv = f->C_SignInit(session, &mechanism, key);
+	if (rv != CKR_OK) {
+		return rv;
+	}
+	return f->C_Sign(session, data, data_len, signature, signature_len);
+}
+*/
+import "C"
+
+import (
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"unsafe"
+
+	"github.com/CanonicalLtd/serial-vault/pkcs11"
+)
+
+// Error is a failed PKCS#11 call, holding the CK_RV return value
+type Error struct {
+	Function string
+	Code     uint
+}
+
+func (e Error) Error() string {
+	return fmt.Sprintf("pkcs11: %s failed with 0x%08X", e.Function, e.Code)
+}
+
+func check(function string, rv C.CK_RV) error {
+	if rv == C.CKR_OK {
+		return nil
+	}
+	return Error{function, uint(rv)}
+}
+
+// Token is a logged-in session on a PKCS#11 token
+type Token struct {
+	mu      sync.Mutex
+	handle  unsafe.Pointer
+	funcs   C.CK_FUNCTION_LIST_PTR
+	session C.CK_SESSION_HANDLE
+}
+
+// OpenToken loads the PKCS#11 module and logs in to the token with the given label
+func OpenToken(modulePath, tokenLabel, pin string) (*Token, error) {
+	t := &Token{}
+
+	cPath := C.CString(modulePath)
+	defer C.free(unsafe.Pointer(cPath))
+
+	if rv := C.load_module(cPath, &t.handle, &t.funcs); rv != C.CKR_OK {
+		if t.handle == nil {
+			return nil, fmt.Errorf("Cannot load the PKCS#11 module %s", modulePath)
+		}
+		C.unload_module(t.handle)
+		return nil, check("C_GetFunctionList", rv)
+	}
+
+	if err := check("C_Initialize", C.initialize(t.funcs)); err != nil {
+		C.unload_module(t.handle)
+		return nil, err
+	}
+
+	if err := t.openSession(tokenLabel, pin); err != nil {
+		C.finalize(t.funcs)
+		C.unload_module(t.handle)
+		return nil, err
+	}
+	return t, nil
+}
+
+func (t *Token) openSession(tokenLabel, pin string) error {
+	slot, err := t.findSlot(tokenLabel)
+	if err != nil {
+		return err
+	}
+
+	if err := check("C_OpenSession", C.open_session(t.funcs, slot, &t.session)); err != nil {
+		return err
+	}
+
+	cPin := C.CBytes([]byte(pin))
+	defer C.free(cPin)
+	rv := C.login(t.funcs, t.session, (*C.CK_UTF8CHAR)(cPin), C.CK_ULONG(len(pin)))
+	if rv != C.CKR_OK && rv != C.CKR_USER_ALREADY_LOGGED_IN {
+		C.close_session(t.funcs, t.session)
+		return check("C_Login", rv)
+	}
+	return nil
+}
+
+// findSlot returns the slot of the token with the label. The token labels are blank-padded
+func (t *Token) findSlot(tokenLabel string) (C.CK_SLOT_ID, error) {
+	var count C.CK_ULONG
+	if err := check("C_GetSlotList", C.get_slot_list(t.funcs, nil, &count)); err != nil {
+		return 0, err
+	}
+	if count == 0 {
+		return 0, pkcs11.ErrorTokenNotFound
+	}
+
+	slots := (*C.CK_SLOT_ID)(C.malloc(C.size_t(count) * C.size_t(unsafe.Sizeof(C.CK_SLOT_ID(0)))))
+	defer C.free(unsafe.Pointer(slots))
+	if err := check("C_GetSlotList", C.get_slot_list(t.funcs, slots, &count)); err != nil {
+		return 0, err
+	}
+
+	info := (*C.CK_TOKEN_INFO)(C.malloc(C.size_t(unsafe.Sizeof(C.CK_TOKEN_INFO{}))))
+	defer C.free(unsafe.Pointer(info))
+	for _, slot := range unsafe.Slice(slots, int(count)) {
+		if err := check("C_GetTokenInfo", C.get_token_info(t.funcs, slot, info)); err != nil {
+			return 0, err
+		}
+		label := C.GoBytes(unsafe.Pointer(&info.label[0]), C.int(len(info.label)))
+		if strings.TrimRight(string(label), " \x00") == tokenLabel {
+			return slot, nil
+		}
+	}
+	return 0, pkcs11.ErrorTokenNotFound
+}
+
+// Close logs out of the token and unloads the PKCS#11 module
+func (t *Token) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	err := check("C_CloseSession", C.close_session(t.funcs, t.session))
+	C.finalize(t.funcs)
+	C.unload_module(t.handle)
+	return err
+}
+
+// KeyLabels returns the labels of the RSA private keys on the token
+func (t *Token) KeyLabels() ([]string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	objects, err := t.findObjects(privateKeyTemplate(""))
+	if err != nil {
+		return nil, err
+	}
+
+	labels := []string{}
+	for _, object := range objects {
+		values, err := t.getAttributes(object, C.CKA_LABEL)
+		if err != nil {
+			return nil, err
+		}
+		labels = append(labels, string(values[0]))
+	}
+	return labels, nil
+}
+
+// PublicKey returns the public part of the RSA private key with the label
+func (t *Token) PublicKey(label string) (*rsa.PublicKey, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	object, err := t.findKey(label)
+	if err != nil {
+		return nil, err
+	}
+	return t.publicKey(object)
+}
+
+func (t *Token) publicKey(object C.CK_OBJECT_HANDLE) (*rsa.PublicKey, error) {
+	values, err := t.getAttributes(object, C.CKA_MODULUS, C.CKA_PUBLIC_EXPONENT)
+	if err != nil {
+		return nil, err
+	}
+
+	exponent := new(big.Int).SetBytes(values[1])
+	if !exponent.IsInt64() || exponent.Int64() > 1<<31-1 {
+		return nil, errors.New("Invalid public exponent for the PKCS#11 key")
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(values[0]), E: int(exponent.Int64())}, nil
+}
+
+// Sign signs the data with the RSA private key with the label, using the CKM_RSA_PKCS mechanism.
+// The data is expected to be the DER-encoded DigestInfo of the message digest
+func (t *Token) Sign(label string, data []byte) ([]byte, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	object, err := t.findKey(label)
+	if err != nil {
+		return nil, err
+	}
+
+	// The signature is the size of the modulus. The buffer is allocated up front, as asking
+	// the token for the length leaves the signing operation active
+	public, err := t.publicKey(object)
+	if err != nil {
+		return nil, err
+	}
+	length := C.CK_ULONG(public.Size())
+
+	cData := C.CBytes(data)
+	defer C.free(cData)
+
+	signature := C.malloc(C.size_t(length))
+	defer C.free(signature)
+	rv := C.sign_rsa_pkcs(t.funcs, t.session, object, (*C.CK_BYTE)(cData), C.CK_ULONG(len(data)), (*C.CK_BYTE)(signature), &length)
+	if err := check("C_Sign", rv); err != nil {
+		return nil, err
+	}
+	return C.GoBytes(signature, C.int(length)), nil
+}
+
+// ImportKey creates the RSA private key on the token as a sensitive, non-extractable object.
+// Importing a key that is already on the token with the same label is a no-op
+func (t *Token) ImportKey(label string, key *rsa.PrivateKey) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(key.Primes) != 2 {
+		return errors.New("Only two-prime RSA keys can be imported to the PKCS#11 token")
+	}
+
+	object, err := t.findKey(label)
+	switch err {
+	case nil:
+		public, err := t.publicKey(object)
+		if err != nil {
+			return err
+		}
+		if public.N.Cmp(key.N) != 0 || public.E != key.E {
+			return pkcs11.ErrorKeyExists
+		}
+		return nil
+	case pkcs11.ErrorKeyNotFound:
+	default:
+		return err
+	}
+
+	key.Precompute()
+	tmpl := append(privateKeyTemplate(label),
+		attribute{C.CKA_TOKEN, boolValue(true)},
+		attribute{C.CKA_PRIVATE, boolValue(true)},
+		attribute{C.CKA_SENSITIVE, boolValue(true)},
+		attribute{C.CKA_EXTRACTABLE, boolValue(false)},
+		attribute{C.CKA_SIGN, boolValue(true)},
+		attribute{C.CKA_ID, []byte(label)},
+		attribute{C.CKA_MODULUS, key.N.Bytes()},
+		attribute{C.CKA_PUBLIC_EXPONENT, big.NewInt(int64(key.E)).Bytes()},
+		attribute{C.CKA_PRIVATE_EXPONENT, key.D.Bytes()},
+		attribute{C.CKA_PRIME_1, key.Primes[0].Bytes()},
+		attribute{C.CKA_PRIME_2, key.Primes[1].Bytes()},
+		attribute{C.CKA_EXPONENT_1, key.Precomputed.Dp.Bytes()},
+		attribute{C.CKA_EXPONENT_2, key.Precomputed.Dq.Bytes()},
+		attribute{C.CKA_COEFFICIENT, key.Precomputed.Qinv.Bytes()},
+	)
+
+	defer func() {
+		for _, a := range tmpl {
+			pkcs11.Zero(a.value)
+		}
+	}()
+
+	cTmpl, count, free := newTemplate(tmpl)
+	defer free()
+
+	var created C.CK_OBJECT_HANDLE
+	return check("C_CreateObject", C.create_object(t.funcs, t.session, cTmpl, count, &created))
+}
+
+// findKey returns the handle of the RSA private key with the label
+func (t *Token) findKey(label string) (C.CK_OBJECT_HANDLE, error) {
+	objects, err := t.findObjects(privateKeyTemplate(label))
+	if err != nil {
+		return 0, err
+	}
+	if len(objects) == 0 {
+		return 0, pkcs11.ErrorKeyNotFound
+	}
+	return objects[0], nil
+}
+
+func (t *Token) findObjects(tmpl []attribute) ([]C.CK_OBJECT_HANDLE, error) {
+	cTmpl, count, free := newTemplate(tmpl)
+	defer free()
+
+	if err := check("C_FindObjectsInit", C.find_objects_init(t.funcs, t.session, cTmpl, count)); err != nil {
+		return nil, err
+	}
+	defer C.find_objects_final(t.funcs, t.session)
+
+	const batch = 16
+	handles := (*C.CK_OBJECT_HANDLE)(C.malloc(batch * C.size_t(unsafe.Sizeof(C.CK_OBJECT_HANDLE(0)))))
+	defer C.free(unsafe.Pointer(handles))
+
+	objects := []C.CK_OBJECT_HANDLE{}
+	for {
+		var found C.CK_ULONG
+		if err := check("C_FindObjects", C.find_objects(t.funcs, t.session, handles, batch, &found)); err != nil {
+			return nil, err
+		}
+		if found == 0 {
+			return objects, nil
+		}
+		objects = append(objects, unsafe.Slice(handles, int(found))...)
+	}
+}
+
+// getAttributes reads the values of the attributes of an object. The token is asked
+// for the length of the values first, so the buffers can be allocated
+func (t *Token) getAttributes(object C.CK_OBJECT_HANDLE, types ...C.CK_ATTRIBUTE_TYPE) ([][]byte, error) {
+	tmpl := make([]attribute, len(types))
+	for i := range types {
+		tmpl[i].typ = types[i]
+	}
+	cTmpl, count, free := newTemplate(tmpl)
+	defer free()
+
+	if err := check("C_GetAttributeValue", C.get_attribute_value(t.funcs, t.session, object, cTmpl, count)); err != nil {
+		return nil, err
+	}
+
+	attrs := unsafe.Slice(cTmpl, int(count))
+	for i := range attrs {
+		attrs[i].pValue = C.malloc(C.size_t(attrs[i].ulValueLen))
+	}
+	defer func() {
+		for i := range attrs {
+			C.free(attrs[i].pValue)
+			attrs[i].pValue = nil
+		}
+	}()
+
+	if err := check("C_GetAttributeValue", C.get_attribute_value(t.funcs, t.session, object, cTmpl, count)); err != nil {
+		return nil, err
+	}
+
+	values := make([][]byte, len(attrs))
+	for i := range attrs {
+		values[i] = C.GoBytes(attrs[i].pValue, C.int(attrs[i].ulValueLen))
+	}
+	return values, nil
+}
+
+type attribute struct {
+	typ   C.CK_ATTRIBUTE_TYPE
+	value []byte
+}
+
+func privateKeyTemplate(label string) []attribute {
+	tmpl := []attribute{
+		{C.CKA_CLASS, ulongValue(C.CKO_PRIVATE_KEY)},
+		{C.CKA_KEY_TYPE, ulongValue(C.CKK_RSA)},
+	}
+	if len(label) > 0 {
+		tmpl = append(tmpl, attribute{C.CKA_LABEL, []byte(label)})
+	}
+	return tmpl
+}
+
+func boolValue(b bool) []byte {
+	if b {
+		return []byte{C.CK_TRUE}
+	}
+	return []byte{C.CK_FALSE}
+}
+
+func ulongValue(v C.CK_ULONG) []byte {
+	return C.GoBytes(unsafe.Pointer(&v), C.int(unsafe.Sizeof(v)))
+}
+
+// newTemplate copies the attributes to C memory, as the template values may not
+// be Go pointers. The values are zeroed when the template is freed, since they
+// may hold private key material
+func newTemplate(tmpl []attribute) (*C.CK_ATTRIBUTE, C.CK_ULONG, func()) {
+	if len(tmpl) == 0 {
+		return nil, 0, func() {}
+	}
+
+	cTmpl := (*C.CK_ATTRIBUTE)(C.calloc(C.size_t(len(tmpl)), C.size_t(unsafe.Sizeof(C.CK_ATTRIBUTE{}))))
+	attrs := unsafe.Slice(cTmpl, len(tmpl))
+	for i, a := range tmpl {
+		attrs[i]._type = a.typ
+		if len(a.value) > 0 {
+			attrs[i].pValue = C.CBytes(a.value)
+			attrs[i].ulValueLen = C.CK_ULONG(len(a.value))
+		}
+	}
+
+	free := func() {
+		for i := range attrs {
+			if attrs[i].pValue != nil {
+				pkcs11.Zero(unsafe.Slice((*byte)(attrs[i].pValue), int(attrs[i].ulValueLen)))
+				C.free(attrs[i].pValue)
+			}
+		}
+		C.free(unsafe.Pointer(cTmpl))
+	}
+	return cTmpl, C.CK_ULONG(len(tmpl)), free
+}
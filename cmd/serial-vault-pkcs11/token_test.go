@@ -0,0 +1,131 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha512"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/CanonicalLtd/serial-vault/pkcs11"
+)
+
+// openTestToken opens the token set in the environment, e.g. for SoftHSMv2:
+//
+//	softhsm2-util --init-token --free --label serial-vault --pin 1234 --so-pin 1234
+//	SERIAL_VAULT_PKCS11_MODULE=/usr/lib/softhsm/libsofthsm2.so SERIAL_VAULT_PKCS11_TOKEN=serial-vault \
+//	    SERIAL_VAULT_PKCS11_PIN=1234 go test ./cmd/serial-vault-pkcs11/
+func openTestToken(t *testing.T) *Token {
+	if len(os.Getenv(pkcs11.EnvModule)) == 0 {
+		t.Skipf("%s is not set, skipping the PKCS#11 token tests", pkcs11.EnvModule)
+	}
+
+	token, err := OpenToken(os.Getenv(pkcs11.EnvModule), os.Getenv(pkcs11.EnvToken), os.Getenv(pkcs11.EnvPIN))
+	if err != nil {
+		t.Fatalf("Error opening the token: %v", err)
+	}
+	return token
+}
+
+func TestTokenImportSign(t *testing.T) {
+	token := openTestToken(t)
+	defer token.Close()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Error generating the key: %v", err)
+	}
+	label := fmt.Sprintf("test-key-%d", time.Now().UnixNano())
+
+	if err = token.ImportKey(label, key); err != nil {
+		t.Fatalf("Error importing the key: %v", err)
+	}
+
+	// Importing the same key again is a no-op
+	if err = token.ImportKey(label, key); err != nil {
+		t.Errorf("Error importing the key again: %v", err)
+	}
+
+	labels, err := token.KeyLabels()
+	if err != nil {
+		t.Fatalf("Error listing the keys: %v", err)
+	}
+	found := false
+	for _, l := range labels {
+		found = found || l == label
+	}
+	if !found {
+		t.Errorf("The key %s is not listed: %v", label, labels)
+	}
+
+	public, err := token.PublicKey(label)
+	if err != nil {
+		t.Fatalf("Error getting the public key: %v", err)
+	}
+	if public.N.Cmp(key.N) != 0 || public.E != key.E {
+		t.Error("The public key does not match the imported key")
+	}
+
+	digest := sha512.Sum512([]byte("message"))
+	digestInfo := append([]byte{0x30, 0x51, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x03, 0x05, 0x00, 0x04, 0x40}, digest[:]...)
+	signature, err := token.Sign(label, digestInfo)
+	if err != nil {
+		t.Fatalf("Error signing: %v", err)
+	}
+	if err = rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA512, digest[:], signature); err != nil {
+		t.Errorf("Invalid signature: %v", err)
+	}
+
+	// Each signature is a complete operation, so the session can sign again
+	if _, err = token.Sign(label, digestInfo); err != nil {
+		t.Errorf("Error signing again: %v", err)
+	}
+
+	// A different key cannot replace the key with the label
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Error generating the key: %v", err)
+	}
+	if err = token.ImportKey(label, other); err != pkcs11.ErrorKeyExists {
+		t.Errorf("Expected the key exists error, got: %v", err)
+	}
+
+	if _, err = token.Sign("unknown", digestInfo); err != pkcs11.ErrorKeyNotFound {
+		t.Errorf("Expected the key not found error, got: %v", err)
+	}
+}
+
+func TestOpenTokenErrors(t *testing.T) {
+	if _, err := OpenToken("/nonexistent/libpkcs11.so", "serial-vault", "1234"); err == nil {
+		t.Error("Expected an error loading an invalid module")
+	}
+
+	if len(os.Getenv(pkcs11.EnvModule)) > 0 {
+		if _, err := OpenToken(os.Getenv(pkcs11.EnvModule), "unknown-token-label", "1234"); err != pkcs11.ErrorTokenNotFound {
+			t.Errorf("Expected the token not found error, got: %v", err)
+		}
+	}
+}
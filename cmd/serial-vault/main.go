@@ -54,6 +54,12 @@ func main() {
 	// Open the connection to the local database
 	datastore.OpenSysDatabase(datastore.Environ.Config.Driver, datastore.Environ.Config.DataSource)
 
+	// Refuse to start on a database schema that is out of date
+	err = datastore.Environ.DB.CheckSchema()
+	if err != nil {
+		svlog.Fatalf("Error checking the database schema: %v", err)
+	}
+
 	// Opening the keypair manager to create the signing database
 	err = datastore.OpenKeyStore(datastore.Environ.Config)
 	if err != nil {
@@ -78,8 +84,20 @@ func main() {
 		if port == "" {
 			port = "8080"
 		}
+
+		// Remove the expired nonces in the background, for the lifetime of the service
+		go datastore.PruneDeviceNonces(nil)
 	}
 
+	// Evict the idle and disabled signing-keys from memory, for the lifetime of the service
+	go datastore.PruneUnsealedKeys(nil)
+
+	// Switch the models to their next signing-key at the scheduled time
+	go datastore.RolloverModels(nil)
+
+	// Record the keystore secret in use, so it is not rotated under the running service
+	go datastore.RecordKeystoreInstance(config.ServiceMode, nil)
+
 	svlog.Infof("Starting service on port %s", port)
 	log.Fatal(http.ListenAndServe(":"+port, handler))
 }
@@ -39,6 +39,7 @@ var revision = "unknown"
 type Settings struct {
 	Version        string
 	Revision       string
+	SettingsFile   string `yaml:"-"`
 	Title          string `yaml:"title"`
 	Logo           string `yaml:"logo"`
 	DocRoot        string `yaml:"docRoot"`
@@ -47,6 +48,7 @@ type Settings struct {
 	KeyStoreType   string `yaml:"keystore"`
 	KeyStorePath   string `yaml:"keystorePath"`
 	KeyStoreSecret string `yaml:"keystoreSecret"`
+	TPMDevice      string `yaml:"tpmDevice"`
 	Mode           string `yaml:"mode"`
 	CSRFAuthKey    string `yaml:"csrfAuthKey"`
 	URLHost        string `yaml:"urlHost"`
@@ -59,6 +61,54 @@ type Settings struct {
 	SyncUser       string `yaml:"syncUser"`
 	SyncAPIKey     string `yaml:"syncAPIKey"`
 	SentryDSN      string `yaml:"sentryDSN"`
+
+	// Nonce expiry time and the interval for pruning the expired nonces, in seconds
+	NonceTTL           int `yaml:"nonceTTL"`
+	NoncePruneInterval int `yaml:"noncePruneInterval"`
+
+	// Idle time after which an unsealed signing-key is evicted from memory, and the interval
+	// for evicting the idle and disabled signing-keys, in seconds
+	UnsealedKeyTTL           int `yaml:"unsealedKeyTTL"`
+	UnsealedKeyPruneInterval int `yaml:"unsealedKeyPruneInterval"`
+
+	// Number of days before its not-after date that a signing-key is reported as expiring
+	KeyExpiryWarningDays int `yaml:"keyExpiryWarningDays"`
+
+	// Require a second Superuser to approve the import, generation, enabling, store registration
+	// and deletion of signing-keys
+	DualControl bool `yaml:"dualControl"`
+
+	RateLimit RateLimitSettings `yaml:"rateLimit"`
+
+	// PKCS#11 keystore: the module and token that hold the signing-keys, and the
+	// key manager command that signs with them (defaults to serial-vault-pkcs11)
+	PKCS11Module     string `yaml:"pkcs11Module"`
+	PKCS11Token      string `yaml:"pkcs11Token"`
+	PKCS11PIN        string `yaml:"pkcs11PIN"`
+	PKCS11KeyManager string `yaml:"pkcs11KeyManager"`
+}
+
+// RateLimitRule defines the request rate and the daily signing quota for an API key.
+// A zero value disables the corresponding limit
+type RateLimitRule struct {
+	RequestsPerSecond float64 `yaml:"requestsPerSecond"`
+	Burst             int     `yaml:"burst"`
+	DailyQuota        int     `yaml:"dailyQuota"`
+}
+
+// RateLimitSettings defines the default rate limit of the signing service API keys,
+// with optional overrides for specific API keys
+type RateLimitSettings struct {
+	RateLimitRule `yaml:",inline"`
+	APIKeys       map[string]RateLimitRule `yaml:"apiKeys"`
+}
+
+// Rule returns the rate limit rule that applies to the API key
+func (s RateLimitSettings) Rule(apiKey string) RateLimitRule {
+	if rule, ok := s.APIKeys[apiKey]; ok {
+		return rule
+	}
+	return s.RateLimitRule
 }
 
 // SettingsFile is the path to the YAML configuration file
@@ -91,6 +141,7 @@ func ReadConfig(settings *Settings, filePath string) error {
 	// Set the application version and revision from the constant
 	settings.Version = version
 	settings.Revision = revision
+	settings.SettingsFile = filePath
 
 	// Set the service mode from the config file if it is not set
 	if ServiceMode == "" {
@@ -19,7 +19,11 @@
 
 package config
 
-import "testing"
+import (
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
 
 func TestReadConfig(t *testing.T) {
 	settings := Settings{}
@@ -27,6 +31,9 @@ func TestReadConfig(t *testing.T) {
 	if err != nil {
 		t.Errorf("Error reading config file: %v", err)
 	}
+	if settings.SettingsFile != "../settings.yaml.example" {
+		t.Errorf("Expected the path of the settings file, got: %s", settings.SettingsFile)
+	}
 }
 
 func TestReadConfigInvalidPath(t *testing.T) {
@@ -44,3 +51,21 @@ func TestReadConfigInvalidFile(t *testing.T) {
 		t.Error("Expected an error with an invalid config file.")
 	}
 }
+
+func TestRateLimitRule(t *testing.T) {
+	settings := Settings{}
+	source := []byte("rateLimit:\n  requestsPerSecond: 5\n  burst: 10\n  apiKeys:\n    factory:\n      dailyQuota: 100\n")
+	if err := yaml.Unmarshal(source, &settings); err != nil {
+		t.Fatalf("Error parsing the rate limit settings: %v", err)
+	}
+
+	rule := settings.RateLimit.Rule("other")
+	if rule.RequestsPerSecond != 5 || rule.Burst != 10 || rule.DailyQuota != 0 {
+		t.Errorf("Expected the default rate limit, got: %v", rule)
+	}
+
+	rule = settings.RateLimit.Rule("factory")
+	if rule.RequestsPerSecond != 0 || rule.Burst != 0 || rule.DailyQuota != 100 {
+		t.Errorf("Expected the API key rate limit, got: %v", rule)
+	}
+}
@@ -29,10 +29,12 @@ import (
 	"errors"
 	"io"
 	"strings"
+	"time"
 
 	"github.com/CanonicalLtd/serial-vault/service/log"
 
 	"github.com/snapcore/snapd/asserts"
+	"golang.org/x/crypto/openpgp"
 	"golang.org/x/crypto/openpgp/armor"
 	"golang.org/x/crypto/openpgp/packet"
 )
@@ -52,7 +54,8 @@ func CreateSecret(length int) (string, error) {
 	return base64.URLEncoding.EncodeToString(rb), nil
 }
 
-// EncryptKey uses symmetric encryption to encrypt the data for storage
+// EncryptKey uses symmetric encryption to encrypt the data for storage, in the legacy
+// format without an integrity check. Keys are now sealed with SealKey
 func EncryptKey(plainTextKey, keyText string) ([]byte, error) {
 	// The AES key needs to be 16 or 32 bytes i.e. AES-128 or AES-256
 	aesKey := padRight(keyText, "x", 32)
@@ -78,7 +81,7 @@ func EncryptKey(plainTextKey, keyText string) ([]byte, error) {
 	return ciphertext, nil
 }
 
-// DecryptKey handles the decryption of a sealed signing key
+// DecryptKey handles the decryption of a sealed signing key in the legacy format
 func DecryptKey(sealedKey []byte, keyText string) ([]byte, error) {
 	aesKey := padRight(keyText, "x", 32)
 
@@ -114,7 +117,46 @@ func DeserializePrivateKey(base64PrivateKey string) (asserts.PrivateKey, string,
 	return privateKeyToAssertsKey(decodedPrivateKey)
 }
 
+// DeserializeRSAPrivateKey decodes a base64 encoded private key file and returns
+// the RSA private key, for keypair stores that need the raw key material
+func DeserializeRSAPrivateKey(base64PrivateKey string) (*rsa.PrivateKey, string, error) {
+	decodedPrivateKey, err := base64.StdEncoding.DecodeString(base64PrivateKey)
+	if err != nil {
+		return nil, "error-decode-key", err
+	}
+
+	return decodeRSAPrivateKey(decodedPrivateKey)
+}
+
+// SerializePrivateKey encodes an RSA private key as an ascii-armored, base64 encoded
+// private key file, the format that DeserializePrivateKey reads
+func SerializePrivateKey(rsaKey *rsa.PrivateKey) (string, error) {
+	buf := new(bytes.Buffer)
+	w, err := armor.Encode(buf, openpgp.PrivateKeyType, nil)
+	if err != nil {
+		return "", err
+	}
+
+	pkt := packet.NewRSAPrivateKey(time.Now(), rsaKey)
+	if err := pkt.Serialize(w); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
 func privateKeyToAssertsKey(key []byte) (asserts.PrivateKey, string, error) {
+	rsaKey, errorCode, err := decodeRSAPrivateKey(key)
+	if err != nil {
+		return nil, errorCode, err
+	}
+	return asserts.RSAPrivateKey(rsaKey), "", nil
+}
+
+func decodeRSAPrivateKey(key []byte) (*rsa.PrivateKey, string, error) {
 	const errorInvalidKey = "invalid-keypair"
 
 	// Validate the signing-key
@@ -132,10 +174,11 @@ func privateKeyToAssertsKey(key []byte) (asserts.PrivateKey, string, error) {
 	if !ok {
 		return nil, errorInvalidKey, errors.New("Not a private key")
 	}
-	if _, ok := privk.PrivateKey.(*rsa.PrivateKey); !ok {
+	rsaKey, ok := privk.PrivateKey.(*rsa.PrivateKey)
+	if !ok {
 		return nil, errorInvalidKey, errors.New("Not an RSA private key")
 	}
-	return asserts.RSAPrivateKey(privk.PrivateKey.(*rsa.PrivateKey)), "", nil
+	return rsaKey, "", nil
 }
 
 // padRight truncates a string to a specific length, padding with a named
@@ -20,9 +20,13 @@
 package crypt
 
 import (
+	"crypto/rand"
+	"crypto/rsa"
 	"encoding/base64"
 	"io/ioutil"
 	"testing"
+
+	"github.com/snapcore/snapd/asserts"
 )
 
 func TestEncryptDecrypt(t *testing.T) {
@@ -80,3 +84,114 @@ func TestDeserializePrivateKey(t *testing.T) {
 		t.Errorf("Error deserializing the test key: %v", err)
 	}
 }
+
+func TestDeserializeRSAPrivateKey(t *testing.T) {
+	signingKey, err := ioutil.ReadFile("../keystore/TestKey.asc")
+	if err != nil {
+		t.Errorf("Error reading the signing-key file: %v", err)
+	}
+	base64PrivateKey := base64.StdEncoding.EncodeToString(signingKey)
+
+	rsaKey, _, err := DeserializeRSAPrivateKey(base64PrivateKey)
+	if err != nil {
+		t.Fatalf("Error deserializing the test key: %v", err)
+	}
+	if err = rsaKey.Validate(); err != nil {
+		t.Errorf("Invalid RSA key: %v", err)
+	}
+
+	_, errorCode, err := DeserializeRSAPrivateKey(base64.StdEncoding.EncodeToString([]byte("invalid")))
+	if err == nil || errorCode != "invalid-keypair" {
+		t.Errorf("Expected an invalid keypair error, got: %s %v", errorCode, err)
+	}
+}
+
+func TestSerializePrivateKey(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Error generating the test key: %v", err)
+	}
+
+	base64PrivateKey, err := SerializePrivateKey(rsaKey)
+	if err != nil {
+		t.Fatalf("Error serializing the test key: %v", err)
+	}
+
+	privateKey, _, err := DeserializePrivateKey(base64PrivateKey)
+	if err != nil {
+		t.Fatalf("Error deserializing the test key: %v", err)
+	}
+	if privateKey.PublicKey().ID() != asserts.RSAPrivateKey(rsaKey).PublicKey().ID() {
+		t.Errorf("Expected the deserialized key to match the generated key")
+	}
+}
+
+func TestSealUnsealKey(t *testing.T) {
+	secret := "secret code to encrypt the auth-key hash"
+	plainText := []byte("fake-hmac-ed-data")
+
+	sealed, err := SealKey(plainText, secret)
+	if err != nil {
+		t.Fatalf("Error sealing the key: %v", err)
+	}
+	if IsLegacySealedKey(sealed) {
+		t.Error("Expected the sealed key to use the envelope")
+	}
+
+	unsealed, err := UnsealKey(sealed, secret)
+	if err != nil {
+		t.Fatalf("Error unsealing the key: %v", err)
+	}
+	if string(unsealed) != string(plainText) {
+		t.Errorf("Expected %s, got: %s", plainText, unsealed)
+	}
+
+	// The same data is sealed differently each time
+	sealedAgain, _ := SealKey(plainText, secret)
+	if string(sealedAgain) == string(sealed) {
+		t.Error("Expected a random salt and nonce")
+	}
+
+	if _, err = UnsealKey(sealed, "wrong secret"); err != ErrorUnsealKey {
+		t.Errorf("Expected the unseal error for the wrong secret, got: %v", err)
+	}
+
+	// Tampering with the header, the nonce or the ciphertext is detected
+	for _, i := range []int{6, 25, 35, len(sealed) - 1} {
+		tampered := append([]byte{}, sealed...)
+		tampered[i] ^= 0x01
+		if _, err = UnsealKey(tampered, secret); err != ErrorUnsealKey {
+			t.Errorf("Expected the unseal error for tampered byte %d, got: %v", i, err)
+		}
+	}
+
+	if _, err = UnsealKey(sealed[:30], secret); err != ErrorSealedKeyTruncated {
+		t.Errorf("Expected the truncated error, got: %v", err)
+	}
+
+	unknownVersion := append([]byte{}, sealed...)
+	unknownVersion[4] = 0x7f
+	if _, err = UnsealKey(unknownVersion, secret); err != ErrorSealedKeyVersion {
+		t.Errorf("Expected the version error, got: %v", err)
+	}
+}
+
+func TestUnsealLegacyKey(t *testing.T) {
+	secret := "this needs to be 32 bytes long!!"
+
+	legacy, err := EncryptKey("fake-hmac-ed-data", secret)
+	if err != nil {
+		t.Fatalf("Error encrypting text: %v", err)
+	}
+	if !IsLegacySealedKey(legacy) {
+		t.Error("Expected the legacy format")
+	}
+
+	unsealed, err := UnsealKey(legacy, secret)
+	if err != nil {
+		t.Fatalf("Error unsealing the legacy key: %v", err)
+	}
+	if string(unsealed) != "fake-hmac-ed-data" {
+		t.Errorf("Invalid decryption of the legacy key: %s", unsealed)
+	}
+}
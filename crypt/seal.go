@@ -0,0 +1,145 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package crypt
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// Sealed-key envelope, version 1:
+//
+//	magic "SVKE" | version 0x01 | salt (16 bytes) | nonce (12 bytes) | AES-256-GCM ciphertext and tag
+//
+// The AES key is derived from the key text with scrypt (N=32768, r=8, p=1) and the salt.
+// The header is authenticated as additional data, so a tampered envelope fails to unseal.
+// Data without the magic prefix is the legacy AES-CFB format of EncryptKey.
+const (
+	sealedKeyVersion1 = 0x01
+
+	sealedKeySaltSize = 16
+	sealedKeyScryptN  = 1 << 15
+	sealedKeyScryptR  = 8
+	sealedKeyScryptP  = 1
+)
+
+var sealedKeyMagic = []byte("SVKE")
+
+// Common error messages
+var (
+	ErrorUnsealKey          = errors.New("Cannot unseal the key: it has been tampered with or the secret is wrong")
+	ErrorSealedKeyVersion   = errors.New("Unsupported version of the sealed key")
+	ErrorSealedKeyTruncated = errors.New("The sealed key is truncated")
+)
+
+// SealKey uses authenticated encryption to seal the data for storage, in a versioned envelope
+func SealKey(plainText []byte, keyText string) ([]byte, error) {
+	header := make([]byte, len(sealedKeyMagic)+1+sealedKeySaltSize)
+	copy(header, sealedKeyMagic)
+	header[len(sealedKeyMagic)] = sealedKeyVersion1
+	salt := header[len(sealedKeyMagic)+1:]
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+
+	aead, err := sealedKeyCipher(keyText, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	sealed := append(header, nonce...)
+	return aead.Seal(sealed, nonce, plainText, header), nil
+}
+
+// UnsealKey decrypts data that was sealed with SealKey. Data in the legacy format
+// of EncryptKey is decrypted with DecryptKey, as it cannot be authenticated
+func UnsealKey(sealed []byte, keyText string) ([]byte, error) {
+	if IsLegacySealedKey(sealed) {
+		return DecryptKey(sealed, keyText)
+	}
+
+	headerSize := len(sealedKeyMagic) + 1 + sealedKeySaltSize
+	if len(sealed) < headerSize {
+		return nil, ErrorSealedKeyTruncated
+	}
+	if sealed[len(sealedKeyMagic)] != sealedKeyVersion1 {
+		return nil, ErrorSealedKeyVersion
+	}
+	header := sealed[:headerSize]
+
+	aead, err := sealedKeyCipher(keyText, header[len(sealedKeyMagic)+1:])
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sealed) < headerSize+aead.NonceSize()+aead.Overhead() {
+		return nil, ErrorSealedKeyTruncated
+	}
+	nonce := sealed[headerSize : headerSize+aead.NonceSize()]
+
+	plainText, err := aead.Open(nil, nonce, sealed[headerSize+aead.NonceSize():], header)
+	if err != nil {
+		return nil, ErrorUnsealKey
+	}
+	return plainText, nil
+}
+
+// IsLegacySealedKey checks if the data is in the legacy format of EncryptKey, which
+// has no envelope. There is a 1 in 2^32 chance that the random IV of a legacy key
+// starts with the magic prefix
+func IsLegacySealedKey(sealed []byte) bool {
+	return !bytes.HasPrefix(sealed, sealedKeyMagic)
+}
+
+func sealedKeyCipher(keyText string, salt []byte) (cipher.AEAD, error) {
+	aesKey, err := scrypt.Key([]byte(keyText), salt, sealedKeyScryptN, sealedKeyScryptR, sealedKeyScryptP, 32)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(aesKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// SecretFingerprint identifies a secret without revealing it, so services can show
+// which secret they use. The fingerprint is derived with scrypt to slow down guessing
+func SecretFingerprint(secret string) string {
+	fingerprint, err := scrypt.Key([]byte(secret), []byte("serial-vault secret fingerprint"), sealedKeyScryptN, sealedKeyScryptR, sealedKeyScryptP, 16)
+	if err != nil {
+		return ""
+	}
+	return hex.EncodeToString(fingerprint)
+}
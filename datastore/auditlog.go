@@ -0,0 +1,219 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package datastore
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/CanonicalLtd/serial-vault/service/log"
+)
+
+// redactedValue replaces the value of a secret field in the audit log
+const redactedValue = "[redacted]"
+
+// auditSecretFields are the fields that are not written to the audit log. A change to
+// them is recorded, but not their values. The names are in lower case without dashes
+var auditSecretFields = map[string]bool{
+	"apikey":        true,
+	"sealedkey":     true,
+	"sealedkeyuser": true,
+	"authkeyhash":   true,
+	"privatekey":    true,
+}
+
+var auditLogExportHeader = []string{"id", "created", "actor", "role", "action", "entity", "entity_id", "diff", "source_ip"}
+
+// auditChange is the value of a field before and after a change
+type auditChange struct {
+	Old interface{} `json:"old"`
+	New interface{} `json:"new"`
+}
+
+// auditLogExportRecord is a line of the JSON Lines export, with the changes as JSON
+type auditLogExportRecord struct {
+	ID       int             `json:"id"`
+	Created  time.Time       `json:"created"`
+	Actor    string          `json:"actor"`
+	Role     int             `json:"role"`
+	Action   string          `json:"action"`
+	Entity   string          `json:"entity"`
+	EntityID int             `json:"entity_id"`
+	Diff     json.RawMessage `json:"diff"`
+	SourceIP string          `json:"source_ip"`
+}
+
+// RecordAudit stores the change that a user made to an entity in the audit log. The entity
+// is nil before it is created and after it is deleted, and its ID is 0 when it is not known.
+// The change has already been made, so an error storing the audit log is logged rather than returned
+func RecordAudit(user User, sourceIP, action, entity string, entityID int, before, after interface{}) {
+	diff, err := AuditDiff(before, after)
+	if err != nil {
+		log.Printf("Error recording the %s of %s %d in the audit log: %v\n", action, entity, entityID, err)
+		return
+	}
+
+	auditLog := AuditLog{
+		Actor:    user.Username,
+		Role:     user.Role,
+		Action:   action,
+		Entity:   entity,
+		EntityID: entityID,
+		Diff:     diff,
+		SourceIP: sourceIP,
+	}
+	if err := Environ.DB.CreateAuditLog(auditLog); err != nil {
+		log.Printf("Error recording the %s of %s %d in the audit log: %v\n", action, entity, entityID, err)
+	}
+}
+
+// AuditDiff returns the fields of the entity that are different after the change, with
+// their old and new values as JSON. The values of secret fields are redacted
+func AuditDiff(before, after interface{}) (string, error) {
+	oldFields, err := auditFields(before)
+	if err != nil {
+		return "", err
+	}
+	newFields, err := auditFields(after)
+	if err != nil {
+		return "", err
+	}
+
+	changes := map[string]auditChange{}
+	for k, v := range oldFields {
+		if !reflect.DeepEqual(v, newFields[k]) {
+			changes[k] = auditChange{Old: redactAudit(k, v), New: redactAudit(k, newFields[k])}
+		}
+	}
+	for k, v := range newFields {
+		if _, ok := oldFields[k]; !ok {
+			changes[k] = auditChange{New: redactAudit(k, v)}
+		}
+	}
+
+	// The keys of the map are sorted, so the same change always has the same diff
+	diff, err := json.Marshal(changes)
+	return string(diff), err
+}
+
+// auditFields converts the entity to its fields, as they are encoded in the API
+func auditFields(entity interface{}) (map[string]interface{}, error) {
+	fields := map[string]interface{}{}
+	if entity == nil || reflect.ValueOf(entity).Kind() == reflect.Ptr && reflect.ValueOf(entity).IsNil() {
+		return fields, nil
+	}
+
+	data, err := json.Marshal(entity)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(data, &fields)
+	return fields, err
+}
+
+// redactAudit replaces the values of the secret fields, including the fields of nested entities
+func redactAudit(key string, value interface{}) interface{} {
+	if auditSecretFields[strings.ToLower(strings.NewReplacer("-", "", "_", "").Replace(key))] {
+		if value == nil || value == "" {
+			return value
+		}
+		return redactedValue
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		redacted := map[string]interface{}{}
+		for k, field := range v {
+			redacted[k] = redactAudit(k, field)
+		}
+		return redacted
+	case []interface{}:
+		redacted := make([]interface{}, len(v))
+		for i, item := range v {
+			redacted[i] = redactAudit("", item)
+		}
+		return redacted
+	default:
+		return value
+	}
+}
+
+// ExportAuditLog writes the audit log in the format, streaming it from the database.
+// The number of records that were written is returned
+func ExportAuditLog(w io.Writer, format string, params *AuditLogParams) (int, error) {
+	if err := ValidExportFormat(format); err != nil {
+		return 0, err
+	}
+
+	var (
+		write func(AuditLog) error
+		flush func() error
+	)
+
+	switch format {
+	case ExportFormatCSV:
+		c := csv.NewWriter(w)
+		if err := c.Write(auditLogExportHeader); err != nil {
+			return 0, err
+		}
+		write = func(l AuditLog) error {
+			return c.Write([]string{strconv.Itoa(l.ID), l.Created.UTC().Format(time.RFC3339), l.Actor, strconv.Itoa(l.Role),
+				l.Action, l.Entity, strconv.Itoa(l.EntityID), l.Diff, l.SourceIP})
+		}
+		flush = func() error {
+			c.Flush()
+			return c.Error()
+		}
+	default:
+		e := json.NewEncoder(w)
+		write = func(l AuditLog) error {
+			diff := json.RawMessage(l.Diff)
+			if !json.Valid(diff) {
+				diff = json.RawMessage("{}")
+			}
+			return e.Encode(auditLogExportRecord{ID: l.ID, Created: l.Created.UTC(), Actor: l.Actor, Role: l.Role,
+				Action: l.Action, Entity: l.Entity, EntityID: l.EntityID, Diff: diff, SourceIP: l.SourceIP})
+		}
+		flush = func() error { return nil }
+	}
+
+	count := 0
+	err := Environ.DB.StreamAuditLog(params, func(l AuditLog) error {
+		if err := write(l); err != nil {
+			return err
+		}
+		count++
+		if count%exportFlushInterval == 0 {
+			return flushExport(w, flush)
+		}
+		return nil
+	})
+	if err != nil {
+		return count, err
+	}
+
+	return count, flushExport(w, flush)
+}
@@ -0,0 +1,182 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package datastore
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CanonicalLtd/serial-vault/config"
+)
+
+// setUpAuditLog records changes by two users in the audit log
+func setUpAuditLog(t *testing.T) *DB {
+	db := openKeystoreTestDatabase(t)
+	if err := db.CreateAuditLogTable(); err != nil {
+		t.Fatalf("Error creating the audit log table: %v", err)
+	}
+
+	env := Environ
+	Environ = &Env{DB: db, Config: config.Settings{Driver: "sqlite3"}}
+	t.Cleanup(func() { Environ = env })
+
+	root := User{Username: "root", Role: Superuser}
+	sv := User{Username: "sv", Role: Admin}
+	RecordAudit(root, "10.0.0.1", AuditActionCreate, AuditEntityModel, 1, nil, Model{ID: 1, BrandID: "system", Name: "alder", APIKey: "secret"})
+	RecordAudit(sv, "10.0.0.2", AuditActionUpdate, AuditEntityModel, 1, Model{ID: 1, BrandID: "system", Name: "alder", APIKey: "secret"},
+		Model{ID: 1, BrandID: "system", Name: "ash", APIKey: "changed"})
+	RecordAudit(root, "10.0.0.1", AuditActionDisable, AuditEntityKeypair, 2, Keypair{ID: 2, Active: true}, Keypair{ID: 2})
+	RecordAudit(root, "10.0.0.1", AuditActionUpdate, AuditEntityUser, 3, User{ID: 3, Username: "sv", Role: Admin}, User{ID: 3, Username: "sv", Role: Superuser})
+	return db
+}
+
+func TestAuditDiff(t *testing.T) {
+	tests := []struct {
+		before interface{}
+		after  interface{}
+		diff   string
+	}{
+		{nil, Account{ID: 1, AuthorityID: "system"}, `{"Assertion":{"old":null,"new":""},"AuthorityID":{"old":null,"new":"system"},"ID":{"old":null,"new":1},"ResellerAPI":{"old":null,"new":false}}`},
+		{Account{ID: 1, AuthorityID: "system"}, nil, `{"Assertion":{"old":"","new":null},"AuthorityID":{"old":"system","new":null},"ID":{"old":1,"new":null},"ResellerAPI":{"old":false,"new":null}}`},
+		{Account{ID: 1, AuthorityID: "system"}, Account{ID: 1, AuthorityID: "system", ResellerAPI: true}, `{"ResellerAPI":{"old":false,"new":true}}`},
+		{Account{ID: 1}, Account{ID: 1}, `{}`},
+		{User{ID: 1, APIKey: "old"}, User{ID: 1, APIKey: "new"}, `{"APIKey":{"old":"[redacted]","new":"[redacted]"}}`},
+		{Model{ID: 1}, Model{ID: 1, APIKey: "new"}, `{"api-key":{"old":"","new":"[redacted]"}}`},
+		{Keypair{ID: 1, SealedKey: "old"}, Keypair{ID: 1, SealedKey: "new"}, `{"SealedKey":{"old":"[redacted]","new":"[redacted]"}}`},
+		{Substore{ID: 1, FromModel: Model{APIKey: "old"}}, Substore{ID: 1, FromModel: Model{APIKey: "new"}}, `"api-key":"[redacted]"`},
+	}
+
+	for _, tt := range tests {
+		diff, err := AuditDiff(tt.before, tt.after)
+		if err != nil {
+			t.Fatalf("Error comparing the entities: %v", err)
+		}
+		if !strings.Contains(diff, tt.diff) {
+			t.Errorf("Expected the diff %s, got: %s", tt.diff, diff)
+		}
+		if strings.Contains(diff, `"old":"old"`) || strings.Contains(diff, `"new":"new"`) || strings.Contains(diff, `"api-key":"new"`) {
+			t.Errorf("Expected the secrets to be redacted: %s", diff)
+		}
+	}
+}
+
+func TestListAuditLog(t *testing.T) {
+	setUpAuditLog(t)
+
+	tests := []struct {
+		params  AuditLogParams
+		total   int
+		actions []string
+	}{
+		{AuditLogParams{}, 4, []string{"update", "disable", "update", "create"}},
+		{AuditLogParams{Limit: 2}, 4, []string{"update", "disable"}},
+		{AuditLogParams{Limit: 2, Offset: 3}, 4, []string{"create"}},
+		{AuditLogParams{Actor: "sv"}, 1, []string{"update"}},
+		{AuditLogParams{Entity: AuditEntityModel, EntityID: 1}, 2, []string{"update", "create"}},
+		{AuditLogParams{Action: AuditActionDisable}, 1, []string{"disable"}},
+		{AuditLogParams{Actor: "nobody"}, 0, []string{}},
+	}
+
+	for _, tt := range tests {
+		page, err := Environ.DB.ListAuditLog(&tt.params)
+		if err != nil {
+			t.Fatalf("Error listing the audit log: %v", err)
+		}
+		if page.Total != tt.total {
+			t.Errorf("Expected a total of %d, got: %d", tt.total, page.Total)
+		}
+		actions := []string{}
+		for _, l := range page.Logs {
+			actions = append(actions, l.Action)
+		}
+		if strings.Join(actions, ",") != strings.Join(tt.actions, ",") {
+			t.Errorf("Expected the actions %v, got: %v", tt.actions, actions)
+		}
+	}
+
+	page, err := Environ.DB.ListAuditLog(&AuditLogParams{Action: AuditActionUpdate, Entity: AuditEntityModel})
+	if err != nil || len(page.Logs) != 1 {
+		t.Fatalf("Expected the model update in the audit log, got: %v, %v", page.Logs, err)
+	}
+	l := page.Logs[0]
+	if l.Actor != "sv" || l.Role != Admin || l.SourceIP != "10.0.0.2" || l.Created.IsZero() {
+		t.Errorf("Unexpected audit log: %v", l)
+	}
+	if l.Diff != `{"api-key":{"old":"[redacted]","new":"[redacted]"},"model":{"old":"alder","new":"ash"}}` {
+		t.Errorf("Unexpected changes in the audit log: %s", l.Diff)
+	}
+
+	// The audit log of a change is not stored without the action
+	if err := Environ.DB.CreateAuditLog(AuditLog{Entity: AuditEntityModel}); err == nil {
+		t.Error("Expected an error creating an audit log without an action")
+	}
+}
+
+func TestExportAuditLog(t *testing.T) {
+	setUpAuditLog(t)
+
+	var b bytes.Buffer
+	count, err := ExportAuditLog(&b, ExportFormatCSV, &AuditLogParams{Actor: "root"})
+	if err != nil {
+		t.Fatalf("Error exporting the audit log: %v", err)
+	}
+	if count != 3 {
+		t.Errorf("Expected 3 records, got: %d", count)
+	}
+	records, err := csv.NewReader(&b).ReadAll()
+	if err != nil {
+		t.Fatalf("Error reading the CSV export: %v", err)
+	}
+	if strings.Join(records[0], ",") != "id,created,actor,role,action,entity,entity_id,diff,source_ip" {
+		t.Errorf("Unexpected CSV header: %v", records[0])
+	}
+	if len(records) != 4 || records[1][4] != "create" || records[3][4] != "update" || records[3][5] != "user" {
+		t.Errorf("Expected the oldest changes first, got: %v", records)
+	}
+
+	// The changes are JSON in the JSON Lines export
+	b.Reset()
+	tomorrow := time.Now().AddDate(0, 0, 1)
+	count, err = ExportAuditLog(&b, ExportFormatJSONL, &AuditLogParams{Entity: AuditEntityUser, To: &tomorrow})
+	if err != nil || count != 1 {
+		t.Fatalf("Expected 1 record, got: %d, %v", count, err)
+	}
+	lines := []auditLogExportRecord{}
+	scanner := bufio.NewScanner(&b)
+	for scanner.Scan() {
+		r := auditLogExportRecord{}
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			t.Fatalf("Error reading the JSONL export: %v", err)
+		}
+		lines = append(lines, r)
+	}
+	if len(lines) != 1 || lines[0].EntityID != 3 || string(lines[0].Diff) != `{"Role":{"old":200,"new":300}}` {
+		t.Errorf("Unexpected JSONL export: %v", lines)
+	}
+
+	if _, err := ExportAuditLog(&b, "xml", &AuditLogParams{}); err == nil {
+		t.Error("Expected an error exporting in an invalid format")
+	}
+}
@@ -0,0 +1,239 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package datastore
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/CanonicalLtd/serial-vault/service/log"
+	sq "github.com/Masterminds/squirrel"
+)
+
+const createAuditLogTableSQL = `
+	CREATE TABLE IF NOT EXISTS audit_log (
+		id          serial primary key not null,
+		created     timestamp default current_timestamp,
+		actor       varchar(200) not null default '',
+		role        int not null default 0,
+		action      varchar(50) not null,
+		entity      varchar(50) not null,
+		entity_id   int not null default 0,
+		diff        text not null default '',
+		source_ip   varchar(200) not null default ''
+	)
+`
+
+// Indexes
+const createAuditLogCreatedIndexSQL = "CREATE INDEX IF NOT EXISTS audit_log_created_idx ON audit_log (created)"
+const createAuditLogEntityIndexSQL = "CREATE INDEX IF NOT EXISTS audit_log_entity_idx ON audit_log (entity, entity_id)"
+
+const createAuditLogSQL = `
+	INSERT INTO audit_log
+	(actor, role, action, entity, entity_id, diff, source_ip)
+	VALUES ($1,$2,$3,$4,$5,$6,$7)`
+
+// sqlite3 syntax for creating an audit log, generating our own ID
+const maxIDAuditLogSQLite = "SELECT coalesce(max(id), 0)+1 FROM audit_log"
+const createAuditLogSQLite = `
+	INSERT INTO audit_log
+	(id, actor, role, action, entity, entity_id, diff, source_ip)
+	VALUES ($1,$2,$3,$4,$5,$6,$7,$8)`
+
+// The actions that are recorded in the audit log
+const (
+	AuditActionCreate   = "create"
+	AuditActionUpdate   = "update"
+	AuditActionDelete   = "delete"
+	AuditActionGenerate = "generate"
+	AuditActionEnable   = "enable"
+	AuditActionDisable  = "disable"
+	AuditActionApprove  = "approve"
+	AuditActionReject   = "reject"
+)
+
+// The entities that are changed by the audited actions
+const (
+	AuditEntityAccount   = "account"
+	AuditEntityKeypair   = "keypair"
+	AuditEntityModel     = "model"
+	AuditEntityOperation = "operation"
+	AuditEntitySubstore  = "substore"
+	AuditEntityUser      = "user"
+)
+
+// ListAuditLogDefaultLimit is the default limit for the search of the audit log
+const ListAuditLogDefaultLimit = 50
+
+// auditLogColumns are the columns of an audit log that are listed
+var auditLogColumns = []string{"id", "created", "actor", "role", "action", "entity", "entity_id", "diff", "source_ip"}
+
+// AuditLog records the change that a user made to an entity, with the fields of the entity
+// that were changed (as JSON) and the IP address that the request came from
+type AuditLog struct {
+	ID       int       `json:"id"`
+	Created  time.Time `json:"created"`
+	Actor    string    `json:"actor"`
+	Role     int       `json:"role"`
+	Action   string    `json:"action"`
+	Entity   string    `json:"entity"`
+	EntityID int       `json:"entityid"`
+	Diff     string    `json:"diff"`
+	SourceIP string    `json:"sourceip"`
+}
+
+// AuditLogParams holds the parameters for the search of the audit log
+type AuditLogParams struct {
+	Limit    uint64 // 0 means no LIMIT here
+	Offset   uint64
+	Actor    string
+	Action   string
+	Entity   string
+	EntityID int        // 0 means any entity
+	From     *time.Time // created at or after
+	To       *time.Time // created before
+}
+
+// AuditLogPage is a page of the audit log search, with the number of records that match the search
+type AuditLogPage struct {
+	Logs  []AuditLog
+	Total int
+}
+
+// CreateAuditLogTable creates the database table for the audit log
+func (db *DB) CreateAuditLogTable() error {
+	for _, s := range []string{createAuditLogTableSQL, createAuditLogCreatedIndexSQL, createAuditLogEntityIndexSQL} {
+		if _, err := db.Exec(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CreateAuditLog stores an entry in the audit log
+func (db *DB) CreateAuditLog(auditLog AuditLog) error {
+	if !validateStringsNotEmpty(auditLog.Action, auditLog.Entity) {
+		return fmt.Errorf("the action and entity of the audit log must be supplied")
+	}
+
+	if !InFactory() {
+		_, err := db.Exec(createAuditLogSQL, auditLog.Actor, auditLog.Role, auditLog.Action, auditLog.Entity,
+			auditLog.EntityID, auditLog.Diff, auditLog.SourceIP)
+		return err
+	}
+
+	// Need to generate our own ID
+	return db.transaction(func(tx *sql.Tx) error {
+		var id int
+		if err := tx.QueryRow(maxIDAuditLogSQLite).Scan(&id); err != nil {
+			return err
+		}
+		_, err := tx.Exec(createAuditLogSQLite, id, auditLog.Actor, auditLog.Role, auditLog.Action, auditLog.Entity,
+			auditLog.EntityID, auditLog.Diff, auditLog.SourceIP)
+		return err
+	})
+}
+
+// ListAuditLog fetches a page of the audit log, the newest first
+func (db *DB) ListAuditLog(params *AuditLogParams) (AuditLogPage, error) {
+	page := AuditLogPage{Logs: []AuditLog{}}
+
+	listSQL := auditLogFilterBuilder(sq.Select(auditLogColumns...), params).OrderBy("id DESC")
+	if params.Limit > 0 {
+		listSQL = listSQL.Limit(params.Limit)
+	}
+	if params.Offset > 0 {
+		listSQL = listSQL.Offset(params.Offset)
+	}
+
+	err := db.scanAuditLog(listSQL, func(l AuditLog) error {
+		page.Logs = append(page.Logs, l)
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error retrieving the audit log: %v\n", err)
+		return page, err
+	}
+
+	err = auditLogFilterBuilder(sq.Select("count(*)"), params).RunWith(db).QueryRow().Scan(&page.Total)
+	if err != nil {
+		log.Printf("Error counting the audit log: %v\n", err)
+		return page, err
+	}
+
+	return page, nil
+}
+
+// StreamAuditLog passes each record of the audit log that matches the search to the function,
+// the oldest first, as it is read from the database cursor
+func (db *DB) StreamAuditLog(params *AuditLogParams, fn func(AuditLog) error) error {
+	err := db.scanAuditLog(auditLogFilterBuilder(sq.Select(auditLogColumns...), params).OrderBy("id"), fn)
+	if err != nil {
+		log.Printf("Error exporting the audit log: %v\n", err)
+	}
+	return err
+}
+
+func (db *DB) scanAuditLog(query sq.SelectBuilder, fn func(AuditLog) error) error {
+	rows, err := query.RunWith(db).Query()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		l := AuditLog{}
+		err := rows.Scan(&l.ID, &l.Created, &l.Actor, &l.Role, &l.Action, &l.Entity, &l.EntityID, &l.Diff, &l.SourceIP)
+		if err != nil {
+			return err
+		}
+		if err := fn(l); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+func auditLogFilterBuilder(sql sq.SelectBuilder, params *AuditLogParams) sq.SelectBuilder {
+	sql = sql.From("audit_log").PlaceholderFormat(sq.Dollar)
+
+	if len(params.Actor) > 0 {
+		sql = sql.Where(sq.Eq{"actor": params.Actor})
+	}
+	if len(params.Action) > 0 {
+		sql = sql.Where(sq.Eq{"action": params.Action})
+	}
+	if len(params.Entity) > 0 {
+		sql = sql.Where(sq.Eq{"entity": params.Entity})
+	}
+	if params.EntityID > 0 {
+		sql = sql.Where(sq.Eq{"entity_id": params.EntityID})
+	}
+	if params.From != nil {
+		sql = sql.Where(sq.GtOrEq{"created": *params.From})
+	}
+	if params.To != nil {
+		sql = sql.Where(sq.Lt{"created": *params.To})
+	}
+	return sql
+}
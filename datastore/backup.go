@@ -0,0 +1,322 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package datastore
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/CanonicalLtd/serial-vault/crypt"
+)
+
+// A backup archive is the JSON encoding of the Backup, compressed with gzip and sealed with the
+// passphrase of the operator, in the envelope of crypt.SealKey
+const backupVersion = 1
+
+// The tables that every backup must hold
+var backupRequiredTables = []string{"account", "keypair", "settings", "model"}
+
+// Common error messages
+var (
+	ErrorBackupPassphraseEmpty = errors.New("The backup passphrase cannot be empty")
+	ErrorBackupArchive         = errors.New("The file is not a backup archive")
+	ErrorBackupVersion         = errors.New("Unsupported version of the backup archive")
+)
+
+// Backup holds the database tables and the keystore material of the vault
+type Backup struct {
+	Version        int           `json:"version"`
+	Created        time.Time     `json:"created"`
+	Driver         string        `json:"driver"`
+	KeystoreType   string        `json:"keystore"`
+	KeystoreSecret string        `json:"keystore-secret"`
+	Tables         []BackupTable `json:"tables"`
+	Files          []BackupFile  `json:"files"`
+}
+
+// BackupFile is a file of the keystore path in a backup e.g. the TPM 2.0 key contexts
+type BackupFile struct {
+	Path string      `json:"path"`
+	Mode os.FileMode `json:"mode"`
+	Data []byte      `json:"data"`
+}
+
+// BackupKeypairCheck is the result of unsealing a signing-key of a backup
+type BackupKeypairCheck struct {
+	AuthorityID string
+	KeyName     string
+	Err         error
+}
+
+// Table returns a table of the backup
+func (b Backup) Table(name string) (BackupTable, bool) {
+	for _, t := range b.Tables {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return BackupTable{}, false
+}
+
+// CreateBackup reads the database tables and the keystore files for a backup
+func CreateBackup(signingLog bool) (Backup, error) {
+	backup := Backup{
+		Version:        backupVersion,
+		Created:        time.Now().UTC(),
+		Driver:         Environ.Config.Driver,
+		KeystoreType:   Environ.Config.KeyStoreType,
+		KeystoreSecret: Environ.Config.KeyStoreSecret,
+	}
+
+	tables, err := Environ.DB.ReadBackupTables(signingLog)
+	if err != nil {
+		return backup, err
+	}
+	backup.Tables = tables
+
+	backup.Files, err = readKeystoreFiles()
+	return backup, err
+}
+
+// SealBackup encodes and compresses the backup, and seals it with the passphrase
+func SealBackup(backup Backup, passphrase string) ([]byte, error) {
+	if len(passphrase) == 0 {
+		return nil, ErrorBackupPassphraseEmpty
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(zw).Encode(backup); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	return crypt.SealKey(buf.Bytes(), passphrase)
+}
+
+// OpenBackup unseals a backup archive with the passphrase, and decodes it
+func OpenBackup(sealed []byte, passphrase string) (Backup, error) {
+	backup := Backup{}
+	if len(passphrase) == 0 {
+		return backup, ErrorBackupPassphraseEmpty
+	}
+	if crypt.IsLegacySealedKey(sealed) {
+		return backup, ErrorBackupArchive
+	}
+
+	data, err := crypt.UnsealKey(sealed, passphrase)
+	if err != nil {
+		return backup, err
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return backup, err
+	}
+	defer zr.Close()
+
+	// Decode the numbers exactly, so the IDs are restored as integers
+	decoder := json.NewDecoder(zr)
+	decoder.UseNumber()
+	if err := decoder.Decode(&backup); err != nil {
+		return backup, err
+	}
+	if backup.Version != backupVersion {
+		return backup, ErrorBackupVersion
+	}
+
+	for _, t := range backup.Tables {
+		for _, r := range t.Rows {
+			for i, v := range r {
+				if n, ok := v.(json.Number); ok {
+					r[i] = backupNumber(n)
+				}
+			}
+		}
+	}
+	return backup, nil
+}
+
+func backupNumber(n json.Number) interface{} {
+	if i, err := n.Int64(); err == nil {
+		return i
+	}
+	if f, err := n.Float64(); err == nil {
+		return f
+	}
+	return n.String()
+}
+
+// VerifyBackup checks that the backup is complete and, for the database keystore, that each
+// sealed signing-key unseals with the keystore secret of the backup
+func VerifyBackup(backup Backup) ([]BackupKeypairCheck, error) {
+	for _, name := range backupRequiredTables {
+		if _, ok := backup.Table(name); !ok {
+			return nil, fmt.Errorf("The backup does not have the '%s' table", name)
+		}
+	}
+	for _, t := range backup.Tables {
+		if _, ok := findBackupTable(t.Name); !ok {
+			return nil, fmt.Errorf("The backup has an unknown table: %s", t.Name)
+		}
+		for _, r := range t.Rows {
+			if len(r) != len(t.Columns) {
+				return nil, fmt.Errorf("A row of the '%s' table has %d values for %d columns", t.Name, len(r), len(t.Columns))
+			}
+		}
+	}
+	for _, f := range backup.Files {
+		if !filepath.IsLocal(filepath.FromSlash(f.Path)) {
+			return nil, fmt.Errorf("The backup has a file outside of the keystore path: %s", f.Path)
+		}
+	}
+
+	checks := []BackupKeypairCheck{}
+	if backup.KeystoreType != DatabaseStore.Name {
+		return checks, nil
+	}
+
+	keypairs, _ := backup.Table("keypair")
+	settings, _ := backup.Table("settings")
+	authKeys := map[string]string{}
+	for _, r := range settings.Rows {
+		authKeys[backupString(settings, r, "code")] = backupString(settings, r, "data")
+	}
+
+	for _, r := range keypairs.Rows {
+		keypair := Keypair{
+			AuthorityID: backupString(keypairs, r, "authority_id"),
+			KeyID:       backupString(keypairs, r, "key_id"),
+			KeyName:     backupString(keypairs, r, "key_name"),
+			SealedKey:   backupString(keypairs, r, "sealed_key"),
+		}
+		if len(keypair.SealedKey) == 0 {
+			continue
+		}
+
+		err := verifySealedKeypair(SyncKeypair{keypair, authKeys[crypt.GenerateAuthKey(keypair.AuthorityID, keypair.KeyID)]}, backup.KeystoreSecret)
+		checks = append(checks, BackupKeypairCheck{keypair.AuthorityID, keypair.KeyName, err})
+	}
+	return checks, nil
+}
+
+// backupString returns the text value of a column of a row in a backup table
+func backupString(table BackupTable, row []interface{}, column string) string {
+	for i, c := range table.Columns {
+		if c == column {
+			s, _ := row[i].(string)
+			return s
+		}
+	}
+	return ""
+}
+
+// RestoreBackup replaces the database tables and the keystore files with the ones of the backup.
+// The backup must be for the same type of keystore as the settings, and the restore is refused
+// while services are running
+func RestoreBackup(backup Backup) error {
+	if backup.KeystoreType != Environ.Config.KeyStoreType {
+		return fmt.Errorf("The backup is of the '%s' keystore, not the '%s' keystore of the settings", backup.KeystoreType, Environ.Config.KeyStoreType)
+	}
+
+	instances, err := Environ.DB.ListKeystoreInstances(time.Now().Add(-keystoreInstanceExpiry))
+	if err != nil {
+		return err
+	}
+	if len(instances) > 0 {
+		return KeystoreSecretInUseError{instances}
+	}
+
+	if err := Environ.DB.RestoreBackupTables(backup.Tables); err != nil {
+		return err
+	}
+
+	return writeKeystoreFiles(backup.Files)
+}
+
+// keystoreFilesBackedUp checks if the keystore keeps material in the files of the keystore path
+func keystoreFilesBackedUp() bool {
+	if len(Environ.Config.KeyStorePath) == 0 {
+		return false
+	}
+	return Environ.Config.KeyStoreType == FilesystemStore.Name || Environ.Config.KeyStoreType == TPM20Store.Name
+}
+
+func readKeystoreFiles() ([]BackupFile, error) {
+	files := []BackupFile{}
+	if !keystoreFilesBackedUp() {
+		return files, nil
+	}
+
+	root := Environ.Config.KeyStorePath
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, BackupFile{Path: filepath.ToSlash(rel), Mode: info.Mode().Perm(), Data: data})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Error reading the keystore files: %v", err)
+	}
+	return files, nil
+}
+
+func writeKeystoreFiles(files []BackupFile) error {
+	if len(files) == 0 {
+		return nil
+	}
+	if !keystoreFilesBackedUp() {
+		return fmt.Errorf("The keystore path must be set in the settings to restore the keystore files")
+	}
+
+	for _, f := range files {
+		path := filepath.Join(Environ.Config.KeyStorePath, filepath.FromSlash(f.Path))
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			return fmt.Errorf("Error restoring the keystore files: %v", err)
+		}
+		if err := ioutil.WriteFile(path, f.Data, f.Mode); err != nil {
+			return fmt.Errorf("Error restoring the keystore files: %v", err)
+		}
+	}
+	return nil
+}
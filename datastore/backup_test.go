@@ -0,0 +1,289 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package datastore
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/CanonicalLtd/serial-vault/config"
+	"github.com/CanonicalLtd/serial-vault/crypt"
+)
+
+const backupPassphrase = "the backup passphrase"
+
+// openBackupTestDatabase creates the tables of a backup in a sqlite database
+func openBackupTestDatabase(t *testing.T) *DB {
+	db := openLegacyTestDatabase(t)
+	for _, create := range []func() error{db.CreateSigningLogChainTable, db.CreateAuditLogTable} {
+		if err := create(); err != nil {
+			t.Fatalf("Error creating the tables: %v", err)
+		}
+	}
+	return db
+}
+
+// openLegacyTestDatabase creates the tables of a backup, from before the signing log hash chain and the audit log
+func openLegacyTestDatabase(t *testing.T) *DB {
+	db := openKeystoreTestDatabase(t)
+	for _, create := range []func() error{db.CreateAccountTable, db.CreateUserTable, db.CreateKeypairStatusTable, db.CreateModelTable,
+		db.CreateModelAssertTable, db.CreateSubstoreTable, db.CreateSerialRuleTable, db.CreateSerialAllocationTable,
+		db.CreateDeviceKeyRevocationTable, db.CreatePendingOperationTable, db.CreateSigningLogTable} {
+		if err := create(); err != nil {
+			t.Fatalf("Error creating the tables: %v", err)
+		}
+	}
+	return db
+}
+
+// setUpBackup stores a sealed keypair, a model and a signing log in the database
+func setUpBackup(t *testing.T, db *DB, rolloverAt time.Time) Keypair {
+	env := Environ
+	Environ = &Env{DB: db, Config: config.Settings{Driver: "sqlite3", KeyStoreType: "database", KeyStoreSecret: oldKeystoreSecret}}
+	t.Cleanup(func() { Environ = env })
+
+	mdb := &sealedKeyMockDB{settings: map[string]Setting{}}
+	legacySealedKeypair(t, mdb, 1, testKeyID(t), oldKeystoreSecret)
+	keypair := mdb.keypairs[0]
+	keypair.Active = true
+
+	// The next keypair of the model is not sealed, so it is not checked
+	for _, k := range []Keypair{keypair, {ID: 2, AuthorityID: "System", KeyID: "next", KeyName: "next", Active: true}} {
+		if err := db.SyncKeypair(SyncKeypair{Keypair: k}); err != nil {
+			t.Fatalf("Error creating the keypair: %v", err)
+		}
+	}
+	for _, s := range mdb.settings {
+		if err := db.PutSetting(s); err != nil {
+			t.Fatalf("Error creating the auth-key: %v", err)
+		}
+	}
+
+	model := Model{ID: 1, BrandID: "System", Name: "alder", KeypairID: 1, KeypairIDUser: 1, APIKey: "backup-api-key", NextKeypairID: 2, RolloverAt: &rolloverAt}
+	if err := db.SyncModel(model); err != nil {
+		t.Fatalf("Error creating the model: %v", err)
+	}
+	if err := db.CreateSigningLog(SigningLog{Make: "System", Model: "alder", SerialNumber: "A1", Fingerprint: "abc", Revision: 1}); err != nil {
+		t.Fatalf("Error creating the signing log: %v", err)
+	}
+	return keypair
+}
+
+func TestBackupRestore(t *testing.T) {
+	rolloverAt := time.Date(2027, 3, 4, 5, 6, 7, 0, time.UTC)
+	keypair := setUpBackup(t, openBackupTestDatabase(t), rolloverAt)
+
+	backup, err := CreateBackup(true)
+	if err != nil {
+		t.Fatalf("Error creating the backup: %v", err)
+	}
+	if _, ok := backup.Table("useraccountlink"); ok {
+		t.Error("Expected the sqlite backup not to have the account-user links")
+	}
+	if log, ok := backup.Table("signinglog"); !ok || len(log.Rows) != 1 {
+		t.Errorf("Expected the signing log in the backup: %v", log)
+	}
+
+	sealed, err := SealBackup(backup, backupPassphrase)
+	if err != nil {
+		t.Fatalf("Error sealing the backup: %v", err)
+	}
+	if _, err = OpenBackup(sealed, "the wrong passphrase"); err != crypt.ErrorUnsealKey {
+		t.Errorf("Expected the wrong passphrase to fail, got: %v", err)
+	}
+	backup, err = OpenBackup(sealed, backupPassphrase)
+	if err != nil {
+		t.Fatalf("Error opening the backup: %v", err)
+	}
+
+	checks, err := VerifyBackup(backup)
+	if err != nil {
+		t.Fatalf("Error verifying the backup: %v", err)
+	}
+	if len(checks) != 1 || checks[0].Err != nil {
+		t.Errorf("Expected the signing-key to unseal: %v", checks)
+	}
+
+	// The restore replaces the keypairs of the database
+	db := openBackupTestDatabase(t)
+	if err := db.SyncKeypair(SyncKeypair{Keypair: Keypair{ID: 1, AuthorityID: "other", KeyID: "other", SealedKey: "other"}}); err != nil {
+		t.Fatalf("Error creating the keypair: %v", err)
+	}
+	Environ.DB = db
+	if err := RestoreBackup(backup); err != nil {
+		t.Fatalf("Error restoring the backup: %v", err)
+	}
+
+	restored, err := db.GetKeypair(1)
+	if err != nil {
+		t.Fatalf("Error fetching the restored keypair: %v", err)
+	}
+	if restored.KeyID != keypair.KeyID || restored.SealedKey != keypair.SealedKey || !restored.Active {
+		t.Errorf("Unexpected restored keypair: %v", restored)
+	}
+	if _, err = decryptKeypair(restored.AuthorityID, restored.KeyID, restored.SealedKey); err != nil {
+		t.Errorf("Error unsealing the restored keypair: %v", err)
+	}
+
+	model, err := db.FindModel("System", "alder", "backup-api-key")
+	if err != nil {
+		t.Fatalf("Error finding the restored model: %v", err)
+	}
+	if model.RolloverAt == nil || !model.RolloverAt.Equal(rolloverAt) {
+		t.Errorf("Unexpected rollover time of the restored model: %v", model.RolloverAt)
+	}
+
+	// The restored rows are found by the time queries
+	count, err := db.RolloverModelKeypairs(rolloverAt.Add(time.Second))
+	if err != nil || count != 1 {
+		t.Errorf("Expected the restored model to roll over: %d, %v", count, err)
+	}
+}
+
+func TestBackupRestoreServicesRunning(t *testing.T) {
+	setUpBackup(t, openBackupTestDatabase(t), time.Now())
+
+	backup, err := CreateBackup(false)
+	if err != nil {
+		t.Fatalf("Error creating the backup: %v", err)
+	}
+	if _, ok := backup.Table("signinglog"); ok {
+		t.Error("Expected the signing log to be left out of the backup")
+	}
+
+	if err := Environ.DB.PutKeystoreInstance(KeystoreInstance{Instance: "vault1/signing/100", Fingerprint: "abc", LastSeen: time.Now()}); err != nil {
+		t.Fatalf("Error recording the service: %v", err)
+	}
+	if _, ok := RestoreBackup(backup).(KeystoreSecretInUseError); !ok {
+		t.Error("Expected the restore to be refused while a service is running")
+	}
+
+	Environ.Config.KeyStoreType = "tpm2.0"
+	if err := RestoreBackup(backup); err == nil {
+		t.Error("Expected the restore to a different keystore to fail")
+	}
+}
+
+func TestVerifyBackupInvalid(t *testing.T) {
+	keypair := setUpBackup(t, openBackupTestDatabase(t), time.Now())
+	backup, err := CreateBackup(false)
+	if err != nil {
+		t.Fatalf("Error creating the backup: %v", err)
+	}
+
+	// A different keystore secret does not unseal the signing-keys
+	backup.KeystoreSecret = newKeystoreSecret
+	checks, err := VerifyBackup(backup)
+	if err != nil || len(checks) != 1 || checks[0].Err == nil || checks[0].KeyName != keypair.KeyName {
+		t.Errorf("Expected the signing-key not to unseal: %v, %v", checks, err)
+	}
+
+	tests := []func(b Backup) Backup{
+		func(b Backup) Backup {
+			b.Tables = b.Tables[1:]
+			return b
+		},
+		func(b Backup) Backup {
+			b.Tables = append(b.Tables, BackupTable{Name: "unknown"})
+			return b
+		},
+		func(b Backup) Backup {
+			b.Tables = append(b.Tables, BackupTable{Name: "signinglog", Columns: []string{"id"}, Rows: [][]interface{}{{1, 2}}})
+			return b
+		},
+		func(b Backup) Backup {
+			b.Files = []BackupFile{{Path: "../outside"}}
+			return b
+		},
+	}
+	for _, tt := range tests {
+		if _, err := VerifyBackup(tt(backup)); err == nil {
+			t.Error("Expected the backup to be invalid")
+		}
+	}
+}
+
+func TestOpenBackupInvalid(t *testing.T) {
+	sealed, err := SealBackup(Backup{Version: 2}, backupPassphrase)
+	if err != nil {
+		t.Fatalf("Error sealing the backup: %v", err)
+	}
+
+	tests := []struct {
+		data       []byte
+		passphrase string
+		err        error
+	}{
+		{sealed, "", ErrorBackupPassphraseEmpty},
+		{[]byte("not a backup"), backupPassphrase, ErrorBackupArchive},
+		{sealed, backupPassphrase, ErrorBackupVersion},
+	}
+	for _, tt := range tests {
+		if _, err := OpenBackup(tt.data, tt.passphrase); err != tt.err {
+			t.Errorf("Expected error %v, got: %v", tt.err, err)
+		}
+	}
+
+	if _, err := SealBackup(Backup{}, ""); err != ErrorBackupPassphraseEmpty {
+		t.Errorf("Expected the empty passphrase to fail, got: %v", err)
+	}
+}
+
+func TestBackupKeystoreFiles(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "private-keys-v1"), 0700); err != nil {
+		t.Fatalf("Error creating the keystore: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "private-keys-v1", "key"), []byte("signing-key"), 0600); err != nil {
+		t.Fatalf("Error creating the keystore: %v", err)
+	}
+
+	env := Environ
+	Environ = &Env{DB: &MockDB{}, Config: config.Settings{KeyStoreType: "filesystem", KeyStorePath: dir}}
+	defer func() { Environ = env }()
+
+	backup, err := CreateBackup(false)
+	if err != nil {
+		t.Fatalf("Error creating the backup: %v", err)
+	}
+	if len(backup.Files) != 1 || backup.Files[0].Path != "private-keys-v1/key" || backup.Files[0].Mode != 0600 {
+		t.Fatalf("Unexpected keystore files: %v", backup.Files)
+	}
+
+	Environ.Config.KeyStorePath = filepath.Join(t.TempDir(), "keystore")
+	if err := RestoreBackup(backup); err != nil {
+		t.Fatalf("Error restoring the backup: %v", err)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(Environ.Config.KeyStorePath, "private-keys-v1", "key"))
+	if err != nil || string(data) != "signing-key" {
+		t.Errorf("Expected the keystore file to be restored: %s, %v", data, err)
+	}
+
+	// The database keystore has no files
+	Environ.Config.KeyStoreType = "database"
+	backup, err = CreateBackup(false)
+	if err != nil || len(backup.Files) != 0 {
+		t.Errorf("Expected no keystore files: %v, %v", backup.Files, err)
+	}
+}
@@ -0,0 +1,266 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package datastore
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/CanonicalLtd/serial-vault/service/log"
+)
+
+// backupTable is a database table that is held in a backup
+type backupTable struct {
+	name       string
+	skipSqlite bool
+}
+
+// The tables of a backup, in the order that they are restored, so the references are satisfied
+var backupTables = []backupTable{
+	{"account", false},
+	{"userinfo", false},
+	{"useraccountlink", true},
+	{"keypair", false},
+	{"keypairstatus", false},
+	{"settings", false},
+	{"model", false},
+	{"modelassertion", false},
+	{"substore", false},
+	{"serialrule", false},
+	{"serialallocation", false},
+	{"devicekey_revocation", false},
+	{"pendingoperation", false},
+	{"audit_log", false},
+	{"signinglog", false},
+	{"signinglogchain", false},
+}
+
+// The signing log, with the heads of its hash chains, is only backed up when it is requested, as it can be large
+var backupSigningLogTables = map[string]bool{"signinglog": true, "signinglogchain": true}
+
+const resetSequenceSQL = "SELECT setval(pg_get_serial_sequence('%s', 'id'), coalesce(max(id), 0) + 1, false) FROM %s"
+
+// BackupTable holds the rows of a database table in a backup
+type BackupTable struct {
+	Name    string          `json:"name"`
+	Columns []string        `json:"columns"`
+	Rows    [][]interface{} `json:"rows"`
+}
+
+// ReadBackupTables reads the rows of the tables that are held in a backup
+func (db *DB) ReadBackupTables(signingLog bool) ([]BackupTable, error) {
+	tables := []BackupTable{}
+	for _, t := range backupTables {
+		if (t.skipSqlite && InFactory()) || (backupSigningLogTables[t.name] && !signingLog) {
+			continue
+		}
+
+		table, err := db.readBackupTable(t.name)
+		if err != nil {
+			log.Printf("Error reading the '%s' table: %v\n", t.name, err)
+			return nil, fmt.Errorf("Error reading the '%s' table: %v", t.name, err)
+		}
+		tables = append(tables, table)
+	}
+	return tables, nil
+}
+
+func (db *DB) readBackupTable(name string) (BackupTable, error) {
+	table := BackupTable{Name: name, Rows: [][]interface{}{}}
+
+	rows, err := db.Query(fmt.Sprintf("SELECT * FROM %s ORDER BY 1", name))
+	if err != nil {
+		return table, err
+	}
+	defer rows.Close()
+
+	table.Columns, err = rows.Columns()
+	if err != nil {
+		return table, err
+	}
+
+	for rows.Next() {
+		values := make([]interface{}, len(table.Columns))
+		pointers := make([]interface{}, len(values))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return table, err
+		}
+
+		for i, v := range values {
+			switch value := v.(type) {
+			case []byte:
+				values[i] = string(value)
+			case time.Time:
+				values[i] = value.UTC()
+			}
+		}
+		table.Rows = append(table.Rows, values)
+	}
+	return table, rows.Err()
+}
+
+// RestoreBackupTables replaces the rows of the tables with the rows from a backup, in a single
+// transaction. The columns of the backup that the database does not have are left out
+func (db *DB) RestoreBackupTables(tables []BackupTable) error {
+	inserts := map[string]backupInsert{}
+	for _, table := range tables {
+		t, ok := findBackupTable(table.Name)
+		if !ok {
+			return fmt.Errorf("The backup has an unknown table: %s", table.Name)
+		}
+		if t.skipSqlite && InFactory() {
+			log.Printf("The '%s' table is not restored to the sqlite database\n", table.Name)
+			continue
+		}
+
+		insert, err := db.prepareBackupInsert(table)
+		if err != nil {
+			log.Printf("Error checking the '%s' table: %v\n", table.Name, err)
+			return fmt.Errorf("Error checking the '%s' table: %v", table.Name, err)
+		}
+		inserts[table.Name] = insert
+	}
+
+	return db.transaction(func(tx *sql.Tx) error {
+		// Remove the existing rows, in the reverse order of the references
+		for i := len(backupTables) - 1; i >= 0; i-- {
+			if _, ok := inserts[backupTables[i].name]; !ok {
+				continue
+			}
+			if _, err := tx.Exec(fmt.Sprintf("DELETE FROM %s", backupTables[i].name)); err != nil {
+				return fmt.Errorf("Error removing the rows of the '%s' table: %v", backupTables[i].name, err)
+			}
+		}
+
+		for _, t := range backupTables {
+			insert, ok := inserts[t.name]
+			if !ok {
+				continue
+			}
+			if err := insert.exec(tx); err != nil {
+				return fmt.Errorf("Error restoring the '%s' table: %v", t.name, err)
+			}
+		}
+		return nil
+	})
+}
+
+// backupInsert holds the statement and the values that restore the rows of a table
+type backupInsert struct {
+	table BackupTable
+	sql   string
+	rows  [][]interface{}
+	id    bool
+}
+
+// prepareBackupInsert matches the columns of a backup table to the columns of the database,
+// converting the timestamps to the type of the column
+func (db *DB) prepareBackupInsert(table BackupTable) (backupInsert, error) {
+	insert := backupInsert{table: table, rows: [][]interface{}{}}
+
+	rows, err := db.Query(fmt.Sprintf("SELECT * FROM %s WHERE 1=0", table.Name))
+	if err != nil {
+		return insert, err
+	}
+	defer rows.Close()
+
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return insert, err
+	}
+	timestamps := map[string]bool{}
+	for _, c := range columnTypes {
+		timestamps[c.Name()] = strings.Contains(strings.ToLower(c.DatabaseTypeName()), "timestamp")
+	}
+
+	columns := []string{}
+	placeholders := []string{}
+	indexes := []int{}
+	for i, c := range table.Columns {
+		if _, ok := timestamps[c]; !ok {
+			log.Printf("The '%s' column of the '%s' table is not restored, as the database does not have it\n", c, table.Name)
+			continue
+		}
+		columns = append(columns, c)
+		placeholders = append(placeholders, fmt.Sprintf("$%d", len(columns)))
+		indexes = append(indexes, i)
+		insert.id = insert.id || c == "id"
+	}
+	if len(columns) == 0 {
+		return insert, fmt.Errorf("The backup has none of the columns of the table")
+	}
+	insert.sql = fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", table.Name, strings.Join(columns, ","), strings.Join(placeholders, ","))
+
+	for _, r := range table.Rows {
+		if len(r) != len(table.Columns) {
+			return insert, fmt.Errorf("A row has %d values for %d columns", len(r), len(table.Columns))
+		}
+
+		values := []interface{}{}
+		for _, i := range indexes {
+			value := r[i]
+			if s, ok := value.(string); ok && timestamps[table.Columns[i]] {
+				if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+					value = t
+				}
+			}
+			values = append(values, value)
+		}
+		insert.rows = append(insert.rows, values)
+	}
+	return insert, nil
+}
+
+func (insert backupInsert) exec(tx *sql.Tx) error {
+	stmt, err := tx.Prepare(insert.sql)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, values := range insert.rows {
+		if _, err := stmt.Exec(values...); err != nil {
+			return err
+		}
+	}
+
+	// Continue the ID sequence of the table after the restored rows
+	if insert.id && !InFactory() {
+		if _, err := tx.Exec(fmt.Sprintf(resetSequenceSQL, insert.table.Name, insert.table.Name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func findBackupTable(name string) (backupTable, bool) {
+	for _, t := range backupTables {
+		if t.name == name {
+			return t, true
+		}
+	}
+	return backupTable{}, false
+}
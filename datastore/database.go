@@ -22,6 +22,8 @@ package datastore
 
 import (
 	"database/sql"
+	"fmt"
+	"time"
 
 	"github.com/CanonicalLtd/serial-vault/config"
 )
@@ -34,6 +36,28 @@ type SigningLogParams struct {
 	Offset       uint64
 	Filter       []string
 	Serialnumber string
+	Fingerprint  string
+	From         *time.Time        // created at or after
+	To           *time.Time        // created before
+	Sort         string            // one of SigningLogSortFields, the ID by default
+	Ascending    bool              // the newest first by default
+	Cursor       *SigningLogCursor // the end of the previous page, used in place of the Offset
+	Synced       *bool             // whether a factory log has been synced to the cloud
+}
+
+// ParseDate parses a date and time in RFC3339 format, or a date, for the From and To of a
+// search. An empty value is no date
+func ParseDate(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return &t, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid date: %s", value)
 }
 
 // Datastore interface for the database logic
@@ -44,13 +68,10 @@ type Datastore interface {
 	UpdateAllowedModel(model Model, authorization User) (string, error)
 	DeleteAllowedModel(model Model, authorization User) (string, error)
 	CreateAllowedModel(model Model, authorization User) (Model, string, error)
-	CreateModelTable() error
-	AlterModelTable() error
 	CheckAPIKey(apiKey string) bool
 	CheckModelExists(brandID, name string) bool
+	RolloverModelKeypairs(now time.Time) (int, error)
 
-	CreateModelAssertTable() error
-	AlterModelAssertTable() error
 	CreateModelAssert(m ModelAssertion) (int, error)
 	UpdateModelAssert(m ModelAssertion) error
 	GetModelAssert(modelID int) (ModelAssertion, error)
@@ -63,28 +84,29 @@ type Datastore interface {
 	PutKeypair(keypair Keypair) (string, error)
 	UpdateAllowedKeypairActive(keypairID int, active bool, authorization User) error
 	UpdateKeypairAssertion(keypair Keypair, authorization User) (string, error)
-	CreateKeypairTable() error
-	AlterKeypairTable() error
 	CheckKeypairKeynameExists(authorityID, name string) bool
+	UpdateKeypairSealedKey(keypair Keypair, authKey Setting) error
+	UpdateKeypairsSealedKeys(keypairs []SyncKeypair, imports []PendingOperation, verify func(SyncKeypair) error, verifyImport func(PendingOperation) error) error
+	UpdateKeypairPolicy(keypairID int, policy KeypairPolicy) error
+	UpdateKeypairValidity(keypair Keypair) error
+	DeleteKeypair(keypairID int) error
 
-	CreateSettingsTable() error
 	PutSetting(setting Setting) error
 	GetSetting(code string) (Setting, error)
 
-	CreateSigningLogTable() error
 	CheckForDuplicate(signLog *SigningLog) (bool, int, error)
+	CheckForDeviceKeyChange(signLog SigningLog) (bool, error)
 	CreateSigningLog(signLog SigningLog) error
-	ListAllowedSigningLog(authorization User) ([]SigningLog, error)
-	ListAllowedSigningLogForAccount(authorization User, authorityID string, params *SigningLogParams) ([]SigningLog, error)
+	ListAllowedSigningLogForAccount(authorization User, authorityID string, params *SigningLogParams) (SigningLogPage, error)
+	ExportAllowedSigningLog(authorization User, authorityID string, params *SigningLogParams, fn func(SigningLog) error) error
+	ListAllowedSigningLogChainHeads(authorization User, authorityID string) ([]SigningLogChainHead, error)
+	VerifySigningLogChain(authorityID string) ([]SigningLogChainReport, error)
 	AllowedSigningLogFilterValues(authorization User, authorityID string) (SigningLogFilters, error)
 
-	CreateDeviceNonceTable() error
 	DeleteExpiredDeviceNonces() error
 	CreateDeviceNonce() (DeviceNonce, error)
 	ValidateDeviceNonce(nonce string) error
 
-	CreateAccountTable() error
-	AlterAccountTable() error
 	ListAllowedAccounts(authorization User) ([]Account, error)
 	GetAllowedAccount(authorityID string, authorization User) (Account, error)
 	GetAccount(authorityID string) (Account, error)
@@ -93,7 +115,6 @@ type Datastore interface {
 	UpdateAccount(account Account, authorization User) error
 	PutAccount(account Account, authorization User) (string, error)
 
-	CreateOpenidNonceTable() error
 	CreateOpenidNonce(nonce OpenidNonce) error
 
 	CreateUser(user User) (int, error)
@@ -104,24 +125,18 @@ type Datastore interface {
 	GetUserByAPIKey(apiKey, username string) (User, error)
 	UpdateUser(user User) error
 	DeleteUser(userID int) error
-	CreateUserTable() error
-	CreateAccountUserLinkTable() error
 	CheckUserInAccount(username, authorityID string) bool
-	AlterUserTable() error
 
 	ListUserAccounts(username string) ([]Account, error)
 	ListNotUserAccounts(username string) ([]Account, error)
 	ListAccountUsers(authorityID string) ([]User, error)
 
-	CreateKeypairStatusTable() error
-	AlterKeypairStatusTable() error
 	CreateKeypairStatus(ks KeypairStatus) (int, error)
 	UpdateKeypairStatus(ks KeypairStatus) error
 	DeleteKeypairStatus(ks KeypairStatus) error
 	GetKeypairStatus(authorityID, keyName string) (KeypairStatus, error)
 	ListAllowedKeypairStatus(authorization User) ([]KeypairStatus, error)
 
-	CreateSubstoreTable() error
 	CreateAllowedSubstore(store Substore, authorization User) (Substore, error)
 	ListSubstores(accountID int, authorization User) ([]Substore, error)
 	UpdateAllowedSubstore(store Substore, authorization User) error
@@ -129,13 +144,55 @@ type Datastore interface {
 	GetAllowedSubstore(fromModelID int, serialNumber string, authorization User) (Substore, error)
 	GetSubstore(fromModelID int, serialNumber string) (Substore, error)
 	GetSubstoreModel(brand, model, serialNumber string) (Substore, error)
+	GetSubstoreByID(storeID int) (Substore, error)
+
+	ListAllowedSerialRules(modelID int, authorization User) ([]SerialRule, error)
+	ListAllAllowedSerialRules(authorization User) ([]SerialRule, error)
+	CreateAllowedSerialRules(modelID int, rules []SerialRule, authorization User) error
+	DeleteAllowedSerialRule(modelID, ruleID int, authorization User) error
+	CheckSerialAllowed(modelID int, serial string) (bool, error)
+	SyncSerialRules(rules []SerialRule) error
+
+	GetAllowedSerialAllocation(modelID int, authorization User) (SerialAllocation, error)
+	UpdateAllowedSerialAllocation(alloc SerialAllocation, authorization User) error
+	DeleteAllowedSerialAllocation(modelID int, authorization User) error
+	AllocateSerial(modelID int, signLog SigningLog, sign func(signLog *SigningLog) error) (string, error)
+
+	ListAllowedDeviceKeyRevocations(authorization User) ([]DeviceKeyRevocation, error)
+	CreateAllowedDeviceKeyRevocation(rev DeviceKeyRevocation, authorization User) (DeviceKeyRevocation, error)
+	DeleteAllowedDeviceKeyRevocation(revocationID int, authorization User) error
+	CheckDeviceKeyRevoked(brandID, modelName, serialNumber, fingerprint string) (DeviceKeyRevocation, error)
+	SyncDeviceKeyRevocations(revocations []DeviceKeyRevocation) error
+
+	CreatePendingOperation(op PendingOperation) (PendingOperation, error)
+	GetPendingOperation(operationID int) (PendingOperation, error)
+	ListAllowedPendingOperations(authorization User) ([]PendingOperation, error)
+	FindApprovedOperation(operation, authorityID, keyName string) (PendingOperation, error)
+	UpdatePendingOperationStatus(op PendingOperation, fromStatus string) error
+
+	CreateAuditLog(auditLog AuditLog) error
+	ListAuditLog(params *AuditLogParams) (AuditLogPage, error)
+	StreamAuditLog(params *AuditLogParams, fn func(AuditLog) error) error
+
+	PutKeystoreInstance(instance KeystoreInstance) error
+	ListKeystoreInstances(since time.Time) ([]KeystoreInstance, error)
+
+	UseSigningQuota(apiKey, day string, quota int) (bool, error)
+	DeleteSigningQuotas(before string) error
 
-	CreateTestLogTable() error
 	CreateTestLog(testLog TestLog) error
 	ListAllowedTestLog(authorization User) ([]TestLog, error)
 
 	HealthCheck() error
 
+	SchemaStatus() ([]MigrationStatus, error)
+	CheckSchema() error
+	MigrateSchema(version int) ([]Migration, error)
+	RollbackSchema() (Migration, error)
+
+	ReadBackupTables(signingLog bool) ([]BackupTable, error)
+	RestoreBackupTables(tables []BackupTable) error
+
 	SyncAccount(account Account) error
 	SyncKeypair(keypair SyncKeypair) error
 	SyncModel(m Model) error
@@ -176,7 +233,7 @@ func OpenSysDatabase(driver, dataSource string) {
 	}
 }
 
-func (db *DB) transaction(txFunc func(*sql.Tx) error) error {
+func (db *DB) transaction(txFunc func(*sql.Tx) error) (err error) {
 	tx, err := db.Begin()
 	if err != nil {
 		return err
@@ -22,16 +22,22 @@ package datastore
 
 import (
 	"database/sql"
+	"fmt"
+	"strings"
 
 	"github.com/CanonicalLtd/serial-vault/service/log"
 
 	_ "github.com/mattn/go-sqlite3" // sqlite driver
 )
 
+// sqliteBusyTimeout is the time, in milliseconds, that a connection waits for the
+// database lock, e.g. while the nonces are pruned in the background
+const sqliteBusyTimeout = 10000
+
 // openSQLiteDatabase return an open database connection for an sqlite database
 func openSQLiteDatabase(driver, dataSource string) {
 	// Open the database connection
-	db, err := sql.Open(driver, dataSource)
+	db, err := sql.Open(driver, sqliteDataSource(dataSource))
 	if err != nil {
 		log.Fatalf("Error opening the database: %v\n", err)
 	}
@@ -45,3 +51,16 @@ func openSQLiteDatabase(driver, dataSource string) {
 	Environ.DB = &DB{db}
 	OpenidNonceStore.DB = &DB{db}
 }
+
+// sqliteDataSource adds the busy timeout to the data source, unless one is configured
+func sqliteDataSource(dataSource string) string {
+	if strings.Contains(dataSource, "_busy_timeout=") {
+		return dataSource
+	}
+
+	separator := "?"
+	if strings.Contains(dataSource, "?") {
+		separator = "&"
+	}
+	return fmt.Sprintf("%s%s_busy_timeout=%d", dataSource, separator, sqliteBusyTimeout)
+}
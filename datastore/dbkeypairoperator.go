@@ -47,7 +47,7 @@ func (dbStore *DatabaseKeypairOperator) ImportKeypair(authorityID, keyID, base64
 	}
 
 	// Use the HMAC-ed auth-key as the key to encrypt the signing-key
-	sealedSigningKey, err := crypt.EncryptKey(base64PrivateKey, authKeyHash)
+	sealedSigningKey, err := crypt.SealKey([]byte(base64PrivateKey), authKeyHash)
 
 	// base64 encode the sealed signing-key for storage
 	base64SealedSigningkey := base64.StdEncoding.EncodeToString(sealedSigningKey)
@@ -62,7 +62,7 @@ func (dbStore *DatabaseKeypairOperator) generateEncryptionKey(authorityID, keyID
 	}
 
 	// Encrypt and store the auth-key hash
-	encryptedAuthKeyHash, err := crypt.EncryptKey(string(encryptionKey[:]), Environ.Config.KeyStoreSecret)
+	encryptedAuthKeyHash, err := crypt.SealKey([]byte(encryptionKey), Environ.Config.KeyStoreSecret)
 	if err != nil {
 		return "", err
 	}
@@ -96,15 +96,15 @@ func unsealKeypair(authorityID string, keyID string, base64SealedSigningKey stri
 			return err
 		}
 
-		// Convert the byte array to an asserts key
-		privateKey, errorCode, err := crypt.DeserializePrivateKey(string(base64SigningKey[:]))
+		// Convert the byte array to an RSA key
+		rsaKey, errorCode, err := crypt.DeserializeRSAPrivateKey(string(base64SigningKey[:]))
 		if err != nil {
 			log.Printf("Error generating the asserts private-key: %v", errorCode)
 			return err
 		}
 
-		// Add the private-key to the memory keypair store
-		err = keypairDB.ImportKey(privateKey)
+		// Add the private-key to the memory keypair store, which evicts it when it is idle
+		err = keypairDB.unsealedKeys.PutRSAKey(rsaKey)
 		if err != nil {
 			log.Println("Error importing the private-key to memory store")
 			return err
@@ -117,6 +117,9 @@ func unsealKeypair(authorityID string, keyID string, base64SealedSigningKey stri
 
 // ReEncryptKeypair unseals the existing private key and re-encrypts it with the new secret
 var ReEncryptKeypair = func(keypair Keypair, newSecret string) (string, string, error) {
+	if Environ.Config.KeyStoreType == PKCS11Store.Name {
+		return "", "", ErrorKeyNotExportable
+	}
 
 	// Decrypt the sealed key
 	// The existing signing-key in the database is encrypted, so it needs to be decrypted
@@ -134,7 +137,7 @@ var ReEncryptKeypair = func(keypair Keypair, newSecret string) (string, string,
 
 	// Use the HMAC-ed auth-key as the key to encrypt the signing-key
 	// This encrypts the signing-key with the secret provided in the API call
-	sealedSigningKey, err := crypt.EncryptKey(string(base64SigningKey), encryptionKey)
+	sealedSigningKey, err := crypt.SealKey(base64SigningKey, encryptionKey)
 	if err != nil {
 		return "", "", err
 	}
@@ -145,7 +148,7 @@ var ReEncryptKeypair = func(keypair Keypair, newSecret string) (string, string,
 	// Encrypt the encryption key
 	// We need to store the key that was used to encrypt the signing-key in a database, so
 	// encrypt it with the new secret... just to add another layer of security
-	encryptedAuthKeyHash, err := crypt.EncryptKey(string(encryptionKey), newSecret)
+	encryptedAuthKeyHash, err := crypt.SealKey([]byte(encryptionKey), newSecret)
 	if err != nil {
 		return "", "", err
 	}
@@ -165,15 +168,20 @@ func decryptKeypair(authorityID, keyID, base64SealedSigningKey string) ([]byte,
 		return nil, err
 	}
 
+	return unsealSigningKey(authKeySetting.Data, base64SealedSigningKey, Environ.Config.KeyStoreSecret)
+}
+
+// unsealSigningKey decrypts the sealed auth-key with the keystore secret, and the sealed signing-key with the auth-key
+func unsealSigningKey(base64SealedAuthKey, base64SealedSigningKey, keystoreSecret string) ([]byte, error) {
 	// Decode the auth-key from storage
-	encryptedAuthKey, err := base64.StdEncoding.DecodeString(authKeySetting.Data)
+	encryptedAuthKey, err := base64.StdEncoding.DecodeString(base64SealedAuthKey)
 	if err != nil {
 		log.Println("Could not decode the auth-key for the signing-key")
 		return nil, err
 	}
 
 	// Decrypt the decoded auth-key
-	authKey, err := crypt.DecryptKey(encryptedAuthKey, Environ.Config.KeyStoreSecret)
+	authKey, err := crypt.UnsealKey(encryptedAuthKey, keystoreSecret)
 	if err != nil {
 		log.Println("Could not decrypt the auth-key for the signing-key")
 		return nil, err
@@ -185,7 +193,7 @@ func decryptKeypair(authorityID, keyID, base64SealedSigningKey string) ([]byte,
 		log.Println("Could not decode the signing-key")
 		return nil, err
 	}
-	base64SigningKey, err := crypt.DecryptKey(sealedSigningKey, string(authKey[:]))
+	base64SigningKey, err := crypt.UnsealKey(sealedSigningKey, string(authKey[:]))
 	if err != nil {
 		log.Println("Could not decrypt the signing-key")
 		return nil, err
@@ -0,0 +1,117 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package datastore
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// The SHA3-384 fingerprint of a device-key, as used in the device-key-sha3-384 header
+var validFingerprintRegexp = regexp.MustCompile(`^[A-Za-z0-9_-]{64}$`)
+
+// ListAllowedDeviceKeyRevocations returns the device-key revocations for the brands the user can access
+func (db *DB) ListAllowedDeviceKeyRevocations(authorization User) ([]DeviceKeyRevocation, error) {
+	switch authorization.Role {
+	case Invalid: // Authentication is disabled
+		fallthrough
+	case Superuser:
+		return db.listDeviceKeyRevocations()
+	case SyncUser:
+		fallthrough
+	case Admin:
+		return db.listDeviceKeyRevocationsFilteredByUser(authorization.Username)
+	default:
+		return []DeviceKeyRevocation{}, nil
+	}
+}
+
+// CreateAllowedDeviceKeyRevocation revokes a device-key or serial number, if the user has access to the brand
+func (db *DB) CreateAllowedDeviceKeyRevocation(rev DeviceKeyRevocation, authorization User) (DeviceKeyRevocation, error) {
+	rev.BrandID = strings.TrimSpace(rev.BrandID)
+	rev.Model = strings.TrimSpace(rev.Model)
+	rev.SerialNumber = strings.TrimSpace(rev.SerialNumber)
+	rev.Fingerprint = strings.TrimSpace(rev.Fingerprint)
+
+	if err := validateDeviceKeyRevocation(rev); err != nil {
+		return rev, err
+	}
+
+	if err := db.checkBrandAllowed(rev.BrandID, authorization); err != nil {
+		return rev, err
+	}
+
+	return db.createDeviceKeyRevocation(rev)
+}
+
+// DeleteAllowedDeviceKeyRevocation removes a device-key revocation, if the user has access to the brand
+func (db *DB) DeleteAllowedDeviceKeyRevocation(revocationID int, authorization User) error {
+	rev, err := db.getDeviceKeyRevocation(revocationID)
+	if err != nil {
+		return err
+	}
+
+	if err := db.checkBrandAllowed(rev.BrandID, authorization); err != nil {
+		return err
+	}
+
+	return db.deleteDeviceKeyRevocation(revocationID)
+}
+
+func (db *DB) checkBrandAllowed(brandID string, authorization User) error {
+	if authorization.Role == Standard {
+		return errors.New("the user does not have permissions to the brand")
+	}
+
+	account, err := db.GetAllowedAccount(brandID, authorization)
+	if err != nil || account.ID == 0 {
+		return errors.New("the brand does not exist or the user does not have permissions to it")
+	}
+	return nil
+}
+
+func validateDeviceKeyRevocation(rev DeviceKeyRevocation) error {
+	if err := validateNotEmpty("Brand", rev.BrandID); err != nil {
+		return err
+	}
+	if err := validateNotEmpty("Reason", rev.Reason); err != nil {
+		return err
+	}
+
+	switch {
+	case len(rev.Fingerprint) > 0 && len(rev.SerialNumber) > 0:
+		return errors.New("a revocation must be for either a device-key fingerprint or a serial number, not both")
+	case len(rev.Fingerprint) > 0:
+		if !validFingerprintRegexp.MatchString(rev.Fingerprint) {
+			return fmt.Errorf("invalid device-key fingerprint '%s': it must be the SHA3-384 digest of the device-key", rev.Fingerprint)
+		}
+	case len(rev.SerialNumber) > 0:
+		if err := validateNotEmpty("Model", rev.Model); err != nil {
+			return err
+		}
+	default:
+		return errors.New("a device-key fingerprint or a serial number must be provided")
+	}
+
+	return nil
+}
@@ -0,0 +1,211 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package datastore
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+const createDeviceKeyRevocationTableSQL = `
+	CREATE TABLE IF NOT EXISTS devicekey_revocation (
+		id               serial primary key not null,
+		brand_id         varchar(200) not null,
+		model            varchar(200) not null default '',
+		serial_number    varchar(200) not null default '',
+		fingerprint      varchar(200) not null default '',
+		reason           text not null default '',
+		created          timestamp default current_timestamp
+	)
+`
+
+// Indexes
+const createDeviceKeyRevocationFingerprintIndexSQL = "CREATE INDEX IF NOT EXISTS devicekey_revocation_fingerprint_idx ON devicekey_revocation (fingerprint)"
+const createDeviceKeyRevocationSerialIndexSQL = "CREATE INDEX IF NOT EXISTS devicekey_revocation_serial_idx ON devicekey_revocation (brand_id, model, serial_number)"
+
+const createDeviceKeyRevocationSQL = `
+	INSERT INTO devicekey_revocation
+	(brand_id, model, serial_number, fingerprint, reason)
+	VALUES ($1,$2,$3,$4,$5) RETURNING id`
+
+const getDeviceKeyRevocationSQL = `
+	SELECT id, brand_id, model, serial_number, fingerprint, reason, created
+	FROM devicekey_revocation
+	WHERE id=$1`
+
+const listDeviceKeyRevocationSQL = `
+	SELECT id, brand_id, model, serial_number, fingerprint, reason, created
+	FROM devicekey_revocation
+	ORDER BY id DESC`
+
+const listDeviceKeyRevocationForUserSQL = `
+	SELECT r.id, r.brand_id, r.model, r.serial_number, r.fingerprint, r.reason, r.created
+	FROM devicekey_revocation r
+	WHERE EXISTS(
+		SELECT * FROM account acc
+		INNER JOIN useraccountlink ua on ua.account_id=acc.id
+		INNER JOIN userinfo u on ua.user_id=u.id
+		WHERE acc.authority_id=r.brand_id and u.username=$1
+	)
+	ORDER BY r.id DESC`
+
+// A compromised device-key is revoked for the models of its brand, and a serial number for its brand and model
+const findDeviceKeyRevocationSQL = `
+	SELECT id, brand_id, model, serial_number, fingerprint, reason, created
+	FROM devicekey_revocation
+	WHERE (fingerprint<>'' AND fingerprint=$1 AND brand_id=$2)
+	OR (serial_number<>'' AND brand_id=$2 AND model=$3 AND serial_number=$4)
+	ORDER BY id LIMIT 1`
+
+const deleteDeviceKeyRevocationSQL = "DELETE FROM devicekey_revocation WHERE id=$1"
+
+// sqlite3 syntax for syncing data locally
+const syncDeleteDeviceKeyRevocationSQL = "DELETE FROM devicekey_revocation"
+const syncInsertDeviceKeyRevocationSQL = `
+	INSERT INTO devicekey_revocation
+	(id, brand_id, model, serial_number, fingerprint, reason, created)
+	VALUES ($1,$2,$3,$4,$5,$6,$7)`
+
+// DeviceKeyRevocation blocks the signing of a device-key (by its SHA3-384 fingerprint) or of a serial number.
+// Only one of the fingerprint or the serial number is set
+type DeviceKeyRevocation struct {
+	ID           int       `json:"id"`
+	BrandID      string    `json:"brand-id"`
+	Model        string    `json:"model"`
+	SerialNumber string    `json:"serialnumber"`
+	Fingerprint  string    `json:"fingerprint"`
+	Reason       string    `json:"reason"`
+	Created      time.Time `json:"created"`
+}
+
+// CreateDeviceKeyRevocationTable creates the database table for the device-key revocations with its indexes
+func (db *DB) CreateDeviceKeyRevocationTable() error {
+	_, err := db.Exec(createDeviceKeyRevocationTableSQL)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(createDeviceKeyRevocationFingerprintIndexSQL)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(createDeviceKeyRevocationSerialIndexSQL)
+	return err
+}
+
+// CheckDeviceKeyRevoked looks for a revocation of the device-key fingerprint for the brand, or of the
+// serial number for the brand and model. The returned revocation has a zero ID if neither is revoked
+func (db *DB) CheckDeviceKeyRevoked(brandID, modelName, serialNumber, fingerprint string) (DeviceKeyRevocation, error) {
+	rev := DeviceKeyRevocation{}
+
+	err := db.QueryRow(findDeviceKeyRevocationSQL, fingerprint, brandID, modelName, serialNumber).Scan(
+		&rev.ID, &rev.BrandID, &rev.Model, &rev.SerialNumber, &rev.Fingerprint, &rev.Reason, &rev.Created)
+	switch {
+	case err == sql.ErrNoRows:
+		return DeviceKeyRevocation{}, nil
+	case err != nil:
+		return rev, fmt.Errorf("error checking the device-key revocations: %v", err)
+	}
+
+	return rev, nil
+}
+
+// SyncDeviceKeyRevocations replaces the revocations in the factory database with the ones from the cloud,
+// so revocations that have been removed in the cloud are also removed
+func (db *DB) SyncDeviceKeyRevocations(revocations []DeviceKeyRevocation) error {
+	return db.transaction(func(tx *sql.Tx) error {
+		_, err := tx.Exec(syncDeleteDeviceKeyRevocationSQL)
+		if err != nil {
+			return fmt.Errorf("error removing the device-key revocations: %v", err)
+		}
+
+		for _, rev := range revocations {
+			_, err = tx.Exec(syncInsertDeviceKeyRevocationSQL, rev.ID, rev.BrandID, rev.Model, rev.SerialNumber, rev.Fingerprint, rev.Reason, rev.Created)
+			if err != nil {
+				return fmt.Errorf("error syncing the device-key revocation %d: %v", rev.ID, err)
+			}
+		}
+		return nil
+	})
+}
+
+func (db *DB) listDeviceKeyRevocations() ([]DeviceKeyRevocation, error) {
+	return db.listDeviceKeyRevocationsFilteredByUser(anyUserFilter)
+}
+
+func (db *DB) listDeviceKeyRevocationsFilteredByUser(username string) ([]DeviceKeyRevocation, error) {
+	var (
+		rows *sql.Rows
+		err  error
+	)
+
+	if len(username) == 0 {
+		rows, err = db.Query(listDeviceKeyRevocationSQL)
+	} else {
+		rows, err = db.Query(listDeviceKeyRevocationForUserSQL, username)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving the device-key revocations: %v", err)
+	}
+	defer rows.Close()
+
+	revocations := []DeviceKeyRevocation{}
+	for rows.Next() {
+		rev := DeviceKeyRevocation{}
+		err := rows.Scan(&rev.ID, &rev.BrandID, &rev.Model, &rev.SerialNumber, &rev.Fingerprint, &rev.Reason, &rev.Created)
+		if err != nil {
+			return nil, fmt.Errorf("error retrieving the device-key revocations: %v", err)
+		}
+		revocations = append(revocations, rev)
+	}
+
+	return revocations, nil
+}
+
+func (db *DB) getDeviceKeyRevocation(revocationID int) (DeviceKeyRevocation, error) {
+	rev := DeviceKeyRevocation{}
+
+	err := db.QueryRow(getDeviceKeyRevocationSQL, revocationID).Scan(
+		&rev.ID, &rev.BrandID, &rev.Model, &rev.SerialNumber, &rev.Fingerprint, &rev.Reason, &rev.Created)
+	if err != nil {
+		return rev, fmt.Errorf("error retrieving the device-key revocation %d: %v", revocationID, err)
+	}
+	return rev, nil
+}
+
+func (db *DB) createDeviceKeyRevocation(rev DeviceKeyRevocation) (DeviceKeyRevocation, error) {
+	var createdID int
+
+	err := db.QueryRow(createDeviceKeyRevocationSQL, rev.BrandID, rev.Model, rev.SerialNumber, rev.Fingerprint, rev.Reason).Scan(&createdID)
+	if err != nil {
+		return rev, fmt.Errorf("error creating the device-key revocation: %v", err)
+	}
+
+	return db.getDeviceKeyRevocation(createdID)
+}
+
+func (db *DB) deleteDeviceKeyRevocation(revocationID int) error {
+	_, err := db.Exec(deleteDeviceKeyRevocationSQL, revocationID)
+	if err != nil {
+		return fmt.Errorf("error deleting the device-key revocation %d: %v", revocationID, err)
+	}
+	return nil
+}
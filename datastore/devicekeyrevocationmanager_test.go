@@ -0,0 +1,96 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package datastore
+
+import (
+	"testing"
+
+	check "gopkg.in/check.v1"
+)
+
+type deviceKeyRevocationSuite struct{}
+
+var _ = check.Suite(&deviceKeyRevocationSuite{})
+
+func (s *deviceKeyRevocationSuite) TestValidateDeviceKeyRevocation(c *check.C) {
+	fingerprint := "majNNh3Nbgg0CozIjfLrvvEWG830dZmm76gJHnyyrW4g7udYbfVgt0WO15ayuN5l"
+
+	tests := []struct {
+		rev   DeviceKeyRevocation
+		valid bool
+	}{
+		{DeviceKeyRevocation{BrandID: "system", Model: "alder", SerialNumber: "A123456L", Reason: "Stolen"}, true},
+		{DeviceKeyRevocation{BrandID: "system", Fingerprint: fingerprint, Reason: "Leaked"}, true},
+		{DeviceKeyRevocation{BrandID: "system", Model: "alder", Fingerprint: fingerprint, Reason: "Leaked"}, true},
+		{DeviceKeyRevocation{BrandID: "", Model: "alder", SerialNumber: "A123456L", Reason: "Stolen"}, false},
+		{DeviceKeyRevocation{BrandID: "system", Model: "alder", SerialNumber: "A123456L", Reason: " "}, false},
+		{DeviceKeyRevocation{BrandID: "system", SerialNumber: "A123456L", Reason: "Stolen"}, false},
+		{DeviceKeyRevocation{BrandID: "system", Model: "alder", Reason: "Stolen"}, false},
+		{DeviceKeyRevocation{BrandID: "system", Model: "alder", SerialNumber: "A123456L", Fingerprint: fingerprint, Reason: "Stolen"}, false},
+		{DeviceKeyRevocation{BrandID: "system", Fingerprint: fingerprint[1:], Reason: "Leaked"}, false},
+		{DeviceKeyRevocation{BrandID: "system", Fingerprint: fingerprint[1:] + "=", Reason: "Leaked"}, false},
+	}
+
+	for _, t := range tests {
+		err := validateDeviceKeyRevocation(t.rev)
+		c.Assert(err == nil, check.Equals, t.valid, check.Commentf("%v: %v", t.rev, err))
+	}
+}
+
+func TestCheckDeviceKeyRevokedSQLite(t *testing.T) {
+	db := openMigrationTestDatabase(t)
+	if _, err := db.MigrateSchema(LatestSchemaVersion()); err != nil {
+		t.Fatalf("Error migrating the database: %v", err)
+	}
+
+	fingerprint := "UytTqTvREVhx0tSfYC6KkFHmLWllIIZbQ3NsEG7OARrWuaXSRJyey0vjIQkTEvMO"
+	revocations := []DeviceKeyRevocation{
+		{ID: 1, BrandID: "system", Model: "alder", SerialNumber: "R123456L", Reason: "Stolen device"},
+		{ID: 2, BrandID: "system", Fingerprint: fingerprint, Reason: "Leaked device-key"},
+	}
+	if err := db.SyncDeviceKeyRevocations(revocations); err != nil {
+		t.Fatalf("Error storing the revocations: %v", err)
+	}
+
+	tests := []struct {
+		brandID      string
+		model        string
+		serialNumber string
+		fingerprint  string
+		revocationID int
+	}{
+		{"system", "alder", "R123456L", "", 1},
+		{"system", "ash", "R123456L", "", 0},
+		{"system", "ash", "A123456L", fingerprint, 2},
+		// A brand cannot revoke the device-keys of another brand
+		{"another", "alder", "A123456L", fingerprint, 0},
+	}
+
+	for _, tt := range tests {
+		rev, err := db.CheckDeviceKeyRevoked(tt.brandID, tt.model, tt.serialNumber, tt.fingerprint)
+		if err != nil {
+			t.Fatalf("Error checking the revocations: %v", err)
+		}
+		if rev.ID != tt.revocationID {
+			t.Errorf("%s/%s/%s: expected revocation %d, got %d", tt.brandID, tt.model, tt.serialNumber, tt.revocationID, rev.ID)
+		}
+	}
+}
@@ -18,20 +18,22 @@
 package datastore
 
 import (
-	"encoding/base64"
-	"os/exec"
+	"crypto/rand"
+	"crypto/rsa"
 
+	"github.com/CanonicalLtd/serial-vault/crypt"
 	"github.com/CanonicalLtd/serial-vault/service/log"
-
-	"github.com/snapcore/snapd/asserts"
 )
 
+// keypairBits is the size of the generated signing-keys
+const keypairBits = 4096
+
 // GenerateKeypair generates a new passwordless signing-key for signing assertions
-func GenerateKeypair(authorityID, passphrase, keyName string) error {
+func GenerateKeypair(authorityID, keyName string) error {
 	// Create a new keypair status record to track progress
 	ks := KeypairStatus{AuthorityID: authorityID, KeyName: keyName}
 
-	base64PrivateKey, err := generateKeypair(&ks, passphrase)
+	base64PrivateKey, err := generateKeypair(&ks)
 	if err != nil {
 		return err
 	}
@@ -46,47 +48,35 @@ func GenerateKeypair(authorityID, passphrase, keyName string) error {
 		return err
 	}
 
-	err = Environ.DB.DeleteKeypairStatus(ks)
-	if err != nil {
-		return err
-	}
-
-	// Delete the key from the local store
-	manager := asserts.NewGPGKeypairManager()
-	err = manager.Delete(keyName)
-	if err != nil {
-		log.Printf("Error removing temporary key: %v", err)
-	}
-	return err
+	return Environ.DB.DeleteKeypairStatus(ks)
 }
 
-func generateKeypair(ks *KeypairStatus, passphrase string) (string, error) {
+func generateKeypair(ks *KeypairStatus) (string, error) {
 	id, err := Environ.DB.CreateKeypairStatus(*ks)
 	if err != nil {
 		return "", err
 	}
 	ks.ID = id
 
-	// Generate the keypair
-	manager := asserts.NewGPGKeypairManager()
-	err = manager.Generate(passphrase, ks.KeyName)
+	// Generate the keypair in memory, so nothing is left on disk if a later step fails
+	rsaKey, err := rsa.GenerateKey(rand.Reader, keypairBits)
 	if err != nil {
-		log.Println("Error fetching the generated key", err)
+		log.Println("Error generating the key", err)
 		return "", err
 	}
 
-	// Export the ascii-armored GPG key
+	// Serialize the key as an ascii-armored private key file
 	ks.Status = KeypairStatusExporting
 	if err = Environ.DB.UpdateKeypairStatus(*ks); err != nil {
 		return "", err
 	}
-	out, err := exec.Command("gpg", "--homedir", "~/.snap/gnupg", "--armor", "--export-secret-key", ks.KeyName).Output()
+	base64PrivateKey, err := crypt.SerializePrivateKey(rsaKey)
 	if err != nil {
-		log.Println("Error exporting the generated key", err)
+		log.Println("Error serializing the generated key", err)
 		return "", err
 	}
 
-	return base64.StdEncoding.EncodeToString(out), nil
+	return base64PrivateKey, nil
 }
 
 func importPrivateKey(ks *KeypairStatus, base64PrivateKey string) (string, string, error) {
@@ -0,0 +1,133 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package datastore
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/CanonicalLtd/serial-vault/config"
+	"github.com/CanonicalLtd/serial-vault/crypt"
+)
+
+// generateKeypairMockDB records the keypair status and the stored keypair
+type generateKeypairMockDB struct {
+	sealedKeyMockDB
+	statuses []string
+	deleted  bool
+}
+
+func (mdb *generateKeypairMockDB) UpdateKeypairStatus(ks KeypairStatus) error {
+	mdb.statuses = append(mdb.statuses, ks.Status)
+	return nil
+}
+
+func (mdb *generateKeypairMockDB) DeleteKeypairStatus(ks KeypairStatus) error {
+	mdb.deleted = true
+	return nil
+}
+
+func (mdb *generateKeypairMockDB) PutSetting(setting Setting) error {
+	mdb.settings[setting.Code] = setting
+	return nil
+}
+
+func (mdb *generateKeypairMockDB) PutKeypair(keypair Keypair) (string, error) {
+	mdb.keypairs = append(mdb.keypairs, keypair)
+	return "", nil
+}
+
+func TestGenerateKeypair(t *testing.T) {
+	settings := config.Settings{KeyStoreType: "database", KeyStoreSecret: "this needs to be something secure"}
+	mdb := &generateKeypairMockDB{sealedKeyMockDB: sealedKeyMockDB{settings: map[string]Setting{}}}
+	Environ = &Env{Config: settings, DB: mdb}
+
+	keystore, err := getKeyStore(settings)
+	if err != nil {
+		t.Fatalf("Error opening the keystore: %v", err)
+	}
+	Environ.KeypairDB = keystore
+
+	if err := GenerateKeypair("System", "generated"); err != nil {
+		t.Fatalf("Error generating the keypair: %v", err)
+	}
+
+	expected := []string{KeypairStatusExporting, KeypairStatusEncrypting, KeypairStatusStoring}
+	if len(mdb.statuses) != len(expected) {
+		t.Fatalf("Expected statuses %v, got %v", expected, mdb.statuses)
+	}
+	for i := range expected {
+		if mdb.statuses[i] != expected[i] {
+			t.Errorf("Expected status %s, got %s", expected[i], mdb.statuses[i])
+		}
+	}
+	if !mdb.deleted {
+		t.Error("Expected the keypair status to be removed")
+	}
+
+	// The stored keypair unseals to a 4096-bit signing-key
+	if len(mdb.keypairs) != 1 {
+		t.Fatalf("Expected 1 stored keypair, got %d", len(mdb.keypairs))
+	}
+	keypair := mdb.keypairs[0]
+	if keypair.AuthorityID != "System" || keypair.KeyName != "generated" {
+		t.Errorf("Unexpected keypair: %s/%s", keypair.AuthorityID, keypair.KeyName)
+	}
+	base64SigningKey, err := decryptKeypair(keypair.AuthorityID, keypair.KeyID, keypair.SealedKey)
+	if err != nil {
+		t.Fatalf("Error unsealing the generated keypair: %v", err)
+	}
+	rsaKey, _, err := crypt.DeserializeRSAPrivateKey(string(base64SigningKey))
+	if err != nil {
+		t.Fatalf("Error deserializing the generated keypair: %v", err)
+	}
+	if rsaKey.N.BitLen() != keypairBits {
+		t.Errorf("Expected a %d-bit key, got %d", keypairBits, rsaKey.N.BitLen())
+	}
+}
+
+// generateKeypairErrorMockDB fails to store the keypair
+type generateKeypairErrorMockDB struct {
+	generateKeypairMockDB
+}
+
+func (mdb *generateKeypairErrorMockDB) PutKeypair(keypair Keypair) (string, error) {
+	return "", errors.New("MOCK error storing the keypair")
+}
+
+func TestGenerateKeypairError(t *testing.T) {
+	settings := config.Settings{KeyStoreType: "database", KeyStoreSecret: "this needs to be something secure"}
+	mdb := &generateKeypairErrorMockDB{generateKeypairMockDB{sealedKeyMockDB: sealedKeyMockDB{settings: map[string]Setting{}}}}
+	Environ = &Env{Config: settings, DB: mdb}
+
+	keystore, err := getKeyStore(settings)
+	if err != nil {
+		t.Fatalf("Error opening the keystore: %v", err)
+	}
+	Environ.KeypairDB = keystore
+
+	if err := GenerateKeypair("System", "generated"); err == nil {
+		t.Fatal("Expected an error storing the keypair")
+	}
+	if mdb.deleted {
+		t.Error("Expected the keypair status to be kept")
+	}
+}
@@ -0,0 +1,76 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package datastore
+
+import (
+	"time"
+
+	"github.com/CanonicalLtd/serial-vault/service/log"
+)
+
+const (
+	defaultKeyExpiryWarningDays = 30
+	defaultRolloverInterval     = 60
+)
+
+// KeyExpiryWarningDays returns the configured number of days before its not-after date
+// that a signing-key is reported as expiring
+func KeyExpiryWarningDays() int {
+	if Environ.Config.KeyExpiryWarningDays > 0 {
+		return Environ.Config.KeyExpiryWarningDays
+	}
+	return defaultKeyExpiryWarningDays
+}
+
+// ExpiringKeypairs lists the active keypairs that expire within the number of days,
+// including the ones that have already expired
+func ExpiringKeypairs(keypairs []Keypair, days int) []Keypair {
+	now := time.Now()
+	within := time.Duration(days) * 24 * time.Hour
+
+	expiring := []Keypair{}
+	for _, k := range keypairs {
+		if k.Active && k.ExpiresWithin(now, within) {
+			expiring = append(expiring, k)
+		}
+	}
+	return expiring
+}
+
+// RolloverModels switches the models to their next keypair when the rollover is due,
+// until the done channel is closed. Signing uses the next keypair from the time of the
+// rollover, so this only has to catch up with the schedule
+func RolloverModels(done <-chan struct{}) {
+	ticker := time.NewTicker(defaultRolloverInterval * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			// Errors are logged, and the rollover is tried again at the next interval
+			if count, err := Environ.DB.RolloverModelKeypairs(time.Now()); err == nil && count > 0 {
+				log.Printf("Rolled over the signing-key of %d models", count)
+			}
+		}
+	}
+}
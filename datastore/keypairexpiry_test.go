@@ -0,0 +1,195 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package datastore
+
+import (
+	"testing"
+	"time"
+
+	"github.com/CanonicalLtd/serial-vault/config"
+)
+
+func TestKeypairCheckValidity(t *testing.T) {
+	now := time.Now()
+	before := now.Add(-time.Hour)
+	after := now.Add(time.Hour)
+
+	tests := []struct {
+		keypair Keypair
+		reason  string
+	}{
+		{Keypair{}, ""},
+		{Keypair{NotBefore: &before, NotAfter: &after}, ""},
+		{Keypair{NotBefore: &after}, PolicyNotYetValid},
+		{Keypair{NotAfter: &before}, PolicyExpired},
+		{Keypair{NotAfter: &now}, PolicyExpired},
+	}
+
+	for _, tc := range tests {
+		err := tc.keypair.CheckValidity(now)
+		if len(tc.reason) == 0 {
+			if err != nil {
+				t.Errorf("Expected the keypair to be valid: %v", err)
+			}
+			continue
+		}
+		if policyErr, ok := err.(*KeypairPolicyError); !ok || policyErr.Reason != tc.reason {
+			t.Errorf("Expected reason %s, got: %v", tc.reason, err)
+		}
+	}
+}
+
+func TestExpiringKeypairs(t *testing.T) {
+	soon := time.Now().Add(24 * time.Hour)
+	later := time.Now().Add(60 * 24 * time.Hour)
+	expired := time.Now().Add(-24 * time.Hour)
+
+	keypairs := []Keypair{
+		{ID: 1, Active: true},
+		{ID: 2, Active: true, NotAfter: &soon},
+		{ID: 3, Active: true, NotAfter: &later},
+		{ID: 4, Active: true, NotAfter: &expired},
+		{ID: 5, Active: false, NotAfter: &soon},
+	}
+
+	expiring := ExpiringKeypairs(keypairs, 30)
+	if len(expiring) != 2 || expiring[0].ID != 2 || expiring[1].ID != 4 {
+		t.Errorf("Unexpected expiring keypairs: %v", expiring)
+	}
+
+	if len(ExpiringKeypairs(keypairs, 90)) != 3 {
+		t.Error("Expected the keypairs that expire within 90 days")
+	}
+}
+
+func TestValidateRollover(t *testing.T) {
+	rollover := time.Now()
+
+	tests := []struct {
+		model Model
+		valid bool
+	}{
+		{Model{KeypairID: 1}, true},
+		{Model{KeypairID: 1, NextKeypairID: 2, RolloverAt: &rollover}, true},
+		{Model{KeypairID: 1, NextKeypairID: 2}, false},
+		{Model{KeypairID: 1, NextKeypairID: 1, RolloverAt: &rollover}, false},
+	}
+
+	for _, tc := range tests {
+		if err := validateRollover(tc.model); (err == nil) != tc.valid {
+			t.Errorf("Expected the rollover of %v to be valid=%v: %v", tc.model, tc.valid, err)
+		}
+	}
+}
+
+func openRolloverTestDatabase(t *testing.T, rolloverAt time.Time) *DB {
+	db := openKeystoreTestDatabase(t)
+	if err := db.CreateModelTable(); err != nil {
+		t.Fatalf("Error creating the model table: %v", err)
+	}
+
+	env := Environ
+	Environ = &Env{DB: db, Config: config.Settings{Driver: "sqlite3"}}
+	t.Cleanup(func() { Environ = env })
+
+	for _, k := range []SyncKeypair{
+		{Keypair: Keypair{ID: 1, AuthorityID: "system", KeyID: "current", Active: true, SealedKey: "sealed1", KeyName: "current"}},
+		{Keypair: Keypair{ID: 2, AuthorityID: "system", KeyID: "next", Active: true, SealedKey: "sealed2", KeyName: "next"}},
+	} {
+		if err := db.SyncKeypair(k); err != nil {
+			t.Fatalf("Error creating the keypair: %v", err)
+		}
+	}
+
+	model := Model{ID: 1, BrandID: "system", Name: "alder", KeypairID: 1, KeypairIDUser: 1, APIKey: "rollover-api-key", NextKeypairID: 2, RolloverAt: &rolloverAt}
+	if err := db.SyncModel(model); err != nil {
+		t.Fatalf("Error creating the model: %v", err)
+	}
+	return db
+}
+
+func TestModelRolloverDue(t *testing.T) {
+	db := openRolloverTestDatabase(t, time.Now().Add(-time.Minute))
+
+	// Signing switches to the next keypair at the rollover time
+	model, err := db.FindModel("system", "alder", "rollover-api-key")
+	if err != nil {
+		t.Fatalf("Error finding the model: %v", err)
+	}
+	if model.KeypairID != 2 || model.KeyID != "next" || model.SealedKey != "sealed2" {
+		t.Errorf("Expected the next keypair, got: %d %s", model.KeypairID, model.KeyID)
+	}
+
+	// The model is then updated to use the next keypair
+	count, err := db.RolloverModelKeypairs(time.Now())
+	if err != nil || count != 1 {
+		t.Fatalf("Expected one model to roll over, got: %d %v", count, err)
+	}
+	model, err = db.FindModel("system", "alder", "rollover-api-key")
+	if err != nil {
+		t.Fatalf("Error finding the model: %v", err)
+	}
+	if model.KeypairID != 2 || model.NextKeypairID != 0 || model.RolloverAt != nil {
+		t.Errorf("Expected the model to be rolled over, got: %d %d %v", model.KeypairID, model.NextKeypairID, model.RolloverAt)
+	}
+}
+
+func TestModelRolloverScheduled(t *testing.T) {
+	rolloverAt := time.Now().Add(time.Hour)
+	db := openRolloverTestDatabase(t, rolloverAt)
+
+	model, err := db.FindModel("system", "alder", "rollover-api-key")
+	if err != nil {
+		t.Fatalf("Error finding the model: %v", err)
+	}
+	if model.KeypairID != 1 || model.NextKeypairID != 2 || model.RolloverAt == nil || !model.RolloverAt.Equal(rolloverAt.UTC().Round(0)) {
+		t.Errorf("Expected the current keypair and the scheduled rollover, got: %d %d %v", model.KeypairID, model.NextKeypairID, model.RolloverAt)
+	}
+
+	count, err := db.RolloverModelKeypairs(time.Now())
+	if err != nil || count != 0 {
+		t.Errorf("Expected no models to roll over, got: %d %v", count, err)
+	}
+}
+
+func TestUpdateKeypairValidity(t *testing.T) {
+	db := openRolloverTestDatabase(t, time.Now())
+
+	notBefore := time.Now().Add(-time.Hour)
+	notAfter := time.Now().Add(time.Hour)
+	if err := db.UpdateKeypairValidity(Keypair{ID: 1, NotBefore: &notAfter, NotAfter: &notBefore}); err == nil {
+		t.Error("Expected an error when the not-before date is after the not-after date")
+	}
+	if err := db.UpdateKeypairValidity(Keypair{ID: 1, NotBefore: &notBefore, NotAfter: &notAfter}); err != nil {
+		t.Fatalf("Error updating the keypair dates: %v", err)
+	}
+
+	keypair, err := db.GetKeypair(1)
+	if err != nil {
+		t.Fatalf("Error fetching the keypair: %v", err)
+	}
+	if keypair.NotBefore == nil || keypair.NotAfter == nil || !keypair.NotAfter.Equal(notAfter) {
+		t.Errorf("Unexpected keypair dates: %v %v", keypair.NotBefore, keypair.NotAfter)
+	}
+	if err := keypair.CheckValidity(time.Now()); err != nil {
+		t.Errorf("Expected the keypair to be valid: %v", err)
+	}
+}
@@ -23,7 +23,10 @@ package datastore
 import (
 	"database/sql"
 	"errors"
+	"fmt"
+	"time"
 
+	"github.com/CanonicalLtd/serial-vault/crypt"
 	"github.com/CanonicalLtd/serial-vault/service/log"
 )
 
@@ -35,25 +38,38 @@ const createKeypairTableSQL = `
 		active        boolean default true,
 		sealed_key    text,
 		assertion     text default '',
-		key_name      varchar(200) default ''
+		key_name      varchar(200) default '',
+		policy_types  text default '',
+		policy_brands text default '',
+		policy_models text default '',
+		not_before    timestamp,
+		not_after     timestamp
 	)
 `
 const listKeypairsSQL = `
-	SELECT k.id, k.authority_id, k.key_id, k.active, k.assertion, k.key_name
+	SELECT k.id, k.authority_id, k.key_id, k.active, k.assertion, k.key_name,
+		k.policy_types, k.policy_brands, k.policy_models, k.not_before, k.not_after
 	FROM keypair k 
 	ORDER BY k.authority_id, k.key_id`
 const listKeypairsForUserSQL = `
-	SELECT k.id, k.authority_id, k.key_id, k.active, k.assertion, k.key_name 
+	SELECT k.id, k.authority_id, k.key_id, k.active, k.assertion, k.key_name,
+		k.policy_types, k.policy_brands, k.policy_models, k.not_before, k.not_after
 	FROM keypair k
 	INNER JOIN account acc ON acc.authority_id=k.authority_id
 	INNER JOIN useraccountlink ua ON ua.account_id=acc.id
 	INNER JOIN userinfo u ON ua.user_id=u.id
 	WHERE u.username=$1
 	ORDER BY k.authority_id, k.key_id`
-const getKeypairSQL = "SELECT id, authority_id, key_id, active, sealed_key, assertion, key_name FROM keypair WHERE id=$1"
-const getKeypairByPublicIDSQL = "SELECT id, authority_id, key_id, active, sealed_key, assertion, key_name FROM keypair WHERE authority_id=$1 AND key_id=$2"
+const getKeypairSQL = `
+	SELECT id, authority_id, key_id, active, sealed_key, assertion, key_name, policy_types, policy_brands, policy_models, not_before, not_after
+	FROM keypair
+	WHERE id=$1`
+const getKeypairByPublicIDSQL = `
+	SELECT id, authority_id, key_id, active, sealed_key, assertion, key_name, policy_types, policy_brands, policy_models, not_before, not_after
+	FROM keypair
+	WHERE authority_id=$1 AND key_id=$2`
 const getKeypairByNameSQL = `
-	SELECT id, authority_id, key_id, active, sealed_key, assertion, key_name
+	SELECT id, authority_id, key_id, active, sealed_key, assertion, key_name, policy_types, policy_brands, policy_models, not_before, not_after
 	FROM keypair
 	WHERE authority_id=$1 AND key_name=$2`
 const toggleKeypairSQL = "UPDATE keypair SET active=$2 WHERE id=$1"
@@ -84,17 +100,41 @@ const checkKeypairKeynameExistsSQL = `
 // sqlite3 syntax for syncing data locally
 const syncUpsertKeypairSQL = `
 	INSERT OR REPLACE INTO keypair
-	(id,authority_id,key_id,sealed_key,assertion,active,key_name)
-	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	(id,authority_id,key_id,sealed_key,assertion,active,key_name,policy_types,policy_brands,policy_models,not_before,not_after)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
 `
 
 const updateKeypairSQL = "UPDATE keypair SET assertion=$2 WHERE id=$1"
 
+const updateKeypairSealedKeySQL = "UPDATE keypair SET sealed_key=$1 WHERE id=$2"
+const getKeypairSealedKeySQL = "SELECT sealed_key FROM keypair WHERE id=$1"
+
+const updateKeypairPolicySQL = "UPDATE keypair SET policy_types=$1, policy_brands=$2, policy_models=$3 WHERE id=$4"
+const updateKeypairValiditySQL = "UPDATE keypair SET not_before=$1, not_after=$2 WHERE id=$3"
+
+// A keypair cannot be deleted while a model or model assertion uses it
+const countKeypairModelsSQL = `
+	SELECT count(*) FROM model
+	WHERE keypair_id=$1 OR user_keypair_id=$1 OR next_keypair_id=$1`
+const countKeypairModelAssertionsSQL = "SELECT count(*) FROM modelassertion WHERE keypair_id=$1"
+const deleteKeypairStatusForKeypairSQL = "DELETE FROM keypairstatus WHERE keypair_id=$1"
+const deleteKeypairSQL = "DELETE FROM keypair WHERE id=$1"
+
 // Add the assertion field to store the assertion for the account key to the table
 const alterKeypairAddAssertion = "ALTER TABLE keypair ADD COLUMN assertion TEXT DEFAULT ''"
 
 // Add the key_name field to store name of the key
 const alterKeypairAddKeyName = "ALTER TABLE keypair ADD COLUMN key_name VARCHAR(200) DEFAULT ''"
+
+// Add the usage policy fields to restrict what a key may sign
+const alterKeypairAddPolicyTypes = "ALTER TABLE keypair ADD COLUMN policy_types TEXT DEFAULT ''"
+const alterKeypairAddPolicyBrands = "ALTER TABLE keypair ADD COLUMN policy_brands TEXT DEFAULT ''"
+const alterKeypairAddPolicyModels = "ALTER TABLE keypair ADD COLUMN policy_models TEXT DEFAULT ''"
+
+// Add the dates that the key is valid between
+const alterKeypairAddNotBefore = "ALTER TABLE keypair ADD COLUMN not_before TIMESTAMP"
+const alterKeypairAddNotAfter = "ALTER TABLE keypair ADD COLUMN not_after TIMESTAMP"
+
 const updateKeypairKeyNameFromStatus = `
 	UPDATE keypair k
 	SET key_name = ks.key_name
@@ -117,6 +157,9 @@ type Keypair struct {
 	SealedKey   string
 	Assertion   string
 	KeyName     string
+	Policy      KeypairPolicy
+	NotBefore   *time.Time
+	NotAfter    *time.Time
 }
 
 // SyncKeypair is the response to fetch keypairs
@@ -135,6 +178,11 @@ func (db *DB) CreateKeypairTable() error {
 func (db *DB) AlterKeypairTable() error {
 	db.Exec(alterKeypairAddAssertion)
 	db.Exec(alterKeypairAddKeyName)
+	db.Exec(alterKeypairAddPolicyTypes)
+	db.Exec(alterKeypairAddPolicyBrands)
+	db.Exec(alterKeypairAddPolicyModels)
+	db.Exec(alterKeypairAddNotBefore)
+	db.Exec(alterKeypairAddNotAfter)
 	db.Exec(updateKeypairKeyNameFromStatus)
 	db.Exec(updateKeypairKeyNameDefault)
 	// Ignore errors as the field may already be added
@@ -166,10 +214,12 @@ func (db *DB) listKeypairsFilteredByUser(username string) ([]Keypair, error) {
 
 	for rows.Next() {
 		keypair := Keypair{}
-		err := rows.Scan(&keypair.ID, &keypair.AuthorityID, &keypair.KeyID, &keypair.Active, &keypair.Assertion, &keypair.KeyName)
+		policy := policyColumns{}
+		err := rows.Scan(&keypair.ID, &keypair.AuthorityID, &keypair.KeyID, &keypair.Active, &keypair.Assertion, &keypair.KeyName, &policy.types, &policy.brands, &policy.models, &keypair.NotBefore, &keypair.NotAfter)
 		if err != nil {
 			return nil, err
 		}
+		keypair.Policy = policy.policy()
 		keypairs = append(keypairs, keypair)
 	}
 
@@ -179,12 +229,14 @@ func (db *DB) listKeypairsFilteredByUser(username string) ([]Keypair, error) {
 // GetKeypair fetches a single keypair from the database by ID
 func (db *DB) GetKeypair(keypairID int) (Keypair, error) {
 	keypair := Keypair{}
+	policy := policyColumns{}
 
-	err := db.QueryRow(getKeypairSQL, keypairID).Scan(&keypair.ID, &keypair.AuthorityID, &keypair.KeyID, &keypair.Active, &keypair.SealedKey, &keypair.Assertion, &keypair.KeyName)
+	err := db.QueryRow(getKeypairSQL, keypairID).Scan(&keypair.ID, &keypair.AuthorityID, &keypair.KeyID, &keypair.Active, &keypair.SealedKey, &keypair.Assertion, &keypair.KeyName, &policy.types, &policy.brands, &policy.models, &keypair.NotBefore, &keypair.NotAfter)
 	if err != nil {
 		log.Printf("Error retrieving keypair by ID: %v\n", err)
 		return keypair, err
 	}
+	keypair.Policy = policy.policy()
 
 	return keypair, nil
 }
@@ -192,12 +244,14 @@ func (db *DB) GetKeypair(keypairID int) (Keypair, error) {
 // GetKeypairByPublicID fetches a single keypair from the database by public ID
 func (db *DB) GetKeypairByPublicID(authorityID, keyID string) (Keypair, error) {
 	keypair := Keypair{}
+	policy := policyColumns{}
 
-	err := db.QueryRow(getKeypairByPublicIDSQL, authorityID, keyID).Scan(&keypair.ID, &keypair.AuthorityID, &keypair.KeyID, &keypair.Active, &keypair.SealedKey, &keypair.Assertion, &keypair.KeyName)
+	err := db.QueryRow(getKeypairByPublicIDSQL, authorityID, keyID).Scan(&keypair.ID, &keypair.AuthorityID, &keypair.KeyID, &keypair.Active, &keypair.SealedKey, &keypair.Assertion, &keypair.KeyName, &policy.types, &policy.brands, &policy.models, &keypair.NotBefore, &keypair.NotAfter)
 	if err != nil {
 		log.Printf("Error retrieving keypair by ID: %v\n", err)
 		return keypair, err
 	}
+	keypair.Policy = policy.policy()
 
 	return keypair, nil
 }
@@ -205,12 +259,14 @@ func (db *DB) GetKeypairByPublicID(authorityID, keyID string) (Keypair, error) {
 // GetKeypairByName fetches a single keypair from the database by its name
 func (db *DB) GetKeypairByName(authorityID, keyName string) (Keypair, error) {
 	keypair := Keypair{}
+	policy := policyColumns{}
 
-	err := db.QueryRow(getKeypairByNameSQL, authorityID, keyName).Scan(&keypair.ID, &keypair.AuthorityID, &keypair.KeyID, &keypair.Active, &keypair.SealedKey, &keypair.Assertion, &keypair.KeyName)
+	err := db.QueryRow(getKeypairByNameSQL, authorityID, keyName).Scan(&keypair.ID, &keypair.AuthorityID, &keypair.KeyID, &keypair.Active, &keypair.SealedKey, &keypair.Assertion, &keypair.KeyName, &policy.types, &policy.brands, &policy.models, &keypair.NotBefore, &keypair.NotAfter)
 	if err != nil {
 		log.Printf("Error retrieving keypair by name: %v\n", err)
 		return keypair, err
 	}
+	keypair.Policy = policy.policy()
 
 	return keypair, nil
 }
@@ -242,7 +298,9 @@ func (db *DB) SyncKeypair(keypair SyncKeypair) error {
 		return errors.New("The Authority ID and the Key ID must be entered")
 	}
 
-	_, err := db.Exec(syncUpsertKeypairSQL, keypair.ID, keypair.AuthorityID, keypair.KeyID, keypair.SealedKey, keypair.Assertion, keypair.Active, keypair.KeyName)
+	policy := keypair.Policy.columns()
+	_, err := db.Exec(syncUpsertKeypairSQL, keypair.ID, keypair.AuthorityID, keypair.KeyID, keypair.SealedKey, keypair.Assertion, keypair.Active, keypair.KeyName,
+		policy.types, policy.brands, policy.models, utcTime(keypair.NotBefore), utcTime(keypair.NotAfter))
 	if err != nil {
 		log.Printf("Error updating the database keypair: %v\n", err)
 		return err
@@ -282,6 +340,135 @@ func (db *DB) updateKeypairAssertion(keypairID int, assertion string) error {
 	return nil
 }
 
+// UpdateKeypairPolicy replaces the usage policy of a keypair
+func (db *DB) UpdateKeypairPolicy(keypairID int, policy KeypairPolicy) error {
+	p := policy.columns()
+	_, err := db.Exec(updateKeypairPolicySQL, p.types, p.brands, p.models, keypairID)
+	if err != nil {
+		log.Printf("Error updating the database keypair policy: %v\n", err)
+		return err
+	}
+
+	return nil
+}
+
+// UpdateKeypairValidity sets the dates that a keypair can be used between
+func (db *DB) UpdateKeypairValidity(keypair Keypair) error {
+	if err := keypair.ValidateValidity(); err != nil {
+		return err
+	}
+
+	_, err := db.Exec(updateKeypairValiditySQL, utcTime(keypair.NotBefore), utcTime(keypair.NotAfter), keypair.ID)
+	if err != nil {
+		log.Printf("Error updating the database keypair dates: %v\n", err)
+		return err
+	}
+
+	return nil
+}
+
+// DeleteKeypair removes a keypair that is not used by any model, with its generation status
+func (db *DB) DeleteKeypair(keypairID int) error {
+	return db.transaction(func(tx *sql.Tx) error {
+		for _, query := range []string{countKeypairModelsSQL, countKeypairModelAssertionsSQL} {
+			var count int
+			if err := tx.QueryRow(query, keypairID).Scan(&count); err != nil {
+				return fmt.Errorf("error checking the models of the signing-key: %v", err)
+			}
+			if count > 0 {
+				return errors.New("the signing-key is used by a model and cannot be deleted")
+			}
+		}
+
+		if _, err := tx.Exec(deleteKeypairStatusForKeypairSQL, keypairID); err != nil {
+			return fmt.Errorf("error deleting the signing-key status: %v", err)
+		}
+
+		result, err := tx.Exec(deleteKeypairSQL, keypairID)
+		if err != nil {
+			return fmt.Errorf("error deleting the signing-key: %v", err)
+		}
+		if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+			return fmt.Errorf("cannot find the signing-key %d", keypairID)
+		}
+		return nil
+	})
+}
+
+// UpdateKeypairSealedKey replaces the sealed signing-key of a keypair and its sealed auth-key
+// setting in a single transaction, so they cannot get out of step
+func (db *DB) UpdateKeypairSealedKey(keypair Keypair, authKey Setting) error {
+	err := db.transaction(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(updateKeypairSealedKeySQL, keypair.SealedKey, keypair.ID); err != nil {
+			return err
+		}
+		_, err := tx.Exec(updateSettingDataSQL, authKey.Data, authKey.Code)
+		return err
+	})
+	if err != nil {
+		log.Printf("Error updating the sealed keypair: %v\n", err)
+	}
+	return err
+}
+
+// UpdateKeypairsSealedKeys replaces the sealed signing-keys and auth-key settings of the keypairs, and
+// the sealed signing-keys of the imports that are waiting for approval, in a single transaction. The
+// stored values are read back and checked with the verify functions before the transaction is committed,
+// so any failure leaves all the keypairs and imports unchanged
+func (db *DB) UpdateKeypairsSealedKeys(keypairs []SyncKeypair, imports []PendingOperation, verify func(SyncKeypair) error, verifyImport func(PendingOperation) error) error {
+	err := db.transaction(func(tx *sql.Tx) error {
+		for _, k := range keypairs {
+			code := crypt.GenerateAuthKey(k.AuthorityID, k.KeyID)
+			if _, err := tx.Exec(updateKeypairSealedKeySQL, k.SealedKey, k.ID); err != nil {
+				return err
+			}
+			result, err := tx.Exec(updateSettingDataSQL, k.AuthKeyHash, code)
+			if err != nil {
+				return err
+			}
+			if rows, err := result.RowsAffected(); err != nil || rows == 0 {
+				return fmt.Errorf("Cannot find the auth-key for the keypair %s/%s", k.AuthorityID, k.KeyName)
+			}
+		}
+		for _, op := range imports {
+			result, err := tx.Exec(updateOpenPendingOperationDataSQL, op.Data, op.ID)
+			if err != nil {
+				return err
+			}
+			if rows, err := result.RowsAffected(); err != nil || rows == 0 {
+				return fmt.Errorf("The import of the keypair %s/%s is no longer waiting for approval", op.AuthorityID, op.KeyName)
+			}
+		}
+
+		for _, k := range keypairs {
+			stored := SyncKeypair{Keypair: k.Keypair}
+			if err := tx.QueryRow(getKeypairSealedKeySQL, k.ID).Scan(&stored.SealedKey); err != nil {
+				return err
+			}
+			if err := tx.QueryRow(getSettingDataSQL, crypt.GenerateAuthKey(k.AuthorityID, k.KeyID)).Scan(&stored.AuthKeyHash); err != nil {
+				return err
+			}
+			if err := verify(stored); err != nil {
+				return fmt.Errorf("The keypair %s/%s does not unseal: %v", k.AuthorityID, k.KeyName, err)
+			}
+		}
+		for _, op := range imports {
+			stored := op
+			if err := tx.QueryRow(getPendingOperationDataSQL, op.ID).Scan(&stored.Data); err != nil {
+				return err
+			}
+			if err := verifyImport(stored); err != nil {
+				return fmt.Errorf("The import of the keypair %s/%s does not unseal: %v", op.AuthorityID, op.KeyName, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error updating the sealed keypairs: %v\n", err)
+	}
+	return err
+}
+
 // CheckKeypairKeynameExists validates that there is a keypair for the brand and key name
 func (db *DB) CheckKeypairKeynameExists(authorityID, name string) bool {
 	row := db.QueryRow(checkKeypairKeynameExistsSQL, authorityID, name)
@@ -0,0 +1,258 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package datastore
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/CanonicalLtd/serial-vault/service/log"
+	"github.com/snapcore/snapd/asserts"
+)
+
+// Reasons that a keypair usage policy rejects an assertion
+const (
+	PolicyAssertionType = "assertion-type"
+	PolicyBrand         = "brand"
+	PolicyModel         = "model"
+	PolicyNotYetValid   = "not-yet-valid"
+	PolicyExpired       = "expired"
+)
+
+// KeypairPolicy restricts the assertions that a keypair may sign. An empty list places
+// no restriction, so a keypair without a policy may sign anything
+type KeypairPolicy struct {
+	AssertionTypes []string
+	Brands         []string
+	Models         []string
+}
+
+// KeypairPolicyError is returned when the usage policy of a keypair does not allow it to
+// sign an assertion
+type KeypairPolicyError struct {
+	Reason  string
+	Message string
+}
+
+func (e *KeypairPolicyError) Error() string {
+	return e.Message
+}
+
+// policyColumns holds the policy of a keypair as it is stored in the database
+type policyColumns struct {
+	types  string
+	brands string
+	models string
+}
+
+func (p policyColumns) policy() KeypairPolicy {
+	return KeypairPolicy{
+		AssertionTypes: splitPolicyList(p.types),
+		Brands:         splitPolicyList(p.brands),
+		Models:         splitPolicyList(p.models),
+	}
+}
+
+func (p KeypairPolicy) columns() policyColumns {
+	p = p.Normalize()
+	return policyColumns{
+		types:  strings.Join(p.AssertionTypes, ","),
+		brands: strings.Join(p.Brands, ","),
+		models: strings.Join(p.Models, ","),
+	}
+}
+
+// Normalize trims the policy values and removes the empty ones
+func (p KeypairPolicy) Normalize() KeypairPolicy {
+	return KeypairPolicy{
+		AssertionTypes: normalizePolicyList(p.AssertionTypes),
+		Brands:         normalizePolicyList(p.Brands),
+		Models:         normalizePolicyList(p.Models),
+	}
+}
+
+// Validate checks that the policy only refers to known assertion types
+func (p KeypairPolicy) Validate() error {
+	for _, name := range p.AssertionTypes {
+		if asserts.Type(name) == nil {
+			return fmt.Errorf("Unknown assertion type %q", name)
+		}
+	}
+	for _, v := range append(p.Brands, p.Models...) {
+		if strings.Contains(v, ",") {
+			return fmt.Errorf("The brand or model %q is invalid", v)
+		}
+	}
+	return nil
+}
+
+// Check verifies that the policy allows an assertion to be signed. The account-key-request
+// is always allowed, as it is signed by the key itself to register it with the store
+func (p KeypairPolicy) Check(assertType *asserts.AssertionType, headers map[string]interface{}) error {
+	if assertType == asserts.AccountKeyRequestType {
+		return nil
+	}
+
+	if len(p.AssertionTypes) > 0 && !policyListContains(p.AssertionTypes, assertType.Name) {
+		return &KeypairPolicyError{PolicyAssertionType, fmt.Sprintf("The signing-key is not allowed to sign %s assertions", assertType.Name)}
+	}
+
+	brand := policyBrand(headers)
+	if len(p.Brands) > 0 && !policyListContains(p.Brands, brand) {
+		return &KeypairPolicyError{PolicyBrand, fmt.Sprintf("The signing-key is not allowed to sign assertions for the brand %q", brand)}
+	}
+
+	if len(p.Models) == 0 {
+		return nil
+	}
+	models := policyModels(headers)
+	if len(models) == 0 {
+		return &KeypairPolicyError{PolicyModel, fmt.Sprintf("The signing-key is restricted to models and cannot sign %s assertions without one", assertType.Name)}
+	}
+	for _, m := range models {
+		if !policyListContains(p.Models, m) {
+			return &KeypairPolicyError{PolicyModel, fmt.Sprintf("The signing-key is not allowed to sign assertions for the model %q", m)}
+		}
+	}
+	return nil
+}
+
+// CheckValidity verifies that the keypair can be used at a time, between its not-before and
+// not-after dates
+func (k Keypair) CheckValidity(now time.Time) error {
+	if k.NotBefore != nil && now.Before(*k.NotBefore) {
+		return &KeypairPolicyError{PolicyNotYetValid, fmt.Sprintf("The signing-key is not valid until %s", k.NotBefore.Format(time.RFC3339))}
+	}
+	if k.NotAfter != nil && !now.Before(*k.NotAfter) {
+		return &KeypairPolicyError{PolicyExpired, fmt.Sprintf("The signing-key expired at %s", k.NotAfter.Format(time.RFC3339))}
+	}
+	return nil
+}
+
+// ValidateValidity checks that the not-before date of the keypair is before its not-after date
+func (k Keypair) ValidateValidity() error {
+	if k.NotBefore != nil && k.NotAfter != nil && !k.NotBefore.Before(*k.NotAfter) {
+		return errors.New("The not-before date must be before the not-after date")
+	}
+	return nil
+}
+
+// ExpiresWithin checks whether the keypair expires before a time from now
+func (k Keypair) ExpiresWithin(now time.Time, d time.Duration) bool {
+	return k.NotAfter != nil && k.NotAfter.Before(now.Add(d))
+}
+
+// checkKeypairPolicy checks the validity dates and the usage policy of the keypair before an
+// assertion is signed. A rejected assertion is recorded in the signing log
+func checkKeypairPolicy(assertType *asserts.AssertionType, headers map[string]interface{}, authorityID, keyID string) error {
+	keypair, err := Environ.DB.GetKeypairByPublicID(authorityID, keyID)
+	if err != nil {
+		return fmt.Errorf("Cannot find the signing-key to check its usage policy: %v", err)
+	}
+
+	// A key is registered with the store before it becomes valid
+	if assertType != asserts.AccountKeyRequestType {
+		err = keypair.CheckValidity(time.Now())
+	}
+	if err == nil {
+		err = keypair.Policy.Check(assertType, headers)
+	}
+	policyErr, ok := err.(*KeypairPolicyError)
+	if !ok {
+		return err
+	}
+
+	log.Printf("Keypair %s/%s rejected: %s", authorityID, keypair.KeyName, policyErr.Message)
+	signLog := SigningLog{
+		Make:         policyBrand(headers),
+		Model:        strings.Join(policyModels(headers), ","),
+		SerialNumber: headerString(headers, "serial"),
+		Fingerprint:  headerString(headers, "device-key-sha3-384"),
+		Rejected:     policyErr.Reason,
+	}
+	if err := Environ.DB.CreateSigningLog(signLog); err != nil {
+		log.Printf("Error logging the rejected assertion: %v", err)
+	}
+	return policyErr
+}
+
+// policyBrand is the brand of the assertion, falling back to the signing authority
+func policyBrand(headers map[string]interface{}) string {
+	if brand := headerString(headers, "brand-id"); len(brand) > 0 {
+		return brand
+	}
+	return headerString(headers, "authority-id")
+}
+
+// policyModels is the model, or list of models, of the assertion
+func policyModels(headers map[string]interface{}) []string {
+	if model := headerString(headers, "model"); len(model) > 0 {
+		return []string{model}
+	}
+
+	models := []string{}
+	list, _ := headers["models"].([]interface{})
+	for _, m := range list {
+		if s, ok := m.(string); ok {
+			models = append(models, s)
+		}
+	}
+	return models
+}
+
+// utcTime stores the dates in UTC, so they compare correctly in every database
+func utcTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	u := t.UTC()
+	return &u
+}
+
+func headerString(headers map[string]interface{}, name string) string {
+	s, _ := headers[name].(string)
+	return s
+}
+
+func splitPolicyList(value string) []string {
+	return normalizePolicyList(strings.Split(value, ","))
+}
+
+func normalizePolicyList(values []string) []string {
+	list := []string{}
+	for _, v := range values {
+		if v = strings.TrimSpace(v); len(v) > 0 && !policyListContains(list, v) {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+func policyListContains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
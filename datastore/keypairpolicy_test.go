@@ -0,0 +1,159 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package datastore
+
+import (
+	"testing"
+	"time"
+
+	"github.com/CanonicalLtd/serial-vault/config"
+	"github.com/snapcore/snapd/asserts"
+)
+
+type policyMockDB struct {
+	MockDB
+	policy   KeypairPolicy
+	notAfter *time.Time
+	logs     []SigningLog
+}
+
+func (mdb *policyMockDB) GetKeypairByPublicID(authorityID, keyID string) (Keypair, error) {
+	return Keypair{ID: 1, AuthorityID: authorityID, KeyID: keyID, KeyName: "policy", Active: true, Policy: mdb.policy, NotAfter: mdb.notAfter}, nil
+}
+
+func (mdb *policyMockDB) CreateSigningLog(signLog SigningLog) error {
+	mdb.logs = append(mdb.logs, signLog)
+	return nil
+}
+
+func TestKeypairPolicyCheck(t *testing.T) {
+	serial := map[string]interface{}{"authority-id": "system", "brand-id": "system", "model": "alder", "serial": "A1"}
+	user := map[string]interface{}{"authority-id": "system", "brand-id": "system", "models": []interface{}{"alder", "ash"}}
+	keyRequest := map[string]interface{}{"account-id": "system"}
+
+	tests := []struct {
+		policy     KeypairPolicy
+		assertType *asserts.AssertionType
+		headers    map[string]interface{}
+		reason     string
+	}{
+		{KeypairPolicy{}, asserts.SerialType, serial, ""},
+		{KeypairPolicy{AssertionTypes: []string{"serial"}}, asserts.SerialType, serial, ""},
+		{KeypairPolicy{AssertionTypes: []string{"serial"}}, asserts.ModelType, serial, PolicyAssertionType},
+		{KeypairPolicy{AssertionTypes: []string{"serial"}}, asserts.AccountKeyRequestType, keyRequest, ""},
+		{KeypairPolicy{Brands: []string{"system"}}, asserts.SerialType, serial, ""},
+		{KeypairPolicy{Brands: []string{"other"}}, asserts.SerialType, serial, PolicyBrand},
+		{KeypairPolicy{Models: []string{"alder"}}, asserts.SerialType, serial, ""},
+		{KeypairPolicy{Models: []string{"ash"}}, asserts.SerialType, serial, PolicyModel},
+		{KeypairPolicy{Models: []string{"alder"}}, asserts.SystemUserType, user, PolicyModel},
+		{KeypairPolicy{Models: []string{"alder", "ash"}}, asserts.SystemUserType, user, ""},
+		{KeypairPolicy{Models: []string{"alder"}}, asserts.AccountType, map[string]interface{}{"authority-id": "system"}, PolicyModel},
+	}
+
+	for _, tc := range tests {
+		err := tc.policy.Check(tc.assertType, tc.headers)
+		if len(tc.reason) == 0 {
+			if err != nil {
+				t.Errorf("Expected %v to allow %s: %v", tc.policy, tc.assertType.Name, err)
+			}
+			continue
+		}
+		policyErr, ok := err.(*KeypairPolicyError)
+		if !ok {
+			t.Errorf("Expected a policy error for %v and %s, got: %v", tc.policy, tc.assertType.Name, err)
+			continue
+		}
+		if policyErr.Reason != tc.reason {
+			t.Errorf("Expected reason %s, got: %s", tc.reason, policyErr.Reason)
+		}
+	}
+}
+
+func TestKeypairPolicyColumns(t *testing.T) {
+	policy := KeypairPolicy{AssertionTypes: []string{" serial", "model", "", "serial"}, Brands: []string{"system"}}
+
+	p := policy.columns()
+	if p.types != "serial,model" || p.brands != "system" || p.models != "" {
+		t.Errorf("Unexpected policy columns: %v", p)
+	}
+
+	stored := p.policy()
+	if len(stored.AssertionTypes) != 2 || len(stored.Brands) != 1 || len(stored.Models) != 0 {
+		t.Errorf("Unexpected policy: %v", stored)
+	}
+}
+
+func TestKeypairPolicyValidate(t *testing.T) {
+	if err := (KeypairPolicy{AssertionTypes: []string{"serial", "system-user"}}).Validate(); err != nil {
+		t.Errorf("Expected a valid policy: %v", err)
+	}
+	if err := (KeypairPolicy{AssertionTypes: []string{"invalid"}}).Validate(); err == nil {
+		t.Error("Expected an error for an unknown assertion type")
+	}
+}
+
+func TestSignAssertionPolicyRejected(t *testing.T) {
+	settings := config.Settings{KeyStoreType: "filesystem", KeyStorePath: "../keystore"}
+	db := &policyMockDB{policy: KeypairPolicy{Brands: []string{"other"}}}
+	Environ = &Env{DB: db, Config: settings}
+	if err := OpenKeyStore(settings); err != nil {
+		t.Fatalf("Error opening the keystore: %v", err)
+	}
+
+	headers := map[string]interface{}{
+		"authority-id":        "system",
+		"brand-id":            "system",
+		"model":               "alder",
+		"serial":              "A1",
+		"device-key-sha3-384": "fingerprint",
+	}
+	_, err := Environ.KeypairDB.SignAssertion(asserts.SerialType, headers, nil, "system", "keyid", "")
+	if policyErr, ok := err.(*KeypairPolicyError); !ok || policyErr.Reason != PolicyBrand {
+		t.Fatalf("Expected the brand policy error, got: %v", err)
+	}
+
+	if len(db.logs) != 1 {
+		t.Fatalf("Expected the rejected assertion to be logged, got: %d", len(db.logs))
+	}
+	expected := SigningLog{Make: "system", Model: "alder", SerialNumber: "A1", Fingerprint: "fingerprint", Rejected: PolicyBrand}
+	if db.logs[0] != expected {
+		t.Errorf("Unexpected signing log: %v", db.logs[0])
+	}
+}
+
+func TestSignAssertionExpired(t *testing.T) {
+	settings := config.Settings{KeyStoreType: "filesystem", KeyStorePath: "../keystore"}
+	expired := time.Now().Add(-time.Hour)
+	db := &policyMockDB{notAfter: &expired}
+	Environ = &Env{DB: db, Config: settings}
+	if err := OpenKeyStore(settings); err != nil {
+		t.Fatalf("Error opening the keystore: %v", err)
+	}
+
+	headers := map[string]interface{}{"authority-id": "system", "brand-id": "system", "model": "alder"}
+	_, err := Environ.KeypairDB.SignAssertion(asserts.ModelType, headers, nil, "system", "keyid", "")
+	if policyErr, ok := err.(*KeypairPolicyError); !ok || policyErr.Reason != PolicyExpired {
+		t.Fatalf("Expected the expired key error, got: %v", err)
+	}
+	if len(db.logs) != 1 || db.logs[0].Rejected != PolicyExpired {
+		t.Errorf("Expected the rejected assertion to be logged, got: %v", db.logs)
+	}
+}
@@ -37,6 +37,7 @@ var (
 	FilesystemStore = KeypairStoreType{"filesystem"}
 	DatabaseStore   = KeypairStoreType{"database"}
 	TPM20Store      = KeypairStoreType{"tpm2.0"}
+	PKCS11Store     = KeypairStoreType{"pkcs11"}
 )
 
 // Common error messages.
@@ -62,6 +63,7 @@ type KeypairDatabase struct {
 	KeyStoreType KeypairStoreType
 	*asserts.Database
 	keypairOperator KeypairOperator
+	unsealedKeys    *unsealedKeypairManager
 }
 
 var keypairDB KeypairDatabase
@@ -81,27 +83,43 @@ func getKeyStore(config config.Settings) (*KeypairDatabase, error) {
 	switch config.KeyStoreType {
 	case DatabaseStore.Name:
 		// Prepare the memory store for the unsealed keys
-		memStore := asserts.NewMemoryKeypairManager()
+		memStore := newUnsealedKeypairManager()
 		db, err := asserts.OpenDatabase(&asserts.DatabaseConfig{
 			KeypairManager: memStore,
 		})
 
 		dbOperator := DatabaseKeypairOperator{}
 
-		keypairDB = KeypairDatabase{DatabaseStore, db, &dbOperator}
+		keypairDB = KeypairDatabase{DatabaseStore, db, &dbOperator, memStore}
 		return &keypairDB, err
 
 	case TPM20Store.Name:
-		// Initialize the TPM store
-		tpm20 := TPM20KeypairOperator{config.KeyStorePath, config.KeyStoreSecret, &tpm20Command{}}
+		// Initialize the TPM store, using the TPM2.0 tools unless a device is configured
+		var tpm20 KeypairOperator = &TPM20KeypairOperator{config.KeyStorePath, config.KeyStoreSecret, &tpm20Command{}}
+		if len(config.TPMDevice) > 0 {
+			tpm20 = &TPM20NativeKeypairOperator{device: config.TPMDevice, secret: config.KeyStoreSecret}
+		}
 
 		// Prepare the memory store for the unsealed keys
-		memStore := asserts.NewMemoryKeypairManager()
+		memStore := newUnsealedKeypairManager()
 		db, err := asserts.OpenDatabase(&asserts.DatabaseConfig{
 			KeypairManager: memStore,
 		})
 
-		keypairDB = KeypairDatabase{TPM20Store, db, &tpm20}
+		keypairDB = KeypairDatabase{TPM20Store, db, tpm20, memStore}
+		return &keypairDB, err
+
+	case PKCS11Store.Name:
+		// The signing-keys stay on the token, so the keypair manager signs via the token
+		p11, err := newPKCS11KeypairOperator(config)
+		if err != nil {
+			return nil, err
+		}
+		db, err := asserts.OpenDatabase(&asserts.DatabaseConfig{
+			KeypairManager: p11.keyMgr,
+		})
+
+		keypairDB = KeypairDatabase{PKCS11Store, db, p11, nil}
 		return &keypairDB, err
 
 	case FilesystemStore.Name:
@@ -113,7 +131,7 @@ func getKeyStore(config config.Settings) (*KeypairDatabase, error) {
 			KeypairManager: fsStore,
 		})
 
-		keypairDB = KeypairDatabase{FilesystemStore, db, nil}
+		keypairDB = KeypairDatabase{FilesystemStore, db, nil, nil}
 		return &keypairDB, err
 
 	default:
@@ -133,6 +151,9 @@ func (kdb *KeypairDatabase) ImportSigningKey(authorityID, base64PrivateKey strin
 		fallthrough
 
 	case TPM20Store.Name:
+		fallthrough
+
+	case PKCS11Store.Name:
 		// Use an internal operator to handle encryption of signing-keys for storage
 		sealedPrivateKey, err := kdb.keypairOperator.ImportKeypair(authorityID, privateKey.PublicKey().ID(), base64PrivateKey)
 		return privateKey, sealedPrivateKey, err
@@ -143,8 +164,18 @@ func (kdb *KeypairDatabase) ImportSigningKey(authorityID, base64PrivateKey strin
 	}
 }
 
+// CheckPolicy checks that the usage policy of the keypair allows an assertion to be signed,
+// without signing it. A rejected assertion is recorded in the signing log
+func (kdb *KeypairDatabase) CheckPolicy(assertType *asserts.AssertionType, headers map[string]interface{}, authorityID string, keyID string) error {
+	return checkKeypairPolicy(assertType, headers, authorityID, keyID)
+}
+
 // SignAssertion signs an assertion using the signing-key from the keypair store
+// The usage policy of the keypair is checked before anything is signed.
 func (kdb *KeypairDatabase) SignAssertion(assertType *asserts.AssertionType, headers map[string]interface{}, body []byte, authorityID string, keyID string, sealedSigningKey string) (asserts.Assertion, error) {
+	if err := checkKeypairPolicy(assertType, headers, authorityID, keyID); err != nil {
+		return nil, err
+	}
 
 	switch kdb.KeyStoreType.Name {
 
@@ -152,13 +183,21 @@ func (kdb *KeypairDatabase) SignAssertion(assertType *asserts.AssertionType, hea
 		fallthrough
 
 	case TPM20Store.Name:
+		fallthrough
+
+	case PKCS11Store.Name:
+		// Hold the unsealed key while signing, so it is not zeroed if it is evicted
+		if kdb.unsealedKeys != nil {
+			defer kdb.unsealedKeys.Acquire(keyID)()
+		}
+
 		// Use an internal operator to handle decryption of signing-keys from storage
 		err := kdb.keypairOperator.UnsealKeypair(authorityID, keyID, sealedSigningKey)
 		if err != nil {
 			return nil, err
 		}
 
-		// Sign the key using the unsealed key in the memory keypair store
+		// Sign the key using the unsealed key in the memory keypair store, or the PKCS#11 token
 		return kdb.Sign(assertType, headers, body, keyID)
 
 	default:
@@ -174,6 +213,9 @@ func (kdb *KeypairDatabase) LoadKeypair(authorityID string, keyID string, sealed
 		fallthrough
 
 	case TPM20Store.Name:
+		fallthrough
+
+	case PKCS11Store.Name:
 		// Use an internal operator to handle decryption of signing-keys from storage
 		err := kdb.keypairOperator.UnsealKeypair(authorityID, keyID, sealedSigningKey)
 		return err
@@ -0,0 +1,134 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package datastore
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/CanonicalLtd/serial-vault/crypt"
+	"github.com/CanonicalLtd/serial-vault/service/log"
+)
+
+// The services record the keystore secret that they use at this interval, and
+// an instance is considered to be running until it has not been seen for longer
+var (
+	keystoreInstanceInterval = 60 * time.Second
+	keystoreInstanceExpiry   = 3 * keystoreInstanceInterval
+)
+
+const createKeystoreInstanceTableSQL = `
+	CREATE TABLE IF NOT EXISTS keystoreinstance (
+		instance      varchar(200) primary key not null,
+		fingerprint   varchar(200) not null,
+		lastseen      bigint not null
+	)
+`
+
+const deleteKeystoreInstanceSQL = "DELETE FROM keystoreinstance WHERE instance=$1"
+const createKeystoreInstanceSQL = "INSERT INTO keystoreinstance (instance, fingerprint, lastseen) VALUES ($1, $2, $3)"
+const listKeystoreInstancesSQL = "SELECT instance, fingerprint, lastseen FROM keystoreinstance WHERE lastseen>=$1 ORDER BY instance"
+
+// KeystoreInstance is a running service that uses the keystore secret. The secret is
+// identified by its fingerprint
+type KeystoreInstance struct {
+	Instance    string
+	Fingerprint string
+	LastSeen    time.Time
+}
+
+// CreateKeystoreInstanceTable creates the database table for the keystore instances
+func (db *DB) CreateKeystoreInstanceTable() error {
+	_, err := db.Exec(createKeystoreInstanceTableSQL)
+	return err
+}
+
+// PutKeystoreInstance records that a service instance is using the keystore secret
+func (db *DB) PutKeystoreInstance(instance KeystoreInstance) error {
+	err := db.transaction(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(deleteKeystoreInstanceSQL, instance.Instance); err != nil {
+			return err
+		}
+		_, err := tx.Exec(createKeystoreInstanceSQL, instance.Instance, instance.Fingerprint, instance.LastSeen.Unix())
+		return err
+	})
+	if err != nil {
+		log.Printf("Error recording the keystore instance: %v\n", err)
+	}
+	return err
+}
+
+// ListKeystoreInstances returns the service instances that have been seen since the time
+func (db *DB) ListKeystoreInstances(since time.Time) ([]KeystoreInstance, error) {
+	rows, err := db.Query(listKeystoreInstancesSQL, since.Unix())
+	if err != nil {
+		log.Printf("Error retrieving the keystore instances: %v\n", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	instances := []KeystoreInstance{}
+	for rows.Next() {
+		var lastSeen int64
+		instance := KeystoreInstance{}
+		if err := rows.Scan(&instance.Instance, &instance.Fingerprint, &lastSeen); err != nil {
+			return nil, err
+		}
+		instance.LastSeen = time.Unix(lastSeen, 0)
+		instances = append(instances, instance)
+	}
+	return instances, rows.Err()
+}
+
+// RecordKeystoreInstance records the fingerprint of the keystore secret of the service at
+// regular intervals, until the done channel is closed. This allows the secret rotation to
+// check that no service is still using the old secret
+func RecordKeystoreInstance(mode string, done <-chan struct{}) {
+	if Environ.Config.KeyStoreType != DatabaseStore.Name && Environ.Config.KeyStoreType != TPM20Store.Name {
+		return
+	}
+
+	if len(mode) == 0 {
+		mode = "signing"
+	}
+	hostname, _ := os.Hostname()
+	instance := KeystoreInstance{
+		Instance:    fmt.Sprintf("%s/%s/%d", hostname, mode, os.Getpid()),
+		Fingerprint: crypt.SecretFingerprint(Environ.Config.KeyStoreSecret),
+	}
+
+	ticker := time.NewTicker(keystoreInstanceInterval)
+	defer ticker.Stop()
+
+	for {
+		// Errors are logged, and the instance is recorded again at the next interval
+		instance.LastSeen = time.Now()
+		Environ.DB.PutKeystoreInstance(instance)
+
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,218 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package datastore
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/CanonicalLtd/serial-vault/crypt"
+)
+
+// Common error messages
+var (
+	ErrorKeystoreSecretEmpty     = errors.New("The new keystore secret cannot be empty")
+	ErrorKeystoreSecretUnchanged = errors.New("The new keystore secret is the same as the current one")
+)
+
+// KeystoreSecretInUseError is returned when services are using the keystore secret that is to be replaced
+type KeystoreSecretInUseError struct {
+	Instances []KeystoreInstance
+}
+
+func (e KeystoreSecretInUseError) Error() string {
+	names := []string{}
+	for _, i := range e.Instances {
+		names = append(names, i.Instance)
+	}
+	return fmt.Sprintf("The keystore secret is in use by the services: %s. Stop the services, or wait %s after they have stopped",
+		strings.Join(names, ", "), keystoreInstanceExpiry)
+}
+
+// RotateKeystoreSecret re-seals the auth-keys and signing-keys of all the keypairs, so they use the new
+// keystore secret in place of the configured one. The signing-keys of the imports that are waiting for
+// approval are re-sealed too. The keypairs and imports are updated in a single transaction, which is only
+// committed if every signing-key unseals with the new secret. The rotation is refused while services are
+// using the configured secret. A dry-run re-seals and checks the signing-keys without storing them
+func RotateKeystoreSecret(newSecret string, dryRun bool) ([]SealedKeyMigration, error) {
+	if Environ.Config.KeyStoreType != DatabaseStore.Name && Environ.Config.KeyStoreType != TPM20Store.Name {
+		return nil, ErrorKeystoreNotSealed
+	}
+	if len(newSecret) == 0 {
+		return nil, ErrorKeystoreSecretEmpty
+	}
+	if newSecret == Environ.Config.KeyStoreSecret {
+		return nil, ErrorKeystoreSecretUnchanged
+	}
+
+	if err := checkKeystoreSecretUnused(Environ.Config.KeyStoreSecret); err != nil {
+		return nil, err
+	}
+
+	keypairs, err := Environ.DB.ListAllowedKeypairs(User{})
+	if err != nil {
+		return nil, err
+	}
+
+	// Re-seal all the keypairs in memory, so nothing is stored unless they all succeed
+	migrations := []SealedKeyMigration{}
+	resealed := []SyncKeypair{}
+	failed := false
+	for _, k := range keypairs {
+		// The keypair list does not include the sealed key
+		keypair, err := Environ.DB.GetKeypair(k.ID)
+		if err != nil {
+			return migrations, err
+		}
+		if len(keypair.SealedKey) == 0 {
+			migrations = append(migrations, SealedKeyMigration{Keypair: keypair})
+			continue
+		}
+
+		sealedKey, sealedAuthKey, err := ReEncryptKeypair(keypair, newSecret)
+		if err == nil {
+			keypair.SealedKey = sealedKey
+			err = verifySealedKeypair(SyncKeypair{keypair, sealedAuthKey}, newSecret)
+		}
+		failed = failed || err != nil
+		migrations = append(migrations, SealedKeyMigration{Keypair: keypair, Migrated: err == nil, Err: err})
+		resealed = append(resealed, SyncKeypair{keypair, sealedAuthKey})
+	}
+
+	// The imports that are waiting for approval hold their signing-key sealed with the keystore secret
+	operations, err := Environ.DB.ListAllowedPendingOperations(User{Role: Superuser})
+	if err != nil {
+		return migrations, err
+	}
+	imports := []PendingOperation{}
+	for _, op := range operations {
+		if op.Operation != OperationImport || (op.Status != OperationStatusPending && op.Status != OperationStatusApproved) {
+			continue
+		}
+
+		keypair := Keypair{AuthorityID: op.AuthorityID, KeyName: op.KeyName}
+		resealedOp, err := resealImportOperation(op, newSecret)
+		if err == nil {
+			err = verifyImportOperation(resealedOp, newSecret)
+		}
+		failed = failed || err != nil
+		migrations = append(migrations, SealedKeyMigration{Keypair: keypair, Migrated: err == nil, Err: err, OperationID: op.ID})
+		imports = append(imports, resealedOp)
+	}
+
+	if failed || dryRun || (len(resealed) == 0 && len(imports) == 0) {
+		return migrations, nil
+	}
+
+	err = Environ.DB.UpdateKeypairsSealedKeys(resealed, imports, func(k SyncKeypair) error {
+		return verifySealedKeypair(k, newSecret)
+	}, func(op PendingOperation) error {
+		return verifyImportOperation(op, newSecret)
+	})
+	return migrations, err
+}
+
+// checkKeystoreSecretUnused checks that no running service has recorded that it uses the secret
+func checkKeystoreSecretUnused(secret string) error {
+	instances, err := Environ.DB.ListKeystoreInstances(time.Now().Add(-keystoreInstanceExpiry))
+	if err != nil {
+		return err
+	}
+
+	fingerprint := crypt.SecretFingerprint(secret)
+	inUse := []KeystoreInstance{}
+	for _, i := range instances {
+		if i.Fingerprint == fingerprint {
+			inUse = append(inUse, i)
+		}
+	}
+	if len(inUse) > 0 {
+		return KeystoreSecretInUseError{inUse}
+	}
+	return nil
+}
+
+// verifySealedKeypair checks that the sealed keypair unseals to a valid signing-key with the secret
+func verifySealedKeypair(keypair SyncKeypair, secret string) error {
+	base64SigningKey, err := unsealSigningKey(keypair.AuthKeyHash, keypair.SealedKey, secret)
+	if err != nil {
+		return err
+	}
+
+	privateKey, _, err := crypt.DeserializePrivateKey(string(base64SigningKey))
+	if err != nil {
+		return err
+	}
+	if privateKey.PublicKey().ID() != keypair.KeyID {
+		return errors.New("The unsealed signing-key does not match the key ID")
+	}
+	return nil
+}
+
+// resealImportOperation re-seals the signing-key of a pending import with the new secret
+func resealImportOperation(op PendingOperation, newSecret string) (PendingOperation, error) {
+	base64PrivateKey, err := unsealImportOperation(op, Environ.Config.KeyStoreSecret)
+	if err != nil {
+		return op, err
+	}
+
+	data := importOperationData{}
+	if err := json.Unmarshal([]byte(op.Data), &data); err != nil {
+		return op, err
+	}
+	sealedKey, err := crypt.SealKey([]byte(base64PrivateKey), newSecret)
+	if err != nil {
+		return op, err
+	}
+	data.SealedKey = base64.StdEncoding.EncodeToString(sealedKey)
+
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return op, err
+	}
+	op.Data = string(encoded)
+	return op, nil
+}
+
+// verifyImportOperation checks that the pending import unseals to its signing-key with the secret
+func verifyImportOperation(op PendingOperation, secret string) error {
+	base64PrivateKey, err := unsealImportOperation(op, secret)
+	if err != nil {
+		return err
+	}
+
+	data := importOperationData{}
+	if err := json.Unmarshal([]byte(op.Data), &data); err != nil {
+		return err
+	}
+	privateKey, _, err := crypt.DeserializePrivateKey(base64PrivateKey)
+	if err != nil {
+		return err
+	}
+	if privateKey.PublicKey().ID() != data.KeyID {
+		return errors.New("The unsealed signing-key does not match the key ID")
+	}
+	return nil
+}
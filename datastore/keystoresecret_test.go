@@ -0,0 +1,362 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package datastore
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/CanonicalLtd/serial-vault/config"
+	"github.com/CanonicalLtd/serial-vault/crypt"
+)
+
+const (
+	oldKeystoreSecret = "this needs to be something secure"
+	newKeystoreSecret = "this is the new keystore secret"
+)
+
+// rotationMockDB stores the re-sealed keypairs and imports only when they all verify, like the transaction
+type rotationMockDB struct {
+	sealedKeyMockDB
+	instances  []KeystoreInstance
+	operations []PendingOperation
+}
+
+func (mdb *rotationMockDB) UpdateKeypairsSealedKeys(keypairs []SyncKeypair, imports []PendingOperation, verify func(SyncKeypair) error, verifyImport func(PendingOperation) error) error {
+	for _, k := range keypairs {
+		if err := verify(k); err != nil {
+			return err
+		}
+	}
+	for _, op := range imports {
+		if err := verifyImport(op); err != nil {
+			return err
+		}
+	}
+	for _, k := range keypairs {
+		code := crypt.GenerateAuthKey(k.AuthorityID, k.KeyID)
+		mdb.UpdateKeypairSealedKey(k.Keypair, Setting{Code: code, Data: k.AuthKeyHash})
+	}
+	for _, op := range imports {
+		for i := range mdb.operations {
+			if mdb.operations[i].ID == op.ID {
+				mdb.operations[i].Data = op.Data
+			}
+		}
+	}
+	return nil
+}
+
+func (mdb *rotationMockDB) ListAllowedPendingOperations(authorization User) ([]PendingOperation, error) {
+	return mdb.operations, nil
+}
+
+func (mdb *rotationMockDB) ListKeystoreInstances(since time.Time) ([]KeystoreInstance, error) {
+	return mdb.instances, nil
+}
+
+func testKeyID(t *testing.T) string {
+	signingKey, err := ioutil.ReadFile("../keystore/TestKey.asc")
+	if err != nil {
+		t.Fatalf("Error reading the signing-key file: %v", err)
+	}
+	privateKey, _, err := crypt.DeserializePrivateKey(base64.StdEncoding.EncodeToString(signingKey))
+	if err != nil {
+		t.Fatalf("Error deserializing the signing-key: %v", err)
+	}
+	return privateKey.PublicKey().ID()
+}
+
+func setUpRotation(t *testing.T) *rotationMockDB {
+	mdb := &rotationMockDB{sealedKeyMockDB: sealedKeyMockDB{settings: map[string]Setting{}}}
+	Environ = &Env{Config: config.Settings{KeyStoreType: "database", KeyStoreSecret: oldKeystoreSecret}, DB: mdb}
+
+	legacySealedKeypair(t, &mdb.sealedKeyMockDB, 1, testKeyID(t), oldKeystoreSecret)
+	mdb.keypairs = append(mdb.keypairs, Keypair{ID: 2, AuthorityID: "System", KeyID: "filesystem"})
+	return mdb
+}
+
+func TestRotateKeystoreSecret(t *testing.T) {
+	mdb := setUpRotation(t)
+	keyID := mdb.keypairs[0].KeyID
+
+	// The dry-run checks the keypairs, without storing them
+	migrations, err := RotateKeystoreSecret(newKeystoreSecret, true)
+	if err != nil {
+		t.Fatalf("Error in the dry-run: %v", err)
+	}
+	if len(migrations) != 2 || !migrations[0].Migrated || migrations[1].Migrated || mdb.updates != 0 {
+		t.Errorf("Unexpected dry-run: %v, %d updates", migrations, mdb.updates)
+	}
+
+	migrations, err = RotateKeystoreSecret(newKeystoreSecret, false)
+	if err != nil {
+		t.Fatalf("Error rotating the secret: %v", err)
+	}
+	if !migrations[0].Migrated || migrations[0].Err != nil || mdb.updates != 1 {
+		t.Errorf("Expected the keypair to be re-sealed: %v, %d updates", migrations[0].Err, mdb.updates)
+	}
+
+	// The keypair unseals with the new secret, and not the old one
+	if _, err = decryptKeypair("System", keyID, mdb.keypairs[0].SealedKey); err == nil {
+		t.Error("Expected the keypair not to unseal with the old secret")
+	}
+	Environ.Config.KeyStoreSecret = newKeystoreSecret
+	if _, err = decryptKeypair("System", keyID, mdb.keypairs[0].SealedKey); err != nil {
+		t.Errorf("Error unsealing with the new secret: %v", err)
+	}
+}
+
+func TestRotateKeystoreSecretImports(t *testing.T) {
+	mdb := setUpRotation(t)
+
+	signingKey, err := ioutil.ReadFile("../keystore/TestKey.asc")
+	if err != nil {
+		t.Fatalf("Error reading the signing-key file: %v", err)
+	}
+	op, err := ImportOperation("System", "imported", base64.StdEncoding.EncodeToString(signingKey))
+	if err != nil {
+		t.Fatalf("Error creating the import operation: %v", err)
+	}
+	op.ID, op.Status = 5, OperationStatusPending
+	done := op
+	done.ID, done.Status = 6, OperationStatusDone
+	mdb.operations = []PendingOperation{op, done}
+
+	migrations, err := RotateKeystoreSecret(newKeystoreSecret, false)
+	if err != nil {
+		t.Fatalf("Error rotating the secret: %v", err)
+	}
+	if len(migrations) != 3 || migrations[2].OperationID != 5 || !migrations[2].Migrated {
+		t.Fatalf("Expected the pending import to be re-sealed: %v", migrations)
+	}
+
+	// The open import unseals with the new secret, and the completed one is left alone
+	if _, err := unsealImportOperation(mdb.operations[0], oldKeystoreSecret); err == nil {
+		t.Error("Expected the pending import not to unseal with the old secret")
+	}
+	if err := verifyImportOperation(mdb.operations[0], newKeystoreSecret); err != nil {
+		t.Errorf("Error unsealing the pending import with the new secret: %v", err)
+	}
+	if mdb.operations[1].Data != done.Data {
+		t.Error("The completed import was re-sealed")
+	}
+}
+
+func TestRotateKeystoreSecretImportFailure(t *testing.T) {
+	mdb := setUpRotation(t)
+	mdb.operations = []PendingOperation{{ID: 5, Operation: OperationImport, AuthorityID: "System", KeyName: "broken", Status: OperationStatusApproved, Data: `{"key-id":"broken","sealed-key":"c2VhbGVk"}`}}
+
+	migrations, err := RotateKeystoreSecret(newKeystoreSecret, false)
+	if err != nil {
+		t.Fatalf("Error rotating the secret: %v", err)
+	}
+	if migrations[2].Err == nil || mdb.updates != 0 {
+		t.Errorf("Expected no keypairs to be stored when an import fails: %d updates", mdb.updates)
+	}
+}
+
+func TestRotateKeystoreSecretFailure(t *testing.T) {
+	mdb := setUpRotation(t)
+	mdb.keypairs = append(mdb.keypairs, Keypair{ID: 3, AuthorityID: "System", KeyID: "no-auth-key", KeyName: "broken", SealedKey: "c2VhbGVk"})
+
+	migrations, err := RotateKeystoreSecret(newKeystoreSecret, false)
+	if err != nil {
+		t.Fatalf("Error rotating the secret: %v", err)
+	}
+	if migrations[2].Err == nil || mdb.updates != 0 {
+		t.Errorf("Expected no keypairs to be stored when one fails: %d updates", mdb.updates)
+	}
+}
+
+func TestRotateKeystoreSecretInUse(t *testing.T) {
+	mdb := setUpRotation(t)
+
+	mdb.instances = []KeystoreInstance{
+		{Instance: "vault1/signing/100", Fingerprint: crypt.SecretFingerprint(newKeystoreSecret)},
+		{Instance: "vault2/signing/200", Fingerprint: crypt.SecretFingerprint(oldKeystoreSecret)},
+	}
+	_, err := RotateKeystoreSecret(newKeystoreSecret, true)
+	inUse, ok := err.(KeystoreSecretInUseError)
+	if !ok {
+		t.Fatalf("Expected the secret in use error, got: %v", err)
+	}
+	if len(inUse.Instances) != 1 || inUse.Instances[0].Instance != "vault2/signing/200" {
+		t.Errorf("Unexpected instances using the secret: %v", inUse.Instances)
+	}
+
+	// Services that already use the new secret do not block the rotation
+	mdb.instances = mdb.instances[:1]
+	if _, err = RotateKeystoreSecret(newKeystoreSecret, true); err != nil {
+		t.Errorf("Error rotating the secret: %v", err)
+	}
+}
+
+func TestRotateKeystoreSecretInvalid(t *testing.T) {
+	setUpRotation(t)
+
+	tests := []struct {
+		keystore  string
+		newSecret string
+		err       error
+	}{
+		{"database", "", ErrorKeystoreSecretEmpty},
+		{"database", oldKeystoreSecret, ErrorKeystoreSecretUnchanged},
+		{"filesystem", newKeystoreSecret, ErrorKeystoreNotSealed},
+		{"pkcs11", newKeystoreSecret, ErrorKeystoreNotSealed},
+	}
+
+	for _, tt := range tests {
+		Environ.Config.KeyStoreType = tt.keystore
+		if _, err := RotateKeystoreSecret(tt.newSecret, true); err != tt.err {
+			t.Errorf("Expected error %v, got: %v", tt.err, err)
+		}
+	}
+}
+
+func openKeystoreTestDatabase(t *testing.T) *DB {
+	dir, err := os.MkdirTemp("", "keystore")
+	if err != nil {
+		t.Fatalf("Error creating the database directory: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	sqlDB, err := sql.Open("sqlite3", filepath.Join(dir, "keystore.db"))
+	if err != nil {
+		t.Fatalf("Error opening the database: %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	db := &DB{sqlDB}
+	for _, create := range []func() error{db.CreateKeypairTable, db.CreateSettingsTable, db.CreateKeystoreInstanceTable, db.CreatePendingOperationTable} {
+		if err := create(); err != nil {
+			t.Fatalf("Error creating the tables: %v", err)
+		}
+	}
+	return db
+}
+
+func TestUpdateKeypairsSealedKeys(t *testing.T) {
+	db := openKeystoreTestDatabase(t)
+
+	for _, stmt := range []string{
+		"INSERT INTO keypair (id, authority_id, key_id, sealed_key, key_name) VALUES (1, 'System', 'key1', 'old1', 'one')",
+		"INSERT INTO keypair (id, authority_id, key_id, sealed_key, key_name) VALUES (2, 'System', 'key2', 'old2', 'two')",
+		"INSERT INTO settings (id, code, data) VALUES (1, 'System/key1', 'auth1')",
+		"INSERT INTO settings (id, code, data) VALUES (2, 'System/key2', 'auth2')",
+		"INSERT INTO pendingoperation (id, operation, authority_id, key_name, data, status) VALUES (1, 'import', 'System', 'three', 'old3', 'pending')",
+		"INSERT INTO pendingoperation (id, operation, authority_id, key_name, data, status) VALUES (2, 'import', 'System', 'four', 'old4', 'rejected')",
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("Error inserting the test data: %v", err)
+		}
+	}
+
+	keypairs := []SyncKeypair{
+		{Keypair{ID: 1, AuthorityID: "System", KeyID: "key1", SealedKey: "new1"}, "newauth1"},
+		{Keypair{ID: 2, AuthorityID: "System", KeyID: "key2", SealedKey: "new2"}, "newauth2"},
+	}
+	imports := []PendingOperation{{ID: 1, AuthorityID: "System", KeyName: "three", Data: "new3"}}
+	verifyImport := func(op PendingOperation) error { return nil }
+
+	// A failed verification rolls back all the keypairs
+	verified := []SyncKeypair{}
+	err := db.UpdateKeypairsSealedKeys(keypairs, imports, func(k SyncKeypair) error {
+		verified = append(verified, k)
+		if k.ID == 2 {
+			return errors.New("MOCK does not unseal")
+		}
+		return nil
+	}, verifyImport)
+	if err == nil {
+		t.Fatal("Expected an error when a keypair does not verify")
+	}
+	if len(verified) != 2 || verified[0].SealedKey != "new1" || verified[0].AuthKeyHash != "newauth1" {
+		t.Errorf("Expected the stored keypairs to be verified: %v", verified)
+	}
+	keypair, _ := db.GetKeypair(1)
+	setting, _ := db.GetSetting("System/key1")
+	if keypair.SealedKey != "old1" || setting.Data != "auth1" {
+		t.Errorf("Expected the update to be rolled back: %s %s", keypair.SealedKey, setting.Data)
+	}
+
+	if op, _ := db.GetPendingOperation(1); op.Data != "old3" {
+		t.Errorf("Expected the import to be rolled back: %s", op.Data)
+	}
+
+	// A failed verification of an import rolls back the keypairs too
+	err = db.UpdateKeypairsSealedKeys(keypairs, imports, func(k SyncKeypair) error { return nil }, func(op PendingOperation) error {
+		return errors.New("MOCK does not unseal")
+	})
+	if err == nil {
+		t.Fatal("Expected an error when an import does not verify")
+	}
+	if keypair, _ = db.GetKeypair(1); keypair.SealedKey != "old1" {
+		t.Errorf("Expected the update to be rolled back: %s", keypair.SealedKey)
+	}
+
+	if err = db.UpdateKeypairsSealedKeys(keypairs, imports, func(k SyncKeypair) error { return nil }, verifyImport); err != nil {
+		t.Fatalf("Error updating the keypairs: %v", err)
+	}
+	keypair, _ = db.GetKeypair(2)
+	setting, _ = db.GetSetting("System/key2")
+	if keypair.SealedKey != "new2" || setting.Data != "newauth2" {
+		t.Errorf("Expected the keypair to be updated: %s %s", keypair.SealedKey, setting.Data)
+	}
+	if op, _ := db.GetPendingOperation(1); op.Data != "new3" {
+		t.Errorf("Expected the import to be updated: %s", op.Data)
+	}
+
+	// A keypair without an auth-key setting cannot be re-sealed
+	missing := []SyncKeypair{{Keypair{ID: 1, AuthorityID: "System", KeyID: "unknown"}, "newauth"}}
+	if err = db.UpdateKeypairsSealedKeys(missing, nil, func(k SyncKeypair) error { return nil }, verifyImport); err == nil {
+		t.Error("Expected an error for a missing auth-key setting")
+	}
+
+	// An import that has been decided cannot be re-sealed
+	decided := []PendingOperation{{ID: 2, AuthorityID: "System", KeyName: "four", Data: "new4"}}
+	if err = db.UpdateKeypairsSealedKeys(nil, decided, func(k SyncKeypair) error { return nil }, verifyImport); err == nil {
+		t.Error("Expected an error for an import that is no longer open")
+	}
+}
+
+func TestKeystoreInstances(t *testing.T) {
+	db := openKeystoreTestDatabase(t)
+
+	now := time.Now()
+	db.PutKeystoreInstance(KeystoreInstance{Instance: "vault/signing/1", Fingerprint: "old", LastSeen: now.Add(-time.Hour)})
+	db.PutKeystoreInstance(KeystoreInstance{Instance: "vault/admin/2", Fingerprint: "old", LastSeen: now.Add(-time.Hour)})
+	db.PutKeystoreInstance(KeystoreInstance{Instance: "vault/signing/1", Fingerprint: "new", LastSeen: now})
+
+	instances, err := db.ListKeystoreInstances(now.Add(-time.Minute))
+	if err != nil {
+		t.Fatalf("Error listing the instances: %v", err)
+	}
+	if len(instances) != 1 || instances[0].Instance != "vault/signing/1" || instances[0].Fingerprint != "new" {
+		t.Errorf("Unexpected instances: %v", instances)
+	}
+}
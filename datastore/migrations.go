@@ -0,0 +1,154 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package datastore
+
+// migrations are the changes to the database schema, in the order of their versions.
+// New migrations are added to the end of the list: a migration that has been released
+// must not be changed, as the databases that have applied it will not run it again
+var migrations = []Migration{
+	{
+		Version:     1,
+		Description: "Baseline schema",
+		apply:       baselineSchema,
+	},
+	{
+		Version:     2,
+		Description: "Keystore instance table",
+		up: map[string][]string{
+			postgresDriver: {createKeystoreInstanceTableSQL},
+			sqliteDriver:   {createKeystoreInstanceTableSQL},
+		},
+		down: map[string][]string{
+			postgresDriver: {"DROP TABLE IF EXISTS keystoreinstance"},
+			sqliteDriver:   {"DROP TABLE IF EXISTS keystoreinstance"},
+		},
+	},
+	{
+		Version:     3,
+		Description: "Pending keypair operation table",
+		up: map[string][]string{
+			postgresDriver: {createPendingOperationTableSQL, createPendingOperationStatusIndexSQL},
+			sqliteDriver:   {createPendingOperationTableSQL, createPendingOperationStatusIndexSQL},
+		},
+		down: map[string][]string{
+			postgresDriver: {"DROP TABLE IF EXISTS pendingoperation"},
+			sqliteDriver:   {"DROP TABLE IF EXISTS pendingoperation"},
+		},
+	},
+	{
+		Version:     4,
+		Description: "Signing log hash chain",
+		up: map[string][]string{
+			postgresDriver: {alterSigningLogAddChainSeqSQL, alterSigningLogAddHashSQL, createSigningLogChainIndexSQL, createSigningLogChainTableSQL},
+			sqliteDriver:   {alterSigningLogAddChainSeqSQL, alterSigningLogAddHashSQL, createSigningLogChainIndexSQL, createSigningLogChainTableSQL},
+		},
+		data: chainExistingSigningLogs,
+		down: map[string][]string{
+			postgresDriver: {
+				"DROP INDEX IF EXISTS chain_idx",
+				"ALTER TABLE signinglog DROP COLUMN IF EXISTS chain_seq",
+				"ALTER TABLE signinglog DROP COLUMN IF EXISTS hash",
+				"DROP TABLE IF EXISTS signinglogchain",
+			},
+			sqliteDriver: dropSigningLogChainSQLite,
+		},
+	},
+	{
+		Version:     5,
+		Description: "Audit log table",
+		up: map[string][]string{
+			postgresDriver: {createAuditLogTableSQL, createAuditLogCreatedIndexSQL, createAuditLogEntityIndexSQL},
+			sqliteDriver:   {createAuditLogTableSQL, createAuditLogCreatedIndexSQL, createAuditLogEntityIndexSQL},
+		},
+		down: map[string][]string{
+			postgresDriver: {"DROP TABLE IF EXISTS audit_log"},
+			sqliteDriver:   {"DROP TABLE IF EXISTS audit_log"},
+		},
+	},
+	{
+		Version:     6,
+		Description: "Serial number rule IDs for SQLite",
+		up: map[string][]string{
+			postgresDriver: {},
+			sqliteDriver:   rebuildSerialRuleTableSQLite,
+		},
+		// The rebuilt table has the same columns, so it is kept
+		down: map[string][]string{
+			postgresDriver: {},
+			sqliteDriver:   {},
+		},
+	},
+	{
+		Version:     7,
+		Description: "Signing quota table",
+		up: map[string][]string{
+			postgresDriver: {createSigningQuotaTableSQL},
+			sqliteDriver:   {createSigningQuotaTableSQL},
+		},
+		down: map[string][]string{
+			postgresDriver: {"DROP TABLE IF EXISTS signingquota"},
+			sqliteDriver:   {"DROP TABLE IF EXISTS signingquota"},
+		},
+	},
+}
+
+// baselineSchema creates the tables of the schema from before the versioned migrations,
+// and brings the tables of an existing database up to it. Each step can be run again, so
+// it adopts a database at any earlier schema. It cannot be rolled back
+func baselineSchema(db *DB) error {
+	steps := []struct {
+		method     func() error
+		skipSqlite bool
+	}{
+		{db.CreateKeypairTable, false},
+		{db.CreateModelTable, false},
+		{db.CreateSettingsTable, false},
+		{db.CreateSigningLogTable, false},
+		{db.CreateDeviceNonceTable, false},
+		{db.CreateAccountTable, false},
+		{db.AlterAccountTable, false},
+		{db.AlterModelTable, false},
+		{db.AlterKeypairTable, false},
+		{db.CreateOpenidNonceTable, false},
+		{db.CreateUserTable, false},
+		{db.CreateAccountUserLinkTable, true},
+		{db.AlterUserTable, true},
+		{db.CreateKeypairStatusTable, false},
+		{db.AlterKeypairStatusTable, false},
+		{db.CreateModelAssertTable, false},
+		{db.AlterModelAssertTable, false},
+		{db.CreateSubstoreTable, false},
+		{db.CreateTestLogTable, false},
+		{db.CreateSerialRuleTable, false},
+		{db.CreateSerialAllocationTable, false},
+		{db.CreateDeviceKeyRevocationTable, false},
+	}
+
+	for _, s := range steps {
+		if s.skipSqlite && InFactory() {
+			continue
+		}
+		if err := s.method(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
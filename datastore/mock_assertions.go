@@ -0,0 +1,112 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016-2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package datastore
+
+// mockAccountKeyAssertions are the cached account-key assertions for the test signing-key, by account
+var mockAccountKeyAssertions = map[string]string{
+	"system":  mockAccountKeySystem,
+	"mybrand": mockAccountKeyMybrand,
+}
+
+// mockAccountSystem is the cached account assertion of the test brand
+const mockAccountSystem = `type: account
+authority-id: canonical
+account-id: system
+display-name: System
+timestamp: 2016-01-01T00:00:00Z
+username: system
+validation: unproven
+sign-key-sha3-384: UytTqTvREVhx0tSfYC6KkFHmLWllIIZbQ3NsEG7OARrWuaXSRJyey0vjIQkTEvMO
+
+AcLBUgQAAQoABgUCatQntgAA7wEQAKDnGxk8l/MHWwJnUkQGJYZHla7YUlCsnVkMCsHR0vE2CSbm
+uRGhMBT9tEYF1S3AsWLJ6cAuT4piPuWCGLWV3zAgeBOg9jaC45DsEFlx4Py0WjYnqi1bRaEX6uOo
+R5tfcffExM7gT6zH1Ag8fKNGsuwLv69DmtDaKoAlIct1mj2EYbXh74ClKLbMzbNx7ZhYYJNQeFsV
+EY6i3fHGKTR/yq1fwOQP7toiuMepkLFp0h9blV5zglZJx5je1InjJGwnZBO3roK/cEhtgzij2dKH
+fwmTTYtMdDsMXaj7c53f7WzoNs1MbeEWpW1y5dpSDJf88bkae4zgcW8Y1d8sWh5YMF3G3LLU6YTn
+ZFpvvlietgaR5V+7HaYa66OQBQPnxfZy8x2iw9bQsAeGEzWlFvMR+M6966J4CuRo6ZipNTgThTkC
+K5f1kfk8WgPzGYdE25ogYACs+5lsV4Sz1e75hFTysfv43D0ecZAQQtofP9dC41146Fum47kmiZyM
+lIwvSVyuRXU1fmBA4cXgW3R7yRCtbvTvWd93gJiTftbOgepg13UxVAjcOSIuXADxbm2uPP0OvbdA
+yxKu8R0jJrdvLmxhoeVWIwDHHvvztiBppK57oqVx2gO51GVeSbvdH6LQmO530bTcyxu+WlVKbhql
+i/FzwrTYM9qAXASRrgkUNK2qEm9O
+`
+
+const mockAccountKeySystem = `type: account-key
+authority-id: canonical
+public-key-sha3-384: UytTqTvREVhx0tSfYC6KkFHmLWllIIZbQ3NsEG7OARrWuaXSRJyey0vjIQkTEvMO
+account-id: system
+name: default
+since: 2016-01-01T00:00:00Z
+body-length: 717
+sign-key-sha3-384: UytTqTvREVhx0tSfYC6KkFHmLWllIIZbQ3NsEG7OARrWuaXSRJyey0vjIQkTEvMO
+
+AcbBTQRWhcGAARAAx6VJoV9ZKASKa1pFA0G6hQimQT7ym8EZFN7+SzZhWSWLIwFd06oRQVKetQB6
+a+ab0zMN3yfI94aB9aH/q6vA7T7Yo1KaBFy4aaztUvDmMzEGaVwJvDSBUBFr4yUCJEtLXAw5fMkS
+DGvNUFRacLifAfGU5mLHJl7WXY2e7T+VjJPoSU3nAZjvGd2YQnQ1fNfQ0X+zuQVDGrtmJJF3x0CM
+8LL0XF4UCTBYyLZK2YvSKrrk2qmIUVr3PXoY+fH9Bs5AZAAZ91GIrt0qc0uradXxI6kq8zy8bVl8
+GTazEmkBE9Y7snAqWJWGXt9K4tO7h+4Xgprvf27dddp68XS2KHT3r86qC/1i9mTGMbHWJ5NKd/No
+Jnawjc1qo2tnVVyw+GKwMhukpvmtuejhtk395dNczGZ2sw2yPHORUHUyq/sPLoAWyWLQFHL3MxQq
+qyxgxWNnRYhcs6wmWEf2nNFlllld6YzS7It+cA+I04j5h85DGO6+knn1J7X4WuORDx3nn3bEQKik
+v4uu1xFJYk6N14B/ofMoUCzbPtgkNpmV0NmgFeogx+I5yRuF0EF5U+LfMuAE+ROoYHHwiBHeSttr
+YewdunntDyeRUc3CTwsvfq2zARObr5He5z4ldSASuzxbzEEXVd6UERPN+zeJGyctKIYEqvpSNNuu
+4Fs8Ctp6yar9KucAEQEAAQ==
+
+AcLBUgQAAQoABgUCatQbjQAAO0wQAJeuLhVmBBnaGoaqBpDxpPlmgtfb2+8f10ehh68idwsufHAM
+9DAJOcaM48TIE+fMlcVvdY48q4XCp54ykLICCJ2pxFyHNlYfvusIrjYA5zirg95QwZSADXkiNJnO
+ITjueBwUyjVHHUwzVa06uHMH3IjN/1nPNX+zr8NcQtvikX4iOKwNs6Y0OqmLUMciDVZ/A7bXLaWf
+Zjjl1AoNY424VM8lnZOzUr70vSqCS2mIMexwKfGAYhPKLlsQJsm0IX+2lkwlNeQeTJ4R+qeyr64p
+R+Ntzw0uAt5JvyeTnsgicwmlZVqo353gUxKYVax7qU2iuCQ+cGyFvDrKbzw31QZV9Xm42fcgR3nl
+s5W5s80+fNNELK0VO0UA/hnfjwcL2gXaWW2uo7/o2b86YseR6Xc9w5x65V3pZpyvkjEGWurUxtmT
+c+Za+oFb/Qxlj4y+kILwhrcA8dIGNYEltIzR6RZo4s7gidIBBsHmVirlSC8USNnWG9U7bjRuWodA
+5BU71GlFmjV5ea5lISvx8Hut+6I6WPEpINJKY8/H945QXyUFAwVo+dDGszC2IxIKbWIcbMQanGST
+P2G/6+aeoX8cy3T5NFBsJJ4tpLLXtcCuqCVPQsvaELr76xNR2oFEJUqIo9Aydm3tA2GMi61O/JT8
+uNDp2fzbLYf4Hut6SIsiMrNGlMVC
+`
+
+const mockAccountKeyMybrand = `type: account-key
+authority-id: canonical
+public-key-sha3-384: UytTqTvREVhx0tSfYC6KkFHmLWllIIZbQ3NsEG7OARrWuaXSRJyey0vjIQkTEvMO
+account-id: mybrand
+name: default
+since: 2016-01-01T00:00:00Z
+body-length: 717
+sign-key-sha3-384: UytTqTvREVhx0tSfYC6KkFHmLWllIIZbQ3NsEG7OARrWuaXSRJyey0vjIQkTEvMO
+
+AcbBTQRWhcGAARAAx6VJoV9ZKASKa1pFA0G6hQimQT7ym8EZFN7+SzZhWSWLIwFd06oRQVKetQB6
+a+ab0zMN3yfI94aB9aH/q6vA7T7Yo1KaBFy4aaztUvDmMzEGaVwJvDSBUBFr4yUCJEtLXAw5fMkS
+DGvNUFRacLifAfGU5mLHJl7WXY2e7T+VjJPoSU3nAZjvGd2YQnQ1fNfQ0X+zuQVDGrtmJJF3x0CM
+8LL0XF4UCTBYyLZK2YvSKrrk2qmIUVr3PXoY+fH9Bs5AZAAZ91GIrt0qc0uradXxI6kq8zy8bVl8
+GTazEmkBE9Y7snAqWJWGXt9K4tO7h+4Xgprvf27dddp68XS2KHT3r86qC/1i9mTGMbHWJ5NKd/No
+Jnawjc1qo2tnVVyw+GKwMhukpvmtuejhtk395dNczGZ2sw2yPHORUHUyq/sPLoAWyWLQFHL3MxQq
+qyxgxWNnRYhcs6wmWEf2nNFlllld6YzS7It+cA+I04j5h85DGO6+knn1J7X4WuORDx3nn3bEQKik
+v4uu1xFJYk6N14B/ofMoUCzbPtgkNpmV0NmgFeogx+I5yRuF0EF5U+LfMuAE+ROoYHHwiBHeSttr
+YewdunntDyeRUc3CTwsvfq2zARObr5He5z4ldSASuzxbzEEXVd6UERPN+zeJGyctKIYEqvpSNNuu
+4Fs8Ctp6yar9KucAEQEAAQ==
+
+AcLBUgQAAQoABgUCatQbjQAANPsQAFqdmT21UUPnyySql1ZooKq2wqgrxEOtjWhRFdZYYPBODK6X
+hy23+XfPnOANWQxvpK1oWCkvgEEydNV5sLDyN+92Mdv2ZIGIBEv7qY+voPTCapEe7eqIFuUPkO9w
+3f1PcOxg+jJD0LX0aKH3oIQMCFHtwCS/qe2e9bVzfduYOPnrA8nqw8ovyRZN7zJj+Ms2aR/kmtmE
+QZzimEJUmSGUMD3dJZxOuhXZz2Re1X0EnfcXzivIMrB4zT27WHH0V41QgCnfdKdPeyL3N/ZfXMhS
+RMFXNg/HDQs8lmVTh7OYxAeWfnZ/yf+oI+EQtRrD+WM9v8f263UktYlekxU32b2Ke9r0/p74LDRk
+C4nsIqCcIserR7LmKjhoA9AvTZ0B81zxRKvNbXyhoLpCCpnr6Hxq1Vih7+I+P/XLz4Wd4+dntQiR
+6Bq5Dg93Rg2uykX/PTtMKF9w7Jd3+xnSSXvHB2KcuXG699Z1KlKDraGqoQpp/hiNgDs/j9lVP5HW
+Zl2vR/MICPNpW/+0gwXG3eFww7G03zJ+ne6LEpoiH15O+Ek3aGvIlvDOy5YoGCV1d7Ra6orYhxGy
+FrYyf0f8jz2IHj8lfQiQCwPSOA7K9NJReATPNUWtCV2aTeGlDGZ12hMrJdlLVugY7agoBk0NrKUA
+ZTymKAgaAEb/g90qF2smfzz/6QyX
+`
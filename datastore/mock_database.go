@@ -29,26 +29,12 @@ import (
 // MockDB holds the successful mocks for the database
 type MockDB struct {
 	encryptedAuthKeyHash string
-}
-
-// CreateModelTable mock for the create model table method
-func (mdb *MockDB) CreateModelTable() error {
-	return nil
-}
-
-// AlterModelTable mock for the alter model table method
-func (mdb *MockDB) AlterModelTable() error {
-	return nil
-}
 
-// CreateKeypairTable mock for the create keypair table method
-func (mdb *MockDB) CreateKeypairTable() error {
-	return nil
-}
+	// AuditLogs holds the audit logs that were created
+	AuditLogs []AuditLog
 
-// AlterKeypairTable mock for the alter keypair table method
-func (mdb *MockDB) AlterKeypairTable() error {
-	return nil
+	// signingQuotas holds the signing requests of each API key and day
+	signingQuotas map[string]int
 }
 
 // UpdateKeypairAssertion mock to update the account-key assertion of a keypair
@@ -56,21 +42,6 @@ func (mdb *MockDB) UpdateKeypairAssertion(keypair Keypair, authorization User) (
 	return "", nil
 }
 
-// CreateSettingsTable mock for the create settings table method
-func (mdb *MockDB) CreateSettingsTable() error {
-	return nil
-}
-
-// CreateAccountTable mock for the create account table method
-func (mdb *MockDB) CreateAccountTable() error {
-	return nil
-}
-
-// AlterAccountTable mock for the create account table method
-func (mdb *MockDB) AlterAccountTable() error {
-	return nil
-}
-
 // CreateAccount mock to create an account record
 func (mdb *MockDB) CreateAccount(account Account) error {
 	return nil
@@ -108,7 +79,7 @@ func (mdb *MockDB) GetAllowedAccount(authorityID string, authorization User) (Ac
 // ListAllowedAccounts mock to return a list of the available accounts
 func (mdb *MockDB) ListAllowedAccounts(authorization User) ([]Account, error) {
 	var accounts []Account
-	accounts = append(accounts, Account{ID: 1, AuthorityID: "system", Assertion: "assertion\n", ResellerAPI: true})
+	accounts = append(accounts, Account{ID: 1, AuthorityID: "system", Assertion: mockAccountSystem, ResellerAPI: true})
 	accounts = append(accounts, Account{ID: 2, AuthorityID: "vendor", Assertion: "assertion\n", ResellerAPI: false})
 	accounts = append(accounts, Account{ID: 3, AuthorityID: "generic", Assertion: "assertion\n", ResellerAPI: true})
 	return accounts, nil
@@ -169,6 +140,18 @@ func (mdb *MockDB) FindModel(brandID, modelName, apiKey string) (Model, error) {
 	if modelName == "inactive" {
 		model = Model{ID: 1, BrandID: "system", Name: "inactive", KeypairID: 1, AuthorityID: "system", KeyID: "UytTqTvREVhx0tSfYC6KkFHmLWllIIZbQ3NsEG7OARrWuaXSRJyey0vjIQkTEvMO", KeyActive: false, SealedKey: ""}
 	}
+	if modelName == "alder-reject" {
+		model = Model{ID: 3, BrandID: "system", Name: "alder-reject", KeypairID: 1, DuplicatePolicy: DuplicateReject, AuthorityID: "system", KeyID: "UytTqTvREVhx0tSfYC6KkFHmLWllIIZbQ3NsEG7OARrWuaXSRJyey0vjIQkTEvMO", KeyActive: true, SealedKey: ""}
+	}
+	if modelName == "alder-reject-devicekey" {
+		model = Model{ID: 4, BrandID: "system", Name: "alder-reject-devicekey", KeypairID: 1, DuplicatePolicy: DuplicateRejectDeviceKey, AuthorityID: "system", KeyID: "UytTqTvREVhx0tSfYC6KkFHmLWllIIZbQ3NsEG7OARrWuaXSRJyey0vjIQkTEvMO", KeyActive: true, SealedKey: ""}
+	}
+	if modelName == "alder-allocate" {
+		model = Model{ID: 5, BrandID: "system", Name: "alder-allocate", KeypairID: 1, AuthorityID: "system", KeyID: "UytTqTvREVhx0tSfYC6KkFHmLWllIIZbQ3NsEG7OARrWuaXSRJyey0vjIQkTEvMO", KeyActive: true, SealedKey: ""}
+	}
+	if modelName == "alder-allocate-error" {
+		model = Model{ID: 6, BrandID: "system", Name: "alder-allocate-error", KeypairID: 1, AuthorityID: "system", KeyID: "UytTqTvREVhx0tSfYC6KkFHmLWllIIZbQ3NsEG7OARrWuaXSRJyey0vjIQkTEvMO", KeyActive: true, SealedKey: ""}
+	}
 	if model.BrandID != brandID || model.Name != modelName || modelName == "invalid" {
 		return model, errors.New("Cannot find a model for that brand and model")
 	}
@@ -273,6 +256,11 @@ func (mdb *MockDB) SyncModel(model Model) error {
 	return nil
 }
 
+// RolloverModelKeypairs mocks switching the models to their next keypair
+func (mdb *MockDB) RolloverModelKeypairs(now time.Time) (int, error) {
+	return 0, nil
+}
+
 // GetKeypair mocks getting a keypair by ID
 func (mdb *MockDB) GetKeypair(keypairID int) (Keypair, error) {
 	keypair := keypairSystem()
@@ -282,12 +270,19 @@ func (mdb *MockDB) GetKeypair(keypairID int) (Keypair, error) {
 // GetKeypairByPublicID mocks getting a keypair by key ID
 func (mdb *MockDB) GetKeypairByPublicID(auth, keyID string) (Keypair, error) {
 	keypair := keypairSystem()
+
+	// Include the cached account-key assertion for the test signing-key
+	if assertion, ok := mockAccountKeyAssertions[auth]; ok && keyID == keypair.KeyID {
+		keypair.AuthorityID = auth
+		keypair.Assertion = assertion
+	}
 	return keypair, nil
 }
 
 // GetKeypairByName mocks getting a keypair by name
 func (mdb *MockDB) GetKeypairByName(authorityID, keyName string) (Keypair, error) {
 	keypair := keypairSystem()
+	keypair.KeyName = keyName
 	return keypair, nil
 }
 
@@ -323,6 +318,41 @@ func (mdb *MockDB) SyncKeypair(keypair SyncKeypair) error {
 	return nil
 }
 
+// UpdateKeypairSealedKey database mock
+func (mdb *MockDB) UpdateKeypairSealedKey(keypair Keypair, authKey Setting) error {
+	return nil
+}
+
+// UpdateKeypairValidity database mock
+func (mdb *MockDB) UpdateKeypairValidity(keypair Keypair) error {
+	return nil
+}
+
+// DeleteKeypair database mock
+func (mdb *MockDB) DeleteKeypair(keypairID int) error {
+	return nil
+}
+
+// UpdateKeypairPolicy database mock
+func (mdb *MockDB) UpdateKeypairPolicy(keypairID int, policy KeypairPolicy) error {
+	return nil
+}
+
+// UpdateKeypairsSealedKeys database mock
+func (mdb *MockDB) UpdateKeypairsSealedKeys(keypairs []SyncKeypair, imports []PendingOperation, verify func(SyncKeypair) error, verifyImport func(PendingOperation) error) error {
+	for _, k := range keypairs {
+		if err := verify(k); err != nil {
+			return err
+		}
+	}
+	for _, op := range imports {
+		if err := verifyImport(op); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // UpdateAllowedKeypairActive database mock
 func (mdb *MockDB) UpdateAllowedKeypairActive(keypairID int, active bool, authorization User) error {
 	return nil
@@ -357,20 +387,71 @@ func (mdb *MockDB) PutSetting(setting Setting) error {
 	return nil
 }
 
-// CreateSigningLogTable database mock
-func (mdb *MockDB) CreateSigningLogTable() error {
+// PutKeystoreInstance database mock
+func (mdb *MockDB) PutKeystoreInstance(instance KeystoreInstance) error {
+	return nil
+}
+
+// ListKeystoreInstances database mock
+func (mdb *MockDB) ListKeystoreInstances(since time.Time) ([]KeystoreInstance, error) {
+	return []KeystoreInstance{}, nil
+}
+
+// UseSigningQuota mock counting the signing requests against the quota
+func (mdb *MockDB) UseSigningQuota(apiKey, day string, quota int) (bool, error) {
+	if mdb.signingQuotas == nil {
+		mdb.signingQuotas = map[string]int{}
+	}
+	key := apiKey + "/" + day
+	if mdb.signingQuotas[key] >= quota {
+		return false, nil
+	}
+	mdb.signingQuotas[key]++
+	return true, nil
+}
+
+// DeleteSigningQuotas database mock
+func (mdb *MockDB) DeleteSigningQuotas(before string) error {
+	for key := range mdb.signingQuotas {
+		if key[strings.LastIndex(key, "/")+1:] < before {
+			delete(mdb.signingQuotas, key)
+		}
+	}
 	return nil
 }
 
-// CreateTestLogTable error mock for the database
-func (mdb *MockDB) CreateTestLogTable() error {
+// SchemaStatus mock for the schema migrations, all of which are applied
+func (mdb *MockDB) SchemaStatus() ([]MigrationStatus, error) {
+	now := time.Now()
+	status := []MigrationStatus{}
+	for _, m := range migrations {
+		status = append(status, MigrationStatus{Migration: m, Applied: true, AppliedAt: &now})
+	}
+	return status, nil
+}
+
+// CheckSchema mock for the schema migrations
+func (mdb *MockDB) CheckSchema() error {
 	return nil
 }
 
+// MigrateSchema mock for the schema migrations
+func (mdb *MockDB) MigrateSchema(version int) ([]Migration, error) {
+	if version < 0 || version > LatestSchemaVersion() {
+		return nil, fmt.Errorf("the schema version must be between 0 and %d", LatestSchemaVersion())
+	}
+	return []Migration{}, nil
+}
+
+// RollbackSchema mock for the schema migrations
+func (mdb *MockDB) RollbackSchema() (Migration, error) {
+	return migrations[len(migrations)-1], nil
+}
+
 // CheckForDuplicate database mock
 func (mdb *MockDB) CheckForDuplicate(signLog *SigningLog) (bool, int, error) {
 	switch signLog.SerialNumber {
-	case "Aduplicate":
+	case "Aduplicate", "AduplicateSameKey", "AduplicateError":
 		return true, 3, nil
 	case "AnError":
 		return false, 0, errors.New("Error in check for duplicate")
@@ -378,6 +459,17 @@ func (mdb *MockDB) CheckForDuplicate(signLog *SigningLog) (bool, int, error) {
 	return false, 0, nil
 }
 
+// CheckForDeviceKeyChange database mock
+func (mdb *MockDB) CheckForDeviceKeyChange(signLog SigningLog) (bool, error) {
+	switch signLog.SerialNumber {
+	case "Aduplicate":
+		return true, nil
+	case "AduplicateError":
+		return false, errors.New("Error in check for device-key change")
+	}
+	return false, nil
+}
+
 // CheckForMatching database mock
 func (mdb *MockDB) CheckForMatching(signLog SigningLog) (bool, error) {
 	switch signLog.SerialNumber {
@@ -405,8 +497,8 @@ func (mdb *MockDB) CreateSigningLogSync(signLog SigningLog) error {
 	return nil
 }
 
-// ListAllowedSigningLog database mock
-func (mdb *MockDB) ListAllowedSigningLog(authorization User) ([]SigningLog, error) {
+// ListAllowedSigningLogForAccount database mock
+func (mdb *MockDB) ListAllowedSigningLogForAccount(authorization User, authorityID string, params *SigningLogParams) (SigningLogPage, error) {
 	var fromID = 11
 	signingLog := []SigningLog{}
 
@@ -417,12 +509,42 @@ func (mdb *MockDB) ListAllowedSigningLog(authorization User) ([]SigningLog, erro
 	for i := 1; i < fromID; i++ {
 		signingLog = append(signingLog, SigningLog{ID: i, Make: "System", Model: "Router 3400", SerialNumber: fmt.Sprintf("A%d", i), Fingerprint: fmt.Sprintf("a%d", i), Created: time.Now()})
 	}
-	return signingLog, nil
+
+	page := SigningLogPage{Logs: signingLog, Total: len(signingLog)}
+	if params.Limit > 0 && params.Limit < uint64(len(signingLog)) {
+		page.Logs = signingLog[:params.Limit]
+		page.NextCursor = EncodeSigningLogCursor(params.Sort, signingLog[params.Limit-1])
+	}
+	return page, nil
 }
 
-// ListAllowedSigningLogForAccount database mock
-func (mdb *MockDB) ListAllowedSigningLogForAccount(authorization User, authorityID string, params *SigningLogParams) ([]SigningLog, error) {
-	return mdb.ListAllowedSigningLog(authorization)
+// ExportAllowedSigningLog database mock
+func (mdb *MockDB) ExportAllowedSigningLog(authorization User, authorityID string, params *SigningLogParams, fn func(SigningLog) error) error {
+	page, _ := mdb.ListAllowedSigningLogForAccount(authorization, authorityID, &SigningLogParams{})
+	for _, l := range page.Logs {
+		if err := fn(l); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListAllowedSigningLogChainHeads database mock
+func (mdb *MockDB) ListAllowedSigningLogChainHeads(authorization User, authorityID string) ([]SigningLogChainHead, error) {
+	heads := []SigningLogChainHead{{Make: "System", Seq: 10, Hash: strings.Repeat("a", 64)}}
+	if len(authorization.Username) > 0 {
+		heads[0].Seq = 4
+	}
+	return heads, nil
+}
+
+// VerifySigningLogChain database mock
+func (mdb *MockDB) VerifySigningLogChain(authorityID string) ([]SigningLogChainReport, error) {
+	reports := []SigningLogChainReport{{Make: "System", Entries: 10, Head: 10, Problems: []string{}}}
+	if authorityID == "changed" {
+		reports = append(reports, SigningLogChainReport{Make: "changed", Entries: 3, Head: 3, Problems: []string{"entry 2 (id 7) has been changed"}})
+	}
+	return reports, nil
 }
 
 // SyncSigningLog database mock
@@ -444,11 +566,6 @@ func (mdb *MockDB) AllowedSigningLogFilterValues(authorization User, authorityID
 	return SigningLogFilters{Makes: []string{"System"}, Models: []string{"Router 3400"}}, nil
 }
 
-// CreateDeviceNonceTable database mock
-func (mdb *MockDB) CreateDeviceNonceTable() error {
-	return nil
-}
-
 // DeleteExpiredDeviceNonces database mock
 func (mdb *MockDB) DeleteExpiredDeviceNonces() error {
 	return nil
@@ -464,11 +581,6 @@ func (mdb *MockDB) ValidateDeviceNonce(nonce string) error {
 	return nil
 }
 
-// CreateOpenidNonceTable database mock
-func (mdb *MockDB) CreateOpenidNonceTable() error {
-	return nil
-}
-
 // CreateOpenidNonce database mock
 func (mdb *MockDB) CreateOpenidNonce(nonce OpenidNonce) error {
 	return nil
@@ -479,21 +591,6 @@ func (mdb *MockDB) CheckUserInAccount(username, authorityID string) bool {
 	return true
 }
 
-// CreateUserTable §
-func (mdb *MockDB) CreateUserTable() error {
-	return nil
-}
-
-// CreateAccountUserLinkTable mock for creating database AccountUserLink table operation
-func (mdb *MockDB) CreateAccountUserLinkTable() error {
-	return nil
-}
-
-// AlterUserTable mock for modifications on User table operation
-func (mdb *MockDB) AlterUserTable() error {
-	return nil
-}
-
 // CreateUser mock for create user operation
 func (mdb *MockDB) CreateUser(user User) (int, error) {
 	return 740, nil
@@ -657,16 +754,6 @@ func (mdb *MockDB) ListAccountUsers(authorityID string) ([]User, error) {
 	return mdb.ListUsers()
 }
 
-// CreateKeypairStatusTable mock the database creation
-func (mdb *MockDB) CreateKeypairStatusTable() error {
-	return nil
-}
-
-// AlterKeypairStatusTable mock the database creation
-func (mdb *MockDB) AlterKeypairStatusTable() error {
-	return nil
-}
-
 // CreateKeypairStatus mocks the creation of a keypair status record
 func (mdb *MockDB) CreateKeypairStatus(ks KeypairStatus) (int, error) {
 	return 4, nil
@@ -703,21 +790,11 @@ func (mdb *MockDB) DeleteKeypairStatus(ks KeypairStatus) error {
 	return nil
 }
 
-// CreateModelAssertTable mock for creating database model assertion headers table
-func (mdb *MockDB) CreateModelAssertTable() error {
-	return nil
-}
-
 // CreateModelAssert mock for creating model assertion record
 func (mdb *MockDB) CreateModelAssert(m ModelAssertion) (int, error) {
 	return 1, nil
 }
 
-// AlterModelAssertTable mock for the alter model assertion table method
-func (mdb *MockDB) AlterModelAssertTable() error {
-	return nil
-}
-
 // UpdateModelAssert mock for updating model assertion record
 func (mdb *MockDB) UpdateModelAssert(m ModelAssertion) error {
 	return nil
@@ -767,11 +844,6 @@ func (mdb *MockDB) UpsertModelAssert(m ModelAssertion) error {
 	return nil
 }
 
-// CreateSubstoreTable mock for the create substore table method
-func (mdb *MockDB) CreateSubstoreTable() error {
-	return nil
-}
-
 // CreateAllowedSubstore mock to create a substore record
 func (mdb *MockDB) CreateAllowedSubstore(store Substore, authorization User) (Substore, error) {
 	substore := Substore{ID: 1, AccountID: store.AccountID, FromModelID: store.FromModelID, Store: store.Store, SerialNumber: store.SerialNumber, ModelName: store.ModelName}
@@ -816,6 +888,14 @@ func (mdb *MockDB) GetSubstore(fromModelID int, serialNumber string) (Substore,
 	return Substore{ID: 1, AccountID: 1, FromModelID: 1, FromModel: fromModel, Store: "mybrand", SerialNumber: "abc1234", ModelName: "alder-mybrand"}, nil
 }
 
+// GetSubstoreByID mock to get a substore record
+func (mdb *MockDB) GetSubstoreByID(storeID int) (Substore, error) {
+	if storeID == 2 {
+		return mdb.GetSubstore(1, "abc1234X")
+	}
+	return mdb.GetSubstore(1, "abc1234")
+}
+
 // GetSubstoreModel mock to get a substore record
 func (mdb *MockDB) GetSubstoreModel(brand, model, serialNumber string) (Substore, error) {
 	if model == "invalid" {
@@ -829,6 +909,248 @@ func (mdb *MockDB) GetAllowedSubstore(fromModelID int, serialNumber string, auth
 	return mdb.GetSubstore(fromModelID, serialNumber)
 }
 
+// ListAllowedSerialRules mock to list the serial number rules of a model
+func (mdb *MockDB) ListAllowedSerialRules(modelID int, authorization User) ([]SerialRule, error) {
+	if modelID == 999 {
+		return nil, errors.New("MOCK the model does not exist")
+	}
+
+	rules := []SerialRule{
+		{ID: 1, ModelID: modelID, RuleType: SerialRuleSerial, Value: "A123456L"},
+		{ID: 2, ModelID: modelID, RuleType: SerialRuleRange, Value: "A", RangeStart: 1000, RangeEnd: 1999},
+		{ID: 3, ModelID: modelID, RuleType: SerialRulePattern, Value: "B[0-9]{6}L"},
+	}
+	return rules, nil
+}
+
+// ListAllAllowedSerialRules mock to list the serial number rules of all the models
+func (mdb *MockDB) ListAllAllowedSerialRules(authorization User) ([]SerialRule, error) {
+	rules := []SerialRule{
+		{ID: 1, ModelID: 1, RuleType: SerialRuleSerial, Value: "A123456L"},
+		{ID: 2, ModelID: 1, RuleType: SerialRuleRange, Value: "A", RangeStart: 1000, RangeEnd: 1999},
+		{ID: 3, ModelID: 2, RuleType: SerialRulePattern, Value: "B[0-9]{6}L"},
+	}
+	return rules, nil
+}
+
+// CreateAllowedSerialRules mock to create serial number rules for a model
+func (mdb *MockDB) CreateAllowedSerialRules(modelID int, rules []SerialRule, authorization User) error {
+	if modelID == 999 {
+		return errors.New("MOCK the model does not exist")
+	}
+	if len(rules) == 0 {
+		return errors.New("no serial number rules supplied")
+	}
+	for _, rule := range rules {
+		if err := validateSerialRule(rule); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteAllowedSerialRule mock to delete a serial number rule
+func (mdb *MockDB) DeleteAllowedSerialRule(modelID, ruleID int, authorization User) error {
+	if modelID == 999 {
+		return errors.New("MOCK the model does not exist")
+	}
+	return nil
+}
+
+// CheckSerialAllowed mock to check a serial number against the rules of a model
+func (mdb *MockDB) CheckSerialAllowed(modelID int, serial string) (bool, error) {
+	switch serial {
+	case "Adisallowed":
+		return false, nil
+	case "AallowedError":
+		return false, errors.New("MOCK error checking the serial number rules")
+	}
+	return true, nil
+}
+
+// GetAllowedSerialAllocation mock to get the serial number allocation of a model
+func (mdb *MockDB) GetAllowedSerialAllocation(modelID int, authorization User) (SerialAllocation, error) {
+	switch modelID {
+	case 999:
+		return SerialAllocation{}, errors.New("MOCK the model does not exist")
+	case 1:
+		return SerialAllocation{ModelID: 1, Prefix: "A", Digits: 6, Checksum: true, Counter: 42}, nil
+	}
+	return SerialAllocation{}, nil
+}
+
+// UpdateAllowedSerialAllocation mock to update the serial number allocation of a model
+func (mdb *MockDB) UpdateAllowedSerialAllocation(alloc SerialAllocation, authorization User) error {
+	if alloc.ModelID == 999 {
+		return errors.New("MOCK the model does not exist")
+	}
+	return validateSerialAllocation(alloc)
+}
+
+// DeleteAllowedSerialAllocation mock to disable the serial number allocation of a model
+func (mdb *MockDB) DeleteAllowedSerialAllocation(modelID int, authorization User) error {
+	if modelID == 999 {
+		return errors.New("MOCK the model does not exist")
+	}
+	return nil
+}
+
+// AllocateSerial mock to assign the next serial number of a model
+func (mdb *MockDB) AllocateSerial(modelID int, signLog SigningLog, sign func(signLog *SigningLog) error) (string, error) {
+	switch modelID {
+	case 5:
+		alloc := SerialAllocation{ModelID: 5, Prefix: "A", Digits: 6, Checksum: true}
+		signLog.SerialNumber = alloc.Format(1)
+		if err := sign(&signLog); err != nil {
+			return "", err
+		}
+		return signLog.SerialNumber, nil
+	case 6:
+		return "", errors.New("MOCK error allocating the serial number")
+	}
+	return "", nil
+}
+
+// ListAllowedDeviceKeyRevocations mock to return the device-key revocations
+func (mdb *MockDB) ListAllowedDeviceKeyRevocations(authorization User) ([]DeviceKeyRevocation, error) {
+	revocations := []DeviceKeyRevocation{
+		{ID: 1, BrandID: "system", Model: "alder", SerialNumber: "R123456L", Reason: "Stolen device", Created: time.Now()},
+		{ID: 2, BrandID: "system", Fingerprint: "UytTqTvREVhx0tSfYC6KkFHmLWllIIZbQ3NsEG7OARrWuaXSRJyey0vjIQkTEvMO", Reason: "Leaked device-key", Created: time.Now()},
+	}
+	return revocations, nil
+}
+
+// CreateAllowedDeviceKeyRevocation mock to revoke a device-key or serial number
+func (mdb *MockDB) CreateAllowedDeviceKeyRevocation(rev DeviceKeyRevocation, authorization User) (DeviceKeyRevocation, error) {
+	if err := validateDeviceKeyRevocation(rev); err != nil {
+		return rev, err
+	}
+	if _, err := mdb.GetAllowedAccount(rev.BrandID, authorization); err != nil {
+		return rev, err
+	}
+	rev.ID = 3
+	rev.Created = time.Now()
+	return rev, nil
+}
+
+// DeleteAllowedDeviceKeyRevocation mock to remove a device-key revocation
+func (mdb *MockDB) DeleteAllowedDeviceKeyRevocation(revocationID int, authorization User) error {
+	if revocationID == 999 {
+		return errors.New("MOCK the device-key revocation does not exist")
+	}
+	return nil
+}
+
+// CheckDeviceKeyRevoked mock to check the device-key revocations
+func (mdb *MockDB) CheckDeviceKeyRevoked(brandID, modelName, serialNumber, fingerprint string) (DeviceKeyRevocation, error) {
+	if serialNumber == "E123456L" {
+		return DeviceKeyRevocation{}, errors.New("MOCK error checking the device-key revocations")
+	}
+
+	revocations, _ := mdb.ListAllowedDeviceKeyRevocations(User{})
+	for _, rev := range revocations {
+		if len(rev.Fingerprint) > 0 && rev.BrandID == brandID && rev.Fingerprint == fingerprint {
+			return rev, nil
+		}
+		if len(rev.SerialNumber) > 0 && rev.BrandID == brandID && rev.Model == modelName && rev.SerialNumber == serialNumber {
+			return rev, nil
+		}
+	}
+	return DeviceKeyRevocation{}, nil
+}
+
+// SyncDeviceKeyRevocations mock to replace the device-key revocations
+func (mdb *MockDB) SyncDeviceKeyRevocations(revocations []DeviceKeyRevocation) error {
+	return nil
+}
+
+// SyncSerialRules mock to replace the serial number rules
+func (mdb *MockDB) SyncSerialRules(rules []SerialRule) error {
+	return nil
+}
+
+// CreatePendingOperation mock to request a keypair operation
+func (mdb *MockDB) CreatePendingOperation(op PendingOperation) (PendingOperation, error) {
+	op.ID = 10
+	op.Status = OperationStatusPending
+	op.RequestedAt = time.Now()
+	return op, nil
+}
+
+// GetPendingOperation mock to return a pending operation from a fixed list
+func (mdb *MockDB) GetPendingOperation(operationID int) (PendingOperation, error) {
+	operations, _ := mdb.ListAllowedPendingOperations(User{})
+	for _, op := range operations {
+		if op.ID == operationID {
+			return op, nil
+		}
+	}
+	return PendingOperation{}, errors.New("Cannot find the pending operation")
+}
+
+// ListAllowedPendingOperations mock to return the pending operations
+func (mdb *MockDB) ListAllowedPendingOperations(authorization User) ([]PendingOperation, error) {
+	decided := time.Now()
+	operations := []PendingOperation{
+		{ID: 1, Operation: OperationEnable, AuthorityID: "system", KeyName: "system", KeypairID: 1, Status: OperationStatusPending, RequestedBy: "sv", RequestedAt: time.Now()},
+		{ID: 2, Operation: OperationGenerate, AuthorityID: "system", KeyName: "newkey", Status: OperationStatusPending, RequestedBy: "root", RequestedAt: time.Now()},
+		{ID: 3, Operation: OperationRegister, AuthorityID: "system", KeyName: "system", KeypairID: 1, Status: OperationStatusApproved, RequestedBy: "sv", RequestedAt: time.Now(), DecidedBy: "root", DecidedAt: &decided},
+		{ID: 4, Operation: OperationDelete, AuthorityID: "system", KeyName: "system", KeypairID: 1, Status: OperationStatusRejected, RequestedBy: "sv", RequestedAt: time.Now(), DecidedBy: "root", DecidedAt: &decided},
+	}
+	return operations, nil
+}
+
+// FindApprovedOperation mock to find an approved operation in the fixed list
+func (mdb *MockDB) FindApprovedOperation(operation, authorityID, keyName string) (PendingOperation, error) {
+	operations, _ := mdb.ListAllowedPendingOperations(User{})
+	for _, op := range operations {
+		if op.Operation == operation && op.AuthorityID == authorityID && op.KeyName == keyName && op.Status == OperationStatusApproved {
+			return op, nil
+		}
+	}
+	return PendingOperation{}, nil
+}
+
+// UpdatePendingOperationStatus mock to record the decision on an operation
+func (mdb *MockDB) UpdatePendingOperationStatus(op PendingOperation, fromStatus string) error {
+	return nil
+}
+
+// CreateAuditLog mock to record a change in the audit log
+func (mdb *MockDB) CreateAuditLog(auditLog AuditLog) error {
+	auditLog.ID = len(mdb.AuditLogs) + 1
+	auditLog.Created = time.Now()
+	mdb.AuditLogs = append(mdb.AuditLogs, auditLog)
+	return nil
+}
+
+// ListAuditLog mock to return a page of the audit log
+func (mdb *MockDB) ListAuditLog(params *AuditLogParams) (AuditLogPage, error) {
+	logs := []AuditLog{}
+	mdb.StreamAuditLog(params, func(l AuditLog) error {
+		logs = append([]AuditLog{l}, logs...)
+		return nil
+	})
+	return AuditLogPage{Logs: logs, Total: len(logs)}, nil
+}
+
+// StreamAuditLog mock to pass the audit logs to the function, the oldest first
+func (mdb *MockDB) StreamAuditLog(params *AuditLogParams, fn func(AuditLog) error) error {
+	logs := []AuditLog{
+		{ID: 1, Created: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC), Actor: "sv", Role: Admin, Action: AuditActionUpdate, Entity: AuditEntityModel, EntityID: 1, Diff: `{"model":{"old":"alder","new":"ash"}}`, SourceIP: "192.168.1.10"},
+		{ID: 2, Created: time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC), Actor: "root", Role: Superuser, Action: AuditActionDisable, Entity: AuditEntityKeypair, EntityID: 1, Diff: `{"Active":{"old":true,"new":false}}`, SourceIP: "192.168.1.20"},
+	}
+	for _, l := range logs {
+		if len(params.Entity) > 0 && l.Entity != params.Entity {
+			continue
+		}
+		if err := fn(l); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // CreateTestLog mock to create a test log
 func (mdb *MockDB) CreateTestLog(testLog TestLog) error {
 	return nil
@@ -873,51 +1195,35 @@ func (mdb *MockDB) HealthCheck() error {
 	return nil
 }
 
-// -----------------------------------------------------------------------------
-
-// ErrorMockDB holds the unsuccessful mocks for the database
-type ErrorMockDB struct{}
-
-// CreateModelTable mock for the create model table method
-func (mdb *ErrorMockDB) CreateModelTable() error {
-	return errors.New("Error creating the model table")
+// ReadBackupTables mock for the database
+func (mdb *MockDB) ReadBackupTables(signingLog bool) ([]BackupTable, error) {
+	tables := []BackupTable{
+		{Name: "account", Columns: []string{"id", "authority_id"}, Rows: [][]interface{}{{int64(1), "system"}}},
+		{Name: "keypair", Columns: []string{"id", "authority_id", "key_id", "sealed_key"}, Rows: [][]interface{}{{int64(1), "system", "61abf588e52be7a3", ""}}},
+		{Name: "settings", Columns: []string{"id", "code", "data"}, Rows: [][]interface{}{}},
+		{Name: "model", Columns: []string{"id", "brand_id", "name", "keypair_id"}, Rows: [][]interface{}{{int64(1), "system", "alder", int64(1)}}},
+	}
+	if signingLog {
+		tables = append(tables, BackupTable{Name: "signinglog", Columns: []string{"id", "make"}, Rows: [][]interface{}{{int64(1), "system"}}})
+	}
+	return tables, nil
 }
 
-// AlterModelTable mock for the alter model table method
-func (mdb *ErrorMockDB) AlterModelTable() error {
+// RestoreBackupTables mock for the database
+func (mdb *MockDB) RestoreBackupTables(tables []BackupTable) error {
 	return nil
 }
 
-// CreateKeypairTable mock for the create keypair table method
-func (mdb *ErrorMockDB) CreateKeypairTable() error {
-	return nil
-}
+// -----------------------------------------------------------------------------
 
-// AlterKeypairTable mock for the alter keypair table method
-func (mdb *ErrorMockDB) AlterKeypairTable() error {
-	return nil
-}
+// ErrorMockDB holds the unsuccessful mocks for the database
+type ErrorMockDB struct{}
 
 // UpdateKeypairAssertion mock to update the account-key assertion of a keypair
 func (mdb *ErrorMockDB) UpdateKeypairAssertion(keypair Keypair, authorization User) (string, error) {
 	return "invalid-assertion", errors.New("MOCK Error updating the keypair assertion")
 }
 
-// CreateSettingsTable mock for the create settings table method
-func (mdb *ErrorMockDB) CreateSettingsTable() error {
-	return nil
-}
-
-// CreateAccountTable mock for the create account table method
-func (mdb *ErrorMockDB) CreateAccountTable() error {
-	return nil
-}
-
-// AlterAccountTable mock for the create account table method
-func (mdb *ErrorMockDB) AlterAccountTable() error {
-	return nil
-}
-
 // CreateAccount mock to create an account record
 func (mdb *ErrorMockDB) CreateAccount(account Account) error {
 	return errors.New("MOCK creating the account")
@@ -1030,6 +1336,11 @@ func (mdb *ErrorMockDB) GetKeypair(keypairID int) (Keypair, error) {
 	return keypair, errors.New("Error fetching from the database")
 }
 
+// RolloverModelKeypairs error mock for the database
+func (mdb *ErrorMockDB) RolloverModelKeypairs(now time.Time) (int, error) {
+	return 0, errors.New("Error updating the database")
+}
+
 // GetKeypairByPublicID error mock for the database
 func (mdb *ErrorMockDB) GetKeypairByPublicID(auth, keyID string) (Keypair, error) {
 	keypair := Keypair{AuthorityID: "system", KeyID: "UytTqTvREVhx0tSfYC6KkFHmLWllIIZbQ3NsEG7OARrWuaXSRJyey0vjIQkTEvMO", Active: true}
@@ -1066,6 +1377,31 @@ func (mdb *ErrorMockDB) SyncKeypair(keypair SyncKeypair) error {
 	return errors.New("Error updating the database")
 }
 
+// UpdateKeypairSealedKey error mock for the database
+func (mdb *ErrorMockDB) UpdateKeypairSealedKey(keypair Keypair, authKey Setting) error {
+	return errors.New("Error updating the database")
+}
+
+// UpdateKeypairValidity error mock for the database
+func (mdb *ErrorMockDB) UpdateKeypairValidity(keypair Keypair) error {
+	return errors.New("Error updating the database")
+}
+
+// DeleteKeypair error mock for the database
+func (mdb *ErrorMockDB) DeleteKeypair(keypairID int) error {
+	return errors.New("Error updating the database")
+}
+
+// UpdateKeypairPolicy error mock for the database
+func (mdb *ErrorMockDB) UpdateKeypairPolicy(keypairID int, policy KeypairPolicy) error {
+	return errors.New("Error updating the database")
+}
+
+// UpdateKeypairsSealedKeys error mock for the database
+func (mdb *ErrorMockDB) UpdateKeypairsSealedKeys(keypairs []SyncKeypair, imports []PendingOperation, verify func(SyncKeypair) error, verifyImport func(PendingOperation) error) error {
+	return errors.New("Error updating the database")
+}
+
 // UpdateAllowedKeypairActive error mock for the database
 func (mdb *ErrorMockDB) UpdateAllowedKeypairActive(keypairID int, active bool, authorization User) error {
 	return errors.New("Error updating the database")
@@ -1086,6 +1422,11 @@ func (mdb *ErrorMockDB) CheckForDuplicate(signLog *SigningLog) (bool, int, error
 	return false, 0, nil
 }
 
+// CheckForDeviceKeyChange error mock for the database
+func (mdb *ErrorMockDB) CheckForDeviceKeyChange(signLog SigningLog) (bool, error) {
+	return false, nil
+}
+
 // CheckForMatching error mock for the database
 func (mdb *ErrorMockDB) CheckForMatching(signLog SigningLog) (bool, error) {
 	return false, nil
@@ -1101,25 +1442,64 @@ func (mdb *ErrorMockDB) CreateSigningLogSync(signLog SigningLog) error {
 	return nil
 }
 
-// CreateSigningLogTable error mock for the database
-func (mdb *ErrorMockDB) CreateSigningLogTable() error {
-	return nil
+// PutKeystoreInstance error mock for the database
+func (mdb *ErrorMockDB) PutKeystoreInstance(instance KeystoreInstance) error {
+	return errors.New("Error updating the database")
 }
 
-// CreateTestLogTable error mock for the database
-func (mdb *ErrorMockDB) CreateTestLogTable() error {
-	return nil
+// ListKeystoreInstances error mock for the database
+func (mdb *ErrorMockDB) ListKeystoreInstances(since time.Time) ([]KeystoreInstance, error) {
+	return nil, errors.New("Error fetching from the database")
 }
 
-// ListAllowedSigningLog error mock for the database
-func (mdb *ErrorMockDB) ListAllowedSigningLog(authorization User) ([]SigningLog, error) {
-	var signingLog []SigningLog
-	return signingLog, errors.New("Error retrieving the signing logs")
+// UseSigningQuota error mock for the database
+func (mdb *ErrorMockDB) UseSigningQuota(apiKey, day string, quota int) (bool, error) {
+	return false, errors.New("Error updating the database")
 }
 
-// ListAllowedSigningLogForAccount database mock
-func (mdb *ErrorMockDB) ListAllowedSigningLogForAccount(authorization User, authorityID string, params *SigningLogParams) ([]SigningLog, error) {
-	return mdb.ListAllowedSigningLog(authorization)
+// DeleteSigningQuotas error mock for the database
+func (mdb *ErrorMockDB) DeleteSigningQuotas(before string) error {
+	return errors.New("Error updating the database")
+}
+
+// SchemaStatus error mock for the database
+func (mdb *ErrorMockDB) SchemaStatus() ([]MigrationStatus, error) {
+	return nil, errors.New("Error fetching from the database")
+}
+
+// CheckSchema error mock for the database
+func (mdb *ErrorMockDB) CheckSchema() error {
+	return errors.New("Error fetching from the database")
+}
+
+// MigrateSchema error mock for the database
+func (mdb *ErrorMockDB) MigrateSchema(version int) ([]Migration, error) {
+	return nil, errors.New("MOCK error migrating the schema")
+}
+
+// RollbackSchema error mock for the database
+func (mdb *ErrorMockDB) RollbackSchema() (Migration, error) {
+	return Migration{}, errors.New("MOCK error rolling back the schema")
+}
+
+// ListAllowedSigningLogForAccount error mock for the database
+func (mdb *ErrorMockDB) ListAllowedSigningLogForAccount(authorization User, authorityID string, params *SigningLogParams) (SigningLogPage, error) {
+	return SigningLogPage{}, errors.New("Error retrieving the signing logs")
+}
+
+// ExportAllowedSigningLog error mock for the database
+func (mdb *ErrorMockDB) ExportAllowedSigningLog(authorization User, authorityID string, params *SigningLogParams, fn func(SigningLog) error) error {
+	return errors.New("Error retrieving the signing logs")
+}
+
+// ListAllowedSigningLogChainHeads error mock for the database
+func (mdb *ErrorMockDB) ListAllowedSigningLogChainHeads(authorization User, authorityID string) ([]SigningLogChainHead, error) {
+	return nil, errors.New("Error retrieving the signing log chain heads")
+}
+
+// VerifySigningLogChain error mock for the database
+func (mdb *ErrorMockDB) VerifySigningLogChain(authorityID string) ([]SigningLogChainReport, error) {
+	return nil, errors.New("Error verifying the signing log chain")
 }
 
 // SyncSigningLog error mock for the database
@@ -1138,11 +1518,6 @@ func (mdb *ErrorMockDB) AllowedSigningLogFilterValues(authorization User, author
 	return SigningLogFilters{}, errors.New("Error retrieving the signing log filters")
 }
 
-// CreateDeviceNonceTable error mock for the database
-func (mdb *ErrorMockDB) CreateDeviceNonceTable() error {
-	return nil
-}
-
 // DeleteExpiredDeviceNonces error mock for the database
 func (mdb *ErrorMockDB) DeleteExpiredDeviceNonces() error {
 	return nil
@@ -1158,11 +1533,6 @@ func (mdb *ErrorMockDB) ValidateDeviceNonce(nonce string) error {
 	return errors.New("MOCK error validating a nonce")
 }
 
-// CreateOpenidNonceTable database mock
-func (mdb *ErrorMockDB) CreateOpenidNonceTable() error {
-	return nil
-}
-
 // CreateOpenidNonce database mock
 func (mdb *ErrorMockDB) CreateOpenidNonce(nonce OpenidNonce) error {
 	return errors.New("MOCK error generating the nonce")
@@ -1173,21 +1543,6 @@ func (mdb *ErrorMockDB) CheckUserInAccount(username, authorityID string) bool {
 	return true
 }
 
-// CreateUserTable mock for creating database User table operation
-func (mdb *ErrorMockDB) CreateUserTable() error {
-	return errors.New("Could not create User table")
-}
-
-// CreateAccountUserLinkTable mock for creating database AccountUserLink table operation
-func (mdb *ErrorMockDB) CreateAccountUserLinkTable() error {
-	return errors.New("Could not create AccountUserLink table")
-}
-
-// AlterUserTable mock for modifications on User table operation
-func (mdb *ErrorMockDB) AlterUserTable() error {
-	return errors.New("Could not alter User table")
-}
-
 // CreateUser error mock for create user operation
 func (mdb *ErrorMockDB) CreateUser(user User) (int, error) {
 	return 0, errors.New("Cannot create user")
@@ -1243,16 +1598,6 @@ func (mdb *ErrorMockDB) ListAccountUsers(authorityID string) ([]User, error) {
 	return []User{}, errors.New("Could not get any user for that account")
 }
 
-// CreateKeypairStatusTable mock the database creation with error
-func (mdb *ErrorMockDB) CreateKeypairStatusTable() error {
-	return errors.New("Could not create the Keypair Status table")
-}
-
-// AlterKeypairStatusTable mock the database creation
-func (mdb *ErrorMockDB) AlterKeypairStatusTable() error {
-	return errors.New("Could not update the Keypair Status table")
-}
-
 // CreateKeypairStatus mocks the creation of a keypair status record
 func (mdb *ErrorMockDB) CreateKeypairStatus(ks KeypairStatus) (int, error) {
 	return 0, errors.New("Cannot create keypair status record")
@@ -1278,21 +1623,11 @@ func (mdb *ErrorMockDB) DeleteKeypairStatus(ks KeypairStatus) error {
 	return errors.New("Cannot delete the keypair status")
 }
 
-// CreateModelAssertTable mock for creating database model assertion headers table
-func (mdb *ErrorMockDB) CreateModelAssertTable() error {
-	return errors.New("Cannot create the model assertion table")
-}
-
 // CreateModelAssert mock for creating model assertion record
 func (mdb *ErrorMockDB) CreateModelAssert(m ModelAssertion) (int, error) {
 	return 0, errors.New("Cannot create the model assertion record")
 }
 
-// AlterModelAssertTable mock for the alter model assertion table method
-func (mdb *ErrorMockDB) AlterModelAssertTable() error {
-	return errors.New("Cannot amend the model assertion table")
-}
-
 // UpdateModelAssert mock for updating model assertion record
 func (mdb *ErrorMockDB) UpdateModelAssert(m ModelAssertion) error {
 	return errors.New("Cannot update the model assertion record")
@@ -1308,11 +1643,6 @@ func (mdb *ErrorMockDB) UpsertModelAssert(m ModelAssertion) error {
 	return errors.New("Cannot upsert the model assertion record")
 }
 
-// CreateSubstoreTable mock for the create substore table method
-func (mdb *ErrorMockDB) CreateSubstoreTable() error {
-	return nil
-}
-
 // CreateAllowedSubstore mock to create a substore record
 func (mdb *ErrorMockDB) CreateAllowedSubstore(store Substore, authorization User) (Substore, error) {
 	return store, errors.New("Cannot create the sub-store model")
@@ -1345,6 +1675,11 @@ func (mdb *ErrorMockDB) GetSubstore(fromModelID int, serialNumber string) (Subst
 	return Substore{}, errors.New("Cannot get the sub-store model")
 }
 
+// GetSubstoreByID mock to get a substore record
+func (mdb *ErrorMockDB) GetSubstoreByID(storeID int) (Substore, error) {
+	return Substore{}, errors.New("Cannot get the sub-store")
+}
+
 // GetSubstoreModel mock to get a substore record
 func (mdb *ErrorMockDB) GetSubstoreModel(brand, model, serialNumber string) (Substore, error) {
 	return Substore{}, errors.New("Cannot get the sub-store model")
@@ -1355,6 +1690,121 @@ func (mdb *ErrorMockDB) GetAllowedSubstore(fromModelID int, serialNumber string,
 	return mdb.GetSubstore(fromModelID, serialNumber)
 }
 
+// ListAllowedSerialRules mock to list the serial number rules of a model
+func (mdb *ErrorMockDB) ListAllowedSerialRules(modelID int, authorization User) ([]SerialRule, error) {
+	return nil, errors.New("MOCK error listing the serial number rules")
+}
+
+// ListAllAllowedSerialRules mock to list the serial number rules of all the models
+func (mdb *ErrorMockDB) ListAllAllowedSerialRules(authorization User) ([]SerialRule, error) {
+	return nil, errors.New("MOCK error listing the serial number rules")
+}
+
+// CreateAllowedSerialRules mock to create serial number rules for a model
+func (mdb *ErrorMockDB) CreateAllowedSerialRules(modelID int, rules []SerialRule, authorization User) error {
+	return errors.New("MOCK error creating the serial number rules")
+}
+
+// DeleteAllowedSerialRule mock to delete a serial number rule
+func (mdb *ErrorMockDB) DeleteAllowedSerialRule(modelID, ruleID int, authorization User) error {
+	return errors.New("MOCK error deleting the serial number rule")
+}
+
+// CheckSerialAllowed mock to check a serial number against the rules of a model
+func (mdb *ErrorMockDB) CheckSerialAllowed(modelID int, serial string) (bool, error) {
+	return true, nil
+}
+
+// GetAllowedSerialAllocation mock to get the serial number allocation of a model
+func (mdb *ErrorMockDB) GetAllowedSerialAllocation(modelID int, authorization User) (SerialAllocation, error) {
+	return SerialAllocation{}, errors.New("MOCK error retrieving the serial number allocation")
+}
+
+// UpdateAllowedSerialAllocation mock to update the serial number allocation of a model
+func (mdb *ErrorMockDB) UpdateAllowedSerialAllocation(alloc SerialAllocation, authorization User) error {
+	return errors.New("MOCK error saving the serial number allocation")
+}
+
+// DeleteAllowedSerialAllocation mock to disable the serial number allocation of a model
+func (mdb *ErrorMockDB) DeleteAllowedSerialAllocation(modelID int, authorization User) error {
+	return errors.New("MOCK error deleting the serial number allocation")
+}
+
+// AllocateSerial mock to assign the next serial number of a model
+func (mdb *ErrorMockDB) AllocateSerial(modelID int, signLog SigningLog, sign func(signLog *SigningLog) error) (string, error) {
+	return "", nil
+}
+
+// ListAllowedDeviceKeyRevocations mock to return the device-key revocations
+func (mdb *ErrorMockDB) ListAllowedDeviceKeyRevocations(authorization User) ([]DeviceKeyRevocation, error) {
+	return nil, errors.New("MOCK error retrieving the device-key revocations")
+}
+
+// CreateAllowedDeviceKeyRevocation mock to revoke a device-key or serial number
+func (mdb *ErrorMockDB) CreateAllowedDeviceKeyRevocation(rev DeviceKeyRevocation, authorization User) (DeviceKeyRevocation, error) {
+	return rev, errors.New("MOCK error creating the device-key revocation")
+}
+
+// DeleteAllowedDeviceKeyRevocation mock to remove a device-key revocation
+func (mdb *ErrorMockDB) DeleteAllowedDeviceKeyRevocation(revocationID int, authorization User) error {
+	return errors.New("MOCK error deleting the device-key revocation")
+}
+
+// CheckDeviceKeyRevoked mock to check the device-key revocations
+func (mdb *ErrorMockDB) CheckDeviceKeyRevoked(brandID, modelName, serialNumber, fingerprint string) (DeviceKeyRevocation, error) {
+	return DeviceKeyRevocation{}, nil
+}
+
+// SyncDeviceKeyRevocations mock to replace the device-key revocations
+func (mdb *ErrorMockDB) SyncDeviceKeyRevocations(revocations []DeviceKeyRevocation) error {
+	return errors.New("MOCK error syncing the device-key revocations")
+}
+
+// SyncSerialRules mock to replace the serial number rules
+func (mdb *ErrorMockDB) SyncSerialRules(rules []SerialRule) error {
+	return errors.New("MOCK error syncing the serial number rules")
+}
+
+// CreatePendingOperation mock to request a keypair operation
+func (mdb *ErrorMockDB) CreatePendingOperation(op PendingOperation) (PendingOperation, error) {
+	return op, errors.New("MOCK error creating the pending operation")
+}
+
+// GetPendingOperation mock to return a pending operation
+func (mdb *ErrorMockDB) GetPendingOperation(operationID int) (PendingOperation, error) {
+	return PendingOperation{}, errors.New("MOCK error retrieving the pending operation")
+}
+
+// ListAllowedPendingOperations mock to return the pending operations
+func (mdb *ErrorMockDB) ListAllowedPendingOperations(authorization User) ([]PendingOperation, error) {
+	return nil, errors.New("MOCK error retrieving the pending operations")
+}
+
+// FindApprovedOperation mock to find an approved operation
+func (mdb *ErrorMockDB) FindApprovedOperation(operation, authorityID, keyName string) (PendingOperation, error) {
+	return PendingOperation{}, errors.New("MOCK error retrieving the approved operations")
+}
+
+// UpdatePendingOperationStatus mock to record the decision on an operation
+func (mdb *ErrorMockDB) UpdatePendingOperationStatus(op PendingOperation, fromStatus string) error {
+	return errors.New("MOCK error updating the pending operation")
+}
+
+// CreateAuditLog mock to record a change in the audit log
+func (mdb *ErrorMockDB) CreateAuditLog(auditLog AuditLog) error {
+	return errors.New("MOCK error creating the audit log")
+}
+
+// ListAuditLog mock to return a page of the audit log
+func (mdb *ErrorMockDB) ListAuditLog(params *AuditLogParams) (AuditLogPage, error) {
+	return AuditLogPage{}, errors.New("MOCK error retrieving the audit log")
+}
+
+// StreamAuditLog mock to pass the audit logs to the function
+func (mdb *ErrorMockDB) StreamAuditLog(params *AuditLogParams, fn func(AuditLog) error) error {
+	return errors.New("MOCK error exporting the audit log")
+}
+
 // CreateTestLog mock to create a test log
 func (mdb *ErrorMockDB) CreateTestLog(testLog TestLog) error {
 	return errors.New("MOCK Cannot create the test log")
@@ -1384,3 +1834,13 @@ func (mdb *ErrorMockDB) UpdateAllowedTestLog(ID int, authorization User) error {
 func (mdb *ErrorMockDB) HealthCheck() error {
 	return errors.New("Health check failed")
 }
+
+// ReadBackupTables error mock for the database
+func (mdb *ErrorMockDB) ReadBackupTables(signingLog bool) ([]BackupTable, error) {
+	return nil, errors.New("Error reading the backup tables")
+}
+
+// RestoreBackupTables error mock for the database
+func (mdb *ErrorMockDB) RestoreBackupTables(tables []BackupTable) error {
+	return errors.New("Error restoring the backup tables")
+}
@@ -33,7 +33,7 @@ func GetMemoryKeyStore(config config.Settings) (*KeypairDatabase, error) {
 	db, err := asserts.OpenDatabase(&asserts.DatabaseConfig{
 		KeypairManager: asserts.NewMemoryKeypairManager(),
 	})
-	kdb := KeypairDatabase{FilesystemStore, db, nil}
+	kdb := KeypairDatabase{FilesystemStore, db, nil, nil}
 	return &kdb, err
 }
 
@@ -56,6 +56,6 @@ func GetErrorMockKeyStore(config config.Settings) (*KeypairDatabase, error) {
 	db, err := asserts.OpenDatabase(&asserts.DatabaseConfig{
 		KeypairManager: mockStore,
 	})
-	kdb := KeypairDatabase{FilesystemStore, db, nil}
+	kdb := KeypairDatabase{FilesystemStore, db, nil, nil}
 	return &kdb, err
 }
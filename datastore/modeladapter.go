@@ -64,12 +64,13 @@ func (db *DB) GetAllowedModel(modelID int, authorization User) (Model, error) {
 
 // UpdateAllowedModel updates the model if authorization is allowed to do it
 func (db *DB) UpdateAllowedModel(model Model, authorization User) (string, error) {
+	model.DuplicatePolicy = defaultDuplicatePolicy(model.DuplicatePolicy)
 	errorSubcode, err := validateModel(model, "error-validate-model")
 	if err != nil {
 		return errorSubcode, fmt.Errorf("error updating the model: %v", err)
 	}
 
-	if !db.checkBrandsMatch(model.BrandID, model.KeypairID, model.KeypairIDUser) {
+	if !db.checkBrandsMatch(model.BrandID, model.KeypairID, model.KeypairIDUser) || !db.checkNextKeypairBrand(model) {
 		return "error-auth", errors.New("error updating the model: the model and the keys must have the same brand")
 	}
 
@@ -122,6 +123,7 @@ func (db *DB) DeleteAllowedModel(model Model, authorization User) (string, error
 
 // CreateAllowedModel creates a new model in case authorization is allowed to do it
 func (db *DB) CreateAllowedModel(model Model, authorization User) (Model, string, error) {
+	model.DuplicatePolicy = defaultDuplicatePolicy(model.DuplicatePolicy)
 	errorSubcode, err := validateModel(model, "error-validate-new-model")
 	if err != nil {
 		return model, errorSubcode, fmt.Errorf("error creating the model: %v", err)
@@ -131,7 +133,7 @@ func (db *DB) CreateAllowedModel(model Model, authorization User) (Model, string
 		return model, "error-auth", errors.New("the user does not have permissions to create a model for this account")
 	}
 
-	if !db.checkBrandsMatch(model.BrandID, model.KeypairID, model.KeypairIDUser) {
+	if !db.checkBrandsMatch(model.BrandID, model.KeypairID, model.KeypairIDUser) || !db.checkNextKeypairBrand(model) {
 		return model, "error-auth", errors.New("error creating the model: the model and the keys must have the same brand")
 	}
 
@@ -181,9 +183,36 @@ func validateModel(model Model, validateModelLabel string) (string, error) {
 		return "error-validate-userkey", fmt.Errorf(errTemplate, model.Name, err)
 	}
 
+	err = validateDuplicatePolicy(model.DuplicatePolicy)
+	if err != nil {
+		return "error-validate-duplicate-policy", fmt.Errorf(errTemplate, model.Name, err)
+	}
+
+	err = validateRollover(model)
+	if err != nil {
+		return "error-validate-rollover", fmt.Errorf(errTemplate, model.Name, err)
+	}
+
 	return "", nil
 }
 
+// defaultDuplicatePolicy keeps the existing behaviour of signing duplicates when no policy is set
+func defaultDuplicatePolicy(policy string) string {
+	if len(policy) == 0 {
+		return DuplicateAllow
+	}
+	return policy
+}
+
+func validateDuplicatePolicy(policy string) error {
+	switch defaultDuplicatePolicy(policy) {
+	case DuplicateAllow, DuplicateRejectDeviceKey, DuplicateReject:
+		return nil
+	default:
+		return fmt.Errorf("the duplicate serial policy must be one of '%s', '%s' or '%s'", DuplicateAllow, DuplicateRejectDeviceKey, DuplicateReject)
+	}
+}
+
 func validateBrandID(brandID string) error {
 	return validateNotEmpty("Brand ID", brandID)
 }
@@ -200,6 +229,19 @@ func validateKeypairID(keypairID int) error {
 	return nil
 }
 
+func validateRollover(model Model) error {
+	if model.NextKeypairID <= 0 {
+		return nil
+	}
+	if model.NextKeypairID == model.KeypairID {
+		return errors.New("the next Signing Key must be different from the current one")
+	}
+	if model.RolloverAt == nil {
+		return errors.New("the rollover time must be entered for the next Signing Key")
+	}
+	return nil
+}
+
 func validateKeypairIDUser(keypairIDUser int) error {
 	if keypairIDUser <= 0 {
 		return errors.New("the System-User Key must be selected")
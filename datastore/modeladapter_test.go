@@ -52,3 +52,19 @@ func TestModelNameUpperChar(t *testing.T) {
 		t.Error("Error happening is not the one searched for")
 	}
 }
+
+func TestDuplicatePolicy(t *testing.T) {
+	for _, policy := range []string{"", DuplicateAllow, DuplicateRejectDeviceKey, DuplicateReject} {
+		if err := validateDuplicatePolicy(policy); err != nil {
+			t.Errorf("Expected policy '%s' to be valid: %v", policy, err)
+		}
+	}
+
+	if err := validateDuplicatePolicy("invalid"); err == nil {
+		t.Error("Expected policy not to be valid, but it is")
+	}
+
+	if defaultDuplicatePolicy("") != DuplicateAllow {
+		t.Error("Expected an empty policy to default to allowing duplicates")
+	}
+}
@@ -23,6 +23,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/CanonicalLtd/serial-vault/service/log"
 )
@@ -34,18 +35,21 @@ const createModelTableSQL = `
 		name             varchar(200) not null,
 		keypair_id       int references keypair not null,
 		user_keypair_id  int references keypair not null,
-		api_key          varchar(200) not null
+		api_key          varchar(200) not null,
+		duplicate_policy varchar(50) not null default 'allow',
+		next_keypair_id  int references keypair,
+		rollover_at      timestamp
 	)
 `
 const listModelsSQL = `
-	select m.id, brand_id, name, m.keypair_id, m.api_key, k.authority_id, k.key_id, k.active, user_keypair_id, ku.authority_id, ku.key_id, ku.active, ku.assertion
+	select m.id, brand_id, name, m.keypair_id, m.api_key, m.duplicate_policy, coalesce(m.next_keypair_id, 0), m.rollover_at, k.authority_id, k.key_id, k.active, user_keypair_id, ku.authority_id, ku.key_id, ku.active, ku.assertion
 	from model m
 	inner join keypair k on k.id = m.keypair_id
 	inner join keypair ku on ku.id = m.user_keypair_id
 	order by name
 `
 const listModelsForUserSQL = `
-	select m.id, brand_id, m.name, m.keypair_id, m.api_key, k.authority_id, k.key_id, k.active, user_keypair_id, ku.authority_id, ku.key_id, ku.active, ku.assertion
+	select m.id, brand_id, m.name, m.keypair_id, m.api_key, m.duplicate_policy, coalesce(m.next_keypair_id, 0), m.rollover_at, k.authority_id, k.key_id, k.active, user_keypair_id, ku.authority_id, ku.key_id, ku.active, ku.assertion
 	from model m
 	inner join keypair k on k.id = m.keypair_id
 	inner join keypair ku on ku.id = m.user_keypair_id
@@ -56,19 +60,19 @@ const listModelsForUserSQL = `
 	order by name
 `
 const findModelSQL = `
-	select m.id, brand_id, name, m.keypair_id, m.api_key, k.authority_id, k.key_id, k.active, k.sealed_key, user_keypair_id, ku.authority_id, ku.key_id, ku.active, ku.sealed_key, ku.assertion
+	select m.id, brand_id, name, m.keypair_id, m.api_key, m.duplicate_policy, coalesce(m.next_keypair_id, 0), m.rollover_at, k.authority_id, k.key_id, k.active, k.sealed_key, user_keypair_id, ku.authority_id, ku.key_id, ku.active, ku.sealed_key, ku.assertion
 	from model m
 	inner join keypair k on k.id = m.keypair_id
 	inner join keypair ku on ku.id = m.user_keypair_id
 	where brand_id=$1 and name=$2 and api_key=$3`
 const getModelSQL = `
-	select m.id, brand_id, name, m.keypair_id, m.api_key, k.authority_id, k.key_id, k.active, k.sealed_key, user_keypair_id, ku.authority_id, ku.key_id, ku.active, ku.sealed_key, ku.assertion
+	select m.id, brand_id, name, m.keypair_id, m.api_key, m.duplicate_policy, coalesce(m.next_keypair_id, 0), m.rollover_at, k.authority_id, k.key_id, k.active, k.sealed_key, user_keypair_id, ku.authority_id, ku.key_id, ku.active, ku.sealed_key, ku.assertion
 	from model m
 	inner join keypair k on k.id = m.keypair_id
 	inner join keypair ku on ku.id = m.user_keypair_id
 	where m.id=$1`
 const getModelForUserSQL = `
-	select m.id, m.brand_id, m.name, m.keypair_id, m.api_key, k.authority_id, k.key_id, k.active, k.sealed_key, user_keypair_id, ku.authority_id, ku.key_id, ku.active, ku.sealed_key, ku.assertion
+	select m.id, m.brand_id, m.name, m.keypair_id, m.api_key, m.duplicate_policy, coalesce(m.next_keypair_id, 0), m.rollover_at, k.authority_id, k.key_id, k.active, k.sealed_key, user_keypair_id, ku.authority_id, ku.key_id, ku.active, ku.sealed_key, ku.assertion
 	from model m
 	inner join keypair k on k.id = m.keypair_id
 	inner join keypair ku on ku.id = m.user_keypair_id
@@ -76,22 +80,33 @@ const getModelForUserSQL = `
 	inner join useraccountlink ua on ua.account_id=acc.id
 	inner join userinfo u on ua.user_id=u.id
 	where m.id=$1 and u.username=$2`
-const updateModelSQL = "update model set brand_id=$2, name=$3, keypair_id=$4, user_keypair_id=$5, api_key=$6 where id=$1"
+const updateModelSQL = `
+	update model set brand_id=$2, name=$3, keypair_id=$4, user_keypair_id=$5, api_key=$6, duplicate_policy=$7,
+		next_keypair_id=$8, rollover_at=$9
+	where id=$1`
 const updateModelForUserSQL = `
-	update model m set brand_id=$2, name=$3, keypair_id=$4, user_keypair_id=$5, api_key=$6
+	update model m set brand_id=$2, name=$3, keypair_id=$4, user_keypair_id=$5, api_key=$6, duplicate_policy=$7,
+		next_keypair_id=$8, rollover_at=$9
 	from account acc
 	inner join useraccountlink ua on ua.account_id=acc.id
 	inner join userinfo u on ua.user_id=u.id
-	where acc.authority_id=m.brand_id and m.id=$1 and u.username=$7`
-const createModelSQL = "insert into model (brand_id,name,keypair_id,user_keypair_id,api_key) values ($1,$2,$3,$4,$5) RETURNING id"
+	where acc.authority_id=m.brand_id and m.id=$1 and u.username=$10`
+const createModelSQL = `
+	insert into model (brand_id,name,keypair_id,user_keypair_id,api_key,duplicate_policy,next_keypair_id,rollover_at)
+	values ($1,$2,$3,$4,$5,$6,$7,$8) RETURNING id`
 
 // sqlite3 syntax for syncing data locally
 const syncUpsertModelSQL = `
 	INSERT OR REPLACE INTO model
-	(id,brand_id,name,keypair_id,user_keypair_id,api_key)
-	VALUES ($1, $2, $3, $4, $5, $6)
+	(id,brand_id,name,keypair_id,user_keypair_id,api_key,duplicate_policy,next_keypair_id,rollover_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
 `
 
+// Switch the models to their next keypair when the rollover is due
+const rolloverModelKeypairSQL = `
+	update model set keypair_id=next_keypair_id, next_keypair_id=null, rollover_at=null
+	where next_keypair_id is not null and rollover_at <= $1`
+
 const deleteModelSQL = "delete from model where id=$1"
 const deleteModelForUserSQL = `
 	delete from model m
@@ -134,11 +149,25 @@ const alterModelAPIKeyNotNullable = `alter table model
 	alter column api_key drop default
 `
 
+// Add the duplicate serial policy field to the models table
+const alterModelDuplicatePolicy = "alter table model add column duplicate_policy varchar(50) not null default 'allow'"
+
+// Add the next keypair that the model rolls over to at a scheduled time
+const alterModelNextKeypair = "alter table model add column next_keypair_id int references keypair"
+const alterModelRolloverAt = "alter table model add column rollover_at timestamp"
+
 // Indexes
 const createModelAPIKeyIndexSQL = "CREATE INDEX IF NOT EXISTS api_key_idx ON model (api_key)"
 
 const minAPIKeyLength = 10
 
+// Policies for handling a serial-request for a serial number or device-key that has already been signed
+const (
+	DuplicateAllow           = "allow"             // sign the duplicate with an incremented revision
+	DuplicateRejectDeviceKey = "reject-device-key" // reject the serial number if it is reused with a different device-key
+	DuplicateReject          = "reject"            // reject all duplicates
+)
+
 // Model holds the model details in the local database
 type Model struct {
 	ID              int            `json:"id"`
@@ -146,6 +175,9 @@ type Model struct {
 	Name            string         `json:"model"`
 	KeypairID       int            `json:"keypair-id"`
 	APIKey          string         `json:"api-key"`
+	DuplicatePolicy string         `json:"duplicate-policy"`
+	NextKeypairID   int            `json:"next-keypair-id"`
+	RolloverAt      *time.Time     `json:"rollover-at"`
 	AuthorityID     string         `json:"authority-id"`      // from the signing keypair
 	KeyID           string         `json:"key-id"`            // from the signing keypair
 	KeyActive       bool           `json:"key-active"`        // from the signing keypair
@@ -177,6 +209,11 @@ func (db *DB) AlterModelTable() error {
 		return err
 	}
 
+	// Ignoring the error when adding the column, as it may already exist
+	db.Exec(alterModelDuplicatePolicy)
+	db.Exec(alterModelNextKeypair)
+	db.Exec(alterModelRolloverAt)
+
 	// Create the index on the API key
 	_, err = db.Exec(createModelAPIKeyIndexSQL)
 	if err != nil {
@@ -277,7 +314,7 @@ func (db *DB) listModelsFilteredByUser(username string) ([]Model, error) {
 
 	for rows.Next() {
 		model := Model{}
-		err := rows.Scan(&model.ID, &model.BrandID, &model.Name, &model.KeypairID, &model.APIKey, &model.AuthorityID, &model.KeyID, &model.KeyActive,
+		err := rows.Scan(&model.ID, &model.BrandID, &model.Name, &model.KeypairID, &model.APIKey, &model.DuplicatePolicy, &model.NextKeypairID, &model.RolloverAt, &model.AuthorityID, &model.KeyID, &model.KeyActive,
 			&model.KeypairIDUser, &model.AuthorityIDUser, &model.KeyIDUser, &model.KeyActiveUser, &model.AssertionUser)
 		if err != nil {
 			return nil, fmt.Errorf("error retrieving models: %v", err)
@@ -298,7 +335,7 @@ func (db *DB) FindModel(brandID, modelName, apiKey string) (Model, error) {
 	model := Model{}
 
 	err := db.QueryRow(findModelSQL, brandID, modelName, apiKey).Scan(
-		&model.ID, &model.BrandID, &model.Name, &model.KeypairID, &model.APIKey, &model.AuthorityID, &model.KeyID, &model.KeyActive, &model.SealedKey,
+		&model.ID, &model.BrandID, &model.Name, &model.KeypairID, &model.APIKey, &model.DuplicatePolicy, &model.NextKeypairID, &model.RolloverAt, &model.AuthorityID, &model.KeyID, &model.KeyActive, &model.SealedKey,
 		&model.KeypairIDUser, &model.AuthorityIDUser, &model.KeyIDUser, &model.KeyActiveUser, &model.SealedKeyUser, &model.AssertionUser)
 	switch {
 	case err == sql.ErrNoRows:
@@ -308,6 +345,20 @@ func (db *DB) FindModel(brandID, modelName, apiKey string) (Model, error) {
 		return model, err
 	}
 
+	// Sign with the next keypair from the time of the rollover, without waiting for the model to be updated
+	if model.RolloverDue(time.Now()) {
+		next, err := db.GetKeypair(model.NextKeypairID)
+		if err != nil {
+			log.Printf("Error retrieving the next keypair of the model: %v\n", err)
+			return model, err
+		}
+		model.KeypairID = next.ID
+		model.AuthorityID = next.AuthorityID
+		model.KeyID = next.KeyID
+		model.KeyActive = next.Active
+		model.SealedKey = next.SealedKey
+	}
+
 	// Get the linked model assertion headers
 	m, _ := db.GetModelAssert(model.ID)
 	model.ModelAssertion = m
@@ -315,6 +366,24 @@ func (db *DB) FindModel(brandID, modelName, apiKey string) (Model, error) {
 	return model, nil
 }
 
+// RolloverDue checks whether the model is scheduled to switch to its next keypair by a time
+func (model Model) RolloverDue(now time.Time) bool {
+	return model.NextKeypairID > 0 && model.RolloverAt != nil && !now.Before(*model.RolloverAt)
+}
+
+// RolloverModelKeypairs switches the models to their next keypair when the rollover is due,
+// returning the number of models that were updated
+func (db *DB) RolloverModelKeypairs(now time.Time) (int, error) {
+	result, err := db.Exec(rolloverModelKeypairSQL, now.UTC())
+	if err != nil {
+		log.Printf("Error rolling over the model keypairs: %v\n", err)
+		return 0, err
+	}
+
+	rows, err := result.RowsAffected()
+	return int(rows), err
+}
+
 func (db *DB) getModel(modelID int) (Model, error) {
 	return db.getModelFilteredByUser(modelID, anyUserFilter)
 }
@@ -330,7 +399,7 @@ func (db *DB) getModelFilteredByUser(modelID int, username string) (Model, error
 		row = db.QueryRow(getModelForUserSQL, modelID, username)
 	}
 
-	err := row.Scan(&model.ID, &model.BrandID, &model.Name, &model.KeypairID, &model.APIKey, &model.AuthorityID, &model.KeyID, &model.KeyActive, &model.SealedKey,
+	err := row.Scan(&model.ID, &model.BrandID, &model.Name, &model.KeypairID, &model.APIKey, &model.DuplicatePolicy, &model.NextKeypairID, &model.RolloverAt, &model.AuthorityID, &model.KeyID, &model.KeyActive, &model.SealedKey,
 		&model.KeypairIDUser, &model.AuthorityIDUser, &model.KeyIDUser, &model.KeyActiveUser, &model.SealedKeyUser, &model.AssertionUser)
 	if err != nil {
 		return model, fmt.Errorf("error retrieving database model %d: %v", modelID, err)
@@ -351,9 +420,11 @@ func (db *DB) updateModelFilteredByUser(model Model, username string) (string, e
 	var err error
 
 	if len(username) == 0 {
-		_, err = db.Exec(updateModelSQL, model.ID, model.BrandID, model.Name, model.KeypairID, model.KeypairIDUser, model.APIKey)
+		_, err = db.Exec(updateModelSQL, model.ID, model.BrandID, model.Name, model.KeypairID, model.KeypairIDUser, model.APIKey, model.DuplicatePolicy,
+			nextKeypairID(model), utcTime(model.RolloverAt))
 	} else {
-		_, err = db.Exec(updateModelForUserSQL, model.ID, model.BrandID, model.Name, model.KeypairID, model.KeypairIDUser, model.APIKey, username)
+		_, err = db.Exec(updateModelForUserSQL, model.ID, model.BrandID, model.Name, model.KeypairID, model.KeypairIDUser, model.APIKey, model.DuplicatePolicy,
+			nextKeypairID(model), utcTime(model.RolloverAt), username)
 	}
 	if err != nil {
 		return "", fmt.Errorf("error updating the database model for %s: %v", model.Name, err)
@@ -370,7 +441,8 @@ func (db *DB) createModelFilteredByUser(model Model, username string) (Model, st
 	// Create the model in the database
 	var createdModelID int
 
-	err := db.QueryRow(createModelSQL, model.BrandID, model.Name, model.KeypairID, model.KeypairIDUser, model.APIKey).Scan(&createdModelID)
+	err := db.QueryRow(createModelSQL, model.BrandID, model.Name, model.KeypairID, model.KeypairIDUser, model.APIKey, model.DuplicatePolicy,
+		nextKeypairID(model), utcTime(model.RolloverAt)).Scan(&createdModelID)
 	if err != nil {
 		return model, "", fmt.Errorf("error creating the model for %s: %v", model.Name, err)
 	}
@@ -390,7 +462,8 @@ func (db *DB) SyncModel(m Model) error {
 		return err
 	}
 
-	_, err = db.Exec(syncUpsertModelSQL, m.ID, m.BrandID, m.Name, m.KeypairID, m.KeypairIDUser, m.APIKey)
+	_, err = db.Exec(syncUpsertModelSQL, m.ID, m.BrandID, m.Name, m.KeypairID, m.KeypairIDUser, m.APIKey, defaultDuplicatePolicy(m.DuplicatePolicy),
+		nextKeypairID(m), utcTime(m.RolloverAt))
 	if err != nil {
 		return err
 	}
@@ -398,6 +471,11 @@ func (db *DB) SyncModel(m Model) error {
 	return nil
 }
 
+// nextKeypairID is the nullable reference to the next keypair of the model
+func nextKeypairID(model Model) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(model.NextKeypairID), Valid: model.NextKeypairID > 0}
+}
+
 func (db *DB) deleteModel(model Model) (string, error) {
 	return db.deleteModelFilteredByUser(model, anyUserFilter)
 }
@@ -411,6 +489,16 @@ func (db *DB) deleteModelFilteredByUser(model Model, username string) (string, e
 			log.Println(err)
 		}
 
+		// Delete the serial number rules of the model
+		if err := db.deleteSerialRulesForModel(model.ID); err != nil {
+			log.Println(err)
+		}
+
+		// Delete the serial number allocation of the model
+		if err := db.deleteSerialAllocation(model.ID); err != nil {
+			log.Println(err)
+		}
+
 		// Delete the model
 		if len(username) == 0 {
 			_, err = db.Exec(deleteModelSQL, model.ID)
@@ -426,6 +514,14 @@ func (db *DB) deleteModelFilteredByUser(model Model, username string) (string, e
 	return "", err
 }
 
+// checkNextKeypairBrand checks that the next keypair of the model is for the same brand
+func (db *DB) checkNextKeypairBrand(model Model) bool {
+	if model.NextKeypairID <= 0 {
+		return true
+	}
+	return db.checkBrandsMatch(model.BrandID, model.NextKeypairID, model.KeypairIDUser)
+}
+
 func (db *DB) checkBrandsMatch(brandID string, keypairID, keypairIDUser int) bool {
 
 	var count int
@@ -32,8 +32,11 @@ import (
 	"github.com/CanonicalLtd/serial-vault/random"
 )
 
-// Set the nonce expiry time
-const nonceMaximumAge = 600
+// Default nonce expiry time and pruning interval, in seconds
+const (
+	defaultNonceTTL           = 600
+	defaultNoncePruneInterval = 60
+)
 
 const createDeviceNonceTableSQL = `
 	CREATE TABLE IF NOT EXISTS devicenonce (
@@ -49,11 +52,10 @@ const createDeviceNonceNonceIndexSQL = "CREATE INDEX IF NOT EXISTS nonce_idx ON
 const createDeviceNonceTimeStampIndexSQL = "CREATE INDEX IF NOT EXISTS timestamp_idx ON devicenonce (timestamp)"
 
 // Queries
-const maxIDDeviceNonceSQLite = "SELECT COUNT(*)+1 from devicenonce"
-const createDeviceNonceSQLite = "INSERT INTO devicenonce (id, nonce, timestamp) VALUES ($1, $2, $3)"
+const createDeviceNonceSQLite = "INSERT INTO devicenonce (id, nonce, timestamp) SELECT COALESCE(MAX(id),0)+1, $1, $2 FROM devicenonce"
 const createDeviceNonceSQL = "INSERT INTO devicenonce (nonce, timestamp) VALUES ($1, $2)"
 const deleteExpiredDeviceNonceSQL = "DELETE FROM devicenonce where timestamp<$1"
-const deleteDeviceNonceSQL = "DELETE FROM devicenonce where nonce=$1"
+const consumeDeviceNonceSQL = "DELETE FROM devicenonce where nonce=$1 and timestamp>=$2"
 
 // DeviceNonce holds the details of the nonce, combining a timestamp and random text
 type DeviceNonce struct {
@@ -91,15 +93,9 @@ func (db *DB) CreateDeviceNonce() (DeviceNonce, error) {
 
 	// Create the nonce in the database
 	if InFactory() {
-		// Need to generate our own ID
-		var nextID int
-		err = db.QueryRow(maxIDDeviceNonceSQLite).Scan(&nextID)
-		if err != nil {
-			log.Printf("Error retrieving next nonce ID: %v\n", err)
-			return nonce, err
-		}
-
-		_, err = db.Exec(createDeviceNonceSQLite, nextID, nonce.Nonce, nonce.TimeStamp)
+		// Need to generate our own ID, in the same statement as the insert so that
+		// concurrent requests cannot be given the same ID
+		_, err = db.Exec(createDeviceNonceSQLite, nonce.Nonce, nonce.TimeStamp)
 	} else {
 		_, err = db.Exec(createDeviceNonceSQL, nonce.Nonce, nonce.TimeStamp)
 	}
@@ -115,7 +111,7 @@ func (db *DB) CreateDeviceNonce() (DeviceNonce, error) {
 // DeleteExpiredDeviceNonces removes nonces with timestamp older than max allowed lifetime
 func (db *DB) DeleteExpiredDeviceNonces() error {
 	// Remove expired nonces from the table
-	timestamp := time.Now().Unix() - nonceMaximumAge
+	timestamp := time.Now().Unix() - nonceTTL()
 	_, err := db.Exec(deleteExpiredDeviceNonceSQL, timestamp)
 	if err != nil {
 		log.Printf("Error deleting expired nonces: %v\n", err)
@@ -125,17 +121,13 @@ func (db *DB) DeleteExpiredDeviceNonces() error {
 	return nil
 }
 
-// ValidateDeviceNonce checks that a device nonce is valid and has not expired
+// ValidateDeviceNonce checks that a device nonce is valid and has not expired, and consumes it
 func (db *DB) ValidateDeviceNonce(nonce string) error {
-	err := db.DeleteExpiredDeviceNonces()
-	if err != nil {
-		log.Printf("Error checking expired nonces: %v\n", err)
-		return err
-	}
-	// Find the nonce in the database to check that it is valid (we already deleted expired nonces)
-	// Here we attempt to delete the nonce and check the number of rows affected. This makes sure that
-	// we do not allow a nonce to be re-used.
-	result, err := db.Exec(deleteDeviceNonceSQL, nonce)
+	// Delete the nonce, if it has not expired, and check the number of rows affected. The check
+	// and the delete are a single statement, so a nonce can only be used once, even when the
+	// same nonce is used by concurrent requests. Expired nonces are removed by the pruning.
+	timestamp := time.Now().Unix() - nonceTTL()
+	result, err := db.Exec(consumeDeviceNonceSQL, nonce, timestamp)
 	if err != nil {
 		log.Printf("Error checking nonce: %v\n", err)
 		return errors.New("Error communicating with the database")
@@ -153,6 +145,35 @@ func (db *DB) ValidateDeviceNonce(nonce string) error {
 	return nil
 }
 
+// PruneDeviceNonces removes the expired nonces at the configured interval, until the done channel is closed
+func PruneDeviceNonces(done <-chan struct{}) {
+	interval := time.Duration(Environ.Config.NoncePruneInterval) * time.Second
+	if interval <= 0 {
+		interval = defaultNoncePruneInterval * time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			// Errors are logged, and the pruning is tried again at the next interval
+			Environ.DB.DeleteExpiredDeviceNonces()
+		}
+	}
+}
+
+// nonceTTL returns the configured nonce expiry time, in seconds
+func nonceTTL() int64 {
+	if Environ.Config.NonceTTL > 0 {
+		return int64(Environ.Config.NonceTTL)
+	}
+	return defaultNonceTTL
+}
+
 func generateNonce() (DeviceNonce, error) {
 	token, err := random.GenerateRandomString(64)
 	if err != nil {
@@ -19,7 +19,16 @@
 
 package datastore
 
-import "testing"
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/CanonicalLtd/serial-vault/config"
+)
 
 func TestNonceGeneration(t *testing.T) {
 	// Generate some nonces
@@ -45,3 +54,164 @@ func TestNonceGeneration(t *testing.T) {
 		}
 	}
 }
+
+// openNonceTestDatabase opens a SQLite database file with the nonce table
+func openNonceTestDatabase(t *testing.T, settings config.Settings) *DB {
+	dir, err := os.MkdirTemp("", "nonce")
+	if err != nil {
+		t.Fatalf("Error creating the database directory: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	// The concurrent writers wait on the database lock, so allow for a slow disk
+	sqlDB, err := sql.Open("sqlite3", filepath.Join(dir, "nonce.db")+"?_busy_timeout=60000")
+	if err != nil {
+		t.Fatalf("Error opening the database: %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	db := &DB{sqlDB}
+	settings.Driver = "sqlite3"
+	env := Environ
+	Environ = &Env{DB: db, Config: settings}
+	t.Cleanup(func() { Environ = env })
+
+	if err := db.CreateDeviceNonceTable(); err != nil {
+		t.Fatalf("Error creating the nonce table: %v", err)
+	}
+	return db
+}
+
+func TestDeviceNonceConcurrentCreate(t *testing.T) {
+	db := openNonceTestDatabase(t, config.Settings{})
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 50)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := db.CreateDeviceNonce(); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("Error creating a nonce: %v", err)
+	}
+
+	var count, ids int
+	err := db.QueryRow("SELECT COUNT(*), COUNT(DISTINCT id) FROM devicenonce").Scan(&count, &ids)
+	if err != nil {
+		t.Fatalf("Error counting the nonces: %v", err)
+	}
+	if count != 50 || ids != 50 {
+		t.Errorf("Expected 50 nonces with unique IDs, got %d nonces and %d IDs", count, ids)
+	}
+}
+
+func TestDeviceNonceCreateAfterDelete(t *testing.T) {
+	db := openNonceTestDatabase(t, config.Settings{})
+
+	nonce1, _ := db.CreateDeviceNonce()
+	db.CreateDeviceNonce()
+	if err := db.ValidateDeviceNonce(nonce1.Nonce); err != nil {
+		t.Fatalf("Error validating the nonce: %v", err)
+	}
+
+	// The IDs of the remaining nonces are not reused
+	if _, err := db.CreateDeviceNonce(); err != nil {
+		t.Errorf("Error creating a nonce after a nonce is used: %v", err)
+	}
+}
+
+func TestDeviceNonceSingleUse(t *testing.T) {
+	db := openNonceTestDatabase(t, config.Settings{})
+
+	nonce, err := db.CreateDeviceNonce()
+	if err != nil {
+		t.Fatalf("Error creating a nonce: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	valid := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := db.ValidateDeviceNonce(nonce.Nonce); err == nil {
+				mu.Lock()
+				valid++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if valid != 1 {
+		t.Errorf("Expected the nonce to be used once, got %d", valid)
+	}
+	if err := db.ValidateDeviceNonce("invalid"); err == nil {
+		t.Error("Expected an error with an invalid nonce")
+	}
+}
+
+func TestDeviceNonceExpiry(t *testing.T) {
+	db := openNonceTestDatabase(t, config.Settings{NonceTTL: 60, NoncePruneInterval: 1})
+
+	nonce, err := db.CreateDeviceNonce()
+	if err != nil {
+		t.Fatalf("Error creating a nonce: %v", err)
+	}
+	_, err = db.Exec("INSERT INTO devicenonce (id, nonce, timestamp) VALUES (100, 'expired', $1)", time.Now().Unix()-61)
+	if err != nil {
+		t.Fatalf("Error creating an expired nonce: %v", err)
+	}
+
+	// The expired nonce cannot be used
+	if err := db.ValidateDeviceNonce("expired"); err == nil {
+		t.Error("Expected an error with an expired nonce")
+	}
+
+	// The expired nonce is removed by the pruning
+	done := make(chan struct{})
+	go PruneDeviceNonces(done)
+	defer close(done)
+
+	var count int
+	for i := 0; i < 30; i++ {
+		time.Sleep(100 * time.Millisecond)
+		db.QueryRow("SELECT COUNT(*) FROM devicenonce").Scan(&count)
+		if count == 1 {
+			break
+		}
+	}
+	if count != 1 {
+		t.Errorf("Expected the expired nonce to be pruned, got %d nonces", count)
+	}
+
+	if err := db.ValidateDeviceNonce(nonce.Nonce); err != nil {
+		t.Errorf("Error validating the nonce: %v", err)
+	}
+}
+
+func TestSQLiteDataSource(t *testing.T) {
+	tests := []struct {
+		dataSource string
+		want       string
+	}{
+		{"/var/lib/serial-vault/factory.db", "/var/lib/serial-vault/factory.db?_busy_timeout=10000"},
+		{"file:factory.db?cache=shared", "file:factory.db?cache=shared&_busy_timeout=10000"},
+		{"factory.db?_busy_timeout=500", "factory.db?_busy_timeout=500"},
+	}
+
+	for _, tt := range tests {
+		if got := sqliteDataSource(tt.dataSource); got != tt.want {
+			t.Errorf("Expected data source %q, got %q", tt.want, got)
+		}
+	}
+}
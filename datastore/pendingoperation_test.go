@@ -0,0 +1,338 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package datastore
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/CanonicalLtd/serial-vault/config"
+	"github.com/CanonicalLtd/serial-vault/crypt"
+)
+
+// setUpPendingOperations stores an inactive keypair, and a keypair that is used by a model
+func setUpPendingOperations(t *testing.T) *DB {
+	db := openBackupTestDatabase(t)
+
+	env := Environ
+	Environ = &Env{DB: db, Config: config.Settings{Driver: "sqlite3", DualControl: true, EnableUserAuth: true}}
+	t.Cleanup(func() { Environ = env })
+
+	for _, k := range []Keypair{{ID: 1, AuthorityID: "system", KeyID: "inactive", KeyName: "inactive"}, {ID: 2, AuthorityID: "system", KeyID: "used", KeyName: "used", Active: true}} {
+		if err := db.SyncKeypair(SyncKeypair{Keypair: k}); err != nil {
+			t.Fatalf("Error creating the keypair: %v", err)
+		}
+	}
+	if err := db.SyncModel(Model{ID: 1, BrandID: "system", Name: "alder", KeypairID: 2, KeypairIDUser: 2, APIKey: "operation-api-key"}); err != nil {
+		t.Fatalf("Error creating the model: %v", err)
+	}
+	return db
+}
+
+// auditEntries returns the audit log as "actor action entity entity-id source-ip", oldest first
+func auditEntries(t *testing.T, db *DB) []string {
+	page, err := db.ListAuditLog(&AuditLogParams{})
+	if err != nil {
+		t.Fatalf("Error listing the audit log: %v", err)
+	}
+	entries := []string{}
+	for i := len(page.Logs) - 1; i >= 0; i-- {
+		l := page.Logs[i]
+		entries = append(entries, fmt.Sprintf("%s %s %s %d %s", l.Actor, l.Action, l.Entity, l.EntityID, l.SourceIP))
+	}
+	return entries
+}
+
+func TestApproveOperation(t *testing.T) {
+	db := setUpPendingOperations(t)
+	requester := User{Username: "alice", Role: Superuser}
+
+	op, err := RequestOperation(PendingOperation{Operation: OperationEnable, AuthorityID: "system", KeyName: "inactive", KeypairID: 1}, requester)
+	if err != nil {
+		t.Fatalf("Error requesting the operation: %v", err)
+	}
+	if op.ID == 0 || op.Status != OperationStatusPending || op.RequestedBy != "alice" {
+		t.Errorf("Unexpected requested operation: %v", op)
+	}
+	if _, err := RequestOperation(PendingOperation{Operation: OperationEnable, AuthorityID: "system", KeyName: "inactive", KeypairID: 1}, requester); err == nil {
+		t.Error("Expected an error requesting the same operation twice")
+	}
+
+	// The requester cannot approve their own request, and an Admin cannot approve it
+	if _, err := ApproveOperation(op.ID, requester, "10.0.0.1"); err == nil {
+		t.Error("Expected an error approving the operation by the requester")
+	}
+	if _, err := ApproveOperation(op.ID, User{Username: "bob", Role: Admin}, "10.0.0.1"); err == nil {
+		t.Error("Expected an error approving the operation by an Admin")
+	}
+	if k, _ := db.GetKeypair(1); k.Active {
+		t.Fatal("The keypair was enabled before the operation was approved")
+	}
+
+	op, err = ApproveOperation(op.ID, User{Username: "bob", Role: Superuser}, "10.0.0.1")
+	if err != nil {
+		t.Fatalf("Error approving the operation: %v", err)
+	}
+	if op.Status != OperationStatusDone || op.DecidedBy != "bob" || op.DecidedAt == nil {
+		t.Errorf("Unexpected approved operation: %v", op)
+	}
+	if k, _ := db.GetKeypair(1); !k.Active {
+		t.Error("The keypair was not enabled when the operation was approved")
+	}
+
+	// The approval, the change to the keypair and the result are recorded for the approver
+	expected := []string{"bob approve operation 1 10.0.0.1", "bob enable keypair 1 10.0.0.1", "bob update operation 1 10.0.0.1"}
+	if entries := auditEntries(t, db); strings.Join(entries, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected the audit log %v, got: %v", expected, entries)
+	}
+
+	// The operation cannot be decided twice
+	if _, err := ApproveOperation(op.ID, User{Username: "carol", Role: Superuser}, "10.0.0.1"); err != ErrorOperationNotPending {
+		t.Errorf("Expected the operation not to be pending: %v", err)
+	}
+	if _, err := RejectOperation(op.ID, User{Username: "carol", Role: Superuser}, "", "10.0.0.1"); err != ErrorOperationNotPending {
+		t.Errorf("Expected the operation not to be pending: %v", err)
+	}
+
+	operations, err := db.ListAllowedPendingOperations(User{Role: Superuser})
+	if err != nil {
+		t.Fatalf("Error listing the operations: %v", err)
+	}
+	if len(operations) != 1 || operations[0].Status != OperationStatusDone {
+		t.Errorf("Unexpected operations: %v", operations)
+	}
+}
+
+func TestApproveOperationFailed(t *testing.T) {
+	db := setUpPendingOperations(t)
+
+	// The keypair is used by a model, so it cannot be deleted
+	op, err := RequestOperation(PendingOperation{Operation: OperationDelete, AuthorityID: "system", KeyName: "used", KeypairID: 2}, User{Username: "alice", Role: Admin})
+	if err != nil {
+		t.Fatalf("Error requesting the operation: %v", err)
+	}
+	if _, err := ApproveOperation(op.ID, User{Username: "bob", Role: Superuser}, "10.0.0.1"); err == nil {
+		t.Fatal("Expected an error deleting a keypair that is used by a model")
+	}
+
+	op, _ = db.GetPendingOperation(op.ID)
+	if op.Status != OperationStatusFailed || len(op.Message) == 0 {
+		t.Errorf("Unexpected failed operation: %v", op)
+	}
+	if _, err := db.GetKeypair(2); err != nil {
+		t.Errorf("The keypair was deleted: %v", err)
+	}
+}
+
+// importMockDB records the imported keypair, as the keypair upsert needs PostgreSQL
+type importMockDB struct {
+	*DB
+	imported []Keypair
+}
+
+func (mdb *importMockDB) PutKeypair(keypair Keypair) (string, error) {
+	mdb.imported = append(mdb.imported, keypair)
+	return "", nil
+}
+
+func TestApproveImportOperation(t *testing.T) {
+	mdb := &importMockDB{DB: setUpPendingOperations(t)}
+	Environ.DB = mdb
+	Environ.Config.KeyStoreSecret = "this needs to be something secure"
+	keystore, err := GetMemoryKeyStore(Environ.Config)
+	if err != nil {
+		t.Fatalf("Error opening the keystore: %v", err)
+	}
+	Environ.KeypairDB = keystore
+
+	signingKey, err := ioutil.ReadFile("../keystore/TestKey.asc")
+	if err != nil {
+		t.Fatalf("Error reading the signing-key: %v", err)
+	}
+	op, err := ImportOperation("system", "imported", base64.StdEncoding.EncodeToString(signingKey))
+	if err != nil {
+		t.Fatalf("Error creating the import operation: %v", err)
+	}
+	op, err = RequestOperation(op, User{Username: "alice", Role: Admin})
+	if err != nil {
+		t.Fatalf("Error requesting the operation: %v", err)
+	}
+
+	// Nothing is stored in the keypair store or the database until the import is approved
+	privateKey, _, _ := crypt.DeserializePrivateKey(base64.StdEncoding.EncodeToString(signingKey))
+	keyID := privateKey.PublicKey().ID()
+	if len(mdb.imported) != 0 {
+		t.Fatal("The keypair was imported before the operation was approved")
+	}
+	if _, err := keystore.PublicKey(keyID); err == nil {
+		t.Fatal("The signing-key was added to the keystore before the operation was approved")
+	}
+
+	if _, err := ApproveOperation(op.ID, User{Username: "bob", Role: Superuser}, "10.0.0.1"); err != nil {
+		t.Fatalf("Error approving the operation: %v", err)
+	}
+	if len(mdb.imported) != 1 {
+		t.Fatalf("Expected the keypair to be imported: %v", mdb.imported)
+	}
+	k := mdb.imported[0]
+	if k.AuthorityID != "system" || k.KeyID != keyID || k.KeyName != "imported" {
+		t.Errorf("Unexpected imported keypair: %v", k)
+	}
+	if _, err := keystore.PublicKey(keyID); err != nil {
+		t.Errorf("The signing-key was not added to the keystore: %v", err)
+	}
+}
+
+func TestImportOperationNoSecret(t *testing.T) {
+	setUpPendingOperations(t)
+
+	if _, err := ImportOperation("system", "imported", "key"); err != ErrorImportNotSealed {
+		t.Errorf("Expected the import to need the keystore secret: %v", err)
+	}
+}
+
+func TestRejectImportOperation(t *testing.T) {
+	mdb := &importMockDB{DB: setUpPendingOperations(t)}
+	Environ.DB = mdb
+	Environ.Config.KeyStoreSecret = "this needs to be something secure"
+	keystore, err := GetMemoryKeyStore(Environ.Config)
+	if err != nil {
+		t.Fatalf("Error opening the keystore: %v", err)
+	}
+	Environ.KeypairDB = keystore
+
+	signingKey, err := ioutil.ReadFile("../keystore/TestKey.asc")
+	if err != nil {
+		t.Fatalf("Error reading the signing-key: %v", err)
+	}
+	op, err := ImportOperation("system", "imported", base64.StdEncoding.EncodeToString(signingKey))
+	if err != nil {
+		t.Fatalf("Error creating the import operation: %v", err)
+	}
+	if op, err = RequestOperation(op, User{Username: "alice", Role: Admin}); err != nil {
+		t.Fatalf("Error requesting the operation: %v", err)
+	}
+	if _, err := RejectOperation(op.ID, User{Username: "bob", Role: Superuser}, "unknown key", "10.0.0.1"); err != nil {
+		t.Fatalf("Error rejecting the operation: %v", err)
+	}
+
+	// The rejected signing-key is not left in the keystore
+	privateKey, _, _ := crypt.DeserializePrivateKey(base64.StdEncoding.EncodeToString(signingKey))
+	if _, err := keystore.PublicKey(privateKey.PublicKey().ID()); err == nil {
+		t.Error("The rejected signing-key was added to the keystore")
+	}
+	if len(mdb.imported) != 0 {
+		t.Errorf("The rejected keypair was imported: %v", mdb.imported)
+	}
+}
+
+func TestRegisterOperation(t *testing.T) {
+	db := setUpPendingOperations(t)
+
+	op, err := RequestOperation(PendingOperation{Operation: OperationRegister, AuthorityID: "system", KeyName: "used", KeypairID: 2}, User{Username: "alice", Role: Admin})
+	if err != nil {
+		t.Fatalf("Error requesting the operation: %v", err)
+	}
+	if approved, _ := db.FindApprovedOperation(OperationRegister, "system", "used"); approved.ID != 0 {
+		t.Fatalf("Unexpected approved operation: %v", approved)
+	}
+
+	// The approved registration is left for the requester to carry out
+	op, err = ApproveOperation(op.ID, User{Username: "bob", Role: Superuser}, "10.0.0.1")
+	if err != nil {
+		t.Fatalf("Error approving the operation: %v", err)
+	}
+	if op.Status != OperationStatusApproved {
+		t.Errorf("Expected the registration to be approved: %v", op)
+	}
+
+	approved, err := db.FindApprovedOperation(OperationRegister, "system", "used")
+	if err != nil || approved.ID != op.ID {
+		t.Fatalf("Error finding the approved operation: %v, %v", approved, err)
+	}
+	if err := CompleteApprovedOperation(approved, nil, User{Username: "alice", Role: Admin}, "10.0.0.2"); err != nil {
+		t.Fatalf("Error completing the operation: %v", err)
+	}
+
+	op, _ = db.GetPendingOperation(op.ID)
+	if op.Status != OperationStatusDone || op.DecidedBy != "bob" {
+		t.Errorf("Unexpected completed operation: %v", op)
+	}
+	expected := []string{"bob approve operation 1 10.0.0.1", "alice update operation 1 10.0.0.2"}
+	if entries := auditEntries(t, db); strings.Join(entries, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected the audit log %v, got: %v", expected, entries)
+	}
+	if approved, _ := db.FindApprovedOperation(OperationRegister, "system", "used"); approved.ID != 0 {
+		t.Errorf("The approved operation can be used twice: %v", approved)
+	}
+}
+
+func TestRejectOperation(t *testing.T) {
+	db := setUpPendingOperations(t)
+
+	op, err := RequestOperation(PendingOperation{Operation: OperationGenerate, AuthorityID: "system", KeyName: "new"}, User{Username: "alice", Role: Admin})
+	if err != nil {
+		t.Fatalf("Error requesting the operation: %v", err)
+	}
+
+	// Only a Superuser or the requester can reject it
+	if _, err := RejectOperation(op.ID, User{Username: "bob", Role: Admin}, "", "10.0.0.1"); err == nil {
+		t.Error("Expected an error rejecting the operation by another Admin")
+	}
+	op, err = RejectOperation(op.ID, User{Username: "alice", Role: Admin}, "Not needed", "10.0.0.1")
+	if err != nil {
+		t.Fatalf("Error withdrawing the operation: %v", err)
+	}
+	if _, err := ApproveOperation(op.ID, User{Username: "bob", Role: Superuser}, "10.0.0.1"); err != ErrorOperationNotPending {
+		t.Errorf("Expected the operation not to be pending: %v", err)
+	}
+
+	op, _ = db.GetPendingOperation(op.ID)
+	if op.Status != OperationStatusRejected || op.Message != "Not needed" || op.DecidedBy != "alice" {
+		t.Errorf("Unexpected rejected operation: %v", op)
+	}
+	if _, err := db.GetKeypairByName("system", "new"); err == nil {
+		t.Error("The keypair was generated for a rejected operation")
+	}
+	if entries := auditEntries(t, db); len(entries) != 1 || entries[0] != "alice reject operation 1 10.0.0.1" {
+		t.Errorf("Expected the rejection in the audit log, got: %v", entries)
+	}
+}
+
+func TestDeleteKeypair(t *testing.T) {
+	db := setUpPendingOperations(t)
+
+	if err := db.DeleteKeypair(2); err == nil {
+		t.Error("Expected an error deleting a keypair that is used by a model")
+	}
+	if err := db.DeleteKeypair(1); err != nil {
+		t.Fatalf("Error deleting the keypair: %v", err)
+	}
+	if _, err := db.GetKeypair(1); err == nil {
+		t.Error("The keypair was not deleted")
+	}
+	if err := db.DeleteKeypair(1); err == nil {
+		t.Error("Expected an error deleting a keypair that does not exist")
+	}
+}
@@ -0,0 +1,291 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package datastore
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/CanonicalLtd/serial-vault/crypt"
+	"github.com/CanonicalLtd/serial-vault/service/log"
+)
+
+// ErrorImportNotSealed is returned when an import cannot be held for approval, as there is no
+// keystore secret to seal the signing-key with
+var ErrorImportNotSealed = errors.New("the keystore secret must be set to import a signing-key with dual control")
+
+// importOperationData is the signing-key of an import that is waiting for approval, sealed with
+// the keystore secret. It is only added to the keypair store once the import is approved
+type importOperationData struct {
+	KeyID     string `json:"key-id"`
+	SealedKey string `json:"sealed-key"`
+}
+
+// DualControl returns whether the sensitive keypair operations need the approval of a second Superuser.
+// The requester and the approver must be known, so the operations cannot be requested from the
+// admin UI when user authentication is disabled
+func DualControl() bool {
+	return Environ.Config.DualControl
+}
+
+// ListAllowedPendingOperations returns the keypair operations for the brands the user can access
+func (db *DB) ListAllowedPendingOperations(authorization User) ([]PendingOperation, error) {
+	switch authorization.Role {
+	case Invalid: // Authentication is disabled
+		fallthrough
+	case Superuser:
+		return db.listPendingOperations()
+	case SyncUser:
+		fallthrough
+	case Admin:
+		return db.listPendingOperationsFilteredByUser(authorization.Username)
+	default:
+		return []PendingOperation{}, nil
+	}
+}
+
+// ImportOperation returns the operation to import a signing-key, which is only added to the keypair
+// store once it is approved. Until then, the operation holds the signing-key sealed with the keystore secret
+func ImportOperation(authorityID, keyName, base64PrivateKey string) (PendingOperation, error) {
+	if len(Environ.Config.KeyStoreSecret) == 0 {
+		return PendingOperation{}, ErrorImportNotSealed
+	}
+
+	privateKey, _, err := crypt.DeserializePrivateKey(base64PrivateKey)
+	if err != nil {
+		return PendingOperation{}, err
+	}
+
+	sealedKey, err := crypt.SealKey([]byte(base64PrivateKey), Environ.Config.KeyStoreSecret)
+	if err != nil {
+		return PendingOperation{}, err
+	}
+
+	data, err := json.Marshal(importOperationData{
+		KeyID:     privateKey.PublicKey().ID(),
+		SealedKey: base64.StdEncoding.EncodeToString(sealedKey),
+	})
+	if err != nil {
+		return PendingOperation{}, err
+	}
+
+	return PendingOperation{
+		Operation:   OperationImport,
+		AuthorityID: authorityID,
+		KeyName:     keyName,
+		Data:        string(data),
+	}, nil
+}
+
+// unsealImportOperation returns the signing-key that is held by an import operation
+func unsealImportOperation(op PendingOperation, secret string) (string, error) {
+	data := importOperationData{}
+	if err := json.Unmarshal([]byte(op.Data), &data); err != nil {
+		return "", fmt.Errorf("error decoding the signing-key to import: %v", err)
+	}
+
+	sealedKey, err := base64.StdEncoding.DecodeString(data.SealedKey)
+	if err != nil {
+		return "", fmt.Errorf("error decoding the signing-key to import: %v", err)
+	}
+	base64PrivateKey, err := crypt.UnsealKey(sealedKey, secret)
+	if err != nil {
+		return "", fmt.Errorf("error unsealing the signing-key to import: %v", err)
+	}
+	return string(base64PrivateKey), nil
+}
+
+// RequestOperation records a keypair operation that is carried out once a second Superuser approves it
+func RequestOperation(op PendingOperation, authorization User) (PendingOperation, error) {
+	switch op.Operation {
+	case OperationImport, OperationGenerate, OperationEnable, OperationRegister, OperationDelete:
+	default:
+		return op, fmt.Errorf("invalid operation '%s'", op.Operation)
+	}
+	if err := validateNotEmpty("Authority ID", op.AuthorityID); err != nil {
+		return op, err
+	}
+	if len(authorization.Username) == 0 {
+		return op, errors.New("the user requesting the operation must be known")
+	}
+
+	op.RequestedBy = authorization.Username
+	return Environ.DB.CreatePendingOperation(op)
+}
+
+// ApproveOperation approves a pending operation and carries it out. The approver must be a Superuser
+// other than the requester. An approved store registration is left for the requester to complete.
+// The approval, and the changes that the operation makes, are recorded in the audit log
+func ApproveOperation(operationID int, approver User, sourceIP string) (PendingOperation, error) {
+	op, err := Environ.DB.GetPendingOperation(operationID)
+	if err != nil {
+		return op, err
+	}
+
+	if approver.Role != Superuser {
+		return op, errors.New("only a Superuser can approve an operation")
+	}
+	if len(approver.Username) == 0 || approver.Username == op.RequestedBy {
+		return op, errors.New("the operation must be approved by a different user to the one that requested it")
+	}
+	if op.Status != OperationStatusPending {
+		return op, ErrorOperationNotPending
+	}
+
+	before := op
+	now := time.Now()
+	op.Status = OperationStatusApproved
+	op.DecidedBy = approver.Username
+	op.DecidedAt = &now
+	if err := Environ.DB.UpdatePendingOperationStatus(op, OperationStatusPending); err != nil {
+		return op, err
+	}
+	RecordAudit(approver, sourceIP, AuditActionApprove, AuditEntityOperation, op.ID, before, op)
+
+	if op.Operation == OperationRegister {
+		return op, nil
+	}
+
+	result := executeOperation(op, approver, sourceIP)
+	return op, completeOperation(&op, result, approver, sourceIP)
+}
+
+// RejectOperation rejects an operation that has not been carried out. The requester can also
+// withdraw their own request. The rejection is recorded in the audit log
+func RejectOperation(operationID int, authorization User, message, sourceIP string) (PendingOperation, error) {
+	op, err := Environ.DB.GetPendingOperation(operationID)
+	if err != nil {
+		return op, err
+	}
+
+	if authorization.Role != Superuser && (len(authorization.Username) == 0 || authorization.Username != op.RequestedBy) {
+		return op, errors.New("only a Superuser or the requester can reject an operation")
+	}
+	if op.Status != OperationStatusPending && op.Status != OperationStatusApproved {
+		return op, ErrorOperationNotPending
+	}
+
+	before := op
+	now := time.Now()
+	op.Status = OperationStatusRejected
+	op.DecidedBy = authorization.Username
+	op.DecidedAt = &now
+	op.Message = message
+	if err := Environ.DB.UpdatePendingOperationStatus(op, before.Status); err != nil {
+		return op, err
+	}
+	RecordAudit(authorization, sourceIP, AuditActionReject, AuditEntityOperation, op.ID, before, op)
+	return op, nil
+}
+
+// CompleteApprovedOperation records the result of an approved operation that the requester carried out
+func CompleteApprovedOperation(op PendingOperation, result error, user User, sourceIP string) error {
+	return completeOperation(&op, result, user, sourceIP)
+}
+
+// completeOperation records the result of an approved operation, and the user that carried it out
+// in the audit log
+func completeOperation(op *PendingOperation, result error, user User, sourceIP string) error {
+	before := *op
+	op.Status = OperationStatusDone
+	if result != nil {
+		op.Status = OperationStatusFailed
+		op.Message = result.Error()
+	}
+
+	if err := Environ.DB.UpdatePendingOperationStatus(*op, OperationStatusApproved); err != nil {
+		log.Printf("Error recording the result of operation %d: %v", op.ID, err)
+		return err
+	}
+	RecordAudit(user, sourceIP, AuditActionUpdate, AuditEntityOperation, op.ID, before, *op)
+	return result
+}
+
+// executeOperation carries out an approved operation, recording the change to the keypair in the
+// audit log as the approver
+func executeOperation(op PendingOperation, approver User, sourceIP string) error {
+	switch op.Operation {
+	case OperationImport:
+		base64PrivateKey, err := unsealImportOperation(op, Environ.Config.KeyStoreSecret)
+		if err != nil {
+			return err
+		}
+		privateKey, sealedPrivateKey, err := Environ.KeypairDB.ImportSigningKey(op.AuthorityID, base64PrivateKey)
+		if err != nil {
+			return err
+		}
+		keypair := Keypair{AuthorityID: op.AuthorityID, KeyID: privateKey.PublicKey().ID(), SealedKey: sealedPrivateKey, KeyName: op.KeyName}
+		if _, err = Environ.DB.PutKeypair(keypair); err != nil {
+			return err
+		}
+		if k, err := Environ.DB.GetKeypairByPublicID(keypair.AuthorityID, keypair.KeyID); err == nil {
+			keypair = k
+		}
+		RecordAudit(approver, sourceIP, AuditActionCreate, AuditEntityKeypair, keypair.ID, nil, keypair)
+		return nil
+
+	case OperationGenerate:
+		if err := GenerateKeypair(op.AuthorityID, op.KeyName); err != nil {
+			return err
+		}
+		keypair := Keypair{AuthorityID: op.AuthorityID, KeyName: op.KeyName}
+		if k, err := Environ.DB.GetKeypairByName(op.AuthorityID, op.KeyName); err == nil {
+			keypair = k
+		}
+		RecordAudit(approver, sourceIP, AuditActionGenerate, AuditEntityKeypair, keypair.ID, nil, keypair)
+		return nil
+
+	case OperationEnable:
+		before, err := Environ.DB.GetKeypair(op.KeypairID)
+		if err != nil {
+			return err
+		}
+		if err := Environ.DB.UpdateAllowedKeypairActive(op.KeypairID, true, approver); err != nil {
+			return err
+		}
+		after := before
+		after.Active = true
+		RecordAudit(approver, sourceIP, AuditActionEnable, AuditEntityKeypair, op.KeypairID, before, after)
+		return nil
+
+	case OperationDelete:
+		before, err := Environ.DB.GetKeypair(op.KeypairID)
+		if err != nil {
+			return err
+		}
+
+		// Remove the signing-key from memory before its record is removed
+		if err := EvictKeypair(op.KeypairID); err != nil {
+			log.Printf("Error evicting the deleted signing-key: %v", err)
+		}
+		if err := Environ.DB.DeleteKeypair(op.KeypairID); err != nil {
+			return err
+		}
+		RecordAudit(approver, sourceIP, AuditActionDelete, AuditEntityKeypair, op.KeypairID, before, nil)
+		return nil
+
+	default:
+		return fmt.Errorf("invalid operation '%s'", op.Operation)
+	}
+}
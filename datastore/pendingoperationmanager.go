@@ -0,0 +1,266 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package datastore
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const createPendingOperationTableSQL = `
+	CREATE TABLE IF NOT EXISTS pendingoperation (
+		id               serial primary key not null,
+		operation        varchar(50) not null,
+		authority_id     varchar(200) not null,
+		key_name         varchar(200) not null default '',
+		keypair_id       int not null default 0,
+		data             text not null default '',
+		status           varchar(20) not null default 'pending',
+		requested_by     varchar(200) not null default '',
+		requested_at     timestamp default current_timestamp,
+		decided_by       varchar(200) not null default '',
+		decided_at       timestamp,
+		message          text not null default ''
+	)
+`
+
+// Indexes
+const createPendingOperationStatusIndexSQL = "CREATE INDEX IF NOT EXISTS pendingoperation_status_idx ON pendingoperation (status)"
+
+const createPendingOperationSQL = `
+	INSERT INTO pendingoperation
+	(operation, authority_id, key_name, keypair_id, data, status, requested_by)
+	VALUES ($1,$2,$3,$4,$5,$6,$7) RETURNING id`
+
+// sqlite3 syntax for creating a pending operation, generating our own ID
+const maxIDPendingOperationSQLite = "SELECT coalesce(max(id), 0)+1 FROM pendingoperation"
+const createPendingOperationSQLite = `
+	INSERT INTO pendingoperation
+	(id, operation, authority_id, key_name, keypair_id, data, status, requested_by)
+	VALUES ($1,$2,$3,$4,$5,$6,$7,$8)`
+
+const getPendingOperationSQL = `
+	SELECT id, operation, authority_id, key_name, keypair_id, data, status, requested_by, requested_at, decided_by, decided_at, message
+	FROM pendingoperation
+	WHERE id=$1`
+
+const listPendingOperationSQL = `
+	SELECT id, operation, authority_id, key_name, keypair_id, data, status, requested_by, requested_at, decided_by, decided_at, message
+	FROM pendingoperation
+	ORDER BY id DESC`
+
+const listPendingOperationForUserSQL = `
+	SELECT o.id, o.operation, o.authority_id, o.key_name, o.keypair_id, o.data, o.status, o.requested_by, o.requested_at, o.decided_by, o.decided_at, o.message
+	FROM pendingoperation o
+	WHERE EXISTS(
+		SELECT * FROM account acc
+		INNER JOIN useraccountlink ua on ua.account_id=acc.id
+		INNER JOIN userinfo u on ua.user_id=u.id
+		WHERE acc.authority_id=o.authority_id and u.username=$1
+	)
+	ORDER BY o.id DESC`
+
+// An operation that is pending or approved is still open, so the same request cannot be made twice
+const countOpenPendingOperationSQL = `
+	SELECT count(*)
+	FROM pendingoperation
+	WHERE operation=$1 AND authority_id=$2 AND key_name=$3 AND keypair_id=$4 AND status IN ('pending','approved')`
+
+const findApprovedOperationSQL = `
+	SELECT id, operation, authority_id, key_name, keypair_id, data, status, requested_by, requested_at, decided_by, decided_at, message
+	FROM pendingoperation
+	WHERE operation=$1 AND authority_id=$2 AND key_name=$3 AND status='approved'
+	ORDER BY id LIMIT 1`
+
+// The status is only changed from the expected status, so two users cannot decide the same operation
+const updatePendingOperationStatusSQL = `
+	UPDATE pendingoperation
+	SET status=$1, decided_by=$2, decided_at=$3, message=$4
+	WHERE id=$5 AND status=$6`
+
+// The sealed signing-key of an import is only replaced while the import is open
+const updateOpenPendingOperationDataSQL = `
+	UPDATE pendingoperation
+	SET data=$1
+	WHERE id=$2 AND status IN ('pending','approved')`
+
+const getPendingOperationDataSQL = "SELECT data FROM pendingoperation WHERE id=$1"
+
+// Sensitive keypair operations that need the approval of a second Superuser
+const (
+	OperationImport   = "import"
+	OperationGenerate = "generate"
+	OperationEnable   = "enable"
+	OperationRegister = "register"
+	OperationDelete   = "delete"
+)
+
+// Status of a pending operation. An approved store registration is carried out by the requester,
+// as the store credentials are not kept in the database
+const (
+	OperationStatusPending  = "pending"
+	OperationStatusApproved = "approved"
+	OperationStatusRejected = "rejected"
+	OperationStatusDone     = "done"
+	OperationStatusFailed   = "failed"
+)
+
+// ErrorOperationNotPending is returned when an operation has already been decided
+var ErrorOperationNotPending = errors.New("the operation is no longer waiting for a decision")
+
+// PendingOperation is a sensitive keypair operation that is waiting for the approval of a second Superuser.
+// The data holds the sealed signing-key of an import, so it is not returned by the API
+type PendingOperation struct {
+	ID          int        `json:"id"`
+	Operation   string     `json:"operation"`
+	AuthorityID string     `json:"authority-id"`
+	KeyName     string     `json:"key-name"`
+	KeypairID   int        `json:"keypair-id"`
+	Data        string     `json:"-"`
+	Status      string     `json:"status"`
+	RequestedBy string     `json:"requested-by"`
+	RequestedAt time.Time  `json:"requested-at"`
+	DecidedBy   string     `json:"decided-by"`
+	DecidedAt   *time.Time `json:"decided-at"`
+	Message     string     `json:"message"`
+}
+
+// CreatePendingOperationTable creates the database table for the pending keypair operations with its indexes
+func (db *DB) CreatePendingOperationTable() error {
+	_, err := db.Exec(createPendingOperationTableSQL)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(createPendingOperationStatusIndexSQL)
+	return err
+}
+
+// CreatePendingOperation stores a new pending operation, unless the same operation is already waiting for a decision
+func (db *DB) CreatePendingOperation(op PendingOperation) (PendingOperation, error) {
+	var count int
+	err := db.QueryRow(countOpenPendingOperationSQL, op.Operation, op.AuthorityID, op.KeyName, op.KeypairID).Scan(&count)
+	if err != nil {
+		return op, fmt.Errorf("error checking the pending operations: %v", err)
+	}
+	if count > 0 {
+		return op, fmt.Errorf("the %s of the signing-key %s/%s is already waiting for approval", op.Operation, op.AuthorityID, op.KeyName)
+	}
+
+	op.Status = OperationStatusPending
+	if InFactory() {
+		// Need to generate our own ID
+		err = db.QueryRow(maxIDPendingOperationSQLite).Scan(&op.ID)
+		if err == nil {
+			_, err = db.Exec(createPendingOperationSQLite, op.ID, op.Operation, op.AuthorityID, op.KeyName, op.KeypairID, op.Data, op.Status, op.RequestedBy)
+		}
+	} else {
+		err = db.QueryRow(createPendingOperationSQL, op.Operation, op.AuthorityID, op.KeyName, op.KeypairID, op.Data, op.Status, op.RequestedBy).Scan(&op.ID)
+	}
+	if err != nil {
+		return op, fmt.Errorf("error creating the pending operation: %v", err)
+	}
+	return db.GetPendingOperation(op.ID)
+}
+
+// GetPendingOperation fetches a pending operation by its ID
+func (db *DB) GetPendingOperation(operationID int) (PendingOperation, error) {
+	op, err := scanPendingOperation(db.QueryRow(getPendingOperationSQL, operationID))
+	if err != nil {
+		return op, fmt.Errorf("error retrieving the pending operation %d: %v", operationID, err)
+	}
+	return op, nil
+}
+
+// FindApprovedOperation looks for an approved operation on a signing-key, that is waiting for the requester
+// to carry it out. The returned operation has a zero ID if there is none
+func (db *DB) FindApprovedOperation(operation, authorityID, keyName string) (PendingOperation, error) {
+	op, err := scanPendingOperation(db.QueryRow(findApprovedOperationSQL, operation, authorityID, keyName))
+	switch {
+	case err == sql.ErrNoRows:
+		return PendingOperation{}, nil
+	case err != nil:
+		return op, fmt.Errorf("error retrieving the approved operations: %v", err)
+	}
+	return op, nil
+}
+
+// UpdatePendingOperationStatus records the decision on an operation, provided that it still has the
+// expected status
+func (db *DB) UpdatePendingOperationStatus(op PendingOperation, fromStatus string) error {
+	result, err := db.Exec(updatePendingOperationStatusSQL, op.Status, op.DecidedBy, utcTime(op.DecidedAt), op.Message, op.ID, fromStatus)
+	if err != nil {
+		return fmt.Errorf("error updating the pending operation %d: %v", op.ID, err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error updating the pending operation %d: %v", op.ID, err)
+	}
+	if rows == 0 {
+		return ErrorOperationNotPending
+	}
+	return nil
+}
+
+func (db *DB) listPendingOperations() ([]PendingOperation, error) {
+	return db.listPendingOperationsFilteredByUser(anyUserFilter)
+}
+
+func (db *DB) listPendingOperationsFilteredByUser(username string) ([]PendingOperation, error) {
+	var (
+		rows *sql.Rows
+		err  error
+	)
+
+	if len(username) == 0 {
+		rows, err = db.Query(listPendingOperationSQL)
+	} else {
+		rows, err = db.Query(listPendingOperationForUserSQL, username)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving the pending operations: %v", err)
+	}
+	defer rows.Close()
+
+	operations := []PendingOperation{}
+	for rows.Next() {
+		op, err := scanPendingOperation(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error retrieving the pending operations: %v", err)
+		}
+		operations = append(operations, op)
+	}
+
+	return operations, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanPendingOperation(row rowScanner) (PendingOperation, error) {
+	op := PendingOperation{}
+	err := row.Scan(&op.ID, &op.Operation, &op.AuthorityID, &op.KeyName, &op.KeypairID, &op.Data, &op.Status,
+		&op.RequestedBy, &op.RequestedAt, &op.DecidedBy, &op.DecidedAt, &op.Message)
+	return op, err
+}
@@ -0,0 +1,135 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package datastore
+
+import (
+	"bytes"
+	"crypto/x509"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+
+	"github.com/CanonicalLtd/serial-vault/config"
+	"github.com/CanonicalLtd/serial-vault/crypt"
+	"github.com/CanonicalLtd/serial-vault/pkcs11"
+	"github.com/CanonicalLtd/serial-vault/service/log"
+	"github.com/snapcore/snapd/asserts"
+)
+
+const defaultPKCS11KeyManager = "serial-vault-pkcs11"
+
+// ErrorKeyNotExportable is returned when a signing-key is held on a PKCS#11 token
+var ErrorKeyNotExportable = errors.New("Signing-keys on a PKCS#11 token cannot be exported")
+
+// PKCS11KeypairOperator is the operator for signing-keys that are held on a PKCS#11 token.
+// The signing-keys are imported into the token as non-extractable keys and signing is done
+// by the token via the key manager command, so the keys are never unsealed into memory.
+// The sealed key that is stored in the database is the label of the key on the token.
+type PKCS11KeypairOperator struct {
+	keyMgrPath string
+	keyMgr     *pkcs11KeypairManager
+}
+
+// pkcs11KeypairManager serializes the access to the external keypair manager,
+// as it caches the keys without locking and the signing requests are concurrent
+type pkcs11KeypairManager struct {
+	mu sync.Mutex
+	*asserts.ExternalKeypairManager
+}
+
+func (km *pkcs11KeypairManager) Get(keyID string) (asserts.PrivateKey, error) {
+	km.mu.Lock()
+	defer km.mu.Unlock()
+	return km.ExternalKeypairManager.Get(keyID)
+}
+
+func (km *pkcs11KeypairManager) GetByName(keyName string) (asserts.PrivateKey, error) {
+	km.mu.Lock()
+	defer km.mu.Unlock()
+	return km.ExternalKeypairManager.GetByName(keyName)
+}
+
+func newPKCS11KeypairOperator(config config.Settings) (*PKCS11KeypairOperator, error) {
+	keyMgrPath := config.PKCS11KeyManager
+	if len(keyMgrPath) == 0 {
+		keyMgrPath = defaultPKCS11KeyManager
+	}
+
+	// The key manager is run by the external keypair manager, inheriting the environment.
+	// Only the path of the settings file is passed, and the key manager reads the token
+	// details from it, so the PIN is not in the environment of the services
+	if len(config.SettingsFile) > 0 {
+		settingsFile, err := filepath.Abs(config.SettingsFile)
+		if err != nil {
+			return nil, err
+		}
+		os.Setenv(pkcs11.EnvSettings, settingsFile)
+	}
+
+	keyMgr, err := asserts.NewExternalKeypairManager(keyMgrPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PKCS11KeypairOperator{keyMgrPath, &pkcs11KeypairManager{ExternalKeypairManager: keyMgr}}, nil
+}
+
+// ImportKeypair imports a signing-key into the PKCS#11 token, labelled with the key ID.
+// The label is returned as the sealed key for storage in the database
+func (p11Store *PKCS11KeypairOperator) ImportKeypair(authorityID, keyID, base64PrivateKey string) (string, error) {
+	rsaKey, errorCode, err := crypt.DeserializeRSAPrivateKey(base64PrivateKey)
+	if err != nil {
+		log.Printf("Error generating the RSA private-key: %v", errorCode)
+		return "", err
+	}
+
+	der := x509.MarshalPKCS1PrivateKey(rsaKey)
+	defer func() {
+		for i := range der {
+			der[i] = 0
+		}
+	}()
+
+	cmd := exec.Command(p11Store.keyMgrPath, "import", "-k", keyID)
+	cmd.Stdin = bytes.NewReader(der)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		log.Printf("Error importing the signing-key into the PKCS#11 token: %s", bytes.TrimSpace(out))
+		return "", err
+	}
+
+	return keyID, nil
+}
+
+// UnsealKeypair checks that the signing-key is on the PKCS#11 token. There is nothing
+// to unseal, as the signing is done by the token
+func (p11Store *PKCS11KeypairOperator) UnsealKeypair(authorityID string, keyID string, label string) error {
+	if len(label) == 0 {
+		label = keyID
+	}
+
+	_, err := p11Store.keyMgr.GetByName(label)
+	if err != nil {
+		log.Printf("Error finding the signing-key on the PKCS#11 token: %v", err)
+	}
+	return err
+}
@@ -0,0 +1,183 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package datastore
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CanonicalLtd/serial-vault/config"
+	"github.com/CanonicalLtd/serial-vault/pkcs11"
+	"github.com/snapcore/snapd/asserts"
+)
+
+// The test binary is also used as the PKCS#11 key manager, with a directory
+// in place of the token
+const envTestKeyManagerDir = "SERIAL_VAULT_TEST_KEYMGR_DIR"
+
+func TestMain(m *testing.M) {
+	if dir := os.Getenv(envTestKeyManagerDir); len(dir) > 0 {
+		if err := pkcs11.Run(dirKeyStore(dir), os.Args[1:], os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// dirKeyStore is a key store that holds the keys as files in a directory
+type dirKeyStore string
+
+func (ks dirKeyStore) KeyLabels() ([]string, error) {
+	files, err := ioutil.ReadDir(string(ks))
+	if err != nil {
+		return nil, err
+	}
+	labels := []string{}
+	for _, f := range files {
+		labels = append(labels, strings.TrimSuffix(f.Name(), ".der"))
+	}
+	return labels, nil
+}
+
+func (ks dirKeyStore) key(label string) (*rsa.PrivateKey, error) {
+	der, err := ioutil.ReadFile(filepath.Join(string(ks), label+".der"))
+	if err != nil {
+		return nil, pkcs11.ErrorKeyNotFound
+	}
+	return x509.ParsePKCS1PrivateKey(der)
+}
+
+func (ks dirKeyStore) PublicKey(label string) (*rsa.PublicKey, error) {
+	key, err := ks.key(label)
+	if err != nil {
+		return nil, err
+	}
+	return &key.PublicKey, nil
+}
+
+func (ks dirKeyStore) Sign(label string, data []byte) ([]byte, error) {
+	key, err := ks.key(label)
+	if err != nil {
+		return nil, err
+	}
+	return rsa.SignPKCS1v15(nil, key, 0, data)
+}
+
+func (ks dirKeyStore) ImportKey(label string, key *rsa.PrivateKey) error {
+	return ioutil.WriteFile(filepath.Join(string(ks), label+".der"), x509.MarshalPKCS1PrivateKey(key), 0600)
+}
+
+func getPKCS11KeyStore(t *testing.T) *KeypairDatabase {
+	t.Setenv(envTestKeyManagerDir, t.TempDir())
+
+	config := config.Settings{KeyStoreType: "pkcs11", PKCS11KeyManager: os.Args[0], PKCS11Token: "serial-vault"}
+	Environ = &Env{Config: config, DB: &MockDB{}}
+
+	keypairDB, err := getKeyStore(config)
+	if err != nil {
+		t.Fatalf("Error setting up the pkcs11 keystore: %v", err)
+	}
+	return keypairDB
+}
+
+func TestPKCS11SettingsEnvironment(t *testing.T) {
+	t.Setenv(envTestKeyManagerDir, t.TempDir())
+	t.Setenv(pkcs11.EnvSettings, "")
+	t.Setenv(pkcs11.EnvPIN, "")
+
+	config := config.Settings{KeyStoreType: "pkcs11", PKCS11KeyManager: os.Args[0], PKCS11PIN: "1234", SettingsFile: "settings.yaml"}
+	if _, err := newPKCS11KeypairOperator(config); err != nil {
+		t.Fatalf("Error setting up the pkcs11 keystore: %v", err)
+	}
+
+	// The key manager reads the PIN from the settings file, so it is not in the environment
+	settingsFile, _ := filepath.Abs("settings.yaml")
+	if os.Getenv(pkcs11.EnvSettings) != settingsFile {
+		t.Errorf("Expected the path of the settings file, got: %s", os.Getenv(pkcs11.EnvSettings))
+	}
+	if len(os.Getenv(pkcs11.EnvPIN)) > 0 {
+		t.Error("Expected the PIN not to be in the environment")
+	}
+}
+
+func TestPKCS11ImportSign(t *testing.T) {
+	keypairDB := getPKCS11KeyStore(t)
+
+	signingKey, err := ioutil.ReadFile("../keystore/TestKey.asc")
+	if err != nil {
+		t.Fatalf("Error reading the signing-key file: %v", err)
+	}
+	base64PrivateKey := base64.StdEncoding.EncodeToString(signingKey)
+
+	privateKey, label, err := keypairDB.ImportSigningKey("System", base64PrivateKey)
+	if err != nil {
+		t.Fatalf("Error importing the signing-key: %v", err)
+	}
+	keyID := privateKey.PublicKey().ID()
+	if label != keyID {
+		t.Errorf("Expected the key ID as the sealed key, got: %s", label)
+	}
+
+	if err = keypairDB.LoadKeypair("System", keyID, label); err != nil {
+		t.Errorf("Error loading the signing-key: %v", err)
+	}
+
+	headers := map[string]interface{}{
+		"authority-id": "System",
+		"series":       "16",
+		"brand-id":     "System",
+		"model":        "alder",
+		"architecture": "amd64",
+		"gadget":       "alder-gadget",
+		"kernel":       "alder-linux",
+		"timestamp":    time.Now().Format(time.RFC3339),
+	}
+	modelAssert, err := keypairDB.SignAssertion(asserts.ModelType, headers, nil, "System", keyID, label)
+	if err != nil {
+		t.Fatalf("Error signing the assertion: %v", err)
+	}
+	if err = asserts.SignatureCheck(modelAssert, privateKey.PublicKey()); err != nil {
+		t.Errorf("Invalid assertion signature: %v", err)
+	}
+}
+
+func TestPKCS11KeyNotFound(t *testing.T) {
+	keypairDB := getPKCS11KeyStore(t)
+
+	if err := keypairDB.LoadKeypair("System", "unknown", "unknown"); err == nil {
+		t.Error("Expected an error loading an unknown signing-key")
+	}
+
+	_, _, err := ReEncryptKeypair(Keypair{AuthorityID: "System", KeyID: "unknown", SealedKey: "unknown"}, "new secret")
+	if err != ErrorKeyNotExportable {
+		t.Errorf("Expected the key not exportable error, got: %v", err)
+	}
+}
@@ -0,0 +1,242 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package datastore
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/CanonicalLtd/serial-vault/config"
+)
+
+func openMigrationTestDatabase(t *testing.T) *DB {
+	dir, err := os.MkdirTemp("", "migration")
+	if err != nil {
+		t.Fatalf("Error creating the database directory: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	sqlDB, err := sql.Open("sqlite3", filepath.Join(dir, "migration.db"))
+	if err != nil {
+		t.Fatalf("Error opening the database: %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	db := &DB{sqlDB}
+	env := Environ
+	Environ = &Env{DB: db, Config: config.Settings{Driver: "sqlite3"}}
+	t.Cleanup(func() { Environ = env })
+
+	return db
+}
+
+func tableExists(t *testing.T, db *DB, name string) bool {
+	var count int
+	if err := db.QueryRow("SELECT count(*) FROM sqlite_master WHERE type='table' AND name=$1", name).Scan(&count); err != nil {
+		t.Fatalf("Error checking the table %s: %v", name, err)
+	}
+	return count > 0
+}
+
+func TestMigrateSchema(t *testing.T) {
+	db := openMigrationTestDatabase(t)
+
+	if err := db.CheckSchema(); err == nil {
+		t.Fatal("Expected an error checking the schema of an empty database")
+	}
+
+	applied, err := db.MigrateSchema(1)
+	if err != nil {
+		t.Fatalf("Error migrating to the baseline: %v", err)
+	}
+	if len(applied) != 1 || applied[0].Version != 1 {
+		t.Errorf("Unexpected migrations applied: %v", applied)
+	}
+	if !tableExists(t, db, "keypair") || tableExists(t, db, "pendingoperation") {
+		t.Error("Expected the baseline tables only")
+	}
+
+	applied, err = db.MigrateSchema(LatestSchemaVersion())
+	if err != nil {
+		t.Fatalf("Error migrating to the latest version: %v", err)
+	}
+	if len(applied) != LatestSchemaVersion()-1 {
+		t.Errorf("Expected %d migrations to be applied, got: %d", LatestSchemaVersion()-1, len(applied))
+	}
+	if !tableExists(t, db, "pendingoperation") {
+		t.Error("Expected the pending operation table to be created")
+	}
+	if err := db.CheckSchema(); err != nil {
+		t.Errorf("Error checking the migrated schema: %v", err)
+	}
+
+	status, err := db.SchemaStatus()
+	if err != nil {
+		t.Fatalf("Error reading the schema status: %v", err)
+	}
+	for _, s := range status {
+		if !s.Applied || s.AppliedAt == nil {
+			t.Errorf("Expected migration %d to be applied: %v", s.Version, s)
+		}
+	}
+
+	// Nothing is left to apply
+	if applied, err = db.MigrateSchema(LatestSchemaVersion()); err != nil || len(applied) != 0 {
+		t.Errorf("Expected no migrations to apply, got: %v, %v", applied, err)
+	}
+}
+
+func TestMigrateSchemaConcurrently(t *testing.T) {
+	db := openMigrationTestDatabase(t)
+	if _, err := db.MigrateSchema(1); err != nil {
+		t.Fatalf("Error migrating to the baseline: %v", err)
+	}
+
+	// Run the migrations from a second connection to the database at the same time
+	var path string
+	if err := db.QueryRow("SELECT file FROM pragma_database_list WHERE name='main'").Scan(&path); err != nil {
+		t.Fatalf("Error reading the database path: %v", err)
+	}
+	dbs := []*DB{}
+	for i := 0; i < 2; i++ {
+		sqlDB, err := sql.Open("sqlite3", sqliteDataSource(path))
+		if err != nil {
+			t.Fatalf("Error opening the database: %v", err)
+		}
+		t.Cleanup(func() { sqlDB.Close() })
+		dbs = append(dbs, &DB{sqlDB})
+	}
+
+	type result struct {
+		applied []Migration
+		err     error
+	}
+	results := make(chan result, len(dbs))
+	for _, d := range dbs {
+		go func(d *DB) {
+			applied, err := d.MigrateSchema(LatestSchemaVersion())
+			results <- result{applied, err}
+		}(d)
+	}
+
+	// Each migration is applied by one of the runs
+	count := 0
+	for range dbs {
+		r := <-results
+		if r.err != nil {
+			t.Fatalf("Error migrating the schema: %v", r.err)
+		}
+		count += len(r.applied)
+	}
+	if count != LatestSchemaVersion()-1 {
+		t.Errorf("Expected %d migrations to be applied, got: %d", LatestSchemaVersion()-1, count)
+	}
+	if err := db.CheckSchema(); err != nil {
+		t.Errorf("Error checking the migrated schema: %v", err)
+	}
+
+	// A run that read the versions before the other applied them skips the migration
+	if applied, err := db.applyMigration(migrations[len(migrations)-1]); err != nil || applied {
+		t.Errorf("Expected the applied migration to be skipped, got: %v, %v", applied, err)
+	}
+}
+
+func TestRollbackSchema(t *testing.T) {
+	db := openMigrationTestDatabase(t)
+
+	if _, err := db.RollbackSchema(); err == nil {
+		t.Error("Expected an error rolling back an empty database")
+	}
+	if _, err := db.MigrateSchema(LatestSchemaVersion()); err != nil {
+		t.Fatalf("Error migrating to the latest version: %v", err)
+	}
+
+	m, err := db.RollbackSchema()
+	if err != nil {
+		t.Fatalf("Error rolling back the schema: %v", err)
+	}
+	if m.Version != LatestSchemaVersion() {
+		t.Errorf("Expected migration %d to be rolled back, got: %d", LatestSchemaVersion(), m.Version)
+	}
+	if tableExists(t, db, "signingquota") {
+		t.Error("Expected the signing quota table to be dropped")
+	}
+	if err := db.CheckSchema(); err == nil {
+		t.Error("Expected an error checking a schema that is out of date")
+	}
+
+	if _, err := db.RollbackSchema(); err != nil {
+		t.Fatalf("Error rolling back the schema: %v", err)
+	}
+	if !tableExists(t, db, "serialrule") || tableExists(t, db, "serialrule_serial") {
+		t.Error("Expected the serial number rule table to be kept")
+	}
+
+	if _, err := db.RollbackSchema(); err != nil {
+		t.Fatalf("Error rolling back the schema: %v", err)
+	}
+	if tableExists(t, db, "audit_log") || !tableExists(t, db, "signinglogchain") {
+		t.Error("Expected the audit log table to be dropped")
+	}
+
+	// Migrate down to the baseline, which cannot be rolled back
+	if _, err := db.MigrateSchema(1); err != nil {
+		t.Fatalf("Error migrating down to the baseline: %v", err)
+	}
+	if tableExists(t, db, "signinglogchain") || tableExists(t, db, "signinglog_chained") || !tableExists(t, db, "signinglog") {
+		t.Error("Expected the signing log chain table to be dropped")
+	}
+	if tableExists(t, db, "keystoreinstance") || !tableExists(t, db, "keypair") {
+		t.Error("Expected the baseline tables only")
+	}
+	if _, err := db.RollbackSchema(); err == nil {
+		t.Error("Expected an error rolling back the baseline")
+	}
+	if _, err := db.MigrateSchema(-1); err == nil {
+		t.Error("Expected an error migrating to an invalid version")
+	}
+}
+
+func TestMigrateExistingSchema(t *testing.T) {
+	db := openLegacyTestDatabase(t)
+
+	env := Environ
+	Environ = &Env{DB: db, Config: config.Settings{Driver: "sqlite3"}}
+	t.Cleanup(func() { Environ = env })
+
+	// A database created before the migrations is adopted by the baseline
+	if _, err := db.MigrateSchema(LatestSchemaVersion()); err != nil {
+		t.Fatalf("Error migrating an existing database: %v", err)
+	}
+	if err := db.CheckSchema(); err != nil {
+		t.Errorf("Error checking the migrated schema: %v", err)
+	}
+
+	// A database that has been migrated by a later version is refused
+	if _, err := db.Exec(createSchemaMigrationSQL, 1000, "Later migration"); err != nil {
+		t.Fatalf("Error recording the migration: %v", err)
+	}
+	if err := db.CheckSchema(); err == nil {
+		t.Error("Expected an error checking a schema from a later version")
+	}
+}
@@ -0,0 +1,368 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package datastore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+)
+
+const createSchemaMigrationsTableSQL = `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version      int primary key not null,
+		description  varchar(200) not null default '',
+		applied_at   timestamp default current_timestamp
+	)
+`
+
+const listSchemaMigrationsSQL = "SELECT version, applied_at FROM schema_migrations ORDER BY version"
+const createSchemaMigrationSQL = "INSERT INTO schema_migrations (version, description) VALUES ($1,$2)"
+const deleteSchemaMigrationSQL = "DELETE FROM schema_migrations WHERE version=$1"
+
+// The version of a migration is recorded unless a concurrent run has recorded it
+var recordSchemaMigrationSQL = map[string]string{
+	postgresDriver: "INSERT INTO schema_migrations (version, description) VALUES ($1,$2) ON CONFLICT (version) DO NOTHING",
+	sqliteDriver:   "INSERT OR IGNORE INTO schema_migrations (version, description) VALUES ($1,$2)",
+}
+
+// schemaMigrationsLockKey is the PostgreSQL advisory lock that is held while the migrations run
+const schemaMigrationsLockKey = 20180501
+const lockSchemaMigrationsSQL = "SELECT pg_advisory_lock($1)"
+const unlockSchemaMigrationsSQL = "SELECT pg_advisory_unlock($1)"
+
+// The database drivers that the migrations hold steps for
+const (
+	postgresDriver = "postgres"
+	sqliteDriver   = "sqlite3"
+)
+
+// Migration is a numbered change to the database schema. The SQL statements that
+// apply and revert the change are held for each database driver
+type Migration struct {
+	Version     int
+	Description string
+	up          map[string][]string
+	down        map[string][]string
+
+	// data brings the existing rows to the new schema, after the statements of the migration
+	// in the same transaction
+	data func(tx *sql.Tx) error
+
+	// apply brings the database to the version outside of a transaction. It is only
+	// used by the baseline, which adopts the databases created before the migrations
+	apply func(db *DB) error
+}
+
+// Reversible checks if the migration can be rolled back
+func (m Migration) Reversible() bool {
+	_, ok := m.down[driverName()]
+	return ok
+}
+
+// MigrationStatus is a schema migration and whether it has been applied to the database
+type MigrationStatus struct {
+	Migration
+	Applied   bool
+	AppliedAt *time.Time
+}
+
+// LatestSchemaVersion is the version of the database schema that the code expects
+func LatestSchemaVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+func driverName() string {
+	if InFactory() {
+		return sqliteDriver
+	}
+	return postgresDriver
+}
+
+// SchemaStatus lists the schema migrations with their state in the database. Migrations
+// that are recorded in the database, but are not known, are listed at the end
+func (db *DB) SchemaStatus() ([]MigrationStatus, error) {
+	applied, err := db.appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	status := []MigrationStatus{}
+	for _, m := range migrations {
+		s := MigrationStatus{Migration: m}
+		if at, ok := applied[m.Version]; ok {
+			s.Applied = true
+			s.AppliedAt = at
+			delete(applied, m.Version)
+		}
+		status = append(status, s)
+	}
+
+	unknown := []int{}
+	for v := range applied {
+		unknown = append(unknown, v)
+	}
+	sort.Ints(unknown)
+	for _, v := range unknown {
+		status = append(status, MigrationStatus{Migration: Migration{Version: v}, Applied: true, AppliedAt: applied[v]})
+	}
+
+	return status, nil
+}
+
+// CheckSchema checks that every schema migration has been applied to the database,
+// and that the database has not been migrated beyond the known migrations
+func (db *DB) CheckSchema() error {
+	status, err := db.SchemaStatus()
+	if err != nil {
+		return fmt.Errorf("error reading the schema migrations: %v", err)
+	}
+
+	pending := 0
+	for _, s := range status {
+		if s.Version > LatestSchemaVersion() {
+			return fmt.Errorf("the database schema has migration %d, which is newer than this version of the serial vault", s.Version)
+		}
+		if !s.Applied {
+			pending++
+		}
+	}
+
+	if pending > 0 {
+		return fmt.Errorf("the database schema is out of date, with %d migration(s) to apply: run 'serial-vault-admin database migrate'", pending)
+	}
+	return nil
+}
+
+// MigrateSchema applies the schema migrations up to the version, in order, or rolls
+// back the migrations above it when the database is at a later version. Each migration
+// runs in its own transaction. The migrations that were applied or rolled back are returned
+func (db *DB) MigrateSchema(version int) ([]Migration, error) {
+	if version < 0 || version > LatestSchemaVersion() {
+		return nil, fmt.Errorf("the schema version must be between 0 and %d", LatestSchemaVersion())
+	}
+
+	done := []Migration{}
+	err := db.lockMigrations(func() error {
+		applied, err := db.appliedMigrations()
+		if err != nil {
+			return err
+		}
+
+		// Roll back the migrations above the version, latest first
+		for i := len(migrations) - 1; i >= 0; i-- {
+			m := migrations[i]
+			if _, ok := applied[m.Version]; !ok || m.Version <= version {
+				continue
+			}
+			rolledBack, err := db.rollbackMigration(m)
+			if err != nil {
+				return err
+			}
+			if rolledBack {
+				done = append(done, m)
+			}
+		}
+
+		// Apply the missing migrations up to the version
+		for _, m := range migrations {
+			if _, ok := applied[m.Version]; ok || m.Version > version {
+				continue
+			}
+			appliedNow, err := db.applyMigration(m)
+			if err != nil {
+				return err
+			}
+			if appliedNow {
+				done = append(done, m)
+			}
+		}
+		return nil
+	})
+
+	return done, err
+}
+
+// RollbackSchema rolls back the latest schema migration that has been applied
+func (db *DB) RollbackSchema() (Migration, error) {
+	var migration Migration
+	err := db.lockMigrations(func() error {
+		applied, err := db.appliedMigrations()
+		if err != nil {
+			return err
+		}
+
+		for i := len(migrations) - 1; i >= 0; i-- {
+			m := migrations[i]
+			if _, ok := applied[m.Version]; ok {
+				migration = m
+				_, err := db.rollbackMigration(m)
+				return err
+			}
+		}
+		return fmt.Errorf("no schema migrations have been applied")
+	})
+
+	return migration, err
+}
+
+// lockMigrations runs the migrations while holding a PostgreSQL advisory lock, so a concurrent
+// run waits for it and then finds the migrations applied. SQLite has no such lock, so each
+// migration records its version as the first step of its transaction instead. That takes the
+// write lock of the database, and a concurrent run skips the migration once it gets the lock
+func (db *DB) lockMigrations(migrate func() error) error {
+	if driverName() != postgresDriver {
+		return migrate()
+	}
+
+	// The advisory lock belongs to the session, so it is taken and released on the same connection
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, lockSchemaMigrationsSQL, schemaMigrationsLockKey); err != nil {
+		return fmt.Errorf("error locking the schema migrations: %v", err)
+	}
+	defer conn.ExecContext(ctx, unlockSchemaMigrationsSQL, schemaMigrationsLockKey)
+
+	return migrate()
+}
+
+// appliedMigrations fetches the versions that have been applied, with their time
+func (db *DB) appliedMigrations() (map[int]*time.Time, error) {
+	_, err := db.Exec(createSchemaMigrationsTableSQL)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(listSchemaMigrationsSQL)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]*time.Time{}
+	for rows.Next() {
+		var (
+			version   int
+			appliedAt *time.Time
+		)
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+
+	return applied, rows.Err()
+}
+
+// applyMigration applies the migration, unless a concurrent run has applied it already
+func (db *DB) applyMigration(m Migration) (bool, error) {
+	if m.apply != nil {
+		if err := m.apply(db); err != nil {
+			return false, fmt.Errorf("error applying migration %d: %v", m.Version, err)
+		}
+		return recordMigration(db.Exec, m)
+	}
+
+	statements, ok := m.up[driverName()]
+	if !ok {
+		return false, fmt.Errorf("migration %d has no steps for the %s database", m.Version, driverName())
+	}
+
+	var applied bool
+	err := db.transaction(func(tx *sql.Tx) error {
+		// Record the version first, so the transaction holds the lock before the schema changes
+		recorded, err := recordMigration(tx.Exec, m)
+		if err != nil || !recorded {
+			return err
+		}
+
+		if err := execStatements(tx, statements); err != nil {
+			return err
+		}
+		if m.data != nil {
+			if err := m.data(tx); err != nil {
+				return err
+			}
+		}
+		applied = true
+		return nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("error applying migration %d: %v", m.Version, err)
+	}
+	return applied, nil
+}
+
+// rollbackMigration rolls back the migration, unless a concurrent run has rolled it back already
+func (db *DB) rollbackMigration(m Migration) (bool, error) {
+	statements, ok := m.down[driverName()]
+	if !ok {
+		return false, fmt.Errorf("migration %d cannot be rolled back", m.Version)
+	}
+
+	var rolledBack bool
+	err := db.transaction(func(tx *sql.Tx) error {
+		// Remove the version first, so the transaction holds the lock before the schema changes
+		result, err := tx.Exec(deleteSchemaMigrationSQL, m.Version)
+		if err != nil {
+			return err
+		}
+		if rows, err := result.RowsAffected(); err != nil || rows == 0 {
+			return err
+		}
+
+		if err := execStatements(tx, statements); err != nil {
+			return err
+		}
+		rolledBack = true
+		return nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("error rolling back migration %d: %v", m.Version, err)
+	}
+	return rolledBack, nil
+}
+
+// recordMigration stores the version of the migration, returning false when it was already stored
+func recordMigration(exec func(query string, args ...interface{}) (sql.Result, error), m Migration) (bool, error) {
+	result, err := exec(recordSchemaMigrationSQL[driverName()], m.Version, m.Description)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+func execStatements(tx *sql.Tx, statements []string) error {
+	for _, s := range statements {
+		if _, err := tx.Exec(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
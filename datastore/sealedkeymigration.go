@@ -0,0 +1,123 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package datastore
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"github.com/CanonicalLtd/serial-vault/crypt"
+)
+
+// ErrorKeystoreNotSealed is returned when the keystore does not seal the signing-keys in the database
+var ErrorKeystoreNotSealed = errors.New("The keystore does not seal the signing-keys in the database")
+
+// SealedKeyMigration is the outcome of re-sealing the signing-key of a keypair. The signing-key
+// of an import that is waiting for approval has the ID of its pending operation
+type SealedKeyMigration struct {
+	Keypair     Keypair
+	Migrated    bool
+	Err         error
+	OperationID int
+}
+
+// MigrateSealedKeypairs re-seals the signing-keys and auth-key settings that are in the legacy
+// unauthenticated format, using the versioned sealed-key envelope. Keypairs that are already in
+// the current format are skipped, so the migration can be repeated after a failure
+func MigrateSealedKeypairs() ([]SealedKeyMigration, error) {
+	if Environ.Config.KeyStoreType != DatabaseStore.Name && Environ.Config.KeyStoreType != TPM20Store.Name {
+		return nil, ErrorKeystoreNotSealed
+	}
+
+	keypairs, err := Environ.DB.ListAllowedKeypairs(User{})
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := []SealedKeyMigration{}
+	for _, k := range keypairs {
+		// The keypair list does not include the sealed key
+		keypair, err := Environ.DB.GetKeypair(k.ID)
+		if err != nil {
+			return migrations, err
+		}
+
+		migrated, err := migrateSealedKeypair(keypair)
+		migrations = append(migrations, SealedKeyMigration{Keypair: keypair, Migrated: migrated, Err: err})
+	}
+	return migrations, nil
+}
+
+func migrateSealedKeypair(keypair Keypair) (bool, error) {
+	if len(keypair.SealedKey) == 0 {
+		return false, nil
+	}
+
+	authKeySetting, err := Environ.DB.GetSetting(crypt.GenerateAuthKey(keypair.AuthorityID, keypair.KeyID))
+	if err != nil {
+		return false, fmt.Errorf("Cannot find the auth-key for the signing-key: %v", err)
+	}
+
+	sealedAuthKey, err := base64.StdEncoding.DecodeString(authKeySetting.Data)
+	if err != nil {
+		return false, fmt.Errorf("Cannot decode the auth-key: %v", err)
+	}
+	sealedSigningKey, err := base64.StdEncoding.DecodeString(keypair.SealedKey)
+	if err != nil {
+		return false, fmt.Errorf("Cannot decode the signing-key: %v", err)
+	}
+
+	if !crypt.IsLegacySealedKey(sealedAuthKey) && !crypt.IsLegacySealedKey(sealedSigningKey) {
+		return false, nil
+	}
+
+	authKey, err := crypt.UnsealKey(sealedAuthKey, Environ.Config.KeyStoreSecret)
+	if err != nil {
+		return false, fmt.Errorf("Cannot unseal the auth-key: %v", err)
+	}
+	base64SigningKey, err := crypt.UnsealKey(sealedSigningKey, string(authKey))
+	if err != nil {
+		return false, fmt.Errorf("Cannot unseal the signing-key: %v", err)
+	}
+
+	// The legacy format is not authenticated, so check the unsealed signing-key
+	// before it is sealed again e.g. in case the keystore secret is wrong
+	if _, _, err = crypt.DeserializePrivateKey(string(base64SigningKey)); err != nil {
+		return false, fmt.Errorf("The unsealed signing-key is invalid, check the keystore secret: %v", err)
+	}
+
+	resealedAuthKey, err := crypt.SealKey(authKey, Environ.Config.KeyStoreSecret)
+	if err != nil {
+		return false, err
+	}
+	resealedSigningKey, err := crypt.SealKey(base64SigningKey, string(authKey))
+	if err != nil {
+		return false, err
+	}
+
+	keypair.SealedKey = base64.StdEncoding.EncodeToString(resealedSigningKey)
+	authKeySetting.Data = base64.StdEncoding.EncodeToString(resealedAuthKey)
+	if err = Environ.DB.UpdateKeypairSealedKey(keypair, authKeySetting); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,179 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package datastore
+
+import (
+	"encoding/base64"
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/CanonicalLtd/serial-vault/config"
+	"github.com/CanonicalLtd/serial-vault/crypt"
+)
+
+// sealedKeyMockDB holds the keypairs and settings, so they can be re-sealed
+type sealedKeyMockDB struct {
+	MockDB
+	keypairs []Keypair
+	settings map[string]Setting
+	updates  int
+}
+
+func (mdb *sealedKeyMockDB) ListAllowedKeypairs(authorization User) ([]Keypair, error) {
+	keypairs := []Keypair{}
+	for _, k := range mdb.keypairs {
+		k.SealedKey = ""
+		keypairs = append(keypairs, k)
+	}
+	return keypairs, nil
+}
+
+func (mdb *sealedKeyMockDB) GetKeypair(keypairID int) (Keypair, error) {
+	for _, k := range mdb.keypairs {
+		if k.ID == keypairID {
+			return k, nil
+		}
+	}
+	return Keypair{}, errors.New("Cannot find the keypair")
+}
+
+func (mdb *sealedKeyMockDB) GetSetting(code string) (Setting, error) {
+	setting, ok := mdb.settings[code]
+	if !ok {
+		return Setting{}, errors.New("Cannot find the setting")
+	}
+	return setting, nil
+}
+
+func (mdb *sealedKeyMockDB) UpdateKeypairSealedKey(keypair Keypair, authKey Setting) error {
+	for i := range mdb.keypairs {
+		if mdb.keypairs[i].ID == keypair.ID {
+			mdb.keypairs[i].SealedKey = keypair.SealedKey
+		}
+	}
+	mdb.settings[authKey.Code] = authKey
+	mdb.updates++
+	return nil
+}
+
+// legacySealedKeypair seals the test signing-key in the legacy format
+func legacySealedKeypair(t *testing.T, mdb *sealedKeyMockDB, id int, keyID, secret string) string {
+	signingKey, err := ioutil.ReadFile("../keystore/TestKey.asc")
+	if err != nil {
+		t.Fatalf("Error reading the signing-key file: %v", err)
+	}
+	base64SigningKey := base64.StdEncoding.EncodeToString(signingKey)
+
+	authKey, err := generateEncryptionKey("System", keyID, secret)
+	if err != nil {
+		t.Fatalf("Error generating the auth-key: %v", err)
+	}
+	sealedSigningKey, err := crypt.EncryptKey(base64SigningKey, authKey)
+	if err != nil {
+		t.Fatalf("Error sealing the signing-key: %v", err)
+	}
+	sealedAuthKey, err := crypt.EncryptKey(authKey, secret)
+	if err != nil {
+		t.Fatalf("Error sealing the auth-key: %v", err)
+	}
+
+	code := crypt.GenerateAuthKey("System", keyID)
+	mdb.settings[code] = Setting{Code: code, Data: base64.StdEncoding.EncodeToString(sealedAuthKey)}
+	mdb.keypairs = append(mdb.keypairs, Keypair{ID: id, AuthorityID: "System", KeyID: keyID, KeyName: keyID, SealedKey: base64.StdEncoding.EncodeToString(sealedSigningKey)})
+	return base64SigningKey
+}
+
+func TestMigrateSealedKeypairs(t *testing.T) {
+	settings := config.Settings{KeyStoreType: "database", KeyStoreSecret: "this needs to be something secure"}
+	mdb := &sealedKeyMockDB{settings: map[string]Setting{}}
+	Environ = &Env{Config: settings, DB: mdb}
+
+	base64SigningKey := legacySealedKeypair(t, mdb, 1, "legacy", settings.KeyStoreSecret)
+	mdb.keypairs = append(mdb.keypairs,
+		Keypair{ID: 2, AuthorityID: "System", KeyID: "no-auth-key", SealedKey: "c2VhbGVk"},
+		Keypair{ID: 3, AuthorityID: "System", KeyID: "filesystem"},
+	)
+
+	migrations, err := MigrateSealedKeypairs()
+	if err != nil {
+		t.Fatalf("Error migrating the keypairs: %v", err)
+	}
+	if len(migrations) != 3 {
+		t.Fatalf("Expected 3 keypairs, got: %d", len(migrations))
+	}
+	if !migrations[0].Migrated || migrations[0].Err != nil {
+		t.Errorf("Expected the legacy keypair to be migrated: %v", migrations[0].Err)
+	}
+	if migrations[1].Migrated || migrations[1].Err == nil {
+		t.Error("Expected an error for the keypair without an auth-key")
+	}
+	if migrations[2].Migrated || migrations[2].Err != nil {
+		t.Errorf("Expected the keypair without a sealed key to be skipped: %v", migrations[2].Err)
+	}
+
+	// The keypair is re-sealed in the envelope and unseals to the same signing-key
+	sealedSigningKey, _ := base64.StdEncoding.DecodeString(mdb.keypairs[0].SealedKey)
+	sealedAuthKey, _ := base64.StdEncoding.DecodeString(mdb.settings["System/legacy"].Data)
+	if crypt.IsLegacySealedKey(sealedSigningKey) || crypt.IsLegacySealedKey(sealedAuthKey) {
+		t.Error("Expected the keypair to be re-sealed in the envelope")
+	}
+	unsealed, err := decryptKeypair("System", "legacy", mdb.keypairs[0].SealedKey)
+	if err != nil {
+		t.Fatalf("Error unsealing the migrated keypair: %v", err)
+	}
+	if string(unsealed) != base64SigningKey {
+		t.Error("The migrated keypair does not unseal to the signing-key")
+	}
+
+	// Migrating again leaves the keypair as it is
+	migrations, err = MigrateSealedKeypairs()
+	if err != nil {
+		t.Fatalf("Error migrating the keypairs: %v", err)
+	}
+	if migrations[0].Migrated || migrations[0].Err != nil || mdb.updates != 1 {
+		t.Errorf("Expected the migrated keypair to be skipped: %v", migrations[0].Err)
+	}
+}
+
+func TestMigrateSealedKeypairsWrongSecret(t *testing.T) {
+	settings := config.Settings{KeyStoreType: "tpm2.0", KeyStoreSecret: "this is not the keystore secret"}
+	mdb := &sealedKeyMockDB{settings: map[string]Setting{}}
+	Environ = &Env{Config: settings, DB: mdb}
+
+	legacySealedKeypair(t, mdb, 1, "legacy", "this needs to be something secure")
+
+	migrations, err := MigrateSealedKeypairs()
+	if err != nil {
+		t.Fatalf("Error migrating the keypairs: %v", err)
+	}
+	if migrations[0].Migrated || migrations[0].Err == nil || mdb.updates != 0 {
+		t.Error("Expected the keypair not to be migrated with the wrong secret")
+	}
+}
+
+func TestMigrateSealedKeypairsNotSealed(t *testing.T) {
+	Environ = &Env{Config: config.Settings{KeyStoreType: "filesystem"}, DB: &MockDB{}}
+
+	if _, err := MigrateSealedKeypairs(); err != ErrorKeystoreNotSealed {
+		t.Errorf("Expected the keystore not sealed error, got: %v", err)
+	}
+}
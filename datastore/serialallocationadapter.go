@@ -0,0 +1,77 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package datastore
+
+import (
+	"fmt"
+	"regexp"
+)
+
+var validSerialPrefixRegexp = regexp.MustCompile(`^[A-Za-z0-9._-]*$`)
+
+// GetAllowedSerialAllocation returns the serial number allocation of a model, if the user has access to the model.
+// The model ID is zero if serial number allocation is not enabled for the model
+func (db *DB) GetAllowedSerialAllocation(modelID int, authorization User) (SerialAllocation, error) {
+	if err := db.checkModelAllowed(modelID, authorization); err != nil {
+		return SerialAllocation{}, err
+	}
+
+	return db.getSerialAllocation(modelID)
+}
+
+// UpdateAllowedSerialAllocation enables or changes the serial number allocation of a model, if the user has access to the model
+func (db *DB) UpdateAllowedSerialAllocation(alloc SerialAllocation, authorization User) error {
+	if alloc.Digits == 0 {
+		alloc.Digits = defaultSerialAllocationDigits
+	}
+
+	if err := validateSerialAllocation(alloc); err != nil {
+		return err
+	}
+
+	if err := db.checkModelAllowed(alloc.ModelID, authorization); err != nil {
+		return err
+	}
+
+	return db.upsertSerialAllocation(alloc)
+}
+
+// DeleteAllowedSerialAllocation disables the serial number allocation of a model, if the user has access to the model
+func (db *DB) DeleteAllowedSerialAllocation(modelID int, authorization User) error {
+	if err := db.checkModelAllowed(modelID, authorization); err != nil {
+		return err
+	}
+
+	return db.deleteSerialAllocation(modelID)
+}
+
+func validateSerialAllocation(alloc SerialAllocation) error {
+	if !validSerialPrefixRegexp.MatchString(alloc.Prefix) {
+		return fmt.Errorf("invalid serial number prefix '%s': only letters, digits, '.', '_' and '-' are allowed", alloc.Prefix)
+	}
+	if alloc.Digits < 1 || alloc.Digits > MaxSerialAllocationDigits {
+		return fmt.Errorf("the number of digits of the serial number must be between 1 and %d", MaxSerialAllocationDigits)
+	}
+	if alloc.Counter < 0 || alloc.Counter > maxSerialAllocationCounter(alloc.Digits) {
+		return fmt.Errorf("the serial number counter %d does not fit in %d digits", alloc.Counter, alloc.Digits)
+	}
+	return nil
+}
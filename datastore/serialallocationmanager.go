@@ -0,0 +1,196 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package datastore
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+const createSerialAllocationTableSQL = `
+	CREATE TABLE IF NOT EXISTS serialallocation (
+		model_id         int primary key references model not null,
+		prefix           varchar(200) not null default '',
+		digits           int not null default 6,
+		checksum         bool not null default false,
+		counter          bigint not null default 0
+	)
+`
+
+const getSerialAllocationSQL = `
+	SELECT model_id, prefix, digits, checksum, counter
+	FROM serialallocation
+	WHERE model_id=$1`
+
+const createSerialAllocationSQL = `
+	INSERT INTO serialallocation
+	(model_id, prefix, digits, checksum, counter)
+	VALUES ($1,$2,$3,$4,$5)`
+
+// The counter is only changed on update if it is moved forwards, so previously allocated serial numbers cannot be reused
+const updateSerialAllocationSQL = `
+	UPDATE serialallocation
+	SET prefix=$1, digits=$2, checksum=$3, counter=CASE WHEN $4 > counter THEN $4 ELSE counter END
+	WHERE model_id=$5`
+
+const incrementSerialAllocationSQL = "UPDATE serialallocation SET counter=counter+1 WHERE model_id=$1"
+const deleteSerialAllocationSQL = "DELETE FROM serialallocation WHERE model_id=$1"
+
+// Limits for the serial number format
+const (
+	MaxSerialAllocationDigits     = 18
+	defaultSerialAllocationDigits = 6
+)
+
+// SerialAllocation holds the format of the serial numbers that the vault assigns for a model,
+// for devices that do not have a serial number at manufacture time.
+// The serial number is the prefix, the zero-padded counter and an optional Luhn check digit
+type SerialAllocation struct {
+	ModelID  int    `json:"modelID"`
+	Prefix   string `json:"prefix"`
+	Digits   int    `json:"digits"`
+	Checksum bool   `json:"checksum"`
+	Counter  int64  `json:"counter"` // the last allocated counter value
+}
+
+// Format generates the serial number for a counter value
+func (alloc SerialAllocation) Format(counter int64) string {
+	number := fmt.Sprintf("%0*d", alloc.Digits, counter)
+	if alloc.Checksum {
+		number += strconv.Itoa(luhnCheckDigit(number))
+	}
+	return alloc.Prefix + number
+}
+
+// luhnCheckDigit calculates the check digit for a string of digits using the Luhn algorithm
+func luhnCheckDigit(digits string) int {
+	sum := 0
+	double := true
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return (10 - sum%10) % 10
+}
+
+// CreateSerialAllocationTable creates the database table for the serial number allocation of the models
+func (db *DB) CreateSerialAllocationTable() error {
+	_, err := db.Exec(createSerialAllocationTableSQL)
+	return err
+}
+
+// AllocateSerial assigns the next serial number for the model and stores the signing log of the
+// device in the same transaction. The counter is incremented in the transaction, so concurrent
+// requests cannot be given the same serial number. The sign function is called with the serial
+// number in the signing log before the log is stored, and the serial number is not used up if
+// it fails. An empty serial number is returned if serial number allocation is not enabled for
+// the model
+func (db *DB) AllocateSerial(modelID int, signLog SigningLog, sign func(signLog *SigningLog) error) (string, error) {
+	var serial string
+
+	err := db.transaction(func(tx *sql.Tx) error {
+		result, err := tx.Exec(incrementSerialAllocationSQL, modelID)
+		if err != nil {
+			return err
+		}
+		if rows, _ := result.RowsAffected(); rows == 0 {
+			// Allocation is not enabled for the model
+			return nil
+		}
+
+		alloc := SerialAllocation{}
+		err = tx.QueryRow(getSerialAllocationSQL, modelID).Scan(&alloc.ModelID, &alloc.Prefix, &alloc.Digits, &alloc.Checksum, &alloc.Counter)
+		if err != nil {
+			return err
+		}
+		if alloc.Counter > maxSerialAllocationCounter(alloc.Digits) {
+			return fmt.Errorf("all the %d digit serial numbers have been allocated", alloc.Digits)
+		}
+
+		signLog.SerialNumber = alloc.Format(alloc.Counter)
+		if err := sign(&signLog); err != nil {
+			return err
+		}
+		if err := validateSigningLog(signLog); err != nil {
+			return err
+		}
+		if err := chainSigningLogTx(tx, signLog, insertSigningLog(signLog)); err != nil {
+			return err
+		}
+
+		serial = signLog.SerialNumber
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("error allocating the serial number for model %d: %v", modelID, err)
+	}
+	return serial, nil
+}
+
+func maxSerialAllocationCounter(digits int) int64 {
+	return int64(math.Pow10(digits)) - 1
+}
+
+func (db *DB) getSerialAllocation(modelID int) (SerialAllocation, error) {
+	alloc := SerialAllocation{}
+	err := db.QueryRow(getSerialAllocationSQL, modelID).Scan(&alloc.ModelID, &alloc.Prefix, &alloc.Digits, &alloc.Checksum, &alloc.Counter)
+	if err != nil && err != sql.ErrNoRows {
+		return alloc, fmt.Errorf("error retrieving the serial number allocation of model %d: %v", modelID, err)
+	}
+	return alloc, nil
+}
+
+// upsertSerialAllocation creates or updates the serial number format of the model
+func (db *DB) upsertSerialAllocation(alloc SerialAllocation) error {
+	err := db.transaction(func(tx *sql.Tx) error {
+		result, err := tx.Exec(updateSerialAllocationSQL, alloc.Prefix, alloc.Digits, alloc.Checksum, alloc.Counter, alloc.ModelID)
+		if err != nil {
+			return err
+		}
+		if rows, _ := result.RowsAffected(); rows > 0 {
+			return nil
+		}
+
+		_, err = tx.Exec(createSerialAllocationSQL, alloc.ModelID, alloc.Prefix, alloc.Digits, alloc.Checksum, alloc.Counter)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("error saving the serial number allocation of model %d: %v", alloc.ModelID, err)
+	}
+	return nil
+}
+
+func (db *DB) deleteSerialAllocation(modelID int) error {
+	_, err := db.Exec(deleteSerialAllocationSQL, modelID)
+	if err != nil {
+		return fmt.Errorf("error deleting the serial number allocation of model %d: %v", modelID, err)
+	}
+	return nil
+}
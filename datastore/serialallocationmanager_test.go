@@ -0,0 +1,119 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package datastore
+
+import (
+	"errors"
+	"testing"
+
+	check "gopkg.in/check.v1"
+)
+
+type serialAllocationSuite struct{}
+
+var _ = check.Suite(&serialAllocationSuite{})
+
+func (s *serialAllocationSuite) TestSerialAllocationFormat(c *check.C) {
+	tests := []struct {
+		alloc   SerialAllocation
+		counter int64
+		serial  string
+	}{
+		{SerialAllocation{Prefix: "A", Digits: 6}, 1, "A000001"},
+		{SerialAllocation{Prefix: "A", Digits: 6, Checksum: true}, 1, "A0000018"},
+		{SerialAllocation{Prefix: "", Digits: 10, Checksum: true}, 7992739871, "79927398713"},
+		{SerialAllocation{Prefix: "B-", Digits: 3}, 12345, "B-12345"},
+		{SerialAllocation{Prefix: "", Digits: 1, Checksum: true}, 0, "00"},
+	}
+
+	for _, t := range tests {
+		c.Assert(t.alloc.Format(t.counter), check.Equals, t.serial)
+	}
+}
+
+func (s *serialAllocationSuite) TestLuhnCheckDigit(c *check.C) {
+	c.Assert(luhnCheckDigit("7992739871"), check.Equals, 3)
+	c.Assert(luhnCheckDigit("000001"), check.Equals, 8)
+	c.Assert(luhnCheckDigit("0"), check.Equals, 0)
+}
+
+func (s *serialAllocationSuite) TestValidateSerialAllocation(c *check.C) {
+	tests := []struct {
+		alloc SerialAllocation
+		valid bool
+	}{
+		{SerialAllocation{Prefix: "A", Digits: 6}, true},
+		{SerialAllocation{Prefix: "", Digits: 18, Counter: 999999999999999999}, true},
+		{SerialAllocation{Prefix: "A B", Digits: 6}, false},
+		{SerialAllocation{Prefix: "A", Digits: 0}, false},
+		{SerialAllocation{Prefix: "A", Digits: 19}, false},
+		{SerialAllocation{Prefix: "A", Digits: 2, Counter: 100}, false},
+		{SerialAllocation{Prefix: "A", Digits: 2, Counter: -1}, false},
+	}
+
+	for _, t := range tests {
+		err := validateSerialAllocation(t.alloc)
+		c.Assert(err == nil, check.Equals, t.valid, check.Commentf("%v: %v", t.alloc, err))
+	}
+}
+
+func TestAllocateSerialSQLite(t *testing.T) {
+	db := openMigrationTestDatabase(t)
+	if _, err := db.MigrateSchema(LatestSchemaVersion()); err != nil {
+		t.Fatalf("Error migrating the database: %v", err)
+	}
+	if err := db.upsertSerialAllocation(SerialAllocation{ModelID: 1, Prefix: "A", Digits: 6, Checksum: true}); err != nil {
+		t.Fatalf("Error enabling the serial number allocation: %v", err)
+	}
+	signLog := SigningLog{Make: "system", Model: "alder", Fingerprint: "fingerprint"}
+
+	// A rejected request does not use up the serial number
+	_, err := db.AllocateSerial(1, signLog, func(signLog *SigningLog) error {
+		return errors.New("MOCK signing error")
+	})
+	if err == nil {
+		t.Fatal("Expected an error when the signing fails")
+	}
+	if alloc, _ := db.getSerialAllocation(1); alloc.Counter != 0 {
+		t.Errorf("Expected the counter to be unchanged, got: %d", alloc.Counter)
+	}
+
+	serial, err := db.AllocateSerial(1, signLog, func(signLog *SigningLog) error {
+		signLog.Revision = 1
+		return nil
+	})
+	if err != nil || serial != "A0000018" {
+		t.Fatalf("Expected the first serial number, got: %s, %v", serial, err)
+	}
+	signLog.SerialNumber = serial
+	if exists, _, err := db.CheckForDuplicate(&signLog); err != nil || !exists {
+		t.Errorf("Expected the signing log to be stored with the serial number: %v", err)
+	}
+
+	// No serial number is allocated when allocation is not enabled for the model
+	serial, err = db.AllocateSerial(2, signLog, func(signLog *SigningLog) error {
+		t.Error("Expected the assertion not to be signed")
+		return nil
+	})
+	if err != nil || serial != "" {
+		t.Errorf("Expected no serial number, got: %s, %v", serial, err)
+	}
+}
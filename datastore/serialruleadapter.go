@@ -0,0 +1,113 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package datastore
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ListAllowedSerialRules returns the serial number rules of a model, if the user has access to the model
+func (db *DB) ListAllowedSerialRules(modelID int, authorization User) ([]SerialRule, error) {
+	if err := db.checkModelAllowed(modelID, authorization); err != nil {
+		return nil, err
+	}
+
+	return db.listSerialRules(modelID)
+}
+
+// ListAllAllowedSerialRules lists the serial number rules of the models that the user can access
+func (db *DB) ListAllAllowedSerialRules(authorization User) ([]SerialRule, error) {
+	switch authorization.Role {
+	case Invalid: // Authentication is disabled
+		fallthrough
+	case Superuser:
+		return db.listAllSerialRules()
+	case SyncUser:
+		fallthrough
+	case Admin:
+		return db.listAllSerialRulesFilteredByUser(authorization.Username)
+	default:
+		return []SerialRule{}, nil
+	}
+}
+
+// CreateAllowedSerialRules adds serial number rules to a model, if the user has access to the model
+func (db *DB) CreateAllowedSerialRules(modelID int, rules []SerialRule, authorization User) error {
+	if len(rules) == 0 {
+		return errors.New("no serial number rules supplied")
+	}
+
+	for _, rule := range rules {
+		if err := validateSerialRule(rule); err != nil {
+			return err
+		}
+	}
+
+	if err := db.checkModelAllowed(modelID, authorization); err != nil {
+		return err
+	}
+
+	return db.createSerialRules(modelID, rules)
+}
+
+// DeleteAllowedSerialRule removes a serial number rule from a model, if the user has access to the model
+func (db *DB) DeleteAllowedSerialRule(modelID, ruleID int, authorization User) error {
+	if err := db.checkModelAllowed(modelID, authorization); err != nil {
+		return err
+	}
+
+	return db.deleteSerialRule(modelID, ruleID)
+}
+
+func (db *DB) checkModelAllowed(modelID int, authorization User) error {
+	model, err := db.GetAllowedModel(modelID, authorization)
+	if err != nil || model.ID == 0 {
+		return errors.New("the model does not exist or the user does not have permissions to it")
+	}
+	return nil
+}
+
+func validateSerialRule(rule SerialRule) error {
+	errTemplate := "invalid serial number rule %s: %v"
+
+	switch rule.RuleType {
+	case SerialRuleSerial:
+		if err := validateNotEmpty("Serial-number", rule.Value); err != nil {
+			return fmt.Errorf(errTemplate, rule.Value, err)
+		}
+	case SerialRuleRange:
+		if rule.RangeStart < 0 || rule.RangeEnd < rule.RangeStart {
+			return fmt.Errorf(errTemplate, rule.Value, fmt.Sprintf("the range %d-%d is invalid", rule.RangeStart, rule.RangeEnd))
+		}
+	case SerialRulePattern:
+		if err := validateNotEmpty("Pattern", rule.Value); err != nil {
+			return fmt.Errorf(errTemplate, rule.Value, err)
+		}
+		if _, err := compileSerialPattern(rule.Value); err != nil {
+			return fmt.Errorf(errTemplate, rule.Value, err)
+		}
+	default:
+		return fmt.Errorf("invalid serial number rule type '%s': must be one of '%s', '%s' or '%s'", rule.RuleType, SerialRuleSerial, SerialRuleRange, SerialRulePattern)
+	}
+
+	return nil
+}
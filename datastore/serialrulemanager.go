@@ -0,0 +1,358 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package datastore
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const createSerialRuleTableSQL = `
+	CREATE TABLE IF NOT EXISTS serialrule (
+		id               serial primary key not null,
+		model_id         int references model not null,
+		rule_type        varchar(20) not null,
+		value            varchar(200) not null,
+		range_start      bigint not null default 0,
+		range_end        bigint not null default 0
+	)
+`
+
+// The serial type does not generate the ID in SQLite, so the factory table is rebuilt
+// with an integer primary key
+var rebuildSerialRuleTableSQLite = []string{
+	"ALTER TABLE serialrule RENAME TO serialrule_serial",
+	`CREATE TABLE serialrule (
+		id               integer primary key autoincrement not null,
+		model_id         int references model not null,
+		rule_type        varchar(20) not null,
+		value            varchar(200) not null,
+		range_start      bigint not null default 0,
+		range_end        bigint not null default 0
+	)`,
+	`INSERT INTO serialrule (id, model_id, rule_type, value, range_start, range_end)
+	 SELECT id, model_id, rule_type, value, range_start, range_end FROM serialrule_serial`,
+	"DROP TABLE serialrule_serial",
+	createSerialRuleModelIndexSQL,
+}
+
+// Indexes
+const createSerialRuleModelIndexSQL = "CREATE INDEX IF NOT EXISTS serialrule_model_idx ON serialrule (model_id, value)"
+
+const createSerialRuleSQL = `
+	INSERT INTO serialrule
+	(model_id, rule_type, value, range_start, range_end)
+	VALUES ($1,$2,$3,$4,$5)`
+
+const listSerialRuleSQL = `
+	SELECT id, model_id, rule_type, value, range_start, range_end
+	FROM serialrule
+	WHERE model_id=$1
+	ORDER BY id`
+
+const listSerialRuleMatchersSQL = `
+	SELECT id, model_id, rule_type, value, range_start, range_end
+	FROM serialrule
+	WHERE model_id=$1 AND rule_type<>'serial'`
+
+const listAllSerialRuleSQL = `
+	SELECT id, model_id, rule_type, value, range_start, range_end
+	FROM serialrule
+	ORDER BY id`
+
+const listAllSerialRuleForUserSQL = `
+	SELECT r.id, r.model_id, r.rule_type, r.value, r.range_start, r.range_end
+	FROM serialrule r
+	INNER JOIN model m on m.id=r.model_id
+	INNER JOIN account acc on acc.authority_id=m.brand_id
+	INNER JOIN useraccountlink ua on ua.account_id=acc.id
+	INNER JOIN userinfo u on ua.user_id=u.id
+	WHERE u.username=$1
+	ORDER BY r.id`
+
+const countSerialRuleSQL = "SELECT COUNT(*) FROM serialrule WHERE model_id=$1"
+
+const checkSerialRuleSerialSQL = `
+	SELECT EXISTS(
+		SELECT * FROM serialrule WHERE model_id=$1 AND rule_type='serial' AND value=$2
+	)`
+
+const deleteSerialRuleSQL = "DELETE FROM serialrule WHERE id=$1 AND model_id=$2"
+const deleteSerialRulesForModelSQL = "DELETE FROM serialrule WHERE model_id=$1"
+
+// The factory replaces its rules with the ones from the cloud
+const syncDeleteSerialRuleSQL = "DELETE FROM serialrule"
+const syncInsertSerialRuleSQL = `
+	INSERT INTO serialrule
+	(id, model_id, rule_type, value, range_start, range_end)
+	VALUES ($1,$2,$3,$4,$5,$6)`
+
+// Types of serial number rule
+const (
+	SerialRuleSerial  = "serial"  // an explicit serial number
+	SerialRuleRange   = "range"   // a numeric range, with an optional prefix
+	SerialRulePattern = "pattern" // a regular expression that must match the whole serial number
+)
+
+// SerialRule holds a rule for the serial numbers that are allowed to be signed for a model.
+// If a model has no rules, any serial number is allowed
+type SerialRule struct {
+	ID         int    `json:"id"`
+	ModelID    int    `json:"modelID"`
+	RuleType   string `json:"type"`
+	Value      string `json:"value"` // the serial number, the prefix of the range or the regular expression
+	RangeStart int64  `json:"start"`
+	RangeEnd   int64  `json:"end"`
+
+	pattern *regexp.Regexp // the compiled pattern of a pattern rule, set when the rule is loaded
+}
+
+// maxSerialPatterns bounds the compiled patterns that are cached. The patterns come from the
+// rules of the admins, so the cache is cleared when it is full rather than growing with every
+// pattern that has been used
+const maxSerialPatterns = 1000
+
+// serialPatterns holds the compiled patterns of the rules, so a pattern is compiled once
+// instead of for every serial-request
+var serialPatterns = struct {
+	sync.RWMutex
+	compiled map[string]*regexp.Regexp
+}{compiled: map[string]*regexp.Regexp{}}
+
+// Matches checks if the serial number is allowed by the rule
+func (rule SerialRule) Matches(serial string) bool {
+	switch rule.RuleType {
+	case SerialRuleSerial:
+		return serial == rule.Value
+
+	case SerialRuleRange:
+		if !strings.HasPrefix(serial, rule.Value) {
+			return false
+		}
+		digits := strings.TrimPrefix(serial, rule.Value)
+		if len(digits) == 0 || strings.TrimLeft(digits, "0123456789") != "" {
+			return false
+		}
+		number, err := strconv.ParseInt(digits, 10, 64)
+		if err != nil {
+			return false
+		}
+		return number >= rule.RangeStart && number <= rule.RangeEnd
+
+	case SerialRulePattern:
+		re := rule.pattern
+		if re == nil {
+			var err error
+			if re, err = serialPattern(rule.Value); err != nil {
+				return false
+			}
+		}
+		return re.MatchString(serial)
+	}
+
+	return false
+}
+
+// compileSerialPattern anchors the pattern, so it has to match the whole serial number
+func compileSerialPattern(pattern string) (*regexp.Regexp, error) {
+	return regexp.Compile("^(?:" + pattern + ")$")
+}
+
+// serialPattern returns the compiled pattern from the cache, compiling it the first time
+func serialPattern(pattern string) (*regexp.Regexp, error) {
+	serialPatterns.RLock()
+	re, ok := serialPatterns.compiled[pattern]
+	serialPatterns.RUnlock()
+	if ok {
+		return re, nil
+	}
+
+	re, err := compileSerialPattern(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	serialPatterns.Lock()
+	if len(serialPatterns.compiled) >= maxSerialPatterns {
+		serialPatterns.compiled = map[string]*regexp.Regexp{}
+	}
+	serialPatterns.compiled[pattern] = re
+	serialPatterns.Unlock()
+	return re, nil
+}
+
+// CreateSerialRuleTable creates the database table for the serial number rules
+func (db *DB) CreateSerialRuleTable() error {
+	_, err := db.Exec(createSerialRuleTableSQL)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(createSerialRuleModelIndexSQL)
+	return err
+}
+
+// CheckSerialAllowed verifies that the serial number is allowed by the rules of the model
+func (db *DB) CheckSerialAllowed(modelID int, serial string) (bool, error) {
+	var count int
+	err := db.QueryRow(countSerialRuleSQL, modelID).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("error checking the serial number rules for model %d: %v", modelID, err)
+	}
+	if count == 0 {
+		// No rules, so any serial number is allowed
+		return true, nil
+	}
+
+	// Check the explicit serial numbers first, as there may be a lot of them
+	var found bool
+	err = db.QueryRow(checkSerialRuleSerialSQL, modelID, serial).Scan(&found)
+	if err != nil {
+		return false, fmt.Errorf("error checking the serial number rules for model %d: %v", modelID, err)
+	}
+	if found {
+		return true, nil
+	}
+
+	// Check the ranges and patterns
+	rows, err := db.Query(listSerialRuleMatchersSQL, modelID)
+	if err != nil {
+		return false, fmt.Errorf("error checking the serial number rules for model %d: %v", modelID, err)
+	}
+	defer rows.Close()
+
+	rules, err := db.rowsToSerialRules(rows)
+	if err != nil {
+		return false, err
+	}
+
+	for _, rule := range rules {
+		if rule.Matches(serial) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// SyncSerialRules replaces the rules in the factory database with the ones from the cloud,
+// so rules that have been removed in the cloud are also removed
+func (db *DB) SyncSerialRules(rules []SerialRule) error {
+	return db.transaction(func(tx *sql.Tx) error {
+		_, err := tx.Exec(syncDeleteSerialRuleSQL)
+		if err != nil {
+			return fmt.Errorf("error removing the serial number rules: %v", err)
+		}
+
+		for _, rule := range rules {
+			_, err = tx.Exec(syncInsertSerialRuleSQL, rule.ID, rule.ModelID, rule.RuleType, rule.Value, rule.RangeStart, rule.RangeEnd)
+			if err != nil {
+				return fmt.Errorf("error syncing the serial number rule %d: %v", rule.ID, err)
+			}
+		}
+		return nil
+	})
+}
+
+func (db *DB) listAllSerialRules() ([]SerialRule, error) {
+	return db.listAllSerialRulesFilteredByUser(anyUserFilter)
+}
+
+func (db *DB) listAllSerialRulesFilteredByUser(username string) ([]SerialRule, error) {
+	var (
+		rows *sql.Rows
+		err  error
+	)
+
+	if len(username) == 0 {
+		rows, err = db.Query(listAllSerialRuleSQL)
+	} else {
+		rows, err = db.Query(listAllSerialRuleForUserSQL, username)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving serial number rules: %v", err)
+	}
+	defer rows.Close()
+
+	return db.rowsToSerialRules(rows)
+}
+
+func (db *DB) listSerialRules(modelID int) ([]SerialRule, error) {
+	rows, err := db.Query(listSerialRuleSQL, modelID)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving serial number rules: %v", err)
+	}
+	defer rows.Close()
+
+	return db.rowsToSerialRules(rows)
+}
+
+// createSerialRules adds the rules for the model in a single transaction
+func (db *DB) createSerialRules(modelID int, rules []SerialRule) error {
+	return db.transaction(func(tx *sql.Tx) error {
+		for _, rule := range rules {
+			_, err := tx.Exec(createSerialRuleSQL, modelID, rule.RuleType, rule.Value, rule.RangeStart, rule.RangeEnd)
+			if err != nil {
+				return fmt.Errorf("error creating the serial number rule (%s, %s): %v", rule.RuleType, rule.Value, err)
+			}
+		}
+		return nil
+	})
+}
+
+func (db *DB) deleteSerialRule(modelID, ruleID int) error {
+	_, err := db.Exec(deleteSerialRuleSQL, ruleID, modelID)
+	if err != nil {
+		return fmt.Errorf("error deleting the serial number rule %d: %v", ruleID, err)
+	}
+	return nil
+}
+
+func (db *DB) deleteSerialRulesForModel(modelID int) error {
+	_, err := db.Exec(deleteSerialRulesForModelSQL, modelID)
+	if err != nil {
+		return fmt.Errorf("error deleting the serial number rules of model %d: %v", modelID, err)
+	}
+	return nil
+}
+
+func (db *DB) rowsToSerialRules(rows *sql.Rows) ([]SerialRule, error) {
+	rules := []SerialRule{}
+
+	for rows.Next() {
+		rule := SerialRule{}
+		err := rows.Scan(&rule.ID, &rule.ModelID, &rule.RuleType, &rule.Value, &rule.RangeStart, &rule.RangeEnd)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning for serial number rule: %v", err)
+		}
+		if rule.RuleType == SerialRulePattern {
+			// An invalid pattern matches no serial numbers
+			rule.pattern, _ = serialPattern(rule.Value)
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
@@ -0,0 +1,135 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package datastore
+
+import (
+	"fmt"
+	"testing"
+
+	check "gopkg.in/check.v1"
+)
+
+type serialRuleSuite struct{}
+
+var _ = check.Suite(&serialRuleSuite{})
+
+func (s *serialRuleSuite) TestSerialRuleMatches(c *check.C) {
+	tests := []struct {
+		rule   SerialRule
+		serial string
+		match  bool
+	}{
+		{SerialRule{RuleType: SerialRuleSerial, Value: "A123456L"}, "A123456L", true},
+		{SerialRule{RuleType: SerialRuleSerial, Value: "A123456L"}, "A123456", false},
+		{SerialRule{RuleType: SerialRuleRange, RangeStart: 100, RangeEnd: 200}, "150", true},
+		{SerialRule{RuleType: SerialRuleRange, RangeStart: 100, RangeEnd: 200}, "0000200", true},
+		{SerialRule{RuleType: SerialRuleRange, RangeStart: 100, RangeEnd: 200}, "201", false},
+		{SerialRule{RuleType: SerialRuleRange, RangeStart: 100, RangeEnd: 200}, "15a", false},
+		{SerialRule{RuleType: SerialRuleRange, Value: "A", RangeStart: 1000, RangeEnd: 1999}, "A1000", true},
+		{SerialRule{RuleType: SerialRuleRange, Value: "A", RangeStart: 1000, RangeEnd: 1999}, "B1000", false},
+		{SerialRule{RuleType: SerialRuleRange, Value: "A", RangeStart: 1000, RangeEnd: 1999}, "A", false},
+		{SerialRule{RuleType: SerialRuleRange, Value: "A", RangeStart: 1000, RangeEnd: 1999}, "A-1000", false},
+		{SerialRule{RuleType: SerialRulePattern, Value: "B[0-9]{6}L"}, "B123456L", true},
+		{SerialRule{RuleType: SerialRulePattern, Value: "B[0-9]{6}L"}, "XB123456L", false},
+		{SerialRule{RuleType: SerialRulePattern, Value: "B[0-9]{6}L|C.*"}, "C1", true},
+		{SerialRule{RuleType: SerialRulePattern, Value: "B[0-9]{6}L|C.*"}, "B1C", false},
+		{SerialRule{RuleType: SerialRulePattern, Value: "[invalid"}, "[invalid", false},
+		{SerialRule{RuleType: "invalid", Value: "A123456L"}, "A123456L", false},
+	}
+
+	for _, t := range tests {
+		c.Assert(t.rule.Matches(t.serial), check.Equals, t.match, check.Commentf("%v: %s", t.rule, t.serial))
+	}
+}
+
+func (s *serialRuleSuite) TestValidateSerialRule(c *check.C) {
+	tests := []struct {
+		rule  SerialRule
+		valid bool
+	}{
+		{SerialRule{RuleType: SerialRuleSerial, Value: "A123456L"}, true},
+		{SerialRule{RuleType: SerialRuleSerial, Value: " "}, false},
+		{SerialRule{RuleType: SerialRuleRange, RangeStart: 100, RangeEnd: 100}, true},
+		{SerialRule{RuleType: SerialRuleRange, RangeStart: 200, RangeEnd: 100}, false},
+		{SerialRule{RuleType: SerialRuleRange, RangeStart: -1, RangeEnd: 100}, false},
+		{SerialRule{RuleType: SerialRulePattern, Value: "B[0-9]{6}L"}, true},
+		{SerialRule{RuleType: SerialRulePattern, Value: "[invalid"}, false},
+		{SerialRule{RuleType: SerialRulePattern, Value: ""}, false},
+		{SerialRule{RuleType: "", Value: "A123456L"}, false},
+	}
+
+	for _, t := range tests {
+		err := validateSerialRule(t.rule)
+		c.Assert(err == nil, check.Equals, t.valid, check.Commentf("%v: %v", t.rule, err))
+	}
+}
+
+func TestSerialRulesSQLite(t *testing.T) {
+	db := openMigrationTestDatabase(t)
+	if _, err := db.MigrateSchema(LatestSchemaVersion()); err != nil {
+		t.Fatalf("Error migrating the database: %v", err)
+	}
+
+	// The IDs of the rules are generated by the factory database
+	rules := []SerialRule{
+		{RuleType: SerialRuleSerial, Value: "A123456L"},
+		{RuleType: SerialRulePattern, Value: "B[0-9]{6}L"},
+	}
+	if err := db.createSerialRules(1, rules); err != nil {
+		t.Fatalf("Error creating the serial number rules: %v", err)
+	}
+	list, err := db.listAllSerialRules()
+	if err != nil || len(list) != 2 || list[0].ID != 1 || list[1].ID != 2 {
+		t.Fatalf("Expected 2 rules with generated IDs, got: %v, %v", list, err)
+	}
+	if list[1].pattern == nil {
+		t.Error("Expected the pattern to be compiled when the rule is loaded")
+	}
+	if allowed, err := db.CheckSerialAllowed(1, "B123456L"); err != nil || !allowed {
+		t.Errorf("Expected the serial number to be allowed: %v", err)
+	}
+
+	// The sync replaces the rules with the ones from the cloud
+	synced := []SerialRule{{ID: 10, ModelID: 1, RuleType: SerialRuleRange, Value: "C", RangeStart: 1, RangeEnd: 9}}
+	if err := db.SyncSerialRules(synced); err != nil {
+		t.Fatalf("Error syncing the serial number rules: %v", err)
+	}
+	if list, err = db.listAllSerialRules(); err != nil || len(list) != 1 || list[0].ID != 10 {
+		t.Errorf("Expected the synced rule only, got: %v, %v", list, err)
+	}
+	if allowed, _ := db.CheckSerialAllowed(1, "B123456L"); allowed {
+		t.Error("Expected the removed rule not to allow the serial number")
+	}
+	if allowed, _ := db.CheckSerialAllowed(1, "C5"); !allowed {
+		t.Error("Expected the synced rule to allow the serial number")
+	}
+}
+
+func (s *serialRuleSuite) TestSerialPatternsBounded(c *check.C) {
+	for i := 0; i <= maxSerialPatterns; i++ {
+		_, err := serialPattern(fmt.Sprintf("B%d[0-9]+", i))
+		c.Assert(err, check.IsNil)
+	}
+
+	serialPatterns.RLock()
+	defer serialPatterns.RUnlock()
+	c.Assert(len(serialPatterns.compiled) <= maxSerialPatterns, check.Equals, true)
+}
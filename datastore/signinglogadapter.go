@@ -19,35 +19,54 @@
 
 package datastore
 
-// ListAllowedSigningLog return signing logs the user is authorized to see
-func (db *DB) ListAllowedSigningLog(authorization User) ([]SigningLog, error) {
+// ListAllowedSigningLogForAccount return a page of the signing logs the user is authorized to see.
+// An empty authority ID searches all the accounts of the user
+func (db *DB) ListAllowedSigningLogForAccount(authorization User, authorityID string, params *SigningLogParams) (SigningLogPage, error) {
 	switch authorization.Role {
 	case Invalid: // Authentication disabled
 		fallthrough
 	case Superuser:
-		return db.listAllSigningLog()
+		return db.listAllSigningLogForAccount(authorityID, params)
 	case SyncUser:
 		fallthrough
 	case Admin:
-		return db.listSigningLogFilteredByUser(authorization.Username)
+		return db.listSigningLogForAccountFilteredByUser(authorization.Username, authorityID, params)
 	default:
-		return []SigningLog{}, nil
+		return SigningLogPage{Logs: []SigningLog{}}, nil
 	}
 }
 
-// ListAllowedSigningLogForAccount return signing logs the user is authorized to see
-func (db *DB) ListAllowedSigningLogForAccount(authorization User, authorityID string, params *SigningLogParams) ([]SigningLog, error) {
+// ExportAllowedSigningLog passes the signing logs the user is authorized to see to the function, in
+// the order they were created. An empty authority ID exports all the accounts of the user
+func (db *DB) ExportAllowedSigningLog(authorization User, authorityID string, params *SigningLogParams, fn func(SigningLog) error) error {
 	switch authorization.Role {
 	case Invalid: // Authentication disabled
 		fallthrough
 	case Superuser:
-		return db.listAllSigningLogForAccount(authorityID, params)
+		return db.exportAllSigningLogForAccount(authorityID, params, fn)
 	case SyncUser:
 		fallthrough
 	case Admin:
-		return db.listSigningLogForAccountFilteredByUser(authorization.Username, authorityID, params)
+		return db.exportSigningLogForAccountFilteredByUser(authorization.Username, authorityID, params, fn)
+	default:
+		return nil
+	}
+}
+
+// ListAllowedSigningLogChainHeads returns the heads of the signing log hash chains of the brands
+// the user is authorized to see. An empty authority ID lists all the accounts of the user
+func (db *DB) ListAllowedSigningLogChainHeads(authorization User, authorityID string) ([]SigningLogChainHead, error) {
+	switch authorization.Role {
+	case Invalid: // Authentication disabled
+		fallthrough
+	case Superuser:
+		return db.listAllSigningLogChainHeads(authorityID)
+	case SyncUser:
+		fallthrough
+	case Admin:
+		return db.listSigningLogChainHeadsFilteredByUser(authorization.Username, authorityID)
 	default:
-		return []SigningLog{}, nil
+		return []SigningLogChainHead{}, nil
 	}
 }
 
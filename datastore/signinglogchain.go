@@ -0,0 +1,460 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package datastore
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/CanonicalLtd/serial-vault/service/log"
+	sq "github.com/Masterminds/squirrel"
+)
+
+// The head of the hash chain of each brand
+const createSigningLogChainTableSQL = `
+	CREATE TABLE IF NOT EXISTS signinglogchain (
+		make      varchar(200) primary key not null,
+		seq       int not null default 0,
+		hash      varchar(64) not null default ''
+	)
+`
+
+// Columns of the signing log for the position and hash in the chain of the brand
+const alterSigningLogAddChainSeqSQL = "ALTER TABLE signinglog ADD COLUMN chain_seq int default 0"
+const alterSigningLogAddHashSQL = "ALTER TABLE signinglog ADD COLUMN hash varchar(64) default ''"
+const createSigningLogChainIndexSQL = "CREATE INDEX IF NOT EXISTS chain_idx ON signinglog (make,chain_seq)"
+
+// The sqlite database cannot drop a column, so the hash chain is removed by copying the signing logs
+// to a table without it. The indexes are dropped with the old table, and created again
+var dropSigningLogChainSQLite = []string{
+	"ALTER TABLE signinglog RENAME TO signinglog_chained",
+	createSigningLogTableSQL,
+	`INSERT INTO signinglog (id, make, model, serial_number, fingerprint, created, revision, synced, rejected)
+	 SELECT id, make, model, serial_number, fingerprint, created, revision, synced, rejected FROM signinglog_chained`,
+	"DROP TABLE signinglog_chained",
+	createSigningLogSerialNumberIndexSQL,
+	createSigningLogFingerprintIndexSQL,
+	createSigningLogCreatedIndexSQL,
+	"DROP TABLE IF EXISTS signinglogchain",
+}
+
+// The head of the chain is created for the first log of a brand, unless a concurrent log has created it
+var startSigningLogChainSQL = map[string]string{
+	postgresDriver: "INSERT INTO signinglogchain (make, seq, hash) VALUES ($1, 0, '') ON CONFLICT (make) DO NOTHING",
+	sqliteDriver:   "INSERT OR IGNORE INTO signinglogchain (make, seq, hash) VALUES ($1, 0, '')",
+}
+
+const incrementSigningLogChainSQL = "UPDATE signinglogchain SET seq=seq+1 WHERE make=$1"
+const createSigningLogChainSQL = "INSERT INTO signinglogchain (make, seq, hash) VALUES ($1, $2, $3)"
+const getSigningLogChainSQL = "SELECT make, seq, hash FROM signinglogchain WHERE make=$1"
+const updateSigningLogChainSQL = "UPDATE signinglogchain SET seq=$1, hash=$2 WHERE make=$3"
+const getSigningLogCreatedSQL = "SELECT created FROM signinglog WHERE id=$1"
+const updateSigningLogChainEntrySQL = "UPDATE signinglog SET chain_seq=$1, hash=$2 WHERE id=$3"
+
+// Queries for the chaining of the logs that were created before the hash chain
+const listSigningLogMakesSQL = "SELECT DISTINCT make FROM signinglog ORDER BY make"
+const listSigningLogUnchainedSQL = `
+	SELECT id, make, model, serial_number, fingerprint, created, revision, rejected
+	FROM signinglog
+	WHERE make=$1 AND id>$2
+	ORDER BY id
+	LIMIT 1000`
+
+// The heads and the logs are read from the same snapshot of the database for verification
+const repeatableReadSQL = "SET TRANSACTION ISOLATION LEVEL REPEATABLE READ"
+
+// signingLogChainColumns are the columns of a signing log that are read to verify the chain
+var signingLogChainColumns = []string{"id", "make", "model", "serial_number", "fingerprint", "created", "revision", "rejected", "chain_seq", "hash"}
+
+// SigningLogChainHead is the latest signing log in the hash chain of a brand
+type SigningLogChainHead struct {
+	Make string `json:"brand"`
+	Seq  int    `json:"seq"`
+	Hash string `json:"hash"`
+}
+
+// SigningLogChainReport is the result of the verification of the hash chain of a brand
+type SigningLogChainReport struct {
+	Make     string   `json:"brand"`
+	Entries  int      `json:"entries"`
+	Head     int      `json:"head"`
+	Problems []string `json:"problems"`
+}
+
+// Valid checks if the chain of the brand has no gaps or changed entries
+func (r SigningLogChainReport) Valid() bool {
+	return len(r.Problems) == 0
+}
+
+// CreateSigningLogChainTable adds the hash chain to the signing log table, and creates the
+// database table for the heads of the chains
+func (db *DB) CreateSigningLogChainTable() error {
+	for _, s := range []string{alterSigningLogAddChainSeqSQL, alterSigningLogAddHashSQL, createSigningLogChainIndexSQL, createSigningLogChainTableSQL} {
+		if _, err := db.Exec(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SigningLogHash is the hash of a signing log in the chain of its brand, which covers the hash of the
+// previous log in the chain. The synced flag is left out, as it is set when a factory log is synced
+func SigningLogHash(prevHash string, signLog SigningLog) string {
+	data, _ := json.Marshal([]interface{}{prevHash, signLog.ChainSeq, signLog.ID, signLog.Make, signLog.Model,
+		signLog.SerialNumber, signLog.Fingerprint, signLog.Created.UTC().Format(time.RFC3339Nano), signLog.Revision, signLog.Rejected})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// chainSigningLog stores a signing log at the head of the hash chain of its brand. The insert
+// function stores the log at the position in the chain, returning its ID
+func (db *DB) chainSigningLog(signLog SigningLog, insert func(tx *sql.Tx, seq int) (int, error)) error {
+	err := db.transaction(func(tx *sql.Tx) error {
+		return chainSigningLogTx(tx, signLog, insert)
+	})
+	if err != nil {
+		log.Printf("Error creating the signing log: %v\n", err)
+	}
+	return err
+}
+
+// chainSigningLogTx stores a signing log at the head of the hash chain of its brand, within a
+// transaction. The head is moved on first, so the logs of the brand that are created at the same
+// time wait for the transaction. The first logs of a brand both create the head, one of them
+// doing nothing, before they move it on in turn
+func chainSigningLogTx(tx *sql.Tx, signLog SigningLog, insert func(tx *sql.Tx, seq int) (int, error)) error {
+	if _, err := tx.Exec(startSigningLogChainSQL[driverName()], signLog.Make); err != nil {
+		return err
+	}
+	_, err := tx.Exec(incrementSigningLogChainSQL, signLog.Make)
+	if err != nil {
+		return err
+	}
+
+	head := SigningLogChainHead{}
+	if err := tx.QueryRow(getSigningLogChainSQL, signLog.Make).Scan(&head.Make, &head.Seq, &head.Hash); err != nil {
+		return err
+	}
+
+	signLog.ChainSeq = head.Seq
+	if signLog.ID, err = insert(tx, head.Seq); err != nil {
+		return err
+	}
+
+	// The hash covers the creation time as it is stored by the database
+	if err := tx.QueryRow(getSigningLogCreatedSQL, signLog.ID).Scan(&signLog.Created); err != nil {
+		return err
+	}
+	signLog.Hash = SigningLogHash(head.Hash, signLog)
+
+	if _, err := tx.Exec(updateSigningLogChainEntrySQL, signLog.ChainSeq, signLog.Hash, signLog.ID); err != nil {
+		return err
+	}
+	_, err = tx.Exec(updateSigningLogChainSQL, signLog.ChainSeq, signLog.Hash, signLog.Make)
+	return err
+}
+
+// chainExistingSigningLogs adds the signing logs that were created before the hash chain to the
+// chain of their brand, in the order they were created. The logs are read in batches, as the
+// database driver cannot update the logs while they are being read
+func chainExistingSigningLogs(tx *sql.Tx) error {
+	brands := []string{}
+	rows, err := tx.Query(listSigningLogMakesSQL)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var brand string
+		if err := rows.Scan(&brand); err != nil {
+			rows.Close()
+			return err
+		}
+		brands = append(brands, brand)
+	}
+	rows.Close()
+
+	for _, brand := range brands {
+		head := SigningLogChainHead{Make: brand}
+		lastID := 0
+
+		for {
+			logs, err := listUnchainedSigningLogs(tx, brand, lastID)
+			if err != nil {
+				return err
+			}
+			if len(logs) == 0 {
+				break
+			}
+
+			for _, l := range logs {
+				l.ChainSeq = head.Seq + 1
+				l.Hash = SigningLogHash(head.Hash, l)
+				if _, err := tx.Exec(updateSigningLogChainEntrySQL, l.ChainSeq, l.Hash, l.ID); err != nil {
+					return err
+				}
+				head.Seq, head.Hash = l.ChainSeq, l.Hash
+				lastID = l.ID
+			}
+		}
+
+		if _, err := tx.Exec(createSigningLogChainSQL, head.Make, head.Seq, head.Hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func listUnchainedSigningLogs(tx *sql.Tx, brand string, fromID int) ([]SigningLog, error) {
+	rows, err := tx.Query(listSigningLogUnchainedSQL, brand, fromID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	logs := []SigningLog{}
+	for rows.Next() {
+		l := SigningLog{}
+		err := rows.Scan(&l.ID, &l.Make, &l.Model, &l.SerialNumber, &l.Fingerprint, &l.Created, &l.Revision, &l.Rejected)
+		if err != nil {
+			return nil, err
+		}
+		logs = append(logs, l)
+	}
+	return logs, rows.Err()
+}
+
+// signingLogChainHeadSQLBuilder builds the query for the heads of the chains of the brands. An empty
+// username does not filter the accounts, and an empty authority ID selects all the accounts
+func signingLogChainHeadSQLBuilder(username, authorityID string) sq.SelectBuilder {
+	sql := sq.Select("make", "seq", "hash").
+		From("signinglogchain s").
+		OrderBy("make").
+		PlaceholderFormat(sq.Dollar)
+
+	if authorityID != "" {
+		sql = sql.Where("make=?", authorityID)
+	}
+
+	if username != "" {
+		nestedBuilder := sq.Select("*").Prefix("EXISTS (").
+			From("account acc").
+			JoinClause("INNER JOIN useraccountlink ua on ua.account_id=acc.id").
+			JoinClause("INNER JOIN userinfo u on ua.user_id=u.id").
+			Where("acc.authority_id=s.make AND u.username=?", username).
+			Suffix(")").PlaceholderFormat(sq.Dollar)
+
+		sql = sql.Where(nestedBuilder)
+	}
+	return sql
+}
+
+// signingLogChainSQLBuilder builds the query for the logs of the chains, in the order of the chain
+func signingLogChainSQLBuilder(authorityID string) sq.SelectBuilder {
+	sql := sq.Select(signingLogChainColumns...).
+		From("signinglog").
+		OrderBy("make", "chain_seq", "id").
+		PlaceholderFormat(sq.Dollar)
+
+	if authorityID != "" {
+		sql = sql.Where("make=?", authorityID)
+	}
+	return sql
+}
+
+func listSigningLogChainHeads(runner sq.BaseRunner, username, authorityID string) ([]SigningLogChainHead, error) {
+	rows, err := signingLogChainHeadSQLBuilder(username, authorityID).RunWith(runner).Query()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	heads := []SigningLogChainHead{}
+	for rows.Next() {
+		head := SigningLogChainHead{}
+		if err := rows.Scan(&head.Make, &head.Seq, &head.Hash); err != nil {
+			return nil, err
+		}
+		heads = append(heads, head)
+	}
+	return heads, rows.Err()
+}
+
+func (db *DB) listAllSigningLogChainHeads(authorityID string) ([]SigningLogChainHead, error) {
+	return db.listSigningLogChainHeadsFilteredByUser(anyUserFilter, authorityID)
+}
+
+func (db *DB) listSigningLogChainHeadsFilteredByUser(username, authorityID string) ([]SigningLogChainHead, error) {
+	heads, err := listSigningLogChainHeads(db, username, authorityID)
+	if err != nil {
+		log.Printf("Error retrieving the signing log chain heads: %v\n", err)
+	}
+	return heads, err
+}
+
+// VerifySigningLogChain checks the hash chain of each brand, or of one brand, for logs that have
+// been changed or removed. The logs are checked as they are read from the database cursor
+func (db *DB) VerifySigningLogChain(authorityID string) ([]SigningLogChainReport, error) {
+	var reports []SigningLogChainReport
+
+	err := db.transaction(func(tx *sql.Tx) error {
+		if !InFactory() {
+			if _, err := tx.Exec(repeatableReadSQL); err != nil {
+				return err
+			}
+		}
+
+		heads, err := listSigningLogChainHeads(tx, anyUserFilter, authorityID)
+		if err != nil {
+			return err
+		}
+
+		rows, err := signingLogChainSQLBuilder(authorityID).RunWith(tx).Query()
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		v := newSigningLogChainVerifier(heads)
+		for rows.Next() {
+			l := SigningLog{}
+			err := rows.Scan(&l.ID, &l.Make, &l.Model, &l.SerialNumber, &l.Fingerprint, &l.Created,
+				&l.Revision, &l.Rejected, &l.ChainSeq, &l.Hash)
+			if err != nil {
+				return err
+			}
+			v.add(l)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		reports = v.finish()
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error verifying the signing log chain: %v\n", err)
+		return nil, fmt.Errorf("Error verifying the signing log chain: %v", err)
+	}
+	return reports, nil
+}
+
+// signingLogChainVerifier follows the chains of the brands through the logs, which are given in the
+// order of the chain of each brand. Only the state of the current brand is held
+type signingLogChainVerifier struct {
+	heads     map[string]SigningLogChainHead
+	reports   []SigningLogChainReport
+	current   *SigningLogChainReport
+	prevSeq   int
+	prevHash  string
+	unchained int
+}
+
+func newSigningLogChainVerifier(heads []SigningLogChainHead) *signingLogChainVerifier {
+	v := &signingLogChainVerifier{heads: map[string]SigningLogChainHead{}}
+	for _, h := range heads {
+		v.heads[h.Make] = h
+	}
+	return v
+}
+
+func (v *signingLogChainVerifier) add(l SigningLog) {
+	if v.current == nil || v.current.Make != l.Make {
+		v.finishBrand()
+		v.current = &SigningLogChainReport{Make: l.Make, Problems: []string{}}
+		v.prevSeq, v.prevHash, v.unchained = 0, "", 0
+	}
+	v.current.Entries++
+
+	switch {
+	case l.ChainSeq == 0:
+		v.unchained++
+		return
+	case l.ChainSeq <= v.prevSeq:
+		v.problem("entry %d is repeated (id %d)", l.ChainSeq, l.ID)
+		return
+	case l.ChainSeq > v.prevSeq+1:
+		// The hash of the missing entry is not known, so the chain is picked up from this entry
+		v.missing(v.prevSeq+1, l.ChainSeq-1)
+	case SigningLogHash(v.prevHash, l) != l.Hash:
+		v.problem("entry %d (id %d) has been changed", l.ChainSeq, l.ID)
+	}
+	v.prevSeq, v.prevHash = l.ChainSeq, l.Hash
+}
+
+// finishBrand compares the end of the chain of the current brand with its head
+func (v *signingLogChainVerifier) finishBrand() {
+	if v.current == nil {
+		return
+	}
+
+	if v.unchained > 0 {
+		v.problem("%d entries are not in the chain", v.unchained)
+	}
+
+	head, ok := v.heads[v.current.Make]
+	delete(v.heads, v.current.Make)
+	switch {
+	case !ok:
+		v.problem("the head of the chain is missing")
+	case head.Seq > v.prevSeq:
+		v.missing(v.prevSeq+1, head.Seq)
+	case head.Seq < v.prevSeq:
+		v.problem("the head of the chain is at entry %d, before the last entry %d", head.Seq, v.prevSeq)
+	case head.Hash != v.prevHash:
+		v.problem("the head of the chain does not match entry %d", head.Seq)
+	}
+	v.current.Head = head.Seq
+
+	v.reports = append(v.reports, *v.current)
+	v.current = nil
+}
+
+// finish completes the reports, including the brands that have a head but no logs
+func (v *signingLogChainVerifier) finish() []SigningLogChainReport {
+	v.finishBrand()
+
+	for _, head := range v.heads {
+		v.current = &SigningLogChainReport{Make: head.Make, Problems: []string{}}
+		v.prevSeq, v.prevHash, v.unchained = 0, "", 0
+		v.finishBrand()
+	}
+
+	reports := append([]SigningLogChainReport{}, v.reports...)
+	sort.Slice(reports, func(i, j int) bool { return reports[i].Make < reports[j].Make })
+	return reports
+}
+
+func (v *signingLogChainVerifier) missing(from, to int) {
+	if from == to {
+		v.problem("entry %d is missing", from)
+		return
+	}
+	v.problem("entries %d to %d are missing", from, to)
+}
+
+func (v *signingLogChainVerifier) problem(format string, a ...interface{}) {
+	v.current.Problems = append(v.current.Problems, fmt.Sprintf(format, a...))
+}
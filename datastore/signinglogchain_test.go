@@ -0,0 +1,271 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package datastore
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CanonicalLtd/serial-vault/config"
+)
+
+// setUpSigningLogChain creates signing logs for two brands, at the heads of their chains
+func setUpSigningLogChain(t *testing.T) *DB {
+	db := openKeystoreTestDatabase(t)
+	if err := db.CreateSigningLogTable(); err != nil {
+		t.Fatalf("Error creating the signing log table: %v", err)
+	}
+	if err := db.CreateSigningLogChainTable(); err != nil {
+		t.Fatalf("Error creating the signing log chain: %v", err)
+	}
+
+	env := Environ
+	Environ = &Env{DB: db, Config: config.Settings{Driver: "sqlite3", KeyStoreSecret: "the keystore secret"}}
+	t.Cleanup(func() { Environ = env })
+
+	logs := []SigningLog{
+		{Make: "system", Model: "alder", SerialNumber: "A1", Fingerprint: "a1", Revision: 1},
+		{Make: "system", Model: "alder", SerialNumber: "A2", Fingerprint: "a2", Revision: 1},
+		{Make: "other", Model: "ash", SerialNumber: "B1", Fingerprint: "b1", Revision: 1},
+		{Make: "system", Rejected: "The signing-key is not allowed to sign assertions for the brand"},
+		{Make: "system", Model: "alder", SerialNumber: "A3", Fingerprint: "a3", Revision: 1},
+	}
+	for _, l := range logs {
+		if err := db.CreateSigningLog(l); err != nil {
+			t.Fatalf("Error creating the signing log: %v", err)
+		}
+	}
+
+	synced := SigningLog{Make: "other", Model: "ash", SerialNumber: "B2", Fingerprint: "b2", Revision: 1, Created: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)}
+	if err := db.CreateSigningLogSync(synced); err != nil {
+		t.Fatalf("Error creating the synced signing log: %v", err)
+	}
+	return db
+}
+
+func verifySigningLogChain(t *testing.T, db *DB, authorityID string) map[string]SigningLogChainReport {
+	reports, err := db.VerifySigningLogChain(authorityID)
+	if err != nil {
+		t.Fatalf("Error verifying the signing log chain: %v", err)
+	}
+
+	byBrand := map[string]SigningLogChainReport{}
+	for _, r := range reports {
+		byBrand[r.Make] = r
+	}
+	return byBrand
+}
+
+func TestSigningLogChain(t *testing.T) {
+	db := setUpSigningLogChain(t)
+
+	reports := verifySigningLogChain(t, db, "")
+	if len(reports) != 2 {
+		t.Fatalf("Expected the chains of 2 brands, got: %v", reports)
+	}
+	if r := reports["system"]; !r.Valid() || r.Entries != 4 || r.Head != 4 {
+		t.Errorf("Unexpected report for the system brand: %v", r)
+	}
+	if r := reports["other"]; !r.Valid() || r.Entries != 2 || r.Head != 2 {
+		t.Errorf("Unexpected report for the other brand: %v", r)
+	}
+
+	// The synced flag is not covered by the hash
+	if err := db.SyncUpdateSigningLog(1); err != nil {
+		t.Fatalf("Error syncing the signing log: %v", err)
+	}
+	if r := verifySigningLogChain(t, db, "system")["system"]; !r.Valid() {
+		t.Errorf("Expected a valid chain after a sync, got: %v", r.Problems)
+	}
+
+	heads, err := db.ListAllowedSigningLogChainHeads(User{}, "other")
+	if err != nil {
+		t.Fatalf("Error listing the chain heads: %v", err)
+	}
+	if len(heads) != 1 || heads[0].Make != "other" || heads[0].Seq != 2 || len(heads[0].Hash) != 64 {
+		t.Errorf("Unexpected chain heads: %v", heads)
+	}
+	if heads, _ = db.ListAllowedSigningLogChainHeads(User{Role: Standard}, ""); len(heads) != 0 {
+		t.Errorf("Expected no chain heads for a standard user, got: %v", heads)
+	}
+}
+
+func TestSigningLogChainFirstLogsConcurrently(t *testing.T) {
+	db := setUpSigningLogChain(t)
+
+	var path string
+	if err := db.QueryRow("SELECT file FROM pragma_database_list WHERE name='main'").Scan(&path); err != nil {
+		t.Fatalf("Error reading the database path: %v", err)
+	}
+	sqlDB, err := sql.Open("sqlite3", sqliteDataSource(path))
+	if err != nil {
+		t.Fatalf("Error opening the database: %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	concurrent := &DB{sqlDB}
+
+	// The first logs of a brand are created at the same time, and both start its chain
+	errs := make(chan error, 4)
+	for i := 0; i < cap(errs); i++ {
+		go func(i int) {
+			errs <- concurrent.CreateSigningLog(SigningLog{Make: "new", Model: "elm", SerialNumber: fmt.Sprintf("C%d", i), Fingerprint: fmt.Sprintf("c%d", i), Revision: 1})
+		}(i)
+	}
+	for i := 0; i < cap(errs); i++ {
+		if err := <-errs; err != nil {
+			t.Fatalf("Error creating the signing log: %v", err)
+		}
+	}
+
+	if r := verifySigningLogChain(t, db, "new")["new"]; !r.Valid() || r.Entries != cap(errs) || r.Head != cap(errs) {
+		t.Errorf("Unexpected report for the new brand: %v", r)
+	}
+}
+
+func TestSigningLogChainTampered(t *testing.T) {
+	tests := []struct {
+		name    string
+		tamper  []string
+		problem string
+	}{
+		{"changed", []string{"UPDATE signinglog SET serial_number='A9' WHERE serial_number='A2'"}, "entry 2 (id 2) has been changed"},
+		{"rehashed", []string{"UPDATE signinglog SET hash='" + strings.Repeat("0", 64) + "' WHERE serial_number='A1'"}, "entry 1 (id 1) has been changed"},
+		{"removed", []string{"DELETE FROM signinglog WHERE serial_number='A2'"}, "entry 2 is missing"},
+		{"truncated", []string{"DELETE FROM signinglog WHERE chain_seq>2 AND make='system'"}, "entries 3 to 4 are missing"},
+		{"head", []string{"UPDATE signinglogchain SET hash='' WHERE make='system'"}, "the head of the chain does not match entry 4"},
+		{"head removed", []string{"DELETE FROM signinglogchain WHERE make='system'"}, "the head of the chain is missing"},
+		{"repeated", []string{"UPDATE signinglog SET chain_seq=1 WHERE serial_number='A2'"}, "entry 1 is repeated (id 2)"},
+		{"unchained", []string{"INSERT INTO signinglog (id, make, model, serial_number, fingerprint) VALUES (99, 'system', 'alder', 'A99', 'a99')"}, "1 entries are not in the chain"},
+		{"emptied", []string{"DELETE FROM signinglog WHERE make='system'"}, "entries 1 to 4 are missing"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := setUpSigningLogChain(t)
+			for _, s := range tt.tamper {
+				if _, err := db.Exec(s); err != nil {
+					t.Fatalf("Error changing the signing log: %v", err)
+				}
+			}
+
+			reports := verifySigningLogChain(t, db, "")
+			r := reports["system"]
+			if r.Valid() || !strings.Contains(strings.Join(r.Problems, "\n"), tt.problem) {
+				t.Errorf("Expected the problem '%s', got: %v", tt.problem, r.Problems)
+			}
+			if !reports["other"].Valid() {
+				t.Errorf("Expected the chain of the other brand to be valid, got: %v", reports["other"].Problems)
+			}
+		})
+	}
+}
+
+func TestMigrateSigningLogChain(t *testing.T) {
+	db := openLegacyTestDatabase(t)
+
+	env := Environ
+	Environ = &Env{DB: db, Config: config.Settings{Driver: "sqlite3"}}
+	t.Cleanup(func() { Environ = env })
+
+	// The logs that were created before the hash chain are chained by the migration
+	for i, brand := range []string{"system", "other", "system"} {
+		_, err := db.Exec("INSERT INTO signinglog (id, make, model, serial_number, fingerprint) VALUES ($1,$2,'alder',$3,$4)",
+			i+1, brand, strings.Repeat("A", i+1), strings.Repeat("a", i+1))
+		if err != nil {
+			t.Fatalf("Error creating the signing log: %v", err)
+		}
+	}
+	if _, err := db.MigrateSchema(LatestSchemaVersion()); err != nil {
+		t.Fatalf("Error migrating the database: %v", err)
+	}
+
+	reports := verifySigningLogChain(t, db, "")
+	if r := reports["system"]; !r.Valid() || r.Entries != 2 || r.Head != 2 {
+		t.Errorf("Unexpected report for the system brand: %v", r)
+	}
+	if r := reports["other"]; !r.Valid() || r.Entries != 1 || r.Head != 1 {
+		t.Errorf("Unexpected report for the other brand: %v", r)
+	}
+
+	// New logs continue the chain
+	if err := db.CreateSigningLog(SigningLog{Make: "system", Model: "alder", SerialNumber: "A4", Fingerprint: "a4", Revision: 1}); err != nil {
+		t.Fatalf("Error creating the signing log: %v", err)
+	}
+	if r := verifySigningLogChain(t, db, "system")["system"]; !r.Valid() || r.Head != 3 {
+		t.Errorf("Unexpected report after a new log: %v", r)
+	}
+
+	// The signing logs are kept when the chain is rolled back
+	if _, err := db.MigrateSchema(3); err != nil {
+		t.Fatalf("Error rolling back the chain: %v", err)
+	}
+	var count int
+	if err := db.QueryRow("SELECT count(*) FROM signinglog").Scan(&count); err != nil || count != 4 {
+		t.Errorf("Expected 4 signing logs after the rollback, got: %d, %v", count, err)
+	}
+}
+
+func TestSignSigningLogCheckpoints(t *testing.T) {
+	env := Environ
+	Environ = &Env{Config: config.Settings{KeyStoreSecret: "the keystore secret"}}
+	t.Cleanup(func() { Environ = env })
+
+	heads := []SigningLogChainHead{{Make: "system", Seq: 4, Hash: strings.Repeat("a", 64)}, {Make: "other", Seq: 2, Hash: strings.Repeat("b", 64)}}
+	now := time.Date(2026, 3, 1, 12, 0, 0, 500, time.UTC)
+
+	key, checkpoints, err := SignSigningLogCheckpoints(heads, now)
+	if err != nil {
+		t.Fatalf("Error signing the checkpoints: %v", err)
+	}
+	if len(checkpoints) != 2 || checkpoints[0].KeyID != key.KeyID || !checkpoints[0].Timestamp.Equal(now.Truncate(time.Second)) {
+		t.Fatalf("Unexpected checkpoints: %v", checkpoints)
+	}
+	for _, c := range checkpoints {
+		if err := VerifySigningLogCheckpoint(key.PublicKey, c); err != nil {
+			t.Errorf("Error verifying the checkpoint of %s: %v", c.Make, err)
+		}
+	}
+
+	// The checkpoint key does not change for the secret
+	if again, _, _ := SignSigningLogCheckpoints(heads, now); again != key {
+		t.Errorf("Expected the same checkpoint key, got: %v and %v", key, again)
+	}
+
+	changed := checkpoints[0]
+	changed.Seq = 3
+	if err := VerifySigningLogCheckpoint(key.PublicKey, changed); err != ErrorCheckpointSignature {
+		t.Errorf("Expected an invalid signature for a changed checkpoint, got: %v", err)
+	}
+
+	Environ.Config.KeyStoreSecret = "another secret"
+	other, _, _ := SignSigningLogCheckpoints(heads, now)
+	if other.KeyID == key.KeyID || VerifySigningLogCheckpoint(other.PublicKey, checkpoints[0]) == nil {
+		t.Error("Expected a different checkpoint key for a different secret")
+	}
+
+	Environ.Config.KeyStoreSecret = ""
+	if _, _, err := SignSigningLogCheckpoints(heads, now); err != ErrorCheckpointKeyUnavailable {
+		t.Errorf("Expected an error without a keystore secret, got: %v", err)
+	}
+}
@@ -0,0 +1,112 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package datastore
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// signingLogCheckpointContext separates the checkpoint key, and the signed checkpoints, from the
+// other uses of the keystore secret
+const signingLogCheckpointContext = "serial-vault signing log checkpoint"
+
+// Common error messages
+var (
+	ErrorCheckpointKeyUnavailable = errors.New("The checkpoints cannot be signed without a keystore secret")
+	ErrorCheckpointSignature      = errors.New("The signature of the checkpoint is invalid")
+)
+
+// SigningLogCheckpointKey is the public key that signs the signing log checkpoints
+type SigningLogCheckpointKey struct {
+	KeyID     string `json:"key_id"`
+	PublicKey string `json:"public_key"`
+}
+
+// SigningLogCheckpoint is the head of the hash chain of a brand, signed by the vault. A copy held
+// outside the database shows that the chain has not been rewritten up to the checkpoint
+type SigningLogCheckpoint struct {
+	SigningLogChainHead
+	Timestamp time.Time `json:"timestamp"`
+	KeyID     string    `json:"key_id"`
+	Signature string    `json:"signature"`
+}
+
+// Message is the text of the checkpoint that is signed
+func (c SigningLogCheckpoint) Message() []byte {
+	return []byte(fmt.Sprintf("%s\n%s\n%d\n%s\n%s\n", signingLogCheckpointContext, c.Make, c.Seq, c.Hash, c.Timestamp.UTC().Format(time.RFC3339)))
+}
+
+// signingLogCheckpointKey derives the Ed25519 checkpoint key from the keystore secret, so the key
+// is not stored in the database that it protects. The key changes when the secret is rotated
+func signingLogCheckpointKey() (ed25519.PrivateKey, error) {
+	if len(Environ.Config.KeyStoreSecret) == 0 {
+		return nil, ErrorCheckpointKeyUnavailable
+	}
+
+	mac := hmac.New(sha256.New, []byte(Environ.Config.KeyStoreSecret))
+	mac.Write([]byte(signingLogCheckpointContext))
+	return ed25519.NewKeyFromSeed(mac.Sum(nil)), nil
+}
+
+func checkpointKeyID(publicKey ed25519.PublicKey) string {
+	sum := sha256.Sum256(publicKey)
+	return hex.EncodeToString(sum[:8])
+}
+
+// SignSigningLogCheckpoints signs the heads of the hash chains with the checkpoint key of the vault
+func SignSigningLogCheckpoints(heads []SigningLogChainHead, now time.Time) (SigningLogCheckpointKey, []SigningLogCheckpoint, error) {
+	privateKey, err := signingLogCheckpointKey()
+	if err != nil {
+		return SigningLogCheckpointKey{}, nil, err
+	}
+
+	publicKey := privateKey.Public().(ed25519.PublicKey)
+	key := SigningLogCheckpointKey{KeyID: checkpointKeyID(publicKey), PublicKey: base64.StdEncoding.EncodeToString(publicKey)}
+
+	checkpoints := []SigningLogCheckpoint{}
+	for _, h := range heads {
+		c := SigningLogCheckpoint{SigningLogChainHead: h, Timestamp: now.UTC().Truncate(time.Second), KeyID: key.KeyID}
+		c.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, c.Message()))
+		checkpoints = append(checkpoints, c)
+	}
+	return key, checkpoints, nil
+}
+
+// VerifySigningLogCheckpoint checks the signature of a checkpoint with the base64 encoded public key
+func VerifySigningLogCheckpoint(publicKey string, checkpoint SigningLogCheckpoint) error {
+	key, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return errors.New("The checkpoint public key is invalid")
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(checkpoint.Signature)
+	if err != nil || !ed25519.Verify(ed25519.PublicKey(key), checkpoint.Message(), signature) {
+		return ErrorCheckpointSignature
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package datastore
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// The formats of the signing log and audit log exports
+const (
+	ExportFormatCSV   = "csv"
+	ExportFormatJSONL = "jsonl"
+)
+
+// The writer is flushed after this number of records, so a stream reaches the client
+const exportFlushInterval = 1000
+
+var signingLogExportHeader = []string{"id", "make", "model", "serial_number", "fingerprint", "created", "revision", "synced", "rejected"}
+
+// signingLogExportRecord is a line of the JSON Lines export
+type signingLogExportRecord struct {
+	ID           int       `json:"id"`
+	Make         string    `json:"make"`
+	Model        string    `json:"model"`
+	SerialNumber string    `json:"serial_number"`
+	Fingerprint  string    `json:"fingerprint"`
+	Created      time.Time `json:"created"`
+	Revision     int       `json:"revision"`
+	Synced       bool      `json:"synced"`
+	Rejected     string    `json:"rejected"`
+}
+
+// ValidExportFormat checks that a log can be exported in the format
+func ValidExportFormat(format string) error {
+	switch format {
+	case ExportFormatCSV, ExportFormatJSONL:
+		return nil
+	default:
+		return fmt.Errorf("invalid export format '%s', it must be one of: %s, %s", format, ExportFormatCSV, ExportFormatJSONL)
+	}
+}
+
+// ExportSigningLog writes the signing logs that the user is authorized to see in the format,
+// streaming them from the database. The number of records that were written is returned
+func ExportSigningLog(w io.Writer, format string, authorization User, authorityID string, params *SigningLogParams) (int, error) {
+	if err := ValidExportFormat(format); err != nil {
+		return 0, err
+	}
+
+	var (
+		write func(SigningLog) error
+		flush func() error
+	)
+
+	switch format {
+	case ExportFormatCSV:
+		c := csv.NewWriter(w)
+		if err := c.Write(signingLogExportHeader); err != nil {
+			return 0, err
+		}
+		write = func(l SigningLog) error {
+			return c.Write([]string{strconv.Itoa(l.ID), l.Make, l.Model, l.SerialNumber, l.Fingerprint,
+				l.Created.UTC().Format(time.RFC3339), strconv.Itoa(l.Revision), strconv.FormatBool(l.Synced > 0), l.Rejected})
+		}
+		flush = func() error {
+			c.Flush()
+			return c.Error()
+		}
+	default:
+		e := json.NewEncoder(w)
+		write = func(l SigningLog) error {
+			return e.Encode(signingLogExportRecord{ID: l.ID, Make: l.Make, Model: l.Model, SerialNumber: l.SerialNumber,
+				Fingerprint: l.Fingerprint, Created: l.Created.UTC(), Revision: l.Revision, Synced: l.Synced > 0, Rejected: l.Rejected})
+		}
+		flush = func() error { return nil }
+	}
+
+	count := 0
+	err := Environ.DB.ExportAllowedSigningLog(authorization, authorityID, params, func(l SigningLog) error {
+		if err := write(l); err != nil {
+			return err
+		}
+		count++
+		if count%exportFlushInterval == 0 {
+			return flushExport(w, flush)
+		}
+		return nil
+	})
+	if err != nil {
+		return count, err
+	}
+
+	return count, flushExport(w, flush)
+}
+
+// flushExport flushes the encoder, then the writer when it is buffered e.g. an HTTP response
+func flushExport(w io.Writer, flush func() error) error {
+	if err := flush(); err != nil {
+		return err
+	}
+	if f, ok := w.(interface{ Flush() }); ok {
+		f.Flush()
+	}
+	return nil
+}
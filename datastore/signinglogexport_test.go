@@ -0,0 +1,141 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package datastore
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CanonicalLtd/serial-vault/config"
+)
+
+// setUpSigningLogExport stores signing logs for two brands, over three days
+func setUpSigningLogExport(t *testing.T) *DB {
+	db := openKeystoreTestDatabase(t)
+	if err := db.CreateSigningLogTable(); err != nil {
+		t.Fatalf("Error creating the signing log table: %v", err)
+	}
+
+	env := Environ
+	Environ = &Env{DB: db, Config: config.Settings{Driver: "sqlite3"}}
+	t.Cleanup(func() { Environ = env })
+
+	day := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	logs := []SigningLog{
+		{ID: 1, Make: "system", Model: "alder", SerialNumber: "A1", Fingerprint: "a1", Created: day, Synced: 1},
+		{ID: 2, Make: "system", Model: "ash", SerialNumber: "A2", Fingerprint: "a2", Created: day.AddDate(0, 0, 1)},
+		{ID: 3, Make: "system", Model: "alder", SerialNumber: "A3", Fingerprint: "a3", Created: day.AddDate(0, 0, 2), Rejected: "brand"},
+		{ID: 4, Make: "other", Model: "alder", SerialNumber: "B1", Fingerprint: "b1", Created: day.AddDate(0, 0, 1)},
+	}
+	for _, l := range logs {
+		_, err := db.Exec("INSERT INTO signinglog (id, make, model, serial_number, fingerprint, created, revision, synced, rejected) VALUES ($1,$2,$3,$4,$5,$6,1,$7,$8)",
+			l.ID, l.Make, l.Model, l.SerialNumber, l.Fingerprint, l.Created, l.Synced, l.Rejected)
+		if err != nil {
+			t.Fatalf("Error creating the signing log: %v", err)
+		}
+	}
+	return db
+}
+
+func TestExportSigningLogCSV(t *testing.T) {
+	setUpSigningLogExport(t)
+
+	from := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		authorityID string
+		params      SigningLogParams
+		serials     []string
+	}{
+		{"", SigningLogParams{}, []string{"A1", "A2", "A3", "B1"}},
+		{"system", SigningLogParams{}, []string{"A1", "A2", "A3"}},
+		{"system", SigningLogParams{Filter: []string{"alder"}}, []string{"A1", "A3"}},
+		{"system", SigningLogParams{From: &from}, []string{"A2", "A3"}},
+		{"", SigningLogParams{To: &from}, []string{"A1"}},
+		{"system", SigningLogParams{Synced: new(bool)}, []string{"A2", "A3"}},
+	}
+
+	for _, tt := range tests {
+		var b bytes.Buffer
+		count, err := ExportSigningLog(&b, ExportFormatCSV, User{}, tt.authorityID, &tt.params)
+		if err != nil {
+			t.Fatalf("Error exporting the signing log: %v", err)
+		}
+		if count != len(tt.serials) {
+			t.Errorf("Expected %d records, got: %d", len(tt.serials), count)
+		}
+
+		records, err := csv.NewReader(&b).ReadAll()
+		if err != nil {
+			t.Fatalf("Error reading the CSV export: %v", err)
+		}
+		if strings.Join(records[0], ",") != "id,make,model,serial_number,fingerprint,created,revision,synced,rejected" {
+			t.Errorf("Unexpected CSV header: %v", records[0])
+		}
+		serials := []string{}
+		for _, r := range records[1:] {
+			serials = append(serials, r[3])
+		}
+		if strings.Join(serials, ",") != strings.Join(tt.serials, ",") {
+			t.Errorf("Expected the serial numbers %v, got: %v", tt.serials, serials)
+		}
+	}
+}
+
+func TestExportSigningLogJSONL(t *testing.T) {
+	setUpSigningLogExport(t)
+
+	var b bytes.Buffer
+	count, err := ExportSigningLog(&b, ExportFormatJSONL, User{}, "system", &SigningLogParams{Filter: []string{"alder"}})
+	if err != nil {
+		t.Fatalf("Error exporting the signing log: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("Expected 2 records, got: %d", count)
+	}
+
+	records := []signingLogExportRecord{}
+	scanner := bufio.NewScanner(&b)
+	for scanner.Scan() {
+		r := signingLogExportRecord{}
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			t.Fatalf("Error reading the JSONL export: %v", err)
+		}
+		records = append(records, r)
+	}
+	if len(records) != 2 || records[0].SerialNumber != "A1" || !records[0].Synced || records[1].Rejected != "brand" {
+		t.Errorf("Unexpected JSONL export: %v", records)
+	}
+
+	if _, err := ExportSigningLog(&b, "xml", User{}, "", &SigningLogParams{}); err == nil {
+		t.Error("Expected an error exporting in an invalid format")
+	}
+
+	// A Standard user cannot see the signing log
+	b.Reset()
+	if count, err := ExportSigningLog(&b, ExportFormatJSONL, User{Username: "user1", Role: Standard}, "", &SigningLogParams{}); err != nil || count != 0 {
+		t.Errorf("Expected no records for a Standard user, got: %d, %v", count, err)
+	}
+}
@@ -21,6 +21,8 @@ package datastore
 
 import (
 	"database/sql"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"time"
@@ -38,13 +40,15 @@ const createSigningLogTableSQL = `
 		fingerprint    varchar(200) not null,
 		created        timestamp default current_timestamp,
 		revision       int default 1,
-		synced         int default 0
+		synced         int default 0,
+		rejected       varchar(200) default ''
 	)
 `
 
 // Additional columns
 const alterSigningLogAddRevisionSQL = "ALTER TABLE signinglog ADD COLUMN revision int default 1"
 const alterSigningLogAddSyncedSQL = "ALTER TABLE signinglog ADD COLUMN synced int default 0"
+const alterSigningLogAddRejectedSQL = "ALTER TABLE signinglog ADD COLUMN rejected varchar(200) default ''"
 
 // MaxFromID is the maximum ID value
 const MaxFromID = 2147483647
@@ -52,30 +56,25 @@ const MaxFromID = 2147483647
 // ListSigningLogDefaultLimit is the default limit for the search queries in SigningLog
 const ListSigningLogDefaultLimit = 50
 
+// ListSigningLogMaxLimit is the largest page of the search queries in SigningLog. The whole
+// of a search is fetched with the export
+const ListSigningLogMaxLimit = 1000
+
 // Indexes
 const createSigningLogSerialNumberIndexSQL = "CREATE INDEX IF NOT EXISTS serialnumber_idx ON signinglog (make,model,serial_number)"
 const createSigningLogFingerprintIndexSQL = "CREATE INDEX IF NOT EXISTS fingerprint_idx ON signinglog (fingerprint)"
 const createSigningLogCreatedIndexSQL = "CREATE INDEX IF NOT EXISTS created_idx ON signinglog (created)"
 
-// Queries
-const findMatchingSigningLogSQL = "SELECT EXISTS(SELECT * FROM signinglog where make=$1 and model=$2 and serial_number=$3 and revision=$4)"
-const findExistingSigningLogSQL = "SELECT EXISTS(SELECT * FROM signinglog where (make=$1 and model=$2 and serial_number=$3) or fingerprint=$4)"
-const findMaxRevisionSigningLogSQL = "SELECT COALESCE(MAX(revision), 0) FROM signinglog where make=$1 and model=$2 and serial_number=$3"
+// Queries (rejected assertions are logged, but were never signed so they are not checked for duplicates)
+const findMatchingSigningLogSQL = "SELECT EXISTS(SELECT * FROM signinglog where make=$1 and model=$2 and serial_number=$3 and revision=$4 and rejected='')"
+const findExistingSigningLogSQL = "SELECT EXISTS(SELECT * FROM signinglog where ((make=$1 and model=$2 and serial_number=$3) or fingerprint=$4) and rejected='')"
+const findDeviceKeyChangeSigningLogSQL = "SELECT EXISTS(SELECT * FROM signinglog where make=$1 and model=$2 and serial_number=$3 and fingerprint<>$4 and rejected='')"
+const findMaxRevisionSigningLogSQL = "SELECT COALESCE(MAX(revision), 0) FROM signinglog where make=$1 and model=$2 and serial_number=$3 and rejected=''"
 const maxIDSigningLogSQLite = "SELECT COUNT(*)+1 from signinglog"
-const createSigningLogSQLite = "INSERT INTO signinglog (id, make, model, serial_number, fingerprint,revision) VALUES ($1, $2, $3, $4, $5, $6)"
-const createSigningLogSQL = "INSERT INTO signinglog (make, model, serial_number, fingerprint,revision) VALUES ($1, $2, $3, $4, $5)"
-const createSigningLogSyncSQL = "INSERT INTO signinglog (make, model, serial_number, fingerprint,revision,created) VALUES ($1, $2, $3, $4, $5, $6)"
-const listSigningLogSQL = "SELECT * FROM signinglog WHERE id < $1 ORDER BY id DESC LIMIT 10000"
-const listSigningLogForUserSQL = `
-	SELECT s.* FROM signinglog s
-	WHERE id < $1 and EXISTS(
-		SELECT * FROM account acc
-		INNER JOIN useraccountlink ua on ua.account_id=acc.id
-		INNER JOIN userinfo u on ua.user_id=u.id
-		WHERE acc.authority_id=s.make and u.username=$2
-	)
-	ORDER BY id DESC LIMIT 10000`
-
+const createSigningLogSQLite = "INSERT INTO signinglog (id, make, model, serial_number, fingerprint,revision,rejected,chain_seq) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)"
+const createSigningLogSQL = "INSERT INTO signinglog (make, model, serial_number, fingerprint,revision,rejected,chain_seq) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id"
+const createSigningLogSyncSQLite = "INSERT INTO signinglog (id, make, model, serial_number, fingerprint,revision,created,rejected,chain_seq) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)"
+const createSigningLogSyncSQL = "INSERT INTO signinglog (make, model, serial_number, fingerprint,revision,created,rejected,chain_seq) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id"
 const deleteSigningLogSQL = "DELETE FROM signinglog WHERE id=$1"
 
 const filterValuesModelSigningLogSQL = "SELECT DISTINCT model FROM signinglog WHERE make=$1 ORDER BY model"
@@ -89,9 +88,12 @@ const filterValuesModelSigningLogForUserSQL = `
 	)
 	AND s.make = $2
 	ORDER BY model`
-const syncSigningLogSQLite = "SELECT * FROM signinglog WHERE synced = 0"
+const syncSigningLogSQLite = "SELECT id, make, model, serial_number, fingerprint, created, revision, synced, rejected FROM signinglog WHERE synced = 0"
 const syncSigningLogUpdateSQLite = "UPDATE signinglog SET synced=1 WHERE id = $1"
 
+// signingLogColumns are the columns of a signing log that are listed
+var signingLogColumns = []string{"id", "make", "model", "serial_number", "fingerprint", "created", "revision", "synced", "rejected", "chain_seq", "hash"}
+
 // SigningLog holds the details of the serial number and public key fingerprint that were supplied
 // in a serial assertion for signing. The details are stored in the local database,
 type SigningLog struct {
@@ -103,9 +105,80 @@ type SigningLog struct {
 	Created      time.Time `json:"created"`
 	Revision     int       `json:"revision"`
 	Synced       int       `json:"synced"`
+	Rejected     string    `json:"rejected"`
+	ChainSeq     int       `json:"chainseq"`
+	Hash         string    `json:"hash"`
 	Total        int
 }
 
+// SigningLogPage is a page of the signing log search, with the number of records that match
+// the search and the cursor for the next page. The cursor is empty on the last page
+type SigningLogPage struct {
+	Logs       []SigningLog
+	Total      int
+	NextCursor string
+}
+
+// SigningLogCursor is the position of the last record of a page in the sort order
+type SigningLogCursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    int    `json:"id"`
+}
+
+// SigningLogSortFields are the fields that the signing log can be sorted by, with their columns
+var SigningLogSortFields = map[string]string{
+	"id":           "id",
+	"created":      "created",
+	"serialnumber": "serial_number",
+	"model":        "model",
+}
+
+func signingLogSortColumn(sort string) string {
+	if column, ok := SigningLogSortFields[sort]; ok {
+		return column
+	}
+	return "id"
+}
+
+// EncodeSigningLogCursor encodes the position of the record in the sort order as an opaque cursor
+func EncodeSigningLogCursor(sort string, signingLog SigningLog) string {
+	cursor := SigningLogCursor{Sort: sort, ID: signingLog.ID}
+	switch signingLogSortColumn(sort) {
+	case "created":
+		cursor.Value = signingLog.Created.Format(time.RFC3339Nano)
+	case "serial_number":
+		cursor.Value = signingLog.SerialNumber
+	case "model":
+		cursor.Value = signingLog.Model
+	}
+
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeSigningLogCursor decodes a cursor, checking that it is for the sort order
+func DecodeSigningLogCursor(sort, value string) (*SigningLogCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+
+	cursor := SigningLogCursor{}
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.ID <= 0 {
+		return nil, errors.New("invalid cursor")
+	}
+	if signingLogSortColumn(cursor.Sort) != signingLogSortColumn(sort) {
+		return nil, errors.New("the cursor is for a different sort order")
+	}
+	if signingLogSortColumn(sort) == "created" {
+		if _, err := time.Parse(time.RFC3339Nano, cursor.Value); err != nil {
+			return nil, errors.New("invalid cursor")
+		}
+	}
+	return &cursor, nil
+}
+
 // SigningLogFilters holds the values of the filters for the searchable columns
 type SigningLogFilters struct {
 	Makes  []string `json:"makes"`
@@ -134,6 +207,7 @@ func (db *DB) CreateSigningLogTable() error {
 	// Ignoring the error when adding the column
 	db.Exec(alterSigningLogAddRevisionSQL)
 	db.Exec(alterSigningLogAddSyncedSQL)
+	db.Exec(alterSigningLogAddRejectedSQL)
 
 	return nil
 }
@@ -159,6 +233,18 @@ func (db *DB) CheckForDuplicate(signLog *SigningLog) (bool, int, error) {
 	return duplicateExists, maxRevision, nil
 }
 
+// CheckForDeviceKeyChange checks whether the serial number has previously been signed with a different device-key
+func (db *DB) CheckForDeviceKeyChange(signLog SigningLog) (bool, error) {
+	var changed bool
+	err := db.QueryRow(findDeviceKeyChangeSigningLogSQL, signLog.Make, signLog.Model, signLog.SerialNumber, signLog.Fingerprint).Scan(&changed)
+	if err != nil {
+		log.Printf("Error checking signinglog for a change of device-key: %v\n", err)
+		return false, errors.New("Error communicating with the database")
+	}
+
+	return changed, nil
+}
+
 // CheckForMatching checks to see if a matching signing-log entry exists
 // (same brand, model, serial number and revision)
 func (db *DB) CheckForMatching(signLog SigningLog) (bool, error) {
@@ -173,106 +259,132 @@ func (db *DB) CheckForMatching(signLog SigningLog) (bool, error) {
 }
 
 // CreateSigningLog logs that a specific serial number has been used, along with the device-key fingerprint.
+// An assertion rejected by a keypair policy is logged with the reason, and only needs the Make.
 func (db *DB) CreateSigningLog(signLog SigningLog) error {
 	var err error
 	// Validate the data
-	if !validateStringsNotEmpty(signLog.Make, signLog.Model, signLog.SerialNumber, signLog.Fingerprint) {
-		return errors.New("The Make, Model, Serial Number and device-key Fingerprint must be supplied")
+	if err = validateSigningLog(signLog); err != nil {
+		return err
 	}
 
-	// Create the signing log in the database
-	if InFactory() {
-		// Need to generate our own ID
-		var nextID int
-		err = db.QueryRow(maxIDSigningLogSQLite).Scan(&nextID)
-		if err != nil {
-			log.Printf("Error retrieving next signing-log ID: %v\n", err)
-			return err
-		}
+	// Create the signing log in the database, at the head of the hash chain of the brand
+	return db.chainSigningLog(signLog, insertSigningLog(signLog))
+}
 
-		_, err = db.Exec(createSigningLogSQLite, nextID, signLog.Make, signLog.Model, signLog.SerialNumber, signLog.Fingerprint, signLog.Revision)
-	} else {
-		_, err = db.Exec(createSigningLogSQL, signLog.Make, signLog.Model, signLog.SerialNumber, signLog.Fingerprint, signLog.Revision)
-	}
+// insertSigningLog returns the function that stores a new signing log at a position in the chain
+func insertSigningLog(signLog SigningLog) func(tx *sql.Tx, seq int) (int, error) {
+	return func(tx *sql.Tx, seq int) (int, error) {
+		if InFactory() {
+			// Need to generate our own ID
+			nextID, err := nextSigningLogID(tx)
+			if err != nil {
+				return 0, err
+			}
+
+			_, err = tx.Exec(createSigningLogSQLite, nextID, signLog.Make, signLog.Model, signLog.SerialNumber, signLog.Fingerprint, signLog.Revision, signLog.Rejected, seq)
+			return nextID, err
+		}
 
-	// Create the log in the database
-	if err != nil {
-		log.Printf("Error creating the signing log: %v\n", err)
-		return err
+		var id int
+		err := tx.QueryRow(createSigningLogSQL, signLog.Make, signLog.Model, signLog.SerialNumber, signLog.Fingerprint, signLog.Revision, signLog.Rejected, seq).Scan(&id)
+		return id, err
 	}
-
-	return nil
 }
 
 // CreateSigningLogSync logs that a specific serial number has been used, along with the device-key fingerprint.
 func (db *DB) CreateSigningLogSync(signLog SigningLog) error {
 	var err error
 	// Validate the data
-	if !validateStringsNotEmpty(signLog.Make, signLog.Model, signLog.SerialNumber, signLog.Fingerprint) {
-		return errors.New("The Make, Model, Serial Number and device-key Fingerprint must be supplied")
+	if err = validateSigningLog(signLog); err != nil {
+		return err
 	}
 
-	// Create the signing log in the database
-	_, err = db.Exec(createSigningLogSyncSQL, signLog.Make, signLog.Model, signLog.SerialNumber, signLog.Fingerprint, signLog.Revision, signLog.Created)
+	// Create the signing log in the database, at the head of the hash chain of the brand
+	return db.chainSigningLog(signLog, func(tx *sql.Tx, seq int) (int, error) {
+		if InFactory() {
+			nextID, err := nextSigningLogID(tx)
+			if err != nil {
+				return 0, err
+			}
+
+			_, err = tx.Exec(createSigningLogSyncSQLite, nextID, signLog.Make, signLog.Model, signLog.SerialNumber, signLog.Fingerprint, signLog.Revision, signLog.Created, signLog.Rejected, seq)
+			return nextID, err
+		}
+
+		var id int
+		err := tx.QueryRow(createSigningLogSyncSQL, signLog.Make, signLog.Model, signLog.SerialNumber, signLog.Fingerprint, signLog.Revision, signLog.Created, signLog.Rejected, seq).Scan(&id)
+		return id, err
+	})
+}
+
+func nextSigningLogID(tx *sql.Tx) (int, error) {
+	var nextID int
+	err := tx.QueryRow(maxIDSigningLogSQLite).Scan(&nextID)
 	if err != nil {
-		log.Printf("Error creating the signing log: %v\n", err)
-		return err
+		log.Printf("Error retrieving next signing-log ID: %v\n", err)
 	}
+	return nextID, err
+}
 
+func validateSigningLog(signLog SigningLog) error {
+	if len(signLog.Rejected) > 0 {
+		if !validateStringsNotEmpty(signLog.Make) {
+			return errors.New("The Make must be supplied")
+		}
+		return nil
+	}
+	if !validateStringsNotEmpty(signLog.Make, signLog.Model, signLog.SerialNumber, signLog.Fingerprint) {
+		return errors.New("The Make, Model, Serial Number and device-key Fingerprint must be supplied")
+	}
 	return nil
 }
 
-func (db *DB) listAllSigningLog() ([]SigningLog, error) {
-	return db.listSigningLogFilteredByUser(anyUserFilter)
+func (db *DB) listAllSigningLogForAccount(authorityID string, params *SigningLogParams) (SigningLogPage, error) {
+	return db.listSigningLogForAccountFilteredByUser(anyUserFilter, authorityID, params)
 }
 
-func (db *DB) listSigningLogFilteredByUser(username string) ([]SigningLog, error) {
-	signingLogs := []SigningLog{}
-
-	var (
-		rows *sql.Rows
-		err  error
-	)
-
-	if len(username) == 0 {
-		rows, err = db.Query(listSigningLogSQL, MaxFromID)
+// signingLogSQLBuilder builds the query for a page of the signing log. An empty authority ID
+// searches the logs of all the accounts
+func signingLogSQLBuilder(username, authorityID string, params *SigningLogParams) sq.SelectBuilder {
+	columns := sq.Select(signingLogColumns...).Column("count(*) OVER() AS total_count")
+	sql := signingLogFilterBuilder(columns, username, authorityID, params).
+		Offset(params.Offset)
+
+	column := signingLogSortColumn(params.Sort)
+	direction := "DESC"
+	if params.Ascending {
+		direction = "ASC"
+	}
+	if column == "id" {
+		sql = sql.OrderBy("id " + direction)
 	} else {
-		rows, err = db.Query(listSigningLogForUserSQL, MaxFromID, username)
+		sql = sql.OrderBy(column+" "+direction, "id "+direction)
 	}
-	if err != nil {
-		log.Printf("Error retrieving signing logs: %v\n", err)
-		return nil, err
+
+	if params.Limit > 0 {
+		sql = sql.Limit(params.Limit)
 	}
-	defer rows.Close()
 
-	for rows.Next() {
-		signingLog := SigningLog{}
-		err := rows.Scan(&signingLog.ID, &signingLog.Make, &signingLog.Model, &signingLog.SerialNumber, &signingLog.Fingerprint, &signingLog.Created, &signingLog.Revision, &signingLog.Synced)
-		if err != nil {
-			return nil, err
-		}
-		signingLogs = append(signingLogs, signingLog)
+	if params.Cursor != nil {
+		sql = sql.Where(signingLogCursorCondition(column, params))
 	}
 
-	return signingLogs, nil
+	return sql
 }
 
-func (db *DB) listAllSigningLogForAccount(authorityID string, params *SigningLogParams) ([]SigningLog, error) {
-	return db.listSigningLogForAccountFilteredByUser(anyUserFilter, authorityID, params)
+// signingLogCountSQLBuilder builds the query for the number of records that match the search
+func signingLogCountSQLBuilder(username, authorityID string, params *SigningLogParams) sq.SelectBuilder {
+	return signingLogFilterBuilder(sq.Select("count(*)"), username, authorityID, params)
 }
 
-func signingLogSQLBuilder(username, authorityID string, params *SigningLogParams) sq.SelectBuilder {
-	sql := sq.
-		Select("*", "count(*) OVER() AS total_count").
+func signingLogFilterBuilder(sql sq.SelectBuilder, username, authorityID string, params *SigningLogParams) sq.SelectBuilder {
+	sql = sql.
 		From("signinglog s").          // FROM signinglog s
 		Where(sq.Lt{"id": MaxFromID}). // WHERE id < $1
-		Where("make=?", authorityID).  // AND make=$2
-		OrderBy("id DESC").
-		Offset(params.Offset).
 		PlaceholderFormat(sq.Dollar)
 
-	if params.Limit > 0 {
-		sql = sql.Limit(params.Limit)
+	if authorityID != "" {
+		sql = sql.Where("make=?", authorityID) // AND make=$2
 	}
 
 	if username != "" {
@@ -292,18 +404,63 @@ func signingLogSQLBuilder(username, authorityID string, params *SigningLogParams
 		// WHERE serial_number LIKE 123%
 		sql = sql.Where(sq.Like{"serial_number": fmt.Sprintf("%s%%", params.Serialnumber)})
 	}
+	if params.Fingerprint != "" {
+		sql = sql.Where(sq.Like{"fingerprint": fmt.Sprintf("%s%%", params.Fingerprint)})
+	}
+	if params.From != nil {
+		sql = sql.Where(sq.GtOrEq{"created": *params.From})
+	}
+	if params.To != nil {
+		sql = sql.Where(sq.Lt{"created": *params.To})
+	}
+	if params.Synced != nil {
+		synced := 0
+		if *params.Synced {
+			synced = 1
+		}
+		sql = sql.Where(sq.Eq{"synced": synced})
+	}
 
 	return sql
 }
 
-func (db *DB) listSigningLogForAccountFilteredByUser(username, authorityID string, params *SigningLogParams) ([]SigningLog, error) {
-	signingLogs := []SigningLog{}
+// signingLogExportSQLBuilder builds the query for the export of the signing log, oldest first. The
+// page and the sort of the params are not used
+func signingLogExportSQLBuilder(username, authorityID string, params *SigningLogParams) sq.SelectBuilder {
+	columns := sq.Select("id", "make", "model", "serial_number", "fingerprint", "created", "revision", "synced", "rejected")
+	return signingLogFilterBuilder(columns, username, authorityID, params).OrderBy("id")
+}
+
+// signingLogCursorCondition selects the records after the cursor, in the sort order. The ID
+// breaks the ties between records with the same value
+func signingLogCursorCondition(column string, params *SigningLogParams) sq.Sqlizer {
+	after := func(column string, value interface{}) sq.Sqlizer {
+		if params.Ascending {
+			return sq.Gt{column: value}
+		}
+		return sq.Lt{column: value}
+	}
+
+	if column == "id" {
+		return after("id", params.Cursor.ID)
+	}
+
+	var value interface{} = params.Cursor.Value
+	if column == "created" {
+		// The cursor was validated when it was decoded
+		value, _ = time.Parse(time.RFC3339Nano, params.Cursor.Value)
+	}
+	return sq.Or{after(column, value), sq.And{sq.Eq{column: value}, after("id", params.Cursor.ID)}}
+}
+
+func (db *DB) listSigningLogForAccountFilteredByUser(username, authorityID string, params *SigningLogParams) (SigningLogPage, error) {
+	page := SigningLogPage{Logs: []SigningLog{}}
 
 	listSQL := signingLogSQLBuilder(username, authorityID, params)
 	rows, err := listSQL.RunWith(db).Query()
 	if err != nil {
 		log.Printf("Error retrieving signing logs: %v\n", err)
-		return nil, err
+		return page, err
 	}
 	defer rows.Close()
 
@@ -311,15 +468,65 @@ func (db *DB) listSigningLogForAccountFilteredByUser(username, authorityID strin
 		signingLog := SigningLog{}
 		err := rows.Scan(&signingLog.ID, &signingLog.Make, &signingLog.Model,
 			&signingLog.SerialNumber, &signingLog.Fingerprint, &signingLog.Created,
-			&signingLog.Revision, &signingLog.Synced, &signingLog.Total)
+			&signingLog.Revision, &signingLog.Synced, &signingLog.Rejected, &signingLog.ChainSeq, &signingLog.Hash,
+			&signingLog.Total)
 		if err != nil {
 			log.Printf("Error retrieving signing logs: %v\n", err)
-			return nil, err
+			return page, err
 		}
-		signingLogs = append(signingLogs, signingLog)
+		page.Logs = append(page.Logs, signingLog)
 	}
 
-	return signingLogs, nil
+	if len(page.Logs) > 0 {
+		page.Total = page.Logs[0].Total
+	}
+
+	// The count of the page leaves out the records before the cursor, so count them all
+	if params.Cursor != nil {
+		err = signingLogCountSQLBuilder(username, authorityID, params).RunWith(db).QueryRow().Scan(&page.Total)
+		if err != nil {
+			log.Printf("Error counting signing logs: %v\n", err)
+			return page, err
+		}
+	}
+
+	// A full page may be followed by another
+	if params.Limit > 0 && len(page.Logs) == int(params.Limit) {
+		page.NextCursor = EncodeSigningLogCursor(params.Sort, page.Logs[len(page.Logs)-1])
+	}
+
+	return page, nil
+}
+
+func (db *DB) exportAllSigningLogForAccount(authorityID string, params *SigningLogParams, fn func(SigningLog) error) error {
+	return db.exportSigningLogForAccountFilteredByUser(anyUserFilter, authorityID, params, fn)
+}
+
+// exportSigningLogForAccountFilteredByUser passes each record to the function as it is read
+// from the database cursor, so the logs are not held in memory
+func (db *DB) exportSigningLogForAccountFilteredByUser(username, authorityID string, params *SigningLogParams, fn func(SigningLog) error) error {
+	rows, err := signingLogExportSQLBuilder(username, authorityID, params).RunWith(db).Query()
+	if err != nil {
+		log.Printf("Error exporting signing logs: %v\n", err)
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		signingLog := SigningLog{}
+		err := rows.Scan(&signingLog.ID, &signingLog.Make, &signingLog.Model,
+			&signingLog.SerialNumber, &signingLog.Fingerprint, &signingLog.Created,
+			&signingLog.Revision, &signingLog.Synced, &signingLog.Rejected)
+		if err != nil {
+			log.Printf("Error exporting signing logs: %v\n", err)
+			return err
+		}
+		if err := fn(signingLog); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
 }
 
 func (db *DB) allSigningLogFilterValues(authorityID string) (SigningLogFilters, error) {
@@ -393,7 +600,7 @@ func (db *DB) SyncSigningLog() ([]SigningLog, error) {
 
 	for rows.Next() {
 		signingLog := SigningLog{}
-		err := rows.Scan(&signingLog.ID, &signingLog.Make, &signingLog.Model, &signingLog.SerialNumber, &signingLog.Fingerprint, &signingLog.Created, &signingLog.Revision, &signingLog.Synced)
+		err := rows.Scan(&signingLog.ID, &signingLog.Make, &signingLog.Model, &signingLog.SerialNumber, &signingLog.Fingerprint, &signingLog.Created, &signingLog.Revision, &signingLog.Synced, &signingLog.Rejected)
 		if err != nil {
 			return nil, err
 		}
@@ -21,6 +21,7 @@ package datastore
 
 import (
 	"testing"
+	"time"
 
 	check "gopkg.in/check.v1"
 )
@@ -32,6 +33,8 @@ type sqlSuite struct{}
 var _ = check.Suite(&sqlSuite{})
 
 func (vs *sqlSuite) TestSigningLogSQLBuilder(c *check.C) {
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
 
 	tests := []struct {
 		username    string
@@ -43,7 +46,7 @@ func (vs *sqlSuite) TestSigningLogSQLBuilder(c *check.C) {
 		{
 			authorityID: "admin",
 			params:      &SigningLogParams{},
-			wantSQL:     "SELECT *, count(*) OVER() AS total_count FROM signinglog s WHERE id < $1 AND make=$2 ORDER BY id DESC OFFSET 0",
+			wantSQL:     "SELECT id, make, model, serial_number, fingerprint, created, revision, synced, rejected, chain_seq, hash, count(*) OVER() AS total_count FROM signinglog s WHERE id < $1 AND make=$2 ORDER BY id DESC OFFSET 0",
 			wantParams:  []interface{}{2147483647, "admin"},
 		},
 		{
@@ -51,7 +54,7 @@ func (vs *sqlSuite) TestSigningLogSQLBuilder(c *check.C) {
 			params: &SigningLogParams{
 				Offset: 150,
 			},
-			wantSQL:    "SELECT *, count(*) OVER() AS total_count FROM signinglog s WHERE id < $1 AND make=$2 ORDER BY id DESC OFFSET 150",
+			wantSQL:    "SELECT id, make, model, serial_number, fingerprint, created, revision, synced, rejected, chain_seq, hash, count(*) OVER() AS total_count FROM signinglog s WHERE id < $1 AND make=$2 ORDER BY id DESC OFFSET 150",
 			wantParams: []interface{}{2147483647, "admin"},
 		},
 		{
@@ -60,7 +63,7 @@ func (vs *sqlSuite) TestSigningLogSQLBuilder(c *check.C) {
 				Offset: 250,
 				Filter: []string{"foo", "bar"},
 			},
-			wantSQL:    "SELECT *, count(*) OVER() AS total_count FROM signinglog s WHERE id < $1 AND make=$2 AND model IN ($3,$4) ORDER BY id DESC OFFSET 250",
+			wantSQL:    "SELECT id, make, model, serial_number, fingerprint, created, revision, synced, rejected, chain_seq, hash, count(*) OVER() AS total_count FROM signinglog s WHERE id < $1 AND make=$2 AND model IN ($3,$4) ORDER BY id DESC OFFSET 250",
 			wantParams: []interface{}{2147483647, "admin", "foo", "bar"},
 		},
 		{
@@ -70,7 +73,7 @@ func (vs *sqlSuite) TestSigningLogSQLBuilder(c *check.C) {
 				Offset:       350,
 				Serialnumber: "R1234567",
 			},
-			wantSQL:    "SELECT *, count(*) OVER() AS total_count FROM signinglog s WHERE id < $1 AND make=$2 AND serial_number LIKE $3 ORDER BY id DESC LIMIT 123 OFFSET 350",
+			wantSQL:    "SELECT id, make, model, serial_number, fingerprint, created, revision, synced, rejected, chain_seq, hash, count(*) OVER() AS total_count FROM signinglog s WHERE id < $1 AND make=$2 AND serial_number LIKE $3 ORDER BY id DESC LIMIT 123 OFFSET 350",
 			wantParams: []interface{}{2147483647, "admin", "R1234567%"},
 		},
 		{
@@ -80,7 +83,7 @@ func (vs *sqlSuite) TestSigningLogSQLBuilder(c *check.C) {
 				Filter:       []string{"aaa"},
 				Serialnumber: "000XXX12354",
 			},
-			wantSQL:    "SELECT *, count(*) OVER() AS total_count FROM signinglog s WHERE id < $1 AND make=$2 AND model IN ($3) AND serial_number LIKE $4 ORDER BY id DESC OFFSET 350",
+			wantSQL:    "SELECT id, make, model, serial_number, fingerprint, created, revision, synced, rejected, chain_seq, hash, count(*) OVER() AS total_count FROM signinglog s WHERE id < $1 AND make=$2 AND model IN ($3) AND serial_number LIKE $4 ORDER BY id DESC OFFSET 350",
 			wantParams: []interface{}{2147483647, "admin", "aaa", "000XXX12354%"},
 		},
 		{
@@ -90,7 +93,7 @@ func (vs *sqlSuite) TestSigningLogSQLBuilder(c *check.C) {
 				Filter:       []string{"aaa"},
 				Serialnumber: "000XXX12354",
 			},
-			wantSQL:    "SELECT *, count(*) OVER() AS total_count FROM signinglog s WHERE id < $1 AND make=$2 AND model IN ($3) AND serial_number LIKE $4 ORDER BY id DESC OFFSET 350",
+			wantSQL:    "SELECT id, make, model, serial_number, fingerprint, created, revision, synced, rejected, chain_seq, hash, count(*) OVER() AS total_count FROM signinglog s WHERE id < $1 AND make=$2 AND model IN ($3) AND serial_number LIKE $4 ORDER BY id DESC OFFSET 350",
 			wantParams: []interface{}{2147483647, "admin", "aaa", "000XXX12354%"},
 		},
 
@@ -98,7 +101,7 @@ func (vs *sqlSuite) TestSigningLogSQLBuilder(c *check.C) {
 			authorityID: "admin",
 			username:    "bob",
 			params:      &SigningLogParams{},
-			wantSQL:     `SELECT *, count(*) OVER() AS total_count FROM signinglog s WHERE id < $1 AND make=$2 AND EXISTS ( SELECT * FROM account acc INNER JOIN useraccountlink ua on ua.account_id=acc.id INNER JOIN userinfo u on ua.user_id=u.id WHERE acc.authority_id=s.make AND u.username=$3 ) ORDER BY id DESC OFFSET 0`,
+			wantSQL:     `SELECT id, make, model, serial_number, fingerprint, created, revision, synced, rejected, chain_seq, hash, count(*) OVER() AS total_count FROM signinglog s WHERE id < $1 AND make=$2 AND EXISTS ( SELECT * FROM account acc INNER JOIN useraccountlink ua on ua.account_id=acc.id INNER JOIN userinfo u on ua.user_id=u.id WHERE acc.authority_id=s.make AND u.username=$3 ) ORDER BY id DESC OFFSET 0`,
 			wantParams:  []interface{}{2147483647, "admin", "bob"},
 		},
 		{
@@ -106,9 +109,57 @@ func (vs *sqlSuite) TestSigningLogSQLBuilder(c *check.C) {
 			params: &SigningLogParams{
 				Serialnumber: "Robert'); DROP TABLE signinglog;--",
 			},
-			wantSQL:    `SELECT *, count(*) OVER() AS total_count FROM signinglog s WHERE id < $1 AND make=$2 AND serial_number LIKE $3 ORDER BY id DESC OFFSET 0`,
+			wantSQL:    `SELECT id, make, model, serial_number, fingerprint, created, revision, synced, rejected, chain_seq, hash, count(*) OVER() AS total_count FROM signinglog s WHERE id < $1 AND make=$2 AND serial_number LIKE $3 ORDER BY id DESC OFFSET 0`,
 			wantParams: []interface{}{2147483647, "admin", "Robert'); DROP TABLE signinglog;--%"},
 		},
+
+		{
+			params: &SigningLogParams{
+				Limit: 50,
+			},
+			wantSQL:    `SELECT id, make, model, serial_number, fingerprint, created, revision, synced, rejected, chain_seq, hash, count(*) OVER() AS total_count FROM signinglog s WHERE id < $1 ORDER BY id DESC LIMIT 50 OFFSET 0`,
+			wantParams: []interface{}{2147483647},
+		},
+		{
+			authorityID: "admin",
+			params: &SigningLogParams{
+				Fingerprint: "abc",
+				From:        &from,
+				To:          &to,
+			},
+			wantSQL:    `SELECT id, make, model, serial_number, fingerprint, created, revision, synced, rejected, chain_seq, hash, count(*) OVER() AS total_count FROM signinglog s WHERE id < $1 AND make=$2 AND fingerprint LIKE $3 AND created >= $4 AND created < $5 ORDER BY id DESC OFFSET 0`,
+			wantParams: []interface{}{2147483647, "admin", "abc%", from, to},
+		},
+		{
+			authorityID: "admin",
+			params: &SigningLogParams{
+				Limit:  10,
+				Cursor: &SigningLogCursor{ID: 60},
+			},
+			wantSQL:    `SELECT id, make, model, serial_number, fingerprint, created, revision, synced, rejected, chain_seq, hash, count(*) OVER() AS total_count FROM signinglog s WHERE id < $1 AND make=$2 AND id < $3 ORDER BY id DESC LIMIT 10 OFFSET 0`,
+			wantParams: []interface{}{2147483647, "admin", 60},
+		},
+		{
+			authorityID: "admin",
+			params: &SigningLogParams{
+				Limit:     10,
+				Sort:      "serialnumber",
+				Ascending: true,
+				Cursor:    &SigningLogCursor{Sort: "serialnumber", Value: "R123", ID: 60},
+			},
+			wantSQL:    `SELECT id, make, model, serial_number, fingerprint, created, revision, synced, rejected, chain_seq, hash, count(*) OVER() AS total_count FROM signinglog s WHERE id < $1 AND make=$2 AND (serial_number > $3 OR (serial_number = $4 AND id > $5)) ORDER BY serial_number ASC, id ASC LIMIT 10 OFFSET 0`,
+			wantParams: []interface{}{2147483647, "admin", "R123", "R123", 60},
+		},
+		{
+			authorityID: "admin",
+			params: &SigningLogParams{
+				Limit:  10,
+				Sort:   "created",
+				Cursor: &SigningLogCursor{Sort: "created", Value: from.Format(time.RFC3339Nano), ID: 60},
+			},
+			wantSQL:    `SELECT id, make, model, serial_number, fingerprint, created, revision, synced, rejected, chain_seq, hash, count(*) OVER() AS total_count FROM signinglog s WHERE id < $1 AND make=$2 AND (created < $3 OR (created = $4 AND id < $5)) ORDER BY created DESC, id DESC LIMIT 10 OFFSET 0`,
+			wantParams: []interface{}{2147483647, "admin", from, from, 60},
+		},
 	}
 
 	for _, tt := range tests {
@@ -120,3 +171,58 @@ func (vs *sqlSuite) TestSigningLogSQLBuilder(c *check.C) {
 		c.Assert(args, check.DeepEquals, tt.wantParams)
 	}
 }
+
+func (vs *sqlSuite) TestSigningLogCountSQLBuilder(c *check.C) {
+	params := &SigningLogParams{Limit: 10, Offset: 20, Serialnumber: "R1", Cursor: &SigningLogCursor{ID: 60}}
+
+	sql, args, err := signingLogCountSQLBuilder("bob", "admin", params).ToSql()
+	c.Assert(err, check.IsNil)
+	c.Assert(sql, check.Equals, `SELECT count(*) FROM signinglog s WHERE id < $1 AND make=$2 AND EXISTS ( SELECT * FROM account acc INNER JOIN useraccountlink ua on ua.account_id=acc.id INNER JOIN userinfo u on ua.user_id=u.id WHERE acc.authority_id=s.make AND u.username=$3 ) AND serial_number LIKE $4`)
+	c.Assert(args, check.DeepEquals, []interface{}{2147483647, "admin", "bob", "R1%"})
+}
+
+func (vs *sqlSuite) TestSigningLogCursor(c *check.C) {
+	created := time.Date(2026, 1, 1, 10, 0, 0, 123000, time.UTC)
+	signingLog := SigningLog{ID: 7, Model: "alder", SerialNumber: "R123", Created: created}
+
+	for _, sort := range []string{"", "id", "created", "serialnumber", "model"} {
+		cursor, err := DecodeSigningLogCursor(sort, EncodeSigningLogCursor(sort, signingLog))
+		c.Assert(err, check.IsNil)
+		c.Assert(cursor.ID, check.Equals, 7)
+	}
+
+	cursor, err := DecodeSigningLogCursor("created", EncodeSigningLogCursor("created", signingLog))
+	c.Assert(err, check.IsNil)
+	c.Assert(cursor.Value, check.Equals, created.Format(time.RFC3339Nano))
+
+	_, err = DecodeSigningLogCursor("model", EncodeSigningLogCursor("created", signingLog))
+	c.Assert(err, check.ErrorMatches, "the cursor is for a different sort order")
+	_, err = DecodeSigningLogCursor("", "not-a-cursor")
+	c.Assert(err, check.ErrorMatches, "invalid cursor")
+}
+
+func (vs *sqlSuite) TestParseDate(c *check.C) {
+	tests := []struct {
+		value    string
+		expected time.Time
+		err      string
+	}{
+		{"2026-01-02", time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC), ""},
+		{"2026-01-02T03:04:05Z", time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC), ""},
+		{"02/01/2026", time.Time{}, "invalid date: 02/01/2026"},
+	}
+
+	for _, t := range tests {
+		date, err := ParseDate(t.value)
+		if len(t.err) > 0 {
+			c.Assert(err, check.ErrorMatches, t.err)
+			continue
+		}
+		c.Assert(err, check.IsNil)
+		c.Assert(date.Equal(t.expected), check.Equals, true, check.Commentf(t.value))
+	}
+
+	date, err := ParseDate("")
+	c.Assert(err, check.IsNil)
+	c.Assert(date, check.IsNil)
+}
@@ -0,0 +1,72 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package datastore
+
+import (
+	"github.com/CanonicalLtd/serial-vault/service/log"
+)
+
+// The signing requests of each API key for a day (UTC), which are counted against the
+// daily quota. The count is shared by the instances of the signing service
+const createSigningQuotaTableSQL = `
+	CREATE TABLE IF NOT EXISTS signingquota (
+		api_key   varchar(200) not null,
+		day       varchar(10) not null,
+		used      int not null default 0,
+		primary key (api_key, day)
+	)
+`
+
+// The count of the day is created for the first signing request, unless a concurrent request has created it
+var startSigningQuotaSQL = map[string]string{
+	postgresDriver: "INSERT INTO signingquota (api_key, day, used) VALUES ($1, $2, 0) ON CONFLICT (api_key, day) DO NOTHING",
+	sqliteDriver:   "INSERT OR IGNORE INTO signingquota (api_key, day, used) VALUES ($1, $2, 0)",
+}
+
+const useSigningQuotaSQL = "UPDATE signingquota SET used=used+1 WHERE api_key=$1 AND day=$2 AND used<$3"
+const deleteSigningQuotasSQL = "DELETE FROM signingquota WHERE day<$1"
+
+// UseSigningQuota counts a signing request of the API key against the quota of the day,
+// returning false when the quota has been used. The count is only moved on while it is
+// below the quota, so the concurrent requests of the instances cannot go over it
+func (db *DB) UseSigningQuota(apiKey, day string, quota int) (bool, error) {
+	if _, err := db.Exec(startSigningQuotaSQL[driverName()], apiKey, day); err != nil {
+		log.Printf("Error creating the signing quota: %v\n", err)
+		return false, err
+	}
+
+	result, err := db.Exec(useSigningQuotaSQL, apiKey, day, quota)
+	if err != nil {
+		log.Printf("Error updating the signing quota: %v\n", err)
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+// DeleteSigningQuotas removes the counts of the days before the day
+func (db *DB) DeleteSigningQuotas(before string) error {
+	_, err := db.Exec(deleteSigningQuotasSQL, before)
+	if err != nil {
+		log.Printf("Error deleting the signing quotas: %v\n", err)
+	}
+	return err
+}
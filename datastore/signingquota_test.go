@@ -0,0 +1,56 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package datastore
+
+import (
+	"testing"
+)
+
+func TestSigningQuotaSQLite(t *testing.T) {
+	db := openMigrationTestDatabase(t)
+	if _, err := db.MigrateSchema(LatestSchemaVersion()); err != nil {
+		t.Fatalf("Error migrating the schema: %v", err)
+	}
+
+	for i, expected := range []bool{true, true, false} {
+		ok, err := db.UseSigningQuota("ValidAPIKey", "2018-05-01", 2)
+		if err != nil || ok != expected {
+			t.Errorf("Request %d: expected %v, got: %v, %v", i, expected, ok, err)
+		}
+	}
+
+	// The other API keys and days have their own count
+	if ok, _ := db.UseSigningQuota("OtherAPIKey", "2018-05-01", 2); !ok {
+		t.Error("Expected the quota of the other API key to be available")
+	}
+	if ok, _ := db.UseSigningQuota("ValidAPIKey", "2018-05-02", 2); !ok {
+		t.Error("Expected the quota of the next day to be available")
+	}
+
+	// The counts of the previous days are deleted
+	if err := db.DeleteSigningQuotas("2018-05-02"); err != nil {
+		t.Fatalf("Error deleting the signing quotas: %v", err)
+	}
+	var count int
+	if err := db.QueryRow("SELECT count(*) FROM signingquota").Scan(&count); err != nil || count != 1 {
+		t.Errorf("Expected the count of the next day to be kept, got: %d, %v", count, err)
+	}
+}
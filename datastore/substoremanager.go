@@ -52,6 +52,11 @@ const getSubstoreSQL = `
 	FROM substore 
 	WHERE from_model_id=$1 AND serial_number=$2`
 
+const getSubstoreByIDSQL = `
+	SELECT id, account_id, from_model_id, store, serial_number, model_name 
+	FROM substore 
+	WHERE id=$1`
+
 const getUserSubstoreSQL = `
 	SELECT s.id, s.account_id, s.from_model_id, s.store, s.serial_number, s.model_name
 	FROM substore s
@@ -165,6 +170,24 @@ func (db *DB) GetSubstore(fromModelID int, serialNumber string) (Substore, error
 	return store, nil
 }
 
+// GetSubstoreByID fetches a sub-store in the database by its ID
+func (db *DB) GetSubstoreByID(storeID int) (Substore, error) {
+	store := Substore{}
+
+	row := db.QueryRow(getSubstoreByIDSQL, storeID)
+	err := row.Scan(&store.ID, &store.AccountID, &store.FromModelID, &store.Store, &store.SerialNumber, &store.ModelName)
+	if err != nil {
+		return store, fmt.Errorf("error retrieving database substore %d: %v", storeID, err)
+	}
+
+	store.FromModel, err = db.getModel(store.FromModelID)
+	if err != nil {
+		return store, fmt.Errorf("error retrieving database model %d: %v", store.FromModelID, err)
+	}
+
+	return store, nil
+}
+
 // GetSubstoreFilteredByUser fetches a sub-store in the database
 func (db *DB) GetSubstoreFilteredByUser(fromModelID int, serialNumber, username string) (Substore, error) {
 	store := Substore{}
@@ -75,7 +75,7 @@ func (tpmStore *TPM20KeypairOperator) ImportKeypair(authorityID, keyID, base64Pr
 	}
 
 	// Use the HMAC-ed auth-key as the key to encrypt the signing-key
-	sealedSigningKey, err := crypt.EncryptKey(base64PrivateKey, authKeyHash)
+	sealedSigningKey, err := crypt.SealKey([]byte(base64PrivateKey), authKeyHash)
 
 	// base64 encode the sealed signing-key for storage
 	base64SealedSigningkey := base64.StdEncoding.EncodeToString(sealedSigningKey)
@@ -127,7 +127,7 @@ func (tpmStore *TPM20KeypairOperator) generateEncryptionKey(authorityID, keyID s
 	}
 
 	// Encrypt and store the auth-key hash
-	encryptedAuthKeyHash, err := crypt.EncryptKey(string(encryptionKey[:]), tpmStore.secret)
+	encryptedAuthKeyHash, err := crypt.SealKey(encryptionKey, tpmStore.secret)
 	if err != nil {
 		return "", err
 	}
@@ -45,12 +45,12 @@ func getTPMKeyStoreWithMockCommand() *KeypairDatabase {
 	tpm20 := TPM20KeypairOperator{config.KeyStorePath, config.KeyStoreSecret, &mockTPM20Command{}}
 
 	// Prepare the memory store for the unsealed keys
-	memStore := asserts.NewMemoryKeypairManager()
+	memStore := newUnsealedKeypairManager()
 	db, _ := asserts.OpenDatabase(&asserts.DatabaseConfig{
 		KeypairManager: memStore,
 	})
 
-	keypairDB = KeypairDatabase{TPM20Store, db, &tpm20}
+	keypairDB = KeypairDatabase{TPM20Store, db, &tpm20, memStore}
 	return &keypairDB
 }
 
@@ -0,0 +1,324 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package datastore
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/CanonicalLtd/serial-vault/crypt"
+	"github.com/CanonicalLtd/serial-vault/service/log"
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpm2/transport"
+	"github.com/google/go-tpm/tpm2/transport/linuxtpm"
+	"github.com/google/go-tpm/tpm2/transport/tcp"
+)
+
+// The persistent handles of the primary key and the HMAC key in the TPM 2.0 module. The HMAC
+// key uses the same handle as with the TPM2.0 tools, so a key created by the tools is reused
+const (
+	handleNativeParent = tpm2.TPMHandle(0x81010001)
+	handleNativeHash   = tpm2.TPMHandle(0x81010002)
+)
+
+// simulatorPrefix marks a TPM 2.0 device that is a TPM simulator, at its command host:port
+const simulatorPrefix = "simulator:"
+
+// Common error messages
+var (
+	ErrorTPMNotInitialized = errors.New("The TPM 2.0 keystore has not been initialized")
+)
+
+// TPM20NativeKeypairOperator is the operator that handles interactions with the TPM2.0 device and
+// signing-keys, talking to the device, or to a TPM simulator, without the TPM2.0 tools
+type TPM20NativeKeypairOperator struct {
+	device string
+	secret string
+	mu     sync.Mutex
+}
+
+// ImportKeypair adds a new signing-key to the TPM2.0 store.
+// The main TPM2.0 operations:
+//   - Use the auth/key-id as the key
+//   - Create a persistent KeyedHash key, if there is not one already
+//   - Use TPM to HMAC the auth-key (using the KeyedHash key)
+//   - Use AES symmetric encryption to encrypt the signing-key file (using Go)
+//   - Encrypt the auth-key and store in the database (using Go)
+func (tpmStore *TPM20NativeKeypairOperator) ImportKeypair(authorityID, keyID, base64PrivateKey string) (string, error) {
+	// Generate an HMAC hash of the signing-key details
+	authKeyHash, err := tpmStore.generateEncryptionKey(authorityID, keyID)
+	if err != nil {
+		return "", err
+	}
+
+	// Use the HMAC-ed auth-key as the key to encrypt the signing-key
+	sealedSigningKey, err := crypt.SealKey([]byte(base64PrivateKey), authKeyHash)
+
+	// base64 encode the sealed signing-key for storage
+	base64SealedSigningkey := base64.StdEncoding.EncodeToString(sealedSigningKey)
+
+	return base64SealedSigningkey, err
+}
+
+// UnsealKeypair unseals a TPM-sealed signing-key and stores it in the memory store
+//   - Decrypt the auth-key
+//   - Decrypt the signing key
+//   - Load into memory store
+func (tpmStore *TPM20NativeKeypairOperator) UnsealKeypair(authorityID string, keyID string, base64SealedSigningKey string) error {
+	return unsealKeypair(authorityID, keyID, base64SealedSigningKey)
+}
+
+// generateEncryptionKey takes the authority and the key details and uses the TPM 2.0 module to create a HMAC hash of the data.
+// This hash is used as the key for symmetric encryption of the signing-key.
+func (tpmStore *TPM20NativeKeypairOperator) generateEncryptionKey(authorityID, keyID string) (string, error) {
+	tpmStore.mu.Lock()
+	defer tpmStore.mu.Unlock()
+
+	tpm, err := openTPM(tpmStore.device)
+	if err != nil {
+		return "", err
+	}
+	defer tpm.Close()
+
+	hashKey, err := tpmStore.hashKey(tpm)
+	if err != nil {
+		return "", err
+	}
+
+	// Use the TPM module to hash the plain-text
+	rsp, err := tpm2.Hmac{
+		Handle:  tpm2.AuthHandle{Handle: hashKey.Handle, Name: hashKey.Name, Auth: tpm2.PasswordAuth(nil)},
+		Buffer:  tpm2.TPM2BMaxBuffer{Buffer: []byte(crypt.GenerateAuthKey(authorityID, keyID))},
+		HashAlg: tpm2.TPMAlgSHA256,
+	}.Execute(tpm)
+	if err != nil {
+		log.Printf("Error in TPM HMAC, %v", err)
+		return "", err
+	}
+	encryptionKey := rsp.OutHMAC.Buffer
+
+	// Encrypt and store the auth-key hash
+	encryptedAuthKeyHash, err := crypt.SealKey(encryptionKey, tpmStore.secret)
+	if err != nil {
+		return "", err
+	}
+
+	// Encrypt the HMAC-ed auth-key for storage
+	base64AuthKeyHash := base64.StdEncoding.EncodeToString(encryptedAuthKeyHash)
+	Environ.DB.PutSetting(Setting{Code: crypt.GenerateAuthKey(authorityID, keyID), Data: base64AuthKeyHash})
+
+	return string(encryptionKey), nil
+}
+
+// hashKey returns the persistent KeyedHash key, creating it under the primary key when it
+// does not exist
+func (tpmStore *TPM20NativeKeypairOperator) hashKey(tpm transport.TPM) (*tpm2.NamedHandle, error) {
+	// Check if we've already created a key for this operation
+	if _, err := Environ.DB.GetSetting(handleString(handleNativeHash)); err == nil {
+		log.Println("Using the existing key for 'hash'")
+		return readNamedHandle(tpm, handleNativeHash)
+	}
+
+	// The key may be in the TPM already, e.g. when the settings have been recreated
+	if hash, err := readNamedHandle(tpm, handleNativeHash); err == nil {
+		log.Println("Using the existing TPM key for 'hash'")
+		Environ.DB.PutSetting(Setting{Code: handleString(handleNativeHash), Data: handleString(handleNativeHash)})
+		return hash, nil
+	}
+
+	// The primary key is created when the keystore is initialized
+	if _, err := Environ.DB.GetSetting("parent"); err != nil {
+		return nil, ErrorTPMNotInitialized
+	}
+	parent, err := readNamedHandle(tpm, handleNativeParent)
+	if err != nil {
+		return nil, err
+	}
+	parentAuth := tpm2.AuthHandle{Handle: parent.Handle, Name: parent.Name, Auth: tpm2.PasswordAuth(nil)}
+
+	// Create the key in the hierarchy
+	created, err := tpm2.Create{
+		ParentHandle: parentAuth,
+		InPublic:     tpm2.New2B(hmacKeyTemplate),
+	}.Execute(tpm)
+	if err != nil {
+		log.Printf("Error in TPM create, %v", err)
+		return nil, err
+	}
+
+	// Load the key in the hierarchy
+	loaded, err := tpm2.Load{
+		ParentHandle: parentAuth,
+		InPrivate:    created.OutPrivate,
+		InPublic:     created.OutPublic,
+	}.Execute(tpm)
+	if err != nil {
+		log.Printf("Error in TPM load, %v", err)
+		return nil, err
+	}
+	defer tpm2.FlushContext{FlushHandle: loaded.ObjectHandle}.Execute(tpm)
+
+	// Move the key to non-volatile storage, so it will survive a power cycle
+	if err := persistKey(tpm, loaded.ObjectHandle, loaded.Name, handleNativeHash); err != nil {
+		return nil, err
+	}
+
+	// Store the handle so we know that it has been created
+	Environ.DB.PutSetting(Setting{Code: handleString(handleNativeHash), Data: handleString(handleNativeHash)})
+
+	return &tpm2.NamedHandle{Handle: handleNativeHash, Name: loaded.Name}, nil
+}
+
+// hmacKeyTemplate is the KeyedHash key that hashes the auth-keys
+var hmacKeyTemplate = tpm2.TPMTPublic{
+	Type:    tpm2.TPMAlgKeyedHash,
+	NameAlg: tpm2.TPMAlgSHA256,
+	ObjectAttributes: tpm2.TPMAObject{
+		SignEncrypt:         true,
+		FixedTPM:            true,
+		FixedParent:         true,
+		SensitiveDataOrigin: true,
+		UserWithAuth:        true,
+	},
+	Parameters: tpm2.NewTPMUPublicParms(tpm2.TPMAlgKeyedHash,
+		&tpm2.TPMSKeyedHashParms{
+			Scheme: tpm2.TPMTKeyedHashScheme{
+				Scheme: tpm2.TPMAlgHMAC,
+				Details: tpm2.NewTPMUSchemeKeyedHash(tpm2.TPMAlgHMAC,
+					&tpm2.TPMSSchemeHMAC{HashAlg: tpm2.TPMAlgSHA256}),
+			},
+		}),
+}
+
+// tpm2InitializeNativeKeystore creates the primary key in the owner hierarchy of the TPM 2.0
+// device and makes it persistent. An existing primary key is kept, so the keys that have
+// been created under it stay usable
+func tpm2InitializeNativeKeystore(device string) error {
+	tpm, err := openTPM(device)
+	if err != nil {
+		return err
+	}
+	defer tpm.Close()
+
+	if _, err := readNamedHandle(tpm, handleNativeParent); err == nil {
+		log.Println("Using the existing TPM primary key")
+	} else {
+		// Create the primary key in the hierarchy
+		primary, err := tpm2.CreatePrimary{
+			PrimaryHandle: tpm2.TPMRHOwner,
+			InPublic:      tpm2.New2B(tpm2.RSASRKTemplate),
+		}.Execute(tpm)
+		if err != nil {
+			log.Printf("Error in TPM createprimary, %v", err)
+			return err
+		}
+		defer tpm2.FlushContext{FlushHandle: primary.ObjectHandle}.Execute(tpm)
+
+		if err := persistKey(tpm, primary.ObjectHandle, primary.Name, handleNativeParent); err != nil {
+			return err
+		}
+	}
+
+	// Save the primary key handle in the database
+	err = Environ.DB.PutSetting(Setting{Code: "parent", Data: handleString(handleNativeParent)})
+	if err != nil {
+		log.Printf("Error in saving the parent key handle in settings, %v", err)
+		return err
+	}
+	return nil
+}
+
+// persistKey moves a loaded key to non-volatile storage at the persistent handle
+func persistKey(tpm transport.TPM, handle tpm2.TPMHandle, name tpm2.TPM2BName, persistent tpm2.TPMHandle) error {
+	_, err := tpm2.EvictControl{
+		Auth:             tpm2.TPMRHOwner,
+		ObjectHandle:     &tpm2.NamedHandle{Handle: handle, Name: name},
+		PersistentHandle: persistent,
+	}.Execute(tpm)
+	if err != nil {
+		log.Printf("Error in TPM evictcontrol, %v", err)
+	}
+	return err
+}
+
+// readNamedHandle returns the name of a persistent key, which is needed to authorize its use
+func readNamedHandle(tpm transport.TPM, handle tpm2.TPMHandle) (*tpm2.NamedHandle, error) {
+	rsp, err := tpm2.ReadPublic{ObjectHandle: handle}.Execute(tpm)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot read the TPM key %s: %v", handleString(handle), err)
+	}
+	return &tpm2.NamedHandle{Handle: handle, Name: rsp.Name}, nil
+}
+
+// handleString formats a handle as it is stored in the settings
+func handleString(handle tpm2.TPMHandle) string {
+	return fmt.Sprintf("0x%08X", uint32(handle))
+}
+
+// openTPM opens the TPM 2.0 device, e.g. /dev/tpmrm0, or a TPM simulator using the TCP protocol of
+// the reference implementation, e.g. simulator:localhost:2321. The simulator is powered on
+// and started, as it is not started by the kernel
+func openTPM(device string) (transport.TPMCloser, error) {
+	if !strings.HasPrefix(device, simulatorPrefix) {
+		return linuxtpm.Open(device)
+	}
+
+	commandAddress := strings.TrimPrefix(device, simulatorPrefix)
+	platformAddress, err := simulatorPlatformAddress(commandAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	tpm, err := tcp.Open(tcp.Config{CommandAddress: commandAddress, PlatformAddress: platformAddress})
+	if err != nil {
+		return nil, err
+	}
+	if err := tpm.PowerOn(); err != nil {
+		tpm.Close()
+		return nil, err
+	}
+	_, err = tpm2.Startup{StartupType: tpm2.TPMSUClear}.Execute(tpm)
+	if err != nil && !errors.Is(err, tpm2.TPMRCInitialize) {
+		// An initialized simulator has already been started
+		tpm.Close()
+		return nil, err
+	}
+	return tpm, nil
+}
+
+// simulatorPlatformAddress returns the address of the platform service of the simulator, which
+// listens on the port after the command port
+func simulatorPlatformAddress(commandAddress string) (string, error) {
+	host, port, err := net.SplitHostPort(commandAddress)
+	if err != nil {
+		return "", err
+	}
+	p, err := strconv.Atoi(port)
+	if err != nil {
+		return "", fmt.Errorf("Invalid TPM simulator port: %s", port)
+	}
+	return net.JoinHostPort(host, strconv.Itoa(p+1)), nil
+}
@@ -0,0 +1,184 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package datastore
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/CanonicalLtd/serial-vault/config"
+	"github.com/snapcore/snapd/asserts"
+)
+
+// envTPMSimulator is the command address of a TPM simulator for the TPM 2.0 tests, e.g. localhost:2321.
+// The simulator is the TCG reference implementation, with the platform service on the next port
+const envTPMSimulator = "SERIAL_VAULT_TPM_SIMULATOR"
+
+// getTPMSimulatorKeyStore opens the TPM 2.0 keystore on the simulator, skipping the test
+// when no simulator is available
+func getTPMSimulatorKeyStore(t *testing.T) (*KeypairDatabase, *generateKeypairMockDB) {
+	address := os.Getenv(envTPMSimulator)
+	if len(address) == 0 {
+		t.Skipf("No TPM simulator, set %s to run the test", envTPMSimulator)
+	}
+
+	settings := config.Settings{KeyStoreType: "tpm2.0", KeyStoreSecret: "this needs to be 32 bytes long!!", TPMDevice: simulatorPrefix + address}
+	mdb := &generateKeypairMockDB{sealedKeyMockDB: sealedKeyMockDB{settings: map[string]Setting{}}}
+	Environ = &Env{Config: settings, DB: mdb}
+
+	if err := TPM2InitializeKeystore(nil); err != nil {
+		t.Fatalf("Error initializing the TPM keystore: %v", err)
+	}
+
+	keystore, err := getKeyStore(settings)
+	if err != nil {
+		t.Fatalf("Error opening the TPM keystore: %v", err)
+	}
+	Environ.KeypairDB = keystore
+	return keystore, mdb
+}
+
+func TestTPMNativeImportUnsealSign(t *testing.T) {
+	keystore, mdb := getTPMSimulatorKeyStore(t)
+
+	signingKey, err := ioutil.ReadFile("../keystore/TestKey.asc")
+	if err != nil {
+		t.Fatalf("Error reading the signing-key file: %v", err)
+	}
+	base64PrivateKey := base64.StdEncoding.EncodeToString(signingKey)
+
+	privateKey, sealedKey, err := keystore.ImportSigningKey("System", base64PrivateKey)
+	if err != nil {
+		t.Fatalf("Error importing the signing-key: %v", err)
+	}
+	keyID := privateKey.PublicKey().ID()
+	if sealedKey == base64PrivateKey {
+		t.Error("The sealed and unsealed signing-keys are the same")
+	}
+	if _, ok := mdb.settings[handleString(handleNativeHash)]; !ok {
+		t.Error("Expected the HMAC key handle to be stored")
+	}
+
+	// The TPM hashes the auth-key with the same key each time
+	operator := keystore.keypairOperator.(*TPM20NativeKeypairOperator)
+	authKey1, err := operator.generateEncryptionKey("System", keyID)
+	if err != nil {
+		t.Fatalf("Error generating the auth-key: %v", err)
+	}
+	authKey2, err := operator.generateEncryptionKey("System", keyID)
+	if err != nil {
+		t.Fatalf("Error generating the auth-key: %v", err)
+	}
+	if authKey1 != authKey2 || len(authKey1) != 32 {
+		t.Errorf("Expected the same 32-byte auth-key from the TPM")
+	}
+
+	// Unseal and sign with a fresh keystore, as a restarted service would
+	keystore, err = getKeyStore(Environ.Config)
+	if err != nil {
+		t.Fatalf("Error opening the TPM keystore: %v", err)
+	}
+	headers := map[string]interface{}{
+		"authority-id": "System",
+		"series":       "16",
+		"brand-id":     "System",
+		"model":        "alder",
+		"architecture": "amd64",
+		"gadget":       "alder-gadget",
+		"kernel":       "alder-linux",
+		"timestamp":    time.Now().Format(time.RFC3339),
+	}
+	modelAssert, err := keystore.SignAssertion(asserts.ModelType, headers, nil, "System", keyID, sealedKey)
+	if err != nil {
+		t.Fatalf("Error signing the assertion: %v", err)
+	}
+	if err = asserts.SignatureCheck(modelAssert, privateKey.PublicKey()); err != nil {
+		t.Errorf("Invalid assertion signature: %v", err)
+	}
+
+	// Initializing the keystore again keeps the keys
+	if err := TPM2InitializeKeystore(nil); err != nil {
+		t.Fatalf("Error initializing the TPM keystore again: %v", err)
+	}
+	authKey3, err := operator.generateEncryptionKey("System", keyID)
+	if err != nil {
+		t.Fatalf("Error generating the auth-key: %v", err)
+	}
+	if authKey3 != authKey1 {
+		t.Error("Expected the same auth-key after initializing the keystore again")
+	}
+}
+
+func TestTPMNativeNotInitialized(t *testing.T) {
+	address := os.Getenv(envTPMSimulator)
+	if len(address) == 0 {
+		t.Skipf("No TPM simulator, set %s to run the test", envTPMSimulator)
+	}
+
+	mdb := &generateKeypairMockDB{sealedKeyMockDB: sealedKeyMockDB{settings: map[string]Setting{}}}
+	Environ = &Env{Config: config.Settings{KeyStoreType: "tpm2.0"}, DB: mdb}
+	operator := TPM20NativeKeypairOperator{device: simulatorPrefix + address, secret: "secret"}
+	if _, err := operator.ImportKeypair("System", "abc", "c2lnbmluZy1rZXk="); err != ErrorTPMNotInitialized {
+		t.Errorf("Expected the not initialized error, got: %v", err)
+	}
+}
+
+func TestTPMNativeOpen(t *testing.T) {
+	tests := []struct {
+		device string
+		err    bool
+	}{
+		{filepath.Join(t.TempDir(), "not-a-device"), true},
+		{simulatorPrefix + "localhost", true},
+		{simulatorPrefix + "localhost:tpm", true},
+		{simulatorPrefix + "localhost:1", true},
+	}
+	ioutil.WriteFile(tests[0].device, []byte{}, 0600)
+
+	for _, tt := range tests {
+		_, err := openTPM(tt.device)
+		if (err != nil) != tt.err {
+			t.Errorf("%s: expected error %v, got: %v", tt.device, tt.err, err)
+		}
+	}
+
+	address, err := simulatorPlatformAddress("localhost:2321")
+	if err != nil || address != "localhost:2322" {
+		t.Errorf("Expected the platform address localhost:2322, got: %s %v", address, err)
+	}
+}
+
+func TestTPMNativeGetKeyStore(t *testing.T) {
+	settings := config.Settings{KeyStoreType: "tpm2.0", KeyStoreSecret: "this needs to be 32 bytes long!!", TPMDevice: "/dev/tpmrm0"}
+	Environ = &Env{Config: settings}
+
+	keystore, err := getKeyStore(settings)
+	if err != nil {
+		t.Fatalf("Error opening the TPM keystore: %v", err)
+	}
+	if _, ok := keystore.keypairOperator.(*TPM20NativeKeypairOperator); !ok {
+		t.Errorf("Expected the native TPM operator, got: %T", keystore.keypairOperator)
+	}
+}
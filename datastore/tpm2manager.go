@@ -31,9 +31,16 @@ import (
 // Main TPM 2.0 operations:
 //   - takeownership
 //   - createprimary
+//
+// When a TPM device is configured, the device is used directly instead of the TPM 2.0 tools,
+// and the primary key is made persistent rather than saved in a context file.
 func TPM2InitializeKeystore(command TPM20Command) error {
 	log.Println("Initialize the TPM Keystore...")
 
+	if command == nil && len(Environ.Config.TPMDevice) > 0 {
+		return tpm2InitializeNativeKeystore(Environ.Config.TPMDevice)
+	}
+
 	// Generate a unique file name to hold the primary key context
 	primaryKeyContext, err := ioutil.TempFile(Environ.Config.KeyStorePath, ".primary")
 	if err != nil {
@@ -26,8 +26,8 @@ import (
 )
 
 func TestTPM2InitializeKeystore(t *testing.T) {
-	// Set up the environment variables
-	config := config.Settings{KeyStorePath: "../keystore", KeyStoreType: "tpm2.0", KeyStoreSecret: "this needs to be 32 bytes long!!"}
+	// Set up the environment variables. The primary key context file is created in the keystore path
+	config := config.Settings{KeyStorePath: t.TempDir(), KeyStoreType: "tpm2.0", KeyStoreSecret: "this needs to be 32 bytes long!!"}
 	Environ = &Env{Config: config, DB: &MockDB{}}
 
 	err := TPM2InitializeKeystore(&mockTPM20Command{})
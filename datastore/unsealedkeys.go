@@ -0,0 +1,287 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017-2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package datastore
+
+import (
+	"crypto/rsa"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/CanonicalLtd/serial-vault/service/log"
+	"github.com/CanonicalLtd/serial-vault/service/metric"
+	"github.com/snapcore/snapd/asserts"
+)
+
+// Default idle time after which an unsealed signing-key is evicted from memory, and
+// the interval for evicting the idle and disabled signing-keys, in seconds
+const (
+	defaultUnsealedKeyTTL           = 900
+	defaultUnsealedKeyPruneInterval = 60
+)
+
+// unsealedKeypairManager is the memory store for the signing-keys that have been unsealed
+// from the database or TPM keystore. A key that is not used for the idle TTL is evicted, and
+// the key material is zeroed when it is evicted, as far as the RSA key allows. A key that is
+// evicted while it is signing is zeroed when the last signing is done
+type unsealedKeypairManager struct {
+	asserts.KeypairManager
+	mu       sync.Mutex
+	lastUsed map[string]time.Time
+	rsaKeys  map[string]*rsa.PrivateKey
+	users    map[string]int
+	retired  map[string][]*rsa.PrivateKey
+	now      func() time.Time
+}
+
+func newUnsealedKeypairManager() *unsealedKeypairManager {
+	return &unsealedKeypairManager{
+		KeypairManager: asserts.NewMemoryKeypairManager(),
+		lastUsed:       make(map[string]time.Time),
+		rsaKeys:        make(map[string]*rsa.PrivateKey),
+		users:          make(map[string]int),
+		retired:        make(map[string][]*rsa.PrivateKey),
+		now:            time.Now,
+	}
+}
+
+// Put stores an unsealed private-key
+func (ukm *unsealedKeypairManager) Put(privKey asserts.PrivateKey) error {
+	ukm.mu.Lock()
+	defer ukm.mu.Unlock()
+	return ukm.put(privKey)
+}
+
+func (ukm *unsealedKeypairManager) put(privKey asserts.PrivateKey) error {
+	if err := ukm.KeypairManager.Put(privKey); err != nil {
+		return err
+	}
+	ukm.lastUsed[privKey.PublicKey().ID()] = ukm.now()
+	ukm.updateMetric()
+	return nil
+}
+
+// PutRSAKey stores an unsealed RSA private-key, keeping a reference to it so the key
+// material can be zeroed when it is evicted
+func (ukm *unsealedKeypairManager) PutRSAKey(rsaKey *rsa.PrivateKey) error {
+	ukm.mu.Lock()
+	defer ukm.mu.Unlock()
+
+	privKey := asserts.RSAPrivateKey(rsaKey)
+	if err := ukm.put(privKey); err != nil {
+		return err
+	}
+	ukm.rsaKeys[privKey.PublicKey().ID()] = rsaKey
+	return nil
+}
+
+// Get returns an unsealed private-key, unless it has been idle for longer than the TTL
+func (ukm *unsealedKeypairManager) Get(keyID string) (asserts.PrivateKey, error) {
+	ukm.mu.Lock()
+	defer ukm.mu.Unlock()
+
+	if lastUsed, ok := ukm.lastUsed[keyID]; ok && ukm.now().Sub(lastUsed) > unsealedKeyTTL() {
+		ukm.evict(keyID)
+	}
+
+	privKey, err := ukm.KeypairManager.Get(keyID)
+	if err != nil {
+		return nil, err
+	}
+	ukm.lastUsed[keyID] = ukm.now()
+	return privKey, nil
+}
+
+// Delete evicts an unsealed private-key
+func (ukm *unsealedKeypairManager) Delete(keyID string) error {
+	ukm.mu.Lock()
+	defer ukm.mu.Unlock()
+
+	if _, ok := ukm.lastUsed[keyID]; !ok {
+		// Use the memory store's key-not-found error
+		return ukm.KeypairManager.Delete(keyID)
+	}
+	ukm.evict(keyID)
+	return nil
+}
+
+// EvictIdle evicts the private-keys that have been idle for longer than the TTL, and
+// the ones that are not in the list of active keys
+func (ukm *unsealedKeypairManager) EvictIdle(active map[string]bool) {
+	ukm.mu.Lock()
+	defer ukm.mu.Unlock()
+
+	for keyID, lastUsed := range ukm.lastUsed {
+		if ukm.now().Sub(lastUsed) > unsealedKeyTTL() || (active != nil && !active[keyID]) {
+			ukm.evict(keyID)
+		}
+	}
+}
+
+// Acquire marks the private-key as in use, until the returned function is called to release
+// it. The key can still be evicted, but it is not zeroed until the last user releases it
+func (ukm *unsealedKeypairManager) Acquire(keyID string) func() {
+	ukm.mu.Lock()
+	defer ukm.mu.Unlock()
+	ukm.users[keyID]++
+
+	return func() {
+		ukm.mu.Lock()
+		defer ukm.mu.Unlock()
+
+		ukm.users[keyID]--
+		if ukm.users[keyID] > 0 {
+			return
+		}
+		delete(ukm.users, keyID)
+		for _, rsaKey := range ukm.retired[keyID] {
+			zeroRSAPrivateKey(rsaKey)
+		}
+		delete(ukm.retired, keyID)
+	}
+}
+
+// Len returns the number of unsealed private-keys
+func (ukm *unsealedKeypairManager) Len() int {
+	ukm.mu.Lock()
+	defer ukm.mu.Unlock()
+	return len(ukm.lastUsed)
+}
+
+// evict removes the private-key from memory and zeroes it, or leaves it to be zeroed when it
+// is released if it is in use. The mutex must be held
+func (ukm *unsealedKeypairManager) evict(keyID string) {
+	ukm.KeypairManager.Delete(keyID)
+	if rsaKey, ok := ukm.rsaKeys[keyID]; ok {
+		if ukm.users[keyID] > 0 {
+			ukm.retired[keyID] = append(ukm.retired[keyID], rsaKey)
+		} else {
+			zeroRSAPrivateKey(rsaKey)
+		}
+	}
+	delete(ukm.rsaKeys, keyID)
+	delete(ukm.lastUsed, keyID)
+	ukm.updateMetric()
+	log.Infof("Evicted the unsealed signing-key %s", keyID)
+}
+
+func (ukm *unsealedKeypairManager) updateMetric() {
+	metric.UnsealedKeysGauge.Set(float64(len(ukm.lastUsed)))
+}
+
+// zeroRSAPrivateKey overwrites the private values of the RSA key. The values that the
+// crypto library derives internally from the key cannot be reached, so they are left
+// for the garbage collector
+func zeroRSAPrivateKey(rsaKey *rsa.PrivateKey) {
+	zeroBigInt(rsaKey.D)
+	for _, p := range rsaKey.Primes {
+		zeroBigInt(p)
+	}
+	zeroBigInt(rsaKey.Precomputed.Dp)
+	zeroBigInt(rsaKey.Precomputed.Dq)
+	zeroBigInt(rsaKey.Precomputed.Qinv)
+	for _, crt := range rsaKey.Precomputed.CRTValues {
+		zeroBigInt(crt.Exp)
+		zeroBigInt(crt.Coeff)
+		zeroBigInt(crt.R)
+	}
+}
+
+func zeroBigInt(n *big.Int) {
+	if n == nil {
+		return
+	}
+	words := n.Bits()
+	for i := range words {
+		words[i] = 0
+	}
+	n.SetInt64(0)
+}
+
+// PruneUnsealedKeys evicts the idle signing-keys from memory at the interval, until the done
+// channel is closed. The signing-keys of the keypairs that have been disabled or deleted are
+// also evicted, as they may have been disabled by another service
+func PruneUnsealedKeys(done <-chan struct{}) {
+	ticker := time.NewTicker(unsealedKeyPruneInterval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			pruneUnsealedKeys()
+		}
+	}
+}
+
+func pruneUnsealedKeys() {
+	if Environ.KeypairDB == nil || Environ.KeypairDB.unsealedKeys == nil {
+		return
+	}
+
+	// Authentication is not checked for the invalid role, so this lists all the keypairs
+	keypairs, err := Environ.DB.ListAllowedKeypairs(User{})
+	if err != nil {
+		// Only evict the idle keys, and check the keypairs again at the next interval
+		Environ.KeypairDB.unsealedKeys.EvictIdle(nil)
+		return
+	}
+
+	// Expired keys cannot sign, so they are evicted like the disabled keys
+	now := time.Now()
+	active := map[string]bool{}
+	for _, k := range keypairs {
+		active[k.KeyID] = active[k.KeyID] || (k.Active && !k.ExpiresWithin(now, 0))
+	}
+	Environ.KeypairDB.unsealedKeys.EvictIdle(active)
+}
+
+// EvictKeypair removes the unsealed signing-key of a keypair from memory, so it must be
+// unsealed again before it is used
+func EvictKeypair(keypairID int) error {
+	if Environ.KeypairDB == nil || Environ.KeypairDB.unsealedKeys == nil {
+		return nil
+	}
+
+	keypair, err := Environ.DB.GetKeypair(keypairID)
+	if err != nil {
+		return err
+	}
+
+	Environ.KeypairDB.unsealedKeys.Delete(keypair.KeyID)
+	return nil
+}
+
+// unsealedKeyTTL returns the configured idle time for an unsealed signing-key
+func unsealedKeyTTL() time.Duration {
+	if Environ.Config.UnsealedKeyTTL > 0 {
+		return time.Duration(Environ.Config.UnsealedKeyTTL) * time.Second
+	}
+	return defaultUnsealedKeyTTL * time.Second
+}
+
+// unsealedKeyPruneInterval returns the configured interval for evicting the signing-keys
+func unsealedKeyPruneInterval() time.Duration {
+	if Environ.Config.UnsealedKeyPruneInterval > 0 {
+		return time.Duration(Environ.Config.UnsealedKeyPruneInterval) * time.Second
+	}
+	return defaultUnsealedKeyPruneInterval * time.Second
+}
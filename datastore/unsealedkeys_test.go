@@ -0,0 +1,209 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package datastore
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/CanonicalLtd/serial-vault/config"
+	"github.com/CanonicalLtd/serial-vault/crypt"
+	"github.com/CanonicalLtd/serial-vault/service/metric"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/snapcore/snapd/asserts"
+)
+
+func unsealedKeysMetric() float64 {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(metric.UnsealedKeysGauge)
+	metrics, err := registry.Gather()
+	if err != nil || len(metrics) != 1 {
+		return -1
+	}
+	return metrics[0].GetMetric()[0].GetGauge().GetValue()
+}
+
+func TestUnsealedKeyIdleEviction(t *testing.T) {
+	Environ = &Env{Config: config.Settings{UnsealedKeyTTL: 60}}
+
+	signingKey, err := ioutil.ReadFile("../keystore/TestKey.asc")
+	if err != nil {
+		t.Fatalf("Error reading the signing-key file: %v", err)
+	}
+	rsaKey, _, err := crypt.DeserializeRSAPrivateKey(base64.StdEncoding.EncodeToString(signingKey))
+	if err != nil {
+		t.Fatalf("Error deserializing the signing-key: %v", err)
+	}
+	keyID := asserts.RSAPrivateKey(rsaKey).PublicKey().ID()
+
+	now := time.Now()
+	ukm := newUnsealedKeypairManager()
+	ukm.now = func() time.Time { return now }
+
+	if err := ukm.PutRSAKey(rsaKey); err != nil {
+		t.Fatalf("Error storing the unsealed key: %v", err)
+	}
+	if unsealedKeysMetric() != 1 {
+		t.Errorf("Expected 1 unsealed key, got %v", unsealedKeysMetric())
+	}
+
+	// Using the key keeps it in memory
+	now = now.Add(50 * time.Second)
+	if _, err := ukm.Get(keyID); err != nil {
+		t.Fatalf("Error fetching the unsealed key: %v", err)
+	}
+	now = now.Add(50 * time.Second)
+	ukm.EvictIdle(nil)
+	if ukm.Len() != 1 {
+		t.Fatalf("Expected the used key to stay unsealed")
+	}
+
+	// An idle key is evicted and zeroed
+	now = now.Add(61 * time.Second)
+	if _, err := ukm.Get(keyID); !asserts.IsKeyNotFound(err) {
+		t.Errorf("Expected the idle key to be evicted, got: %v", err)
+	}
+	if ukm.Len() != 0 {
+		t.Errorf("Expected no unsealed keys, got %d", ukm.Len())
+	}
+	if rsaKey.D.Sign() != 0 || rsaKey.Primes[0].Sign() != 0 || rsaKey.Precomputed.Dp.Sign() != 0 {
+		t.Error("Expected the evicted key to be zeroed")
+	}
+	if unsealedKeysMetric() != 0 {
+		t.Errorf("Expected no unsealed keys, got %v", unsealedKeysMetric())
+	}
+}
+
+func TestUnsealedKeyEvictedInUse(t *testing.T) {
+	Environ = &Env{Config: config.Settings{UnsealedKeyTTL: 60}}
+
+	signingKey, err := ioutil.ReadFile("../keystore/TestKey.asc")
+	if err != nil {
+		t.Fatalf("Error reading the signing-key file: %v", err)
+	}
+	rsaKey, _, err := crypt.DeserializeRSAPrivateKey(base64.StdEncoding.EncodeToString(signingKey))
+	if err != nil {
+		t.Fatalf("Error deserializing the signing-key: %v", err)
+	}
+	keyID := asserts.RSAPrivateKey(rsaKey).PublicKey().ID()
+
+	ukm := newUnsealedKeypairManager()
+	if err := ukm.PutRSAKey(rsaKey); err != nil {
+		t.Fatalf("Error storing the unsealed key: %v", err)
+	}
+
+	// A key that is in use is evicted, but not zeroed until the last user releases it
+	release1 := ukm.Acquire(keyID)
+	release2 := ukm.Acquire(keyID)
+	if err := ukm.Delete(keyID); err != nil {
+		t.Fatalf("Error evicting the unsealed key: %v", err)
+	}
+	if ukm.Len() != 0 {
+		t.Errorf("Expected no unsealed keys, got %d", ukm.Len())
+	}
+
+	release1()
+	if rsaKey.D.Sign() == 0 {
+		t.Fatal("Expected the key in use not to be zeroed")
+	}
+	release2()
+	if rsaKey.D.Sign() != 0 || rsaKey.Primes[0].Sign() != 0 {
+		t.Error("Expected the released key to be zeroed")
+	}
+}
+
+func TestUnsealedKeyDisabledEviction(t *testing.T) {
+	settings := config.Settings{KeyStoreType: "database", KeyStoreSecret: "this needs to be something secure"}
+	mdb := &sealedKeyMockDB{settings: map[string]Setting{}}
+	Environ = &Env{Config: settings, DB: mdb}
+
+	keyID := testKeyID(t)
+	legacySealedKeypair(t, mdb, 1, keyID, settings.KeyStoreSecret)
+	mdb.keypairs[0].Active = true
+
+	keystore, err := getKeyStore(settings)
+	if err != nil {
+		t.Fatalf("Error opening the keystore: %v", err)
+	}
+	Environ.KeypairDB = keystore
+
+	if err := keystore.LoadKeypair("System", keyID, mdb.keypairs[0].SealedKey); err != nil {
+		t.Fatalf("Error unsealing the keypair: %v", err)
+	}
+	if keystore.unsealedKeys.Len() != 1 {
+		t.Fatalf("Expected 1 unsealed key, got %d", keystore.unsealedKeys.Len())
+	}
+
+	// An active keypair stays unsealed
+	pruneUnsealedKeys()
+	if keystore.unsealedKeys.Len() != 1 {
+		t.Fatalf("Expected the active key to stay unsealed")
+	}
+
+	// A keypair disabled by another service is evicted when the keys are pruned
+	mdb.keypairs[0].Active = false
+	pruneUnsealedKeys()
+	if keystore.unsealedKeys.Len() != 0 {
+		t.Errorf("Expected the disabled key to be evicted")
+	}
+
+	// A keypair disabled by this service is evicted straight away
+	if err := keystore.LoadKeypair("System", keyID, mdb.keypairs[0].SealedKey); err != nil {
+		t.Fatalf("Error unsealing the keypair: %v", err)
+	}
+	if err := EvictKeypair(1); err != nil {
+		t.Fatalf("Error evicting the keypair: %v", err)
+	}
+	if keystore.unsealedKeys.Len() != 0 {
+		t.Errorf("Expected the disabled key to be evicted")
+	}
+
+	// A deleted keypair is evicted when the keys are pruned
+	if err := keystore.LoadKeypair("System", keyID, mdb.keypairs[0].SealedKey); err != nil {
+		t.Fatalf("Error unsealing the keypair: %v", err)
+	}
+	mdb.keypairs = nil
+	pruneUnsealedKeys()
+	if keystore.unsealedKeys.Len() != 0 {
+		t.Errorf("Expected the deleted key to be evicted")
+	}
+
+	if err := EvictKeypair(1); err == nil {
+		t.Error("Expected an error for an unknown keypair")
+	}
+}
+
+func TestUnsealedKeyPruneInterval(t *testing.T) {
+	env := Environ
+	defer func() { Environ = env }()
+
+	Environ = &Env{Config: config.Settings{}}
+	if interval := unsealedKeyPruneInterval(); interval != time.Minute {
+		t.Errorf("Expected the default interval, got: %v", interval)
+	}
+
+	Environ = &Env{Config: config.Settings{UnsealedKeyPruneInterval: 5}}
+	if interval := unsealedKeyPruneInterval(); interval != 5*time.Second {
+		t.Errorf("Expected the configured interval, got: %v", interval)
+	}
+}
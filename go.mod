@@ -1,6 +1,6 @@
 module github.com/CanonicalLtd/serial-vault
 
-go 1.18
+go 1.22
 
 require (
 	github.com/Masterminds/squirrel v1.2.0
@@ -9,6 +9,7 @@ require (
 	github.com/dgrijalva/jwt-go v3.2.0+incompatible
 	github.com/getsentry/sentry-go v0.8.0
 	github.com/golang-jwt/jwt/v5 v5.2.1
+	github.com/google/go-tpm v0.9.8
 	github.com/gorilla/csrf v1.0.3-0.20161122164500-69581736821c
 	github.com/gorilla/mux v1.7.4-0.20190701202633-d83b6ffe499a
 	github.com/jessevdk/go-flags v1.5.1-0.20210607101731-3927b71304df
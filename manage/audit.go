@@ -0,0 +1,86 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package manage
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/CanonicalLtd/serial-vault/datastore"
+)
+
+// AuditCommand handles the export of the audit log for the serial-vault-admin command
+type AuditCommand struct {
+	Format   string `short:"f" long:"format" description:"Format of the export" choice:"csv" choice:"jsonl" default:"csv"`
+	Output   string `short:"o" long:"output" description:"Path of the file to create (default: standard output)"`
+	Actor    string `short:"u" long:"user" description:"The username of the user that made the changes"`
+	Action   string `short:"a" long:"action" description:"The action e.g. create, update, delete, enable, disable"`
+	Entity   string `short:"e" long:"entity" description:"The type of the changed records" choice:"account" choice:"keypair" choice:"model" choice:"substore" choice:"user"`
+	EntityID int    `short:"i" long:"id" description:"The ID of the changed record"`
+	From     string `long:"from" description:"The changes made at or after the date (RFC3339 or YYYY-MM-DD)"`
+	To       string `long:"to" description:"The changes made before the date (RFC3339 or YYYY-MM-DD)"`
+}
+
+// Execute the export of the audit log
+func (cmd AuditCommand) Execute(args []string) error {
+	params := &datastore.AuditLogParams{Actor: cmd.Actor, Action: cmd.Action, Entity: cmd.Entity, EntityID: cmd.EntityID}
+
+	var err error
+	if params.From, err = datastore.ParseDate(cmd.From); err != nil {
+		return err
+	}
+	if params.To, err = datastore.ParseDate(cmd.To); err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if cmd.Output != "" {
+		// An existing file is not overwritten
+		f, err := os.OpenFile(cmd.Output, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err != nil {
+			return fmt.Errorf("Error creating the export file: %v", err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	buf := bufio.NewWriter(w)
+
+	openDatabase()
+	count, err := datastore.ExportAuditLog(buf, cmd.Format, params)
+	if err == nil {
+		err = buf.Flush()
+	}
+	if err != nil {
+		// Do not leave an incomplete export behind
+		if cmd.Output != "" {
+			os.Remove(cmd.Output)
+		}
+		return fmt.Errorf("Error exporting the audit log: %v", err)
+	}
+
+	if cmd.Output != "" {
+		fmt.Printf("Exported %d audit logs to %s\n", count, cmd.Output)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package manage
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/CanonicalLtd/serial-vault/datastore"
+	"gopkg.in/check.v1"
+)
+
+type AuditSuite struct{}
+
+var _ = check.Suite(&AuditSuite{})
+
+func (s *AuditSuite) TestAuditExport(c *check.C) {
+	dir := c.MkDir()
+	exportFile := filepath.Join(dir, "audit.jsonl")
+	errorFile := filepath.Join(dir, "error.csv")
+
+	tests := []struct {
+		manTest
+		db datastore.Datastore
+	}{
+		{manTest{[]string{"serial-vault-admin", "audit", "-f", "xml"}, "Invalid value `xml' for option `-f, --format'.*"}, &datastore.MockDB{}},
+		{manTest{[]string{"serial-vault-admin", "audit", "-e", "signinglog"}, "Invalid value `signinglog' for option `-e, --entity'.*"}, &datastore.MockDB{}},
+		{manTest{[]string{"serial-vault-admin", "audit", "--to", "tomorrow"}, "invalid date: tomorrow"}, &datastore.MockDB{}},
+		{manTest{[]string{"serial-vault-admin", "audit"}, ""}, &datastore.MockDB{}},
+		{manTest{[]string{"serial-vault-admin", "audit", "-f", "jsonl", "-o", exportFile, "-e", "keypair", "-u", "root", "--from", "2026-01-01"}, ""}, &datastore.MockDB{}},
+		{manTest{[]string{"serial-vault-admin", "audit", "-o", exportFile}, "Error creating the export file: .*"}, &datastore.MockDB{}},
+		{manTest{[]string{"serial-vault-admin", "audit", "-o", errorFile}, "Error exporting the audit log: MOCK error exporting the audit log"}, &datastore.ErrorMockDB{}},
+	}
+
+	for _, t := range tests {
+		datastore.Environ = &datastore.Env{DB: t.db}
+		// The flags are parsed into the global command, so reset the previous values
+		Manage.Audit = AuditCommand{}
+		runTest(c, t.Args, t.ErrorMessage)
+	}
+
+	data, err := ioutil.ReadFile(exportFile)
+	c.Assert(err, check.IsNil)
+	c.Assert(strings.Count(string(data), "\n"), check.Equals, 1)
+	c.Assert(strings.Contains(string(data), `"diff":{"Active":{"old":true,"new":false}}`), check.Equals, true)
+
+	// An incomplete export is removed
+	_, err = os.Stat(errorFile)
+	c.Assert(os.IsNotExist(err), check.Equals, true)
+}
@@ -0,0 +1,108 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package manage
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/CanonicalLtd/serial-vault/datastore"
+)
+
+// BackupCommand is the main command for the encrypted backups of the vault
+type BackupCommand struct {
+	Create  BackupCreateCommand  `command:"create" alias:"c" description:"Create an encrypted backup of the database and the keystore"`
+	Restore BackupRestoreCommand `command:"restore" alias:"r" description:"Restore the database and the keystore from an encrypted backup"`
+	Verify  BackupVerifyCommand  `command:"verify" alias:"v" description:"Check that an encrypted backup can be restored"`
+}
+
+// readPassphrase reads the backup passphrase from a file
+func readPassphrase(path string) (string, error) {
+	passphrase, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("Error reading the backup passphrase: %v", err)
+	}
+	return strings.TrimSpace(string(passphrase)), nil
+}
+
+// openBackup reads and unseals a backup archive, and checks that it is complete
+func openBackup(path, passphraseFile string) (datastore.Backup, []datastore.BackupKeypairCheck, error) {
+	passphrase, err := readPassphrase(passphraseFile)
+	if err != nil {
+		return datastore.Backup{}, nil, err
+	}
+
+	sealed, err := ioutil.ReadFile(path)
+	if err != nil {
+		return datastore.Backup{}, nil, fmt.Errorf("Error reading the backup: %v", err)
+	}
+
+	backup, err := datastore.OpenBackup(sealed, passphrase)
+	if err != nil {
+		return backup, nil, fmt.Errorf("Error opening the backup: %v", err)
+	}
+
+	checks, err := datastore.VerifyBackup(backup)
+	if err != nil {
+		return backup, nil, fmt.Errorf("Error verifying the backup: %v", err)
+	}
+	return backup, checks, nil
+}
+
+// printBackup shows the contents of a backup and the signing-keys that were checked
+func printBackup(backup datastore.Backup, checks []datastore.BackupKeypairCheck) int {
+	// Create a tabwriter to format the output
+	w := new(tabwriter.Writer)
+	w.Init(os.Stdout, 5, 0, 4, ' ', 0)
+
+	fmt.Fprintln(w, "")
+	fmt.Fprintf(w, "Created:\t%s\n", backup.Created.Format("2006-01-02 15:04"))
+	fmt.Fprintf(w, "Driver:\t%s\n", backup.Driver)
+	fmt.Fprintf(w, "Keystore:\t%s\n", backup.KeystoreType)
+	fmt.Fprintf(w, "Keystore files:\t%d\n", len(backup.Files))
+	fmt.Fprintln(w, "")
+
+	fmt.Fprintln(w, "Table\tRows")
+	for _, t := range backup.Tables {
+		fmt.Fprintf(w, "%s\t%d\n", t.Name, len(t.Rows))
+	}
+	fmt.Fprintln(w, "")
+
+	var failed int
+	if len(checks) > 0 {
+		fmt.Fprintln(w, "Authority\tKey Name\tStatus")
+		for _, c := range checks {
+			status := "unseals"
+			if c.Err != nil {
+				status = c.Err.Error()
+				failed++
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", c.AuthorityID, c.KeyName, status)
+		}
+		fmt.Fprintln(w, "")
+	}
+	w.Flush()
+
+	return failed
+}
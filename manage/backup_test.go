@@ -0,0 +1,76 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package manage
+
+import (
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/CanonicalLtd/serial-vault/config"
+	"github.com/CanonicalLtd/serial-vault/datastore"
+	"gopkg.in/check.v1"
+)
+
+type BackupSuite struct{}
+
+var _ = check.Suite(&BackupSuite{})
+
+func (s *BackupSuite) TestBackup(c *check.C) {
+	dir := c.MkDir()
+	passphraseFile := filepath.Join(dir, "passphrase")
+	err := ioutil.WriteFile(passphraseFile, []byte("the backup passphrase\n"), 0600)
+	c.Assert(err, check.IsNil)
+	wrongFile := filepath.Join(dir, "wrong")
+	err = ioutil.WriteFile(wrongFile, []byte("the wrong passphrase\n"), 0600)
+	c.Assert(err, check.IsNil)
+	backupFile := filepath.Join(dir, "vault.backup")
+	secretFile := filepath.Join(dir, "secret")
+
+	tests := []struct {
+		manTest
+		secret string
+		db     datastore.Datastore
+	}{
+		{manTest{[]string{"serial-vault-admin", "backup"}, "Please specify one command of: create, restore or verify"}, "secret", &datastore.MockDB{}},
+		{manTest{[]string{"serial-vault-admin", "backup", "create", "-o", backupFile}, "the required flag .* was not specified"}, "secret", &datastore.MockDB{}},
+		{manTest{[]string{"serial-vault-admin", "backup", "create", "-o", backupFile, "-p", filepath.Join(dir, "invalid")}, "Error reading the backup passphrase: .*"}, "secret", &datastore.MockDB{}},
+		{manTest{[]string{"serial-vault-admin", "backup", "create", "-o", backupFile, "-p", passphraseFile}, "Error creating the backup: Error reading the backup tables"}, "secret", &datastore.ErrorMockDB{}},
+		{manTest{[]string{"serial-vault-admin", "backup", "create", "-o", backupFile, "-p", passphraseFile, "--signing-log"}, ""}, "secret", &datastore.MockDB{}},
+		{manTest{[]string{"serial-vault-admin", "backup", "create", "-o", backupFile, "-p", passphraseFile}, "Error creating the backup file: .*"}, "secret", &datastore.MockDB{}},
+		{manTest{[]string{"serial-vault-admin", "backup", "verify", "-i", backupFile, "-p", wrongFile}, "Error opening the backup: Cannot unseal the key.*"}, "secret", &datastore.MockDB{}},
+		{manTest{[]string{"serial-vault-admin", "backup", "verify", "-i", filepath.Join(dir, "invalid"), "-p", passphraseFile}, "Error reading the backup: .*"}, "secret", &datastore.MockDB{}},
+		{manTest{[]string{"serial-vault-admin", "backup", "verify", "-i", backupFile, "-p", passphraseFile}, ""}, "secret", &datastore.MockDB{}},
+		{manTest{[]string{"serial-vault-admin", "backup", "restore", "-i", backupFile, "-p", passphraseFile}, "The keystoreSecret of the settings is not the one of the backup: .*"}, "other secret", &datastore.MockDB{}},
+		{manTest{[]string{"serial-vault-admin", "backup", "restore", "-i", backupFile, "-p", passphraseFile}, "Error restoring the backup: Error fetching from the database"}, "secret", &datastore.ErrorMockDB{}},
+		{manTest{[]string{"serial-vault-admin", "backup", "restore", "-i", backupFile, "-p", passphraseFile}, ""}, "secret", &datastore.MockDB{}},
+		{manTest{[]string{"serial-vault-admin", "backup", "restore", "-i", backupFile, "-p", passphraseFile, "-f", secretFile}, ""}, "other secret", &datastore.MockDB{}},
+	}
+
+	for _, t := range tests {
+		datastore.Environ = &datastore.Env{DB: t.db, Config: config.Settings{KeyStoreType: "database", KeyStoreSecret: t.secret}}
+		runTest(c, t.Args, t.ErrorMessage)
+	}
+
+	// The keystore secret of the backup is saved for the settings
+	secret, err := ioutil.ReadFile(secretFile)
+	c.Assert(err, check.IsNil)
+	c.Assert(string(secret), check.Equals, "secret\n")
+}
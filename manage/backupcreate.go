@@ -0,0 +1,72 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package manage
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/CanonicalLtd/serial-vault/datastore"
+)
+
+// BackupCreateCommand handles the creation of a backup for the serial-vault-admin command
+type BackupCreateCommand struct {
+	Output         string `short:"o" long:"output" description:"Path of the backup file to create" required:"yes"`
+	PassphraseFile string `short:"p" long:"passphrase-file" description:"Path to the file with the passphrase that encrypts the backup" required:"yes"`
+	SigningLog     bool   `short:"s" long:"signing-log" description:"Include the signing log in the backup"`
+}
+
+// Execute the creation of a backup
+func (cmd BackupCreateCommand) Execute(args []string) error {
+	passphrase, err := readPassphrase(cmd.PassphraseFile)
+	if err != nil {
+		return err
+	}
+
+	// Open the database and read the backup
+	openDatabase()
+	backup, err := datastore.CreateBackup(cmd.SigningLog)
+	if err != nil {
+		return fmt.Errorf("Error creating the backup: %v", err)
+	}
+
+	sealed, err := datastore.SealBackup(backup, passphrase)
+	if err != nil {
+		return fmt.Errorf("Error encrypting the backup: %v", err)
+	}
+
+	// An existing backup is not overwritten
+	f, err := os.OpenFile(cmd.Output, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return fmt.Errorf("Error creating the backup file: %v", err)
+	}
+	if _, err := f.Write(sealed); err != nil {
+		f.Close()
+		return fmt.Errorf("Error writing the backup file: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("Error writing the backup file: %v", err)
+	}
+
+	printBackup(backup, nil)
+	fmt.Printf("Backup written to %s\n", cmd.Output)
+	return nil
+}
@@ -0,0 +1,72 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package manage
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/CanonicalLtd/serial-vault/datastore"
+)
+
+// BackupRestoreCommand handles the restore of a backup for the serial-vault-admin command
+type BackupRestoreCommand struct {
+	Input          string `short:"i" long:"input" description:"Path of the backup file" required:"yes"`
+	PassphraseFile string `short:"p" long:"passphrase-file" description:"Path to the file with the passphrase that encrypts the backup" required:"yes"`
+	SecretFile     string `short:"f" long:"secret-file" description:"Path of a file to write the keystore secret of the backup to, when it differs from the settings"`
+}
+
+// Execute the restore of a backup. The backup is verified before the database is changed
+func (cmd BackupRestoreCommand) Execute(args []string) error {
+	backup, checks, err := openBackup(cmd.Input, cmd.PassphraseFile)
+	if err != nil {
+		return err
+	}
+
+	if failed := printBackup(backup, checks); failed > 0 {
+		return fmt.Errorf("Error unsealing %d of the signing-keys in the backup, it has not been restored", failed)
+	}
+
+	// Open the database, which also reads the settings
+	openDatabase()
+
+	// The sealed signing-keys need the keystore secret of the backup
+	sealed := backup.KeystoreType == datastore.DatabaseStore.Name || backup.KeystoreType == datastore.TPM20Store.Name
+	secretChanged := sealed && backup.KeystoreSecret != datastore.Environ.Config.KeyStoreSecret
+	if secretChanged {
+		if len(cmd.SecretFile) == 0 {
+			return fmt.Errorf("The keystoreSecret of the settings is not the one of the backup: use --secret-file to save the secret of the backup")
+		}
+		if err := ioutil.WriteFile(cmd.SecretFile, []byte(backup.KeystoreSecret+"\n"), 0600); err != nil {
+			return fmt.Errorf("Error writing the keystore secret: %v", err)
+		}
+	}
+
+	if err := datastore.RestoreBackup(backup); err != nil {
+		return fmt.Errorf("Error restoring the backup: %v", err)
+	}
+
+	fmt.Println("Backup restored successfully")
+	if secretChanged {
+		fmt.Printf("Set the keystoreSecret in the settings of all the services to the secret in %s before starting them\n", cmd.SecretFile)
+	}
+	return nil
+}
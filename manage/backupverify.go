@@ -0,0 +1,46 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package manage
+
+import (
+	"fmt"
+)
+
+// BackupVerifyCommand handles the verification of a backup for the serial-vault-admin command
+type BackupVerifyCommand struct {
+	Input          string `short:"i" long:"input" description:"Path of the backup file" required:"yes"`
+	PassphraseFile string `short:"p" long:"passphrase-file" description:"Path to the file with the passphrase that encrypts the backup" required:"yes"`
+}
+
+// Execute the verification of a backup. The database is not used
+func (cmd BackupVerifyCommand) Execute(args []string) error {
+	backup, checks, err := openBackup(cmd.Input, cmd.PassphraseFile)
+	if err != nil {
+		return err
+	}
+
+	if failed := printBackup(backup, checks); failed > 0 {
+		return fmt.Errorf("Error unsealing %d of the signing-keys in the backup", failed)
+	}
+
+	fmt.Println("The backup can be restored")
+	return nil
+}
@@ -28,39 +28,14 @@ import (
 	"github.com/CanonicalLtd/serial-vault/datastore"
 )
 
-const (
-	create = "Create"
-	update = "Update"
-)
-
-type operation struct {
-	method     func() error
-	action     string
-	table      string
-	skipSqlite bool
-}
-
-func execOne(method func() error, action, tableName string) {
-	err := method()
-	if err != nil {
-		log.Fatal(err)
-	} else {
-		fmt.Printf("%sd the '%s' table.\n", action, tableName)
-	}
-}
-
-func exec(operations []operation) {
-	for _, op := range operations {
-		if op.skipSqlite && datastore.InFactory() {
-			continue
-		}
-		execOne(op.method, op.action, op.table)
-	}
+// DatabaseCommand is the main command for database management. Without a sub-command,
+// it migrates the schema to the latest version
+type DatabaseCommand struct {
+	Status   DatabaseStatusCommand   `command:"status" alias:"s" description:"List the schema migrations and whether they are applied"`
+	Migrate  DatabaseMigrateCommand  `command:"migrate" alias:"m" description:"Migrate the schema to a version"`
+	Rollback DatabaseRollbackCommand `command:"rollback" alias:"r" description:"Roll back the latest schema migration"`
 }
 
-// DatabaseCommand is the main command for database management
-type DatabaseCommand struct{}
-
 // Execute the database schema updates
 func (cmd DatabaseCommand) Execute(args []string) error {
 	fmt.Println("Update the database schema...")
@@ -72,65 +47,32 @@ func (cmd DatabaseCommand) Execute(args []string) error {
 	return nil
 }
 
-// UpdateDatabase updates the database schema
+// UpdateDatabase migrates the database schema to the latest version, and initializes the keystore
 func UpdateDatabase() {
+	err := migrateDatabase(datastore.LatestSchemaVersion())
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	// Execute all create and alter table operations
-	operations := []operation{
-		// Create the keypair table, if it does not exist
-		{datastore.Environ.DB.CreateKeypairTable, create, "keypair", false},
-
-		// Create the model table, if it does not exist
-		{datastore.Environ.DB.CreateModelTable, create, "model", false},
-
-		// Create the keypair table, if it does not exist
-		{datastore.Environ.DB.CreateSettingsTable, create, "settings", false},
-
-		// Create the signinglog table, if it does not exist
-		{datastore.Environ.DB.CreateSigningLogTable, create, "signinglog", false},
-
-		// Create the nonce table, if it does not exist
-		{datastore.Environ.DB.CreateDeviceNonceTable, create, "nonce", false},
-
-		// Create the account table, if it does not exist
-		{datastore.Environ.DB.CreateAccountTable, create, "account", false},
-		{datastore.Environ.DB.AlterAccountTable, update, "account", false},
-
-		// Update the model table, adding the new user-keypair field
-		{datastore.Environ.DB.AlterModelTable, update, "model", false},
-
-		// Update the keypair table, adding the new fields
-		{datastore.Environ.DB.AlterKeypairTable, update, "keypair", false},
-
-		// Create the OpenID nonce table, if it does not exist
-		{datastore.Environ.DB.CreateOpenidNonceTable, create, "openid nonce", false},
-
-		// Create the User table, if it does not exist
-		{datastore.Environ.DB.CreateUserTable, create, "userinfo", false},
-
-		// Create the AccountUserLink table, if it does not exist
-		{datastore.Environ.DB.CreateAccountUserLinkTable, create, "account-user link", true},
-
-		// Update the User table, removing not needed openid_identity field
-		{datastore.Environ.DB.AlterUserTable, update, "userinfo", true},
-
-		// Create the Keypair Status table, if it does not exist, and add indexes
-		{datastore.Environ.DB.CreateKeypairStatusTable, create, "keypair status", false},
-		{datastore.Environ.DB.AlterKeypairStatusTable, update, "keypair status", false},
-
-		// Create the Model Assertion table, if it does not exist
-		{datastore.Environ.DB.CreateModelAssertTable, create, "model assertion", false},
-		{datastore.Environ.DB.AlterModelAssertTable, update, "model assertion", false},
-
-		// Create the Sub-store table, if it does not exist
-		{datastore.Environ.DB.CreateSubstoreTable, create, "sub-store", false},
+	initializeKeystore()
+}
 
-		// Create the testlog table, if it does not exist
-		{datastore.Environ.DB.CreateTestLogTable, create, "testlog", false},
+func migrateDatabase(version int) error {
+	migrations, err := datastore.Environ.DB.MigrateSchema(version)
+	for _, m := range migrations {
+		fmt.Printf("Migrated the schema: %d %s\n", m.Version, m.Description)
 	}
+	if err != nil {
+		return fmt.Errorf("Error migrating the database schema: %v", err)
+	}
+	if len(migrations) == 0 {
+		fmt.Printf("The schema is at version %d\n", version)
+	}
+	return nil
+}
 
-	exec(operations)
-
+// initializeKeystore prepares the keystore once the schema is up to date
+func initializeKeystore() {
 	// Create the test key (if the filesystem store is used)
 	if datastore.Environ.Config.KeyStoreType == "filesystem" {
 		// Create the test key as it is in the default filesystem keystore
@@ -20,8 +20,6 @@
 package manage
 
 import (
-	"github.com/CanonicalLtd/serial-vault/service/log"
-
 	"github.com/CanonicalLtd/serial-vault/config"
 	"github.com/CanonicalLtd/serial-vault/datastore"
 
@@ -40,33 +38,52 @@ func (s *databaseSuite) SetUpTest(c *check.C) {
 	datastore.OpenKeyStore(config)
 }
 
-func (s *databaseSuite) TestDoTable(c *check.C) {
-	m1 := func() error {
-		log.Println("Successful execution 1")
-		return nil
+func (s *databaseSuite) TestDatabase(c *check.C) {
+	tests := []manTest{
+		{
+			Args:         []string{"serial-vault-admin", "database"},
+			ErrorMessage: ""},
+		{
+			Args:         []string{"serial-vault-admin", "database", "status"},
+			ErrorMessage: ""},
+		{
+			Args:         []string{"serial-vault-admin", "database", "migrate"},
+			ErrorMessage: ""},
+		{
+			Args:         []string{"serial-vault-admin", "database", "migrate", "--to", "2"},
+			ErrorMessage: ""},
+		{
+			Args:         []string{"serial-vault-admin", "database", "migrate", "--to", "1000"},
+			ErrorMessage: "Error migrating the database schema: the schema version must be between 0 and .*"},
+		{
+			Args:         []string{"serial-vault-admin", "database", "rollback"},
+			ErrorMessage: ""},
 	}
 
-	m2 := func() error {
-		log.Println("Successful execution 2")
-		return nil
+	for _, t := range tests {
+		// The flags are parsed into the global command, so reset the previous values
+		Manage.Database.Migrate = DatabaseMigrateCommand{}
+		runTest(c, t.Args, t.ErrorMessage)
 	}
-
-	exec([]operation{
-		{m1, create, "the table 1", false},
-		{m2, update, "the table 2", false},
-		{m1, create, "the table 1", true},
-		{m2, update, "the table 2", true},
-	})
 }
 
-func (s *databaseSuite) TestDatabase(c *check.C) {
+func (s *databaseSuite) TestDatabaseError(c *check.C) {
+	datastore.Environ.DB = &datastore.ErrorMockDB{}
+
 	tests := []manTest{
 		{
-			Args:         []string{"serial-vault-admin", "database"},
-			ErrorMessage: ""},
+			Args:         []string{"serial-vault-admin", "database", "status"},
+			ErrorMessage: "Error reading the schema migrations: Error fetching from the database"},
+		{
+			Args:         []string{"serial-vault-admin", "database", "migrate", "--to", "1"},
+			ErrorMessage: "Error migrating the database schema: MOCK error migrating the schema"},
+		{
+			Args:         []string{"serial-vault-admin", "database", "rollback"},
+			ErrorMessage: "Error rolling back the database schema: MOCK error rolling back the schema"},
 	}
 
 	for _, t := range tests {
+		Manage.Database.Migrate = DatabaseMigrateCommand{}
 		runTest(c, t.Args, t.ErrorMessage)
 	}
 }
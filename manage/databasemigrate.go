@@ -0,0 +1,49 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package manage
+
+import (
+	"github.com/CanonicalLtd/serial-vault/datastore"
+)
+
+// DatabaseMigrateCommand handles the migration of the schema for the serial-vault-admin command
+type DatabaseMigrateCommand struct {
+	To *int `short:"t" long:"to" description:"The schema version to migrate to, applying or rolling back migrations (default: the latest version)"`
+}
+
+// Execute the migration of the schema
+func (cmd DatabaseMigrateCommand) Execute(args []string) error {
+	openDatabase()
+
+	version := datastore.LatestSchemaVersion()
+	if cmd.To != nil {
+		version = *cmd.To
+	}
+
+	if err := migrateDatabase(version); err != nil {
+		return err
+	}
+
+	if version == datastore.LatestSchemaVersion() {
+		initializeKeystore()
+	}
+	return nil
+}
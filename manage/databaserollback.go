@@ -0,0 +1,43 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package manage
+
+import (
+	"fmt"
+
+	"github.com/CanonicalLtd/serial-vault/datastore"
+)
+
+// DatabaseRollbackCommand handles the rollback of a schema migration for the serial-vault-admin command
+type DatabaseRollbackCommand struct{}
+
+// Execute the rollback of the latest schema migration
+func (cmd DatabaseRollbackCommand) Execute(args []string) error {
+	openDatabase()
+
+	m, err := datastore.Environ.DB.RollbackSchema()
+	if err != nil {
+		return fmt.Errorf("Error rolling back the database schema: %v", err)
+	}
+
+	fmt.Printf("Rolled back the schema: %d %s\n", m.Version, m.Description)
+	return nil
+}
@@ -0,0 +1,74 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package manage
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/CanonicalLtd/serial-vault/datastore"
+)
+
+// DatabaseStatusCommand handles the status of the schema migrations for the serial-vault-admin command
+type DatabaseStatusCommand struct{}
+
+// Execute the status of the schema migrations
+func (cmd DatabaseStatusCommand) Execute(args []string) error {
+	// Open the database and get the migrations
+	openDatabase()
+	status, err := datastore.Environ.DB.SchemaStatus()
+	if err != nil {
+		return fmt.Errorf("Error reading the schema migrations: %v", err)
+	}
+
+	// Create a tabwriter to format the output
+	w := new(tabwriter.Writer)
+	w.Init(os.Stdout, 5, 0, 4, ' ', 0)
+
+	// Print the headers
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "Version\tDescription\tApplied\tReversible")
+
+	// Print the migrations
+	for _, s := range status {
+		applied := "pending"
+		if s.Applied && s.AppliedAt != nil {
+			applied = s.AppliedAt.Format("2006-01-02 15:04")
+		} else if s.Applied {
+			applied = "yes"
+		}
+		if len(s.Description) == 0 {
+			s.Description = "(unknown migration)"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%t\n", s.Version, s.Description, applied, s.Reversible())
+	}
+	fmt.Fprintln(w, "")
+	w.Flush()
+
+	if err := datastore.Environ.DB.CheckSchema(); err != nil {
+		fmt.Println(err)
+	} else {
+		fmt.Printf("The schema is up to date, at version %d\n", datastore.LatestSchemaVersion())
+	}
+
+	return nil
+}
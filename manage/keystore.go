@@ -0,0 +1,26 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017-2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package manage
+
+// KeystoreCommand is the main command for the management of the sealed signing-keys
+type KeystoreCommand struct {
+	Migrate      KeystoreMigrateCommand      `command:"migrate" alias:"m" description:"Re-seal the signing-keys in the legacy format, using authenticated encryption"`
+	RotateSecret KeystoreRotateSecretCommand `command:"rotate-secret" alias:"r" description:"Re-seal the signing-keys with a new keystore secret"`
+}
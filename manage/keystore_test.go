@@ -0,0 +1,99 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017-2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package manage
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/CanonicalLtd/serial-vault/config"
+	"github.com/CanonicalLtd/serial-vault/datastore"
+	"gopkg.in/check.v1"
+)
+
+type KeystoreSuite struct{}
+
+var _ = check.Suite(&KeystoreSuite{})
+
+func (s *KeystoreSuite) TestKeystoreMigrate(c *check.C) {
+	tests := []struct {
+		manTest
+		keystore string
+		db       datastore.Datastore
+	}{
+		{manTest{[]string{"serial-vault-admin", "keystore"}, "Please specify one command of: migrate or rotate-secret"}, "database", &datastore.MockDB{}},
+		{manTest{[]string{"serial-vault-admin", "keystore", "migrate"}, ""}, "database", &datastore.MockDB{}},
+		{manTest{[]string{"serial-vault-admin", "keystore", "migrate"}, "Error migrating 1 of the sealed signing-keys"}, "tpm2.0", &keystoreMockDB{}},
+		{manTest{[]string{"serial-vault-admin", "keystore", "migrate"}, "Error migrating the sealed signing-keys: The keystore does not seal .*"}, "filesystem", &datastore.MockDB{}},
+	}
+
+	for _, t := range tests {
+		datastore.Environ = &datastore.Env{DB: t.db, Config: config.Settings{KeyStoreType: t.keystore}}
+		runTest(c, t.Args, t.ErrorMessage)
+	}
+}
+
+func (s *KeystoreSuite) TestKeystoreRotateSecret(c *check.C) {
+	dir := c.MkDir()
+	secretFile := filepath.Join(dir, "secret")
+	err := ioutil.WriteFile(secretFile, []byte("the new secret\n"), 0600)
+	c.Assert(err, check.IsNil)
+	emptyFile := filepath.Join(dir, "empty")
+	err = ioutil.WriteFile(emptyFile, []byte("\n"), 0600)
+	c.Assert(err, check.IsNil)
+
+	tests := []struct {
+		manTest
+		keystore string
+		db       datastore.Datastore
+	}{
+		{manTest{[]string{"serial-vault-admin", "keystore", "rotate-secret"}, "the required flag .* was not specified"}, "database", &datastore.MockDB{}},
+		{manTest{[]string{"serial-vault-admin", "keystore", "rotate-secret", "-f", filepath.Join(dir, "invalid")}, "Error reading the new keystore secret: .*"}, "database", &datastore.MockDB{}},
+		{manTest{[]string{"serial-vault-admin", "keystore", "rotate-secret", "-f", emptyFile}, "Error rotating the keystore secret: The new keystore secret cannot be empty"}, "database", &datastore.MockDB{}},
+		{manTest{[]string{"serial-vault-admin", "keystore", "rotate-secret", "-f", secretFile}, "Error rotating the keystore secret: The keystore does not seal .*"}, "filesystem", &datastore.MockDB{}},
+		{manTest{[]string{"serial-vault-admin", "keystore", "rotate-secret", "-f", secretFile}, "Error rotating the keystore secret: Error fetching from the database"}, "database", &datastore.ErrorMockDB{}},
+		{manTest{[]string{"serial-vault-admin", "keystore", "rotate-secret", "-f", secretFile}, "Error re-sealing 1 of the signing-keys, .*"}, "tpm2.0", &keystoreMockDB{}},
+		{manTest{[]string{"serial-vault-admin", "keystore", "rotate-secret", "-f", secretFile, "--dry-run"}, ""}, "database", &datastore.MockDB{}},
+		{manTest{[]string{"serial-vault-admin", "keystore", "rotate-secret", "-f", secretFile}, ""}, "database", &datastore.MockDB{}},
+	}
+
+	for _, t := range tests {
+		datastore.Environ = &datastore.Env{DB: t.db, Config: config.Settings{KeyStoreType: t.keystore, KeyStoreSecret: "the old secret"}}
+		runTest(c, t.Args, t.ErrorMessage)
+	}
+}
+
+// keystoreMockDB returns a keypair that has no auth-key setting
+type keystoreMockDB struct {
+	datastore.MockDB
+}
+
+func (mdb *keystoreMockDB) GetKeypair(keypairID int) (datastore.Keypair, error) {
+	return datastore.Keypair{ID: keypairID, AuthorityID: "system", KeyID: "abc", SealedKey: "c2VhbGVk"}, nil
+}
+
+func (mdb *keystoreMockDB) ListAllowedKeypairs(authorization datastore.User) ([]datastore.Keypair, error) {
+	return []datastore.Keypair{{ID: 1, AuthorityID: "system", KeyID: "abc"}}, nil
+}
+
+func (mdb *keystoreMockDB) GetSetting(code string) (datastore.Setting, error) {
+	return datastore.Setting{}, errors.New("MOCK cannot find the setting")
+}
@@ -0,0 +1,71 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017-2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package manage
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/CanonicalLtd/serial-vault/datastore"
+)
+
+// KeystoreMigrateCommand handles the migration of the sealed signing-keys for the serial-vault-admin command
+type KeystoreMigrateCommand struct{}
+
+// Execute the migration of the sealed signing-keys
+func (cmd KeystoreMigrateCommand) Execute(args []string) error {
+	// Open the database and re-seal the keypairs
+	openDatabase()
+	migrations, err := datastore.MigrateSealedKeypairs()
+	if err != nil {
+		return fmt.Errorf("Error migrating the sealed signing-keys: %v", err)
+	}
+
+	// Create a tabwriter to format the output
+	w := new(tabwriter.Writer)
+	w.Init(os.Stdout, 5, 0, 4, ' ', 0)
+
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "Authority\tKey Name\tStatus")
+
+	var migrated, failed int
+	for _, m := range migrations {
+		status := "up to date"
+		switch {
+		case m.Err != nil:
+			status = m.Err.Error()
+			failed++
+		case m.Migrated:
+			status = "migrated"
+			migrated++
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", m.Keypair.AuthorityID, m.Keypair.KeyName, status)
+	}
+	fmt.Fprintln(w, "")
+	w.Flush()
+
+	if failed > 0 {
+		return fmt.Errorf("Error migrating %d of the sealed signing-keys", failed)
+	}
+
+	fmt.Printf("%d signing-keys migrated successfully\n", migrated)
+	return nil
+}
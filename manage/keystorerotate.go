@@ -0,0 +1,91 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017-2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package manage
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/CanonicalLtd/serial-vault/datastore"
+)
+
+// KeystoreRotateSecretCommand handles the rotation of the keystore secret for the serial-vault-admin command
+type KeystoreRotateSecretCommand struct {
+	NewSecretFile string `short:"f" long:"new-secret-file" description:"Path to the file with the new keystore secret" required:"yes"`
+	DryRun        bool   `short:"n" long:"dry-run" description:"Check that the signing-keys can be re-sealed, without storing them"`
+}
+
+// Execute the rotation of the keystore secret
+func (cmd KeystoreRotateSecretCommand) Execute(args []string) error {
+	newSecret, err := ioutil.ReadFile(cmd.NewSecretFile)
+	if err != nil {
+		return fmt.Errorf("Error reading the new keystore secret: %v", err)
+	}
+
+	// Open the database and re-seal the keypairs
+	openDatabase()
+	migrations, err := datastore.RotateKeystoreSecret(strings.TrimSpace(string(newSecret)), cmd.DryRun)
+	if err != nil {
+		return fmt.Errorf("Error rotating the keystore secret: %v", err)
+	}
+
+	// Create a tabwriter to format the output
+	w := new(tabwriter.Writer)
+	w.Init(os.Stdout, 5, 0, 4, ' ', 0)
+
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "Authority\tKey Name\tStatus")
+
+	var resealed, failed int
+	for _, m := range migrations {
+		status := "not sealed"
+		switch {
+		case m.Err != nil:
+			status = m.Err.Error()
+			failed++
+		case m.Migrated:
+			status = "re-sealed"
+			resealed++
+		}
+		keyName := m.Keypair.KeyName
+		if m.OperationID > 0 {
+			keyName = fmt.Sprintf("%s (import %d waiting for approval)", keyName, m.OperationID)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", m.Keypair.AuthorityID, keyName, status)
+	}
+	fmt.Fprintln(w, "")
+	w.Flush()
+
+	if failed > 0 {
+		return fmt.Errorf("Error re-sealing %d of the signing-keys, the keystore secret has not been changed", failed)
+	}
+
+	if cmd.DryRun {
+		fmt.Printf("%d signing-keys can be re-sealed with the new keystore secret (dry-run)\n", resealed)
+		return nil
+	}
+
+	fmt.Printf("%d signing-keys re-sealed successfully\n", resealed)
+	fmt.Println("Set the new keystoreSecret in the settings of all the services before starting them")
+	return nil
+}
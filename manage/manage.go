@@ -28,10 +28,17 @@ import (
 type Command struct {
 	SettingsFile string `short:"c" long:"config" description:"Path to the config file" default:"./settings.yaml"`
 
-	Account  AccountCommand  `command:"account" alias:"a" description:"Account management"`
-	Client   ClientCommand   `command:"client" alias:"c" description:"Serial-Vault Client to generate a test serial assertion request"`
-	Database DatabaseCommand `command:"database" alias:"d" description:"Database schema update"`
-	User     UserCommand     `command:"user" alias:"u" description:"User management"`
+	Account    AccountCommand    `command:"account" alias:"a" description:"Account management"`
+	Audit      AuditCommand      `command:"audit" description:"Export of the audit log of the changes made by users"`
+	Backup     BackupCommand     `command:"backup" alias:"b" description:"Encrypted backup and restore of the database and keystore"`
+	Client     ClientCommand     `command:"client" alias:"c" description:"Serial-Vault Client to generate a test serial assertion request"`
+	Database   DatabaseCommand   `command:"database" alias:"d" subcommands-optional:"true" description:"Database schema migrations"`
+	Keystore   KeystoreCommand   `command:"keystore" alias:"k" description:"Management of the sealed signing-keys"`
+	Operation  OperationCommand  `command:"operation" alias:"o" description:"Approval of the sensitive signing-key operations"`
+	Revocation RevocationCommand `command:"revocation" alias:"r" description:"Management of the revoked device-keys and serial numbers"`
+	SerialRule SerialRuleCommand `command:"serialrule" alias:"s" description:"Management of the allowed serial numbers of a model"`
+	SigningLog SigningLogCommand `command:"signinglog" alias:"l" description:"Export and verification of the signing log"`
+	User       UserCommand       `command:"user" alias:"u" description:"User management"`
 }
 
 // Manage is the implementation of the command configuration for the serial-vault-admin command-line
@@ -48,3 +55,12 @@ func openDatabase() {
 	// Open the connection to the database
 	datastore.OpenSysDatabase(datastore.Environ.Config.Driver, datastore.Environ.Config.DataSource)
 }
+
+func openKeyStore() error {
+	// Check that the keystore has not been set e.g. by a mock
+	if datastore.Environ.KeypairDB != nil {
+		return nil
+	}
+
+	return datastore.OpenKeyStore(datastore.Environ.Config)
+}
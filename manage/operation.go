@@ -0,0 +1,52 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package manage
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/CanonicalLtd/serial-vault/datastore"
+)
+
+// OperationCommand is the main command for the approval of the sensitive keypair operations. The user
+// that decides an operation is authenticated by their API key, as they are in the admin API
+type OperationCommand struct {
+	List    OperationListCommand    `command:"list" alias:"ls" alias:"l" description:"List the keypair operations and their approval status"`
+	Approve OperationApproveCommand `command:"approve" alias:"a" description:"Approve, and carry out, a pending keypair operation"`
+	Reject  OperationRejectCommand  `command:"reject" alias:"r" description:"Reject a pending keypair operation"`
+}
+
+// authenticateUser finds the user by their username and API key. The API key is read from a
+// file, so it does not appear in the shell history
+func authenticateUser(username, apiKeyFile string) (datastore.User, error) {
+	apiKey, err := ioutil.ReadFile(apiKeyFile)
+	if err != nil {
+		return datastore.User{}, fmt.Errorf("Error reading the API key: %v", err)
+	}
+
+	user, err := datastore.Environ.DB.GetUserByAPIKey(strings.TrimSpace(string(apiKey)), username)
+	if err != nil {
+		return user, fmt.Errorf("Error authenticating the user: %v", err)
+	}
+	return user, nil
+}
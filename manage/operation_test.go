@@ -0,0 +1,146 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package manage
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/CanonicalLtd/serial-vault/datastore"
+	"gopkg.in/check.v1"
+)
+
+type OperationSuite struct {
+	apiKeys string
+}
+
+var _ = check.Suite(&OperationSuite{})
+
+// operationMockDB checks the API key of the user, which is the username with a prefix
+type operationMockDB struct {
+	datastore.MockDB
+}
+
+func (mdb *operationMockDB) GetUserByAPIKey(apiKey, username string) (datastore.User, error) {
+	if apiKey != "api-key-"+username {
+		return datastore.User{}, errors.New("Cannot find the user")
+	}
+	return mdb.MockDB.GetUserByAPIKey(apiKey, username)
+}
+
+func (s *OperationSuite) SetUpTest(c *check.C) {
+	datastore.Environ = &datastore.Env{DB: &operationMockDB{}}
+	datastore.Environ.KeypairDB, _ = datastore.GetMemoryKeyStore(datastore.Environ.Config)
+
+	// Write the API key files of the users
+	s.apiKeys = c.MkDir()
+	for _, username := range []string{"root", "sv", "invalid"} {
+		err := ioutil.WriteFile(filepath.Join(s.apiKeys, username), []byte("api-key-"+username+"\n"), 0600)
+		c.Assert(err, check.IsNil)
+	}
+}
+
+func (s *OperationSuite) apiKeyFile(username string) string {
+	return filepath.Join(s.apiKeys, username)
+}
+
+func (s *OperationSuite) TestOperation(c *check.C) {
+	tests := []manTest{
+		{
+			Args:         []string{"serial-vault-admin", "operation"},
+			ErrorMessage: "Please specify one command of: approve, list or reject"},
+		{
+			Args:         []string{"serial-vault-admin", "operation", "list"},
+			ErrorMessage: ""},
+		{
+			Args:         []string{"serial-vault-admin", "operation", "approve", "-i", "1", "-u", "root"},
+			ErrorMessage: "the required flag `-k, --api-key-file' was not specified"},
+		{
+			Args:         []string{"serial-vault-admin", "operation", "approve", "-i", "1", "-u", "root", "-k", s.apiKeyFile("root")},
+			ErrorMessage: ""},
+		{
+			Args:         []string{"serial-vault-admin", "operation", "approve", "-i", "1", "-u", "root", "-k", s.apiKeyFile("sv")},
+			ErrorMessage: "Error authenticating the user: Cannot find the user"},
+		{
+			Args:         []string{"serial-vault-admin", "operation", "approve", "-i", "1", "-u", "root", "-k", filepath.Join(s.apiKeys, "missing")},
+			ErrorMessage: "Error reading the API key: .*"},
+		{
+			Args:         []string{"serial-vault-admin", "operation", "approve", "-i", "1", "-u", "sv", "-k", s.apiKeyFile("sv")},
+			ErrorMessage: "Error approving the operation: only a Superuser can approve an operation"},
+		{
+			Args:         []string{"serial-vault-admin", "operation", "approve", "-i", "2", "-u", "root", "-k", s.apiKeyFile("root")},
+			ErrorMessage: "Error approving the operation: the operation must be approved by a different user to the one that requested it"},
+		{
+			Args:         []string{"serial-vault-admin", "operation", "approve", "-i", "3", "-u", "root", "-k", s.apiKeyFile("root")},
+			ErrorMessage: "Error approving the operation: the operation is no longer waiting for a decision"},
+		{
+			Args:         []string{"serial-vault-admin", "operation", "approve", "-i", "1", "-u", "invalid", "-k", s.apiKeyFile("invalid")},
+			ErrorMessage: "Error authenticating the user: Cannot find the user"},
+		{
+			Args:         []string{"serial-vault-admin", "operation", "reject", "-i", "2", "-u", "root", "-m", "Not needed"},
+			ErrorMessage: "the required flag `-k, --api-key-file' was not specified"},
+		{
+			Args:         []string{"serial-vault-admin", "operation", "reject", "-i", "2", "-u", "root", "-k", s.apiKeyFile("root"), "-m", "Not needed"},
+			ErrorMessage: ""},
+		{
+			Args:         []string{"serial-vault-admin", "operation", "reject", "-i", "2", "-u", "root", "-k", s.apiKeyFile("sv")},
+			ErrorMessage: "Error authenticating the user: Cannot find the user"},
+		{
+			Args:         []string{"serial-vault-admin", "operation", "reject", "-i", "1", "-u", "sv", "-k", s.apiKeyFile("sv")},
+			ErrorMessage: ""},
+		{
+			Args:         []string{"serial-vault-admin", "operation", "reject", "-i", "2", "-u", "sv", "-k", s.apiKeyFile("sv")},
+			ErrorMessage: "Error rejecting the operation: only a Superuser or the requester can reject an operation"},
+		{
+			Args:         []string{"serial-vault-admin", "operation", "reject", "-i", "4", "-u", "root", "-k", s.apiKeyFile("root")},
+			ErrorMessage: "Error rejecting the operation: the operation is no longer waiting for a decision"},
+	}
+
+	for _, t := range tests {
+		// The flags are parsed into the global command, so reset the previous values
+		Manage.Operation.Approve = OperationApproveCommand{}
+		Manage.Operation.Reject = OperationRejectCommand{}
+		runTest(c, t.Args, t.ErrorMessage)
+	}
+}
+
+func (s *OperationSuite) TestOperationError(c *check.C) {
+	datastore.Environ.DB = &datastore.ErrorMockDB{}
+
+	tests := []manTest{
+		{
+			Args:         []string{"serial-vault-admin", "operation", "list"},
+			ErrorMessage: "Error listing the keypair operations: MOCK error retrieving the pending operations"},
+		{
+			Args:         []string{"serial-vault-admin", "operation", "approve", "-i", "1", "-u", "root", "-k", s.apiKeyFile("root")},
+			ErrorMessage: "Error authenticating the user: .*"},
+		{
+			Args:         []string{"serial-vault-admin", "operation", "reject", "-i", "1", "-u", "root", "-k", s.apiKeyFile("root")},
+			ErrorMessage: "Error authenticating the user: .*"},
+	}
+
+	for _, t := range tests {
+		Manage.Operation.Approve = OperationApproveCommand{}
+		Manage.Operation.Reject = OperationRejectCommand{}
+		runTest(c, t.Args, t.ErrorMessage)
+	}
+}
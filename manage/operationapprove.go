@@ -0,0 +1,67 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package manage
+
+import (
+	"fmt"
+
+	"github.com/CanonicalLtd/serial-vault/datastore"
+)
+
+// OperationApproveCommand handles the approval of a keypair operation for the serial-vault-admin command
+type OperationApproveCommand struct {
+	OperationID int    `short:"i" long:"id" description:"ID of the operation, as shown by the list command" required:"yes"`
+	Username    string `short:"u" long:"username" description:"Username of the approving Superuser, who must not be the requester" required:"yes"`
+	APIKeyFile  string `short:"k" long:"api-key-file" description:"Path to the file with the API key of the approving Superuser" required:"yes"`
+}
+
+// Execute the approval of a keypair operation
+func (cmd OperationApproveCommand) Execute(args []string) error {
+	// Open the database and authenticate the approving user
+	openDatabase()
+	user, err := authenticateUser(cmd.Username, cmd.APIKeyFile)
+	if err != nil {
+		return err
+	}
+
+	// An import or generation of a signing-key is carried out in the keystore
+	op, err := datastore.Environ.DB.GetPendingOperation(cmd.OperationID)
+	if err != nil {
+		return fmt.Errorf("Error approving the operation: %v", err)
+	}
+	if op.Operation == datastore.OperationImport || op.Operation == datastore.OperationGenerate {
+		if err := openKeyStore(); err != nil {
+			return fmt.Errorf("Error opening the keystore: %v", err)
+		}
+	}
+
+	op, err = datastore.ApproveOperation(cmd.OperationID, user, "")
+	if err != nil {
+		return fmt.Errorf("Error approving the operation: %v", err)
+	}
+
+	if op.Status == datastore.OperationStatusApproved {
+		fmt.Printf("Operation %d approved: the requester can now carry out the %s\n", op.ID, op.Operation)
+		return nil
+	}
+	fmt.Printf("Operation %d approved and carried out successfully\n", op.ID)
+	return nil
+}
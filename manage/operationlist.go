@@ -0,0 +1,65 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package manage
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/CanonicalLtd/serial-vault/datastore"
+)
+
+// OperationListCommand handles the list of keypair operations for the serial-vault-admin command
+type OperationListCommand struct{}
+
+// Execute the list of keypair operations
+func (cmd OperationListCommand) Execute(args []string) error {
+	// Open the database and get the operations
+	openDatabase()
+	operations, err := datastore.Environ.DB.ListAllowedPendingOperations(datastore.User{})
+	if err != nil {
+		return fmt.Errorf("Error listing the keypair operations: %v", err)
+	}
+
+	if len(operations) == 0 {
+		fmt.Println("No keypair operations have been requested")
+		return nil
+	}
+
+	// Create a tabwriter to format the output
+	w := new(tabwriter.Writer)
+	w.Init(os.Stdout, 5, 0, 4, ' ', 0)
+
+	// Print the headers
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "ID\tOperation\tAuthority ID\tKey Name\tStatus\tRequested By\tRequested\tDecided By\tMessage")
+
+	// Print the operation list
+	for _, o := range operations {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", o.ID, o.Operation, o.AuthorityID, o.KeyName, o.Status, o.RequestedBy,
+			o.RequestedAt.Format("2006-01-02 15:04"), o.DecidedBy, o.Message)
+	}
+	fmt.Fprintln(w, "")
+	w.Flush()
+
+	return nil
+}
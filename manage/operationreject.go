@@ -0,0 +1,53 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package manage
+
+import (
+	"fmt"
+
+	"github.com/CanonicalLtd/serial-vault/datastore"
+)
+
+// OperationRejectCommand handles the rejection of a keypair operation for the serial-vault-admin command
+type OperationRejectCommand struct {
+	OperationID int    `short:"i" long:"id" description:"ID of the operation, as shown by the list command" required:"yes"`
+	Username    string `short:"u" long:"username" description:"Username of the rejecting Superuser, or of the requester to withdraw it" required:"yes"`
+	APIKeyFile  string `short:"k" long:"api-key-file" description:"Path to the file with the API key of the rejecting user" required:"yes"`
+	Message     string `short:"m" long:"message" description:"Reason for rejecting the operation"`
+}
+
+// Execute the rejection of a keypair operation
+func (cmd OperationRejectCommand) Execute(args []string) error {
+	// Open the database and authenticate the rejecting user
+	openDatabase()
+	user, err := authenticateUser(cmd.Username, cmd.APIKeyFile)
+	if err != nil {
+		return err
+	}
+
+	_, err = datastore.RejectOperation(cmd.OperationID, user, cmd.Message, "")
+	if err != nil {
+		return fmt.Errorf("Error rejecting the operation: %v", err)
+	}
+
+	fmt.Printf("Operation %d rejected\n", cmd.OperationID)
+	return nil
+}
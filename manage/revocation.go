@@ -0,0 +1,27 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017-2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package manage
+
+// RevocationCommand is the main command for the management of the revoked device-keys and serial numbers
+type RevocationCommand struct {
+	List   RevocationListCommand   `command:"list" alias:"ls" alias:"l" description:"List the revoked device-keys and serial numbers"`
+	Add    RevocationAddCommand    `command:"add" alias:"a" description:"Revoke a device-key or a serial number"`
+	Delete RevocationDeleteCommand `command:"delete" alias:"d" description:"Delete a revocation, so the device-key or serial number can be signed again"`
+}
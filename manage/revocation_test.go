@@ -0,0 +1,101 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017-2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package manage
+
+import (
+	"github.com/CanonicalLtd/serial-vault/datastore"
+	"gopkg.in/check.v1"
+)
+
+type RevocationSuite struct{}
+
+var _ = check.Suite(&RevocationSuite{})
+
+func (s *RevocationSuite) SetUpTest(c *check.C) {
+	datastore.Environ = &datastore.Env{DB: &datastore.MockDB{}}
+}
+
+func (s *RevocationSuite) TestRevocation(c *check.C) {
+	tests := []manTest{
+		{
+			Args:         []string{"serial-vault-admin", "revocation"},
+			ErrorMessage: "Please specify one command of: add, delete or list"},
+		{
+			Args:         []string{"serial-vault-admin", "revocation", "list"},
+			ErrorMessage: ""},
+		{
+			Args:         []string{"serial-vault-admin", "revocation", "add", "-b", "system", "-m", "alder", "-s", "A123456L", "-r", "Stolen device"},
+			ErrorMessage: ""},
+		{
+			Args:         []string{"serial-vault-admin", "revocation", "add", "-b", "system", "-f", "majNNh3Nbgg0CozIjfLrvvEWG830dZmm76gJHnyyrW4g7udYbfVgt0WO15ayuN5l", "-r", "Leaked device-key"},
+			ErrorMessage: ""},
+		{
+			Args:         []string{"serial-vault-admin", "revocation", "add", "-b", "system", "-r", "Stolen device"},
+			ErrorMessage: "Please supply the serial number or the device-key fingerprint to revoke"},
+		{
+			Args:         []string{"serial-vault-admin", "revocation", "add", "-b", "system", "-m", "alder", "-s", "A123456L"},
+			ErrorMessage: "the required flag `-r, --reason' was not specified"},
+		{
+			Args:         []string{"serial-vault-admin", "revocation", "add", "-b", "system", "-s", "A123456L", "-r", "Stolen device"},
+			ErrorMessage: "Error adding the revocation: Model must not be empty"},
+		{
+			Args:         []string{"serial-vault-admin", "revocation", "add", "-b", "system", "-f", "invalid", "-r", "Leaked device-key"},
+			ErrorMessage: "Error adding the revocation: invalid device-key fingerprint .*"},
+		{
+			Args:         []string{"serial-vault-admin", "revocation", "add", "-b", "invalid", "-m", "alder", "-s", "A123456L", "-r", "Stolen device"},
+			ErrorMessage: "Error adding the revocation: .*"},
+		{
+			Args:         []string{"serial-vault-admin", "revocation", "delete"},
+			ErrorMessage: "the required flag `-i, --id' was not specified"},
+		{
+			Args:         []string{"serial-vault-admin", "revocation", "delete", "-i", "1"},
+			ErrorMessage: ""},
+		{
+			Args:         []string{"serial-vault-admin", "revocation", "delete", "-i", "999"},
+			ErrorMessage: "Error deleting the revocation: MOCK the device-key revocation does not exist"},
+	}
+
+	for _, t := range tests {
+		// The flags are parsed into the global command, so reset the previous values
+		Manage.Revocation.Add = RevocationAddCommand{}
+		runTest(c, t.Args, t.ErrorMessage)
+	}
+}
+
+func (s *RevocationSuite) TestRevocationError(c *check.C) {
+	datastore.Environ.DB = &datastore.ErrorMockDB{}
+
+	tests := []manTest{
+		{
+			Args:         []string{"serial-vault-admin", "revocation", "list"},
+			ErrorMessage: "Error listing the device-key revocations: MOCK error retrieving the device-key revocations"},
+		{
+			Args:         []string{"serial-vault-admin", "revocation", "add", "-b", "system", "-m", "alder", "-s", "A123456L", "-r", "Stolen device"},
+			ErrorMessage: "Error adding the revocation: MOCK error creating the device-key revocation"},
+		{
+			Args:         []string{"serial-vault-admin", "revocation", "delete", "-i", "1"},
+			ErrorMessage: "Error deleting the revocation: MOCK error deleting the device-key revocation"},
+	}
+
+	for _, t := range tests {
+		Manage.Revocation.Add = RevocationAddCommand{}
+		runTest(c, t.Args, t.ErrorMessage)
+	}
+}
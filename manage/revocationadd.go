@@ -0,0 +1,61 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017-2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package manage
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/CanonicalLtd/serial-vault/datastore"
+)
+
+// RevocationAddCommand handles the revocation of a device-key or serial number for the serial-vault-admin command
+type RevocationAddCommand struct {
+	Brand       string `short:"b" long:"brand" description:"Brand ID of the device" required:"yes"`
+	Model       string `short:"m" long:"model" description:"Model of the device, required to revoke a serial number"`
+	Serial      string `short:"s" long:"serial" description:"Serial number to revoke"`
+	Fingerprint string `short:"f" long:"fingerprint" description:"SHA3-384 fingerprint of the device-key to revoke"`
+	Reason      string `short:"r" long:"reason" description:"Reason for the revocation" required:"yes"`
+}
+
+// Execute the revocation of a device-key or serial number
+func (cmd RevocationAddCommand) Execute(args []string) error {
+	if len(cmd.Serial) == 0 && len(cmd.Fingerprint) == 0 {
+		return errors.New("Please supply the serial number or the device-key fingerprint to revoke")
+	}
+
+	rev := datastore.DeviceKeyRevocation{
+		BrandID:      cmd.Brand,
+		Model:        cmd.Model,
+		SerialNumber: cmd.Serial,
+		Fingerprint:  cmd.Fingerprint,
+		Reason:       cmd.Reason,
+	}
+
+	// Open the database and create the revocation
+	openDatabase()
+	rev, err := datastore.Environ.DB.CreateAllowedDeviceKeyRevocation(rev, datastore.User{})
+	if err != nil {
+		return fmt.Errorf("Error adding the revocation: %v", err)
+	}
+
+	fmt.Printf("Revocation %d added successfully\n", rev.ID)
+	return nil
+}
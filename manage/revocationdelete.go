@@ -0,0 +1,44 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017-2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package manage
+
+import (
+	"fmt"
+
+	"github.com/CanonicalLtd/serial-vault/datastore"
+)
+
+// RevocationDeleteCommand handles the deletion of a device-key revocation for the serial-vault-admin command
+type RevocationDeleteCommand struct {
+	RevocationID int `short:"i" long:"id" description:"ID of the revocation, as shown by the list command" required:"yes"`
+}
+
+// Execute the deletion of a device-key revocation
+func (cmd RevocationDeleteCommand) Execute(args []string) error {
+	// Open the database and delete the revocation
+	openDatabase()
+	err := datastore.Environ.DB.DeleteAllowedDeviceKeyRevocation(cmd.RevocationID, datastore.User{})
+	if err != nil {
+		return fmt.Errorf("Error deleting the revocation: %v", err)
+	}
+
+	fmt.Printf("Revocation %d deleted successfully\n", cmd.RevocationID)
+	return nil
+}
@@ -0,0 +1,63 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017-2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package manage
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/CanonicalLtd/serial-vault/datastore"
+)
+
+// RevocationListCommand handles the list of device-key revocations for the serial-vault-admin command
+type RevocationListCommand struct{}
+
+// Execute the list of device-key revocations
+func (cmd RevocationListCommand) Execute(args []string) error {
+	// Open the database and get the revocations
+	openDatabase()
+	revocations, err := datastore.Environ.DB.ListAllowedDeviceKeyRevocations(datastore.User{})
+	if err != nil {
+		return fmt.Errorf("Error listing the device-key revocations: %v", err)
+	}
+
+	if len(revocations) == 0 {
+		fmt.Println("No device-keys or serial numbers have been revoked")
+		return nil
+	}
+
+	// Create a tabwriter to format the output
+	w := new(tabwriter.Writer)
+	w.Init(os.Stdout, 5, 0, 4, ' ', 0)
+
+	// Print the headers
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "ID\tBrand\tModel\tSerial Number\tDevice-Key Fingerprint\tReason\tCreated")
+
+	// Print the revocation list
+	for _, r := range revocations {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n", r.ID, r.BrandID, r.Model, r.SerialNumber, r.Fingerprint, r.Reason, r.Created.Format("2006-01-02 15:04"))
+	}
+	fmt.Fprintln(w, "")
+	w.Flush()
+
+	return nil
+}
@@ -0,0 +1,56 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017-2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package manage
+
+import (
+	"fmt"
+
+	"github.com/CanonicalLtd/serial-vault/datastore"
+)
+
+// SerialRuleCommand is the main command for the management of the allowed serial numbers of a model
+type SerialRuleCommand struct {
+	List   SerialRuleListCommand   `command:"list" alias:"ls" alias:"l" description:"List the serial number rules of a model"`
+	Add    SerialRuleAddCommand    `command:"add" alias:"a" description:"Add serial numbers, ranges or patterns to the allowed serial numbers of a model"`
+	Delete SerialRuleDeleteCommand `command:"delete" alias:"d" description:"Delete a serial number rule of a model"`
+}
+
+func checkModelArgs(args []string, action string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("%s serial number rules expects 'brand' and 'model' arguments", action)
+	}
+	return nil
+}
+
+// findModel looks up the model from the brand and model name. The command-line
+// has full access to the database, so the models are not filtered by user
+func findModel(brandID, modelName string) (datastore.Model, error) {
+	models, err := datastore.Environ.DB.ListAllowedModels(datastore.User{})
+	if err != nil {
+		return datastore.Model{}, fmt.Errorf("Error finding the model: %v", err)
+	}
+
+	for _, m := range models {
+		if m.BrandID == brandID && m.Name == modelName {
+			return m, nil
+		}
+	}
+	return datastore.Model{}, fmt.Errorf("Cannot find the model '%s' for brand '%s'", modelName, brandID)
+}
@@ -0,0 +1,113 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017-2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package manage
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/CanonicalLtd/serial-vault/datastore"
+	"gopkg.in/check.v1"
+)
+
+type SerialRuleSuite struct{}
+
+var _ = check.Suite(&SerialRuleSuite{})
+
+func (s *SerialRuleSuite) SetUpTest(c *check.C) {
+	datastore.Environ = &datastore.Env{DB: &datastore.MockDB{}}
+}
+
+func (s *SerialRuleSuite) TestSerialRule(c *check.C) {
+	dir := c.MkDir()
+	serialFile := filepath.Join(dir, "serials.txt")
+	err := ioutil.WriteFile(serialFile, []byte("A0001\n\nA0002\n"), os.ModePerm)
+	c.Assert(err, check.IsNil)
+
+	tests := []manTest{
+		{
+			Args:         []string{"serial-vault-admin", "serialrule"},
+			ErrorMessage: "Please specify one command of: add, delete or list"},
+		{
+			Args:         []string{"serial-vault-admin", "serialrule", "list"},
+			ErrorMessage: "List serial number rules expects 'brand' and 'model' arguments"},
+		{
+			Args:         []string{"serial-vault-admin", "serialrule", "list", "system", "alder"},
+			ErrorMessage: ""},
+		{
+			Args:         []string{"serial-vault-admin", "serialrule", "list", "system", "invalid"},
+			ErrorMessage: "Cannot find the model 'invalid' for brand 'system'"},
+		{
+			Args:         []string{"serial-vault-admin", "serialrule", "add", "system"},
+			ErrorMessage: "Add serial number rules expects 'brand' and 'model' arguments"},
+		{
+			Args:         []string{"serial-vault-admin", "serialrule", "add", "system", "alder"},
+			ErrorMessage: "No serial number rules requested. Please supply serial numbers, a range or a pattern"},
+		{
+			Args:         []string{"serial-vault-admin", "serialrule", "add", "system", "alder", "-s", "A123456L", "-s", "A123456M"},
+			ErrorMessage: ""},
+		{
+			Args:         []string{"serial-vault-admin", "serialrule", "add", "system", "alder", "-f", serialFile},
+			ErrorMessage: ""},
+		{
+			Args:         []string{"serial-vault-admin", "serialrule", "add", "system", "alder", "-f", filepath.Join(dir, "missing.txt")},
+			ErrorMessage: "Error opening the serial number file: .*"},
+		{
+			Args:         []string{"serial-vault-admin", "serialrule", "add", "system", "alder", "-r", "1000-1999", "-p", "A"},
+			ErrorMessage: ""},
+		{
+			Args:         []string{"serial-vault-admin", "serialrule", "add", "system", "alder", "-r", "1999-1000"},
+			ErrorMessage: "Error adding the serial number rules: invalid serial number rule .*"},
+		{
+			Args:         []string{"serial-vault-admin", "serialrule", "add", "system", "alder", "-r", "1000"},
+			ErrorMessage: "The range must be in the form START-END"},
+		{
+			Args:         []string{"serial-vault-admin", "serialrule", "add", "system", "alder", "-r", "a-1000"},
+			ErrorMessage: "The start of the range must be a number"},
+		{
+			Args:         []string{"serial-vault-admin", "serialrule", "add", "system", "alder", "-p", "A"},
+			ErrorMessage: "The prefix can only be used with a range"},
+		{
+			Args:         []string{"serial-vault-admin", "serialrule", "add", "system", "alder", "-e", "B[0-9]{6}L"},
+			ErrorMessage: ""},
+		{
+			Args:         []string{"serial-vault-admin", "serialrule", "add", "system", "alder", "-e", "[invalid"},
+			ErrorMessage: "Error adding the serial number rules: invalid serial number rule .*"},
+		{
+			Args:         []string{"serial-vault-admin", "serialrule", "add", "system", "invalid", "-s", "A123456L"},
+			ErrorMessage: "Cannot find the model 'invalid' for brand 'system'"},
+		{
+			Args:         []string{"serial-vault-admin", "serialrule", "delete", "system", "alder"},
+			ErrorMessage: "the required flag `-i, --id' was not specified"},
+		{
+			Args:         []string{"serial-vault-admin", "serialrule", "delete", "system", "alder", "-i", "1"},
+			ErrorMessage: ""},
+		{
+			Args:         []string{"serial-vault-admin", "serialrule", "delete", "system", "-i", "1"},
+			ErrorMessage: "Delete serial number rules expects 'brand' and 'model' arguments"},
+	}
+
+	for _, t := range tests {
+		// The flags are parsed into the global command, so reset the previous values
+		Manage.SerialRule.Add = SerialRuleAddCommand{}
+		runTest(c, t.Args, t.ErrorMessage)
+	}
+}
@@ -0,0 +1,145 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017-2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package manage
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/CanonicalLtd/serial-vault/datastore"
+)
+
+// SerialRuleAddCommand handles adding serial number rules for the serial-vault-admin command
+type SerialRuleAddCommand struct {
+	Serials  []string `short:"s" long:"serial" description:"Serial number that is allowed (can be repeated)"`
+	File     string   `short:"f" long:"file" description:"Path to a file with a serial number on each line"`
+	Range    string   `short:"r" long:"range" description:"Range of serial numbers that are allowed, in the form START-END"`
+	Prefix   string   `short:"p" long:"prefix" description:"Prefix of the serial numbers in the range"`
+	Patterns []string `short:"e" long:"pattern" description:"Regular expression that must match the whole serial number (can be repeated)"`
+}
+
+// Execute the adding of the serial number rules
+func (cmd SerialRuleAddCommand) Execute(args []string) error {
+	err := checkModelArgs(args, "Add")
+	if err != nil {
+		return err
+	}
+
+	rules, err := cmd.rules()
+	if err != nil {
+		return err
+	}
+	if len(rules) == 0 {
+		return fmt.Errorf("No serial number rules requested. Please supply serial numbers, a range or a pattern")
+	}
+
+	// Open the database and add the rules to the model
+	openDatabase()
+	model, err := findModel(args[0], args[1])
+	if err != nil {
+		return err
+	}
+
+	err = datastore.Environ.DB.CreateAllowedSerialRules(model.ID, rules, datastore.User{})
+	if err != nil {
+		return fmt.Errorf("Error adding the serial number rules: %v", err)
+	}
+
+	fmt.Printf("%d serial number rule(s) added to model '%s' successfully\n", len(rules), model.Name)
+	return nil
+}
+
+func (cmd SerialRuleAddCommand) rules() ([]datastore.SerialRule, error) {
+	rules := []datastore.SerialRule{}
+
+	for _, s := range cmd.Serials {
+		rules = append(rules, datastore.SerialRule{RuleType: datastore.SerialRuleSerial, Value: s})
+	}
+
+	if len(cmd.File) > 0 {
+		serials, err := readSerialFile(cmd.File)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range serials {
+			rules = append(rules, datastore.SerialRule{RuleType: datastore.SerialRuleSerial, Value: s})
+		}
+	}
+
+	if len(cmd.Range) > 0 {
+		start, end, err := parseSerialRange(cmd.Range)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, datastore.SerialRule{RuleType: datastore.SerialRuleRange, Value: cmd.Prefix, RangeStart: start, RangeEnd: end})
+	} else if len(cmd.Prefix) > 0 {
+		return nil, fmt.Errorf("The prefix can only be used with a range")
+	}
+
+	for _, p := range cmd.Patterns {
+		rules = append(rules, datastore.SerialRule{RuleType: datastore.SerialRulePattern, Value: p})
+	}
+
+	return rules, nil
+}
+
+// readSerialFile reads the serial numbers from a file, one per line, ignoring empty lines
+func readSerialFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error opening the serial number file: %v", err)
+	}
+	defer f.Close()
+
+	serials := []string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		s := strings.TrimSpace(scanner.Text())
+		if len(s) == 0 {
+			continue
+		}
+		serials = append(serials, s)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Error reading the serial number file: %v", err)
+	}
+	return serials, nil
+}
+
+// parseSerialRange parses a range in the form START-END
+func parseSerialRange(r string) (int64, int64, error) {
+	parts := strings.Split(r, "-")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("The range must be in the form START-END")
+	}
+
+	start, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("The start of the range must be a number")
+	}
+	end, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("The end of the range must be a number")
+	}
+	return start, end, nil
+}
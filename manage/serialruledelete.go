@@ -0,0 +1,54 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017-2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package manage
+
+import (
+	"fmt"
+
+	"github.com/CanonicalLtd/serial-vault/datastore"
+)
+
+// SerialRuleDeleteCommand handles serial number rule delete for the serial-vault-admin command
+type SerialRuleDeleteCommand struct {
+	RuleID int `short:"i" long:"id" description:"ID of the serial number rule, as shown by the list command" required:"yes"`
+}
+
+// Execute serial number rule deletion
+func (cmd SerialRuleDeleteCommand) Execute(args []string) error {
+	err := checkModelArgs(args, "Delete")
+	if err != nil {
+		return err
+	}
+
+	// Open the database and delete the rule from the model
+	openDatabase()
+	model, err := findModel(args[0], args[1])
+	if err != nil {
+		return err
+	}
+
+	err = datastore.Environ.DB.DeleteAllowedSerialRule(model.ID, cmd.RuleID, datastore.User{})
+	if err != nil {
+		return fmt.Errorf("Error deleting the serial number rule: %v", err)
+	}
+
+	fmt.Printf("Serial number rule %d deleted from model '%s' successfully\n", cmd.RuleID, model.Name)
+	return nil
+}
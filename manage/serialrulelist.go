@@ -0,0 +1,79 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017-2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package manage
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/CanonicalLtd/serial-vault/datastore"
+)
+
+// SerialRuleListCommand handles the list of serial number rules for the serial-vault-admin command
+type SerialRuleListCommand struct{}
+
+// Execute the list of serial number rules
+func (cmd SerialRuleListCommand) Execute(args []string) error {
+	err := checkModelArgs(args, "List")
+	if err != nil {
+		return err
+	}
+
+	// Open the database and get the rules of the model
+	openDatabase()
+	model, err := findModel(args[0], args[1])
+	if err != nil {
+		return err
+	}
+
+	rules, err := datastore.Environ.DB.ListAllowedSerialRules(model.ID, datastore.User{})
+	if err != nil {
+		return fmt.Errorf("Error listing the serial number rules: %v", err)
+	}
+
+	if len(rules) == 0 {
+		fmt.Printf("No serial number rules for model '%s': all serial numbers are allowed\n", model.Name)
+		return nil
+	}
+
+	// Create a tabwriter to format the output
+	w := new(tabwriter.Writer)
+	w.Init(os.Stdout, 5, 0, 4, ' ', 0)
+
+	// Print the headers
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "ID\tType\tValue\tStart\tEnd")
+
+	// Print the rule list
+	for _, r := range rules {
+		s := fmt.Sprintf("%d\t%s\t%s\t", r.ID, r.RuleType, r.Value)
+		if r.RuleType == datastore.SerialRuleRange {
+			s += fmt.Sprintf("%d\t%d", r.RangeStart, r.RangeEnd)
+		} else {
+			s += "\t"
+		}
+		fmt.Fprintln(w, s)
+	}
+	fmt.Fprintln(w, "")
+	w.Flush()
+
+	return nil
+}
@@ -0,0 +1,87 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package manage
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/CanonicalLtd/serial-vault/datastore"
+	"gopkg.in/check.v1"
+)
+
+type SigningLogSuite struct{}
+
+var _ = check.Suite(&SigningLogSuite{})
+
+func (s *SigningLogSuite) TestSigningLogVerify(c *check.C) {
+	tests := []struct {
+		manTest
+		db datastore.Datastore
+	}{
+		{manTest{[]string{"serial-vault-admin", "signinglog", "verify"}, ""}, &datastore.MockDB{}},
+		{manTest{[]string{"serial-vault-admin", "signinglog", "verify", "-b", "System"}, ""}, &datastore.MockDB{}},
+		{manTest{[]string{"serial-vault-admin", "signinglog", "verify", "--brand", "changed"}, "The signing log hash chain has 1 problem\\(s\\)"}, &datastore.MockDB{}},
+		{manTest{[]string{"serial-vault-admin", "signinglog", "verify"}, "Error verifying the signing log chain"}, &datastore.ErrorMockDB{}},
+	}
+
+	for _, t := range tests {
+		datastore.Environ = &datastore.Env{DB: t.db}
+		Manage.SigningLog.Verify = SigningLogVerifyCommand{}
+		runTest(c, t.Args, t.ErrorMessage)
+	}
+}
+
+func (s *SigningLogSuite) TestSigningLogExport(c *check.C) {
+	dir := c.MkDir()
+	exportFile := filepath.Join(dir, "signinglog.csv")
+	errorFile := filepath.Join(dir, "error.csv")
+
+	tests := []struct {
+		manTest
+		db datastore.Datastore
+	}{
+		{manTest{[]string{"serial-vault-admin", "signinglog"}, "Please specify one command of: export or verify"}, &datastore.MockDB{}},
+		{manTest{[]string{"serial-vault-admin", "signinglog", "export", "-f", "xml"}, "Invalid value `xml' for option `-f, --format'.*"}, &datastore.MockDB{}},
+		{manTest{[]string{"serial-vault-admin", "signinglog", "export", "--from", "yesterday"}, "invalid date: yesterday"}, &datastore.MockDB{}},
+		{manTest{[]string{"serial-vault-admin", "signinglog", "export", "--synced", "maybe"}, "Invalid value `maybe' for option `--synced'.*"}, &datastore.MockDB{}},
+		{manTest{[]string{"serial-vault-admin", "signinglog", "export", "-o", exportFile, "-b", "system", "-m", "alder", "-m", "ash", "--from", "2026-01-01", "--synced", "true"}, ""}, &datastore.MockDB{}},
+		{manTest{[]string{"serial-vault-admin", "signinglog", "export", "-o", exportFile}, "Error creating the export file: .*"}, &datastore.MockDB{}},
+		{manTest{[]string{"serial-vault-admin", "signinglog", "export", "-f", "jsonl", "-o", errorFile}, "Error exporting the signing log: Error retrieving the signing logs"}, &datastore.ErrorMockDB{}},
+	}
+
+	for _, t := range tests {
+		datastore.Environ = &datastore.Env{DB: t.db}
+		// The flags are parsed into the global command, so reset the previous values
+		Manage.SigningLog.Export = SigningLogExportCommand{}
+		runTest(c, t.Args, t.ErrorMessage)
+	}
+
+	data, err := ioutil.ReadFile(exportFile)
+	c.Assert(err, check.IsNil)
+	c.Assert(strings.Count(string(data), "\n"), check.Equals, 11)
+
+	// An incomplete export is removed
+	_, err = os.Stat(errorFile)
+	c.Assert(os.IsNotExist(err), check.Equals, true)
+}
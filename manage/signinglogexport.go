@@ -0,0 +1,90 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package manage
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/CanonicalLtd/serial-vault/datastore"
+)
+
+// SigningLogExportCommand handles the export of the signing log for the serial-vault-admin command
+type SigningLogExportCommand struct {
+	Format string   `short:"f" long:"format" description:"Format of the export" choice:"csv" choice:"jsonl" default:"csv"`
+	Output string   `short:"o" long:"output" description:"Path of the file to create (default: standard output)"`
+	Brand  string   `short:"b" long:"brand" description:"The brand (authority-id) of the devices"`
+	Models []string `short:"m" long:"model" description:"A model of the devices, which can be repeated"`
+	From   string   `long:"from" description:"The logs created at or after the date (RFC3339 or YYYY-MM-DD)"`
+	To     string   `long:"to" description:"The logs created before the date (RFC3339 or YYYY-MM-DD)"`
+	Synced string   `long:"synced" description:"Whether the factory logs have been synced to the cloud" choice:"true" choice:"false"`
+}
+
+// Execute the export of the signing log
+func (cmd SigningLogExportCommand) Execute(args []string) error {
+	params := &datastore.SigningLogParams{Filter: cmd.Models}
+
+	var err error
+	if params.From, err = datastore.ParseDate(cmd.From); err != nil {
+		return err
+	}
+	if params.To, err = datastore.ParseDate(cmd.To); err != nil {
+		return err
+	}
+	if cmd.Synced != "" {
+		synced := cmd.Synced == "true"
+		params.Synced = &synced
+	}
+
+	var w io.Writer = os.Stdout
+	if cmd.Output != "" {
+		// An existing file is not overwritten
+		f, err := os.OpenFile(cmd.Output, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err != nil {
+			return fmt.Errorf("Error creating the export file: %v", err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	// The records are written as they are read, so the buffer is all that is held in memory
+	buf := bufio.NewWriter(w)
+
+	openDatabase()
+	count, err := datastore.ExportSigningLog(buf, cmd.Format, datastore.User{}, cmd.Brand, params)
+	if err == nil {
+		err = buf.Flush()
+	}
+	if err != nil {
+		// Do not leave an incomplete export behind
+		if cmd.Output != "" {
+			os.Remove(cmd.Output)
+		}
+		return fmt.Errorf("Error exporting the signing log: %v", err)
+	}
+
+	if cmd.Output != "" {
+		fmt.Printf("Exported %d signing logs to %s\n", count, cmd.Output)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package manage
+
+import (
+	"fmt"
+
+	"github.com/CanonicalLtd/serial-vault/datastore"
+)
+
+// SigningLogVerifyCommand handles the verification of the signing log hash chains for the serial-vault-admin command
+type SigningLogVerifyCommand struct {
+	Brand string `short:"b" long:"brand" description:"The brand (authority-id) to verify (default: all brands)"`
+}
+
+// Execute the verification of the signing log hash chains
+func (cmd SigningLogVerifyCommand) Execute(args []string) error {
+	openDatabase()
+
+	reports, err := datastore.Environ.DB.VerifySigningLogChain(cmd.Brand)
+	if err != nil {
+		return err
+	}
+	if len(reports) == 0 {
+		fmt.Println("No signing logs to verify")
+		return nil
+	}
+
+	problems := 0
+	for _, r := range reports {
+		if r.Valid() {
+			fmt.Printf("%s: %d entries, verified to entry %d\n", r.Make, r.Entries, r.Head)
+			continue
+		}
+
+		fmt.Printf("%s: %d entries, %d problem(s)\n", r.Make, r.Entries, len(r.Problems))
+		for _, p := range r.Problems {
+			fmt.Printf("  %s\n", p)
+		}
+		problems += len(r.Problems)
+	}
+
+	if problems > 0 {
+		return fmt.Errorf("The signing log hash chain has %d problem(s)", problems)
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package pkcs11 is the protocol of the key manager for RSA signing-keys that are held on a
+// PKCS#11 token. The binding of the token uses cgo, so it is kept in the serial-vault-pkcs11
+// command, and this package can be imported by the services without it.
+package pkcs11
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// Common error messages
+var (
+	ErrorKeyNotFound   = errors.New("Cannot find the signing-key on the PKCS#11 token")
+	ErrorKeyExists     = errors.New("A different signing-key with the same label is already on the PKCS#11 token")
+	ErrorTokenNotFound = errors.New("Cannot find the PKCS#11 token")
+)
+
+// KeyStore is the store of the RSA signing-keys used by the key manager
+type KeyStore interface {
+	KeyLabels() ([]string, error)
+	PublicKey(label string) (*rsa.PublicKey, error)
+	Sign(label string, data []byte) ([]byte, error)
+	ImportKey(label string, key *rsa.PrivateKey) error
+}
+
+// Environment variables used to configure the key manager. When the settings file is set,
+// the module, token and PIN are read from it instead, so the PIN is not in the environment
+const (
+	EnvModule   = "SERIAL_VAULT_PKCS11_MODULE"
+	EnvToken    = "SERIAL_VAULT_PKCS11_TOKEN"
+	EnvPIN      = "SERIAL_VAULT_PKCS11_PIN"
+	EnvSettings = "SERIAL_VAULT_PKCS11_SETTINGS"
+)
+
+// Run handles a single key manager operation, using the protocol of the snapd external
+// keypair manager (asserts.ExternalKeypairManager):
+//
+//	features                      list the supported signing mechanisms and public key formats
+//	key-names                     list the labels of the keys
+//	get-public-key -f DER -k NAME write the DER-encoded public key
+//	sign -m RSA-PKCS -k NAME      sign the DigestInfo from stdin, writing the signature
+//
+// The import operation is specific to the serial vault:
+//
+//	import -k NAME                import the PKCS#1 DER-encoded private key from stdin
+func Run(ks KeyStore, args []string, stdin io.Reader, stdout io.Writer) error {
+	if len(args) == 0 {
+		return errors.New("Missing the key manager operation")
+	}
+
+	flags := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard)
+	format := flags.String("f", "DER", "public key format")
+	mechanism := flags.String("m", "RSA-PKCS", "signing mechanism")
+	label := flags.String("k", "", "key name")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "features":
+		return json.NewEncoder(stdout).Encode(map[string][]string{
+			"signing":     {"RSA-PKCS"},
+			"public-keys": {"DER"},
+		})
+
+	case "key-names":
+		labels, err := ks.KeyLabels()
+		if err != nil {
+			return err
+		}
+		return json.NewEncoder(stdout).Encode(map[string][]string{"key-names": labels})
+
+	case "get-public-key":
+		if *format != "DER" {
+			return fmt.Errorf("Unsupported public key format: %s", *format)
+		}
+		public, err := ks.PublicKey(*label)
+		if err != nil {
+			return err
+		}
+		der, err := x509.MarshalPKIXPublicKey(public)
+		if err != nil {
+			return err
+		}
+		_, err = stdout.Write(der)
+		return err
+
+	case "sign":
+		if *mechanism != "RSA-PKCS" {
+			return fmt.Errorf("Unsupported signing mechanism: %s", *mechanism)
+		}
+		data, err := ioutil.ReadAll(stdin)
+		if err != nil {
+			return err
+		}
+		signature, err := ks.Sign(*label, data)
+		if err != nil {
+			return err
+		}
+		_, err = stdout.Write(signature)
+		return err
+
+	case "import":
+		if len(*label) == 0 {
+			return errors.New("Missing the key name")
+		}
+		der, err := ioutil.ReadAll(stdin)
+		if err != nil {
+			return err
+		}
+		defer Zero(der)
+		key, err := x509.ParsePKCS1PrivateKey(der)
+		if err != nil {
+			return err
+		}
+		return ks.ImportKey(*label, key)
+
+	default:
+		return fmt.Errorf("Unknown key manager operation: %s", args[0])
+	}
+}
+
+// Zero overwrites the key material in the buffer
+func Zero(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
@@ -0,0 +1,163 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package pkcs11
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// memoryKeyStore is a key store that signs with keys held in memory
+type memoryKeyStore struct {
+	keys map[string]*rsa.PrivateKey
+}
+
+func (ks *memoryKeyStore) KeyLabels() ([]string, error) {
+	labels := []string{}
+	for label := range ks.keys {
+		labels = append(labels, label)
+	}
+	return labels, nil
+}
+
+func (ks *memoryKeyStore) PublicKey(label string) (*rsa.PublicKey, error) {
+	key, ok := ks.keys[label]
+	if !ok {
+		return nil, ErrorKeyNotFound
+	}
+	return &key.PublicKey, nil
+}
+
+func (ks *memoryKeyStore) Sign(label string, data []byte) ([]byte, error) {
+	key, ok := ks.keys[label]
+	if !ok {
+		return nil, ErrorKeyNotFound
+	}
+	// The data is already wrapped in a DigestInfo, so it is signed directly
+	return rsa.SignPKCS1v15(nil, key, 0, data)
+}
+
+func (ks *memoryKeyStore) ImportKey(label string, key *rsa.PrivateKey) error {
+	ks.keys[label] = key
+	return nil
+}
+
+func runKeyManager(ks KeyStore, stdin []byte, args ...string) ([]byte, error) {
+	stdout := &bytes.Buffer{}
+	err := Run(ks, args, bytes.NewReader(stdin), stdout)
+	return stdout.Bytes(), err
+}
+
+func TestRunFeatures(t *testing.T) {
+	out, err := runKeyManager(&memoryKeyStore{}, nil, "features")
+	if err != nil {
+		t.Fatalf("Error getting the features: %v", err)
+	}
+
+	var features struct {
+		Signing    []string `json:"signing"`
+		PublicKeys []string `json:"public-keys"`
+	}
+	if err := json.Unmarshal(out, &features); err != nil {
+		t.Fatalf("Error decoding the features: %v", err)
+	}
+	if len(features.Signing) != 1 || features.Signing[0] != "RSA-PKCS" {
+		t.Errorf("Expected RSA-PKCS signing, got: %v", features.Signing)
+	}
+	if len(features.PublicKeys) != 1 || features.PublicKeys[0] != "DER" {
+		t.Errorf("Expected DER public keys, got: %v", features.PublicKeys)
+	}
+}
+
+func TestRunImportSign(t *testing.T) {
+	ks := &memoryKeyStore{keys: map[string]*rsa.PrivateKey{}}
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Error generating the key: %v", err)
+	}
+
+	if _, err = runKeyManager(ks, x509.MarshalPKCS1PrivateKey(key), "import", "-k", "signing-key"); err != nil {
+		t.Fatalf("Error importing the key: %v", err)
+	}
+
+	out, err := runKeyManager(ks, nil, "key-names")
+	if err != nil {
+		t.Fatalf("Error listing the keys: %v", err)
+	}
+	if strings.TrimSpace(string(out)) != `{"key-names":["signing-key"]}` {
+		t.Errorf("Unexpected key names: %s", out)
+	}
+
+	out, err = runKeyManager(ks, nil, "get-public-key", "-f", "DER", "-k", "signing-key")
+	if err != nil {
+		t.Fatalf("Error getting the public key: %v", err)
+	}
+	public, err := x509.ParsePKIXPublicKey(out)
+	if err != nil {
+		t.Fatalf("Error parsing the public key: %v", err)
+	}
+	if public.(*rsa.PublicKey).N.Cmp(key.N) != 0 {
+		t.Error("The public key does not match the imported key")
+	}
+
+	// Sign the DigestInfo, as the external keypair manager does
+	digest := sha512.Sum512([]byte("message"))
+	digestInfo := append([]byte{0x30, 0x51, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x03, 0x05, 0x00, 0x04, 0x40}, digest[:]...)
+	signature, err := runKeyManager(ks, digestInfo, "sign", "-m", "RSA-PKCS", "-k", "signing-key")
+	if err != nil {
+		t.Fatalf("Error signing: %v", err)
+	}
+	if err = rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA512, digest[:], signature); err != nil {
+		t.Errorf("Invalid signature: %v", err)
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	ks := &memoryKeyStore{keys: map[string]*rsa.PrivateKey{}}
+
+	tests := []struct {
+		args  []string
+		stdin []byte
+	}{
+		{[]string{}, nil},
+		{[]string{"generate", "-k", "signing-key"}, nil},
+		{[]string{"features", "-x"}, nil},
+		{[]string{"get-public-key", "-f", "PEM", "-k", "signing-key"}, nil},
+		{[]string{"get-public-key", "-f", "DER", "-k", "unknown"}, nil},
+		{[]string{"sign", "-m", "RSA-PSS", "-k", "signing-key"}, []byte("data")},
+		{[]string{"sign", "-m", "RSA-PKCS", "-k", "unknown"}, []byte("data")},
+		{[]string{"import"}, []byte("key")},
+		{[]string{"import", "-k", "signing-key"}, []byte("invalid key")},
+	}
+
+	for _, tt := range tests {
+		if _, err := runKeyManager(ks, tt.stdin, tt.args...); err == nil {
+			t.Errorf("Expected an error for: %v", tt.args)
+		}
+	}
+}
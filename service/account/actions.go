@@ -72,7 +72,7 @@ func listHandler(w http.ResponseWriter, user datastore.User, apiCall bool) {
 	formatListResponse(accounts, w)
 }
 
-func createHandler(w http.ResponseWriter, user datastore.User, apiCall bool, acct datastore.Account) {
+func createHandler(w http.ResponseWriter, user datastore.User, apiCall bool, sourceIP string, acct datastore.Account) {
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 
 	err := auth.CheckUserPermissions(user, datastore.Superuser, apiCall)
@@ -86,6 +86,10 @@ func createHandler(w http.ResponseWriter, user datastore.User, apiCall bool, acc
 		response.FormatStandardResponse(false, "error-creating-account", "", "Error creating the account in the database", w)
 		return
 	}
+	if a, err := datastore.Environ.DB.GetAccount(acct.AuthorityID); err == nil {
+		acct = a
+	}
+	datastore.RecordAudit(user, sourceIP, datastore.AuditActionCreate, datastore.AuditEntityAccount, acct.ID, nil, acct)
 
 	// Return successful JSON response
 	w.WriteHeader(http.StatusOK)
@@ -113,7 +117,7 @@ func getHandler(w http.ResponseWriter, user datastore.User, apiCall bool, accoun
 	formatGetResponse(account, w)
 }
 
-func updateHandler(w http.ResponseWriter, user datastore.User, apiCall bool, acct datastore.Account) {
+func updateHandler(w http.ResponseWriter, user datastore.User, apiCall bool, sourceIP string, acct datastore.Account) {
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 
 	err := auth.CheckUserPermissions(user, datastore.Superuser, apiCall)
@@ -122,6 +126,12 @@ func updateHandler(w http.ResponseWriter, user datastore.User, apiCall bool, acc
 		return
 	}
 
+	before, err := datastore.Environ.DB.GetAccountByID(acct.ID, user)
+	if err != nil {
+		response.FormatStandardResponse(false, "error-account", "", err.Error(), w)
+		return
+	}
+
 	err = datastore.Environ.DB.UpdateAccount(acct, user)
 	if err != nil {
 		log.Println("Error updating the account:", err)
@@ -129,12 +139,17 @@ func updateHandler(w http.ResponseWriter, user datastore.User, apiCall bool, acc
 		return
 	}
 
+	if a, err := datastore.Environ.DB.GetAccountByID(acct.ID, user); err == nil {
+		acct = a
+	}
+	datastore.RecordAudit(user, sourceIP, datastore.AuditActionUpdate, datastore.AuditEntityAccount, acct.ID, before, acct)
+
 	// Return successful JSON response
 	w.WriteHeader(http.StatusOK)
 	response.FormatStandardResponse(true, "", "", "", w)
 }
 
-func uploadHandler(w http.ResponseWriter, user datastore.User, apiCall bool, assertionRequest AssertionRequest) {
+func uploadHandler(w http.ResponseWriter, user datastore.User, apiCall bool, sourceIP string, assertionRequest AssertionRequest) {
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 
 	err := auth.CheckUserPermissions(user, datastore.Superuser, apiCall)
@@ -169,12 +184,24 @@ func uploadHandler(w http.ResponseWriter, user datastore.User, apiCall bool, ass
 		Assertion:   string(decodedAssertion),
 	}
 
+	// The upload creates the account when it does not exist
+	var before interface{}
+	action := datastore.AuditActionCreate
+	if a, err := datastore.Environ.DB.GetAccount(account.AuthorityID); err == nil {
+		action, before = datastore.AuditActionUpdate, a
+	}
+
 	errorCode, err := datastore.Environ.DB.PutAccount(account, user)
 	if err != nil {
 		response.FormatStandardResponse(false, errorCode, "", err.Error(), w)
 		return
 	}
 
+	if a, err := datastore.Environ.DB.GetAccount(account.AuthorityID); err == nil {
+		account = a
+	}
+	datastore.RecordAudit(user, sourceIP, action, datastore.AuditEntityAccount, account.ID, before, account)
+
 	// Return successful JSON response
 	w.WriteHeader(http.StatusOK)
 	response.FormatStandardResponse(true, "", "", "", w)
@@ -28,6 +28,7 @@ import (
 
 	"github.com/CanonicalLtd/serial-vault/datastore"
 	"github.com/CanonicalLtd/serial-vault/service/auth"
+	"github.com/CanonicalLtd/serial-vault/service/request"
 	"github.com/CanonicalLtd/serial-vault/service/response"
 	"github.com/gorilla/mux"
 )
@@ -73,7 +74,7 @@ func Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	createHandler(w, authUser, false, acct)
+	createHandler(w, authUser, false, request.SourceIP(r), acct)
 }
 
 // Get is the API method to fetch an account
@@ -120,7 +121,7 @@ func Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	updateHandler(w, authUser, false, acct)
+	updateHandler(w, authUser, false, request.SourceIP(r), acct)
 }
 
 // Upload is the API method to upload an account assertion
@@ -147,5 +148,5 @@ func Upload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	uploadHandler(w, authUser, false, assertionRequest)
+	uploadHandler(w, authUser, false, request.SourceIP(r), assertionRequest)
 }
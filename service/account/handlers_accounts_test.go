@@ -192,6 +192,14 @@ func (s *AccountSuite) TestCreateGetUpdateAccountHandlers(c *check.C) {
 
 		datastore.Environ.Config.EnableUserAuth = false
 	}
+
+	// the account that was created and then updated is in the audit log
+	logs := datastore.Environ.DB.(*datastore.MockDB).AuditLogs
+	c.Assert(logs, check.HasLen, 2)
+	c.Assert(logs[0].Action, check.Equals, datastore.AuditActionCreate)
+	c.Assert(logs[1].Action, check.Equals, datastore.AuditActionUpdate)
+	c.Assert(logs[1].Entity, check.Equals, datastore.AuditEntityAccount)
+	c.Assert(logs[1].EntityID, check.Equals, account.ID)
 }
 
 func (s *AccountSuite) TestAccountsHandlerError(c *check.C) {
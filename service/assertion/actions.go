@@ -61,6 +61,10 @@ func modelAssertionHandler(w http.ResponseWriter, apiKey string, request ModelAs
 
 	// Sign the assertion with the snapd assertions module
 	signedAssertion, err := datastore.Environ.KeypairDB.SignAssertion(asserts.ModelType, assertionHeaders, []byte(""), model.BrandID, keypair.KeyID, keypair.SealedKey)
+	if policyErr, ok := err.(*datastore.KeypairPolicyError); ok {
+		log.Message("MODEL", "keypair-policy", policyErr.Message)
+		return response.PolicyError(policyErr.Reason, policyErr.Message)
+	}
 	if err != nil {
 		log.Message("MODEL", response.ErrorSignAssertion.Code, err.Error())
 		return response.ErrorResponse{Success: false, Code: response.ErrorSignAssertion.Code, Message: err.Error(), StatusCode: http.StatusBadRequest}
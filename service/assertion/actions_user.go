@@ -89,6 +89,11 @@ func GenerateSystemUserAssertion(user SystemUserRequest, model datastore.Model)
 
 	// Sign the system-user assertion using the system-user key
 	signedAssertion, err := datastore.Environ.KeypairDB.SignAssertion(asserts.SystemUserType, assertionHeaders, nil, model.AuthorityIDUser, model.KeyIDUser, model.SealedKeyUser)
+	if policyErr, ok := err.(*datastore.KeypairPolicyError); ok {
+		errResponse := response.PolicyError(policyErr.Reason, policyErr.Message)
+		log.Message("USER", errResponse.Code, policyErr.Message)
+		return SystemUserResponse{ErrorCode: errResponse.Code, ErrorSubcode: errResponse.SubCode, ErrorMessage: errResponse.Message}
+	}
 	if err != nil {
 		log.Message("USER", response.ErrorSignAssertion.Code, err.Error())
 		return SystemUserResponse{ErrorCode: response.ErrorSignAssertion.Code, ErrorMessage: err.Error()}
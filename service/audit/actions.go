@@ -0,0 +1,107 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/CanonicalLtd/serial-vault/service/log"
+
+	"github.com/CanonicalLtd/serial-vault/datastore"
+	"github.com/CanonicalLtd/serial-vault/service/auth"
+	"github.com/CanonicalLtd/serial-vault/service/response"
+)
+
+// ListResponse is the JSON response from the API audit log method
+type ListResponse struct {
+	Success      bool                 `json:"success"`
+	ErrorCode    string               `json:"error_code"`
+	ErrorSubcode string               `json:"error_subcode"`
+	ErrorMessage string               `json:"message"`
+	Logs         []datastore.AuditLog `json:"logs"`
+	Total        int                  `json:"total"`
+}
+
+// listHandler is the API method to fetch a page of the audit log
+func listHandler(w http.ResponseWriter, user datastore.User, apiCall bool, params *datastore.AuditLogParams) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	err := auth.CheckUserPermissions(user, datastore.Superuser, apiCall)
+	if err != nil {
+		response.FormatStandardResponse(false, response.ErrorAuth.Code, "", err.Error(), w)
+		return
+	}
+
+	page, err := datastore.Environ.DB.ListAuditLog(params)
+	if err != nil {
+		log.Println(err)
+		response.FormatStandardResponse(false, response.ErrorFetchAuditLog.Code, "", err.Error(), w)
+		return
+	}
+
+	// Return successful JSON response with the page of the audit log
+	w.WriteHeader(http.StatusOK)
+	formatListResponse(page, w)
+}
+
+// exportHandler is the API method to stream the audit log in a file format. The page
+// of the params is not used, so every matching record is exported
+func exportHandler(w http.ResponseWriter, user datastore.User, apiCall bool, format string, params *datastore.AuditLogParams) {
+	err := auth.CheckUserPermissions(user, datastore.Superuser, apiCall)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		response.FormatStandardResponse(false, response.ErrorAuth.Code, "", err.Error(), w)
+		return
+	}
+
+	if err := datastore.ValidExportFormat(format); err != nil {
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		response.FormatStandardResponse(false, response.ErrorAuditLogParams.Code, "", err.Error(), w)
+		return
+	}
+
+	contentType := "text/csv; charset=UTF-8"
+	if format == datastore.ExportFormatJSONL {
+		contentType = "application/x-ndjson"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"audit.%s\"", format))
+	w.WriteHeader(http.StatusOK)
+
+	// The response has started, so an error can only be logged and the export is cut short
+	count, err := datastore.ExportAuditLog(w, format, params)
+	if err != nil {
+		log.Printf("Error exporting the audit log after %d records: %v", count, err)
+	}
+}
+
+func formatListResponse(page datastore.AuditLogPage, w http.ResponseWriter) error {
+	response := ListResponse{Success: true, Logs: page.Logs, Total: page.Total}
+
+	// Encode the response as JSON
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Error forming the audit log response (%v).\n %v", response, err)
+		return err
+	}
+	return nil
+}
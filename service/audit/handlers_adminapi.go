@@ -0,0 +1,114 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package audit
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/CanonicalLtd/serial-vault/datastore"
+	"github.com/CanonicalLtd/serial-vault/service/request"
+	"github.com/CanonicalLtd/serial-vault/service/response"
+)
+
+// APIList is the API method to fetch a page of the audit log
+func APIList(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	// Validate the user and API key
+	user, err := request.CheckUserAPI(r)
+	if err != nil {
+		response.FormatStandardResponse(false, response.ErrorAuth.Code, "", err.Error(), w)
+		return
+	}
+
+	params, err := GetAuditLogParams(r)
+	if err != nil {
+		response.FormatStandardResponse(false, response.ErrorAuditLogParams.Code, "", err.Error(), w)
+		return
+	}
+
+	// Call the API with the user
+	listHandler(w, user, true, params)
+}
+
+// APIExport is the API method to stream the audit log as CSV or JSON Lines
+func APIExport(w http.ResponseWriter, r *http.Request) {
+	// Validate the user and API key
+	user, err := request.CheckUserAPI(r)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		response.FormatStandardResponse(false, response.ErrorAuth.Code, "", err.Error(), w)
+		return
+	}
+
+	params, err := GetAuditLogParams(r)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		response.FormatStandardResponse(false, response.ErrorAuditLogParams.Code, "", err.Error(), w)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = datastore.ExportFormatCSV
+	}
+
+	// Call the API with the user
+	exportHandler(w, user, true, format, params)
+}
+
+// GetAuditLogParams parses the search of the audit log from the query parameters
+func GetAuditLogParams(r *http.Request) (*datastore.AuditLogParams, error) {
+	params := &datastore.AuditLogParams{
+		Limit: datastore.ListAuditLogDefaultLimit,
+	}
+	query := r.URL.Query()
+
+	if offset, err := strconv.ParseUint(query.Get("offset"), 10, 64); err == nil {
+		params.Offset = offset
+	}
+
+	if limit, err := strconv.ParseUint(query.Get("limit"), 10, 64); err == nil && limit > 0 {
+		params.Limit = limit
+	}
+
+	params.Actor = query.Get("actor")
+	params.Action = query.Get("action")
+	params.Entity = query.Get("entity")
+
+	var err error
+	if entityID := query.Get("entityid"); entityID != "" {
+		if params.EntityID, err = strconv.Atoi(entityID); err != nil {
+			return nil, fmt.Errorf("invalid entity ID: %s", entityID)
+		}
+	}
+
+	if params.From, err = datastore.ParseDate(query.Get("from")); err != nil {
+		return nil, err
+	}
+	if params.To, err = datastore.ParseDate(query.Get("to")); err != nil {
+		return nil, err
+	}
+
+	return params, nil
+}
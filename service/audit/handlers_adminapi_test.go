@@ -0,0 +1,158 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package audit_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/CanonicalLtd/serial-vault/config"
+	"github.com/CanonicalLtd/serial-vault/datastore"
+	"github.com/CanonicalLtd/serial-vault/service"
+	"github.com/CanonicalLtd/serial-vault/service/audit"
+	check "gopkg.in/check.v1"
+)
+
+func TestAuditSuite(t *testing.T) { check.TestingT(t) }
+
+type AuditSuite struct{}
+
+type AuditTest struct {
+	URL         string
+	Code        int
+	Permissions int
+	EnableAuth  bool
+	Success     bool
+	ErrorCode   string
+	List        int
+}
+
+var _ = check.Suite(&AuditSuite{})
+
+func (s *AuditSuite) SetUpTest(c *check.C) {
+	// Mock the database
+	config := config.Settings{KeyStoreType: "filesystem", KeyStorePath: "../keystore", JwtSecret: "SomeTestSecretValue"}
+	datastore.Environ = &datastore.Env{DB: &datastore.MockDB{}, Config: config}
+	datastore.OpenKeyStore(config)
+
+	// Disable CSRF for tests as we do not have a secure connection
+	service.MiddlewareWithCSRF = service.Middleware
+}
+
+func (s *AuditSuite) TestAPIListHandler(c *check.C) {
+	tests := []AuditTest{
+		{"/api/audit", 400, 0, false, false, "error-auth", 0},
+		{"/api/audit", 200, datastore.Superuser, true, true, "", 2},
+		{"/api/audit?entity=keypair", 200, datastore.Superuser, true, true, "", 1},
+		{"/api/audit?entityid=abc", 400, datastore.Superuser, true, false, "audit-log-params", 0},
+		{"/api/audit?from=yesterday", 400, datastore.Superuser, true, false, "audit-log-params", 0},
+		{"/api/audit", 400, datastore.Admin, true, false, "error-auth", 0},
+		{"/api/audit", 400, datastore.Standard, true, false, "error-auth", 0},
+	}
+
+	for _, t := range tests {
+		datastore.Environ.Config.EnableUserAuth = t.EnableAuth
+
+		w := sendAdminAPIRequest("GET", t.URL, nil, t.Permissions, c)
+		c.Assert(w.Code, check.Equals, t.Code, check.Commentf(t.URL))
+		c.Assert(w.Header().Get("Content-Type"), check.Equals, "application/json; charset=UTF-8")
+
+		result, err := parseListResponse(w)
+		c.Assert(err, check.IsNil)
+		c.Assert(result.Success, check.Equals, t.Success)
+		c.Assert(result.ErrorCode, check.Equals, t.ErrorCode)
+		c.Assert(len(result.Logs), check.Equals, t.List)
+
+		datastore.Environ.Config.EnableUserAuth = false
+	}
+}
+
+func (s *AuditSuite) TestAPIExportHandler(c *check.C) {
+	tests := []struct {
+		url         string
+		permissions int
+		code        int
+		contentType string
+		lines       int
+	}{
+		{"/api/audit/export", datastore.Superuser, 200, "text/csv; charset=UTF-8", 3},
+		{"/api/audit/export?format=jsonl&entity=model", datastore.Superuser, 200, "application/x-ndjson", 1},
+		{"/api/audit/export?format=xml", datastore.Superuser, 400, "application/json; charset=UTF-8", 1},
+		{"/api/audit/export?to=tomorrow", datastore.Superuser, 400, "application/json; charset=UTF-8", 1},
+		{"/api/audit/export", datastore.Admin, 400, "application/json; charset=UTF-8", 1},
+		{"/api/audit/export", 0, 400, "application/json; charset=UTF-8", 1},
+	}
+
+	datastore.Environ.Config.EnableUserAuth = true
+	defer func() { datastore.Environ.Config.EnableUserAuth = false }()
+
+	for _, t := range tests {
+		w := sendAdminAPIRequest("GET", t.url, nil, t.permissions, c)
+		c.Assert(w.Code, check.Equals, t.code, check.Commentf(t.url))
+		c.Assert(w.Header().Get("Content-Type"), check.Equals, t.contentType)
+		c.Assert(strings.Count(w.Body.String(), "\n"), check.Equals, t.lines)
+	}
+}
+
+func (s *AuditSuite) TestErrorAPIHandler(c *check.C) {
+	datastore.Environ.DB = &datastore.ErrorMockDB{}
+
+	w := sendAdminAPIRequest("GET", "/api/audit", nil, datastore.Superuser, c)
+	c.Assert(w.Code, check.Equals, 400)
+
+	result, err := parseListResponse(w)
+	c.Assert(err, check.IsNil)
+	c.Assert(result.Success, check.Equals, false)
+}
+
+func sendAdminAPIRequest(method, url string, data io.Reader, permissions int, c *check.C) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest(method, url, data)
+
+	switch permissions {
+	case datastore.Superuser:
+		r.Header.Set("user", "root")
+		r.Header.Set("api-key", "ValidAPIKey")
+	case datastore.Admin:
+		r.Header.Set("user", "sv")
+		r.Header.Set("api-key", "ValidAPIKey")
+	case datastore.Standard:
+		r.Header.Set("user", "user1")
+		r.Header.Set("api-key", "ValidAPIKey")
+	default:
+		break
+	}
+
+	service.AdminRouter().ServeHTTP(w, r)
+
+	return w
+}
+
+func parseListResponse(w *httptest.ResponseRecorder) (audit.ListResponse, error) {
+	// Check the JSON response
+	result := audit.ListResponse{}
+	err := json.NewDecoder(w.Body).Decode(&result)
+	return result, err
+}
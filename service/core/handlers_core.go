@@ -23,6 +23,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"sync"
+	"time"
 
 	"github.com/CanonicalLtd/serial-vault/datastore"
 	"github.com/CanonicalLtd/serial-vault/service/auth"
@@ -39,7 +41,9 @@ type VersionResponse struct {
 
 // HealthResponse is the JSON response from the health check method
 type HealthResponse struct {
-	Database string `json:"database"`
+	Database         string `json:"database"`
+	Keypairs         string `json:"keypairs"`
+	ExpiringKeypairs int    `json:"expiring-keypairs,omitempty"`
 }
 
 // TokenResponse is the JSON response from the API Version method
@@ -63,7 +67,10 @@ func Version(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// Health is the API method to return if the app is up and db.Ping() doesn't return an error
+// Health is the API method to return if the app is up and db.Ping() doesn't return an error.
+// The number of active signing-keys that expire within the days of the settings is also
+// reported, but they do not make the service unhealthy. The keys are listed by the
+// authenticated /keypairs/expiring method
 func Health(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", response.JSONHeader)
 	err := datastore.Environ.DB.HealthCheck()
@@ -76,12 +83,58 @@ func Health(w http.ResponseWriter, r *http.Request) {
 		database = "healthy"
 	}
 	response := HealthResponse{Database: database}
+	if err == nil {
+		response.Keypairs, response.ExpiringKeypairs = keypairsHealth()
+	}
 	if err := json.NewEncoder(w).Encode(response); err != nil {
 		message := fmt.Sprintf("Error ecoding the health response: %v", err)
 		log.Message("HEALTH", "health", message)
 	}
 }
 
+// keypairsHealthTTL is how long the keypairs health is cached, as the health method is
+// public and polled often
+const keypairsHealthTTL = time.Minute
+
+var keypairsCache struct {
+	mu      sync.Mutex
+	db      datastore.Datastore
+	expires time.Time
+	status  string
+	count   int
+}
+
+// keypairsHealth returns the status and the number of the expiring keypairs, from the
+// cache when it was read from the same database in the last minute
+func keypairsHealth() (string, int) {
+	keypairsCache.mu.Lock()
+	defer keypairsCache.mu.Unlock()
+
+	now := time.Now()
+	if keypairsCache.db == datastore.Environ.DB && now.Before(keypairsCache.expires) {
+		return keypairsCache.status, keypairsCache.count
+	}
+
+	// Authentication is not checked for the invalid role, so this lists all the keypairs
+	keypairs, err := datastore.Environ.DB.ListAllowedKeypairs(datastore.User{})
+	if err != nil {
+		log.Message("HEALTH", "health", fmt.Sprintf("Error listing the keypairs: %v", err))
+		return "error", 0
+	}
+
+	status := "healthy"
+	count := len(datastore.ExpiringKeypairs(keypairs, datastore.KeyExpiryWarningDays()))
+	if count > 0 {
+		status = "expiring"
+	}
+
+	keypairsCache.db = datastore.Environ.DB
+	keypairsCache.expires = now.Add(keypairsHealthTTL)
+	keypairsCache.status = status
+	keypairsCache.count = count
+	return status, count
+}
+
 // Token returns CSRF protection new token in a X-CSRF-Token response header
 // This method is also used by the /authtoken endpoint to return the JWT. The method
 // indicates to the UI whether OpenID user auth is enabled
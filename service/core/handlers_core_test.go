@@ -26,6 +26,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/CanonicalLtd/serial-vault/config"
 	"github.com/CanonicalLtd/serial-vault/datastore"
@@ -134,8 +135,48 @@ func (s *CoreSuite) TestHealthHandler(c *check.C) {
 		c.Assert(err, check.IsNil)
 		if t.Success {
 			c.Assert(result.Database, check.Equals, t.Result)
+			c.Assert(result.Keypairs, check.Equals, "healthy")
 		}
 
 		datastore.Environ.DB = &datastore.MockDB{}
 	}
 }
+
+type expiringMockDB struct {
+	datastore.MockDB
+	listCalls int
+}
+
+// ListAllowedKeypairs returns a keypair that expires in ten days
+func (mdb *expiringMockDB) ListAllowedKeypairs(authorization datastore.User) ([]datastore.Keypair, error) {
+	mdb.listCalls++
+	notAfter := time.Now().AddDate(0, 0, 10)
+	keypairs := []datastore.Keypair{
+		{ID: 1, AuthorityID: "system", KeyID: "61abf588e52be7a3", KeyName: "system", Active: true, NotAfter: &notAfter},
+	}
+	return keypairs, nil
+}
+
+func (s *CoreSuite) TestHealthHandlerExpiringKeypairs(c *check.C) {
+	mdb := &expiringMockDB{}
+	datastore.Environ.DB = mdb
+	defer func() { datastore.Environ.DB = &datastore.MockDB{} }()
+
+	for i := 0; i < 2; i++ {
+		w := sendRequest("GET", "/v1/health", nil, c)
+		c.Assert(w.Code, check.Equals, 200)
+
+		// The keypairs are counted, but not named
+		c.Assert(w.Body.String(), check.Not(check.Matches), "(?s).*system.*")
+
+		result := core.HealthResponse{}
+		err := json.NewDecoder(w.Body).Decode(&result)
+		c.Assert(err, check.IsNil)
+		c.Assert(result.Database, check.Equals, "healthy")
+		c.Assert(result.Keypairs, check.Equals, "expiring")
+		c.Assert(result.ExpiringKeypairs, check.Equals, 1)
+	}
+
+	// The second request is answered from the cache
+	c.Assert(mdb.listCalls, check.Equals, 1)
+}
@@ -26,11 +26,13 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/CanonicalLtd/serial-vault/service/log"
 
 	"github.com/CanonicalLtd/serial-vault/datastore"
 	"github.com/CanonicalLtd/serial-vault/service/auth"
+	"github.com/CanonicalLtd/serial-vault/service/operation"
 	"github.com/CanonicalLtd/serial-vault/service/response"
 	"github.com/snapcore/snapd/asserts"
 )
@@ -42,6 +44,7 @@ type ListResponse struct {
 	ErrorSubcode string              `json:"error_subcode"`
 	ErrorMessage string              `json:"message"`
 	Keypairs     []datastore.Keypair `json:"keypairs"`
+	Warnings     []string            `json:"warnings"`
 }
 
 // GetResponse is the JSON response from the API get Keypair method
@@ -83,6 +86,28 @@ func listHandler(w http.ResponseWriter, user datastore.User, apiCall bool) {
 	formatListResponse(true, "", "", "", keypairs, w)
 }
 
+// expiringHandler is the API method to list the active signing keys that have expired,
+// or that expire within the days
+func expiringHandler(w http.ResponseWriter, user datastore.User, apiCall bool, days int) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	err := auth.CheckUserPermissions(user, datastore.Admin, apiCall)
+	if err != nil {
+		response.FormatStandardResponse(false, response.ErrorAuth.Code, "", "", w)
+		return
+	}
+
+	keypairs, err := datastore.Environ.DB.ListAllowedKeypairs(user)
+	if err != nil {
+		response.FormatStandardResponse(false, response.ErrorFetchKeypairs.Code, "", err.Error(), w)
+		return
+	}
+
+	// Return successful JSON response with the list of expiring keypairs
+	w.WriteHeader(http.StatusOK)
+	formatListResponse(true, "", "", "", datastore.ExpiringKeypairs(keypairs, days), w)
+}
+
 // getHandler is the API method to fetch a signing key
 func getHandler(w http.ResponseWriter, user datastore.User, apiCall bool, keypairID int) {
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
@@ -105,7 +130,7 @@ func getHandler(w http.ResponseWriter, user datastore.User, apiCall bool, keypai
 }
 
 // updateHandler is the API method to update a signing key
-func updateHandler(w http.ResponseWriter, user datastore.User, apiCall bool, keypair datastore.Keypair) {
+func updateHandler(w http.ResponseWriter, user datastore.User, apiCall bool, sourceIP string, keypair datastore.Keypair) {
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 
 	err := auth.CheckUserPermissions(user, datastore.Admin, apiCall)
@@ -121,6 +146,7 @@ func updateHandler(w http.ResponseWriter, user datastore.User, apiCall bool, key
 	}
 
 	// Update the key name
+	before := k
 	k.KeyName = keypair.KeyName
 
 	errorCode, err := datastore.Environ.DB.PutKeypair(k)
@@ -128,6 +154,7 @@ func updateHandler(w http.ResponseWriter, user datastore.User, apiCall bool, key
 		response.FormatStandardResponse(false, errorCode, "", err.Error(), w)
 		return
 	}
+	datastore.RecordAudit(user, sourceIP, datastore.AuditActionUpdate, datastore.AuditEntityKeypair, k.ID, before, k)
 
 	// Return successful JSON response
 	w.WriteHeader(http.StatusOK)
@@ -135,7 +162,7 @@ func updateHandler(w http.ResponseWriter, user datastore.User, apiCall bool, key
 }
 
 // createHandler is the API method to create a signing key
-func createHandler(w http.ResponseWriter, user datastore.User, apiCall bool, keypairWithKey WithPrivateKey) {
+func createHandler(w http.ResponseWriter, user datastore.User, apiCall bool, sourceIP string, keypairWithKey WithPrivateKey) {
 	err := auth.CheckUserPermissions(user, datastore.Admin, apiCall)
 	if err != nil {
 		response.FormatStandardResponse(false, response.ErrorAuth.Code, "", err.Error(), w)
@@ -147,6 +174,18 @@ func createHandler(w http.ResponseWriter, user datastore.User, apiCall bool, key
 		return
 	}
 
+	// Only the sealed signing-key is kept until a second Superuser approves the import, so
+	// nothing is added to the keypair store before then
+	if datastore.DualControl() {
+		op, err := datastore.ImportOperation(keypairWithKey.AuthorityID, keypairWithKey.KeyName, keypairWithKey.PrivateKey)
+		if err != nil {
+			response.FormatStandardResponse(false, response.ErrorRequestOperation.Code, "", err.Error(), w)
+			return
+		}
+		operation.RequestApproval(w, user, op)
+		return
+	}
+
 	// Store the signing-key in the keypair store using the asserts module
 	privateKey, sealedPrivateKey, err := datastore.Environ.KeypairDB.ImportSigningKey(keypairWithKey.AuthorityID, keypairWithKey.PrivateKey)
 	if err != nil {
@@ -161,11 +200,16 @@ func createHandler(w http.ResponseWriter, user datastore.User, apiCall bool, key
 		SealedKey:   sealedPrivateKey,
 		KeyName:     keypairWithKey.KeyName,
 	}
+
 	errorCode, err := datastore.Environ.DB.PutKeypair(keypair)
 	if err != nil {
 		response.FormatStandardResponse(false, errorCode, "", err.Error(), w)
 		return
 	}
+	if k, err := datastore.Environ.DB.GetKeypairByPublicID(keypair.AuthorityID, keypair.KeyID); err == nil {
+		keypair = k
+	}
+	datastore.RecordAudit(user, sourceIP, datastore.AuditActionCreate, datastore.AuditEntityKeypair, keypair.ID, nil, keypair)
 
 	// Return success response
 	w.WriteHeader(http.StatusOK)
@@ -173,7 +217,7 @@ func createHandler(w http.ResponseWriter, user datastore.User, apiCall bool, key
 }
 
 // generateHandler is the API method to generate a signing key
-func generateHandler(w http.ResponseWriter, user datastore.User, apiCall bool, keypairWithKey WithPrivateKey) {
+func generateHandler(w http.ResponseWriter, user datastore.User, apiCall bool, sourceIP string, keypairWithKey WithPrivateKey) {
 	err := auth.CheckUserPermissions(user, datastore.Admin, apiCall)
 	if err != nil {
 		response.FormatStandardResponse(false, response.ErrorAuth.Code, "", err.Error(), w)
@@ -185,7 +229,18 @@ func generateHandler(w http.ResponseWriter, user datastore.User, apiCall bool, k
 		return
 	}
 
-	go datastore.GenerateKeypair(keypairWithKey.AuthorityID, "", keypairWithKey.KeyName)
+	if datastore.DualControl() {
+		operation.RequestApproval(w, user, datastore.PendingOperation{
+			Operation:   datastore.OperationGenerate,
+			AuthorityID: keypairWithKey.AuthorityID,
+			KeyName:     keypairWithKey.KeyName,
+		})
+		return
+	}
+
+	go datastore.GenerateKeypair(keypairWithKey.AuthorityID, keypairWithKey.KeyName)
+	datastore.RecordAudit(user, sourceIP, datastore.AuditActionGenerate, datastore.AuditEntityKeypair, 0, nil,
+		datastore.Keypair{AuthorityID: keypairWithKey.AuthorityID, KeyName: keypairWithKey.KeyName})
 
 	// Return the URL to watch for the response
 	statusURL := fmt.Sprintf("/v1/keypairs/status/%s/%s", keypairWithKey.AuthorityID, keypairWithKey.KeyName)
@@ -195,13 +250,25 @@ func generateHandler(w http.ResponseWriter, user datastore.User, apiCall bool, k
 }
 
 // enableDisableHandler is the API method to enable/disable a signing key
-func enableDisableHandler(w http.ResponseWriter, user datastore.User, apiCall bool, enabled bool, keypairID int) {
+func enableDisableHandler(w http.ResponseWriter, user datastore.User, apiCall bool, sourceIP string, enabled bool, keypairID int) {
 	err := auth.CheckUserPermissions(user, datastore.Admin, apiCall)
 	if err != nil {
 		response.FormatStandardResponse(false, response.ErrorAuth.Code, "", err.Error(), w)
 		return
 	}
 
+	// Disabling a signing-key is not sensitive, so it does not need approval
+	if enabled && datastore.DualControl() {
+		requestKeypairApproval(w, user, datastore.OperationEnable, keypairID)
+		return
+	}
+
+	before, err := datastore.Environ.DB.GetKeypair(keypairID)
+	if err != nil {
+		response.FormatStandardResponse(false, response.ErrorFetchKeypair.Code, "", err.Error(), w)
+		return
+	}
+
 	// Update the keypair in the local database
 	err = datastore.Environ.DB.UpdateAllowedKeypairActive(keypairID, enabled, user)
 	if err != nil {
@@ -209,13 +276,94 @@ func enableDisableHandler(w http.ResponseWriter, user datastore.User, apiCall bo
 		return
 	}
 
+	action := datastore.AuditActionDisable
+	if enabled {
+		action = datastore.AuditActionEnable
+	}
+	after := before
+	after.Active = enabled
+	datastore.RecordAudit(user, sourceIP, action, datastore.AuditEntityKeypair, keypairID, before, after)
+
+	// Remove a disabled signing-key from memory straight away
+	if !enabled {
+		if err := datastore.EvictKeypair(keypairID); err != nil {
+			log.Printf("Error evicting the disabled signing-key: %v", err)
+		}
+	}
+
+	// Return success response
+	w.WriteHeader(http.StatusOK)
+	response.FormatStandardResponse(true, "", "", "", w)
+}
+
+// deleteHandler is the API method to delete a signing key that is not used by a model
+func deleteHandler(w http.ResponseWriter, user datastore.User, apiCall bool, sourceIP string, keypairID int) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	err := auth.CheckUserPermissions(user, datastore.Admin, apiCall)
+	if err != nil {
+		response.FormatStandardResponse(false, response.ErrorAuth.Code, "", err.Error(), w)
+		return
+	}
+
+	if datastore.DualControl() {
+		requestKeypairApproval(w, user, datastore.OperationDelete, keypairID)
+		return
+	}
+
+	k, err := datastore.Environ.DB.GetKeypair(keypairID)
+	if err != nil {
+		response.FormatStandardResponse(false, response.ErrorFetchKeypair.Code, "", err.Error(), w)
+		return
+	}
+
+	// Check that the user has permissions to this authority-id
+	if !datastore.Environ.DB.CheckUserInAccount(user.Username, k.AuthorityID) {
+		response.FormatStandardResponse(false, response.ErrorAuth.Code, "", "Your user does not have permissions for the Signing Authority", w)
+		return
+	}
+
+	// Remove the signing-key from memory before its record is removed
+	if err := datastore.EvictKeypair(keypairID); err != nil {
+		log.Printf("Error evicting the deleted signing-key: %v", err)
+	}
+
+	err = datastore.Environ.DB.DeleteKeypair(keypairID)
+	if err != nil {
+		response.FormatStandardResponse(false, response.ErrorDeleteKeypair.Code, "", err.Error(), w)
+		return
+	}
+	datastore.RecordAudit(user, sourceIP, datastore.AuditActionDelete, datastore.AuditEntityKeypair, keypairID, k, nil)
+
 	// Return success response
 	w.WriteHeader(http.StatusOK)
 	response.FormatStandardResponse(true, "", "", "", w)
 }
 
+// requestKeypairApproval requests the approval of an operation on an existing signing key
+func requestKeypairApproval(w http.ResponseWriter, user datastore.User, op string, keypairID int) {
+	k, err := datastore.Environ.DB.GetKeypair(keypairID)
+	if err != nil {
+		response.FormatStandardResponse(false, response.ErrorFetchKeypair.Code, "", err.Error(), w)
+		return
+	}
+
+	// Check that the user has permissions to this authority-id
+	if !datastore.Environ.DB.CheckUserInAccount(user.Username, k.AuthorityID) {
+		response.FormatStandardResponse(false, response.ErrorAuth.Code, "", "Your user does not have permissions for the Signing Authority", w)
+		return
+	}
+
+	operation.RequestApproval(w, user, datastore.PendingOperation{
+		Operation:   op,
+		AuthorityID: k.AuthorityID,
+		KeyName:     k.KeyName,
+		KeypairID:   k.ID,
+	})
+}
+
 // assertionHandler is the API method to update a key assertion
-func assertionHandler(w http.ResponseWriter, user datastore.User, apiCall bool, assertionRequest AssertionRequest) {
+func assertionHandler(w http.ResponseWriter, user datastore.User, apiCall bool, sourceIP string, assertionRequest AssertionRequest) {
 	err := auth.CheckUserPermissions(user, datastore.Admin, apiCall)
 	if err != nil {
 		response.FormatStandardResponse(false, response.ErrorAuth.Code, "", err.Error(), w)
@@ -255,12 +403,114 @@ func assertionHandler(w http.ResponseWriter, user datastore.User, apiCall bool,
 		Assertion:   string(decodedAssertion),
 	}
 
+	before, err := datastore.Environ.DB.GetKeypair(keypair.ID)
+	if err != nil {
+		response.FormatStandardResponse(false, response.ErrorFetchKeypair.Code, "", err.Error(), w)
+		return
+	}
+
 	errorCode, err := datastore.Environ.DB.UpdateKeypairAssertion(keypair, user)
 	if err != nil {
 		response.FormatStandardResponse(false, errorCode, "", err.Error(), w)
 		return
 	}
 
+	after := before
+	after.Assertion = keypair.Assertion
+	datastore.RecordAudit(user, sourceIP, datastore.AuditActionUpdate, datastore.AuditEntityKeypair, keypair.ID, before, after)
+
+	// Return success response
+	w.WriteHeader(http.StatusOK)
+	response.FormatStandardResponse(true, "", "", "", w)
+}
+
+// policyHandler is the API method to update the usage policy of a signing key
+func policyHandler(w http.ResponseWriter, user datastore.User, apiCall bool, sourceIP string, keypairID int, policy datastore.KeypairPolicy) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	err := auth.CheckUserPermissions(user, datastore.Admin, apiCall)
+	if err != nil {
+		response.FormatStandardResponse(false, response.ErrorAuth.Code, "", err.Error(), w)
+		return
+	}
+
+	k, err := datastore.Environ.DB.GetKeypair(keypairID)
+	if err != nil {
+		response.FormatStandardResponse(false, response.ErrorFetchKeypair.Code, "", err.Error(), w)
+		return
+	}
+
+	// Check that the user has permissions to this authority-id
+	if !datastore.Environ.DB.CheckUserInAccount(user.Username, k.AuthorityID) {
+		response.FormatStandardResponse(false, response.ErrorAuth.Code, "", "Your user does not have permissions for the Signing Authority", w)
+		return
+	}
+
+	policy = policy.Normalize()
+	if err := policy.Validate(); err != nil {
+		response.FormatStandardResponse(false, response.ErrorInvalidData.Code, "", err.Error(), w)
+		return
+	}
+
+	err = datastore.Environ.DB.UpdateKeypairPolicy(keypairID, policy)
+	if err != nil {
+		response.FormatStandardResponse(false, response.ErrorStoreKeypair.Code, "", err.Error(), w)
+		return
+	}
+
+	after := k
+	after.Policy = policy
+	datastore.RecordAudit(user, sourceIP, datastore.AuditActionUpdate, datastore.AuditEntityKeypair, keypairID, k, after)
+
+	// Return success response
+	w.WriteHeader(http.StatusOK)
+	response.FormatStandardResponse(true, "", "", "", w)
+}
+
+// validityHandler is the API method to set the dates that a signing key can be used between
+func validityHandler(w http.ResponseWriter, user datastore.User, apiCall bool, sourceIP string, keypair datastore.Keypair) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	err := auth.CheckUserPermissions(user, datastore.Admin, apiCall)
+	if err != nil {
+		response.FormatStandardResponse(false, response.ErrorAuth.Code, "", err.Error(), w)
+		return
+	}
+
+	k, err := datastore.Environ.DB.GetKeypair(keypair.ID)
+	if err != nil {
+		response.FormatStandardResponse(false, response.ErrorFetchKeypair.Code, "", err.Error(), w)
+		return
+	}
+
+	// Check that the user has permissions to this authority-id
+	if !datastore.Environ.DB.CheckUserInAccount(user.Username, k.AuthorityID) {
+		response.FormatStandardResponse(false, response.ErrorAuth.Code, "", "Your user does not have permissions for the Signing Authority", w)
+		return
+	}
+
+	before := k
+	k.NotBefore = keypair.NotBefore
+	k.NotAfter = keypair.NotAfter
+	if err := k.ValidateValidity(); err != nil {
+		response.FormatStandardResponse(false, response.ErrorInvalidData.Code, "", err.Error(), w)
+		return
+	}
+
+	err = datastore.Environ.DB.UpdateKeypairValidity(k)
+	if err != nil {
+		response.FormatStandardResponse(false, response.ErrorStoreKeypair.Code, "", err.Error(), w)
+		return
+	}
+	datastore.RecordAudit(user, sourceIP, datastore.AuditActionUpdate, datastore.AuditEntityKeypair, k.ID, before, k)
+
+	// Remove an expired signing-key from memory straight away
+	if k.ExpiresWithin(time.Now(), 0) {
+		if err := datastore.EvictKeypair(k.ID); err != nil {
+			log.Printf("Error evicting the expired signing-key: %v", err)
+		}
+	}
+
 	// Return success response
 	w.WriteHeader(http.StatusOK)
 	response.FormatStandardResponse(true, "", "", "", w)
@@ -313,7 +563,8 @@ func progressHandler(w http.ResponseWriter, user datastore.User, apiCall bool) {
 }
 
 func formatListResponse(success bool, errorCode, errorSubcode, message string, keypairs []datastore.Keypair, w http.ResponseWriter) error {
-	response := ListResponse{Success: success, ErrorCode: errorCode, ErrorSubcode: errorSubcode, ErrorMessage: message, Keypairs: keypairs}
+	response := ListResponse{Success: success, ErrorCode: errorCode, ErrorSubcode: errorSubcode, ErrorMessage: message, Keypairs: keypairs,
+		Warnings: expiryWarnings(keypairs)}
 
 	// Encode the response as JSON
 	if err := json.NewEncoder(w).Encode(response); err != nil {
@@ -323,6 +574,20 @@ func formatListResponse(success bool, errorCode, errorSubcode, message string, k
 	return nil
 }
 
+// expiryWarnings reports the active keypairs that have expired, or expire soon
+func expiryWarnings(keypairs []datastore.Keypair) []string {
+	warnings := []string{}
+	now := time.Now()
+	for _, k := range datastore.ExpiringKeypairs(keypairs, datastore.KeyExpiryWarningDays()) {
+		if k.NotAfter.After(now) {
+			warnings = append(warnings, fmt.Sprintf("The signing-key %s/%s expires at %s", k.AuthorityID, k.KeyName, k.NotAfter.Format(time.RFC3339)))
+		} else {
+			warnings = append(warnings, fmt.Sprintf("The signing-key %s/%s expired at %s", k.AuthorityID, k.KeyName, k.NotAfter.Format(time.RFC3339)))
+		}
+	}
+	return warnings
+}
+
 func formatGetResponse(keypair datastore.Keypair, w http.ResponseWriter) error {
 	response := GetResponse{Success: true, Keypair: keypair}
 
@@ -22,12 +22,15 @@ package keypair
 
 import (
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 
 	"github.com/CanonicalLtd/serial-vault/service/log"
 	"github.com/CanonicalLtd/serial-vault/service/request"
 	"github.com/CanonicalLtd/serial-vault/service/response"
+	"github.com/gorilla/mux"
 )
 
 // SyncRequest is the request to fetch keypairs
@@ -48,6 +51,106 @@ func APIList(w http.ResponseWriter, r *http.Request) {
 	listHandler(w, user, true)
 }
 
+// APIExpiring is the API method to list the active keypairs that expire soon
+func APIExpiring(w http.ResponseWriter, r *http.Request) {
+	// Validate the user and API key
+	user, err := request.CheckUserAPI(r)
+	if err != nil {
+		response.FormatStandardResponse(false, "error-auth", "", err.Error(), w)
+		return
+	}
+
+	// Call the API with the user
+	expiringHandler(w, user, true, expiryDays(r))
+}
+
+// APIPolicy updates the usage policy of a keypair
+func APIPolicy(w http.ResponseWriter, r *http.Request) {
+	// Validate the user and API key
+	user, err := request.CheckUserAPI(r)
+	if err != nil {
+		response.FormatStandardResponse(false, "error-auth", "", err.Error(), w)
+		return
+	}
+
+	keypairID, policy, ok := decodePolicy(w, r)
+	if !ok {
+		return
+	}
+
+	// Call the API with the user
+	policyHandler(w, user, true, request.SourceIP(r), keypairID, policy)
+}
+
+// APIValidity sets the not-before and not-after dates of a keypair
+func APIValidity(w http.ResponseWriter, r *http.Request) {
+	// Validate the user and API key
+	user, err := request.CheckUserAPI(r)
+	if err != nil {
+		response.FormatStandardResponse(false, "error-auth", "", err.Error(), w)
+		return
+	}
+
+	keypair, ok := decodeValidity(w, r)
+	if !ok {
+		return
+	}
+
+	// Call the API with the user
+	validityHandler(w, user, true, request.SourceIP(r), keypair)
+}
+
+// APIEnable enables a keypair, or requests the approval to enable it
+func APIEnable(w http.ResponseWriter, r *http.Request) {
+	apiEnableDisable(w, r, true)
+}
+
+// APIDisable disables a keypair
+func APIDisable(w http.ResponseWriter, r *http.Request) {
+	apiEnableDisable(w, r, false)
+}
+
+func apiEnableDisable(w http.ResponseWriter, r *http.Request, enabled bool) {
+	// Validate the user and API key
+	user, err := request.CheckUserAPI(r)
+	if err != nil {
+		response.FormatStandardResponse(false, "error-auth", "", err.Error(), w)
+		return
+	}
+
+	// Get the keypair primary key
+	vars := mux.Vars(r)
+	keypairID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		response.FormatStandardResponse(false, response.ErrorInvalidID.Code, "", fmt.Sprintf("%v", vars["id"]), w)
+		return
+	}
+
+	// Call the API with the user
+	enableDisableHandler(w, user, true, request.SourceIP(r), enabled, keypairID)
+}
+
+// APIDelete removes a keypair that is not used by any model, or requests the approval to remove it
+func APIDelete(w http.ResponseWriter, r *http.Request) {
+	// Validate the user and API key
+	user, err := request.CheckUserAPI(r)
+	if err != nil {
+		response.FormatStandardResponse(false, "error-auth", "", err.Error(), w)
+		return
+	}
+
+	// Get the keypair primary key
+	vars := mux.Vars(r)
+	keypairID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		response.FormatStandardResponse(false, response.ErrorInvalidID.Code, "", fmt.Sprintf("%v", vars["id"]), w)
+		return
+	}
+
+	// Call the API with the user
+	deleteHandler(w, user, true, request.SourceIP(r), keypairID)
+}
+
 // APISyncKeypairs fetches the signing-keys accessible by a user
 // A encryption secret is provided and the keypairs are decrypted and re-encrypted
 // using the supplied keystore secret
@@ -25,6 +25,7 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"time"
 
 	"github.com/CanonicalLtd/serial-vault/datastore"
 	"github.com/CanonicalLtd/serial-vault/service"
@@ -59,6 +60,114 @@ func (s *KeypairSuite) TestAPIKeypairsHandler(c *check.C) {
 	}
 }
 
+func (s *KeypairSuite) TestAPIExpiringHandler(c *check.C) {
+	tests := []KeypairTest{
+		{"GET", "/api/keypairs/expiring", nil, 400, "application/json; charset=UTF-8", 0, false, false, 0},
+		{"GET", "/api/keypairs/expiring?days=7", nil, 200, "application/json; charset=UTF-8", datastore.Admin, false, true, 0},
+		{"GET", "/api/keypairs/expiring", nil, 400, "application/json; charset=UTF-8", datastore.Standard, false, false, 0},
+	}
+
+	for _, t := range tests {
+		if t.EnableAuth {
+			datastore.Environ.Config.EnableUserAuth = true
+		}
+
+		w := sendAdminAPIRequest(t.Method, t.URL, bytes.NewReader(t.Data), t.Permissions, c)
+		c.Assert(w.Code, check.Equals, t.Code)
+		c.Assert(w.Header().Get("Content-Type"), check.Equals, t.Type)
+
+		result, err := parseListResponse(w)
+		c.Assert(err, check.IsNil)
+		c.Assert(result.Success, check.Equals, t.Success)
+		c.Assert(len(result.Keypairs), check.Equals, t.List)
+
+		datastore.Environ.Config.EnableUserAuth = false
+	}
+}
+
+func (s *KeypairSuite) TestAPIPolicyHandler(c *check.C) {
+	policy, _ := json.Marshal(datastore.KeypairPolicy{AssertionTypes: []string{"serial"}, Models: []string{"alder"}})
+
+	tests := []KeypairTest{
+		{"PUT", "/api/keypairs/1/policy", policy, 400, "application/json; charset=UTF-8", 0, false, false, 0},
+		{"PUT", "/api/keypairs/1/policy", policy, 200, "application/json; charset=UTF-8", datastore.Admin, false, true, 0},
+		{"PUT", "/api/keypairs/1/policy", policy, 400, "application/json; charset=UTF-8", datastore.Standard, false, false, 0},
+		{"PUT", "/api/keypairs/1/policy", nil, 400, "application/json; charset=UTF-8", datastore.Admin, false, false, 0},
+	}
+
+	for _, t := range tests {
+		w := sendAdminAPIRequest(t.Method, t.URL, bytes.NewReader(t.Data), t.Permissions, c)
+		c.Assert(w.Code, check.Equals, t.Code)
+		c.Assert(w.Header().Get("Content-Type"), check.Equals, t.Type)
+
+		result, err := parseListResponse(w)
+		c.Assert(err, check.IsNil)
+		c.Assert(result.Success, check.Equals, t.Success)
+	}
+}
+
+func (s *KeypairSuite) TestAPIValidityHandler(c *check.C) {
+	notAfter := time.Now().AddDate(1, 0, 0)
+	validity, _ := json.Marshal(datastore.Keypair{NotAfter: &notAfter})
+
+	tests := []KeypairTest{
+		{"PUT", "/api/keypairs/1/validity", validity, 400, "application/json; charset=UTF-8", 0, false, false, 0},
+		{"PUT", "/api/keypairs/1/validity", validity, 200, "application/json; charset=UTF-8", datastore.Admin, false, true, 0},
+		{"PUT", "/api/keypairs/1/validity", validity, 400, "application/json; charset=UTF-8", datastore.Standard, false, false, 0},
+		{"PUT", "/api/keypairs/1/validity", nil, 400, "application/json; charset=UTF-8", datastore.Admin, false, false, 0},
+	}
+
+	for _, t := range tests {
+		w := sendAdminAPIRequest(t.Method, t.URL, bytes.NewReader(t.Data), t.Permissions, c)
+		c.Assert(w.Code, check.Equals, t.Code)
+		c.Assert(w.Header().Get("Content-Type"), check.Equals, t.Type)
+
+		result, err := parseListResponse(w)
+		c.Assert(err, check.IsNil)
+		c.Assert(result.Success, check.Equals, t.Success)
+	}
+}
+
+func (s *KeypairSuite) TestAPIEnableDisableDeleteHandler(c *check.C) {
+	tests := []KeypairTest{
+		{"POST", "/api/keypairs/1/enable", nil, 400, "application/json; charset=UTF-8", 0, false, false, 0},
+		{"POST", "/api/keypairs/1/enable", nil, 200, "application/json; charset=UTF-8", datastore.Admin, false, true, 0},
+		{"POST", "/api/keypairs/1/enable", nil, 202, "application/json; charset=UTF-8", datastore.Admin, true, true, 0},
+		{"POST", "/api/keypairs/1/enable", nil, 400, "application/json; charset=UTF-8", datastore.Standard, false, false, 0},
+		{"POST", "/api/keypairs/1/disable", nil, 200, "application/json; charset=UTF-8", datastore.Admin, false, true, 0},
+		{"POST", "/api/keypairs/1/disable", nil, 200, "application/json; charset=UTF-8", datastore.Admin, true, true, 0},
+		{"POST", "/api/keypairs/1/disable", nil, 400, "application/json; charset=UTF-8", datastore.Standard, false, false, 0},
+		{"DELETE", "/api/keypairs/1", nil, 400, "application/json; charset=UTF-8", 0, false, false, 0},
+		{"DELETE", "/api/keypairs/1", nil, 200, "application/json; charset=UTF-8", datastore.Admin, false, true, 0},
+		{"DELETE", "/api/keypairs/1", nil, 202, "application/json; charset=UTF-8", datastore.Admin, true, true, 0},
+		{"DELETE", "/api/keypairs/1", nil, 400, "application/json; charset=UTF-8", datastore.Standard, false, false, 0},
+	}
+
+	// The dual control flag is in the EnableAuth field
+	for _, t := range tests {
+		datastore.Environ.Config.DualControl = t.EnableAuth
+
+		w := sendAdminAPIRequest(t.Method, t.URL, bytes.NewReader(t.Data), t.Permissions, c)
+		c.Assert(w.Code, check.Equals, t.Code)
+		c.Assert(w.Header().Get("Content-Type"), check.Equals, t.Type)
+
+		result, err := parseListResponse(w)
+		c.Assert(err, check.IsNil)
+		c.Assert(result.Success, check.Equals, t.Success)
+
+		datastore.Environ.Config.DualControl = false
+	}
+
+	// only the changes that were applied are in the audit log
+	logs := datastore.Environ.DB.(*datastore.MockDB).AuditLogs
+	c.Assert(logs, check.HasLen, 4)
+	for i, action := range []string{datastore.AuditActionEnable, datastore.AuditActionDisable, datastore.AuditActionDisable, datastore.AuditActionDelete} {
+		c.Assert(logs[i].Action, check.Equals, action)
+		c.Assert(logs[i].Entity, check.Equals, datastore.AuditEntityKeypair)
+		c.Assert(logs[i].EntityID, check.Equals, 1)
+	}
+}
+
 func (s *KeypairSuite) TestAPISyncKeypairsHandler(c *check.C) {
 	datastore.ReEncryptKeypair = mockReEncryptKeypair
 
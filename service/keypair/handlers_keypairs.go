@@ -30,6 +30,7 @@ import (
 
 	"github.com/CanonicalLtd/serial-vault/datastore"
 	"github.com/CanonicalLtd/serial-vault/service/auth"
+	"github.com/CanonicalLtd/serial-vault/service/request"
 	"github.com/CanonicalLtd/serial-vault/service/response"
 	"github.com/gorilla/mux"
 )
@@ -60,6 +61,17 @@ func List(w http.ResponseWriter, r *http.Request) {
 	listHandler(w, authUser, false)
 }
 
+// Expiring is the API method to list the active keypairs that expire soon
+func Expiring(w http.ResponseWriter, r *http.Request) {
+	authUser, err := auth.GetUserFromJWT(w, r)
+	if err != nil {
+		response.FormatStandardResponse(false, response.ErrorAuth.Code, "", err.Error(), w)
+		return
+	}
+
+	expiringHandler(w, authUser, false, expiryDays(r))
+}
+
 // Create is the API method to create a keypair
 // Create a new keypair that can be used for signing serial assertions. The
 // keypairs are stored in the signing database and the authority-id/key-id is
@@ -77,7 +89,7 @@ func Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	createHandler(w, authUser, false, keypairWithKey)
+	createHandler(w, authUser, false, request.SourceIP(r), keypairWithKey)
 }
 
 // Get is the API method to fetch a keypair
@@ -137,7 +149,7 @@ func Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	updateHandler(w, authUser, false, keypair)
+	updateHandler(w, authUser, false, request.SourceIP(r), keypair)
 }
 
 // Generate is the API method to generate a new keypair that can be used
@@ -156,7 +168,7 @@ func Generate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	generateHandler(w, authUser, false, keypairWithKey)
+	generateHandler(w, authUser, false, request.SourceIP(r), keypairWithKey)
 }
 
 // Disable disables an existing keypair, which will mean that any
@@ -177,7 +189,7 @@ func Disable(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	enableDisableHandler(w, authUser, false, false, keypairID)
+	enableDisableHandler(w, authUser, false, request.SourceIP(r), false, keypairID)
 }
 
 // Enable enables an existing keypair, which will mean that any
@@ -198,7 +210,26 @@ func Enable(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	enableDisableHandler(w, authUser, false, true, keypairID)
+	enableDisableHandler(w, authUser, false, request.SourceIP(r), true, keypairID)
+}
+
+// Delete removes a keypair that is not used by any model
+func Delete(w http.ResponseWriter, r *http.Request) {
+	authUser, err := auth.GetUserFromJWT(w, r)
+	if err != nil {
+		response.FormatStandardResponse(false, response.ErrorAuth.Code, "", err.Error(), w)
+		return
+	}
+
+	// Get the keypair primary key
+	vars := mux.Vars(r)
+	keypairID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		response.FormatStandardResponse(false, response.ErrorInvalidID.Code, "", fmt.Sprintf("%v", vars["id"]), w)
+		return
+	}
+
+	deleteHandler(w, authUser, false, request.SourceIP(r), keypairID)
 }
 
 // Assertion updates the account key assertion on a keypair
@@ -224,7 +255,41 @@ func Assertion(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	assertionHandler(w, authUser, false, assertionRequest)
+	assertionHandler(w, authUser, false, request.SourceIP(r), assertionRequest)
+}
+
+// Policy updates the usage policy of a keypair, which restricts the assertion types,
+// brands and models that the keypair can sign
+func Policy(w http.ResponseWriter, r *http.Request) {
+	authUser, err := auth.GetUserFromJWT(w, r)
+	if err != nil {
+		response.FormatStandardResponse(false, response.ErrorAuth.Code, "", err.Error(), w)
+		return
+	}
+
+	keypairID, policy, ok := decodePolicy(w, r)
+	if !ok {
+		return
+	}
+
+	policyHandler(w, authUser, false, request.SourceIP(r), keypairID, policy)
+}
+
+// Validity sets the not-before and not-after dates of a keypair, outside of which
+// the keypair cannot sign
+func Validity(w http.ResponseWriter, r *http.Request) {
+	authUser, err := auth.GetUserFromJWT(w, r)
+	if err != nil {
+		response.FormatStandardResponse(false, response.ErrorAuth.Code, "", err.Error(), w)
+		return
+	}
+
+	keypair, ok := decodeValidity(w, r)
+	if !ok {
+		return
+	}
+
+	validityHandler(w, authUser, false, request.SourceIP(r), keypair)
 }
 
 // Status returns the creation status of a keypair
@@ -251,6 +316,70 @@ func Progress(w http.ResponseWriter, r *http.Request) {
 	progressHandler(w, authUser, false)
 }
 
+func decodePolicy(w http.ResponseWriter, r *http.Request) (int, datastore.KeypairPolicy, bool) {
+	policy := datastore.KeypairPolicy{}
+
+	vars := mux.Vars(r)
+	keypairID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		response.FormatStandardResponse(false, response.ErrorInvalidID.Code, "", fmt.Sprintf("%v", vars["id"]), w)
+		return 0, policy, false
+	}
+
+	defer r.Body.Close()
+
+	err = json.NewDecoder(r.Body).Decode(&policy)
+	switch {
+	// Check we have some data
+	case err == io.EOF:
+		response.FormatStandardResponse(false, response.ErrorInvalidData.Code, "", response.ErrorInvalidData.Message, w)
+		return keypairID, policy, false
+		// Check for parsing errors
+	case err != nil:
+		response.FormatStandardResponse(false, response.ErrorDecodeJSON.Code, "", err.Error(), w)
+		return keypairID, policy, false
+	}
+
+	return keypairID, policy, true
+}
+
+// expiryDays returns the number of days from the query, defaulting to the settings
+func expiryDays(r *http.Request) int {
+	if days, err := strconv.Atoi(r.URL.Query().Get("days")); err == nil && days >= 0 {
+		return days
+	}
+	return datastore.KeyExpiryWarningDays()
+}
+
+func decodeValidity(w http.ResponseWriter, r *http.Request) (datastore.Keypair, bool) {
+	keypair := datastore.Keypair{}
+
+	vars := mux.Vars(r)
+	keypairID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		response.FormatStandardResponse(false, response.ErrorInvalidID.Code, "", fmt.Sprintf("%v", vars["id"]), w)
+		return keypair, false
+	}
+
+	defer r.Body.Close()
+
+	err = json.NewDecoder(r.Body).Decode(&keypair)
+	switch {
+	// Check we have some data
+	case err == io.EOF:
+		response.FormatStandardResponse(false, response.ErrorInvalidData.Code, "", response.ErrorInvalidData.Message, w)
+		return keypair, false
+		// Check for parsing errors
+	case err != nil:
+		response.FormatStandardResponse(false, response.ErrorDecodeJSON.Code, "", err.Error(), w)
+		return keypair, false
+	}
+
+	// The keypair is identified by the URL
+	keypair.ID = keypairID
+	return keypair, true
+}
+
 func verifyKeypair(w http.ResponseWriter, r *http.Request, authUser datastore.User) (WithPrivateKey, bool) {
 
 	keypairWithKey := WithPrivateKey{}
@@ -30,6 +30,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/CanonicalLtd/serial-vault/config"
 	"github.com/CanonicalLtd/serial-vault/crypt"
@@ -107,6 +108,32 @@ func (s *KeypairSuite) TestListStatusHandler(c *check.C) {
 	}
 }
 
+func (s *KeypairSuite) TestExpiringHandler(c *check.C) {
+	tests := []KeypairTest{
+		{"GET", "/v1/keypairs/expiring", nil, 200, response.JSONHeader, 0, false, true, 0},
+		{"GET", "/v1/keypairs/expiring?days=7", nil, 200, response.JSONHeader, datastore.Admin, true, true, 0},
+		{"GET", "/v1/keypairs/expiring", nil, 400, response.JSONHeader, datastore.Standard, true, false, 0},
+		{"GET", "/v1/keypairs/expiring", nil, 400, response.JSONHeader, 0, true, false, 0},
+	}
+
+	for _, t := range tests {
+		if t.EnableAuth {
+			datastore.Environ.Config.EnableUserAuth = true
+		}
+
+		w := sendAdminRequest(t.Method, t.URL, bytes.NewReader(t.Data), t.Permissions, c)
+		c.Assert(w.Code, check.Equals, t.Code)
+		c.Assert(w.Header().Get("Content-Type"), check.Equals, t.Type)
+
+		result, err := parseListResponse(w)
+		c.Assert(err, check.IsNil)
+		c.Assert(result.Success, check.Equals, t.Success)
+		c.Assert(len(result.Keypairs), check.Equals, t.List)
+
+		datastore.Environ.Config.EnableUserAuth = false
+	}
+}
+
 func (s *KeypairSuite) TestKeypairsErrorHandler(c *check.C) {
 	datastore.Environ.DB = &datastore.ErrorMockDB{}
 	tests := []KeypairTest{
@@ -222,6 +249,85 @@ func (s *KeypairSuite) TestCreateGenerateEnableDisableHandler(c *check.C) {
 	}
 }
 
+func (s *KeypairSuite) TestDeleteHandler(c *check.C) {
+	tests := []KeypairTest{
+		{"DELETE", "/v1/keypairs/1", nil, 200, response.JSONHeader, 0, false, true, 0},
+		{"DELETE", "/v1/keypairs/1", nil, 200, response.JSONHeader, datastore.Admin, true, true, 0},
+		{"DELETE", "/v1/keypairs/1", nil, 400, response.JSONHeader, datastore.Standard, true, false, 0},
+		{"DELETE", "/v1/keypairs/1", nil, 400, response.JSONHeader, datastore.Admin, true, false, 1},
+		{"DELETE", "/v1/keypairs/9999999999999999999999999", nil, 400, response.JSONHeader, datastore.Admin, true, false, 0},
+	}
+	for _, t := range tests {
+		if t.List > 0 {
+			// Use the error database mock
+			datastore.Environ.DB = &datastore.ErrorMockDB{}
+		}
+		datastore.Environ.Config.EnableUserAuth = t.EnableAuth
+
+		w := sendAdminRequest(t.Method, t.URL, bytes.NewReader(t.Data), t.Permissions, c)
+		c.Assert(w.Code, check.Equals, t.Code)
+		c.Assert(w.Header().Get("Content-Type"), check.Equals, t.Type)
+
+		result, err := response.ParseStandardResponse(w)
+		c.Assert(err, check.IsNil)
+		c.Assert(result.Success, check.Equals, t.Success)
+
+		datastore.Environ.Config.EnableUserAuth = false
+		datastore.Environ.DB = &datastore.MockDB{}
+	}
+}
+
+func (s *KeypairSuite) TestDualControlHandler(c *check.C) {
+	// Mock the database and the keystore, with the sensitive operations needing approval
+	config := config.Settings{KeyStoreType: "memory", KeyStoreSecret: "this needs to be something secure", JwtSecret: "SomeTestSecretValue", DualControl: true}
+	datastore.Environ = &datastore.Env{DB: &datastore.MockDB{}, Config: config}
+
+	signingKey, err := ioutil.ReadFile("../../keystore/TestKey.asc")
+	c.Assert(err, check.IsNil)
+	k := keypair.WithPrivateKey{PrivateKey: base64.StdEncoding.EncodeToString(signingKey), AuthorityID: "system", KeyName: "serial-key"}
+	data, _ := json.Marshal(k)
+
+	tests := []KeypairTest{
+		{"POST", "/v1/keypairs", data, 202, response.JSONHeader, datastore.Admin, true, true, 0},
+		{"POST", "/v1/keypairs", data, 400, response.JSONHeader, 0, false, false, 0},
+		{"POST", "/v1/keypairs", data, 400, response.JSONHeader, datastore.Admin, true, false, 1},
+		{"POST", "/v1/keypairs/generate", data, 202, response.JSONHeader, datastore.Admin, true, true, 0},
+		{"POST", "/v1/keypairs/generate", data, 400, response.JSONHeader, datastore.Admin, true, false, 1},
+		{"POST", "/v1/keypairs/1/enable", nil, 202, response.JSONHeader, datastore.Admin, true, true, 0},
+		{"POST", "/v1/keypairs/1/enable", nil, 400, response.JSONHeader, datastore.Admin, true, false, 1},
+		{"POST", "/v1/keypairs/1/disable", nil, 200, response.JSONHeader, datastore.Admin, true, true, 0},
+		{"DELETE", "/v1/keypairs/1", nil, 202, response.JSONHeader, datastore.Admin, true, true, 0},
+		{"DELETE", "/v1/keypairs/1", nil, 400, response.JSONHeader, datastore.Admin, true, false, 1},
+	}
+	for _, t := range tests {
+		datastore.Environ.KeypairDB, _ = datastore.GetMemoryKeyStore(config)
+		if t.List > 0 {
+			// Use the error database mock
+			datastore.Environ.DB = &datastore.ErrorMockDB{}
+		}
+		datastore.Environ.Config.EnableUserAuth = t.EnableAuth
+
+		w := sendAdminRequest(t.Method, t.URL, bytes.NewReader(t.Data), t.Permissions, c)
+		c.Assert(w.Code, check.Equals, t.Code)
+		c.Assert(w.Header().Get("Content-Type"), check.Equals, t.Type)
+
+		result, err := response.ParseStandardResponse(w)
+		c.Assert(err, check.IsNil)
+		c.Assert(result.Success, check.Equals, t.Success)
+
+		// The imported signing-key is not added to the keystore until the import is approved
+		if t.URL == "/v1/keypairs" {
+			privateKey, _, err := crypt.DeserializePrivateKey(k.PrivateKey)
+			c.Assert(err, check.IsNil)
+			_, err = datastore.Environ.KeypairDB.PublicKey(privateKey.PublicKey().ID())
+			c.Assert(err, check.NotNil)
+		}
+
+		datastore.Environ.Config.EnableUserAuth = false
+		datastore.Environ.DB = &datastore.MockDB{}
+	}
+}
+
 func (s *KeypairSuite) TestCreateKeyStoreError(c *check.C) {
 	// Mock the database and the keystore
 	config := config.Settings{KeyStoreType: "memory", JwtSecret: "SomeTestSecretValue"}
@@ -314,6 +420,95 @@ func (s *KeypairSuite) TestAssertionHandler(c *check.C) {
 	}
 }
 
+func (s *KeypairSuite) TestPolicyHandler(c *check.C) {
+	policy, _ := json.Marshal(datastore.KeypairPolicy{AssertionTypes: []string{"serial", " model "}, Brands: []string{"system", ""}})
+	invalid, _ := json.Marshal(datastore.KeypairPolicy{AssertionTypes: []string{"not-a-type"}})
+
+	tests := []KeypairTest{
+		{"PUT", "/v1/keypairs/1/policy", policy, 200, response.JSONHeader, 0, false, true, 0},
+		{"PUT", "/v1/keypairs/1/policy", policy, 200, response.JSONHeader, datastore.Admin, true, true, 0},
+		{"PUT", "/v1/keypairs/1/policy", policy, 400, response.JSONHeader, datastore.Standard, true, false, 0},
+		{"PUT", "/v1/keypairs/1/policy", invalid, 400, response.JSONHeader, datastore.Admin, true, false, 0},
+		{"PUT", "/v1/keypairs/1/policy", []byte("\u1000"), 400, response.JSONHeader, datastore.Admin, true, false, 0},
+		{"PUT", "/v1/keypairs/1/policy", nil, 400, response.JSONHeader, datastore.Admin, true, false, 0},
+	}
+
+	for _, t := range tests {
+		if t.EnableAuth {
+			datastore.Environ.Config.EnableUserAuth = true
+		}
+
+		w := sendAdminRequest(t.Method, t.URL, bytes.NewReader(t.Data), t.Permissions, c)
+		c.Assert(w.Code, check.Equals, t.Code)
+		c.Assert(w.Header().Get("Content-Type"), check.Equals, t.Type)
+
+		result, err := parseListResponse(w)
+		c.Assert(err, check.IsNil)
+		c.Assert(result.Success, check.Equals, t.Success)
+
+		datastore.Environ.Config.EnableUserAuth = false
+	}
+}
+
+func (s *KeypairSuite) TestPolicyHandlerError(c *check.C) {
+	datastore.Environ.DB = &datastore.ErrorMockDB{}
+	policy, _ := json.Marshal(datastore.KeypairPolicy{AssertionTypes: []string{"serial"}})
+
+	w := sendAdminRequest("PUT", "/v1/keypairs/1/policy", bytes.NewReader(policy), datastore.Admin, c)
+	c.Assert(w.Code, check.Equals, 400)
+
+	result, err := parseListResponse(w)
+	c.Assert(err, check.IsNil)
+	c.Assert(result.Success, check.Equals, false)
+	c.Assert(result.ErrorCode, check.Equals, response.ErrorFetchKeypair.Code)
+}
+
+func (s *KeypairSuite) TestValidityHandler(c *check.C) {
+	notBefore := time.Now().AddDate(0, -1, 0)
+	notAfter := time.Now().AddDate(1, 0, 0)
+	validity, _ := json.Marshal(datastore.Keypair{NotBefore: &notBefore, NotAfter: &notAfter})
+	reversed, _ := json.Marshal(datastore.Keypair{NotBefore: &notAfter, NotAfter: &notBefore})
+
+	tests := []KeypairTest{
+		{"PUT", "/v1/keypairs/1/validity", validity, 200, response.JSONHeader, 0, false, true, 0},
+		{"PUT", "/v1/keypairs/1/validity", validity, 200, response.JSONHeader, datastore.Admin, true, true, 0},
+		{"PUT", "/v1/keypairs/1/validity", []byte("{}"), 200, response.JSONHeader, datastore.Admin, true, true, 0},
+		{"PUT", "/v1/keypairs/1/validity", validity, 400, response.JSONHeader, datastore.Standard, true, false, 0},
+		{"PUT", "/v1/keypairs/1/validity", reversed, 400, response.JSONHeader, datastore.Admin, true, false, 0},
+		{"PUT", "/v1/keypairs/1/validity", []byte("\u1000"), 400, response.JSONHeader, datastore.Admin, true, false, 0},
+		{"PUT", "/v1/keypairs/1/validity", nil, 400, response.JSONHeader, datastore.Admin, true, false, 0},
+	}
+
+	for _, t := range tests {
+		if t.EnableAuth {
+			datastore.Environ.Config.EnableUserAuth = true
+		}
+
+		w := sendAdminRequest(t.Method, t.URL, bytes.NewReader(t.Data), t.Permissions, c)
+		c.Assert(w.Code, check.Equals, t.Code)
+		c.Assert(w.Header().Get("Content-Type"), check.Equals, t.Type)
+
+		result, err := parseListResponse(w)
+		c.Assert(err, check.IsNil)
+		c.Assert(result.Success, check.Equals, t.Success)
+
+		datastore.Environ.Config.EnableUserAuth = false
+	}
+}
+
+func (s *KeypairSuite) TestValidityHandlerError(c *check.C) {
+	datastore.Environ.DB = &datastore.ErrorMockDB{}
+	validity, _ := json.Marshal(datastore.Keypair{})
+
+	w := sendAdminRequest("PUT", "/v1/keypairs/1/validity", bytes.NewReader(validity), datastore.Admin, c)
+	c.Assert(w.Code, check.Equals, 400)
+
+	result, err := parseListResponse(w)
+	c.Assert(err, check.IsNil)
+	c.Assert(result.Success, check.Equals, false)
+	c.Assert(result.ErrorCode, check.Equals, response.ErrorFetchKeypair.Code)
+}
+
 func parseListResponse(w *httptest.ResponseRecorder) (keypair.ListResponse, error) {
 	// Check the JSON response
 	result := keypair.ListResponse{}
@@ -41,10 +41,39 @@ var HTTPIncomingTimeoutsCounterVec = prometheus.NewCounterVec(
 	[]string{"method", "view"},
 )
 
+// RateLimitRejectedCounterVec is metric for the requests rejected by the rate limit or the daily quota
+var RateLimitRejectedCounterVec = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "rate_limit_rejected",
+		Help: "metric for requests rejected by the API key rate limit or daily signing quota",
+	},
+	[]string{"view", "reason"},
+)
+
+// SigningQuotaUsedCounterVec is metric for the signing requests counted against the daily quota
+var SigningQuotaUsedCounterVec = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "signing_quota_used",
+		Help: "metric for signing requests counted against the daily signing quota",
+	},
+	[]string{"view"},
+)
+
+// UnsealedKeysGauge is metric for the signing-keys that are unsealed in memory
+var UnsealedKeysGauge = prometheus.NewGauge(
+	prometheus.GaugeOpts{
+		Name: "unsealed_keys",
+		Help: "metric for signing-keys currently unsealed in memory",
+	},
+)
+
 // InitMetrics register all the metrics
 func InitMetrics() {
 	prometheus.MustRegister(HTTPIncomingRequestCounterVec)
 	prometheus.MustRegister(HTTPIncomingLatencyHistogramVec)
 	prometheus.MustRegister(HTTPIncomingErrorsCounterVec)
 	prometheus.MustRegister(HTTPIncomingTimeoutsCounterVec)
+	prometheus.MustRegister(RateLimitRejectedCounterVec)
+	prometheus.MustRegister(SigningQuotaUsedCounterVec)
+	prometheus.MustRegister(UnsealedKeysGauge)
 }
@@ -58,6 +58,21 @@ func (r *recordResponse) Write(b []byte) (int, error) {
 	return n, err
 }
 
+// Flush sends the buffered response to the client, so a streamed response is not held back
+func (r *recordResponse) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		if !r.wroteHeader {
+			r.WriteHeader(http.StatusOK)
+		}
+		f.Flush()
+	}
+}
+
+// Unwrap returns the wrapped http.ResponseWriter, for the http.ResponseController
+func (r *recordResponse) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
 // Status returns the HTTP status of the request as a string
 func (r *recordResponse) Status() string {
 	return strconv.Itoa(r.status)
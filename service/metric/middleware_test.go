@@ -15,6 +15,7 @@ var expectedPrometheusData = map[string]string{
 	"http_in_latency":  `label:{name:"method" value:"GET"} label:{name:"status" value:"200"} label:{name:"view" value:"testOK"} histogram:{sample_count:1`,
 	"http_in_requests": `label:{name:"method" value:"GET"} label:{name:"status" value:"200"} label:{name:"view" value:"testOK"} counter:{value:1`,
 	"http_in_timeouts": `label:{name:"method" value:"GET"} label:{name:"view" value:"testTimeout"} counter:{value:1`,
+	"unsealed_keys":    `gauge:{value:0}`,
 }
 
 func TestCollectAPIStats(t *testing.T) {
@@ -75,3 +76,33 @@ func TestCollectAPIStats(t *testing.T) {
 		}
 	}
 }
+
+func TestCollectAPIStatsFlush(t *testing.T) {
+	snapshot := prometheus.DefaultRegisterer
+	defer func() {
+		prometheus.DefaultRegisterer = snapshot
+	}()
+	prometheus.DefaultRegisterer = prometheus.NewRegistry()
+	InitMetrics()
+
+	var wrapped http.ResponseWriter
+	handler := CollectAPIStats("testFlush", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := w.(http.Flusher); !ok {
+			t.Error("Expected the response to be flushable")
+		}
+		w.Write([]byte("streamed"))
+		if err := http.NewResponseController(w).Flush(); err != nil {
+			t.Errorf("Error flushing the response: %v", err)
+		}
+		wrapped = w
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/flush", nil))
+	if !w.Flushed {
+		t.Error("Expected the response to be flushed")
+	}
+	if u, ok := wrapped.(interface{ Unwrap() http.ResponseWriter }); !ok || u.Unwrap() != w {
+		t.Error("Expected the wrapped response to be returned")
+	}
+}
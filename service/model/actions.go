@@ -92,7 +92,7 @@ func getHandler(w http.ResponseWriter, user datastore.User, apiCall bool, modelI
 	formatInstanceResponse(model, w)
 }
 
-func updateHandler(w http.ResponseWriter, user datastore.User, apiCall bool, modelID int, mdl datastore.Model) {
+func updateHandler(w http.ResponseWriter, user datastore.User, apiCall bool, sourceIP string, modelID int, mdl datastore.Model) {
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 
 	err := auth.CheckUserPermissions(user, datastore.Admin, apiCall)
@@ -106,6 +106,13 @@ func updateHandler(w http.ResponseWriter, user datastore.User, apiCall bool, mod
 		return
 	}
 
+	before, err := datastore.Environ.DB.GetAllowedModel(modelID, user)
+	if err != nil {
+		log.Println(err)
+		response.FormatStandardResponse(false, "error-updating-model", "", err.Error(), w)
+		return
+	}
+
 	errorSubcode, err := datastore.Environ.DB.UpdateAllowedModel(mdl, user)
 	if err != nil {
 		log.Println(err)
@@ -113,12 +120,19 @@ func updateHandler(w http.ResponseWriter, user datastore.User, apiCall bool, mod
 		return
 	}
 
+	after, err := datastore.Environ.DB.GetAllowedModel(modelID, user)
+	if err != nil {
+		log.Println(err)
+		after = mdl
+	}
+	datastore.RecordAudit(user, sourceIP, datastore.AuditActionUpdate, datastore.AuditEntityModel, modelID, before, after)
+
 	// Return successful JSON response
 	w.WriteHeader(http.StatusOK)
 	response.FormatStandardResponse(true, "", "", "", w)
 }
 
-func deleteHandler(w http.ResponseWriter, user datastore.User, apiCall bool, modelID int) {
+func deleteHandler(w http.ResponseWriter, user datastore.User, apiCall bool, sourceIP string, modelID int) {
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 
 	err := auth.CheckUserPermissions(user, datastore.Admin, apiCall)
@@ -127,20 +141,27 @@ func deleteHandler(w http.ResponseWriter, user datastore.User, apiCall bool, mod
 		return
 	}
 
-	mdl := datastore.Model{ID: modelID}
+	mdl, err := datastore.Environ.DB.GetAllowedModel(modelID, user)
+	if err != nil {
+		log.Println(err)
+		response.FormatStandardResponse(false, "error-deleting-model", "", err.Error(), w)
+		return
+	}
+
 	errorSubcode, err := datastore.Environ.DB.DeleteAllowedModel(mdl, user)
 	if err != nil {
 		log.Println(err)
 		response.FormatStandardResponse(false, "error-deleting-model", errorSubcode, err.Error(), w)
 		return
 	}
+	datastore.RecordAudit(user, sourceIP, datastore.AuditActionDelete, datastore.AuditEntityModel, modelID, mdl, nil)
 
 	// Return successful JSON response
 	w.WriteHeader(http.StatusOK)
 	response.FormatStandardResponse(true, "", "", "", w)
 }
 
-func createHandler(w http.ResponseWriter, user datastore.User, apiCall bool, mdl datastore.Model) {
+func createHandler(w http.ResponseWriter, user datastore.User, apiCall bool, sourceIP string, mdl datastore.Model) {
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 
 	err := auth.CheckUserPermissions(user, datastore.Admin, apiCall)
@@ -155,13 +176,14 @@ func createHandler(w http.ResponseWriter, user datastore.User, apiCall bool, mdl
 		response.FormatStandardResponse(false, "error-model-json", errorSubcode, err.Error(), w)
 		return
 	}
+	datastore.RecordAudit(user, sourceIP, datastore.AuditActionCreate, datastore.AuditEntityModel, allowedModel.ID, nil, allowedModel)
 
 	// Return successful JSON response
 	w.WriteHeader(http.StatusOK)
 	formatInstanceResponse(allowedModel, w)
 }
 
-func assertionHeaders(w http.ResponseWriter, user datastore.User, apiCall bool, assert datastore.ModelAssertion) {
+func assertionHeaders(w http.ResponseWriter, user datastore.User, apiCall bool, sourceIP string, assert datastore.ModelAssertion) {
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 
 	err := auth.CheckUserPermissions(user, datastore.Admin, apiCall)
@@ -171,7 +193,7 @@ func assertionHeaders(w http.ResponseWriter, user datastore.User, apiCall bool,
 	}
 
 	// Check that the user has permissions to access the model
-	_, err = datastore.Environ.DB.GetAllowedModel(assert.ModelID, user)
+	before, err := datastore.Environ.DB.GetAllowedModel(assert.ModelID, user)
 	if err != nil {
 		log.Println(err)
 		response.FormatStandardResponse(false, "error-get-model", "", err.Error(), w)
@@ -185,6 +207,10 @@ func assertionHeaders(w http.ResponseWriter, user datastore.User, apiCall bool,
 		return
 	}
 
+	after := before
+	after.ModelAssertion = assert
+	datastore.RecordAudit(user, sourceIP, datastore.AuditActionUpdate, datastore.AuditEntityModel, assert.ModelID, before, after)
+
 	w.WriteHeader(http.StatusOK)
 	response.FormatStandardResponse(true, "", "", "", w)
 }
@@ -97,7 +97,7 @@ func APIUpdate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	updateHandler(w, user, true, modelID, mdl)
+	updateHandler(w, user, true, request.SourceIP(r), modelID, mdl)
 }
 
 // APIDelete is the API method to delete a model
@@ -116,7 +116,7 @@ func APIDelete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	deleteHandler(w, user, true, modelID)
+	deleteHandler(w, user, true, request.SourceIP(r), modelID)
 }
 
 // APICreate is the API method to create a model
@@ -144,7 +144,7 @@ func APICreate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	createHandler(w, user, true, mdl)
+	createHandler(w, user, true, request.SourceIP(r), mdl)
 }
 
 // APIAssertionHeaders is the API method to upsert the model assertion header details
@@ -172,5 +172,5 @@ func APIAssertionHeaders(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	assertionHeaders(w, user, true, assert)
+	assertionHeaders(w, user, true, request.SourceIP(r), assert)
 }
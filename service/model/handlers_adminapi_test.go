@@ -80,6 +80,14 @@ func (s *ModelsSuite) TestAPICreateHandlerReturnModel(c *check.C) {
 	c.Assert(result.Model.ID > 0, check.Equals, true)
 	c.Assert(result.Model.BrandID, check.Equals, model.BrandID)
 	c.Assert(result.Model.Name, check.Equals, model.Name)
+
+	// the new model is recorded in the audit log
+	logs := datastore.Environ.DB.(*datastore.MockDB).AuditLogs
+	c.Assert(logs, check.HasLen, 1)
+	c.Assert(logs[0].Actor, check.Equals, "sv")
+	c.Assert(logs[0].Action, check.Equals, datastore.AuditActionCreate)
+	c.Assert(logs[0].Entity, check.Equals, datastore.AuditEntityModel)
+	c.Assert(logs[0].EntityID, check.Equals, result.Model.ID)
 }
 
 func sendAdminAPIRequest(method, url string, data io.Reader, permissions int, c *check.C) *httptest.ResponseRecorder {
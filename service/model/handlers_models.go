@@ -27,6 +27,7 @@ import (
 
 	"github.com/CanonicalLtd/serial-vault/datastore"
 	"github.com/CanonicalLtd/serial-vault/service/auth"
+	"github.com/CanonicalLtd/serial-vault/service/request"
 	"github.com/CanonicalLtd/serial-vault/service/response"
 	"github.com/gorilla/mux"
 )
@@ -91,7 +92,7 @@ func Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	updateHandler(w, authUser, false, modelID, mdl)
+	updateHandler(w, authUser, false, request.SourceIP(r), modelID, mdl)
 }
 
 // Delete is the API method to delete a model
@@ -109,7 +110,7 @@ func Delete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	deleteHandler(w, authUser, false, modelID)
+	deleteHandler(w, authUser, false, request.SourceIP(r), modelID)
 }
 
 // Create is the API method to create a model
@@ -136,7 +137,7 @@ func Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	createHandler(w, authUser, false, mdl)
+	createHandler(w, authUser, false, request.SourceIP(r), mdl)
 }
 
 // AssertionHeaders is the API method to upsert the model assertion header details
@@ -163,5 +164,5 @@ func AssertionHeaders(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	assertionHeaders(w, authUser, false, assert)
+	assertionHeaders(w, authUser, false, request.SourceIP(r), assert)
 }
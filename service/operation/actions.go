@@ -0,0 +1,160 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package operation
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/CanonicalLtd/serial-vault/service/log"
+
+	"github.com/CanonicalLtd/serial-vault/datastore"
+	"github.com/CanonicalLtd/serial-vault/service/auth"
+	"github.com/CanonicalLtd/serial-vault/service/response"
+)
+
+// InstanceResponse is the response from the API request, approve and reject methods
+type InstanceResponse struct {
+	Success      bool                       `json:"success"`
+	ErrorCode    string                     `json:"error_code"`
+	ErrorSubcode string                     `json:"error_subcode"`
+	ErrorMessage string                     `json:"message"`
+	Operation    datastore.PendingOperation `json:"operation"`
+}
+
+// ListResponse is the JSON response from the API pending operations method
+type ListResponse struct {
+	Success      bool                         `json:"success"`
+	ErrorCode    string                       `json:"error_code"`
+	ErrorSubcode string                       `json:"error_subcode"`
+	ErrorMessage string                       `json:"message"`
+	Operations   []datastore.PendingOperation `json:"operations"`
+}
+
+// Decision is the JSON body of a rejection, with the reason for it
+type Decision struct {
+	Message string `json:"message"`
+}
+
+// RequestApproval records a keypair operation that needs the approval of a second Superuser,
+// responding that it has been accepted
+func RequestApproval(w http.ResponseWriter, user datastore.User, op datastore.PendingOperation) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	requested, err := datastore.RequestOperation(op, user)
+	if err != nil {
+		log.Println(err)
+		response.FormatStandardResponse(false, response.ErrorRequestOperation.Code, "", err.Error(), w)
+		return
+	}
+
+	// Return the operation that is waiting for approval
+	w.WriteHeader(http.StatusAccepted)
+	formatInstanceResponse(requested, "The operation is waiting for the approval of a second Superuser", w)
+}
+
+// listHandler is the API method to fetch the pending keypair operations
+func listHandler(w http.ResponseWriter, user datastore.User, apiCall bool) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	err := auth.CheckUserPermissions(user, datastore.Admin, apiCall)
+	if err != nil {
+		response.FormatStandardResponse(false, response.ErrorAuth.Code, "", err.Error(), w)
+		return
+	}
+
+	operations, err := datastore.Environ.DB.ListAllowedPendingOperations(user)
+	if err != nil {
+		log.Println(err)
+		response.FormatStandardResponse(false, response.ErrorFetchOperations.Code, "", err.Error(), w)
+		return
+	}
+
+	// Return successful JSON response with the list of operations
+	w.WriteHeader(http.StatusOK)
+	formatListResponse(operations, w)
+}
+
+// approveHandler is the API method for a Superuser to approve, and carry out, a pending operation
+func approveHandler(w http.ResponseWriter, user datastore.User, apiCall bool, sourceIP string, operationID int) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	err := auth.CheckUserPermissions(user, datastore.Superuser, apiCall)
+	if err != nil {
+		response.FormatStandardResponse(false, response.ErrorAuth.Code, "", err.Error(), w)
+		return
+	}
+
+	op, err := datastore.ApproveOperation(operationID, user, sourceIP)
+	if err != nil {
+		log.Println(err)
+		response.FormatStandardResponse(false, response.ErrorApproveOperation.Code, "", err.Error(), w)
+		return
+	}
+
+	// Return successful JSON response
+	w.WriteHeader(http.StatusOK)
+	formatInstanceResponse(op, "", w)
+}
+
+// rejectHandler is the API method to reject a pending operation, or for the requester to withdraw it
+func rejectHandler(w http.ResponseWriter, user datastore.User, apiCall bool, sourceIP string, operationID int, decision Decision) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	err := auth.CheckUserPermissions(user, datastore.Admin, apiCall)
+	if err != nil {
+		response.FormatStandardResponse(false, response.ErrorAuth.Code, "", err.Error(), w)
+		return
+	}
+
+	op, err := datastore.RejectOperation(operationID, user, decision.Message, sourceIP)
+	if err != nil {
+		log.Println(err)
+		response.FormatStandardResponse(false, response.ErrorRejectOperation.Code, "", err.Error(), w)
+		return
+	}
+
+	// Return successful JSON response
+	w.WriteHeader(http.StatusOK)
+	formatInstanceResponse(op, "", w)
+}
+
+func formatListResponse(operations []datastore.PendingOperation, w http.ResponseWriter) error {
+	response := ListResponse{Success: true, Operations: operations}
+
+	// Encode the response as JSON
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Error forming the pending operations response (%v).\n %v", response, err)
+		return err
+	}
+	return nil
+}
+
+func formatInstanceResponse(op datastore.PendingOperation, message string, w http.ResponseWriter) error {
+	response := InstanceResponse{Success: true, ErrorMessage: message, Operation: op}
+
+	// Encode the response as JSON
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Error forming the pending operation response (%v).\n %v", response, err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package operation
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/CanonicalLtd/serial-vault/service/request"
+	"github.com/CanonicalLtd/serial-vault/service/response"
+	"github.com/gorilla/mux"
+)
+
+// APIList is the API method to fetch the pending keypair operations
+func APIList(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	// Validate the user and API key
+	user, err := request.CheckUserAPI(r)
+	if err != nil {
+		response.FormatStandardResponse(false, response.ErrorAuth.Code, "", err.Error(), w)
+		return
+	}
+
+	// Call the API with the user
+	listHandler(w, user, true)
+}
+
+// APIApprove is the API method to approve a pending keypair operation
+func APIApprove(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	// Validate the user and API key
+	user, err := request.CheckUserAPI(r)
+	if err != nil {
+		response.FormatStandardResponse(false, response.ErrorAuth.Code, "", err.Error(), w)
+		return
+	}
+
+	vars := mux.Vars(r)
+	operationID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		response.FormatStandardResponse(false, response.ErrorInvalidID.Code, "", err.Error(), w)
+		return
+	}
+
+	// Call the API with the user
+	approveHandler(w, user, true, request.SourceIP(r), operationID)
+}
+
+// APIReject is the API method to reject a pending keypair operation
+func APIReject(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	// Validate the user and API key
+	user, err := request.CheckUserAPI(r)
+	if err != nil {
+		response.FormatStandardResponse(false, response.ErrorAuth.Code, "", err.Error(), w)
+		return
+	}
+
+	vars := mux.Vars(r)
+	operationID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		response.FormatStandardResponse(false, response.ErrorInvalidID.Code, "", err.Error(), w)
+		return
+	}
+
+	decision, ok := decodeDecision(w, r)
+	if !ok {
+		return
+	}
+
+	// Call the API with the user
+	rejectHandler(w, user, true, request.SourceIP(r), operationID, decision)
+}
+
+// decodeDecision decodes the optional reason for a rejection
+func decodeDecision(w http.ResponseWriter, r *http.Request) (Decision, bool) {
+	decision := Decision{}
+	if r.Body == nil {
+		return decision, true
+	}
+	defer r.Body.Close()
+
+	err := json.NewDecoder(r.Body).Decode(&decision)
+	if err != nil && err != io.EOF {
+		response.FormatStandardResponse(false, response.ErrorDecodeJSON.Code, "", err.Error(), w)
+		return decision, false
+	}
+	return decision, true
+}
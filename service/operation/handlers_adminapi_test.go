@@ -0,0 +1,235 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package operation_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/CanonicalLtd/serial-vault/config"
+	"github.com/CanonicalLtd/serial-vault/datastore"
+	"github.com/CanonicalLtd/serial-vault/service"
+	"github.com/CanonicalLtd/serial-vault/service/operation"
+	check "gopkg.in/check.v1"
+)
+
+func TestOperationSuite(t *testing.T) { check.TestingT(t) }
+
+type OperationSuite struct{}
+
+type OperationTest struct {
+	Method      string
+	URL         string
+	Data        []byte
+	Code        int
+	Type        string
+	Permissions int
+	EnableAuth  bool
+	Success     bool
+	List        int
+}
+
+var _ = check.Suite(&OperationSuite{})
+
+func (s *OperationSuite) SetUpTest(c *check.C) {
+	// Mock the database
+	config := config.Settings{KeyStoreType: "filesystem", KeyStorePath: "../keystore", JwtSecret: "SomeTestSecretValue", DualControl: true}
+	datastore.Environ = &datastore.Env{DB: &datastore.MockDB{}, Config: config}
+	datastore.OpenKeyStore(config)
+
+	// Disable CSRF for tests as we do not have a secure connection
+	service.MiddlewareWithCSRF = service.Middleware
+}
+
+func (s *OperationSuite) TestAPIListHandler(c *check.C) {
+	tests := []OperationTest{
+		{"GET", "/api/operations", nil, 400, "application/json; charset=UTF-8", 0, false, false, 0},
+		{"GET", "/api/operations", nil, 200, "application/json; charset=UTF-8", datastore.Admin, true, true, 4},
+		{"GET", "/api/operations", nil, 200, "application/json; charset=UTF-8", datastore.Superuser, true, true, 4},
+		{"GET", "/api/operations", nil, 400, "application/json; charset=UTF-8", datastore.SyncUser, true, false, 0},
+		{"GET", "/api/operations", nil, 400, "application/json; charset=UTF-8", datastore.Standard, true, false, 0},
+	}
+
+	for _, t := range tests {
+		if t.EnableAuth {
+			datastore.Environ.Config.EnableUserAuth = true
+		}
+
+		w := sendAdminAPIRequest(t.Method, t.URL, bytes.NewReader(t.Data), t.Permissions, c)
+		c.Assert(w.Code, check.Equals, t.Code)
+		c.Assert(w.Header().Get("Content-Type"), check.Equals, t.Type)
+
+		result, err := parseListResponse(w)
+		c.Assert(err, check.IsNil)
+		c.Assert(result.Success, check.Equals, t.Success)
+		c.Assert(len(result.Operations), check.Equals, t.List)
+
+		datastore.Environ.Config.EnableUserAuth = false
+	}
+}
+
+func (s *OperationSuite) TestAPIApproveHandler(c *check.C) {
+	tests := []OperationTest{
+		{"POST", "/api/operations/1/approve", nil, 400, "application/json; charset=UTF-8", 0, false, false, 0},
+		{"POST", "/api/operations/1/approve", nil, 200, "application/json; charset=UTF-8", datastore.Superuser, true, true, 0},
+		{"POST", "/api/operations/1/approve", nil, 400, "application/json; charset=UTF-8", datastore.Admin, true, false, 0},
+		{"POST", "/api/operations/2/approve", nil, 400, "application/json; charset=UTF-8", datastore.Superuser, true, false, 0},
+		{"POST", "/api/operations/3/approve", nil, 400, "application/json; charset=UTF-8", datastore.Superuser, true, false, 0},
+		{"POST", "/api/operations/999/approve", nil, 400, "application/json; charset=UTF-8", datastore.Superuser, true, false, 0},
+	}
+
+	for _, t := range tests {
+		if t.EnableAuth {
+			datastore.Environ.Config.EnableUserAuth = true
+		}
+
+		w := sendAdminAPIRequest(t.Method, t.URL, bytes.NewReader(t.Data), t.Permissions, c)
+		c.Assert(w.Code, check.Equals, t.Code)
+		c.Assert(w.Header().Get("Content-Type"), check.Equals, t.Type)
+
+		result, err := parseInstanceResponse(w)
+		c.Assert(err, check.IsNil)
+		c.Assert(result.Success, check.Equals, t.Success)
+		if t.Success {
+			c.Assert(result.Operation.Status, check.Equals, datastore.OperationStatusDone)
+			c.Assert(result.Operation.DecidedBy, check.Equals, "root")
+		}
+
+		datastore.Environ.Config.EnableUserAuth = false
+	}
+
+	// The approval and the change it made are in the audit log, for the approver and their IP address
+	logs := datastore.Environ.DB.(*datastore.MockDB).AuditLogs
+	c.Assert(logs, check.HasLen, 3)
+	for i, action := range []string{datastore.AuditActionApprove, datastore.AuditActionEnable, datastore.AuditActionUpdate} {
+		c.Assert(logs[i].Action, check.Equals, action)
+		c.Assert(logs[i].Actor, check.Equals, "root")
+		c.Assert(logs[i].SourceIP, check.Equals, "10.0.0.1")
+	}
+	c.Assert(logs[1].Entity, check.Equals, datastore.AuditEntityKeypair)
+}
+
+func (s *OperationSuite) TestAPIRejectHandler(c *check.C) {
+	decision, _ := json.Marshal(operation.Decision{Message: "Not needed"})
+
+	tests := []OperationTest{
+		{"POST", "/api/operations/2/reject", decision, 400, "application/json; charset=UTF-8", 0, false, false, 0},
+		{"POST", "/api/operations/2/reject", decision, 200, "application/json; charset=UTF-8", datastore.Superuser, true, true, 0},
+		{"POST", "/api/operations/2/reject", nil, 200, "application/json; charset=UTF-8", datastore.Superuser, true, true, 0},
+		{"POST", "/api/operations/2/reject", decision, 400, "application/json; charset=UTF-8", datastore.Admin, true, false, 0},
+		{"POST", "/api/operations/1/reject", decision, 200, "application/json; charset=UTF-8", datastore.Admin, true, true, 0},
+		{"POST", "/api/operations/4/reject", decision, 400, "application/json; charset=UTF-8", datastore.Superuser, true, false, 0},
+		{"POST", "/api/operations/2/reject", []byte("{invalid"), 400, "application/json; charset=UTF-8", datastore.Superuser, true, false, 0},
+		{"POST", "/api/operations/2/reject", decision, 400, "application/json; charset=UTF-8", datastore.Standard, true, false, 0},
+	}
+
+	for _, t := range tests {
+		if t.EnableAuth {
+			datastore.Environ.Config.EnableUserAuth = true
+		}
+
+		w := sendAdminAPIRequest(t.Method, t.URL, bytes.NewReader(t.Data), t.Permissions, c)
+		c.Assert(w.Code, check.Equals, t.Code)
+		c.Assert(w.Header().Get("Content-Type"), check.Equals, t.Type)
+
+		result, err := parseInstanceResponse(w)
+		c.Assert(err, check.IsNil)
+		c.Assert(result.Success, check.Equals, t.Success)
+		if t.Success {
+			c.Assert(result.Operation.Status, check.Equals, datastore.OperationStatusRejected)
+		}
+
+		datastore.Environ.Config.EnableUserAuth = false
+	}
+
+	// Each rejection is in the audit log
+	logs := datastore.Environ.DB.(*datastore.MockDB).AuditLogs
+	c.Assert(logs, check.HasLen, 3)
+	for _, l := range logs {
+		c.Assert(l.Action, check.Equals, datastore.AuditActionReject)
+		c.Assert(l.Entity, check.Equals, datastore.AuditEntityOperation)
+		c.Assert(l.SourceIP, check.Equals, "10.0.0.1")
+	}
+}
+
+func (s *OperationSuite) TestErrorAPIHandler(c *check.C) {
+	tests := []OperationTest{
+		{"GET", "/api/operations", nil, 400, "application/json; charset=UTF-8", datastore.Admin, true, false, 0},
+		{"POST", "/api/operations/1/approve", nil, 400, "application/json; charset=UTF-8", datastore.Superuser, true, false, 0},
+		{"POST", "/api/operations/1/reject", nil, 400, "application/json; charset=UTF-8", datastore.Superuser, true, false, 0},
+	}
+
+	datastore.Environ.DB = &datastore.ErrorMockDB{}
+	for _, t := range tests {
+		w := sendAdminAPIRequest(t.Method, t.URL, bytes.NewReader(t.Data), t.Permissions, c)
+		c.Assert(w.Code, check.Equals, t.Code)
+		c.Assert(w.Header().Get("Content-Type"), check.Equals, t.Type)
+
+		result, err := parseInstanceResponse(w)
+		c.Assert(err, check.IsNil)
+		c.Assert(result.Success, check.Equals, t.Success)
+	}
+}
+
+func sendAdminAPIRequest(method, url string, data io.Reader, permissions int, c *check.C) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest(method, url, data)
+	r.RemoteAddr = "10.0.0.1:40000"
+
+	switch permissions {
+	case datastore.Superuser:
+		r.Header.Set("user", "root")
+		r.Header.Set("api-key", "ValidAPIKey")
+	case datastore.Admin:
+		r.Header.Set("user", "sv")
+		r.Header.Set("api-key", "ValidAPIKey")
+	case datastore.SyncUser:
+		r.Header.Set("user", "sync")
+		r.Header.Set("api-key", "ValidAPIKey")
+	case datastore.Standard:
+		r.Header.Set("user", "user1")
+		r.Header.Set("api-key", "ValidAPIKey")
+	default:
+		break
+	}
+
+	service.AdminRouter().ServeHTTP(w, r)
+
+	return w
+}
+
+func parseListResponse(w *httptest.ResponseRecorder) (operation.ListResponse, error) {
+	// Check the JSON response
+	result := operation.ListResponse{}
+	err := json.NewDecoder(w.Body).Decode(&result)
+	return result, err
+}
+
+func parseInstanceResponse(w *httptest.ResponseRecorder) (operation.InstanceResponse, error) {
+	// Check the JSON response
+	result := operation.InstanceResponse{}
+	err := json.NewDecoder(w.Body).Decode(&result)
+	return result, err
+}
@@ -34,6 +34,7 @@ import (
 	svlog "github.com/CanonicalLtd/serial-vault/service/log"
 	"github.com/CanonicalLtd/serial-vault/service/request"
 	"github.com/CanonicalLtd/serial-vault/service/response"
+	"github.com/CanonicalLtd/serial-vault/service/revocation"
 	"github.com/snapcore/snapd/asserts"
 )
 
@@ -99,6 +100,10 @@ func ModelAssertion(w http.ResponseWriter, r *http.Request) response.ErrorRespon
 
 	// Sign the assertion with the snapd assertions module
 	signedAssertion, err := datastore.Environ.KeypairDB.SignAssertion(asserts.ModelType, assertionHeaders, []byte(""), substore.FromModel.BrandID, keypair.KeyID, keypair.SealedKey)
+	if policyErr, ok := err.(*datastore.KeypairPolicyError); ok {
+		svlog.Message("PIVOT", "keypair-policy", policyErr.Message)
+		return response.PolicyError(policyErr.Reason, policyErr.Message)
+	}
 	if err != nil {
 		svlog.Message("PIVOT", "signing-assertion", err.Error())
 		return response.ErrorResponse{Success: false, Code: "signing-assertion", Message: err.Error(), StatusCode: http.StatusBadRequest}
@@ -151,6 +156,10 @@ func SerialAssertion(w http.ResponseWriter, r *http.Request) response.ErrorRespo
 
 	// Sign the assertion with the snapd assertions module
 	signedAssertion, err := datastore.Environ.KeypairDB.SignAssertion(asserts.SerialType, assertionHeaders, assertion.Body(), substore.FromModel.BrandID, substore.FromModel.KeyID, substore.FromModel.SealedKey)
+	if policyErr, ok := err.(*datastore.KeypairPolicyError); ok {
+		svlog.Message("PIVOT", "keypair-policy", policyErr.Message)
+		return response.PolicyError(policyErr.Reason, policyErr.Message)
+	}
 	if err != nil {
 		svlog.Message("PIVOT", "signing-assertion", err.Error())
 		return response.ErrorResponse{Success: false, Code: "signing-assertion", Message: err.Error(), StatusCode: http.StatusBadRequest}
@@ -199,6 +208,12 @@ func parseSerialAssertion(r *http.Request) (asserts.Assertion, response.ErrorRes
 		return nil, response.ErrorInvalidType
 	}
 
+	// Check that neither the device-key nor the serial number have been revoked
+	errResponse := revocation.CheckRevoked("PIVOT", assertion.HeaderString("brand-id"), assertion.HeaderString("model"), assertion.HeaderString("serial"), assertion.HeaderString("device-key-sha3-384"))
+	if !errResponse.Success {
+		return nil, errResponse
+	}
+
 	return assertion, response.ErrorResponse{Success: true}
 }
 
@@ -33,6 +33,7 @@ import (
 	"github.com/CanonicalLtd/serial-vault/datastore"
 	"github.com/CanonicalLtd/serial-vault/service"
 	"github.com/CanonicalLtd/serial-vault/service/pivot"
+	"github.com/CanonicalLtd/serial-vault/service/response"
 	"github.com/snapcore/snapd/asserts"
 	check "gopkg.in/check.v1"
 )
@@ -157,6 +158,33 @@ func (s *PivotSuite) TestPivotSystemUserAssertionHandler(c *check.C) {
 	}
 }
 
+// revokedMockDB revokes the device-key of the serial assertions in the mock data
+type revokedMockDB struct {
+	datastore.MockDB
+}
+
+func (mdb *revokedMockDB) CheckDeviceKeyRevoked(brandID, modelName, serialNumber, fingerprint string) (datastore.DeviceKeyRevocation, error) {
+	if fingerprint == "cP_JQakFySqvoRbC6RiTP4ik-YXrAK1xFX_V4qN4WWqdGC0X817QpOX6SJf77E_U" {
+		return datastore.DeviceKeyRevocation{ID: 1, BrandID: brandID, Fingerprint: fingerprint, Reason: "Leaked device-key"}, nil
+	}
+	return datastore.DeviceKeyRevocation{}, nil
+}
+
+func (s *PivotSuite) TestPivotRevokedDeviceKey(c *check.C) {
+	datastore.Environ.DB = &revokedMockDB{}
+
+	for _, url := range []string{"/v1/pivot", "/v1/pivotmodel", "/v1/pivotserial"} {
+		w := sendSigningRequest("POST", url, bytes.NewReader([]byte(pivot.SerialAssert)), "ValidAPIKey", c)
+		c.Assert(w.Code, check.Equals, 400)
+		c.Assert(w.Header().Get("Content-Type"), check.Equals, jsonType)
+
+		result, err := response.ParseStandardResponse(w)
+		c.Assert(err, check.IsNil)
+		c.Assert(result.Success, check.Equals, false)
+		c.Assert(result.ErrorCode, check.Equals, response.ErrorRevokedDeviceKey.Code)
+	}
+}
+
 func sendSigningRequest(method, url string, data io.Reader, apiKey string, c *check.C) *httptest.ResponseRecorder {
 	w := httptest.NewRecorder()
 	r, _ := http.NewRequest(method, url, data)
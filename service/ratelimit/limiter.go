@@ -0,0 +1,176 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/CanonicalLtd/serial-vault/config"
+	"github.com/CanonicalLtd/serial-vault/datastore"
+	"github.com/CanonicalLtd/serial-vault/service/log"
+	"github.com/CanonicalLtd/serial-vault/service/response"
+)
+
+// idleTimeout is the time after which the state of an unused API key is discarded
+const idleTimeout = time.Hour
+
+// quotaDayFormat is the format of the day that the quota is counted for
+const quotaDayFormat = "2006-01-02"
+
+// now returns the current time, and is mocked in the tests
+var now = time.Now
+
+// bucket is the token bucket of an API key
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter throttles the requests of each API key using a token bucket, and counts
+// the signing requests of each API key against a daily quota. The token buckets are
+// held in memory, so the rate limit applies to each instance of the signing service.
+// The quota is counted in the database, so it is shared by the instances and kept
+// when they are restarted
+type Limiter struct {
+	settings config.RateLimitSettings
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastPrune time.Time
+}
+
+// NewLimiter creates a limiter for the rate limit settings
+func NewLimiter(settings config.RateLimitSettings) *Limiter {
+	return &Limiter{
+		settings:  settings,
+		buckets:   map[string]*bucket{},
+		lastPrune: now(),
+	}
+}
+
+// Allow checks whether a request for the API key is allowed. Signing requests are also
+// counted against the daily quota. When the request is rejected, the error response and
+// the time to wait before retrying are returned
+func (l *Limiter) Allow(apiKey string, signing bool) (response.ErrorResponse, time.Duration) {
+	rule := l.settings.Rule(apiKey)
+	if rule.RequestsPerSecond <= 0 && (!signing || rule.DailyQuota <= 0) {
+		return response.ErrorResponse{Success: true}, 0
+	}
+
+	t := now()
+	l.prune(t)
+
+	if rule.RequestsPerSecond > 0 {
+		if e, wait := l.allowRate(apiKey, rule, t); !e.Success {
+			return e, wait
+		}
+	}
+
+	if signing && rule.DailyQuota > 0 {
+		return useQuota(apiKey, rule, t)
+	}
+	return response.ErrorResponse{Success: true}, 0
+}
+
+// AllowQuota counts a signing request for the API key against the daily quota, without
+// applying the rate limit. It is used for the signing requests of a batch, which has been
+// through the rate limit as one request
+func (l *Limiter) AllowQuota(apiKey string) (response.ErrorResponse, time.Duration) {
+	rule := l.settings.Rule(apiKey)
+	if rule.DailyQuota <= 0 {
+		return response.ErrorResponse{Success: true}, 0
+	}
+	t := now()
+	l.prune(t)
+	return useQuota(apiKey, rule, t)
+}
+
+// allowRate takes a token from the bucket of the API key, creating it with a full bucket
+func (l *Limiter) allowRate(apiKey string, rule config.RateLimitRule, t time.Time) (response.ErrorResponse, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[apiKey]
+	if !ok {
+		b = &bucket{tokens: float64(burst(rule)), last: t}
+		l.buckets[apiKey] = b
+	}
+
+	// Refill the bucket for the time since the last request
+	b.tokens = math.Min(float64(burst(rule)), b.tokens+t.Sub(b.last).Seconds()*rule.RequestsPerSecond)
+	b.last = t
+
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / rule.RequestsPerSecond * float64(time.Second))
+		return response.ErrorRateLimited, wait
+	}
+	b.tokens--
+	return response.ErrorResponse{Success: true}, 0
+}
+
+// useQuota counts a signing request against the daily quota, which is reset at midnight UTC.
+// The request is refused when the quota cannot be counted, as the signing would fail too
+func useQuota(apiKey string, rule config.RateLimitRule, t time.Time) (response.ErrorResponse, time.Duration) {
+	day := t.UTC().Truncate(24 * time.Hour)
+
+	ok, err := datastore.Environ.DB.UseSigningQuota(apiKey, day.Format(quotaDayFormat), rule.DailyQuota)
+	if err != nil {
+		log.Printf("Error counting the signing quota: %v", err)
+		return response.ErrorInternal, 0
+	}
+	if !ok {
+		return response.ErrorQuotaExceeded, day.Add(24 * time.Hour).Sub(t)
+	}
+	return response.ErrorResponse{Success: true}, 0
+}
+
+// burst returns the size of the token bucket for the rule, which allows at least one request
+func burst(rule config.RateLimitRule) int {
+	if rule.Burst > 0 {
+		return rule.Burst
+	}
+	return int(math.Max(1, math.Ceil(rule.RequestsPerSecond)))
+}
+
+// prune discards the token buckets of the API keys that have not been used recently, and
+// the quota counts of the previous days
+func (l *Limiter) prune(t time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if t.Sub(l.lastPrune) < idleTimeout {
+		return
+	}
+	l.lastPrune = t
+
+	for apiKey, b := range l.buckets {
+		if t.Sub(b.last) >= idleTimeout {
+			delete(l.buckets, apiKey)
+		}
+	}
+
+	day := t.UTC().Truncate(24 * time.Hour)
+	if err := datastore.Environ.DB.DeleteSigningQuotas(day.Format(quotaDayFormat)); err != nil {
+		log.Printf("Error deleting the signing quotas: %v", err)
+	}
+}
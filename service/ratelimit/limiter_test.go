@@ -0,0 +1,228 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ratelimit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/CanonicalLtd/serial-vault/config"
+	"github.com/CanonicalLtd/serial-vault/datastore"
+	"github.com/CanonicalLtd/serial-vault/service/response"
+	check "gopkg.in/check.v1"
+)
+
+func TestRateLimitSuite(t *testing.T) { check.TestingT(t) }
+
+type RateLimitSuite struct {
+	clock time.Time
+}
+
+var _ = check.Suite(&RateLimitSuite{})
+
+func (s *RateLimitSuite) SetUpTest(c *check.C) {
+	s.clock = time.Date(2018, 5, 1, 23, 59, 0, 0, time.UTC)
+	now = func() time.Time { return s.clock }
+	datastore.Environ = &datastore.Env{DB: &datastore.MockDB{}}
+}
+
+func (s *RateLimitSuite) TearDownTest(c *check.C) {
+	now = time.Now
+}
+
+func (s *RateLimitSuite) sendRequest(l *Limiter, apiKey string, signing bool) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("POST", "/v1/serial", nil)
+	r.Header.Set("api-key", apiKey)
+
+	l.Middleware("test", signing, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})).ServeHTTP(w, r)
+	return w
+}
+
+func (s *RateLimitSuite) checkRejected(c *check.C, w *httptest.ResponseRecorder, code, retryAfter string) {
+	c.Assert(w.Code, check.Equals, http.StatusTooManyRequests)
+	c.Assert(w.Header().Get("Retry-After"), check.Equals, retryAfter)
+
+	result := response.ErrorResponse{}
+	err := json.NewDecoder(w.Body).Decode(&result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result.Success, check.Equals, false)
+	c.Assert(result.Code, check.Equals, code)
+}
+
+func (s *RateLimitSuite) TestDisabled(c *check.C) {
+	l := NewLimiter(config.RateLimitSettings{})
+
+	for i := 0; i < 100; i++ {
+		w := s.sendRequest(l, "ValidAPIKey", true)
+		c.Assert(w.Code, check.Equals, http.StatusOK)
+	}
+}
+
+func (s *RateLimitSuite) TestRateLimit(c *check.C) {
+	l := NewLimiter(config.RateLimitSettings{
+		RateLimitRule: config.RateLimitRule{RequestsPerSecond: 0.5, Burst: 2},
+	})
+
+	// The burst is allowed, then the requests are throttled
+	for i := 0; i < 2; i++ {
+		w := s.sendRequest(l, "ValidAPIKey", false)
+		c.Assert(w.Code, check.Equals, http.StatusOK)
+	}
+	w := s.sendRequest(l, "ValidAPIKey", false)
+	s.checkRejected(c, w, "rate-limited", "2")
+
+	// Other API keys have their own bucket
+	w = s.sendRequest(l, "OtherAPIKey", false)
+	c.Assert(w.Code, check.Equals, http.StatusOK)
+
+	// The bucket refills over time
+	s.clock = s.clock.Add(1500 * time.Millisecond)
+	w = s.sendRequest(l, "ValidAPIKey", false)
+	s.checkRejected(c, w, "rate-limited", "1")
+
+	s.clock = s.clock.Add(500 * time.Millisecond)
+	w = s.sendRequest(l, "ValidAPIKey", false)
+	c.Assert(w.Code, check.Equals, http.StatusOK)
+}
+
+func (s *RateLimitSuite) TestAPIKeyOverride(c *check.C) {
+	l := NewLimiter(config.RateLimitSettings{
+		RateLimitRule: config.RateLimitRule{RequestsPerSecond: 1},
+		APIKeys: map[string]config.RateLimitRule{
+			"FactoryAPIKey": {RequestsPerSecond: 10, Burst: 5},
+		},
+	})
+
+	for i := 0; i < 5; i++ {
+		w := s.sendRequest(l, "FactoryAPIKey", false)
+		c.Assert(w.Code, check.Equals, http.StatusOK)
+	}
+	w := s.sendRequest(l, "FactoryAPIKey", false)
+	s.checkRejected(c, w, "rate-limited", "1")
+
+	w = s.sendRequest(l, "ValidAPIKey", false)
+	c.Assert(w.Code, check.Equals, http.StatusOK)
+	w = s.sendRequest(l, "ValidAPIKey", false)
+	s.checkRejected(c, w, "rate-limited", "1")
+}
+
+func (s *RateLimitSuite) TestDailyQuota(c *check.C) {
+	l := NewLimiter(config.RateLimitSettings{
+		RateLimitRule: config.RateLimitRule{DailyQuota: 2},
+	})
+
+	for i := 0; i < 2; i++ {
+		w := s.sendRequest(l, "ValidAPIKey", true)
+		c.Assert(w.Code, check.Equals, http.StatusOK)
+	}
+
+	// The quota is only applied to signing requests
+	w := s.sendRequest(l, "ValidAPIKey", false)
+	c.Assert(w.Code, check.Equals, http.StatusOK)
+
+	// Retry after midnight UTC
+	w = s.sendRequest(l, "ValidAPIKey", true)
+	s.checkRejected(c, w, "quota-exceeded", "60")
+
+	// The quota is reset on the next day
+	s.clock = s.clock.Add(time.Minute)
+	w = s.sendRequest(l, "ValidAPIKey", true)
+	c.Assert(w.Code, check.Equals, http.StatusOK)
+}
+
+func (s *RateLimitSuite) TestPrune(c *check.C) {
+	s.clock = time.Date(2018, 5, 1, 10, 0, 0, 0, time.UTC)
+	l := NewLimiter(config.RateLimitSettings{
+		RateLimitRule: config.RateLimitRule{RequestsPerSecond: 1, DailyQuota: 10},
+	})
+
+	s.sendRequest(l, "SigningAPIKey", true)
+	s.sendRequest(l, "OtherAPIKey", false)
+	c.Assert(l.buckets, check.HasLen, 2)
+
+	// Idle keys are discarded
+	s.clock = s.clock.Add(2 * time.Hour)
+	s.sendRequest(l, "ValidAPIKey", false)
+	c.Assert(l.buckets, check.HasLen, 1)
+	c.Assert(l.buckets["ValidAPIKey"], check.NotNil)
+}
+
+func (s *RateLimitSuite) TestDailyQuotaShared(c *check.C) {
+	settings := config.RateLimitSettings{
+		RateLimitRule: config.RateLimitRule{DailyQuota: 2},
+	}
+
+	// The quota is counted in the database, so it applies across the instances and restarts
+	w := s.sendRequest(NewLimiter(settings), "ValidAPIKey", true)
+	c.Assert(w.Code, check.Equals, http.StatusOK)
+	w = s.sendRequest(NewLimiter(settings), "ValidAPIKey", true)
+	c.Assert(w.Code, check.Equals, http.StatusOK)
+	w = s.sendRequest(NewLimiter(settings), "ValidAPIKey", true)
+	s.checkRejected(c, w, "quota-exceeded", "60")
+
+	// The request is refused when the quota cannot be counted
+	datastore.Environ.DB = &datastore.ErrorMockDB{}
+	w = s.sendRequest(NewLimiter(settings), "ValidAPIKey", true)
+	c.Assert(w.Code, check.Equals, http.StatusInternalServerError)
+}
+
+func (s *RateLimitSuite) TestUnknownAPIKey(c *check.C) {
+	l := NewLimiter(config.RateLimitSettings{
+		RateLimitRule: config.RateLimitRule{RequestsPerSecond: 1, DailyQuota: 10},
+	})
+
+	// The request is left for the handler to reject, without keeping state for the API key
+	w := s.sendRequest(l, "InvalidAPIKey", true)
+	c.Assert(w.Code, check.Equals, http.StatusOK)
+	w = s.sendRequest(l, "", true)
+	c.Assert(w.Code, check.Equals, http.StatusOK)
+	c.Assert(l.buckets, check.HasLen, 0)
+}
+
+func (s *RateLimitSuite) TestUseQuota(c *check.C) {
+	l := NewLimiter(config.RateLimitSettings{
+		RateLimitRule: config.RateLimitRule{DailyQuota: 2},
+	})
+
+	// Each signing request of the batch is counted against the quota
+	codes := []string{}
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("POST", "/v1/serials", nil)
+	r.Header.Set("api-key", "ValidAPIKey")
+	l.Middleware("test", false, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < 3; i++ {
+			codes = append(codes, UseQuota(r, "test").Code)
+		}
+		w.WriteHeader(http.StatusOK)
+	})).ServeHTTP(w, r)
+
+	c.Assert(w.Code, check.Equals, http.StatusOK)
+	c.Assert(codes, check.DeepEquals, []string{"", "", "quota-exceeded"})
+
+	// A request that has not been through the limiter is not counted
+	c.Assert(UseQuota(r, "test").Success, check.Equals, true)
+}
@@ -0,0 +1,98 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ratelimit
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/CanonicalLtd/serial-vault/service/log"
+	"github.com/CanonicalLtd/serial-vault/service/metric"
+	"github.com/CanonicalLtd/serial-vault/service/request"
+	"github.com/CanonicalLtd/serial-vault/service/response"
+)
+
+// contextKey is the type of the keys of the values that the middleware adds to the request context
+type contextKey int
+
+// limiterContextKey is the key of the limiter in the request context
+const limiterContextKey contextKey = 0
+
+// Middleware rejects the requests that exceed the rate limit of the API key. When the
+// request is a signing request, it is also counted against the daily quota. The API key
+// is checked first, so the limiter only holds the state of known API keys, and a request
+// with an unknown API key is left for the handler to reject. The limiter is added to the
+// request context for UseQuota
+func (l *Limiter) Middleware(view string, signing bool, inner http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apiKey, err := request.CheckModelAPI(r)
+		if err != nil {
+			inner.ServeHTTP(w, r)
+			return
+		}
+
+		e, wait := l.Allow(apiKey, signing)
+		if !e.Success {
+			metric.RateLimitRejectedCounterVec.WithLabelValues(view, e.Code).Inc()
+
+			retryAfter := int(math.Max(1, math.Ceil(wait.Seconds())))
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+			w.WriteHeader(e.StatusCode)
+
+			// Encode the response as JSON
+			if err := json.NewEncoder(w).Encode(e); err != nil {
+				log.Printf("Error forming the rate limit response: %v\n", err)
+			}
+			return
+		}
+
+		if signing && l.settings.Rule(apiKey).DailyQuota > 0 {
+			metric.SigningQuotaUsedCounterVec.WithLabelValues(view).Inc()
+		}
+		inner.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), limiterContextKey, l)))
+	})
+}
+
+// UseQuota counts one of the signing requests of a batch against the daily quota of the
+// API key, using the limiter of the request. A request that has not been through a limiter
+// is not counted
+func UseQuota(r *http.Request, view string) response.ErrorResponse {
+	l, ok := r.Context().Value(limiterContextKey).(*Limiter)
+	if !ok {
+		return response.ErrorResponse{Success: true}
+	}
+
+	apiKey := r.Header.Get("api-key")
+	e, _ := l.AllowQuota(apiKey)
+	if !e.Success {
+		metric.RateLimitRejectedCounterVec.WithLabelValues(view, e.Code).Inc()
+		return e
+	}
+
+	if l.settings.Rule(apiKey).DailyQuota > 0 {
+		metric.SigningQuotaUsedCounterVec.WithLabelValues(view).Inc()
+	}
+	return e
+}
@@ -21,7 +21,9 @@ package request
 
 import (
 	"errors"
+	"net"
 	"net/http"
+	"strings"
 
 	"github.com/CanonicalLtd/serial-vault/datastore"
 )
@@ -49,3 +51,25 @@ func CheckModelAPI(r *http.Request) (string, error) {
 
 	return apiKey, nil
 }
+
+// maxSourceIP is the length of the source IP address that is stored
+const maxSourceIP = 200
+
+// SourceIP returns the IP address that the request came from. When the request came
+// through a proxy, the X-Forwarded-For addresses come before the address of the proxy.
+// A long list is cut from the start, as the last addresses are the ones that were seen
+func SourceIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+
+	if forwarded := strings.TrimSpace(r.Header.Get("X-Forwarded-For")); len(forwarded) > 0 {
+		ip = forwarded + ", " + ip
+	}
+
+	if len(ip) > maxSourceIP {
+		return ip[len(ip)-maxSourceIP:]
+	}
+	return ip
+}
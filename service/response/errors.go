@@ -51,10 +51,19 @@ var (
 	ErrorInactiveModel             = ErrorResponse{false, "invalid-model", "", "The model is linked with an inactive signing-key", http.StatusBadRequest}
 	ErrorInvalidAccount            = ErrorResponse{false, "invalid-account", "", "The account cannot be found", http.StatusBadRequest}
 	ErrorInvalidAssertion          = ErrorResponse{false, "invalid-assertion", "", "The assertion is invalid", http.StatusBadRequest}
+	ErrorInvalidModelSignature     = ErrorResponse{false, "invalid-model-signature", "", "The model assertion is not signed by a known signing-key for the brand", http.StatusBadRequest}
+	ErrorBatchTooLarge             = ErrorResponse{false, "batch-too-large", "", "Too many serial-requests supplied in the batch", http.StatusBadRequest}
 	ErrorInvalidKeypair            = ErrorResponse{false, "invalid-keypair", "", "The keypair is invalid", http.StatusBadRequest}
 	ErrorFetchKeypairs             = ErrorResponse{false, "fetch-keypairs", "", "Error fetching the signing-keys", http.StatusBadRequest}
 	ErrorFetchKeypair              = ErrorResponse{false, "fetch-keypair", "", "Error fetching the signing-key", http.StatusBadRequest}
 	ErrorStoreKeypair              = ErrorResponse{false, "store-keypair", "", "Error string the signing-key", http.StatusBadRequest}
+	ErrorDeleteKeypair             = ErrorResponse{false, "delete-keypair", "", "Error deleting the signing-key", http.StatusBadRequest}
+	ErrorRequestOperation          = ErrorResponse{false, "request-operation", "", "Error requesting the approval of the operation", http.StatusBadRequest}
+	ErrorFetchOperations           = ErrorResponse{false, "fetch-operations", "", "Error fetching the pending operations", http.StatusBadRequest}
+	ErrorApproveOperation          = ErrorResponse{false, "approve-operation", "", "Error approving the operation", http.StatusBadRequest}
+	ErrorRejectOperation           = ErrorResponse{false, "reject-operation", "", "Error rejecting the operation", http.StatusBadRequest}
+	ErrorFetchAuditLog             = ErrorResponse{false, "fetch-audit-log", "", "Error fetching the audit log", http.StatusBadRequest}
+	ErrorAuditLogParams            = ErrorResponse{false, "audit-log-params", "", "Invalid search of the audit log", http.StatusBadRequest}
 	ErrorEmptySerial               = ErrorResponse{false, "create-assertion", "", "The serial number is missing from both the header and body", http.StatusBadRequest}
 	ErrorCreateAssertion           = ErrorResponse{false, "create-assertion", "", "Error converting the serial-request to a serial assertion", http.StatusBadRequest}
 	ErrorDecodeAssertion           = ErrorResponse{false, "decode-assertion", "", "Error decoding the assertion", http.StatusBadRequest}
@@ -62,8 +71,42 @@ var (
 	ErrorCreateModelAssertion      = ErrorResponse{false, "create-assertion", "", "Error with the model assertion headers", http.StatusBadRequest}
 	ErrorCreateSystemUserAssertion = ErrorResponse{false, "create-assertion", "", "Error with the system-user assertion", http.StatusBadRequest}
 	ErrorDuplicateAssertion        = ErrorResponse{false, "duplicate-assertion", "", "The serial number and/or device-key have already been used to sign a device", http.StatusBadRequest}
+	ErrorDuplicateSerial           = ErrorResponse{false, "duplicate-serial", "", "The serial number and/or device-key have already been used to sign a device", http.StatusBadRequest}
+	ErrorDuplicateDeviceKey        = ErrorResponse{false, "duplicate-device-key", "", "The serial number has already been used to sign a device with a different device-key", http.StatusBadRequest}
+	ErrorSerialNotAllowed          = ErrorResponse{false, "serial-not-allowed", "", "The serial number is not in the allowed serial numbers for the model", http.StatusBadRequest}
+	ErrorAllocateSerial            = ErrorResponse{false, "allocate-serial", "", "Error allocating the serial number for the model", http.StatusBadRequest}
+	ErrorRevokedDeviceKey          = ErrorResponse{false, "revoked-device-key", "", "The device-key has been revoked", http.StatusBadRequest}
+	ErrorRevokedSerial             = ErrorResponse{false, "revoked-serial", "", "The serial number has been revoked for the model", http.StatusBadRequest}
+	ErrorCheckRevocation           = ErrorResponse{false, "check-revocation", "", "Error checking the device-key revocations", http.StatusBadRequest}
 	ErrorAccountAssertion          = ErrorResponse{false, "account-assertion", "", "Error retrieving the account assertion from the database", http.StatusBadRequest}
 	ErrorSignAssertion             = ErrorResponse{false, "signing-assertion", "", "Error signing the assertion", http.StatusBadRequest}
 	ErrorGenerateNonce             = ErrorResponse{false, "generate-nonce", "", "Error generating a nonce. Please try again later", http.StatusBadRequest}
+	ErrorRateLimited               = ErrorResponse{false, "rate-limited", "", "Too many requests for the API key. Please try again later", http.StatusTooManyRequests}
+	ErrorQuotaExceeded             = ErrorResponse{false, "quota-exceeded", "", "The daily signing quota for the API key has been used", http.StatusTooManyRequests}
+	ErrorPolicyAssertionType       = ErrorResponse{false, "keypair-policy", "assertion-type", "The signing-key is not allowed to sign this type of assertion", http.StatusForbidden}
+	ErrorPolicyBrand               = ErrorResponse{false, "keypair-policy", "brand", "The signing-key is not allowed to sign assertions for the brand", http.StatusForbidden}
+	ErrorPolicyModel               = ErrorResponse{false, "keypair-policy", "model", "The signing-key is not allowed to sign assertions for the model", http.StatusForbidden}
+	ErrorPolicyNotYetValid         = ErrorResponse{false, "keypair-policy", "not-yet-valid", "The signing-key is not valid yet", http.StatusForbidden}
+	ErrorPolicyExpired             = ErrorResponse{false, "keypair-policy", "expired", "The signing-key has expired", http.StatusForbidden}
 	ErrorInternal                  = ErrorResponse{false, "server-error", "", "Internal Server Error", http.StatusInternalServerError}
 )
+
+// PolicyError returns the error response for the reason that a keypair usage policy, or its
+// validity dates, rejected an assertion, with the detailed message
+func PolicyError(reason, message string) ErrorResponse {
+	var e ErrorResponse
+	switch reason {
+	case ErrorPolicyBrand.SubCode:
+		e = ErrorPolicyBrand
+	case ErrorPolicyModel.SubCode:
+		e = ErrorPolicyModel
+	case ErrorPolicyNotYetValid.SubCode:
+		e = ErrorPolicyNotYetValid
+	case ErrorPolicyExpired.SubCode:
+		e = ErrorPolicyExpired
+	default:
+		e = ErrorPolicyAssertionType
+	}
+	e.Message = message
+	return e
+}
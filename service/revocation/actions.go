@@ -0,0 +1,139 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package revocation
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/CanonicalLtd/serial-vault/service/log"
+
+	"github.com/CanonicalLtd/serial-vault/datastore"
+	"github.com/CanonicalLtd/serial-vault/service/auth"
+	"github.com/CanonicalLtd/serial-vault/service/response"
+)
+
+// InstanceResponse is the response from the API create method
+type InstanceResponse struct {
+	Success      bool                          `json:"success"`
+	ErrorCode    string                        `json:"error_code"`
+	ErrorSubcode string                        `json:"error_subcode"`
+	ErrorMessage string                        `json:"message"`
+	Revocation   datastore.DeviceKeyRevocation `json:"revocation"`
+}
+
+// ListResponse is the JSON response from the API device-key revocations method
+type ListResponse struct {
+	Success      bool                            `json:"success"`
+	ErrorCode    string                          `json:"error_code"`
+	ErrorSubcode string                          `json:"error_subcode"`
+	ErrorMessage string                          `json:"message"`
+	Revocations  []datastore.DeviceKeyRevocation `json:"revocations"`
+}
+
+// listHandler is the API method to fetch the device-key revocations. The sync user
+// is allowed, so the revocations can be synced to the factory
+func listHandler(w http.ResponseWriter, user datastore.User, apiCall bool) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	err := auth.CheckUserPermissions(user, datastore.SyncUser, apiCall)
+	if err != nil {
+		response.FormatStandardResponse(false, "error-auth", "", err.Error(), w)
+		return
+	}
+
+	revocations, err := datastore.Environ.DB.ListAllowedDeviceKeyRevocations(user)
+	if err != nil {
+		log.Println(err)
+		response.FormatStandardResponse(false, "error-revocations-json", "", err.Error(), w)
+		return
+	}
+
+	// Return successful JSON response with the list of revocations
+	w.WriteHeader(http.StatusOK)
+	formatListResponse(revocations, w)
+}
+
+// createHandler is the API method to revoke a device-key or serial number
+func createHandler(w http.ResponseWriter, user datastore.User, apiCall bool, rev datastore.DeviceKeyRevocation) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	err := auth.CheckUserPermissions(user, datastore.Admin, apiCall)
+	if err != nil {
+		response.FormatStandardResponse(false, "error-auth", "", err.Error(), w)
+		return
+	}
+
+	created, err := datastore.Environ.DB.CreateAllowedDeviceKeyRevocation(rev, user)
+	if err != nil {
+		log.Println(err)
+		response.FormatStandardResponse(false, "error-revocation-create", "", err.Error(), w)
+		return
+	}
+
+	// Return successful JSON response
+	w.WriteHeader(http.StatusOK)
+	formatInstanceResponse(created, w)
+}
+
+// deleteHandler is the API method to remove a device-key revocation
+func deleteHandler(w http.ResponseWriter, user datastore.User, apiCall bool, revocationID int) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	err := auth.CheckUserPermissions(user, datastore.Admin, apiCall)
+	if err != nil {
+		response.FormatStandardResponse(false, "error-auth", "", err.Error(), w)
+		return
+	}
+
+	err = datastore.Environ.DB.DeleteAllowedDeviceKeyRevocation(revocationID, user)
+	if err != nil {
+		log.Println(err)
+		response.FormatStandardResponse(false, "error-revocation-delete", "", err.Error(), w)
+		return
+	}
+
+	// Return successful JSON response
+	w.WriteHeader(http.StatusOK)
+	response.FormatStandardResponse(true, "", "", "", w)
+}
+
+func formatListResponse(revocations []datastore.DeviceKeyRevocation, w http.ResponseWriter) error {
+	response := ListResponse{Success: true, Revocations: revocations}
+
+	// Encode the response as JSON
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Error forming the device-key revocations response (%v).\n %v", response, err)
+		return err
+	}
+	return nil
+}
+
+func formatInstanceResponse(rev datastore.DeviceKeyRevocation, w http.ResponseWriter) error {
+	response := InstanceResponse{Success: true, Revocation: rev}
+
+	// Encode the response as JSON
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Error forming the device-key revocation response (%v).\n %v", response, err)
+		return err
+	}
+	return nil
+}
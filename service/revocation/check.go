@@ -0,0 +1,49 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package revocation
+
+import (
+	"net/http"
+
+	"github.com/CanonicalLtd/serial-vault/datastore"
+	svlog "github.com/CanonicalLtd/serial-vault/service/log"
+	"github.com/CanonicalLtd/serial-vault/service/response"
+)
+
+// CheckRevoked verifies that neither the device-key fingerprint nor the serial number of
+// the device have been revoked
+func CheckRevoked(logType, brandID, modelName, serialNumber, fingerprint string) response.ErrorResponse {
+	rev, err := datastore.Environ.DB.CheckDeviceKeyRevoked(brandID, modelName, serialNumber, fingerprint)
+	if err != nil {
+		svlog.Message(logType, response.ErrorCheckRevocation.Code, err.Error())
+		return response.ErrorCheckRevocation
+	}
+	if rev.ID == 0 {
+		return response.ErrorResponse{Success: true}
+	}
+
+	errResponse := response.ErrorRevokedSerial
+	if len(rev.Fingerprint) > 0 {
+		errResponse = response.ErrorRevokedDeviceKey
+	}
+	svlog.Message(logType, errResponse.Code, rev.Reason)
+	return response.ErrorResponse{Success: false, Code: errResponse.Code, Message: errResponse.Message + ": " + rev.Reason, StatusCode: http.StatusBadRequest}
+}
@@ -0,0 +1,224 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package revocation_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/CanonicalLtd/serial-vault/config"
+	"github.com/CanonicalLtd/serial-vault/datastore"
+	"github.com/CanonicalLtd/serial-vault/service"
+	"github.com/CanonicalLtd/serial-vault/service/response"
+	"github.com/CanonicalLtd/serial-vault/service/revocation"
+	check "gopkg.in/check.v1"
+)
+
+func TestRevocationSuite(t *testing.T) { check.TestingT(t) }
+
+type RevocationSuite struct{}
+
+type RevocationTest struct {
+	Method      string
+	URL         string
+	Data        []byte
+	Code        int
+	Type        string
+	Permissions int
+	EnableAuth  bool
+	Success     bool
+	List        int
+}
+
+var _ = check.Suite(&RevocationSuite{})
+
+func (s *RevocationSuite) SetUpTest(c *check.C) {
+	// Mock the database
+	config := config.Settings{KeyStoreType: "filesystem", KeyStorePath: "../keystore", JwtSecret: "SomeTestSecretValue"}
+	datastore.Environ = &datastore.Env{DB: &datastore.MockDB{}, Config: config}
+	datastore.OpenKeyStore(config)
+
+	// Disable CSRF for tests as we do not have a secure connection
+	service.MiddlewareWithCSRF = service.Middleware
+}
+
+func (s *RevocationSuite) TestAPIListHandler(c *check.C) {
+	tests := []RevocationTest{
+		{"GET", "/api/revocations", nil, 400, "application/json; charset=UTF-8", 0, false, false, 0},
+		{"GET", "/api/revocations", nil, 200, "application/json; charset=UTF-8", datastore.Admin, true, true, 2},
+		{"GET", "/api/revocations", nil, 200, "application/json; charset=UTF-8", datastore.Superuser, true, true, 2},
+		{"GET", "/api/revocations", nil, 200, "application/json; charset=UTF-8", datastore.SyncUser, true, true, 2},
+		{"GET", "/api/revocations", nil, 400, "application/json; charset=UTF-8", datastore.Standard, true, false, 0},
+		{"GET", "/api/revocations", nil, 400, "application/json; charset=UTF-8", 0, true, false, 0},
+	}
+
+	for _, t := range tests {
+		if t.EnableAuth {
+			datastore.Environ.Config.EnableUserAuth = true
+		}
+
+		w := sendAdminAPIRequest(t.Method, t.URL, bytes.NewReader(t.Data), t.Permissions, c)
+		c.Assert(w.Code, check.Equals, t.Code)
+		c.Assert(w.Header().Get("Content-Type"), check.Equals, t.Type)
+
+		result, err := parseListResponse(w)
+		c.Assert(err, check.IsNil)
+		c.Assert(result.Success, check.Equals, t.Success)
+		c.Assert(len(result.Revocations), check.Equals, t.List)
+
+		datastore.Environ.Config.EnableUserAuth = false
+	}
+}
+
+func (s *RevocationSuite) TestAPICreateHandler(c *check.C) {
+	serial, _ := json.Marshal(datastore.DeviceKeyRevocation{BrandID: "system", Model: "alder", SerialNumber: "A123456L", Reason: "Stolen device"})
+	fingerprint, _ := json.Marshal(datastore.DeviceKeyRevocation{BrandID: "system", Fingerprint: "majNNh3Nbgg0CozIjfLrvvEWG830dZmm76gJHnyyrW4g7udYbfVgt0WO15ayuN5l", Reason: "Leaked device-key"})
+	both, _ := json.Marshal(datastore.DeviceKeyRevocation{BrandID: "system", Model: "alder", SerialNumber: "A123456L", Fingerprint: "majNNh3Nbgg0CozIjfLrvvEWG830dZmm76gJHnyyrW4g7udYbfVgt0WO15ayuN5l", Reason: "Stolen device"})
+	noModel, _ := json.Marshal(datastore.DeviceKeyRevocation{BrandID: "system", SerialNumber: "A123456L", Reason: "Stolen device"})
+	noReason, _ := json.Marshal(datastore.DeviceKeyRevocation{BrandID: "system", Model: "alder", SerialNumber: "A123456L"})
+	badFingerprint, _ := json.Marshal(datastore.DeviceKeyRevocation{BrandID: "system", Fingerprint: "invalid", Reason: "Leaked device-key"})
+	badBrand, _ := json.Marshal(datastore.DeviceKeyRevocation{BrandID: "invalid", Model: "alder", SerialNumber: "A123456L", Reason: "Stolen device"})
+
+	tests := []RevocationTest{
+		{"POST", "/api/revocations", serial, 400, "application/json; charset=UTF-8", 0, false, false, 0},
+		{"POST", "/api/revocations", serial, 200, "application/json; charset=UTF-8", datastore.Admin, true, true, 0},
+		{"POST", "/api/revocations", fingerprint, 200, "application/json; charset=UTF-8", datastore.Admin, true, true, 0},
+		{"POST", "/api/revocations", serial, 400, "application/json; charset=UTF-8", datastore.SyncUser, true, false, 0},
+		{"POST", "/api/revocations", serial, 400, "application/json; charset=UTF-8", datastore.Standard, true, false, 0},
+		{"POST", "/api/revocations", nil, 400, "application/json; charset=UTF-8", datastore.Admin, true, false, 0},
+		{"POST", "/api/revocations", []byte("{invalid"), 400, "application/json; charset=UTF-8", datastore.Admin, true, false, 0},
+		{"POST", "/api/revocations", both, 400, "application/json; charset=UTF-8", datastore.Admin, true, false, 0},
+		{"POST", "/api/revocations", noModel, 400, "application/json; charset=UTF-8", datastore.Admin, true, false, 0},
+		{"POST", "/api/revocations", noReason, 400, "application/json; charset=UTF-8", datastore.Admin, true, false, 0},
+		{"POST", "/api/revocations", badFingerprint, 400, "application/json; charset=UTF-8", datastore.Admin, true, false, 0},
+		{"POST", "/api/revocations", badBrand, 400, "application/json; charset=UTF-8", datastore.Admin, true, false, 0},
+	}
+
+	for _, t := range tests {
+		if t.EnableAuth {
+			datastore.Environ.Config.EnableUserAuth = true
+		}
+
+		w := sendAdminAPIRequest(t.Method, t.URL, bytes.NewReader(t.Data), t.Permissions, c)
+		c.Assert(w.Code, check.Equals, t.Code)
+		c.Assert(w.Header().Get("Content-Type"), check.Equals, t.Type)
+
+		result, err := parseInstanceResponse(w)
+		c.Assert(err, check.IsNil)
+		c.Assert(result.Success, check.Equals, t.Success)
+		if t.Success {
+			c.Assert(result.Revocation.ID, check.Not(check.Equals), 0)
+		}
+
+		datastore.Environ.Config.EnableUserAuth = false
+	}
+}
+
+func (s *RevocationSuite) TestAPIDeleteHandler(c *check.C) {
+	tests := []RevocationTest{
+		{"DELETE", "/api/revocations/1", nil, 400, "application/json; charset=UTF-8", 0, false, false, 0},
+		{"DELETE", "/api/revocations/1", nil, 200, "application/json; charset=UTF-8", datastore.Admin, true, true, 0},
+		{"DELETE", "/api/revocations/1", nil, 400, "application/json; charset=UTF-8", datastore.SyncUser, true, false, 0},
+		{"DELETE", "/api/revocations/1", nil, 400, "application/json; charset=UTF-8", datastore.Standard, true, false, 0},
+		{"DELETE", "/api/revocations/999", nil, 400, "application/json; charset=UTF-8", datastore.Admin, true, false, 0},
+	}
+
+	for _, t := range tests {
+		if t.EnableAuth {
+			datastore.Environ.Config.EnableUserAuth = true
+		}
+
+		w := sendAdminAPIRequest(t.Method, t.URL, bytes.NewReader(t.Data), t.Permissions, c)
+		c.Assert(w.Code, check.Equals, t.Code)
+		c.Assert(w.Header().Get("Content-Type"), check.Equals, t.Type)
+
+		result, err := response.ParseStandardResponse(w)
+		c.Assert(err, check.IsNil)
+		c.Assert(result.Success, check.Equals, t.Success)
+
+		datastore.Environ.Config.EnableUserAuth = false
+	}
+}
+
+func (s *RevocationSuite) TestErrorAPIHandler(c *check.C) {
+	r, _ := json.Marshal(datastore.DeviceKeyRevocation{BrandID: "system", Model: "alder", SerialNumber: "A123456L", Reason: "Stolen device"})
+
+	tests := []RevocationTest{
+		{"GET", "/api/revocations", nil, 400, "application/json; charset=UTF-8", datastore.Admin, true, false, 0},
+		{"POST", "/api/revocations", r, 400, "application/json; charset=UTF-8", datastore.Admin, true, false, 0},
+		{"DELETE", "/api/revocations/1", nil, 400, "application/json; charset=UTF-8", datastore.Admin, true, false, 0},
+	}
+
+	datastore.Environ.DB = &datastore.ErrorMockDB{}
+	for _, t := range tests {
+		w := sendAdminAPIRequest(t.Method, t.URL, bytes.NewReader(t.Data), t.Permissions, c)
+		c.Assert(w.Code, check.Equals, t.Code)
+		c.Assert(w.Header().Get("Content-Type"), check.Equals, t.Type)
+
+		result, err := response.ParseStandardResponse(w)
+		c.Assert(err, check.IsNil)
+		c.Assert(result.Success, check.Equals, t.Success)
+	}
+}
+
+func sendAdminAPIRequest(method, url string, data io.Reader, permissions int, c *check.C) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest(method, url, data)
+
+	switch permissions {
+	case datastore.Superuser:
+		r.Header.Set("user", "root")
+		r.Header.Set("api-key", "ValidAPIKey")
+	case datastore.Admin:
+		r.Header.Set("user", "sv")
+		r.Header.Set("api-key", "ValidAPIKey")
+	case datastore.SyncUser:
+		r.Header.Set("user", "sync")
+		r.Header.Set("api-key", "ValidAPIKey")
+	case datastore.Standard:
+		r.Header.Set("user", "user1")
+		r.Header.Set("api-key", "ValidAPIKey")
+	default:
+		break
+	}
+
+	service.AdminRouter().ServeHTTP(w, r)
+
+	return w
+}
+
+func parseListResponse(w *httptest.ResponseRecorder) (revocation.ListResponse, error) {
+	// Check the JSON response
+	result := revocation.ListResponse{}
+	err := json.NewDecoder(w.Body).Decode(&result)
+	return result, err
+}
+
+func parseInstanceResponse(w *httptest.ResponseRecorder) (revocation.InstanceResponse, error) {
+	// Check the JSON response
+	result := revocation.InstanceResponse{}
+	err := json.NewDecoder(w.Body).Decode(&result)
+	return result, err
+}
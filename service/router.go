@@ -28,11 +28,17 @@ import (
 	"github.com/CanonicalLtd/serial-vault/service/account"
 	"github.com/CanonicalLtd/serial-vault/service/app"
 	"github.com/CanonicalLtd/serial-vault/service/assertion"
+	"github.com/CanonicalLtd/serial-vault/service/audit"
 	"github.com/CanonicalLtd/serial-vault/service/core"
 	"github.com/CanonicalLtd/serial-vault/service/keypair"
 	"github.com/CanonicalLtd/serial-vault/service/metric"
 	"github.com/CanonicalLtd/serial-vault/service/model"
+	"github.com/CanonicalLtd/serial-vault/service/operation"
 	"github.com/CanonicalLtd/serial-vault/service/pivot"
+	"github.com/CanonicalLtd/serial-vault/service/ratelimit"
+	"github.com/CanonicalLtd/serial-vault/service/revocation"
+	"github.com/CanonicalLtd/serial-vault/service/serialallocation"
+	"github.com/CanonicalLtd/serial-vault/service/serialrule"
 	"github.com/CanonicalLtd/serial-vault/service/sign"
 	"github.com/CanonicalLtd/serial-vault/service/signinglog"
 	"github.com/CanonicalLtd/serial-vault/service/status"
@@ -52,27 +58,35 @@ func SigningRouter() *mux.Router {
 	router.Handle("/v1/version", Middleware(http.HandlerFunc(core.Version))).Methods("GET")
 	router.Handle("/v1/health", Middleware(http.HandlerFunc(core.Health))).Methods("GET")
 
-	// API routes
+	// API routes, throttled by the rate limit and daily signing quota of the API key
+	limiter := ratelimit.NewLimiter(datastore.Environ.Config.RateLimit)
 	router.Handle("/v1/serial", metric.CollectAPIStats("signSerial",
-		Middleware(ErrorHandler(sign.Serial)))).
+		Middleware(limiter.Middleware("signSerial", true, ErrorHandler(sign.Serial))))).
+		Methods("POST")
+	router.Handle("/v1/serial/validate", metric.CollectAPIStats("signSerialValidate",
+		Middleware(limiter.Middleware("signSerialValidate", false, ErrorHandler(sign.Validate))))).
+		Methods("POST")
+	// Each serial-request of a batch is counted against the daily quota by the handler
+	router.Handle("/v1/serials", metric.CollectAPIStats("signSerials",
+		Middleware(limiter.Middleware("signSerials", false, ErrorHandler(sign.Serials))))).
 		Methods("POST")
 	router.Handle("/v1/request-id", metric.CollectAPIStats("signRequestID",
-		Middleware(ErrorHandler(sign.RequestID)))).
+		Middleware(limiter.Middleware("signRequestID", false, ErrorHandler(sign.RequestID))))).
 		Methods("POST")
 	router.Handle("/v1/model", metric.CollectAPIStats("assertionModelAssertion",
-		Middleware(ErrorHandler(assertion.ModelAssertion)))).
+		Middleware(limiter.Middleware("assertionModelAssertion", true, ErrorHandler(assertion.ModelAssertion))))).
 		Methods("POST")
 	router.Handle("/v1/pivot", metric.CollectAPIStats("pivotModel",
-		Middleware(ErrorHandler(pivot.Model)))).
+		Middleware(limiter.Middleware("pivotModel", false, ErrorHandler(pivot.Model))))).
 		Methods("POST")
 	router.Handle("/v1/pivotmodel", metric.CollectAPIStats("pivotModelAssertion",
-		Middleware(ErrorHandler(pivot.ModelAssertion)))).
+		Middleware(limiter.Middleware("pivotModelAssertion", true, ErrorHandler(pivot.ModelAssertion))))).
 		Methods("POST")
 	router.Handle("/v1/pivotserial", metric.CollectAPIStats("pivotSerialAssertion",
-		Middleware(ErrorHandler(pivot.SerialAssertion)))).
+		Middleware(limiter.Middleware("pivotSerialAssertion", true, ErrorHandler(pivot.SerialAssertion))))).
 		Methods("POST")
 	router.Handle("/v1/pivotuser", metric.CollectAPIStats("pivotSystemUserAssertion",
-		Middleware(ErrorHandler(pivot.SystemUserAssertion)))).
+		Middleware(limiter.Middleware("pivotSystemUserAssertion", true, ErrorHandler(pivot.SystemUserAssertion))))).
 		Methods("POST")
 
 	// Test log upload routes (only in the factory)
@@ -132,18 +146,30 @@ func AdminRouter() *mux.Router {
 	router.Handle("/v1/keypairs", metric.CollectAPIStats("keypairCreate",
 		MiddlewareWithCSRF(http.HandlerFunc(keypair.Create)))).
 		Methods("POST")
+	router.Handle("/v1/keypairs/expiring", metric.CollectAPIStats("keypairExpiring",
+		MiddlewareWithCSRF(http.HandlerFunc(keypair.Expiring)))).
+		Methods("GET")
 	router.Handle("/v1/keypairs/{id:[0-9]+}", metric.CollectAPIStats("keypairGet",
 		MiddlewareWithCSRF(http.HandlerFunc(keypair.Get)))).
 		Methods("GET")
 	router.Handle("/v1/keypairs/{id:[0-9]+}", metric.CollectAPIStats("keypairUpdate",
 		MiddlewareWithCSRF(http.HandlerFunc(keypair.Update)))).
 		Methods("PUT")
+	router.Handle("/v1/keypairs/{id:[0-9]+}", metric.CollectAPIStats("keypairDelete",
+		MiddlewareWithCSRF(http.HandlerFunc(keypair.Delete)))).
+		Methods("DELETE")
 	router.Handle("/v1/keypairs/{id:[0-9]+}/disable", metric.CollectAPIStats("keypairDisable",
 		MiddlewareWithCSRF(http.HandlerFunc(keypair.Disable)))).
 		Methods("POST")
 	router.Handle("/v1/keypairs/{id:[0-9]+}/enable", metric.CollectAPIStats("keypairEnable",
 		MiddlewareWithCSRF(http.HandlerFunc(keypair.Enable)))).
 		Methods("POST")
+	router.Handle("/v1/keypairs/{id:[0-9]+}/policy", metric.CollectAPIStats("keypairPolicy",
+		MiddlewareWithCSRF(http.HandlerFunc(keypair.Policy)))).
+		Methods("PUT")
+	router.Handle("/v1/keypairs/{id:[0-9]+}/validity", metric.CollectAPIStats("keypairValidity",
+		MiddlewareWithCSRF(http.HandlerFunc(keypair.Validity)))).
+		Methods("PUT")
 	router.Handle("/v1/keypairs/assertion", metric.CollectAPIStats("keypairAssertion",
 		MiddlewareWithCSRF(http.HandlerFunc(keypair.Assertion)))).
 		Methods("POST")
@@ -172,6 +198,9 @@ func AdminRouter() *mux.Router {
 	router.Handle("/v1/signinglog/account/{authorityID}/filters", metric.CollectAPIStats("signinglogListFilters",
 		MiddlewareWithCSRF(http.HandlerFunc(signinglog.ListFilters)))).
 		Methods("GET")
+	router.Handle("/v1/signinglog/account/{authorityID}/export", metric.CollectAPIStats("signinglogExport",
+		MiddlewareWithCSRF(http.HandlerFunc(signinglog.Export)))).
+		Methods("GET")
 
 	// API routes: account assertions
 	router.Handle("/v1/accounts", metric.CollectAPIStats("accountList",
@@ -207,6 +236,33 @@ func AdminRouter() *mux.Router {
 		MiddlewareWithCSRF(http.HandlerFunc(assertion.SystemUserAssertion)))).
 		Methods("POST")
 
+	// API routes: pending keypair operations
+	router.Handle("/v1/operations", metric.CollectAPIStats("operationList",
+		MiddlewareWithCSRF(http.HandlerFunc(operation.List)))).
+		Methods("GET")
+	router.Handle("/v1/operations/{id:[0-9]+}/approve", metric.CollectAPIStats("operationApprove",
+		MiddlewareWithCSRF(http.HandlerFunc(operation.Approve)))).
+		Methods("POST")
+	router.Handle("/v1/operations/{id:[0-9]+}/reject", metric.CollectAPIStats("operationReject",
+		MiddlewareWithCSRF(http.HandlerFunc(operation.Reject)))).
+		Methods("POST")
+
+	// API routes: audit log
+	router.Handle("/v1/audit", metric.CollectAPIStats("auditList",
+		MiddlewareWithCSRF(http.HandlerFunc(audit.List)))).
+		Methods("GET")
+
+	// API routes: device-key revocations
+	router.Handle("/v1/revocations", metric.CollectAPIStats("revocationList",
+		MiddlewareWithCSRF(http.HandlerFunc(revocation.List)))).
+		Methods("GET")
+	router.Handle("/v1/revocations", metric.CollectAPIStats("revocationCreate",
+		MiddlewareWithCSRF(http.HandlerFunc(revocation.Create)))).
+		Methods("POST")
+	router.Handle("/v1/revocations/{id:[0-9]+}", metric.CollectAPIStats("revocationDelete",
+		MiddlewareWithCSRF(http.HandlerFunc(revocation.Delete)))).
+		Methods("DELETE")
+
 	// API routes: users management
 	router.Handle("/v1/users", metric.CollectAPIStats("userList",
 		MiddlewareWithCSRF(http.HandlerFunc(user.List)))).
@@ -243,6 +299,8 @@ func AdminRouter() *mux.Router {
 	router.PathPrefix("/accounts").Handler(MiddlewareWithCSRF(http.HandlerFunc(app.Index)))
 	router.PathPrefix("/signinglog").Handler(MiddlewareWithCSRF(http.HandlerFunc(app.Index)))
 	router.PathPrefix("/substores").Handler(MiddlewareWithCSRF(http.HandlerFunc(app.Index)))
+	router.PathPrefix("/revocations").Handler(MiddlewareWithCSRF(http.HandlerFunc(app.Index)))
+	router.PathPrefix("/audit").Handler(MiddlewareWithCSRF(http.HandlerFunc(app.Index)))
 	router.PathPrefix("/systemuser").Handler(MiddlewareWithCSRF(http.HandlerFunc(app.Index)))
 	router.PathPrefix("/users").Handler(MiddlewareWithCSRF(http.HandlerFunc(app.Index)))
 	router.PathPrefix("/notfound").Handler(MiddlewareWithCSRF(http.HandlerFunc(app.Index)))
@@ -252,9 +310,33 @@ func AdminRouter() *mux.Router {
 	router.Handle("/api/signinglog", metric.CollectAPIStats("signinglogAPIList",
 		Middleware(http.HandlerFunc(signinglog.APIList)))).
 		Methods("GET")
+	router.Handle("/api/signinglog/export", metric.CollectAPIStats("signinglogAPIExport",
+		Middleware(http.HandlerFunc(signinglog.APIExport)))).
+		Methods("GET")
+	router.Handle("/api/signinglog/checkpoints", metric.CollectAPIStats("signinglogAPICheckpoints",
+		Middleware(http.HandlerFunc(signinglog.APICheckpoints)))).
+		Methods("GET")
 	router.Handle("/api/keypairs", metric.CollectAPIStats("keypairAPIList",
 		Middleware(http.HandlerFunc(keypair.APIList)))).
 		Methods("GET")
+	router.Handle("/api/keypairs/expiring", metric.CollectAPIStats("keypairAPIExpiring",
+		Middleware(http.HandlerFunc(keypair.APIExpiring)))).
+		Methods("GET")
+	router.Handle("/api/keypairs/{id:[0-9]+}", metric.CollectAPIStats("keypairAPIDelete",
+		Middleware(http.HandlerFunc(keypair.APIDelete)))).
+		Methods("DELETE")
+	router.Handle("/api/keypairs/{id:[0-9]+}/enable", metric.CollectAPIStats("keypairAPIEnable",
+		Middleware(http.HandlerFunc(keypair.APIEnable)))).
+		Methods("POST")
+	router.Handle("/api/keypairs/{id:[0-9]+}/disable", metric.CollectAPIStats("keypairAPIDisable",
+		Middleware(http.HandlerFunc(keypair.APIDisable)))).
+		Methods("POST")
+	router.Handle("/api/keypairs/{id:[0-9]+}/policy", metric.CollectAPIStats("keypairAPIPolicy",
+		Middleware(http.HandlerFunc(keypair.APIPolicy)))).
+		Methods("PUT")
+	router.Handle("/api/keypairs/{id:[0-9]+}/validity", metric.CollectAPIStats("keypairAPIValidity",
+		Middleware(http.HandlerFunc(keypair.APIValidity)))).
+		Methods("PUT")
 	router.Handle("/api/accounts/{id:[0-9]+}/stores", metric.CollectAPIStats("substoreAPIList",
 		Middleware(http.HandlerFunc(substore.APIList)))).
 		Methods("GET")
@@ -291,6 +373,51 @@ func AdminRouter() *mux.Router {
 	router.Handle("/api/models/assertion", metric.CollectAPIStats("modelAPIAssertionHeaders",
 		Middleware(http.HandlerFunc(model.APIAssertionHeaders)))).
 		Methods("POST")
+	router.Handle("/api/serialrules", metric.CollectAPIStats("serialruleAPIListAll",
+		Middleware(http.HandlerFunc(serialrule.APIListAll)))).
+		Methods("GET")
+	router.Handle("/api/models/{id:[0-9]+}/serialrules", metric.CollectAPIStats("serialruleAPIList",
+		Middleware(http.HandlerFunc(serialrule.APIList)))).
+		Methods("GET")
+	router.Handle("/api/models/{id:[0-9]+}/serialrules", metric.CollectAPIStats("serialruleAPICreate",
+		Middleware(http.HandlerFunc(serialrule.APICreate)))).
+		Methods("POST")
+	router.Handle("/api/models/{id:[0-9]+}/serialrules/{ruleID:[0-9]+}", metric.CollectAPIStats("serialruleAPIDelete",
+		Middleware(http.HandlerFunc(serialrule.APIDelete)))).
+		Methods("DELETE")
+	router.Handle("/api/models/{id:[0-9]+}/serialallocation", metric.CollectAPIStats("serialallocationAPIGet",
+		Middleware(http.HandlerFunc(serialallocation.APIGet)))).
+		Methods("GET")
+	router.Handle("/api/models/{id:[0-9]+}/serialallocation", metric.CollectAPIStats("serialallocationAPIUpdate",
+		Middleware(http.HandlerFunc(serialallocation.APIUpdate)))).
+		Methods("PUT")
+	router.Handle("/api/models/{id:[0-9]+}/serialallocation", metric.CollectAPIStats("serialallocationAPIDelete",
+		Middleware(http.HandlerFunc(serialallocation.APIDelete)))).
+		Methods("DELETE")
+	router.Handle("/api/audit", metric.CollectAPIStats("auditAPIList",
+		Middleware(http.HandlerFunc(audit.APIList)))).
+		Methods("GET")
+	router.Handle("/api/audit/export", metric.CollectAPIStats("auditAPIExport",
+		Middleware(http.HandlerFunc(audit.APIExport)))).
+		Methods("GET")
+	router.Handle("/api/operations", metric.CollectAPIStats("operationAPIList",
+		Middleware(http.HandlerFunc(operation.APIList)))).
+		Methods("GET")
+	router.Handle("/api/operations/{id:[0-9]+}/approve", metric.CollectAPIStats("operationAPIApprove",
+		Middleware(http.HandlerFunc(operation.APIApprove)))).
+		Methods("POST")
+	router.Handle("/api/operations/{id:[0-9]+}/reject", metric.CollectAPIStats("operationAPIReject",
+		Middleware(http.HandlerFunc(operation.APIReject)))).
+		Methods("POST")
+	router.Handle("/api/revocations", metric.CollectAPIStats("revocationAPIList",
+		Middleware(http.HandlerFunc(revocation.APIList)))).
+		Methods("GET")
+	router.Handle("/api/revocations", metric.CollectAPIStats("revocationAPICreate",
+		Middleware(http.HandlerFunc(revocation.APICreate)))).
+		Methods("POST")
+	router.Handle("/api/revocations/{id:[0-9]+}", metric.CollectAPIStats("revocationAPIDelete",
+		Middleware(http.HandlerFunc(revocation.APIDelete)))).
+		Methods("DELETE")
 
 	// Sync API routes
 	router.Handle("/api/accounts", metric.CollectAPIStats("accountAPIList",
@@ -0,0 +1,119 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package serialallocation
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/CanonicalLtd/serial-vault/service/log"
+
+	"github.com/CanonicalLtd/serial-vault/datastore"
+	"github.com/CanonicalLtd/serial-vault/service/auth"
+	"github.com/CanonicalLtd/serial-vault/service/response"
+)
+
+// InstanceResponse is the JSON response from the API serial number allocation method
+type InstanceResponse struct {
+	Success          bool                       `json:"success"`
+	ErrorCode        string                     `json:"error_code"`
+	ErrorSubcode     string                     `json:"error_subcode"`
+	ErrorMessage     string                     `json:"message"`
+	Enabled          bool                       `json:"enabled"`
+	SerialAllocation datastore.SerialAllocation `json:"serialallocation"`
+}
+
+// getHandler is the API method to fetch the serial number allocation of a model
+func getHandler(w http.ResponseWriter, user datastore.User, apiCall bool, modelID int) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	err := auth.CheckUserPermissions(user, datastore.Admin, apiCall)
+	if err != nil {
+		response.FormatStandardResponse(false, "error-auth", "", err.Error(), w)
+		return
+	}
+
+	alloc, err := datastore.Environ.DB.GetAllowedSerialAllocation(modelID, user)
+	if err != nil {
+		log.Println(err)
+		response.FormatStandardResponse(false, "error-get-serialallocation", "", err.Error(), w)
+		return
+	}
+
+	// Return successful JSON response with the serial number allocation
+	w.WriteHeader(http.StatusOK)
+	formatInstanceResponse(alloc, w)
+}
+
+// updateHandler is the API method to enable or change the serial number allocation of a model
+func updateHandler(w http.ResponseWriter, user datastore.User, apiCall bool, alloc datastore.SerialAllocation) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	err := auth.CheckUserPermissions(user, datastore.Admin, apiCall)
+	if err != nil {
+		response.FormatStandardResponse(false, "error-auth", "", err.Error(), w)
+		return
+	}
+
+	err = datastore.Environ.DB.UpdateAllowedSerialAllocation(alloc, user)
+	if err != nil {
+		log.Println(err)
+		response.FormatStandardResponse(false, "error-updating-serialallocation", "", err.Error(), w)
+		return
+	}
+
+	// Return successful JSON response
+	w.WriteHeader(http.StatusOK)
+	response.FormatStandardResponse(true, "", "", "", w)
+}
+
+// deleteHandler is the API method to disable the serial number allocation of a model
+func deleteHandler(w http.ResponseWriter, user datastore.User, apiCall bool, modelID int) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	err := auth.CheckUserPermissions(user, datastore.Admin, apiCall)
+	if err != nil {
+		response.FormatStandardResponse(false, "error-auth", "", "", w)
+		return
+	}
+
+	err = datastore.Environ.DB.DeleteAllowedSerialAllocation(modelID, user)
+	if err != nil {
+		log.Println(err)
+		response.FormatStandardResponse(false, "error-deleting-serialallocation", "", err.Error(), w)
+		return
+	}
+
+	// Return successful JSON response
+	w.WriteHeader(http.StatusOK)
+	response.FormatStandardResponse(true, "", "", "", w)
+}
+
+func formatInstanceResponse(alloc datastore.SerialAllocation, w http.ResponseWriter) error {
+	response := InstanceResponse{Success: true, Enabled: alloc.ModelID != 0, SerialAllocation: alloc}
+
+	// Encode the response as JSON
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Error forming the serial number allocation response (%v).\n %v", response, err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package serialallocation_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/CanonicalLtd/serial-vault/config"
+	"github.com/CanonicalLtd/serial-vault/datastore"
+	"github.com/CanonicalLtd/serial-vault/service"
+	"github.com/CanonicalLtd/serial-vault/service/response"
+	"github.com/CanonicalLtd/serial-vault/service/serialallocation"
+	check "gopkg.in/check.v1"
+)
+
+func TestSerialAllocationSuite(t *testing.T) { check.TestingT(t) }
+
+type SerialAllocationSuite struct{}
+
+type SerialAllocationTest struct {
+	Method      string
+	URL         string
+	Data        []byte
+	Code        int
+	Type        string
+	Permissions int
+	EnableAuth  bool
+	Success     bool
+	Enabled     bool
+}
+
+var _ = check.Suite(&SerialAllocationSuite{})
+
+func (s *SerialAllocationSuite) SetUpTest(c *check.C) {
+	// Mock the database
+	config := config.Settings{KeyStoreType: "filesystem", KeyStorePath: "../keystore", JwtSecret: "SomeTestSecretValue"}
+	datastore.Environ = &datastore.Env{DB: &datastore.MockDB{}, Config: config}
+	datastore.OpenKeyStore(config)
+}
+
+func (s *SerialAllocationSuite) TestAPIGetHandler(c *check.C) {
+	tests := []SerialAllocationTest{
+		{"GET", "/api/models/1/serialallocation", nil, 400, "application/json; charset=UTF-8", 0, false, false, false},
+		{"GET", "/api/models/1/serialallocation", nil, 200, "application/json; charset=UTF-8", datastore.Admin, true, true, true},
+		{"GET", "/api/models/2/serialallocation", nil, 200, "application/json; charset=UTF-8", datastore.Superuser, true, true, false},
+		{"GET", "/api/models/1/serialallocation", nil, 400, "application/json; charset=UTF-8", datastore.Standard, true, false, false},
+		{"GET", "/api/models/1/serialallocation", nil, 400, "application/json; charset=UTF-8", 0, true, false, false},
+		{"GET", "/api/models/999/serialallocation", nil, 400, "application/json; charset=UTF-8", datastore.Admin, true, false, false},
+	}
+
+	for _, t := range tests {
+		if t.EnableAuth {
+			datastore.Environ.Config.EnableUserAuth = true
+		}
+
+		w := sendAdminAPIRequest(t.Method, t.URL, bytes.NewReader(t.Data), t.Permissions, c)
+		c.Assert(w.Code, check.Equals, t.Code)
+		c.Assert(w.Header().Get("Content-Type"), check.Equals, t.Type)
+
+		result, err := parseInstanceResponse(w)
+		c.Assert(err, check.IsNil)
+		c.Assert(result.Success, check.Equals, t.Success)
+		c.Assert(result.Enabled, check.Equals, t.Enabled)
+		if t.Enabled {
+			c.Assert(result.SerialAllocation.Prefix, check.Equals, "A")
+			c.Assert(result.SerialAllocation.Counter, check.Equals, int64(42))
+		}
+
+		datastore.Environ.Config.EnableUserAuth = false
+	}
+}
+
+func (s *SerialAllocationSuite) TestAPIUpdateDeleteHandler(c *check.C) {
+	alloc, _ := json.Marshal(datastore.SerialAllocation{Prefix: "A", Digits: 6, Checksum: true})
+	invalid, _ := json.Marshal(datastore.SerialAllocation{Prefix: "A B", Digits: 6})
+
+	tests := []SerialAllocationTest{
+		{"PUT", "/api/models/1/serialallocation", alloc, 400, "application/json; charset=UTF-8", 0, false, false, false},
+		{"PUT", "/api/models/1/serialallocation", alloc, 200, "application/json; charset=UTF-8", datastore.Admin, true, true, false},
+		{"PUT", "/api/models/1/serialallocation", alloc, 400, "application/json; charset=UTF-8", datastore.Standard, true, false, false},
+		{"PUT", "/api/models/1/serialallocation", nil, 400, "application/json; charset=UTF-8", datastore.Admin, true, false, false},
+		{"PUT", "/api/models/1/serialallocation", []byte("{invalid"), 400, "application/json; charset=UTF-8", datastore.Admin, true, false, false},
+		{"PUT", "/api/models/1/serialallocation", invalid, 400, "application/json; charset=UTF-8", datastore.Admin, true, false, false},
+		{"PUT", "/api/models/999/serialallocation", alloc, 400, "application/json; charset=UTF-8", datastore.Admin, true, false, false},
+		{"DELETE", "/api/models/1/serialallocation", nil, 400, "application/json; charset=UTF-8", 0, false, false, false},
+		{"DELETE", "/api/models/1/serialallocation", nil, 200, "application/json; charset=UTF-8", datastore.Admin, true, true, false},
+		{"DELETE", "/api/models/1/serialallocation", nil, 400, "application/json; charset=UTF-8", datastore.Standard, true, false, false},
+		{"DELETE", "/api/models/999/serialallocation", nil, 400, "application/json; charset=UTF-8", datastore.Admin, true, false, false},
+	}
+
+	for _, t := range tests {
+		if t.EnableAuth {
+			datastore.Environ.Config.EnableUserAuth = true
+		}
+
+		w := sendAdminAPIRequest(t.Method, t.URL, bytes.NewReader(t.Data), t.Permissions, c)
+		c.Assert(w.Code, check.Equals, t.Code)
+		c.Assert(w.Header().Get("Content-Type"), check.Equals, t.Type)
+
+		result, err := response.ParseStandardResponse(w)
+		c.Assert(err, check.IsNil)
+		c.Assert(result.Success, check.Equals, t.Success)
+
+		datastore.Environ.Config.EnableUserAuth = false
+	}
+}
+
+func (s *SerialAllocationSuite) TestErrorAPIHandler(c *check.C) {
+	alloc, _ := json.Marshal(datastore.SerialAllocation{Prefix: "A", Digits: 6})
+
+	tests := []SerialAllocationTest{
+		{"GET", "/api/models/1/serialallocation", nil, 400, "application/json; charset=UTF-8", datastore.Admin, true, false, false},
+		{"PUT", "/api/models/1/serialallocation", alloc, 400, "application/json; charset=UTF-8", datastore.Admin, true, false, false},
+		{"DELETE", "/api/models/1/serialallocation", nil, 400, "application/json; charset=UTF-8", datastore.Admin, true, false, false},
+	}
+
+	datastore.Environ.DB = &datastore.ErrorMockDB{}
+	for _, t := range tests {
+		w := sendAdminAPIRequest(t.Method, t.URL, bytes.NewReader(t.Data), t.Permissions, c)
+		c.Assert(w.Code, check.Equals, t.Code)
+		c.Assert(w.Header().Get("Content-Type"), check.Equals, t.Type)
+
+		result, err := response.ParseStandardResponse(w)
+		c.Assert(err, check.IsNil)
+		c.Assert(result.Success, check.Equals, t.Success)
+	}
+}
+
+func sendAdminAPIRequest(method, url string, data io.Reader, permissions int, c *check.C) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest(method, url, data)
+
+	switch permissions {
+	case datastore.Superuser:
+		r.Header.Set("user", "root")
+		r.Header.Set("api-key", "ValidAPIKey")
+	case datastore.Admin:
+		r.Header.Set("user", "sv")
+		r.Header.Set("api-key", "ValidAPIKey")
+	case datastore.Standard:
+		r.Header.Set("user", "user1")
+		r.Header.Set("api-key", "ValidAPIKey")
+	default:
+		break
+	}
+
+	service.AdminRouter().ServeHTTP(w, r)
+
+	return w
+}
+
+func parseInstanceResponse(w *httptest.ResponseRecorder) (serialallocation.InstanceResponse, error) {
+	// Check the JSON response
+	result := serialallocation.InstanceResponse{}
+	err := json.NewDecoder(w.Body).Decode(&result)
+	return result, err
+}
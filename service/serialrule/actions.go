@@ -0,0 +1,141 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package serialrule
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/CanonicalLtd/serial-vault/service/log"
+
+	"github.com/CanonicalLtd/serial-vault/datastore"
+	"github.com/CanonicalLtd/serial-vault/service/auth"
+	"github.com/CanonicalLtd/serial-vault/service/response"
+)
+
+// ListResponse is the JSON response from the API serial number rules method
+type ListResponse struct {
+	Success      bool                   `json:"success"`
+	ErrorCode    string                 `json:"error_code"`
+	ErrorSubcode string                 `json:"error_subcode"`
+	ErrorMessage string                 `json:"message"`
+	SerialRules  []datastore.SerialRule `json:"serialrules"`
+}
+
+// listHandler is the API method to fetch the serial number rules of a model
+func listHandler(w http.ResponseWriter, user datastore.User, apiCall bool, modelID int) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	err := auth.CheckUserPermissions(user, datastore.Admin, apiCall)
+	if err != nil {
+		response.FormatStandardResponse(false, "error-auth", "", err.Error(), w)
+		return
+	}
+
+	rules, err := datastore.Environ.DB.ListAllowedSerialRules(modelID, user)
+	if err != nil {
+		log.Println(err)
+		response.FormatStandardResponse(false, "error-serialrules-json", "", err.Error(), w)
+		return
+	}
+
+	// Return successful JSON response with the list of rules
+	w.WriteHeader(http.StatusOK)
+	formatListResponse(rules, w)
+}
+
+// listAllHandler is the API method to fetch the serial number rules of all the models of the user,
+// which are synced to the factory
+func listAllHandler(w http.ResponseWriter, user datastore.User, apiCall bool) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	err := auth.CheckUserPermissions(user, datastore.SyncUser, apiCall)
+	if err != nil {
+		response.FormatStandardResponse(false, "error-auth", "", err.Error(), w)
+		return
+	}
+
+	rules, err := datastore.Environ.DB.ListAllAllowedSerialRules(user)
+	if err != nil {
+		log.Println(err)
+		response.FormatStandardResponse(false, "error-serialrules-json", "", err.Error(), w)
+		return
+	}
+
+	// Return successful JSON response with the list of rules
+	w.WriteHeader(http.StatusOK)
+	formatListResponse(rules, w)
+}
+
+// createHandler is the API method to add serial number rules to a model
+func createHandler(w http.ResponseWriter, user datastore.User, apiCall bool, modelID int, rules []datastore.SerialRule) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	err := auth.CheckUserPermissions(user, datastore.Admin, apiCall)
+	if err != nil {
+		response.FormatStandardResponse(false, "error-auth", "", err.Error(), w)
+		return
+	}
+
+	err = datastore.Environ.DB.CreateAllowedSerialRules(modelID, rules, user)
+	if err != nil {
+		log.Println(err)
+		response.FormatStandardResponse(false, "error-creating-serialrules", "", err.Error(), w)
+		return
+	}
+
+	// Return successful JSON response
+	w.WriteHeader(http.StatusOK)
+	response.FormatStandardResponse(true, "", "", "", w)
+}
+
+// deleteHandler is the API method to remove a serial number rule from a model
+func deleteHandler(w http.ResponseWriter, user datastore.User, apiCall bool, modelID, ruleID int) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	err := auth.CheckUserPermissions(user, datastore.Admin, apiCall)
+	if err != nil {
+		response.FormatStandardResponse(false, "error-auth", "", "", w)
+		return
+	}
+
+	err = datastore.Environ.DB.DeleteAllowedSerialRule(modelID, ruleID, user)
+	if err != nil {
+		log.Println(err)
+		response.FormatStandardResponse(false, "error-deleting-serialrule", "", err.Error(), w)
+		return
+	}
+
+	// Return successful JSON response
+	w.WriteHeader(http.StatusOK)
+	response.FormatStandardResponse(true, "", "", "", w)
+}
+
+func formatListResponse(rules []datastore.SerialRule, w http.ResponseWriter) error {
+	response := ListResponse{Success: true, SerialRules: rules}
+
+	// Encode the response as JSON
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Error forming the serial number rules response (%v).\n %v", response, err)
+		return err
+	}
+	return nil
+}
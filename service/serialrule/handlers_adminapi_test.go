@@ -0,0 +1,213 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017-2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package serialrule_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/CanonicalLtd/serial-vault/config"
+	"github.com/CanonicalLtd/serial-vault/datastore"
+	"github.com/CanonicalLtd/serial-vault/service"
+	"github.com/CanonicalLtd/serial-vault/service/response"
+	"github.com/CanonicalLtd/serial-vault/service/serialrule"
+	check "gopkg.in/check.v1"
+)
+
+func TestSerialRuleSuite(t *testing.T) { check.TestingT(t) }
+
+type SerialRuleSuite struct{}
+
+type SerialRuleTest struct {
+	Method      string
+	URL         string
+	Data        []byte
+	Code        int
+	Type        string
+	Permissions int
+	EnableAuth  bool
+	Success     bool
+	List        int
+}
+
+var _ = check.Suite(&SerialRuleSuite{})
+
+func (s *SerialRuleSuite) SetUpTest(c *check.C) {
+	// Mock the database
+	config := config.Settings{KeyStoreType: "filesystem", KeyStorePath: "../keystore", JwtSecret: "SomeTestSecretValue"}
+	datastore.Environ = &datastore.Env{DB: &datastore.MockDB{}, Config: config}
+	datastore.OpenKeyStore(config)
+}
+
+func (s *SerialRuleSuite) TestAPIListHandler(c *check.C) {
+	tests := []SerialRuleTest{
+		{"GET", "/api/models/1/serialrules", nil, 400, "application/json; charset=UTF-8", 0, false, false, 0},
+		{"GET", "/api/models/1/serialrules", nil, 200, "application/json; charset=UTF-8", datastore.Admin, true, true, 3},
+		{"GET", "/api/models/1/serialrules", nil, 200, "application/json; charset=UTF-8", datastore.Superuser, true, true, 3},
+		{"GET", "/api/models/1/serialrules", nil, 400, "application/json; charset=UTF-8", datastore.Standard, true, false, 0},
+		{"GET", "/api/models/1/serialrules", nil, 400, "application/json; charset=UTF-8", 0, true, false, 0},
+		{"GET", "/api/models/999/serialrules", nil, 400, "application/json; charset=UTF-8", datastore.Admin, true, false, 0},
+	}
+
+	for _, t := range tests {
+		if t.EnableAuth {
+			datastore.Environ.Config.EnableUserAuth = true
+		}
+
+		w := sendAdminAPIRequest(t.Method, t.URL, bytes.NewReader(t.Data), t.Permissions, c)
+		c.Assert(w.Code, check.Equals, t.Code)
+		c.Assert(w.Header().Get("Content-Type"), check.Equals, t.Type)
+
+		result, err := parseListResponse(w)
+		c.Assert(err, check.IsNil)
+		c.Assert(result.Success, check.Equals, t.Success)
+		c.Assert(len(result.SerialRules), check.Equals, t.List)
+
+		datastore.Environ.Config.EnableUserAuth = false
+	}
+}
+
+func (s *SerialRuleSuite) TestAPIListAllHandler(c *check.C) {
+	tests := []SerialRuleTest{
+		{"GET", "/api/serialrules", nil, 400, "application/json; charset=UTF-8", 0, false, false, 0},
+		{"GET", "/api/serialrules", nil, 200, "application/json; charset=UTF-8", datastore.SyncUser, true, true, 3},
+		{"GET", "/api/serialrules", nil, 200, "application/json; charset=UTF-8", datastore.Admin, true, true, 3},
+		{"GET", "/api/serialrules", nil, 400, "application/json; charset=UTF-8", datastore.Standard, true, false, 0},
+	}
+
+	for _, t := range tests {
+		if t.EnableAuth {
+			datastore.Environ.Config.EnableUserAuth = true
+		}
+
+		w := sendAdminAPIRequest(t.Method, t.URL, bytes.NewReader(t.Data), t.Permissions, c)
+		c.Assert(w.Code, check.Equals, t.Code)
+		c.Assert(w.Header().Get("Content-Type"), check.Equals, t.Type)
+
+		result, err := parseListResponse(w)
+		c.Assert(err, check.IsNil)
+		c.Assert(result.Success, check.Equals, t.Success)
+		c.Assert(len(result.SerialRules), check.Equals, t.List)
+
+		datastore.Environ.Config.EnableUserAuth = false
+	}
+}
+
+func (s *SerialRuleSuite) TestAPICreateDeleteHandler(c *check.C) {
+	rules := []datastore.SerialRule{
+		{RuleType: datastore.SerialRuleSerial, Value: "A123456L"},
+		{RuleType: datastore.SerialRuleRange, Value: "A", RangeStart: 1000, RangeEnd: 1999},
+		{RuleType: datastore.SerialRulePattern, Value: "B[0-9]{6}L"},
+	}
+	r, _ := json.Marshal(rules)
+
+	invalid := []datastore.SerialRule{{RuleType: datastore.SerialRuleRange, RangeStart: 200, RangeEnd: 100}}
+	rInvalid, _ := json.Marshal(invalid)
+
+	tests := []SerialRuleTest{
+		{"POST", "/api/models/1/serialrules", r, 400, "application/json; charset=UTF-8", 0, false, false, 0},
+		{"POST", "/api/models/1/serialrules", r, 200, "application/json; charset=UTF-8", datastore.Admin, true, true, 0},
+		{"POST", "/api/models/1/serialrules", r, 400, "application/json; charset=UTF-8", datastore.Standard, true, false, 0},
+		{"POST", "/api/models/1/serialrules", nil, 400, "application/json; charset=UTF-8", datastore.Admin, true, false, 0},
+		{"POST", "/api/models/1/serialrules", []byte("[]"), 400, "application/json; charset=UTF-8", datastore.Admin, true, false, 0},
+		{"POST", "/api/models/1/serialrules", []byte("{invalid"), 400, "application/json; charset=UTF-8", datastore.Admin, true, false, 0},
+		{"POST", "/api/models/1/serialrules", rInvalid, 400, "application/json; charset=UTF-8", datastore.Admin, true, false, 0},
+		{"POST", "/api/models/999/serialrules", r, 400, "application/json; charset=UTF-8", datastore.Admin, true, false, 0},
+		{"DELETE", "/api/models/1/serialrules/1", nil, 400, "application/json; charset=UTF-8", 0, false, false, 0},
+		{"DELETE", "/api/models/1/serialrules/1", nil, 200, "application/json; charset=UTF-8", datastore.Admin, true, true, 0},
+		{"DELETE", "/api/models/1/serialrules/1", nil, 400, "application/json; charset=UTF-8", datastore.Standard, true, false, 0},
+		{"DELETE", "/api/models/999/serialrules/1", nil, 400, "application/json; charset=UTF-8", datastore.Admin, true, false, 0},
+	}
+
+	for _, t := range tests {
+		if t.EnableAuth {
+			datastore.Environ.Config.EnableUserAuth = true
+		}
+
+		w := sendAdminAPIRequest(t.Method, t.URL, bytes.NewReader(t.Data), t.Permissions, c)
+		c.Assert(w.Code, check.Equals, t.Code)
+		c.Assert(w.Header().Get("Content-Type"), check.Equals, t.Type)
+
+		result, err := response.ParseStandardResponse(w)
+		c.Assert(err, check.IsNil)
+		c.Assert(result.Success, check.Equals, t.Success)
+
+		datastore.Environ.Config.EnableUserAuth = false
+	}
+}
+
+func (s *SerialRuleSuite) TestErrorAPIHandler(c *check.C) {
+	r, _ := json.Marshal([]datastore.SerialRule{{RuleType: datastore.SerialRuleSerial, Value: "A123456L"}})
+
+	tests := []SerialRuleTest{
+		{"GET", "/api/models/1/serialrules", nil, 400, "application/json; charset=UTF-8", datastore.Admin, true, false, 0},
+		{"GET", "/api/serialrules", nil, 400, "application/json; charset=UTF-8", datastore.SyncUser, true, false, 0},
+		{"POST", "/api/models/1/serialrules", r, 400, "application/json; charset=UTF-8", datastore.Admin, true, false, 0},
+		{"DELETE", "/api/models/1/serialrules/1", nil, 400, "application/json; charset=UTF-8", datastore.Admin, true, false, 0},
+	}
+
+	datastore.Environ.DB = &datastore.ErrorMockDB{}
+	for _, t := range tests {
+		w := sendAdminAPIRequest(t.Method, t.URL, bytes.NewReader(t.Data), t.Permissions, c)
+		c.Assert(w.Code, check.Equals, t.Code)
+		c.Assert(w.Header().Get("Content-Type"), check.Equals, t.Type)
+
+		result, err := response.ParseStandardResponse(w)
+		c.Assert(err, check.IsNil)
+		c.Assert(result.Success, check.Equals, t.Success)
+	}
+}
+
+func sendAdminAPIRequest(method, url string, data io.Reader, permissions int, c *check.C) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest(method, url, data)
+
+	switch permissions {
+	case datastore.Superuser:
+		r.Header.Set("user", "root")
+		r.Header.Set("api-key", "ValidAPIKey")
+	case datastore.Admin:
+		r.Header.Set("user", "sv")
+		r.Header.Set("api-key", "ValidAPIKey")
+	case datastore.SyncUser:
+		r.Header.Set("user", "sync")
+		r.Header.Set("api-key", "ValidAPIKey")
+	case datastore.Standard:
+		r.Header.Set("user", "user1")
+		r.Header.Set("api-key", "ValidAPIKey")
+	default:
+		break
+	}
+
+	service.AdminRouter().ServeHTTP(w, r)
+
+	return w
+}
+
+func parseListResponse(w *httptest.ResponseRecorder) (serialrule.ListResponse, error) {
+	// Check the JSON response
+	result := serialrule.ListResponse{}
+	err := json.NewDecoder(w.Body).Decode(&result)
+	return result, err
+}
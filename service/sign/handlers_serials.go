@@ -0,0 +1,243 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package sign
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	svlog "github.com/CanonicalLtd/serial-vault/service/log"
+	"github.com/CanonicalLtd/serial-vault/service/ratelimit"
+	"github.com/CanonicalLtd/serial-vault/service/request"
+	"github.com/CanonicalLtd/serial-vault/service/response"
+	"github.com/snapcore/snapd/asserts"
+)
+
+// maxBatchSize is the maximum number of serial-requests accepted in one batch
+const maxBatchSize = 100
+
+// SerialResult is the outcome of signing one serial-request in a batch
+type SerialResult struct {
+	Success      bool   `json:"success"`
+	Serial       string `json:"serial"`
+	Assertion    string `json:"assertion"`
+	ErrorCode    string `json:"error_code"`
+	ErrorMessage string `json:"message"`
+}
+
+// SerialsResponse is the JSON response from the API Serials method
+type SerialsResponse struct {
+	Success bool           `json:"success"`
+	Results []SerialResult `json:"results"`
+}
+
+// batchItem holds the decoded assertions of one serial-request in a batch, or the error
+// found decoding them
+type batchItem struct {
+	assertions  map[string]asserts.Assertion
+	errResponse response.ErrorResponse
+}
+
+// Serials is the API method to sign a batch of serial-requests from the factory.
+// Each serial-request is handled as it would be by the Serial method, and the
+// results are returned per item, so one failing request does not fail the batch
+func Serials(w http.ResponseWriter, r *http.Request) response.ErrorResponse {
+
+	// Check that we have an authorised API key header
+	apiKey, err := request.CheckModelAPI(r)
+	if err != nil {
+		svlog.Message("SIGN", response.ErrorInvalidAPIKey.Code, response.ErrorInvalidAPIKey.Message)
+		return response.ErrorInvalidAPIKey
+	}
+
+	items, errResponse := parseBatchAssertionStream(r)
+	if !errResponse.Success {
+		return errResponse
+	}
+
+	results := []SerialResult{}
+	for _, item := range items {
+		results = append(results, signBatchItem(r, item, apiKey))
+	}
+
+	// Return the results for each of the serial-requests
+	formatSerialsResponse(results, w)
+	return response.ErrorResponse{Success: true}
+}
+
+// signBatchItem signs one serial-request of a batch. Each serial-request that has been
+// decoded is counted against the daily signing quota of the API key
+func signBatchItem(r *http.Request, item batchItem, apiKey string) SerialResult {
+	if !item.errResponse.Success {
+		return SerialResult{ErrorCode: item.errResponse.Code, ErrorMessage: item.errResponse.Message}
+	}
+
+	result := SerialResult{Serial: item.assertions["serial-request"].HeaderString("serial")}
+
+	if errResponse := ratelimit.UseQuota(r, "signSerials"); !errResponse.Success {
+		result.ErrorCode = errResponse.Code
+		result.ErrorMessage = errResponse.Message
+		return result
+	}
+
+	signedAssertion, errResponse := signSerialRequest(item.assertions, apiKey)
+	if !errResponse.Success {
+		result.ErrorCode = errResponse.Code
+		result.ErrorMessage = errResponse.Message
+		return result
+	}
+
+	result.Success = true
+	result.Serial = signedAssertion.HeaderString("serial")
+	result.Assertion = string(asserts.Encode(signedAssertion))
+	return result
+}
+
+// parseBatchAssertionStream decodes the serial-requests from the request body. A multipart
+// body holds one serial-request, with its optional model and serial, per part. Any other
+// body is a single stream of assertions, with each serial-request starting a new item
+func parseBatchAssertionStream(r *http.Request) ([]batchItem, response.ErrorResponse) {
+	defer r.Body.Close()
+
+	var items []batchItem
+	var errResponse response.ErrorResponse
+
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err == nil && strings.HasPrefix(mediaType, "multipart/") {
+		items, errResponse = decodeMultipartStream(r)
+	} else {
+		items, errResponse = splitAssertionStream(asserts.NewDecoder(r.Body))
+	}
+	if !errResponse.Success {
+		return nil, errResponse
+	}
+
+	if len(items) == 0 {
+		svlog.Message("SIGN", "invalid-assertion", response.ErrorEmptyData.Message)
+		return nil, response.ErrorEmptyData
+	}
+	if len(items) > maxBatchSize {
+		svlog.Message("SIGN", response.ErrorBatchTooLarge.Code, response.ErrorBatchTooLarge.Message)
+		return nil, response.ErrorBatchTooLarge
+	}
+
+	return items, response.ErrorResponse{Success: true}
+}
+
+func decodeMultipartStream(r *http.Request) ([]batchItem, response.ErrorResponse) {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		svlog.Message("SIGN", response.ErrorInvalidData.Code, err.Error())
+		return nil, response.ErrorResponse{Success: false, Code: response.ErrorInvalidData.Code, Message: err.Error(), StatusCode: http.StatusBadRequest}
+	}
+
+	items := []batchItem{}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			svlog.Message("SIGN", response.ErrorInvalidData.Code, err.Error())
+			return nil, response.ErrorResponse{Success: false, Code: response.ErrorInvalidData.Code, Message: err.Error(), StatusCode: http.StatusBadRequest}
+		}
+
+		// A badly formed part only fails its own item
+		assertions, errResponse := decodeAssertionStream(asserts.NewDecoder(part))
+		part.Close()
+		items = append(items, batchItem{assertions: assertions, errResponse: errResponse})
+
+		if len(items) > maxBatchSize {
+			break
+		}
+	}
+
+	return items, response.ErrorResponse{Success: true}
+}
+
+// splitAssertionStream groups a stream of assertions by serial-request. The model and
+// serial assertions belong to the serial-request that precedes them
+func splitAssertionStream(dec *asserts.Decoder) ([]batchItem, response.ErrorResponse) {
+	items := []batchItem{}
+
+	for {
+		assertion, err := dec.Decode()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			svlog.Message("SIGN", "invalid-assertion", err.Error())
+			return nil, response.ErrorResponse{Success: false, Code: response.ErrorInvalidAssertion.Code, Message: err.Error(), StatusCode: http.StatusBadRequest}
+		}
+
+		switch assertion.Type() {
+		case asserts.SerialRequestType:
+			items = append(items, batchItem{
+				assertions:  map[string]asserts.Assertion{"serial-request": assertion},
+				errResponse: response.ErrorResponse{Success: true},
+			})
+			if len(items) > maxBatchSize {
+				return items, response.ErrorResponse{Success: true}
+			}
+
+		case asserts.ModelType, asserts.SerialType:
+			if len(items) == 0 {
+				svlog.Message("SIGN", response.ErrorInvalidType.Code, "The assertion type must be 'serial-request'")
+				return nil, response.ErrorInvalidType
+			}
+
+			// The model must come before the serial, and neither may be repeated
+			current := items[len(items)-1].assertions
+			_, hasSerial := current["serial"]
+			if _, ok := current[assertion.Type().Name]; ok || (assertion.Type() == asserts.ModelType && hasSerial) {
+				msg := fmt.Sprintf("unexpected %s assertion in the request stream", assertion.Type().Name)
+				svlog.Message("SIGN", response.ErrorInvalidAssertion.Code, msg)
+				return nil, response.ErrorResponse{Success: false, Code: response.ErrorInvalidAssertion.Code, Message: msg, StatusCode: http.StatusBadRequest}
+			}
+			current[assertion.Type().Name] = assertion
+
+		default:
+			msg := fmt.Sprintf("unexpected %s assertion in the request stream", assertion.Type().Name)
+			svlog.Message("SIGN", response.ErrorInvalidAssertion.Code, msg)
+			return nil, response.ErrorResponse{Success: false, Code: response.ErrorInvalidAssertion.Code, Message: msg, StatusCode: http.StatusBadRequest}
+		}
+	}
+
+	return items, response.ErrorResponse{Success: true}
+}
+
+func formatSerialsResponse(results []SerialResult, w http.ResponseWriter) error {
+	response := SerialsResponse{Success: true, Results: results}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+
+	// Encode the response as JSON
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		svlog.Message("SIGN", "error-encode-json", err.Error())
+		return err
+	}
+	return nil
+}
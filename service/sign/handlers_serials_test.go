@@ -0,0 +1,140 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package sign_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/CanonicalLtd/serial-vault/datastore"
+	"github.com/CanonicalLtd/serial-vault/service"
+	"github.com/CanonicalLtd/serial-vault/service/response"
+	"github.com/CanonicalLtd/serial-vault/service/sign"
+	check "gopkg.in/check.v1"
+)
+
+func joinAssertions(assertions ...[]byte) []byte {
+	return bytes.Join(assertions, []byte("\n"))
+}
+
+func (s *SignSuite) TestSerials(c *check.C) {
+	assert, err := generateSerialRequestAssertion("alder", "A123456L", "")
+	c.Assert(err, check.IsNil)
+	assertSPlusM, err := serialRequestPlusModelAssertion(c)
+	c.Assert(err, check.IsNil)
+	assertFakeModel, err := generateSerialRequestAssertion("invalid", "A123456L", "")
+	c.Assert(err, check.IsNil)
+	model, err := generateModelAssertion("system", "alder", time.Now())
+	c.Assert(err, check.IsNil)
+
+	tooMany := [][]byte{}
+	for i := 0; i < 101; i++ {
+		tooMany = append(tooMany, assert)
+	}
+
+	tests := []SuiteTest{
+		{false, "POST", "/v1/serials", joinAssertions(assert, assertFakeModel, assertSPlusM), 200, response.JSONHeader, "ValidAPIKey"},
+		{false, "POST", "/v1/serials", joinAssertions([]byte(model), assert), 400, response.JSONHeader, "ValidAPIKey"},
+		{false, "POST", "/v1/serials", joinAssertions(assertSPlusM, []byte(model)), 400, response.JSONHeader, "ValidAPIKey"},
+		{false, "POST", "/v1/serials", joinAssertions(assert, []byte(badSerialRequest)), 400, response.JSONHeader, "ValidAPIKey"},
+		{false, "POST", "/v1/serials", joinAssertions(tooMany...), 400, response.JSONHeader, "ValidAPIKey"},
+		{false, "POST", "/v1/serials", []byte(""), 400, response.JSONHeader, "ValidAPIKey"},
+		{false, "POST", "/v1/serials", assert, 400, response.JSONHeader, "InvalidAPIKey"},
+		{true, "POST", "/v1/serials", assert, 200, response.JSONHeader, "ValidAPIKey"},
+	}
+
+	for _, t := range tests {
+		if t.MockError {
+			datastore.Environ.DB = &datastore.ErrorMockDB{}
+		}
+
+		w := sendRequest(t.Method, t.URL, bytes.NewReader(t.Data), t.APIKey, c)
+		c.Assert(w.Code, check.Equals, t.Code)
+		c.Assert(w.Header().Get("Content-Type"), check.Equals, t.Type)
+
+		datastore.Environ.DB = &datastore.MockDB{}
+	}
+}
+
+func (s *SignSuite) TestSerialsResults(c *check.C) {
+	assert, err := generateSerialRequestAssertion("alder", "A123456L", "")
+	c.Assert(err, check.IsNil)
+	assertFakeModel, err := generateSerialRequestAssertion("invalid", "B123456L", "")
+	c.Assert(err, check.IsNil)
+	assertSPlusM, err := serialRequestPlusModelAssertion(c)
+	c.Assert(err, check.IsNil)
+
+	w := sendRequest("POST", "/v1/serials", bytes.NewReader(joinAssertions(assert, assertFakeModel, assertSPlusM)), "ValidAPIKey", c)
+	c.Assert(w.Code, check.Equals, 200)
+
+	result := sign.SerialsResponse{}
+	err = json.NewDecoder(w.Body).Decode(&result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result.Success, check.Equals, true)
+	c.Assert(result.Results, check.HasLen, 3)
+
+	c.Assert(result.Results[0].Success, check.Equals, true)
+	c.Assert(result.Results[0].Serial, check.Equals, "A123456L")
+	c.Assert(result.Results[0].Assertion, check.Matches, "(?s)type: serial\n.*")
+
+	c.Assert(result.Results[1].Success, check.Equals, false)
+	c.Assert(result.Results[1].Serial, check.Equals, "B123456L")
+	c.Assert(result.Results[1].ErrorCode, check.Equals, response.ErrorInvalidModel.Code)
+	c.Assert(result.Results[1].Assertion, check.Equals, "")
+
+	c.Assert(result.Results[2].Success, check.Equals, true)
+}
+
+func (s *SignSuite) TestSerialsMultipart(c *check.C) {
+	assert, err := generateSerialRequestAssertion("alder", "A123456L", "")
+	c.Assert(err, check.IsNil)
+	assertSPlusM, err := serialRequestPlusModelAssertion(c)
+	c.Assert(err, check.IsNil)
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	for _, data := range [][]byte{assert, []byte(badSerialRequest), assertSPlusM} {
+		part, err := writer.CreatePart(nil)
+		c.Assert(err, check.IsNil)
+		part.Write(data)
+	}
+	c.Assert(writer.Close(), check.IsNil)
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("POST", "/v1/serials", body)
+	r.Header.Set("api-key", "ValidAPIKey")
+	r.Header.Set("Content-Type", "multipart/mixed; boundary="+writer.Boundary())
+	service.SigningRouter().ServeHTTP(w, r)
+	c.Assert(w.Code, check.Equals, 200)
+
+	result := sign.SerialsResponse{}
+	err = json.NewDecoder(w.Body).Decode(&result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result.Results, check.HasLen, 3)
+	c.Assert(result.Results[0].Success, check.Equals, true)
+	c.Assert(result.Results[1].Success, check.Equals, false)
+	c.Assert(result.Results[1].ErrorCode, check.Equals, response.ErrorInvalidAssertion.Code)
+	c.Assert(result.Results[2].Success, check.Equals, true)
+}
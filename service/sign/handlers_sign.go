@@ -25,7 +25,10 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/CanonicalLtd/serial-vault/service/log"
@@ -34,6 +37,7 @@ import (
 	svlog "github.com/CanonicalLtd/serial-vault/service/log"
 	"github.com/CanonicalLtd/serial-vault/service/request"
 	"github.com/CanonicalLtd/serial-vault/service/response"
+	"github.com/CanonicalLtd/serial-vault/service/revocation"
 	"github.com/snapcore/snapd/asserts"
 	"gopkg.in/yaml.v2"
 )
@@ -55,12 +59,6 @@ func RequestID(w http.ResponseWriter, r *http.Request) response.ErrorResponse {
 		return response.ErrorInvalidAPIKey
 	}
 
-	err = datastore.Environ.DB.DeleteExpiredDeviceNonces()
-	if err != nil {
-		svlog.Message("REQUESTID", "delete-expired-nonces", err.Error())
-		return response.ErrorGenerateNonce
-	}
-
 	nonce, err := datastore.Environ.DB.CreateDeviceNonce()
 	if err != nil {
 		svlog.Message("REQUESTID", "generate-request-id", err.Error())
@@ -74,10 +72,16 @@ func RequestID(w http.ResponseWriter, r *http.Request) response.ErrorResponse {
 
 func parseAssertionStream(r *http.Request) (map[string]asserts.Assertion, response.ErrorResponse) {
 	defer r.Body.Close()
-	assertions := make(map[string]asserts.Assertion)
 
 	// Use snapd assertion module to decode the assertions in the request stream
-	dec := asserts.NewDecoder(r.Body)
+	return decodeAssertionStream(asserts.NewDecoder(r.Body))
+}
+
+// decodeAssertionStream decodes a serial-request assertion, followed by the optional model
+// and serial assertions. The stream must end after the assertions.
+func decodeAssertionStream(dec *asserts.Decoder) (map[string]asserts.Assertion, response.ErrorResponse) {
+	assertions := make(map[string]asserts.Assertion)
+
 	serialRequestAssertion, err := dec.Decode()
 	if err == io.EOF {
 		svlog.Message("SIGN", "invalid-assertion", response.ErrorEmptyData.Message)
@@ -164,93 +168,281 @@ func Serial(w http.ResponseWriter, r *http.Request) response.ErrorResponse {
 		return errResponse
 	}
 
-	serialReq, ok := assertions["serial-request"].(*asserts.SerialRequest)
-	if !ok {
-		msg := fmt.Sprintf("expected serial-request, got type %q", serialReq.Type().Name)
-		svlog.Message("SIGN", response.ErrorInvalidAssertion.Code, msg)
-		return response.ErrorResponse{Success: false, Code: response.ErrorInvalidAssertion.Code, Message: msg, StatusCode: http.StatusBadRequest}
+	signedAssertion, errResponse := signSerialRequest(assertions, apiKey)
+	if !errResponse.Success {
+		return errResponse
 	}
 
-	err = asserts.SignatureCheck(serialReq, serialReq.DeviceKey())
-	if err != nil {
-		msg := fmt.Sprintf("could not validate serial-request self-signature (%s)", err)
-		svlog.Message("SIGN", response.ErrorInvalidAssertion.Code, msg)
-		return response.ErrorResponse{Success: false, Code: response.ErrorInvalidAssertion.Code, Message: msg, StatusCode: http.StatusBadRequest}
+	// Add the cached account and account-key assertions of the signing-key, when they are requested
+	signedAssertions := []asserts.Assertion{}
+	if chainRequested(r) {
+		signedAssertions = fetchCachedAssertions(signedAssertion)
 	}
 
-	// Double check the model assertion if present
-	modelAssert, ok := assertions["model"]
-	if ok {
-		if modelAssert.HeaderString("brand-id") != serialReq.HeaderString("brand-id") || modelAssert.HeaderString("model") != serialReq.HeaderString("model") {
-			const msg = "Model and serial-request assertion do not match"
-			svlog.Message("SIGN", "mismatched-model", msg)
-			return response.ErrorResponse{Success: false, Code: "mismatched-model", Message: msg, StatusCode: http.StatusBadRequest}
-		}
+	// Add the serial assertion after the account and account-key assertions
+	signedAssertions = append(signedAssertions, signedAssertion)
+
+	// Return successful response with the signed assertions
+	formatSignResponse(signedAssertions, w)
+	return response.ErrorResponse{Success: true}
+}
 
-		// TODO: ideally check the signature of model, need access
-		// to the brand public key(s) for models
+// chainRequested checks if the account and account-key assertions are requested with the serial
+// assertion, using the chain query parameter or the chain parameter of the accepted media type
+func chainRequested(r *http.Request) bool {
+	if chain, err := strconv.ParseBool(r.URL.Query().Get("chain")); err == nil && chain {
+		return true
 	}
 
-	if isRemodelingSerialRequest(serialReq) {
-		serialAssert := assertions["serial"]
-		errResponse := checkRemodelingRequest(serialReq, modelAssert, serialAssert, apiKey)
-		if !errResponse.Success {
-			return errResponse
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(accept)
+		if err != nil || mediaType != asserts.MediaType {
+			continue
 		}
-	} else {
-		// Check the serial assertion
-		if _, ok := assertions["serial"]; ok {
-			const msg = "unexpected assertion in the request stream"
-			svlog.Message("SIGN", response.ErrorInvalidAssertion.Code, msg)
-			return response.ErrorResponse{Success: false, Code: response.ErrorInvalidAssertion.Code, Message: msg, StatusCode: http.StatusBadRequest}
+		if chain, err := strconv.ParseBool(params["chain"]); err == nil && chain {
+			return true
 		}
 	}
+	return false
+}
+
+// fetchCachedAssertions returns the account and account-key assertions of the key that signed
+// the assertion, from the assertions cached in the database
+func fetchCachedAssertions(assertion asserts.Assertion) []asserts.Assertion {
+	assertions := []asserts.Assertion{}
+
+	// Add the account assertion to the assertions list
+	account, err := datastore.Environ.DB.GetAccount(assertion.AuthorityID())
+	appendCachedAssertion(&assertions, asserts.AccountType, account.Assertion, err)
+
+	// Add the account-key assertion to the assertions list
+	keypair, err := datastore.Environ.DB.GetKeypairByPublicID(assertion.AuthorityID(), assertion.SignKeyID())
+	appendCachedAssertion(&assertions, asserts.AccountKeyType, keypair.Assertion, err)
+
+	return assertions
+}
+
+// appendCachedAssertion decodes a cached assertion and adds it to the assertions list. The
+// assertion is skipped when it is not cached, in the same way as the assertions from the store
+func appendCachedAssertion(assertions *[]asserts.Assertion, assertType *asserts.AssertionType, cached string, err error) {
+	if err == nil && len(cached) == 0 {
+		err = fmt.Errorf("the %s assertion is not cached", assertType.Name)
+	}
+
+	var assertion asserts.Assertion
+	if err == nil {
+		assertion, err = asserts.Decode([]byte(cached))
+	}
+	if err == nil && assertion.Type() != assertType {
+		err = fmt.Errorf("expected %s, got type %q", assertType.Name, assertion.Type().Name)
+	}
+
+	if err != nil {
+		svlog.Message("SIGN", "assertion", err.Error())
+		return
+	}
+	*assertions = append(*assertions, assertion)
+}
+
+// signSerialRequest validates a serial-request, with its optional model and serial assertions,
+// and converts it to a signed serial assertion
+func signSerialRequest(assertions map[string]asserts.Assertion, apiKey string) (asserts.Assertion, response.ErrorResponse) {
+	serialReq, errResponse := checkSelfSignature(assertions)
+	if !errResponse.Success {
+		return nil, errResponse
+	}
+
+	// Double check the model assertion if present
+	modelAssert := assertions["model"]
+	errResponse = checkModelAssertion(serialReq, modelAssert)
+	if !errResponse.Success {
+		return nil, errResponse
+	}
+
+	errResponse = checkSerialAssertion(serialReq, modelAssert, assertions["serial"], apiKey)
+	if !errResponse.Success {
+		return nil, errResponse
+	}
 
 	// Verify that the nonce is valid and has not expired
-	err = datastore.Environ.DB.ValidateDeviceNonce(serialReq.HeaderString("request-id"))
+	err := datastore.Environ.DB.ValidateDeviceNonce(serialReq.HeaderString("request-id"))
 	if err != nil {
 		svlog.Message("SIGN", response.ErrorInvalidNonce.Code, response.ErrorInvalidNonce.Message)
-		return response.ErrorInvalidNonce
+		return nil, response.ErrorInvalidNonce
 	}
 
 	// Validate the model by checking that it exists on the database
 	model, errResponse := findModel(serialReq.HeaderString("brand-id"), serialReq.HeaderString("model"), serialReq.HeaderString("serial"), apiKey)
 	if !errResponse.Success {
-		return errResponse
+		return nil, errResponse
 	}
 
 	// Check that the model has an active keypair
 	if !model.KeyActive {
 		svlog.Message("SIGN", response.ErrorInactiveModel.Code, response.ErrorInactiveModel.Message)
-		return response.ErrorInactiveModel
+		return nil, response.ErrorInactiveModel
 	}
 
 	// Create a basic signing log entry (without the serial number)
 	signingLog := datastore.SigningLog{Make: serialReq.HeaderString("brand-id"), Model: serialReq.HeaderString("model"), Fingerprint: serialReq.SignKeyID()}
 
-	// Convert the serial-request headers into a serial assertion
-	serialAssertion, err := serialRequestToSerial(serialReq, &signingLog)
+	// Get the serial-number from the header, but fallback to the body if it is not there
+	serial := requestSerialNumber(serialReq)
+	if len(serial) == 0 {
+		// No serial from the device, so assign the next one if the model allocates serial numbers
+		return signAllocatedSerial(serialReq, model, signingLog)
+	}
+
+	// Check that the serial number is allowed for the model
+	errResponse = checkSerialAllowed(model, serial)
+	if !errResponse.Success {
+		return nil, errResponse
+	}
+	signingLog.SerialNumber = serial
+
+	signedAssertion, errResponse := signSerial(serialReq, model, &signingLog)
+	if !errResponse.Success {
+		return nil, errResponse
+	}
+
+	// Store the serial number and device-key fingerprint in the database
+	err = datastore.Environ.DB.CreateSigningLog(signingLog)
 	if err != nil {
-		svlog.Message("SIGN", response.ErrorCreateAssertion.Code, err.Error())
-		return response.ErrorCreateAssertion
+		svlog.Message("SIGN", "logging-assertion", err.Error())
+		return nil, response.ErrorResponse{Success: false, Code: "logging-assertion", Message: err.Error(), StatusCode: http.StatusBadRequest}
+	}
+
+	return signedAssertion, response.ErrorResponse{Success: true}
+}
+
+// signAllocatedSerial signs a serial-request that does not supply a serial number, allocating the
+// next serial number of the model. The device-key and the signing-key are checked first, and the
+// serial number is allocated in the transaction that stores the signing log, so a rejected
+// request does not use up a serial number
+func signAllocatedSerial(serialReq *asserts.SerialRequest, model datastore.Model, signingLog datastore.SigningLog) (asserts.Assertion, response.ErrorResponse) {
+	// Check that the device-key has not been revoked or already used to sign a device
+	errResponse := revocation.CheckRevoked("SIGN", signingLog.Make, signingLog.Model, "", signingLog.Fingerprint)
+	if !errResponse.Success {
+		return nil, errResponse
+	}
+	_, errResponse = checkDuplicate(model, signingLog)
+	if !errResponse.Success {
+		return nil, errResponse
+	}
+
+	// Check the usage policy of the signing-key, as a rejected assertion is logged on its own
+	err := datastore.Environ.KeypairDB.CheckPolicy(asserts.SerialType, serialHeaders(serialReq), model.AuthorityID, model.KeyID)
+	errResponse = signingError(err)
+	if !errResponse.Success {
+		return nil, errResponse
+	}
+
+	// Allocate the serial number, then sign the assertion and store the signing log in the same transaction
+	var signedAssertion asserts.Assertion
+	serial, err := datastore.Environ.DB.AllocateSerial(model.ID, signingLog, func(signLog *datastore.SigningLog) error {
+		signedAssertion, errResponse = signSerial(serialReq, model, signLog)
+		if !errResponse.Success {
+			return errors.New(errResponse.Message)
+		}
+		return nil
+	})
+	if !errResponse.Success {
+		return nil, errResponse
+	}
+	if err != nil {
+		svlog.Message("SIGN", response.ErrorAllocateSerial.Code, err.Error())
+		return nil, response.ErrorAllocateSerial
+	}
+
+	// Check that we have a serial
+	if len(serial) == 0 {
+		svlog.Message("SIGN", response.ErrorCreateAssertion.Code, response.ErrorEmptySerial.Message)
+		return nil, response.ErrorCreateAssertion
+	}
+
+	return signedAssertion, response.ErrorResponse{Success: true}
+}
+
+// signSerial converts the serial-request to a serial assertion for the serial number of the
+// signing log, and signs it
+func signSerial(serialReq *asserts.SerialRequest, model datastore.Model, signingLog *datastore.SigningLog) (asserts.Assertion, response.ErrorResponse) {
+	// Convert the serial-request headers into a serial assertion
+	serialAssertion, errResponse := serialRequestToSerial(serialReq, model, signingLog)
+	if !errResponse.Success {
+		return nil, errResponse
 	}
 
 	// Sign the assertion with the snapd assertions module
 	signedAssertion, err := datastore.Environ.KeypairDB.SignAssertion(asserts.SerialType, serialAssertion.Headers(), serialAssertion.Body(), model.AuthorityID, model.KeyID, model.SealedKey)
+	errResponse = signingError(err)
+	if !errResponse.Success {
+		return nil, errResponse
+	}
+
+	return signedAssertion, response.ErrorResponse{Success: true}
+}
+
+// signingError converts an error from signing an assertion to the error response
+func signingError(err error) response.ErrorResponse {
+	if policyErr, ok := err.(*datastore.KeypairPolicyError); ok {
+		svlog.Message("SIGN", "keypair-policy", policyErr.Message)
+		return response.PolicyError(policyErr.Reason, policyErr.Message)
+	}
 	if err != nil {
 		svlog.Message("SIGN", "signing-assertion", err.Error())
 		return response.ErrorResponse{Success: false, Code: "signing-assertion", Message: err.Error(), StatusCode: http.StatusBadRequest}
 	}
+	return response.ErrorResponse{Success: true}
+}
 
-	// Store the serial number and device-key fingerprint in the database
-	err = datastore.Environ.DB.CreateSigningLog(signingLog)
+// checkSelfSignature checks that the stream has a serial-request that is signed by the device-key
+func checkSelfSignature(assertions map[string]asserts.Assertion) (*asserts.SerialRequest, response.ErrorResponse) {
+	serialReq, ok := assertions["serial-request"].(*asserts.SerialRequest)
+	if !ok {
+		msg := fmt.Sprintf("expected serial-request, got type %q", serialReq.Type().Name)
+		svlog.Message("SIGN", response.ErrorInvalidAssertion.Code, msg)
+		return nil, response.ErrorResponse{Success: false, Code: response.ErrorInvalidAssertion.Code, Message: msg, StatusCode: http.StatusBadRequest}
+	}
+
+	err := asserts.SignatureCheck(serialReq, serialReq.DeviceKey())
 	if err != nil {
-		svlog.Message("SIGN", "logging-assertion", err.Error())
-		return response.ErrorResponse{Success: false, Code: "logging-assertion", Message: err.Error(), StatusCode: http.StatusBadRequest}
+		msg := fmt.Sprintf("could not validate serial-request self-signature (%s)", err)
+		svlog.Message("SIGN", response.ErrorInvalidAssertion.Code, msg)
+		return nil, response.ErrorResponse{Success: false, Code: response.ErrorInvalidAssertion.Code, Message: msg, StatusCode: http.StatusBadRequest}
+	}
+
+	return serialReq, response.ErrorResponse{Success: true}
+}
+
+// checkModelAssertion checks that the optional model assertion matches the serial-request
+// and is signed by the brand
+func checkModelAssertion(serialReq *asserts.SerialRequest, modelAssert asserts.Assertion) response.ErrorResponse {
+	if modelAssert == nil {
+		return response.ErrorResponse{Success: true}
 	}
 
-	// Return successful JSON response with the signed text
-	formatSignResponse(signedAssertion, w)
+	if modelAssert.HeaderString("brand-id") != serialReq.HeaderString("brand-id") || modelAssert.HeaderString("model") != serialReq.HeaderString("model") {
+		const msg = "Model and serial-request assertion do not match"
+		svlog.Message("SIGN", "mismatched-model", msg)
+		return response.ErrorResponse{Success: false, Code: "mismatched-model", Message: msg, StatusCode: http.StatusBadRequest}
+	}
+
+	// Check the signature of the model against the cached account-key assertions of the brand
+	return checkModelSignature(modelAssert)
+}
+
+// checkSerialAssertion checks the serial assertion of a remodeling request. Other requests
+// must not include a serial assertion
+func checkSerialAssertion(serialReq *asserts.SerialRequest, modelAssert, serialAssert asserts.Assertion, apiKey string) response.ErrorResponse {
+	if isRemodelingSerialRequest(serialReq) {
+		return checkRemodelingRequest(serialReq, modelAssert, serialAssert, apiKey)
+	}
+
+	if serialAssert != nil {
+		const msg = "unexpected assertion in the request stream"
+		svlog.Message("SIGN", response.ErrorInvalidAssertion.Code, msg)
+		return response.ErrorResponse{Success: false, Code: response.ErrorInvalidAssertion.Code, Message: msg, StatusCode: http.StatusBadRequest}
+	}
 	return response.ErrorResponse{Success: true}
 }
 
@@ -279,6 +471,12 @@ func checkRemodelingRequest(serialReq *asserts.SerialRequest, modelAssert, seria
 		return errResponse
 	}
 
+	// Check that the device has not been revoked, before it moves to the new model
+	errResponse = revocation.CheckRevoked("SIGN", originalBrandID, originalModel, originalSerial, serialAssert.HeaderString("device-key-sha3-384"))
+	if !errResponse.Success {
+		return errResponse
+	}
+
 	// Validate the new model: it must be defind in the sub-store of the orignal model
 	substore, err := datastore.Environ.DB.GetSubstore(originalModelAssert.ID, originalSerial)
 	if err != nil {
@@ -341,6 +539,60 @@ func checkRemodelingRequest(serialReq *asserts.SerialRequest, modelAssert, seria
 	return response.ErrorResponse{Success: true}
 }
 
+// checkModelSignature verifies that the model assertion is signed by one of the brand's signing-keys,
+// using the account-key assertion that is cached in the database for the key
+func checkModelSignature(modelAssert asserts.Assertion) response.ErrorResponse {
+	invalidSignature := func(msg string) response.ErrorResponse {
+		svlog.Message("SIGN", response.ErrorInvalidModelSignature.Code, msg)
+		return response.ErrorResponse{Success: false, Code: response.ErrorInvalidModelSignature.Code, Message: msg, StatusCode: http.StatusBadRequest}
+	}
+
+	model, ok := modelAssert.(*asserts.Model)
+	if !ok {
+		return invalidSignature(fmt.Sprintf("expected model, got type %q", modelAssert.Type().Name))
+	}
+
+	// The model must be signed by the brand itself
+	if model.AuthorityID() != model.BrandID() {
+		return invalidSignature("the model assertion must be signed by the brand")
+	}
+
+	// Get the cached account-key assertion for the signing-key of the model
+	keypair, err := datastore.Environ.DB.GetKeypairByPublicID(model.AuthorityID(), model.SignKeyID())
+	if err != nil || len(keypair.Assertion) == 0 {
+		return invalidSignature("cannot find the account-key assertion for the signing-key of the model")
+	}
+
+	assertion, err := asserts.Decode([]byte(keypair.Assertion))
+	if err != nil {
+		return invalidSignature(fmt.Sprintf("cannot decode the account-key assertion (%s)", err))
+	}
+	accountKey, ok := assertion.(*asserts.AccountKey)
+	if !ok {
+		return invalidSignature(fmt.Sprintf("expected account-key, got type %q", assertion.Type().Name))
+	}
+
+	// Check that the account-key belongs to the brand and was valid when the model was signed
+	if accountKey.AccountID() != model.BrandID() || accountKey.PublicKeyID() != model.SignKeyID() {
+		return invalidSignature("the account-key assertion does not match the brand and signing-key of the model")
+	}
+	if model.Timestamp().Before(accountKey.Since()) || (!accountKey.Until().IsZero() && !model.Timestamp().Before(accountKey.Until())) {
+		return invalidSignature("the signing-key of the model was not valid at the time the model was signed")
+	}
+
+	publicKey, err := asserts.DecodePublicKey(accountKey.Body())
+	if err != nil {
+		return invalidSignature(fmt.Sprintf("cannot decode the public key of the account-key assertion (%s)", err))
+	}
+
+	err = asserts.SignatureCheck(model, publicKey)
+	if err != nil {
+		return invalidSignature(fmt.Sprintf("could not validate the model signature (%s)", err))
+	}
+
+	return response.ErrorResponse{Success: true}
+}
+
 // findModel finds the model by checking that there is an original or pivoted model
 func findModel(brandID, modelName, serialNumer, apiKey string) (datastore.Model, response.ErrorResponse) {
 	// Assume this is an original (non-pivoted) serial assertion
@@ -369,73 +621,143 @@ func findModel(brandID, modelName, serialNumer, apiKey string) (datastore.Model,
 	return substore.FromModel, response.ErrorResponse{Success: true}
 }
 
-// serialRequestToSerial converts a serial-request to a serial assertion
-func serialRequestToSerial(assertion asserts.Assertion, signingLog *datastore.SigningLog) (asserts.Assertion, error) {
+// serialRequestToSerial converts a serial-request to a serial assertion for the serial number
+// of the signing log, applying the revocations and the duplicate serial policy of the model
+func serialRequestToSerial(assertion asserts.Assertion, model datastore.Model, signingLog *datastore.SigningLog) (asserts.Assertion, response.ErrorResponse) {
 
 	// Create the serial assertion header from the serial-request headers
+	headers := serialHeaders(assertion)
+	headers["serial"] = signingLog.SerialNumber
+
+	// Check that neither the device-key nor the serial number have been revoked
+	errResponse := revocation.CheckRevoked("SIGN", signingLog.Make, signingLog.Model, signingLog.SerialNumber, signingLog.Fingerprint)
+	if !errResponse.Success {
+		return nil, errResponse
+	}
+
+	// Check that we have not already signed this device, and get the max. revision number for the serial number
+	maxRevision, errResponse := checkDuplicate(model, *signingLog)
+	if !errResponse.Success {
+		return nil, errResponse
+	}
+
+	// Set the revision number, incrementing the previously used one
+	signingLog.Revision = maxRevision + 1
+	headers["revision"] = fmt.Sprintf("%d", signingLog.Revision)
+
+	// If we have a body, set the body length
+	if len(assertion.Body()) > 0 {
+		headers["body-length"] = assertion.HeaderString("body-length")
+	}
+
+	// Create a new serial assertion
+	content, signature := assertion.Signature()
+	serialAssertion, err := asserts.Assemble(headers, assertion.Body(), content, signature)
+	if err != nil {
+		svlog.Message("SIGN", response.ErrorCreateAssertion.Code, err.Error())
+		return nil, response.ErrorCreateAssertion
+	}
+	return serialAssertion, response.ErrorResponse{Success: true}
+}
+
+// serialHeaders returns the headers of the serial assertion from the serial-request headers,
+// without the serial number and revision
+func serialHeaders(assertion asserts.Assertion) map[string]interface{} {
 	serialHeaders := assertion.Headers()
-	headers := map[string]interface{}{
+	return map[string]interface{}{
 		"type":                asserts.SerialType.Name,
 		"authority-id":        serialHeaders["brand-id"],
 		"brand-id":            serialHeaders["brand-id"],
-		"serial":              serialHeaders["serial"],
 		"device-key":          serialHeaders["device-key"],
 		"sign-key-sha3-384":   serialHeaders["sign-key-sha3-384"],
 		"device-key-sha3-384": serialHeaders["sign-key-sha3-384"],
 		"model":               serialHeaders["model"],
 		"timestamp":           time.Now().Format(time.RFC3339),
 	}
+}
 
-	// Get the serial-number from the header, but fallback to the body if it is not there
-	if headers["serial"] == nil || headers["serial"].(string) == "" {
-		// Decode the body which must be YAML, ignore errors
-		body := make(map[string]interface{})
-		yaml.Unmarshal(assertion.Body(), &body)
-
-		// Get the extra headers from the body
-		headers["serial"] = body["serial"]
+// requestSerialNumber returns the serial number of the serial-request from the header, or from the
+// body when it is not in the header. It is empty if the device does not supply a serial number
+func requestSerialNumber(assertion asserts.Assertion) string {
+	if serial := assertion.HeaderString("serial"); len(serial) > 0 {
+		return serial
 	}
 
-	// Check that we have a serial
-	if headers["serial"] == nil {
-		svlog.Message("SIGN", "create-assertion", response.ErrorEmptySerial.Message)
-		return nil, errors.New(response.ErrorEmptySerial.Message)
+	// Decode the body which must be YAML, ignore errors
+	body := make(map[string]interface{})
+	yaml.Unmarshal(assertion.Body(), &body)
+
+	serial, _ := body["serial"].(string)
+	return serial
+}
+
+// checkSerialAllowed checks that the serial number is in the allowed serial numbers of the model
+func checkSerialAllowed(model datastore.Model, serial string) response.ErrorResponse {
+	allowed, err := datastore.Environ.DB.CheckSerialAllowed(model.ID, serial)
+	if err != nil {
+		svlog.Message("SIGN", response.ErrorCreateAssertion.Code, err.Error())
+		return response.ErrorCreateAssertion
+	}
+	if !allowed {
+		svlog.Message("SIGN", response.ErrorSerialNotAllowed.Code, response.ErrorSerialNotAllowed.Message)
+		return response.ErrorSerialNotAllowed
 	}
+	return response.ErrorResponse{Success: true}
+}
 
-	// Check that we have not already signed this device, and get the max. revision number for the serial number
-	signingLog.SerialNumber = headers["serial"].(string)
-	duplicateExists, maxRevision, err := datastore.Environ.DB.CheckForDuplicate(signingLog)
+// checkDuplicate checks whether the device has already been signed against the duplicate policy
+// of the model, and returns the max. revision number for the serial number
+func checkDuplicate(model datastore.Model, signingLog datastore.SigningLog) (int, response.ErrorResponse) {
+	duplicateExists, maxRevision, err := datastore.Environ.DB.CheckForDuplicate(&signingLog)
 	if err != nil {
 		svlog.Message("SIGN", "duplicate-assertion", err.Error())
-		return nil, errors.New(response.ErrorDuplicateAssertion.Message)
+		return 0, response.ErrorCreateAssertion
 	}
 	if duplicateExists {
 		svlog.Message("SIGN", "duplicate-assertion", "The serial number and/or device-key have already been used to sign a device")
-	}
 
-	// Set the revision number, incrementing the previously used one
-	signingLog.Revision = maxRevision + 1
-	headers["revision"] = fmt.Sprintf("%d", signingLog.Revision)
+		errResponse := checkDuplicatePolicy(model, signingLog)
+		if !errResponse.Success {
+			return 0, errResponse
+		}
+	}
+	return maxRevision, response.ErrorResponse{Success: true}
+}
 
-	// If we have a body, set the body length
-	if len(assertion.Body()) > 0 {
-		headers["body-length"] = serialHeaders["body-length"]
+// checkDuplicatePolicy decides whether a duplicate serial-request can be signed for the model
+func checkDuplicatePolicy(model datastore.Model, signingLog datastore.SigningLog) response.ErrorResponse {
+	switch model.DuplicatePolicy {
+	case datastore.DuplicateReject:
+		svlog.Message("SIGN", response.ErrorDuplicateSerial.Code, response.ErrorDuplicateSerial.Message)
+		return response.ErrorDuplicateSerial
+
+	case datastore.DuplicateRejectDeviceKey:
+		changed, err := datastore.Environ.DB.CheckForDeviceKeyChange(signingLog)
+		if err != nil {
+			svlog.Message("SIGN", "duplicate-assertion", err.Error())
+			return response.ErrorCreateAssertion
+		}
+		if changed {
+			svlog.Message("SIGN", response.ErrorDuplicateDeviceKey.Code, response.ErrorDuplicateDeviceKey.Message)
+			return response.ErrorDuplicateDeviceKey
+		}
 	}
 
-	// Create a new serial assertion
-	content, signature := assertion.Signature()
-	return asserts.Assemble(headers, assertion.Body(), content, signature)
+	return response.ErrorResponse{Success: true}
 }
 
-func formatSignResponse(assertion asserts.Assertion, w http.ResponseWriter) error {
+func formatSignResponse(assertions []asserts.Assertion, w http.ResponseWriter) error {
 	w.Header().Set("Content-Type", asserts.MediaType)
 	w.WriteHeader(http.StatusOK)
 	encoder := asserts.NewEncoder(w)
-	err := encoder.Encode(assertion)
-	if err != nil {
-		// Not much we can do if we're here - apart from panic!
-		svlog.Message("SIGN", "error-encode-assertion", "Error encoding the assertion.")
-		return err
+
+	for _, assertion := range assertions {
+		err := encoder.Encode(assertion)
+		if err != nil {
+			// Not much we can do if we're here - apart from panic!
+			svlog.Message("SIGN", "error-encode-assertion", "Error encoding the assertion.")
+			return err
+		}
 	}
 
 	return nil
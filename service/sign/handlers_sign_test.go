@@ -23,11 +23,14 @@ package sign_test
 import (
 	"bytes"
 	"encoding/base64"
+	"errors"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/CanonicalLtd/serial-vault/config"
 	"github.com/CanonicalLtd/serial-vault/crypt"
@@ -83,6 +86,12 @@ func (s *SignSuite) TestSerial(c *check.C) {
 	c.Assert(err, check.IsNil)
 	assertSPlusM, err := serialRequestPlusModelAssertion(c)
 	c.Assert(err, check.IsNil)
+	assertSPlusForged, err := serialRequestPlusForgedModelAssertion(c)
+	c.Assert(err, check.IsNil)
+	assertSPlusExpiredKey, err := serialRequestPlusExpiredKeyModelAssertion(c)
+	c.Assert(err, check.IsNil)
+	assertSPlusTampered, err := serialRequestPlusTamperedModelAssertion(c)
+	c.Assert(err, check.IsNil)
 	assertSPlusBad, err := serialRequestPlusBadModelAssertion(c)
 	c.Assert(err, check.IsNil)
 	assertSPlusMPlusBad, err := serialRequestPlusModelPlusGarbage(c)
@@ -99,6 +108,26 @@ func (s *SignSuite) TestSerial(c *check.C) {
 	c.Assert(err, check.IsNil)
 	assertSigningLogError, err := generateSerialRequestAssertion("alder", "AsigninglogError", "")
 	c.Assert(err, check.IsNil)
+	assertDisallowed, err := generateSerialRequestAssertion("alder", "Adisallowed", "")
+	c.Assert(err, check.IsNil)
+	assertDisallowedInBody, err := generateSerialRequestAssertion("alder", "", "serial: Adisallowed")
+	c.Assert(err, check.IsNil)
+	assertAllowedError, err := generateSerialRequestAssertion("alder", "AallowedError", "")
+	c.Assert(err, check.IsNil)
+	assertRejectNew, err := generateSerialRequestAssertion("alder-reject", "A123456L", "")
+	c.Assert(err, check.IsNil)
+	assertRejectDuplicate, err := generateSerialRequestAssertion("alder-reject", "AduplicateSameKey", "")
+	c.Assert(err, check.IsNil)
+	assertRejectKeyChanged, err := generateSerialRequestAssertion("alder-reject-devicekey", "Aduplicate", "")
+	c.Assert(err, check.IsNil)
+	assertRejectKeySame, err := generateSerialRequestAssertion("alder-reject-devicekey", "AduplicateSameKey", "")
+	c.Assert(err, check.IsNil)
+	assertRejectKeyError, err := generateSerialRequestAssertion("alder-reject-devicekey", "AduplicateError", "")
+	c.Assert(err, check.IsNil)
+	assertAllocate, err := generateSerialRequestAssertion("alder-allocate", "", "")
+	c.Assert(err, check.IsNil)
+	assertAllocateError, err := generateSerialRequestAssertion("alder-allocate-error", "", "")
+	c.Assert(err, check.IsNil)
 
 	assertionsWithSerial := append(assertSPlusM, []byte("\n"+serial)...)
 
@@ -106,6 +135,9 @@ func (s *SignSuite) TestSerial(c *check.C) {
 		{false, "POST", "/v1/serial", assert, 200, asserts.MediaType, "ValidAPIKey"},
 		{false, "POST", "/v1/serial", assertSerialInBody, 200, asserts.MediaType, "ValidAPIKey"},
 		{false, "POST", "/v1/serial", assertSPlusM, 200, asserts.MediaType, "ValidAPIKey"},
+		{false, "POST", "/v1/serial", assertSPlusForged, 400, response.JSONHeader, "ValidAPIKey"},
+		{false, "POST", "/v1/serial", assertSPlusExpiredKey, 400, response.JSONHeader, "ValidAPIKey"},
+		{false, "POST", "/v1/serial", assertSPlusTampered, 400, response.JSONHeader, "ValidAPIKey"},
 		{false, "POST", "/v1/serial", assertSPlusBad, 400, response.JSONHeader, "ValidAPIKey"},
 		{false, "POST", "/v1/serial", assertSPlusMPlusBad, 400, response.JSONHeader, "ValidAPIKey"},
 		{false, "POST", "/v1/serial", assertSPlusMPlusExtra, 400, response.JSONHeader, "ValidAPIKey"},
@@ -115,6 +147,16 @@ func (s *SignSuite) TestSerial(c *check.C) {
 		{false, "POST", "/v1/serial", assert, 400, response.JSONHeader, "NoModelForApiKey"},
 		{false, "POST", "/v1/serial", assertSigningLogError, 400, response.JSONHeader, "ValidAPIKey"},
 		{false, "POST", "/v1/serial", assertDuplicate, 200, asserts.MediaType, "ValidAPIKey"},
+		{false, "POST", "/v1/serial", assertDisallowed, 400, response.JSONHeader, "ValidAPIKey"},
+		{false, "POST", "/v1/serial", assertDisallowedInBody, 400, response.JSONHeader, "ValidAPIKey"},
+		{false, "POST", "/v1/serial", assertAllowedError, 400, response.JSONHeader, "ValidAPIKey"},
+		{false, "POST", "/v1/serial", assertRejectNew, 200, asserts.MediaType, "ValidAPIKey"},
+		{false, "POST", "/v1/serial", assertRejectDuplicate, 400, response.JSONHeader, "ValidAPIKey"},
+		{false, "POST", "/v1/serial", assertRejectKeyChanged, 400, response.JSONHeader, "ValidAPIKey"},
+		{false, "POST", "/v1/serial", assertRejectKeySame, 200, asserts.MediaType, "ValidAPIKey"},
+		{false, "POST", "/v1/serial", assertRejectKeyError, 400, response.JSONHeader, "ValidAPIKey"},
+		{false, "POST", "/v1/serial", assertAllocate, 200, asserts.MediaType, "ValidAPIKey"},
+		{false, "POST", "/v1/serial", assertAllocateError, 400, response.JSONHeader, "ValidAPIKey"},
 		{false, "POST", "/v1/serial", nil, 400, response.JSONHeader, "ValidAPIKey"},
 		{false, "POST", "/v1/serial", []byte(""), 400, response.JSONHeader, "ValidAPIKey"},
 		{false, "POST", "/v1/serial", assert, 400, response.JSONHeader, "InvalidAPIKey"},
@@ -139,6 +181,128 @@ func (s *SignSuite) TestSerial(c *check.C) {
 	}
 }
 
+func (s *SignSuite) TestSerialAllocated(c *check.C) {
+	assertions, err := generateSerialRequestAssertion("alder-allocate", "", "")
+	c.Assert(err, check.IsNil)
+
+	w := sendRequest("POST", "/v1/serial", bytes.NewReader(assertions), "ValidAPIKey", c)
+	c.Assert(w.Code, check.Equals, 200)
+
+	serialAssert, err := asserts.Decode(w.Body.Bytes())
+	c.Assert(err, check.IsNil)
+	c.Assert(serialAssert.HeaderString("serial"), check.Equals, "A0000018")
+
+	// A serial number supplied by the device is used instead of allocating one
+	assertions, err = generateSerialRequestAssertion("alder-allocate", "A123456L", "")
+	c.Assert(err, check.IsNil)
+
+	w = sendRequest("POST", "/v1/serial", bytes.NewReader(assertions), "ValidAPIKey", c)
+	c.Assert(w.Code, check.Equals, 200)
+
+	serialAssert, err = asserts.Decode(w.Body.Bytes())
+	c.Assert(err, check.IsNil)
+	c.Assert(serialAssert.HeaderString("serial"), check.Equals, "A123456L")
+}
+
+// uncachedAccountMockDB mocks a database without the cached account assertion of the brand
+type uncachedAccountMockDB struct {
+	datastore.MockDB
+}
+
+// GetAccount mocks a missing account
+func (mdb *uncachedAccountMockDB) GetAccount(authorityID string) (datastore.Account, error) {
+	return datastore.Account{}, errors.New("MOCK account not found")
+}
+
+func decodeAssertionTypes(w *httptest.ResponseRecorder, c *check.C) []string {
+	types := []string{}
+	dec := asserts.NewDecoder(w.Body)
+	for {
+		assertion, err := dec.Decode()
+		if err == io.EOF {
+			break
+		}
+		c.Assert(err, check.IsNil)
+		types = append(types, assertion.Type().Name)
+	}
+	return types
+}
+
+func (s *SignSuite) TestSerialChain(c *check.C) {
+	assertions, err := generateSerialRequestAssertion("alder", "A123456L", "")
+	c.Assert(err, check.IsNil)
+
+	// Only the serial assertion is returned by default
+	w := sendRequest("POST", "/v1/serial", bytes.NewReader(assertions), "ValidAPIKey", c)
+	c.Assert(w.Code, check.Equals, 200)
+	c.Assert(decodeAssertionTypes(w, c), check.DeepEquals, []string{"serial"})
+
+	// The chain is requested with the query parameter
+	w = sendRequest("POST", "/v1/serial?chain=true", bytes.NewReader(assertions), "ValidAPIKey", c)
+	c.Assert(w.Code, check.Equals, 200)
+	c.Assert(decodeAssertionTypes(w, c), check.DeepEquals, []string{"account", "account-key", "serial"})
+
+	// The chain is requested with the Accept header
+	w = httptest.NewRecorder()
+	r, _ := http.NewRequest("POST", "/v1/serial", bytes.NewReader(assertions))
+	r.Header.Set("api-key", "ValidAPIKey")
+	r.Header.Set("Accept", "application/json, application/x.ubuntu.assertion; chain=true")
+	service.SigningRouter().ServeHTTP(w, r)
+	c.Assert(w.Code, check.Equals, 200)
+	c.Assert(decodeAssertionTypes(w, c), check.DeepEquals, []string{"account", "account-key", "serial"})
+
+	// The assertions that are not cached are skipped
+	datastore.Environ.DB = &uncachedAccountMockDB{}
+	w = sendRequest("POST", "/v1/serial?chain=true", bytes.NewReader(assertions), "ValidAPIKey", c)
+	c.Assert(w.Code, check.Equals, 200)
+	c.Assert(decodeAssertionTypes(w, c), check.DeepEquals, []string{"account-key", "serial"})
+}
+
+func (s *SignSuite) TestSerialRevoked(c *check.C) {
+	// The serial number is revoked for the brand and model
+	assertions, err := generateSerialRequestAssertion("alder", "R123456L", "")
+	c.Assert(err, check.IsNil)
+
+	w := sendRequest("POST", "/v1/serial", bytes.NewReader(assertions), "ValidAPIKey", c)
+	c.Assert(w.Code, check.Equals, 400)
+	result, err := response.ParseStandardResponse(w)
+	c.Assert(err, check.IsNil)
+	c.Assert(result.ErrorCode, check.Equals, response.ErrorRevokedSerial.Code)
+
+	// The device-key is revoked, whatever the serial number
+	signingKey, err := ioutil.ReadFile("../../keystore/TestKey.asc")
+	c.Assert(err, check.IsNil)
+	privateKey, _, err := crypt.DeserializePrivateKey(base64.StdEncoding.EncodeToString(signingKey))
+	c.Assert(err, check.IsNil)
+	encodedPubKey, err := asserts.EncodePublicKey(privateKey.PublicKey())
+	c.Assert(err, check.IsNil)
+	headers := map[string]interface{}{
+		"brand-id":   "system",
+		"device-key": string(encodedPubKey),
+		"request-id": "REQID",
+		"model":      "alder",
+		"serial":     "A123456L",
+	}
+	sreq, err := asserts.SignWithoutAuthority(asserts.SerialRequestType, headers, nil, privateKey)
+	c.Assert(err, check.IsNil)
+
+	w = sendRequest("POST", "/v1/serial", bytes.NewReader(asserts.Encode(sreq)), "ValidAPIKey", c)
+	c.Assert(w.Code, check.Equals, 400)
+	result, err = response.ParseStandardResponse(w)
+	c.Assert(err, check.IsNil)
+	c.Assert(result.ErrorCode, check.Equals, response.ErrorRevokedDeviceKey.Code)
+
+	// Error checking the revocations
+	assertions, err = generateSerialRequestAssertion("alder", "E123456L", "")
+	c.Assert(err, check.IsNil)
+
+	w = sendRequest("POST", "/v1/serial", bytes.NewReader(assertions), "ValidAPIKey", c)
+	c.Assert(w.Code, check.Equals, 400)
+	result, err = response.ParseStandardResponse(w)
+	c.Assert(err, check.IsNil)
+	c.Assert(result.ErrorCode, check.Equals, response.ErrorCheckRevocation.Code)
+}
+
 func (s *SignSuite) TestRequestIDHandler(c *check.C) {
 	tests := []SuiteTest{
 		{false, "POST", "/v1/request-id", nil, 200, response.JSONHeader, "InbuiltAPIKey"},
@@ -192,6 +356,27 @@ func generateSerialRequestAssertion(model, serial, body string) ([]byte, error)
 	return asserts.Encode(sreq), nil
 }
 
+func generateModelAssertion(brandID, model string, timestamp time.Time) (string, error) {
+	headers := map[string]interface{}{
+		"authority-id": brandID,
+		"series":       "16",
+		"brand-id":     brandID,
+		"model":        model,
+		"architecture": "amd64",
+		"gadget":       model + "-gadget",
+		"kernel":       model + "-linux",
+		"timestamp":    timestamp.Format(time.RFC3339),
+	}
+
+	// Sign the model with the test signing-key from the keystore
+	modelAssert, err := datastore.Environ.KeypairDB.SignAssertion(asserts.ModelType, headers, nil, brandID, "UytTqTvREVhx0tSfYC6KkFHmLWllIIZbQ3NsEG7OARrWuaXSRJyey0vjIQkTEvMO", "")
+	if err != nil {
+		return "", err
+	}
+
+	return string(asserts.Encode(modelAssert)), nil
+}
+
 func serialRequestPlusModelAssertion(c *check.C) ([]byte, error) {
 	// Generate a test serial-request assertion
 	assertions, err := generateSerialRequestAssertion("alder", "A123456L", "")
@@ -199,10 +384,62 @@ func serialRequestPlusModelAssertion(c *check.C) ([]byte, error) {
 		return nil, err
 	}
 
+	model, err := generateModelAssertion("system", "alder", time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	assertions = append(assertions, []byte("\n"+model)...)
+	return assertions, nil
+}
+
+func serialRequestPlusForgedModelAssertion(c *check.C) ([]byte, error) {
+	// Generate a test serial-request assertion
+	assertions, err := generateSerialRequestAssertion("alder", "A123456L", "")
+	if err != nil {
+		return nil, err
+	}
+
+	// The model assertion does not have a valid signature
 	assertions = append(assertions, []byte("\n"+modelAssertion)...)
 	return assertions, nil
 }
 
+func serialRequestPlusTamperedModelAssertion(c *check.C) ([]byte, error) {
+	// Generate a test serial-request assertion
+	assertions, err := generateSerialRequestAssertion("alder", "A123456L", "")
+	if err != nil {
+		return nil, err
+	}
+
+	// The model assertion is modified after it has been signed
+	model, err := generateModelAssertion("system", "alder", time.Now())
+	if err != nil {
+		return nil, err
+	}
+	model = strings.Replace(model, "gadget: alder-gadget", "gadget: other-gadget", 1)
+
+	assertions = append(assertions, []byte("\n"+model)...)
+	return assertions, nil
+}
+
+func serialRequestPlusExpiredKeyModelAssertion(c *check.C) ([]byte, error) {
+	// Generate a test serial-request assertion
+	assertions, err := generateSerialRequestAssertion("alder", "A123456L", "")
+	if err != nil {
+		return nil, err
+	}
+
+	// The model assertion is signed before the account-key is valid
+	model, err := generateModelAssertion("system", "alder", time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		return nil, err
+	}
+
+	assertions = append(assertions, []byte("\n"+model)...)
+	return assertions, nil
+}
+
 func serialRequestPlusBadModelAssertion(c *check.C) ([]byte, error) {
 	// Generate a test serial-request assertion
 	assertions, err := generateSerialRequestAssertion("alder", "A123456L", "")
@@ -263,23 +500,6 @@ timestamp: 2016-01-02T15:04:05Z
 
 AXNpZw==
 `
-const newModelAssertion = `type: model
-authority-id: mybrand
-series: 16
-brand-id: mybrand
-model: alder-mybrand
-display-name: Mybrand
-architecture: amd64
-gadget: mybrand-gadget
-kernel: mybrand-linux
-store: mybrand-store
-body-length: 0
-sign-key-sha3-384: Jv8_JiHiIzJVcO9M55pPdqSDWUvuhfDIBJUS-3VW7F_idjix7Ffn5qMxB21ZQuij
-timestamp: 2018-05-07T14:38:51Z
-
-AXNpZw==
-`
-
 const badSerialRequest = `type: serial-request
 brand-id: System
 device-key:
@@ -581,6 +801,8 @@ func (s *SignSuite) TestRemodeling(c *check.C) {
 	serialAssertions := w.Body.String()
 	serialReq, err = generateSerialRequestAssertionRemodeling("alder-mybrand", "alder", "A123456L", "")
 	c.Assert(err, check.IsNil)
+	newModelAssertion, err := generateModelAssertion("mybrand", "alder-mybrand", time.Now())
+	c.Assert(err, check.IsNil)
 
 	assertionsOK := append(serialReq, []byte("\n"+newModelAssertion)...)
 	assertionsOK = append(assertionsOK, []byte("\n"+serialAssertions)...)
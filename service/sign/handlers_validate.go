@@ -0,0 +1,189 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017-2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package sign
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/CanonicalLtd/serial-vault/datastore"
+	svlog "github.com/CanonicalLtd/serial-vault/service/log"
+	"github.com/CanonicalLtd/serial-vault/service/request"
+	"github.com/CanonicalLtd/serial-vault/service/response"
+	"github.com/CanonicalLtd/serial-vault/service/revocation"
+	"github.com/snapcore/snapd/asserts"
+)
+
+// Results of the serial-request validation checks
+const (
+	CheckPass = "pass"
+	CheckFail = "fail"
+	CheckSkip = "skip"
+)
+
+// ValidationCheck is the result of one of the checks of a serial-request
+type ValidationCheck struct {
+	Name    string `json:"name"`
+	Result  string `json:"result"`
+	Code    string `json:"error_code,omitempty"`
+	SubCode string `json:"error_subcode,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// ValidationResponse is the JSON response of the serial-request validation
+type ValidationResponse struct {
+	Success bool              `json:"success"`
+	Valid   bool              `json:"valid"`
+	Checks  []ValidationCheck `json:"checks"`
+}
+
+// validation collects the results of the checks of a serial-request
+type validation struct {
+	checks []ValidationCheck
+	valid  bool
+}
+
+// run runs a check and records its result. The check is skipped when it depends on a check that failed
+func (v *validation) run(name string, ready bool, check func() (string, response.ErrorResponse)) bool {
+	if !ready {
+		v.checks = append(v.checks, ValidationCheck{Name: name, Result: CheckSkip})
+		return false
+	}
+
+	msg, errResponse := check()
+	if !errResponse.Success {
+		v.valid = false
+		v.checks = append(v.checks, ValidationCheck{Name: name, Result: CheckFail, Code: errResponse.Code, SubCode: errResponse.SubCode, Message: errResponse.Message})
+		return false
+	}
+
+	v.checks = append(v.checks, ValidationCheck{Name: name, Result: CheckPass, Message: msg})
+	return true
+}
+
+// Validate is the API method to check whether a serial-request would be signed, without
+// consuming the nonce or writing to the signing log
+func Validate(w http.ResponseWriter, r *http.Request) response.ErrorResponse {
+	// Check that we have an authorised API key header
+	apiKey, err := request.CheckModelAPI(r)
+	if err != nil {
+		svlog.Message("VALIDATE", response.ErrorInvalidAPIKey.Code, response.ErrorInvalidAPIKey.Message)
+		return response.ErrorInvalidAPIKey
+	}
+
+	v := validateSerialRequest(r, apiKey)
+
+	// Return successful JSON response with the results of the checks
+	formatValidationResponse(v, w)
+	return response.ErrorResponse{Success: true}
+}
+
+// validateSerialRequest runs the checks of the signing of a serial-request, apart from
+// the nonce, without changing the database
+func validateSerialRequest(r *http.Request, apiKey string) validation {
+	v := validation{valid: true}
+
+	var assertions map[string]asserts.Assertion
+	parsed := v.run("assertion-stream", true, func() (string, response.ErrorResponse) {
+		var errResponse response.ErrorResponse
+		assertions, errResponse = parseAssertionStream(r)
+		return "", errResponse
+	})
+
+	var serialReq *asserts.SerialRequest
+	signed := v.run("self-signature", parsed, func() (string, response.ErrorResponse) {
+		var errResponse response.ErrorResponse
+		serialReq, errResponse = checkSelfSignature(assertions)
+		return "", errResponse
+	})
+
+	v.run("model-assertion", signed, func() (string, response.ErrorResponse) {
+		return "", checkModelAssertion(serialReq, assertions["model"])
+	})
+
+	v.run("remodeling", signed, func() (string, response.ErrorResponse) {
+		return "", checkSerialAssertion(serialReq, assertions["model"], assertions["serial"], apiKey)
+	})
+
+	var model datastore.Model
+	found := v.run("model", signed, func() (string, response.ErrorResponse) {
+		var errResponse response.ErrorResponse
+		model, errResponse = findModel(serialReq.HeaderString("brand-id"), serialReq.HeaderString("model"), serialReq.HeaderString("serial"), apiKey)
+		if errResponse.Success && !model.KeyActive {
+			errResponse = response.ErrorInactiveModel
+		}
+		return "", errResponse
+	})
+
+	var signingLog datastore.SigningLog
+	numbered := v.run("serial-number", found, func() (string, response.ErrorResponse) {
+		serial, msg, errResponse := checkSerialNumber(serialReq, model)
+		signingLog = datastore.SigningLog{Make: serialReq.HeaderString("brand-id"), Model: serialReq.HeaderString("model"), SerialNumber: serial, Fingerprint: serialReq.SignKeyID()}
+		return msg, errResponse
+	})
+
+	v.run("revocation", numbered, func() (string, response.ErrorResponse) {
+		return "", revocation.CheckRevoked("VALIDATE", signingLog.Make, signingLog.Model, signingLog.SerialNumber, signingLog.Fingerprint)
+	})
+
+	v.run("duplicate", numbered, func() (string, response.ErrorResponse) {
+		_, errResponse := checkDuplicate(model, signingLog)
+		return "", errResponse
+	})
+
+	return v
+}
+
+// checkSerialNumber checks the serial number of the serial-request against the model. When the
+// device does not supply a serial number, the serial number that would be allocated is returned,
+// without allocating it
+func checkSerialNumber(serialReq *asserts.SerialRequest, model datastore.Model) (string, string, response.ErrorResponse) {
+	serial := requestSerialNumber(serialReq)
+	if len(serial) > 0 {
+		return serial, "", checkSerialAllowed(model, serial)
+	}
+
+	alloc, err := datastore.Environ.DB.GetAllowedSerialAllocation(model.ID, datastore.User{})
+	if err != nil {
+		svlog.Message("VALIDATE", response.ErrorAllocateSerial.Code, err.Error())
+		return "", "", response.ErrorAllocateSerial
+	}
+	if alloc.ModelID == 0 {
+		svlog.Message("VALIDATE", response.ErrorEmptySerial.Code, response.ErrorEmptySerial.Message)
+		return "", "", response.ErrorEmptySerial
+	}
+
+	serial = alloc.Format(alloc.Counter + 1)
+	return serial, fmt.Sprintf("The serial number %s would be allocated", serial), response.ErrorResponse{Success: true}
+}
+
+func formatValidationResponse(v validation, w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", response.JSONHeader)
+	response := ValidationResponse{Success: true, Valid: v.valid, Checks: v.checks}
+
+	// Encode the response as JSON
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		svlog.Message("VALIDATE", "error-validate-response", err.Error())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016-2018 Canonical Ltd
+ * License granted by Canonical Limited
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package sign_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/CanonicalLtd/serial-vault/datastore"
+	"github.com/CanonicalLtd/serial-vault/service/response"
+	"github.com/CanonicalLtd/serial-vault/service/sign"
+	check "gopkg.in/check.v1"
+)
+
+// dryRunMockDB records the database calls that must not be made by the validation
+type dryRunMockDB struct {
+	datastore.MockDB
+	calls []string
+}
+
+// ValidateDeviceNonce records that the nonce is consumed
+func (mdb *dryRunMockDB) ValidateDeviceNonce(nonce string) error {
+	mdb.calls = append(mdb.calls, "ValidateDeviceNonce")
+	return nil
+}
+
+// AllocateSerial records that a serial number is allocated
+func (mdb *dryRunMockDB) AllocateSerial(modelID int, signLog datastore.SigningLog, sign func(signLog *datastore.SigningLog) error) (string, error) {
+	mdb.calls = append(mdb.calls, "AllocateSerial")
+	return "", nil
+}
+
+// CreateSigningLog records that the signing log is written
+func (mdb *dryRunMockDB) CreateSigningLog(signLog datastore.SigningLog) error {
+	mdb.calls = append(mdb.calls, "CreateSigningLog")
+	return nil
+}
+
+func sendValidateRequest(data []byte, apiKey string, c *check.C) (int, sign.ValidationResponse) {
+	w := sendRequest("POST", "/v1/serial/validate", bytes.NewReader(data), apiKey, c)
+
+	result := sign.ValidationResponse{}
+	err := json.NewDecoder(w.Body).Decode(&result)
+	c.Assert(err, check.IsNil)
+	return w.Code, result
+}
+
+func checkResults(c *check.C, result sign.ValidationResponse, expected map[string]string) {
+	c.Assert(result.Checks, check.HasLen, 8)
+	for _, chk := range result.Checks {
+		exp, ok := expected[chk.Name]
+		if !ok {
+			exp = sign.CheckPass
+		}
+		c.Assert(strings.TrimSpace(chk.Result+" "+chk.Code), check.Equals, exp, check.Commentf("check %s", chk.Name))
+	}
+}
+
+func (s *SignSuite) TestValidate(c *check.C) {
+	db := &dryRunMockDB{}
+	datastore.Environ.DB = db
+
+	assertValid, err := generateSerialRequestAssertion("alder", "A123456L", "")
+	c.Assert(err, check.IsNil)
+	assertSPlusM, err := serialRequestPlusModelAssertion(c)
+	c.Assert(err, check.IsNil)
+	assertSPlusForged, err := serialRequestPlusForgedModelAssertion(c)
+	c.Assert(err, check.IsNil)
+	assertNoSerial, err := generateSerialRequestAssertion("alder", "", "")
+	c.Assert(err, check.IsNil)
+	assertNoAllocation, err := generateSerialRequestAssertion("alder-allocate", "", "")
+	c.Assert(err, check.IsNil)
+	assertFakeModel, err := generateSerialRequestAssertion("invalid", "A123456L", "")
+	c.Assert(err, check.IsNil)
+	assertInactive, err := generateSerialRequestAssertion("inactive", "A123456L", "")
+	c.Assert(err, check.IsNil)
+	assertDisallowed, err := generateSerialRequestAssertion("alder", "Adisallowed", "")
+	c.Assert(err, check.IsNil)
+	assertRevoked, err := generateSerialRequestAssertion("alder", "R123456L", "")
+	c.Assert(err, check.IsNil)
+	assertDuplicate, err := generateSerialRequestAssertion("alder-reject", "AduplicateSameKey", "")
+	c.Assert(err, check.IsNil)
+	assertKeyChanged, err := generateSerialRequestAssertion("alder-reject-devicekey", "Aduplicate", "")
+	c.Assert(err, check.IsNil)
+
+	tests := []struct {
+		data     []byte
+		valid    bool
+		expected map[string]string
+	}{
+		{assertValid, true, nil},
+		{assertSPlusM, true, nil},
+		{assertNoSerial, true, nil},
+		{assertSPlusForged, false, map[string]string{"model-assertion": "fail invalid-model-signature"}},
+		{assertNoAllocation, false, map[string]string{"serial-number": "fail create-assertion", "revocation": "skip", "duplicate": "skip"}},
+		{assertFakeModel, false, map[string]string{"model": "fail invalid-model", "serial-number": "skip", "revocation": "skip", "duplicate": "skip"}},
+		{assertInactive, false, map[string]string{"model": "fail invalid-model", "serial-number": "skip", "revocation": "skip", "duplicate": "skip"}},
+		{assertDisallowed, false, map[string]string{"serial-number": "fail serial-not-allowed", "revocation": "skip", "duplicate": "skip"}},
+		{assertRevoked, false, map[string]string{"revocation": "fail revoked-serial"}},
+		{assertDuplicate, false, map[string]string{"duplicate": "fail duplicate-serial"}},
+		{assertKeyChanged, false, map[string]string{"duplicate": "fail duplicate-device-key"}},
+		{[]byte("invalid"), false, map[string]string{"assertion-stream": "fail invalid-assertion", "self-signature": "skip", "model-assertion": "skip", "remodeling": "skip", "model": "skip", "serial-number": "skip", "revocation": "skip", "duplicate": "skip"}},
+	}
+
+	for _, t := range tests {
+		code, result := sendValidateRequest(t.data, "ValidAPIKey", c)
+		c.Assert(code, check.Equals, http.StatusOK)
+		c.Assert(result.Success, check.Equals, true)
+		c.Assert(result.Valid, check.Equals, t.valid)
+		checkResults(c, result, t.expected)
+	}
+
+	// The serial number that would be allocated is reported
+	_, result := sendValidateRequest(assertNoSerial, "ValidAPIKey", c)
+	c.Assert(result.Checks[5].Message, check.Equals, "The serial number A0000430 would be allocated")
+
+	// The validation does not change the database
+	c.Assert(db.calls, check.HasLen, 0)
+}
+
+func (s *SignSuite) TestValidateInvalidAPIKey(c *check.C) {
+	assertions, err := generateSerialRequestAssertion("alder", "A123456L", "")
+	c.Assert(err, check.IsNil)
+
+	w := sendRequest("POST", "/v1/serial/validate", bytes.NewReader(assertions), "InvalidAPIKey", c)
+	c.Assert(w.Code, check.Equals, http.StatusBadRequest)
+	result, err := response.ParseStandardResponse(w)
+	c.Assert(err, check.IsNil)
+	c.Assert(result.ErrorCode, check.Equals, response.ErrorInvalidAPIKey.Code)
+}
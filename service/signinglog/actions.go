@@ -21,7 +21,9 @@ package signinglog
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/CanonicalLtd/serial-vault/service/log"
 
@@ -38,6 +40,7 @@ type ListResponse struct {
 	ErrorMessage string                 `json:"message"`
 	SigningLog   []datastore.SigningLog `json:"logs"`
 	Total        int                    `json:"total_count"`
+	NextCursor   string                 `json:"next_cursor"`
 }
 
 // FiltersResponse is the JSON response from the API Signing Log Filters method
@@ -49,8 +52,20 @@ type FiltersResponse struct {
 	Filters      datastore.SigningLogFilters `json:"filters"`
 }
 
-// listHandler is the API method to fetch the log records from signing
-func listHandler(w http.ResponseWriter, user datastore.User, apiCall bool) {
+// CheckpointsResponse is the JSON response from the API Signing Log Checkpoints method
+type CheckpointsResponse struct {
+	Success      bool                             `json:"success"`
+	ErrorCode    string                           `json:"error_code"`
+	ErrorSubcode string                           `json:"error_subcode"`
+	ErrorMessage string                           `json:"message"`
+	KeyID        string                           `json:"key_id"`
+	PublicKey    string                           `json:"public_key"`
+	Checkpoints  []datastore.SigningLogCheckpoint `json:"checkpoints"`
+}
+
+// listForAccountHandler is the API method to fetch a page of the log records from signing for an
+// account, or for all the accounts of the user when no account is given
+func listForAccountHandler(w http.ResponseWriter, user datastore.User, apiCall bool, authorityID string, params *datastore.SigningLogParams) {
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 
 	err := auth.CheckUserPermissions(user, datastore.Admin, apiCall)
@@ -59,36 +74,75 @@ func listHandler(w http.ResponseWriter, user datastore.User, apiCall bool) {
 		return
 	}
 
-	logs, err := datastore.Environ.DB.ListAllowedSigningLog(user)
+	page, err := datastore.Environ.DB.ListAllowedSigningLogForAccount(user, authorityID, params)
 	if err != nil {
 		response.FormatStandardResponse(false, "error-fetch-signinglog", "", err.Error(), w)
 		return
 	}
 
-	// Return successful JSON response with the list of models
+	// Return successful JSON response with the page of logs
 	w.WriteHeader(http.StatusOK)
-	formatListResponse(true, "", "", "", logs, w)
+	formatListResponse(true, "", "", "", page, w)
 }
 
-// listForAccountHandler is the API method to fetch the log records from signing for an account
-func listForAccountHandler(w http.ResponseWriter, user datastore.User, apiCall bool, authorityID string, params *datastore.SigningLogParams) {
-	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+// exportHandler is the API method to stream the log records from signing in a file format. The
+// page of the params is not used, so every matching record is exported
+func exportHandler(w http.ResponseWriter, user datastore.User, apiCall bool, authorityID, format string, params *datastore.SigningLogParams) {
+	err := auth.CheckUserPermissions(user, datastore.Admin, apiCall)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		response.FormatStandardResponse(false, "error-auth", "", "", w)
+		return
+	}
 
+	if err := datastore.ValidExportFormat(format); err != nil {
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		response.FormatStandardResponse(false, "error-signinglog-params", "", err.Error(), w)
+		return
+	}
+
+	contentType := "text/csv; charset=UTF-8"
+	if format == datastore.ExportFormatJSONL {
+		contentType = "application/x-ndjson"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"signinglog.%s\"", format))
+	w.WriteHeader(http.StatusOK)
+
+	// The response has started, so an error can only be logged and the export is cut short
+	count, err := datastore.ExportSigningLog(w, format, user, authorityID, params)
+	if err != nil {
+		log.Printf("Error exporting the signing log after %d records: %v", count, err)
+	}
+}
+
+// checkpointsHandler is the API method to sign the heads of the hash chains of the accounts, or
+// of all the accounts of the user when no account is given
+func checkpointsHandler(w http.ResponseWriter, user datastore.User, apiCall bool, authorityID string) {
 	err := auth.CheckUserPermissions(user, datastore.Admin, apiCall)
 	if err != nil {
 		response.FormatStandardResponse(false, "error-auth", "", "", w)
 		return
 	}
 
-	logs, err := datastore.Environ.DB.ListAllowedSigningLogForAccount(user, authorityID, params)
+	heads, err := datastore.Environ.DB.ListAllowedSigningLogChainHeads(user, authorityID)
 	if err != nil {
 		response.FormatStandardResponse(false, "error-fetch-signinglog", "", err.Error(), w)
 		return
 	}
 
-	// Return successful JSON response with the list of models
+	key, checkpoints, err := datastore.SignSigningLogCheckpoints(heads, time.Now())
+	if err != nil {
+		response.FormatStandardResponse(false, "error-signinglog-checkpoint", "", err.Error(), w)
+		return
+	}
+
+	// Encode the response as JSON
 	w.WriteHeader(http.StatusOK)
-	formatListResponse(true, "", "", "", logs, w)
+	response := CheckpointsResponse{Success: true, KeyID: key.KeyID, PublicKey: key.PublicKey, Checkpoints: checkpoints}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Println("Error forming the signing log checkpoints response.")
+	}
 }
 
 // listFiltersHandler is the API method to fetch the log filter values
@@ -112,12 +166,9 @@ func listFiltersHandler(w http.ResponseWriter, user datastore.User, apiCall bool
 	formatFiltersResponse(true, "", "", "", filters, w)
 }
 
-func formatListResponse(success bool, errorCode, errorSubcode, message string, logs []datastore.SigningLog, w http.ResponseWriter) error {
-	response := ListResponse{Success: success, ErrorCode: errorCode, ErrorSubcode: errorSubcode, ErrorMessage: message, SigningLog: logs}
-
-	if len(logs) > 0 {
-		response.Total = logs[0].Total
-	}
+func formatListResponse(success bool, errorCode, errorSubcode, message string, page datastore.SigningLogPage, w http.ResponseWriter) error {
+	response := ListResponse{Success: success, ErrorCode: errorCode, ErrorSubcode: errorSubcode, ErrorMessage: message,
+		SigningLog: page.Logs, Total: page.Total, NextCursor: page.NextCursor}
 
 	// Encode the response as JSON
 	if err := json.NewEncoder(w).Encode(response); err != nil {
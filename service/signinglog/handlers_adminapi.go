@@ -21,6 +21,7 @@ package signinglog
 
 import (
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 	"strconv"
@@ -42,8 +43,55 @@ func APIList(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	params, err := GetSigningLogParams(r)
+	if err != nil {
+		response.FormatStandardResponse(false, "error-signinglog-params", "", err.Error(), w)
+		return
+	}
+
+	// Call the API with the user
+	listForAccountHandler(w, user, true, r.URL.Query().Get("account"), params)
+}
+
+// APIExport is the API method to stream the log records from signing as CSV or JSON Lines
+func APIExport(w http.ResponseWriter, r *http.Request) {
+	// Validate the user and API key
+	user, err := request.CheckUserAPI(r)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		response.FormatStandardResponse(false, "error-auth", "", err.Error(), w)
+		return
+	}
+
+	params, err := GetSigningLogParams(r)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		response.FormatStandardResponse(false, "error-signinglog-params", "", err.Error(), w)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = datastore.ExportFormatCSV
+	}
+
+	// Call the API with the user
+	exportHandler(w, user, true, r.URL.Query().Get("account"), format, params)
+}
+
+// APICheckpoints is the API method to fetch the heads of the signing log hash chains, signed by the vault
+func APICheckpoints(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	// Validate the user and API key
+	user, err := request.CheckUserAPI(r)
+	if err != nil {
+		response.FormatStandardResponse(false, "error-auth", "", err.Error(), w)
+		return
+	}
+
 	// Call the API with the user
-	listHandler(w, user, true)
+	checkpointsHandler(w, user, true, r.URL.Query().Get("account"))
 }
 
 // APISyncLog is the API method to sync a factory log to the cloud
@@ -73,7 +121,7 @@ func APISyncLog(w http.ResponseWriter, r *http.Request) {
 }
 
 // GetSigningLogParams parse and set defaults for the search parameters from the request
-func GetSigningLogParams(r *http.Request) *datastore.SigningLogParams {
+func GetSigningLogParams(r *http.Request) (*datastore.SigningLogParams, error) {
 	params := &datastore.SigningLogParams{
 		Limit: datastore.ListSigningLogDefaultLimit,
 	}
@@ -83,9 +131,11 @@ func GetSigningLogParams(r *http.Request) *datastore.SigningLogParams {
 		params.Offset = offset
 	}
 
-	if fetchAll := query.Get("all"); fetchAll == "true" {
-		params.Limit = 0 // Means no limit.
-		params.Offset = 0
+	if limit, err := strconv.ParseUint(query.Get("limit"), 10, 64); err == nil && limit > 0 {
+		params.Limit = limit
+	}
+	if params.Limit > datastore.ListSigningLogMaxLimit {
+		params.Limit = datastore.ListSigningLogMaxLimit
 	}
 
 	if filter := query.Get("filter"); filter != "" {
@@ -93,6 +143,46 @@ func GetSigningLogParams(r *http.Request) *datastore.SigningLogParams {
 	}
 
 	params.Serialnumber = query.Get("serialnumber")
+	params.Fingerprint = query.Get("fingerprint")
+
+	var err error
+	if params.From, err = datastore.ParseDate(query.Get("from")); err != nil {
+		return nil, err
+	}
+	if params.To, err = datastore.ParseDate(query.Get("to")); err != nil {
+		return nil, err
+	}
+
+	if sort := query.Get("sort"); sort != "" {
+		if _, ok := datastore.SigningLogSortFields[sort]; !ok {
+			return nil, fmt.Errorf("invalid sort field: %s", sort)
+		}
+		params.Sort = sort
+	}
+
+	switch query.Get("order") {
+	case "", "desc":
+	case "asc":
+		params.Ascending = true
+	default:
+		return nil, fmt.Errorf("invalid sort order: %s", query.Get("order"))
+	}
+
+	switch query.Get("synced") {
+	case "":
+	case "true", "false":
+		synced := query.Get("synced") == "true"
+		params.Synced = &synced
+	default:
+		return nil, fmt.Errorf("invalid synced state: %s", query.Get("synced"))
+	}
+
+	if cursor := query.Get("cursor"); cursor != "" {
+		if params.Cursor, err = datastore.DecodeSigningLogCursor(params.Sort, cursor); err != nil {
+			return nil, err
+		}
+		params.Offset = 0
+	}
 
-	return params
+	return params, nil
 }
@@ -26,6 +26,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"strings"
 	"time"
 
 	"github.com/CanonicalLtd/serial-vault/datastore"
@@ -67,6 +68,75 @@ func (s *SigningLogSuite) TestAPISigningLogHandler(c *check.C) {
 	}
 }
 
+func (s *SigningLogSuite) TestAPIExportHandler(c *check.C) {
+	tests := []struct {
+		url         string
+		permissions int
+		code        int
+		contentType string
+		lines       int
+	}{
+		{"/api/signinglog/export", datastore.Admin, 200, "text/csv; charset=UTF-8", 5},
+		{"/api/signinglog/export?format=jsonl&account=system&synced=false", datastore.Admin, 200, "application/x-ndjson", 4},
+		{"/api/signinglog/export?format=xml", datastore.Admin, 400, "application/json; charset=UTF-8", 1},
+		{"/api/signinglog/export?synced=maybe", datastore.Admin, 400, "application/json; charset=UTF-8", 1},
+		{"/api/signinglog/export", datastore.Standard, 400, "application/json; charset=UTF-8", 1},
+		{"/api/signinglog/export", 0, 400, "application/json; charset=UTF-8", 1},
+	}
+
+	datastore.Environ.Config.EnableUserAuth = true
+	defer func() { datastore.Environ.Config.EnableUserAuth = false }()
+
+	for _, t := range tests {
+		w := sendAdminAPIRequest("GET", t.url, nil, t.permissions, c)
+		c.Assert(w.Code, check.Equals, t.code, check.Commentf(t.url))
+		c.Assert(w.Header().Get("Content-Type"), check.Equals, t.contentType)
+		c.Assert(strings.Count(w.Body.String(), "\n"), check.Equals, t.lines)
+
+		// The export is streamed through the metrics of the router
+		c.Assert(w.Flushed, check.Equals, t.code == 200, check.Commentf(t.url))
+	}
+}
+
+func (s *SigningLogSuite) TestAPICheckpointsHandler(c *check.C) {
+	tests := []struct {
+		url         string
+		permissions int
+		secret      string
+		success     bool
+		errorCode   string
+		seq         int
+	}{
+		{"/api/signinglog/checkpoints", datastore.Admin, "the keystore secret", true, "", 4},
+		{"/api/signinglog/checkpoints?account=System", datastore.Admin, "the keystore secret", true, "", 4},
+		{"/api/signinglog/checkpoints", datastore.Admin, "", false, "error-signinglog-checkpoint", 0},
+		{"/api/signinglog/checkpoints", datastore.Standard, "the keystore secret", false, "error-auth", 0},
+		{"/api/signinglog/checkpoints", 0, "the keystore secret", false, "error-auth", 0},
+	}
+
+	datastore.Environ.Config.EnableUserAuth = true
+	defer func() { datastore.Environ.Config.EnableUserAuth = false }()
+
+	for _, t := range tests {
+		datastore.Environ.Config.KeyStoreSecret = t.secret
+
+		w := sendAdminAPIRequest("GET", t.url, nil, t.permissions, c)
+		result := signinglog.CheckpointsResponse{}
+		err := json.NewDecoder(w.Body).Decode(&result)
+		c.Assert(err, check.IsNil)
+		c.Assert(result.Success, check.Equals, t.success, check.Commentf(t.url))
+		c.Assert(result.ErrorCode, check.Equals, t.errorCode)
+		if !t.success {
+			continue
+		}
+
+		c.Assert(result.Checkpoints, check.HasLen, 1)
+		c.Assert(result.Checkpoints[0].Seq, check.Equals, t.seq)
+		c.Assert(result.Checkpoints[0].KeyID, check.Equals, result.KeyID)
+		c.Assert(datastore.VerifySigningLogCheckpoint(result.PublicKey, result.Checkpoints[0]), check.IsNil)
+	}
+}
+
 func sendAdminAPIRequest(method, url string, data io.Reader, permissions int, c *check.C) *httptest.ResponseRecorder {
 	w := httptest.NewRecorder()
 	r, _ := http.NewRequest(method, url, data)
@@ -129,15 +199,56 @@ func (s *SigningLogSuite) TestGetSigningLogParams(c *check.C) {
 			name: "case 5",
 			url:  `/ping?all=true`,
 			want: &datastore.SigningLogParams{
-				Limit: 0,
+				Limit: datastore.ListSigningLogDefaultLimit,
+			},
+		},
+		{
+			name: "case 6",
+			url:  `/ping?limit=100000`,
+			want: &datastore.SigningLogParams{
+				Limit: datastore.ListSigningLogMaxLimit,
 			},
 		},
 	}
 	for _, tt := range tests {
 		r, _ := http.NewRequest("GET", tt.url, nil)
 
-		if got := signinglog.GetSigningLogParams(r); !reflect.DeepEqual(got, tt.want) {
+		got, err := signinglog.GetSigningLogParams(r)
+		c.Assert(err, check.IsNil)
+		if !reflect.DeepEqual(got, tt.want) {
 			c.Errorf("getSigningLogParams() = %#v, want %#v", got, tt.want)
 		}
 	}
 }
+
+func (s *SigningLogSuite) TestGetSigningLogParamsSearch(c *check.C) {
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, 2, 1, 12, 30, 0, 0, time.UTC)
+	cursor := datastore.EncodeSigningLogCursor("serialnumber", datastore.SigningLog{ID: 7, SerialNumber: "R123"})
+
+	r, _ := http.NewRequest("GET", "/ping?offset=10&limit=20&fingerprint=abc&from=2026-01-01&to=2026-02-01T12:30:00Z&sort=serialnumber&order=asc&cursor="+cursor, nil)
+	got, err := signinglog.GetSigningLogParams(r)
+	c.Assert(err, check.IsNil)
+	c.Assert(got, check.DeepEquals, &datastore.SigningLogParams{
+		Limit:       20,
+		Fingerprint: "abc",
+		From:        &from,
+		To:          &to,
+		Sort:        "serialnumber",
+		Ascending:   true,
+		Cursor:      &datastore.SigningLogCursor{Sort: "serialnumber", Value: "R123", ID: 7},
+	})
+
+	for _, url := range []string{
+		"/ping?from=yesterday",
+		"/ping?to=2026-13-01",
+		"/ping?sort=fingerprint",
+		"/ping?order=up",
+		"/ping?cursor=invalid",
+		"/ping?sort=created&cursor=" + cursor,
+	} {
+		r, _ := http.NewRequest("GET", url, nil)
+		_, err := signinglog.GetSigningLogParams(r)
+		c.Assert(err, check.NotNil, check.Commentf(url))
+	}
+}
@@ -22,6 +22,7 @@ package signinglog
 import (
 	"net/http"
 
+	"github.com/CanonicalLtd/serial-vault/datastore"
 	"github.com/CanonicalLtd/serial-vault/service/auth"
 	"github.com/CanonicalLtd/serial-vault/service/response"
 	"github.com/gorilla/mux"
@@ -35,7 +36,13 @@ func List(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	listHandler(w, authUser, false)
+	params, err := GetSigningLogParams(r)
+	if err != nil {
+		response.FormatStandardResponse(false, "error-signinglog-params", "", err.Error(), w)
+		return
+	}
+
+	listForAccountHandler(w, authUser, false, r.URL.Query().Get("account"), params)
 }
 
 // ListForAccount is the API method to fetch the log records from signing for an account
@@ -47,11 +54,40 @@ func ListForAccount(w http.ResponseWriter, r *http.Request) {
 	}
 
 	vars := mux.Vars(r)
-	params := GetSigningLogParams(r)
+	params, err := GetSigningLogParams(r)
+	if err != nil {
+		response.FormatStandardResponse(false, "error-signinglog-params", "", err.Error(), w)
+		return
+	}
 
 	listForAccountHandler(w, authUser, false, vars["authorityID"], params)
 }
 
+// Export is the API method to download the log records from signing for an account as CSV or JSON Lines
+func Export(w http.ResponseWriter, r *http.Request) {
+	authUser, err := auth.GetUserFromJWT(w, r)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		response.FormatStandardResponse(false, "error-auth", "", err.Error(), w)
+		return
+	}
+
+	vars := mux.Vars(r)
+	params, err := GetSigningLogParams(r)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		response.FormatStandardResponse(false, "error-signinglog-params", "", err.Error(), w)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = datastore.ExportFormatCSV
+	}
+
+	exportHandler(w, authUser, false, vars["authorityID"], format, params)
+}
+
 // ListFilters is the API method to fetch the log filter values
 func ListFilters(w http.ResponseWriter, r *http.Request) {
 	authUser, err := auth.GetUserFromJWT(w, r)
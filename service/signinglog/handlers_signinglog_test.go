@@ -26,6 +26,7 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/CanonicalLtd/serial-vault/config"
@@ -75,6 +76,9 @@ func (s *SigningLogSuite) TestSigningLogHandler(c *check.C) {
 		{"GET", "/v1/signinglog/account/system", nil, 200, "application/json; charset=UTF-8", datastore.Admin, true, true, 4},
 		{"GET", "/v1/signinglog/account/system", nil, 400, "application/json; charset=UTF-8", datastore.Standard, true, false, 0},
 		{"GET", "/v1/signinglog/account/system", nil, 400, "application/json; charset=UTF-8", 0, true, false, 0},
+		{"GET", "/v1/signinglog/account/system?limit=3", nil, 200, "application/json; charset=UTF-8", 0, false, true, 3},
+		{"GET", "/v1/signinglog/account/system?sort=invalid", nil, 400, "application/json; charset=UTF-8", 0, false, false, 0},
+		{"GET", "/v1/signinglog?from=invalid", nil, 400, "application/json; charset=UTF-8", 0, false, false, 0},
 	}
 
 	for _, t := range tests {
@@ -95,6 +99,55 @@ func (s *SigningLogSuite) TestSigningLogHandler(c *check.C) {
 	}
 }
 
+func (s *SigningLogSuite) TestSigningLogPage(c *check.C) {
+	w := sendAdminRequest("GET", "/v1/signinglog/account/system?limit=3&sort=serialnumber", nil, 0, c)
+	c.Assert(w.Code, check.Equals, 200)
+
+	result, err := parseListResponse(w)
+	c.Assert(err, check.IsNil)
+	c.Assert(result.Total, check.Equals, 10)
+	c.Assert(result.SigningLog, check.HasLen, 3)
+
+	cursor, err := datastore.DecodeSigningLogCursor("serialnumber", result.NextCursor)
+	c.Assert(err, check.IsNil)
+	c.Assert(cursor.Value, check.Equals, result.SigningLog[2].SerialNumber)
+
+	// The last page has no cursor
+	w = sendAdminRequest("GET", "/v1/signinglog/account/system?cursor="+result.NextCursor+"&sort=serialnumber", nil, 0, c)
+	c.Assert(w.Code, check.Equals, 200)
+	result, err = parseListResponse(w)
+	c.Assert(err, check.IsNil)
+	c.Assert(result.NextCursor, check.Equals, "")
+}
+
+func (s *SigningLogSuite) TestExportHandler(c *check.C) {
+	tests := []struct {
+		url         string
+		permissions int
+		enableAuth  bool
+		code        int
+		contentType string
+		lines       int
+	}{
+		{"/v1/signinglog/account/system/export", 0, false, 200, "text/csv; charset=UTF-8", 11},
+		{"/v1/signinglog/account/system/export?format=jsonl&serialnumber=R", datastore.Admin, true, 200, "application/x-ndjson", 4},
+		{"/v1/signinglog/account/system/export?format=xml", 0, false, 400, "application/json; charset=UTF-8", 1},
+		{"/v1/signinglog/account/system/export", datastore.Standard, true, 400, "application/json; charset=UTF-8", 1},
+	}
+
+	for _, t := range tests {
+		datastore.Environ.Config.EnableUserAuth = t.enableAuth
+
+		w := sendAdminRequest("GET", t.url, nil, t.permissions, c)
+		c.Assert(w.Code, check.Equals, t.code, check.Commentf(t.url))
+		c.Assert(w.Header().Get("Content-Type"), check.Equals, t.contentType)
+		c.Assert(strings.Count(w.Body.String(), "\n"), check.Equals, t.lines, check.Commentf(t.url))
+		c.Assert(w.Flushed, check.Equals, t.code == 200, check.Commentf(t.url))
+
+		datastore.Environ.Config.EnableUserAuth = false
+	}
+}
+
 func (s *SigningLogSuite) TestSigningLogErrorHandler(c *check.C) {
 	datastore.Environ.DB = &datastore.ErrorMockDB{}
 	tests := []SigningLogTest{
@@ -25,11 +25,12 @@ import (
 	"github.com/CanonicalLtd/serial-vault/datastore"
 	"github.com/CanonicalLtd/serial-vault/service/auth"
 	"github.com/CanonicalLtd/serial-vault/service/log"
+	"github.com/CanonicalLtd/serial-vault/service/operation"
 	"github.com/CanonicalLtd/serial-vault/service/response"
 	"github.com/CanonicalLtd/serial-vault/store"
 )
 
-func keyRegisterHandler(w http.ResponseWriter, user datastore.User, apiCall bool, keyAuth store.KeyRegister) {
+func keyRegisterHandler(w http.ResponseWriter, user datastore.User, apiCall bool, sourceIP string, keyAuth store.KeyRegister) {
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 
 	err := auth.CheckUserPermissions(user, datastore.Admin, apiCall)
@@ -51,8 +52,36 @@ func keyRegisterHandler(w http.ResponseWriter, user datastore.User, apiCall bool
 		return
 	}
 
+	// The requester registers the key once a second Superuser has approved it, as the store
+	// credentials are not kept while the registration is waiting for approval
+	approved := datastore.PendingOperation{}
+	if datastore.DualControl() {
+		approved, err = datastore.Environ.DB.FindApprovedOperation(datastore.OperationRegister, keypair.AuthorityID, keypair.KeyName)
+		if err != nil {
+			log.Message("KEYPAIR", response.ErrorRequestOperation.Code, err.Error())
+			response.FormatStandardResponse(false, response.ErrorRequestOperation.Code, "", err.Error(), w)
+			return
+		}
+		if approved.ID == 0 {
+			operation.RequestApproval(w, user, datastore.PendingOperation{
+				Operation:   datastore.OperationRegister,
+				AuthorityID: keypair.AuthorityID,
+				KeyName:     keypair.KeyName,
+				KeypairID:   keypair.ID,
+			})
+			return
+		}
+		if approved.RequestedBy != user.Username {
+			response.FormatStandardResponse(false, response.ErrorAuth.Code, "", "The registration was approved for another user", w)
+			return
+		}
+	}
+
 	// Register the account key with the store
 	err = store.RegisterKey(keyAuth, keypair)
+	if approved.ID != 0 {
+		datastore.CompleteApprovedOperation(approved, err, user, sourceIP)
+	}
 	if err != nil {
 		log.Message("KEYPAIR", response.ErrorStoreKeypair.Code, err.Error())
 		response.FormatStandardResponse(false, response.ErrorStoreKeypair.Code, "", err.Error(), w)
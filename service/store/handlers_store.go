@@ -26,6 +26,7 @@ import (
 	"github.com/CanonicalLtd/serial-vault/service/log"
 
 	"github.com/CanonicalLtd/serial-vault/service/auth"
+	"github.com/CanonicalLtd/serial-vault/service/request"
 	"github.com/CanonicalLtd/serial-vault/service/response"
 	"github.com/CanonicalLtd/serial-vault/store"
 )
@@ -69,5 +70,5 @@ func KeyRegister(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	keyRegisterHandler(w, authUser, false, keyAuth)
+	keyRegisterHandler(w, authUser, false, request.SourceIP(r), keyAuth)
 }
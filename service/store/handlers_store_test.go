@@ -112,6 +112,48 @@ func (s *StoreSuite) TestStoreHandler(c *check.C) {
 	}
 }
 
+func (s *StoreSuite) TestStoreHandlerDualControl(c *check.C) {
+	datastore.Environ.Config.DualControl = true
+
+	tests := []StoreSuiteTest{
+		// The registration is waiting for approval
+		{validKeyRegister(), 202, false, false, datastore.Admin, true, false},
+		// The registration has been approved
+		{approvedKeyRegister(), 200, false, false, datastore.Admin, true, false},
+		{approvedKeyRegister(), 400, false, true, datastore.Admin, true, false},
+		{validKeyRegister(), 400, true, false, datastore.Admin, true, false},
+		// The requester must be known
+		{validKeyRegister(), 400, false, false, 0, false, false},
+	}
+
+	for _, t := range tests {
+		if t.MockError {
+			datastore.Environ.DB = &datastore.ErrorMockDB{}
+		}
+		if t.MockRegError {
+			store.RegisterKey = mockRegisterKeyError
+		}
+		datastore.Environ.Config.EnableUserAuth = t.EnableAuth
+
+		w := sendAdminRequest("POST", "/v1/keypairs/register", bytes.NewReader(t.Data), t.Permissions, t.SkipJWT, c)
+		c.Assert(w.Code, check.Equals, t.Code)
+
+		datastore.Environ.DB = &datastore.MockDB{}
+		store.RegisterKey = mockRegisterKey
+		datastore.Environ.Config.EnableUserAuth = false
+	}
+}
+
+func approvedKeyRegister() []byte {
+	a := store.KeyRegister{
+		Auth:        store.Auth{Email: "john@example.com", Password: "password", OTP: ""},
+		AuthorityID: "system",
+		KeyName:     "system",
+	}
+	d, _ := json.Marshal(a)
+	return d
+}
+
 func validKeyRegister() []byte {
 	a := store.KeyRegister{
 		Auth:        store.Auth{Email: "john@example.com", Password: "password", OTP: ""},
@@ -72,7 +72,7 @@ func listHandler(w http.ResponseWriter, user datastore.User, apiCall bool, accou
 	formatListResponse(true, "", "", "", stores, w)
 }
 
-func updateHandler(w http.ResponseWriter, user datastore.User, apiCall bool, storeID int, store datastore.Substore) {
+func updateHandler(w http.ResponseWriter, user datastore.User, apiCall bool, sourceIP string, storeID int, store datastore.Substore) {
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 
 	err := auth.CheckUserPermissions(user, datastore.Admin, apiCall)
@@ -86,6 +86,13 @@ func updateHandler(w http.ResponseWriter, user datastore.User, apiCall bool, sto
 		return
 	}
 
+	before, err := datastore.Environ.DB.GetSubstoreByID(storeID)
+	if err != nil {
+		log.Println(err)
+		response.FormatStandardResponse(false, "error-stores-substore", "", err.Error(), w)
+		return
+	}
+
 	err = datastore.Environ.DB.UpdateAllowedSubstore(store, user)
 	if err != nil {
 		log.Println(err)
@@ -93,12 +100,17 @@ func updateHandler(w http.ResponseWriter, user datastore.User, apiCall bool, sto
 		return
 	}
 
+	if s, err := datastore.Environ.DB.GetSubstoreByID(storeID); err == nil {
+		store = s
+	}
+	datastore.RecordAudit(user, sourceIP, datastore.AuditActionUpdate, datastore.AuditEntitySubstore, storeID, before, store)
+
 	// Return successful JSON response
 	w.WriteHeader(http.StatusOK)
 	response.FormatStandardResponse(true, "", "", "", w)
 }
 
-func createHandler(w http.ResponseWriter, user datastore.User, apiCall bool, store datastore.Substore) {
+func createHandler(w http.ResponseWriter, user datastore.User, apiCall bool, sourceIP string, store datastore.Substore) {
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 
 	err := auth.CheckUserPermissions(user, datastore.Admin, apiCall)
@@ -113,6 +125,7 @@ func createHandler(w http.ResponseWriter, user datastore.User, apiCall bool, sto
 		response.FormatStandardResponse(false, "error-stores-json", "", err.Error(), w)
 		return
 	}
+	datastore.RecordAudit(user, sourceIP, datastore.AuditActionCreate, datastore.AuditEntitySubstore, allowedSubstore.ID, nil, allowedSubstore)
 
 	// Return successful JSON response
 	w.WriteHeader(http.StatusOK)
@@ -141,7 +154,7 @@ func formatInstanceResponse(store datastore.Substore, w http.ResponseWriter) err
 	return nil
 }
 
-func deleteHandler(w http.ResponseWriter, user datastore.User, apiCall bool, storeID int) {
+func deleteHandler(w http.ResponseWriter, user datastore.User, apiCall bool, sourceIP string, storeID int) {
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 
 	err := auth.CheckUserPermissions(user, datastore.Admin, apiCall)
@@ -150,12 +163,20 @@ func deleteHandler(w http.ResponseWriter, user datastore.User, apiCall bool, sto
 		return
 	}
 
+	store, err := datastore.Environ.DB.GetSubstoreByID(storeID)
+	if err != nil {
+		log.Println(err)
+		response.FormatStandardResponse(false, "error-deleting-store", "", err.Error(), w)
+		return
+	}
+
 	errorSubcode, err := datastore.Environ.DB.DeleteAllowedSubstore(storeID, user)
 	if err != nil {
 		log.Println(err)
 		response.FormatStandardResponse(false, "error-deleting-store", errorSubcode, err.Error(), w)
 		return
 	}
+	datastore.RecordAudit(user, sourceIP, datastore.AuditActionDelete, datastore.AuditEntitySubstore, storeID, store, nil)
 
 	// Return successful JSON response
 	w.WriteHeader(http.StatusOK)
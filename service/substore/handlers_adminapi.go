@@ -86,7 +86,7 @@ func APIUpdate(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Call the API with the user
-	updateHandler(w, user, true, storeID, store)
+	updateHandler(w, user, true, request.SourceIP(r), storeID, store)
 }
 
 // APICreate is the API method to create a sub-store model
@@ -115,7 +115,7 @@ func APICreate(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Call the API with the user
-	createHandler(w, user, true, store)
+	createHandler(w, user, true, request.SourceIP(r), store)
 }
 
 // APIDelete is the API method to delete a sub-store model
@@ -137,7 +137,7 @@ func APIDelete(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Call the API with the user
-	deleteHandler(w, user, true, storeID)
+	deleteHandler(w, user, true, request.SourceIP(r), storeID)
 }
 
 // APIGet is the API method to get a particular instance of a substore
@@ -115,6 +115,12 @@ func (s *SubstoreSuite) TestAPICreateHandlerReturnSubstore(c *check.C) {
 	c.Assert(result.Substore.ID > 0, check.Equals, true)
 	c.Assert(result.Substore.FromModelID, check.Equals, substoreNew.FromModelID)
 	c.Assert(result.Substore.SerialNumber, check.Equals, substoreNew.SerialNumber)
+
+	// the new substore is recorded in the audit log
+	logs := datastore.Environ.DB.(*datastore.MockDB).AuditLogs
+	c.Assert(logs, check.HasLen, 1)
+	c.Assert(logs[0].Action, check.Equals, datastore.AuditActionCreate)
+	c.Assert(logs[0].Entity, check.Equals, datastore.AuditEntitySubstore)
 }
 
 func (s *SubstoreSuite) TestAPIGetHandler(c *check.C) {
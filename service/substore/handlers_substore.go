@@ -27,6 +27,7 @@ import (
 
 	"github.com/CanonicalLtd/serial-vault/datastore"
 	"github.com/CanonicalLtd/serial-vault/service/auth"
+	"github.com/CanonicalLtd/serial-vault/service/request"
 	"github.com/CanonicalLtd/serial-vault/service/response"
 	"github.com/gorilla/mux"
 )
@@ -84,7 +85,7 @@ func Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	updateHandler(w, authUser, false, storeID, store)
+	updateHandler(w, authUser, false, request.SourceIP(r), storeID, store)
 }
 
 // Create is the API method to create a sub-store model
@@ -113,7 +114,7 @@ func Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	createHandler(w, authUser, false, store)
+	createHandler(w, authUser, false, request.SourceIP(r), store)
 }
 
 // Delete is the API method to delete a sub-store model
@@ -133,5 +134,5 @@ func Delete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	deleteHandler(w, authUser, false, storeID)
+	deleteHandler(w, authUser, false, request.SourceIP(r), storeID)
 }
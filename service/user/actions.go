@@ -98,7 +98,7 @@ func getHandler(w http.ResponseWriter, user datastore.User, apiCall bool, userID
 	formatUserResponse(u, w)
 }
 
-func createHandler(w http.ResponseWriter, authUser datastore.User, apiCall bool, user datastore.User) {
+func createHandler(w http.ResponseWriter, authUser datastore.User, apiCall bool, sourceIP string, user datastore.User) {
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 
 	err := auth.CheckUserPermissions(authUser, datastore.Superuser, apiCall)
@@ -113,6 +113,7 @@ func createHandler(w http.ResponseWriter, authUser datastore.User, apiCall bool,
 		response.FormatStandardResponse(false, "error-creating-user", "", err.Error(), w)
 		return
 	}
+	datastore.RecordAudit(authUser, sourceIP, datastore.AuditActionCreate, datastore.AuditEntityUser, user.ID, nil, user)
 
 	// Return successful JSON response
 	w.WriteHeader(http.StatusOK)
@@ -141,7 +142,7 @@ func formatUserResponse(user datastore.User, w http.ResponseWriter) error {
 	return nil
 }
 
-func updateHandler(w http.ResponseWriter, authUser datastore.User, apiCall bool, user datastore.User) {
+func updateHandler(w http.ResponseWriter, authUser datastore.User, apiCall bool, sourceIP string, user datastore.User) {
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 
 	err := auth.CheckUserPermissions(authUser, datastore.Superuser, apiCall)
@@ -150,6 +151,12 @@ func updateHandler(w http.ResponseWriter, authUser datastore.User, apiCall bool,
 		return
 	}
 
+	before, err := datastore.Environ.DB.GetUser(user.ID)
+	if err != nil {
+		response.FormatStandardResponse(false, "error-get-user", "", err.Error(), w)
+		return
+	}
+
 	err = datastore.Environ.DB.UpdateUser(user)
 	if err != nil {
 		log.Println("Error updating the store:", err)
@@ -157,12 +164,17 @@ func updateHandler(w http.ResponseWriter, authUser datastore.User, apiCall bool,
 		return
 	}
 
+	if u, err := datastore.Environ.DB.GetUser(user.ID); err == nil {
+		user = u
+	}
+	datastore.RecordAudit(authUser, sourceIP, datastore.AuditActionUpdate, datastore.AuditEntityUser, user.ID, before, user)
+
 	// Return successful JSON response
 	w.WriteHeader(http.StatusOK)
 	response.FormatStandardResponse(true, "", "", "", w)
 }
 
-func deleteHandler(w http.ResponseWriter, user datastore.User, apiCall bool, userID int) {
+func deleteHandler(w http.ResponseWriter, user datastore.User, apiCall bool, sourceIP string, userID int) {
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 
 	err := auth.CheckUserPermissions(user, datastore.Superuser, apiCall)
@@ -171,11 +183,18 @@ func deleteHandler(w http.ResponseWriter, user datastore.User, apiCall bool, use
 		return
 	}
 
+	before, err := datastore.Environ.DB.GetUser(userID)
+	if err != nil {
+		response.FormatStandardResponse(false, "error-deleting-user", "", err.Error(), w)
+		return
+	}
+
 	err = datastore.Environ.DB.DeleteUser(userID)
 	if err != nil {
 		response.FormatStandardResponse(false, "error-deleting-user", "", err.Error(), w)
 		return
 	}
+	datastore.RecordAudit(user, sourceIP, datastore.AuditActionDelete, datastore.AuditEntityUser, userID, before, nil)
 
 	// Return successful JSON response
 	w.WriteHeader(http.StatusOK)
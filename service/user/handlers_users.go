@@ -27,6 +27,7 @@ import (
 
 	"github.com/CanonicalLtd/serial-vault/datastore"
 	"github.com/CanonicalLtd/serial-vault/service/auth"
+	"github.com/CanonicalLtd/serial-vault/service/request"
 	"github.com/CanonicalLtd/serial-vault/service/response"
 	"github.com/gorilla/mux"
 )
@@ -104,7 +105,7 @@ func Create(w http.ResponseWriter, r *http.Request) {
 		Accounts: datastore.BuildAccountsFromAuthorityIDs(userRequest.Accounts),
 	}
 
-	createHandler(w, authUser, false, user)
+	createHandler(w, authUser, false, request.SourceIP(r), user)
 }
 
 // Update is the API method to update a user
@@ -151,7 +152,7 @@ func Update(w http.ResponseWriter, r *http.Request) {
 		Accounts: datastore.BuildAccountsFromAuthorityIDs(userRequest.Accounts),
 	}
 
-	updateHandler(w, authUser, false, user)
+	updateHandler(w, authUser, false, request.SourceIP(r), user)
 }
 
 // Delete is the API method to delete a user
@@ -171,7 +172,7 @@ func Delete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	deleteHandler(w, authUser, false, userID)
+	deleteHandler(w, authUser, false, request.SourceIP(r), userID)
 }
 
 // GetOtherAccounts is the API method to retrieve accounts not belonging to the user
@@ -224,6 +224,13 @@ func (s *ServiceSuite) TestUpdateUserHandler(c *check.C) {
 
 	result := s.sendRequestRepliesUser("PUT", "/v1/users/2", bytes.NewReader(data), c)
 	c.Assert(result.Success, check.Equals, true)
+
+	logs := datastore.Environ.DB.(*datastore.MockDB).AuditLogs
+	c.Assert(logs, check.HasLen, 1)
+	c.Assert(logs[0].Action, check.Equals, datastore.AuditActionUpdate)
+	c.Assert(logs[0].Entity, check.Equals, datastore.AuditEntityUser)
+	c.Assert(logs[0].EntityID, check.Equals, 2)
+	c.Assert(logs[0].Role, check.Equals, datastore.Superuser)
 }
 
 func (s *ServiceSuite) TestUpdateUserHandlerWithAccount(c *check.C) {
@@ -292,6 +299,11 @@ func (s *ServiceSuite) TestDeleteUserHandler(c *check.C) {
 	datastore.Environ.DB = &datastore.MockDB{}
 
 	s.sendRequestRepliesUser("DELETE", "/v1/users/2", nil, c)
+
+	logs := datastore.Environ.DB.(*datastore.MockDB).AuditLogs
+	c.Assert(logs, check.HasLen, 1)
+	c.Assert(logs[0].Action, check.Equals, datastore.AuditActionDelete)
+	c.Assert(logs[0].Entity, check.Equals, datastore.AuditEntityUser)
 }
 
 func (s *ServiceSuite) TestDeleteUserHandlerWithError(c *check.C) {
@@ -152,6 +152,52 @@ func (c *FactoryClient) Models() error {
 	return nil
 }
 
+// DeviceKeyRevocations synchronizes the device-key revocations to the factory instance
+func (c *FactoryClient) DeviceKeyRevocations() error {
+	// Fetch the revocations from the serial-vault
+	result, err := FetchDeviceKeyRevocations(c.URL, c.Username, c.APIKey)
+	if err != nil {
+		log.Errorf("Error parsing device-key revocations: %v", err)
+		return err
+	}
+	if !result.Success {
+		log.Errorf("Error fetching device-key revocations: %s", result.ErrorMessage)
+		return errors.New(result.ErrorMessage)
+	}
+
+	// Replace the revocations in the factory database, so removed revocations are dropped too
+	err = datastore.Environ.DB.SyncDeviceKeyRevocations(result.Revocations)
+	if err != nil {
+		log.Errorf("Error updating device-key revocations: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// SerialRules synchronizes the serial number rules of the models to the factory instance
+func (c *FactoryClient) SerialRules() error {
+	// Fetch the rules from the serial-vault
+	result, err := FetchSerialRules(c.URL, c.Username, c.APIKey)
+	if err != nil {
+		log.Errorf("Error parsing serial number rules: %v", err)
+		return err
+	}
+	if !result.Success {
+		log.Errorf("Error fetching serial number rules: %s", result.ErrorMessage)
+		return errors.New(result.ErrorMessage)
+	}
+
+	// Replace the rules in the factory database, so removed rules are dropped too
+	err = datastore.Environ.DB.SyncSerialRules(result.SerialRules)
+	if err != nil {
+		log.Errorf("Error updating serial number rules: %v", err)
+		return err
+	}
+
+	return nil
+}
+
 // SigningLogs sends signing logs to the cloud from the factory
 func (c *FactoryClient) SigningLogs() error {
 	// Fetch the signing logs that have not been synced
@@ -33,6 +33,8 @@ import (
 	"github.com/CanonicalLtd/serial-vault/service/account"
 	"github.com/CanonicalLtd/serial-vault/service/keypair"
 	"github.com/CanonicalLtd/serial-vault/service/model"
+	"github.com/CanonicalLtd/serial-vault/service/revocation"
+	"github.com/CanonicalLtd/serial-vault/service/serialrule"
 	"github.com/CanonicalLtd/serial-vault/sync"
 	check "gopkg.in/check.v1"
 )
@@ -72,6 +74,28 @@ func (s *startSuite) TestStartUnit(c *check.C) {
 			Args:         []string{"model"},
 			ErrorMessage: "MOCK fail fetching models",
 			MockFail:     true},
+		{
+			Args:         []string{"revocation"},
+			ErrorMessage: ""},
+		{
+			Args:         []string{"revocation"},
+			ErrorMessage: "MOCK error fetching device-key revocations",
+			MockErrorDB:  true},
+		{
+			Args:         []string{"revocation"},
+			ErrorMessage: "MOCK fail fetching device-key revocations",
+			MockFail:     true},
+		{
+			Args:         []string{"serialrule"},
+			ErrorMessage: ""},
+		{
+			Args:         []string{"serialrule"},
+			ErrorMessage: "MOCK error fetching serial number rules",
+			MockErrorDB:  true},
+		{
+			Args:         []string{"serialrule"},
+			ErrorMessage: "MOCK fail fetching serial number rules",
+			MockFail:     true},
 		{
 			Args:         []string{"signinglog"},
 			ErrorMessage: ""},
@@ -96,6 +120,8 @@ func (s *startSuite) TestStartUnit(c *check.C) {
 			sync.FetchAccounts = mockFetchAccountsError
 			sync.FetchSigningKeys = mockFetchSigningKeysError
 			sync.FetchModels = mockFetchModelsError
+			sync.FetchDeviceKeyRevocations = mockFetchDeviceKeyRevocationsError
+			sync.FetchSerialRules = mockFetchSerialRulesError
 			sync.SendSigningLog = mockSendSigningLogError
 			sync.SendTestLog = mockSendTestLogError
 		}
@@ -104,6 +130,8 @@ func (s *startSuite) TestStartUnit(c *check.C) {
 			sync.FetchAccounts = mockFetchAccountsFail
 			sync.FetchSigningKeys = mockFetchSigningKeysFail
 			sync.FetchModels = mockFetchModelsFail
+			sync.FetchDeviceKeyRevocations = mockFetchDeviceKeyRevocationsFail
+			sync.FetchSerialRules = mockFetchSerialRulesFail
 			sync.SendTestLog = mockSendTestLogError
 		}
 		if !t.MockErrorDB && !t.MockFail {
@@ -120,6 +148,10 @@ func (s *startSuite) TestStartUnit(c *check.C) {
 			err = client.SigningKeys()
 		case "model":
 			err = client.Models()
+		case "revocation":
+			err = client.DeviceKeyRevocations()
+		case "serialrule":
+			err = client.SerialRules()
 		case "signinglog":
 			err = client.SigningLogs()
 		case "testlog":
@@ -137,6 +169,8 @@ func (s *startSuite) TestStartUnit(c *check.C) {
 		sync.FetchAccounts = mockFetchAccounts
 		sync.FetchSigningKeys = mockFetchSigningKeys
 		sync.FetchModels = mockFetchModels
+		sync.FetchDeviceKeyRevocations = mockFetchDeviceKeyRevocations
+		sync.FetchSerialRules = mockFetchSerialRules
 		sync.SendSigningLog = mockSendSigningLog
 		sync.SendTestLog = mockSendTestLog
 	}
@@ -182,6 +216,32 @@ func mockFetchModelsFail(url, username, apikey string) (model.ListResponse, erro
 	return model.ListResponse{Success: false, ErrorMessage: "MOCK fail fetching models"}, nil
 }
 
+func mockFetchDeviceKeyRevocations(url, username, apikey string) (revocation.ListResponse, error) {
+	w := sendSyncAPIRequest("GET", "/api/revocations", nil)
+	return parseRevocationResponse(w)
+}
+
+func mockFetchDeviceKeyRevocationsError(url, username, apikey string) (revocation.ListResponse, error) {
+	return revocation.ListResponse{}, errors.New("MOCK error fetching device-key revocations")
+}
+
+func mockFetchDeviceKeyRevocationsFail(url, username, apikey string) (revocation.ListResponse, error) {
+	return revocation.ListResponse{Success: false, ErrorMessage: "MOCK fail fetching device-key revocations"}, nil
+}
+
+func mockFetchSerialRules(url, username, apikey string) (serialrule.ListResponse, error) {
+	w := sendSyncAPIRequest("GET", "/api/serialrules", nil)
+	return parseSerialRuleResponse(w)
+}
+
+func mockFetchSerialRulesError(url, username, apikey string) (serialrule.ListResponse, error) {
+	return serialrule.ListResponse{}, errors.New("MOCK error fetching serial number rules")
+}
+
+func mockFetchSerialRulesFail(url, username, apikey string) (serialrule.ListResponse, error) {
+	return serialrule.ListResponse{Success: false, ErrorMessage: "MOCK fail fetching serial number rules"}, nil
+}
+
 func mockSendSigningLog(url, username, apikey string, signLog datastore.SigningLog) (bool, error) {
 	return true, nil
 }
@@ -231,6 +291,13 @@ func parseModelResponse(w *httptest.ResponseRecorder) (model.ListResponse, error
 	return result, err
 }
 
+func parseRevocationResponse(w *httptest.ResponseRecorder) (revocation.ListResponse, error) {
+	// Check the JSON response
+	result := revocation.ListResponse{}
+	err := json.NewDecoder(w.Body).Decode(&result)
+	return result, err
+}
+
 func mockReEncryptKeypair(keypair datastore.Keypair, newSecret string) (string, string, error) {
 	return "Base64SealedKey", "Base64SAuthKey", nil
 }
@@ -239,3 +306,10 @@ func mockReEncryptKeypair(keypair datastore.Keypair, newSecret string) (string,
 func mockGetKeypairByPublicID(auth, keyID string) (datastore.Keypair, error) {
 	return datastore.Keypair{}, errors.New("MOCK Error fetching from the database")
 }
+
+func parseSerialRuleResponse(w *httptest.ResponseRecorder) (serialrule.ListResponse, error) {
+	// Check the JSON response
+	result := serialrule.ListResponse{}
+	err := json.NewDecoder(w.Body).Decode(&result)
+	return result, err
+}
@@ -31,6 +31,8 @@ import (
 	"github.com/CanonicalLtd/serial-vault/service/log"
 	"github.com/CanonicalLtd/serial-vault/service/model"
 	"github.com/CanonicalLtd/serial-vault/service/response"
+	"github.com/CanonicalLtd/serial-vault/service/revocation"
+	"github.com/CanonicalLtd/serial-vault/service/serialrule"
 )
 
 var hclient http.Client
@@ -82,6 +84,30 @@ var FetchModels = func(url, username, apikey string) (model.ListResponse, error)
 	return parseModelResponse(w)
 }
 
+// FetchDeviceKeyRevocations fetches the device-key revocations from the cloud serial vault
+var FetchDeviceKeyRevocations = func(url, username, apikey string) (revocation.ListResponse, error) {
+	w, err := SendRequest("GET", url, "revocations", username, apikey, nil)
+	if err != nil {
+		log.Errorf("Error fetching device-key revocations: %v", err)
+		return revocation.ListResponse{}, err
+	}
+
+	// Parse the response from the cloud
+	return parseRevocationResponse(w)
+}
+
+// FetchSerialRules fetches the serial number rules of the models from the cloud serial vault
+var FetchSerialRules = func(url, username, apikey string) (serialrule.ListResponse, error) {
+	w, err := SendRequest("GET", url, "serialrules", username, apikey, nil)
+	if err != nil {
+		log.Errorf("Error fetching serial number rules: %v", err)
+		return serialrule.ListResponse{}, err
+	}
+
+	// Parse the response from the cloud
+	return parseSerialRuleResponse(w)
+}
+
 // SendSigningLog sends a signing log to the cloud serial vault
 var SendSigningLog = func(url, username, apikey string, signLog datastore.SigningLog) (bool, error) {
 
@@ -160,6 +186,20 @@ func parseModelResponse(w *http.Response) (model.ListResponse, error) {
 	return result, err
 }
 
+func parseRevocationResponse(w *http.Response) (revocation.ListResponse, error) {
+	// Check the JSON response
+	result := revocation.ListResponse{}
+	err := json.NewDecoder(w.Body).Decode(&result)
+	return result, err
+}
+
+func parseSerialRuleResponse(w *http.Response) (serialrule.ListResponse, error) {
+	// Check the JSON response
+	result := serialrule.ListResponse{}
+	err := json.NewDecoder(w.Body).Decode(&result)
+	return result, err
+}
+
 func parseStandardResponse(w *http.Response) (response.StandardResponse, error) {
 	// Check the JSON response
 	result := response.StandardResponse{}
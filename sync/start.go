@@ -79,6 +79,20 @@ func (cmd StartCommand) Execute(args []string) error {
 			withErrors = true
 		}
 
+		// Sync the serial number rules of the models
+		log.Info("Sync the serial number rules from the cloud")
+		err = client.SerialRules()
+		if err != nil {
+			withErrors = true
+		}
+
+		// Sync the device-key revocations
+		log.Info("Sync the device-key revocations from the cloud")
+		err = client.DeviceKeyRevocations()
+		if err != nil {
+			withErrors = true
+		}
+
 		// Sync the signing logs
 		log.Info("Sync the signing logs to the cloud")
 		err = client.SigningLogs()
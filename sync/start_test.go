@@ -41,6 +41,8 @@ func (s *startSuite) SetUpTest(c *check.C) {
 	sync.FetchSigningKeys = mockFetchSigningKeys
 	datastore.ReEncryptKeypair = mockReEncryptKeypair
 	sync.FetchModels = mockFetchModels
+	sync.FetchDeviceKeyRevocations = mockFetchDeviceKeyRevocations
+	sync.FetchSerialRules = mockFetchSerialRules
 	sync.SendSigningLog = mockSendSigningLog
 	sync.SendTestLog = mockSendTestLog
 }
@@ -69,6 +71,8 @@ func (s *startSuite) TestStart(c *check.C) {
 			sync.FetchAccounts = mockFetchAccountsError
 			sync.FetchSigningKeys = mockFetchSigningKeysError
 			sync.FetchModels = mockFetchModelsError
+			sync.FetchDeviceKeyRevocations = mockFetchDeviceKeyRevocationsError
+			sync.FetchSerialRules = mockFetchSerialRulesError
 			sync.SendSigningLog = mockSendSigningLogError
 		}
 		if t.MockFail {
@@ -76,6 +80,8 @@ func (s *startSuite) TestStart(c *check.C) {
 			sync.FetchAccounts = mockFetchAccountsFail
 			sync.FetchSigningKeys = mockFetchSigningKeysFail
 			sync.FetchModels = mockFetchModelsFail
+			sync.FetchDeviceKeyRevocations = mockFetchDeviceKeyRevocationsFail
+			sync.FetchSerialRules = mockFetchSerialRulesFail
 			sync.SendSigningLog = mockSendSigningLogError
 		}
 
@@ -85,6 +91,8 @@ func (s *startSuite) TestStart(c *check.C) {
 		sync.FetchAccounts = mockFetchAccounts
 		sync.FetchSigningKeys = mockFetchSigningKeys
 		sync.FetchModels = mockFetchModels
+		sync.FetchDeviceKeyRevocations = mockFetchDeviceKeyRevocations
+		sync.FetchSerialRules = mockFetchSerialRules
 		sync.SendSigningLog = mockSendSigningLog
 	}
 }
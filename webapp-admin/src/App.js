@@ -33,19 +33,22 @@ import AccountKeyForm from './components/AccountKeyForm'
 import Keypair from './components/Keypair'
 import SigningLog from './components/SigningLog'
 import SubstoreList from './components/SubstoreList'
+import RevocationList from './components/RevocationList'
 import SystemUserForm from './components/SystemUserForm'
 import NavigationSubmenu from './components/NavigationSubmenu';
 import UserList from './components/UserList'
 import UserEdit from './components/UserEdit'
+import AuditLog from './components/AuditLog'
 import Accounts from './models/accounts'
 import Keypairs from './models/keypairs'
 import Models from './models/models';
+import Revocations from './models/revocations';
 import {sectionFromPath, sectionIdFromPath, subSectionIdFromPath, isLoggedIn, getAccount, saveAccount, isUserAdmin, isUserSuperuser, formatError} from './components/Utils'
 import createHistory from 'history/createBrowserHistory'
 import './sass/App.css'
 
 const history = createHistory()
-const submenuModels = ['models','substores','systemuser']
+const submenuModels = ['models','substores','revocations','systemuser']
 
 class App extends Component {
   constructor(props) {
@@ -56,7 +59,9 @@ class App extends Component {
       models: [],
       accounts: [],
       keypairs: [],
+      keypairWarnings: [],
       substores: [],
+      revocations: [],
       selectedAccount: getAccount() || {},
     }
 
@@ -116,7 +121,7 @@ class App extends Component {
             return k.AuthorityID === authorityID;
         })
 
-        this.setState({keypairs: keypairs, message: message});
+        this.setState({keypairs: keypairs, keypairWarnings: data.warnings || [], message: message});
     });
   }
 
@@ -148,6 +153,21 @@ class App extends Component {
     });
   }
 
+  getRevocations(authorityID) {
+    Revocations.list().then((response) => {
+        var data = JSON.parse(response.body);
+
+        if (response.statusCode >= 300) {
+            this.setState({message: formatError(data)});
+        } else {
+            var revocations = data.revocations.filter((r) => {
+                return r['brand-id'] === authorityID;
+            })
+            this.setState({revocations: revocations, message: null});
+        }
+    });
+  }
+
   updateDataForRoute(selectedAccount) {
     var currentSection = sectionFromPath(window.location.pathname);
 
@@ -163,6 +183,10 @@ class App extends Component {
       }
       this.getModels(selectedAccount.AuthorityID)
     }
+    if(currentSection==='revocations') {
+      this.getRevocations(selectedAccount.AuthorityID)
+      this.getModels(selectedAccount.AuthorityID)
+    }
     if(currentSection==='systemuser') {this.getModels(selectedAccount.AuthorityID)}
   }
 
@@ -228,7 +252,7 @@ class App extends Component {
       case 'new':
         return <KeypairAdd token={this.props.token} selectedAccount={this.state.selectedAccount} />
       case '':
-        return <Keypair token={this.props.token} selectedAccount={this.state.selectedAccount} keypairs={this.state.keypairs} onRefresh={this.handleAccountChange} />
+        return <Keypair token={this.props.token} selectedAccount={this.state.selectedAccount} keypairs={this.state.keypairs} warnings={this.state.keypairWarnings} onRefresh={this.handleAccountChange} />
       case 'store':
         return <KeypairStore token={this.props.token} selectedAccount={this.state.selectedAccount} keypairs={this.state.keypairs} />
       default:
@@ -248,7 +272,7 @@ class App extends Component {
           <div className="spacer" />
 
           {(isUserAdmin(this.props.token)||isUserSuperuser(this.props.token)) &&
-           (currentSection==='models'||currentSection==='substores'||currentSection==='systemuser')? 
+           (currentSection==='models'||currentSection==='substores'||currentSection==='revocations'||currentSection==='systemuser')? 
             <section className="row">
               <NavigationSubmenu items={submenuModels} selected={currentSection} />
             </section>
@@ -268,9 +292,13 @@ class App extends Component {
           {currentSection==='substores'? <SubstoreList token={this.props.token}
             selectedAccount={this.state.selectedAccount} onRefresh={this.handleAccountChange}
             substores={this.state.substores} models={this.state.models} /> : ''}
+          {currentSection==='revocations'? <RevocationList token={this.props.token}
+            selectedAccount={this.state.selectedAccount} onRefresh={this.handleAccountChange}
+            revocations={this.state.revocations} models={this.state.models} /> : ''}
           {currentSection==='systemuser'? <SystemUserForm token={this.props.token} models={this.state.models} /> : ''}
 
           {currentSection==='users'? this.renderUsers() : ''}
+          {currentSection==='audit'? <AuditLog token={this.props.token} /> : ''}
 
           <Footer />
       </div>
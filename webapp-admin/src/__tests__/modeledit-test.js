@@ -57,9 +57,15 @@ describe('model edit', function() {
 
         // Check that the form is rendered without data
         var inputs = ReactTestUtils.scryRenderedDOMComponentsWithTag(modelPage, 'input');
-        expect(inputs.length).toBe(3);
+        expect(inputs.length).toBe(4);
         expect(inputs[0].value).toBe('');
         expect(inputs[1].value).toBe('');
+        expect(inputs[3].value).toBe('');
+
+        // Check that duplicates are allowed by default
+        var select = ReactTestUtils.scryRenderedDOMComponentsWithTag(modelPage, 'select');
+        expect(select.length).toBe(4);
+        expect(select[0].value).toBe('allow');
 
     });
 
@@ -57,12 +57,13 @@ describe('navigation', function() {
 
     // Check all the expected elements are rendered
     var ul = ReactTestUtils.findRenderedDOMComponentWithTag(page, 'ul');
-    expect(ul.children.length).toBe(6);
+    expect(ul.children.length).toBe(7);
     expect(ul.children[1].firstChild.textContent).toBe('Accounts');
     expect(ul.children[2].firstChild.textContent).toBe('Signing Keys');
     expect(ul.children[3].firstChild.textContent).toBe('Models');
     expect(ul.children[4].firstChild.textContent).toBe('Signing Log');
     expect(ul.children[5].firstChild.textContent).toBe('Users');
+    expect(ul.children[6].firstChild.textContent).toBe('Audit Log');
   });
 
   it('displays the navigation menu with models active for admin', function() {
@@ -0,0 +1,178 @@
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+import React, {Component} from 'react'
+import moment from 'moment'
+import AlertBox from './AlertBox'
+import Audit from '../models/audit'
+import {T, isUserSuperuser, roleAsString, formatError} from './Utils'
+
+const PAGE_SIZE = 50;
+const entities = ['account', 'keypair', 'model', 'operation', 'substore', 'user'];
+
+
+class AuditLog extends Component {
+
+    constructor(props) {
+        super(props);
+
+        this.state = {
+            error: null,
+            logs: [],
+            total: 0,
+            offset: 0,
+            actor: '',
+            entity: '',
+        }
+        this.getAuditLog(0, '', '')
+    }
+
+    getAuditLog = (offset, actor, entity) => {
+        Audit.list(offset, actor, entity).then((response) => {
+            var data = JSON.parse(response.body);
+            if (!data.success) {
+                this.setState({error: formatError(data)});
+            } else {
+                this.setState({logs: data.logs, total: data.total, offset: offset, error: null});
+            }
+        })
+    }
+
+    handleActorChange = (e) => {
+        this.setState({actor: e.target.value})
+        this.getAuditLog(0, e.target.value, this.state.entity)
+    }
+
+    handleEntityChange = (e) => {
+        this.setState({entity: e.target.value})
+        this.getAuditLog(0, this.state.actor, e.target.value)
+    }
+
+    handlePageDown = (e) => {
+        e.preventDefault()
+        this.getAuditLog(Math.max(this.state.offset - PAGE_SIZE, 0), this.state.actor, this.state.entity)
+    }
+
+    handlePageUp = (e) => {
+        e.preventDefault()
+        this.getAuditLog(this.state.offset + PAGE_SIZE, this.state.actor, this.state.entity)
+    }
+
+    renderChanges(diff) {
+        var changes;
+        try {
+            changes = JSON.parse(diff)
+        } catch (e) {
+            return diff
+        }
+
+        return (
+            <ul className="p-list">
+                {Object.keys(changes).map((field) => {
+                    var c = changes[field]
+                    return (
+                        <li key={field} className="p-list__item" title={JSON.stringify(c)}>
+                            {field}: {JSON.stringify(c.old)} &rarr; {JSON.stringify(c.new)}
+                        </li>
+                    )
+                })}
+            </ul>
+        )
+    }
+
+    renderPaging() {
+        var pages = Math.ceil(this.state.total / PAGE_SIZE);
+        if (pages <= 1) {
+            return ''
+        }
+
+        var page = Math.floor(this.state.offset / PAGE_SIZE) + 1;
+        return (
+            <div className="u-float--right">
+                <button className="p-button--neutral" onClick={this.handlePageDown} disabled={page === 1}>&laquo;</button>
+                <span>&nbsp;{page} of {pages}&nbsp;</span>
+                <button className="p-button--neutral" onClick={this.handlePageUp} disabled={page === pages}>&raquo;</button>
+            </div>
+        )
+    }
+
+    renderLog(l) {
+        return (
+            <tr key={l.id}>
+                <td className="wrap">{moment(l.created).format("YYYY-MM-DD HH:mm")}</td>
+                <td className="overflow" title={l.actor}>{l.actor}</td>
+                <td>{roleAsString(l.role)}</td>
+                <td>{l.action}</td>
+                <td>{l.entity}{l.entityid ? ' ' + l.entityid : ''}</td>
+                <td className="wrap">{this.renderChanges(l.diff)}</td>
+                <td className="overflow" title={l.sourceip}>{l.sourceip}</td>
+            </tr>
+        )
+    }
+
+    render() {
+        if (!isUserSuperuser(this.props.token)) {
+            return (
+                <div className="row">
+                <AlertBox message={T('error-no-permissions')} />
+                </div>
+            )
+        }
+
+        return (
+            <div>
+                <section className="row no-border">
+                    <h2>{T('audit')}</h2>
+                    <p>{T('audit-description')}</p>
+
+                    <AlertBox message={this.state.error} />
+
+                    <div className="row">
+                        <div className="col-5">
+                            <input type="search" placeholder={T('find-actor')} onChange={this.handleActorChange} value={this.state.actor} />
+                        </div>
+                        <div className="col-4">
+                            <select value={this.state.entity} onChange={this.handleEntityChange}>
+                                <option value="">{T('all-entities')}</option>
+                                {entities.map((e) => {
+                                    return <option key={e} value={e}>{T(e)}</option>
+                                })}
+                            </select>
+                        </div>
+                        {this.renderPaging()}
+                    </div>
+
+                    <table>
+                        <thead>
+                            <tr>
+                                <th>{T('date')}</th><th>{T('actor')}</th><th>{T('role')}</th><th>{T('action')}</th>
+                                <th>{T('entity')}</th><th>{T('changes')}</th><th>{T('source-ip')}</th>
+                            </tr>
+                        </thead>
+                        <tbody>
+                            {this.state.logs.map((l) => {
+                                return this.renderLog(l)
+                            })}
+                        </tbody>
+                    </table>
+                </section>
+            </div>
+        )
+    }
+
+}
+
+export default AuditLog
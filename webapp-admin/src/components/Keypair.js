@@ -20,6 +20,7 @@
 import React, {Component} from 'react';
 import KeypairList from './KeypairList';
 import KeypairStatus from './KeypairStatus';
+import OperationList from './OperationList';
 import AlertBox from './AlertBox';
 import {T, isUserAdmin} from './Utils'
 
@@ -56,6 +57,22 @@ class Keypair extends Component {
     return message;
   }
 
+  renderWarnings() {
+    if (!this.props.warnings || this.props.warnings.length === 0) {
+      return null;
+    }
+
+    return (
+      <div className="col-12">
+        <div className="p-notification--caution">
+          {this.props.warnings.map((w) => {
+            return <p key={w} className="p-notification__response">{w}</p>;
+          })}
+        </div>
+      </div>
+    );
+  }
+
   render() {
     if (!isUserAdmin(this.props.token)) {
       return (
@@ -91,6 +108,10 @@ class Keypair extends Component {
             <div className="col-12">
               <KeypairStatus token={this.props.token} />
             </div>
+            <div className="col-12">
+              <OperationList token={this.props.token} refresh={this.handleRefresh} />
+            </div>
+            {this.renderWarnings()}
             <div className="col-12">
               <KeypairList keypairs={this.props.keypairs} refresh={this.handleRefresh} />
             </div>
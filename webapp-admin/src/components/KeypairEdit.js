@@ -19,7 +19,8 @@
 import React, {Component} from 'react'
 import Keypairs from '../models/keypairs'
 import AlertBox from './AlertBox'
-import {T, isUserAdmin} from './Utils';
+import DialogBox from './DialogBox'
+import {T, isUserAdmin, dateTimeInput, dateTimeValue} from './Utils';
 
 class KeypairEdit extends Component {
     constructor(props) {
@@ -27,7 +28,10 @@ class KeypairEdit extends Component {
 
         this.state = {
             keypair: {},
+            policy: {AssertionTypes: '', Brands: '', Models: ''},
+            validity: {NotBefore: '', NotAfter: ''},
             error: null,
+            confirmDelete: false,
         };
 
         this.getKeypair(this.props.id)
@@ -40,7 +44,15 @@ class KeypairEdit extends Component {
             if (response.statusCode >= 300) {
                 this.setState({error: this.formatError(data), hideForm: true});
             } else {
-                this.setState({keypair: data.keypair, hideForm: false});
+                var policy = data.keypair.Policy || {};
+                this.setState({keypair: data.keypair, policy: {
+                    AssertionTypes: (policy.AssertionTypes || []).join(', '),
+                    Brands: (policy.Brands || []).join(', '),
+                    Models: (policy.Models || []).join(', '),
+                }, validity: {
+                    NotBefore: dateTimeInput(data.keypair.NotBefore),
+                    NotAfter: dateTimeInput(data.keypair.NotAfter),
+                }, hideForm: false});
             }
         });
     }
@@ -51,6 +63,22 @@ class KeypairEdit extends Component {
         this.setState({keypair: k});
     }
 
+    handleChangePolicy = (e) => {
+        var p = this.state.policy
+        p[e.target.name] = e.target.value
+        this.setState({policy: p});
+    }
+
+    handleChangeValidity = (e) => {
+        var v = this.state.validity
+        v[e.target.name] = e.target.value
+        this.setState({validity: v});
+    }
+
+    policyList(value) {
+        return value.split(',').map(v => v.trim()).filter(v => v.length > 0)
+    }
+
     handleSaveClick = (e) => {
         e.preventDefault();
 
@@ -58,6 +86,54 @@ class KeypairEdit extends Component {
             var data = JSON.parse(response.body);
             if ((response.statusCode >= 300) || (!data.success)) {
                 this.setState({error: this.formatError(data)});
+                return
+            }
+
+            var policy = {
+                AssertionTypes: this.policyList(this.state.policy.AssertionTypes),
+                Brands: this.policyList(this.state.policy.Brands),
+                Models: this.policyList(this.state.policy.Models),
+            }
+            Keypairs.policy(this.state.keypair.ID, policy).then((response) => {
+                var data = JSON.parse(response.body);
+                if ((response.statusCode >= 300) || (!data.success)) {
+                    this.setState({error: this.formatError(data)});
+                    return
+                }
+
+                var validity = {
+                    NotBefore: dateTimeValue(this.state.validity.NotBefore),
+                    NotAfter: dateTimeValue(this.state.validity.NotAfter),
+                }
+                Keypairs.validity(this.state.keypair.ID, validity).then((response) => {
+                    var data = JSON.parse(response.body);
+                    if ((response.statusCode >= 300) || (!data.success)) {
+                        this.setState({error: this.formatError(data)});
+                    } else {
+                        window.location = '/signing-keys';
+                    }
+                });
+            });
+        });
+    }
+
+    handleDeleteClick = (e) => {
+        e.preventDefault();
+        this.setState({confirmDelete: true});
+    }
+
+    handleDeleteCancel = (e) => {
+        e.preventDefault();
+        this.setState({confirmDelete: false});
+    }
+
+    handleDelete = (e) => {
+        e.preventDefault();
+
+        Keypairs.delete(this.state.keypair.ID).then((response) => {
+            var data = JSON.parse(response.body);
+            if (response.statusCode >= 300) {
+                this.setState({error: this.formatError(data), confirmDelete: false});
             } else {
                 window.location = '/signing-keys';
             }
@@ -109,12 +185,44 @@ class KeypairEdit extends Component {
                                         value={this.state.keypair.AuthorityID} disabled />
                                 </label>
                             </fieldset>
+                            <fieldset>
+                                <legend>{T('usage-policy')}</legend>
+                                <label htmlFor="assertion-types">{T('allowed-assertion-types')}:
+                                    <input type="text" id="assertion-types" name="AssertionTypes" onChange={this.handleChangePolicy}
+                                        value={this.state.policy.AssertionTypes} placeholder={T('allowed-assertion-types-description')} />
+                                </label>
+                                <label htmlFor="brands">{T('allowed-brands')}:
+                                    <input type="text" id="brands" name="Brands" onChange={this.handleChangePolicy}
+                                        value={this.state.policy.Brands} placeholder={T('allowed-brands-description')} />
+                                </label>
+                                <label htmlFor="models">{T('allowed-models')}:
+                                    <input type="text" id="models" name="Models" onChange={this.handleChangePolicy}
+                                        value={this.state.policy.Models} placeholder={T('allowed-models-description')} />
+                                </label>
+                            </fieldset>
+                            <fieldset>
+                                <legend>{T('validity')}</legend>
+                                <label htmlFor="not-before">{T('not-before')}:
+                                    <input type="datetime-local" id="not-before" name="NotBefore" onChange={this.handleChangeValidity}
+                                        value={this.state.validity.NotBefore} />
+                                </label>
+                                <label htmlFor="not-after">{T('not-after')}:
+                                    <input type="datetime-local" id="not-after" name="NotAfter" onChange={this.handleChangeValidity}
+                                        value={this.state.validity.NotAfter} />
+                                </label>
+                            </fieldset>
                         </form>
                         <div>
                             <a href='/signing-keys' className="p-button--neutral">{T('cancel')}</a>
                             &nbsp;
                             <a href='/signing-keys' onClick={this.handleSaveClick} className="p-button--brand">{T('save')}</a>
+                            &nbsp;
+                            <a href='/signing-keys' onClick={this.handleDeleteClick} className="p-button--negative">{T('delete-signing-key')}</a>
                         </div>
+                        {this.state.confirmDelete ?
+                            <DialogBox message={T('confirm-keypair-delete')} handleYesClick={this.handleDelete} handleCancelClick={this.handleDeleteCancel} />
+                            : ''
+                        }
                 </section>
             </div>
         )
@@ -16,6 +16,7 @@
  */
 import React, {Component} from 'react';
 import Keypairs from '../models/keypairs';
+import moment from 'moment';
 import {T} from './Utils'
 
 
@@ -48,6 +49,7 @@ class KeypairList extends Component {
           </button>
         </td>
         <td className="overflow" title={keypr.KeyName}>{keypr.KeyName}</td>
+        <td>{keypr.NotAfter ? moment(keypr.NotAfter).format("YYYY-MM-DD HH:mm") : ''}</td>
       </tr>
     );
   }
@@ -60,7 +62,7 @@ class KeypairList extends Component {
           <thead>
             <tr>
               <th className="small" /><th>{T('authority-id')}</th><th>{T('key-id')}</th><th className="small" >{T('active')}</th>
-              <th>{T('key-name')}</th>
+              <th>{T('key-name')}</th><th>{T('expires')}</th>
             </tr>
           </thead>
           <tbody>
@@ -18,7 +18,7 @@
 import React, {Component} from 'react';
 import AlertBox from './AlertBox';
 import Models from '../models/models';
-import {T, isUserAdmin} from './Utils';
+import {T, isUserAdmin, dateTimeInput, dateTimeValue} from './Utils';
 
 class ModelEdit extends Component {
 
@@ -27,7 +27,7 @@ class ModelEdit extends Component {
         super(props)
         this.state = {
             title: null,
-            model: {'brand-id': this.props.selectedAccount.AuthorityID},
+            model: {'brand-id': this.props.selectedAccount.AuthorityID, 'duplicate-policy': 'allow'},
             error: null,
             hideForm: false,
         }
@@ -87,6 +87,12 @@ class ModelEdit extends Component {
         this.setState({model: model});
     }
 
+    handleChangeDuplicatePolicy = (e) => {
+        var model = this.state.model;
+        model['duplicate-policy'] = e.target.value;
+        this.setState({model: model});
+    }
+
     handleChangePrivateKey = (e) => {
         var model = this.state.model;
         model['keypair-id'] = parseInt(e.target.value, 10);
@@ -99,6 +105,18 @@ class ModelEdit extends Component {
         this.setState({model: model});
     }
 
+    handleChangeNextPrivateKey = (e) => {
+        var model = this.state.model;
+        model['next-keypair-id'] = parseInt(e.target.value, 10) || 0;
+        this.setState({model: model});
+    }
+
+    handleChangeRolloverAt = (e) => {
+        var model = this.state.model;
+        model['rollover-at'] = dateTimeValue(e.target.value);
+        this.setState({model: model});
+    }
+
     handleSaveClick = (e) => {
         e.preventDefault();
         var self = this;
@@ -173,6 +191,13 @@ class ModelEdit extends Component {
                                     <input type="text" id="api-key" placeholder={T('api-key-description')}
                                         value={this.state.model['api-key']} onChange={this.handleChangeAPIKey}/>
                                 </label>
+                                <label htmlFor="duplicate-policy">{T('duplicate-policy')}:
+                                    <select value={this.state.model['duplicate-policy']} id="duplicate-policy" onChange={this.handleChangeDuplicatePolicy}>
+                                        <option value="allow">{T('duplicate-allow')}</option>
+                                        <option value="reject-device-key">{T('duplicate-reject-device-key')}</option>
+                                        <option value="reject">{T('duplicate-reject')}</option>
+                                    </select>
+                                </label>
                                 <label htmlFor="keypair">{T('private-key')}:
                                     <select value={this.state.model['keypair-id']} id="keypair" onChange={this.handleChangePrivateKey}>
                                         <option />
@@ -198,6 +223,25 @@ class ModelEdit extends Component {
                                     </select>
                                 </label>
                             </fieldset>
+                            <fieldset>
+                                <legend>{T('key-rollover')}</legend>
+                                <label htmlFor="next-keypair">{T('next-private-key')}:
+                                    <select value={this.state.model['next-keypair-id']} id="next-keypair" onChange={this.handleChangeNextPrivateKey}>
+                                        <option value="0"></option>
+                                        {this.props.keypairs.map((kpr) => {
+                                            if (kpr.Active) {
+                                                return <option key={kpr.ID} value={kpr.ID}>{kpr.KeyName} - {kpr.KeyID}</option>;
+                                            } else {
+                                                return <option key={kpr.ID} value={kpr.ID}>{kpr.KeyName} - {kpr.KeyID} ({T('inactive')})</option>;
+                                            }
+                                        })}
+                                    </select>
+                                </label>
+                                <label htmlFor="rollover-at">{T('rollover-at')}:
+                                    <input type="datetime-local" id="rollover-at" onChange={this.handleChangeRolloverAt}
+                                        value={dateTimeInput(this.state.model['rollover-at'])} />
+                                </label>
+                            </fieldset>
                         </form>
 
                         <div>
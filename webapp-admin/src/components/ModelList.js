@@ -107,6 +107,7 @@ class ModelList extends Component {
             <tr>
               <th></th><th>{T('model')}</th><th>{T('private-key-short')}</th><th>{T('private-key-user-short')}</th>
               <th className="small">{T('active')}</th>
+              <th>{T('duplicate-policy-short')}</th>
               <th>{T('private-key-model-short')}</th>
             </tr>
           </thead>
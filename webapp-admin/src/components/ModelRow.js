@@ -73,6 +73,7 @@ class ModelRow extends Component {
                 <td className="overflow" title={fingerprint} >{fingerprint}</td>
                 <td className="overflow" title={fingerprintUser} >{fingerprintUser}</td>
                 <td>{this.props.model['key-active'] && this.props.model['key-active-user'] ? <i className="fa fa-check"></i> :  <i className="fa fa-times"></i>}</td>
+                <td>{this.props.model['duplicate-policy']}</td>
                 <td className="overflow" title={fingerprintModel} >
                     <button className="p-button--neutral small" title={T('assertion-settings')} data-key={this.props.model.id} onClick={this.props.showAssert}>
                         <i className="fa fa-sliders" aria-hidden="true" data-key={this.props.model.id} />
@@ -19,7 +19,7 @@ import React, {Component} from 'react';
 import {T, isLoggedIn} from './Utils'
 import {Role} from './Constants'
 
-const linksSuperuser = ['accounts', 'signing-keys', 'models', 'signinglog', "users"];
+const linksSuperuser = ['accounts', 'signing-keys', 'models', 'signinglog', "users", 'audit'];
 const linksAdmin = ['signing-keys', 'models', 'signinglog'];
 const linksStandard = ['systemuser'];
 
@@ -0,0 +1,159 @@
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+import React, {Component} from 'react';
+import Operations from '../models/operations';
+import AlertBox from './AlertBox';
+import {T, isUserSuperuser, formatError} from './Utils';
+import { setTimeout } from 'timers';
+
+
+class OperationList extends Component {
+
+    constructor(props) {
+        super(props)
+        this.state = {
+            operations: [],
+            error: null,
+        }
+
+        this.getOperations()
+    }
+
+    poll = () => {
+        // Polls every 30s
+        setTimeout(this.getOperations.bind(this), 30000);
+    }
+
+    getOperations() {
+        Operations.list().then((response) => {
+            var data = JSON.parse(response.body);
+            if (response.statusCode >= 300) {
+                this.setState({error: formatError(data)});
+            } else {
+                this.setState({operations: data.operations || [], error: null});
+            }
+        })
+        .done( ()=> {
+            this.poll()
+        })
+    }
+
+    refresh() {
+        Operations.list().then((response) => {
+            var data = JSON.parse(response.body);
+            if (response.statusCode < 300) {
+                this.setState({operations: data.operations || []});
+            }
+        })
+    }
+
+    handleApprove = (e) => {
+        e.preventDefault();
+        var id = parseInt(e.target.getAttribute('data-key'), 10);
+
+        Operations.approve(id).then((response) => {
+            var data = JSON.parse(response.body);
+            if (response.statusCode >= 300) {
+                this.setState({error: formatError(data)});
+            } else {
+                this.setState({error: null});
+                this.refresh();
+                if (this.props.refresh) {
+                    this.props.refresh();
+                }
+            }
+        })
+    }
+
+    handleReject = (e) => {
+        e.preventDefault();
+        var id = parseInt(e.target.getAttribute('data-key'), 10);
+
+        Operations.reject(id, '').then((response) => {
+            var data = JSON.parse(response.body);
+            if (response.statusCode >= 300) {
+                this.setState({error: formatError(data)});
+            } else {
+                this.setState({error: null});
+                this.refresh();
+            }
+        })
+    }
+
+    renderActions(op) {
+        return (
+            <div>
+                {isUserSuperuser(this.props.token) && op.status === 'pending' ?
+                    <button data-key={op.id} onClick={this.handleApprove} className="p-button--brand small" title={T('approve')}>
+                        <i data-key={op.id} className="fa fa-check" />
+                    </button>
+                    : ''
+                }
+                <button data-key={op.id} onClick={this.handleReject} className="p-button--neutral small" title={T('reject')}>
+                    <i data-key={op.id} className="fa fa-times" />
+                </button>
+            </div>
+        )
+    }
+
+    renderRow(op) {
+        return (
+            <tr key={op.id}>
+                <td>{this.renderActions(op)}</td>
+                <td className="overflow" title={op.operation}>{T('operation-' + op.operation)}</td>
+                <td className="overflow" title={op['authority-id']}>{op['authority-id']}</td>
+                <td className="overflow" title={op['key-name']}>{op['key-name']}</td>
+                <td className="overflow" title={op.status}>{T(op.status)}</td>
+                <td className="overflow" title={op['requested-by']}>{op['requested-by']}</td>
+                <td className="overflow" title={op['requested-at']}>{op['requested-at']}</td>
+            </tr>
+        );
+    }
+
+    render() {
+        var operations = this.state.operations.filter((op) => {
+            return op.status === 'pending' || op.status === 'approved'
+        })
+
+        if ((operations.length === 0) && (!this.state.error)) {
+            return <span />;
+        }
+
+        return (
+            <div className="p-card--highlighted spacer">
+                <h3>{T('pending-operations')}</h3>
+                <AlertBox message={this.state.error} />
+                <table>
+                    <thead>
+                        <tr>
+                            <th className="small"></th><th>{T('operation')}</th><th>{T('authority-id')}</th>
+                            <th>{T('key-name')}</th><th>{T('status')}</th><th>{T('requested-by')}</th><th>{T('date')}</th>
+                        </tr>
+                    </thead>
+                    <tbody>
+                        {operations.map((op) => {
+                            return this.renderRow(op);
+                        })}
+                    </tbody>
+                </table>
+            </div>
+        );
+    }
+
+}
+
+export default OperationList;
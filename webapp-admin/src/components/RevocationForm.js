@@ -0,0 +1,106 @@
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+import React, {Component} from 'react'
+import {T, isUserAdmin} from './Utils';
+
+
+class RevocationForm extends Component {
+
+    handleChangeType = (e) => {
+        e.preventDefault()
+        this.props.onChange('revokeType', e.target.value)
+    }
+
+    handleChangeModel = (e) => {
+        e.preventDefault()
+        this.props.onChange('model', e.target.value)
+    }
+
+    handleChangeSerial = (e) => {
+        e.preventDefault()
+        this.props.onChange('serialnumber', e.target.value)
+    }
+
+    handleChangeFingerprint = (e) => {
+        e.preventDefault()
+        this.props.onChange('fingerprint', e.target.value)
+    }
+
+    handleChangeReason = (e) => {
+        e.preventDefault()
+        this.props.onChange('reason', e.target.value)
+    }
+
+    render() {
+
+        var b = this.props.revocation
+
+        return (
+            <tr>
+                <td colSpan="6">
+                    <form>
+                        <fieldset>
+                            <label htmlFor="revokeType">{T('revoke-type')}:
+                                <select value={b.revokeType} id="revokeType" onChange={this.handleChangeType}>
+                                    <option value="serial">{T('serial-number')}</option>
+                                    <option value="fingerprint">{T('device-key-fingerprint')}</option>
+                                </select>
+                            </label>
+
+                            {b.revokeType === 'fingerprint' ?
+                                <label htmlFor="fingerprint">{T('device-key-fingerprint')}:
+                                    <input type="text" id="fingerprint" placeholder={T('device-key-fingerprint-description')}
+                                        value={b.fingerprint} onChange={this.handleChangeFingerprint} />
+                                </label>
+                            :
+                                <span>
+                                    <label htmlFor="model">{T('model')}:
+                                        <select value={b.model} id="model" onChange={this.handleChangeModel}>
+                                            <option></option>
+                                            {this.props.models.map(function(m) {
+                                                return <option key={m.id} value={m.model}>{m.model}</option>;
+                                            })}
+                                        </select>
+                                    </label>
+
+                                    <label htmlFor="serial">{T('serial-number')}:
+                                        <input type="text" id="serial" placeholder={T('serial-number-description')}
+                                            value={b.serialnumber} onChange={this.handleChangeSerial} />
+                                    </label>
+                                </span>
+                            }
+
+                            <label htmlFor="reason">{T('reason')}:
+                                <input type="text" id="reason" placeholder={T('reason-description')}
+                                    value={b.reason} onChange={this.handleChangeReason} />
+                            </label>
+                        </fieldset>
+                        {isUserAdmin(this.props.token) ?
+                          <span>
+                            <button className="p-button--neutral" onClick={this.props.onCancel}>{T('cancel')}</button>
+                            <button className="p-button--brand" onClick={this.props.onSave}>{T('save')}</button>
+                          </span>
+                          : <button className="p-button--neutral" onClick={this.props.onCancel}>{T('close')}</button>
+                        }
+                    </form>
+                </td>
+            </tr>
+        )
+    }
+}
+
+export default RevocationForm
@@ -0,0 +1,195 @@
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+import React, {Component} from 'react'
+import  AlertBox from './AlertBox'
+import Revocations from '../models/revocations'
+import {T, isUserAdmin, formatError} from './Utils';
+import RevocationForm from './RevocationForm';
+import DialogBox from './DialogBox';
+
+
+class RevocationList extends Component {
+
+    constructor(props) {
+        super(props);
+
+        this.state = {
+            error: null,
+            showNew: false,
+            showDelete: null,
+            revocation: {revokeType: 'serial'},
+        }
+    }
+
+    handleShowNew = (e) => {
+        e.preventDefault();
+        this.setState({showNew: true, showDelete: null, revocation: {revokeType: 'serial'}})
+    }
+
+    handleShowDelete = (e) => {
+        e.preventDefault();
+        var id = parseInt(e.target.getAttribute('data-key'), 10);
+        if (this.state.showDelete === id) {
+            this.setState({showDelete: null, showNew: false})
+        } else {
+            var revocations = this.props.revocations.filter( (r) => {
+                return r.id === id
+            })
+            this.setState({revocation: revocations[0], showDelete: id, showNew: false})
+        }
+    }
+
+    handleRevocationChange = (field, value) => {
+        var r = this.state.revocation
+        r[field] = value
+        this.setState({revocation: r})
+    }
+
+    handleSaveRevocation = (e) => {
+        e.preventDefault()
+
+        if (!isUserAdmin(this.props.token)) {
+            window.location = '/models';
+        }
+
+        // Only send the serial number or the fingerprint, depending on the type of revocation
+        var r = this.state.revocation
+        var revocation = {'brand-id': this.props.selectedAccount.AuthorityID, reason: r.reason}
+        if (r.revokeType === 'fingerprint') {
+            revocation.fingerprint = r.fingerprint
+        } else {
+            revocation.model = r.model
+            revocation.serialnumber = r.serialnumber
+        }
+
+        Revocations.create(revocation).then((response) => {
+            var data = JSON.parse(response.body);
+            if (response.statusCode >= 300) {
+                this.setState({error: formatError(data)});
+            } else {
+                this.setState({revocation: {revokeType: 'serial'}, showNew: false, showDelete: null, error: null})
+                this.props.onRefresh(this.props.selectedAccount)
+            }
+        })
+    }
+
+    handleDeleteRevocation = (e) => {
+        e.preventDefault()
+
+        Revocations.delete(this.state.revocation).then((response) => {
+            var data = JSON.parse(response.body);
+            if (response.statusCode >= 300) {
+                this.setState({error: formatError(data)});
+            } else {
+                this.props.onRefresh(this.props.selectedAccount)
+                this.setState({revocation: {revokeType: 'serial'}, showNew: false, showDelete: null, error: null})
+            }
+        })
+    }
+
+    handleCancelRevocation = (e) => {
+        e.preventDefault()
+        this.setState({revocation: {revokeType: 'serial'}, showNew: false, showDelete: null, error: null})
+        this.props.onRefresh(this.props.selectedAccount)
+    }
+
+    renderActions(r) {
+        if (this.state.showDelete === r.id) {
+            return (
+                <DialogBox message={T('confirm-revocation-delete')} handleYesClick={this.handleDeleteRevocation} handleCancelClick={this.handleCancelRevocation} small />
+            );
+        } else {
+            return (
+                <div>
+                    <button data-key={r.id} onClick={this.handleShowDelete} className="p-button--neutral small" title={T('delete-revocation')}>
+                        <i data-key={r.id} className="fa fa-trash" />
+                    </button>
+                </div>
+            )
+        }
+    }
+
+    renderRevocation(r) {
+        return (
+            <tr key={r.id}>
+                <td>
+                    {this.renderActions(r)}
+                </td>
+                <td className="overflow" title={r.model}>{r.model}</td>
+                <td className="overflow" title={r.serialnumber}>{r.serialnumber}</td>
+                <td className="overflow" title={r.fingerprint}>{r.fingerprint}</td>
+                <td className="overflow" title={r.reason}>{r.reason}</td>
+                <td className="overflow" title={r.created}>{r.created}</td>
+            </tr>
+        )
+    }
+
+    render() {
+        if (!isUserAdmin(this.props.token)) {
+            return (
+                <div className="row">
+                <AlertBox message={T('error-no-permissions')} />
+                </div>
+            )
+        }
+
+        return (
+            <div>
+                <section className="row no-border">
+                    <div>
+                        <div className="u-equal-height">
+                            <h2 className="p-card__title col-5">{T('revocations')}</h2>
+                            &nbsp;
+                            <div className="col-1">
+                                <button onClick={this.handleShowNew} className="p-button--brand" title={T('new-revocation')}>
+                                    <i className="fa fa-plus"></i>
+                                </button>
+                            </div>
+                        </div>
+
+                        <p>{T('revocations-description')}</p>
+
+                        <AlertBox message={this.state.error} />
+
+                        <table>
+                          <thead>
+                            <tr>
+                                <th></th><th>{T('model')}</th><th>{T('serial-number')}</th>
+                                <th>{T('device-key-fingerprint')}</th><th>{T('reason')}</th><th>{T('date')}</th>
+                            </tr>
+                          </thead>
+                          <tbody>
+                              {this.state.showNew ?
+                                    <RevocationForm revocation={this.state.revocation} models={this.props.models} token={this.props.token}
+                                        onSave={this.handleSaveRevocation} onChange={this.handleRevocationChange} onCancel={this.handleCancelRevocation} />
+                                : ''
+                              }
+                              {this.props.revocations.map((r) => {
+                                return this.renderRevocation(r)
+                              })}
+                          </tbody>
+                        </table>
+                    </div>
+                </section>
+            </div>
+        )
+
+    }
+
+}
+
+export default RevocationList
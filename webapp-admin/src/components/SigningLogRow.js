@@ -17,6 +17,7 @@
 
 import React, {Component} from 'react';
 import moment from 'moment';
+import {T} from './Utils';
 
 
 class SigningLogRow extends Component {
@@ -26,7 +27,11 @@ class SigningLogRow extends Component {
 				<td className="wrap">{this.props.log.make}</td>
 				<td className="wrap">{this.props.log.model}</td>
 				<td className="wrap">{this.props.log.serialnumber}</td>
-				<td>{this.props.log.revision}</td>
+				{this.props.log.rejected ?
+					<td title={T('rejected-' + this.props.log.rejected)}>{T('rejected')}</td>
+					:
+					<td>{this.props.log.revision}</td>
+				}
 				<td className="overflow" title={this.props.log.fingerprint}>{this.props.log.fingerprint}</td>
 				<td className="wrap">{moment(this.props.log.created).format("YYYY-MM-DD HH:mm")}</td>
 			</tr>
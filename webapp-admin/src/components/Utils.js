@@ -16,11 +16,12 @@
  */
 import Messages from './messages'
 import jwtDecode from 'jwt-decode'
+import moment from 'moment'
 import Ajax from '../models/Ajax'
 import {Role} from './Constants'
 
 
-const sections = ['signing-keys', 'models', 'keypairs', 'accounts', 'signinglog', 'substores', 'systemuser', 'users', 'notfound']
+const sections = ['signing-keys', 'models', 'keypairs', 'accounts', 'signinglog', 'substores', 'revocations', 'systemuser', 'users', 'audit', 'notfound']
 
 
 export function sectionFromPath(path) {
@@ -170,3 +171,13 @@ export function getAccount() {
         ResellerAPI: sessionStorage.getItem('accountReseller')==='true',
     }
 }
+
+// dateTimeInput formats a timestamp for a datetime-local input
+export function dateTimeInput(value) {
+    return value ? moment(value).format('YYYY-MM-DDTHH:mm') : ''
+}
+
+// dateTimeValue converts the value of a datetime-local input to a timestamp
+export function dateTimeValue(value) {
+    return value ? moment(value).toISOString() : null
+}
@@ -24,19 +24,34 @@ var intlData = {
       "account-description": "The authority-id for the account",
       "account-keys": "Account Key Assertions",
       "accounts": "Accounts",
+      "action": "Action",
       "activate": "Activate",
       "active": "Active",
+      "actor": "User",
       "add": "Add",
       "add-new-model": "Add a new model",
       "add-new-signing-key": "Import a signing key",
       "add-new-user": "Add a new user",
+      "all-entities": "All changes",
+      "allowed-assertion-types": "Allowed Assertion Types",
+      "allowed-assertion-types-description": "Comma-separated assertion types that the key may sign, e.g. serial, model. Leave empty to allow any",
+      "allowed-brands": "Allowed Brands",
+      "allowed-brands-description": "Comma-separated brands that the key may sign for. Leave empty to allow any",
+      "allowed-models": "Allowed Models",
+      "allowed-models-description": "Comma-separated models that the key may sign for. Leave empty to allow any",
       "api-key": "API Key",
       "api-key-description": "API Key to sign a serial assertion request (min. 10 characters). Will be generated if blank or invalid",
+      "approve": "Approve",
+      "approve-operation": "Error approving the operation",
+      "approved": "Approved",
       "architecture": "Architecture",
       "architecture-description": "The architecture of the device",
       "assertion": "Assertion",
       "assertion-settings": "Configure the model assertion headers",
       "assertion-status": "Assertion Status",
+      "audit": "Audit Log",
+      "audit-description": "Changes that users have made to the accounts, signing keys, models, sub-stores and users",
+      "audit-log-params": "Invalid search of the audit log",
       "authority-id-description": "The authority for signing models",
       "authority-id": "Signing Authority",
       "base": "Base",
@@ -44,12 +59,15 @@ var intlData = {
       "brand": "Brand",
       "brand-description": "The name of the device brand",
       "cancel": "Cancel",
+      "changes": "Changes",
       "classic": "Classic",
       "classic-description": "(optional) Ubuntu Classic system: true or false",
       "close": "Close",
       "complete": "Complete",
+      "confirm-keypair-delete": "Remove this signing-key?",
       "confirm-log-delete": "Remove this log?",
       "confirm-model-delete": "Remove this model?",
+      "confirm-revocation-delete": "Remove this revocation? The device will be able to get a serial assertion again",
       "confirm-store-delete": "Remove this sub-store model?",
       "confirm-user-delete": "Remove this user?",
       "copy-api-key": "Copy API key to clipboard",
@@ -57,17 +75,30 @@ var intlData = {
       "create-system-user": "Create System-User",
       "date": "Date",
       "deactivate": "Deactivate",
+      "delete-keypair": "Error deleting the signing-key",
       "delete-log": "Delete log",
       "delete-model": "Delete model",
+      "delete-revocation": "Delete revocation",
+      "delete-signing-key": "Delete signing-key",
       "delete-user": "Delete user",
       "description": "The Serial Vault is a web service that generates cryptographically-signed serial assertions.",
+      "device-key-fingerprint": "Device-Key Fingerprint",
+      "device-key-fingerprint-description": "The SHA3-384 fingerprint of the device-key (device-key-sha3-384)",
       "display_name": "Display Name",
       "display_name-description": "Descriptive name of the device",
       "download": "Download",
+      "duplicate-allow": "Allow: sign duplicates with a new revision",
+      "duplicate-device-key": "The serial number has already been used with a different device-key",
+      "duplicate-policy": "Duplicate Serial Numbers",
+      "duplicate-policy-short": "Duplicates",
+      "duplicate-reject": "Reject: refuse all duplicates",
+      "duplicate-reject-device-key": "Reject new device-key: refuse a serial number reused with a different device-key",
+      "duplicate-serial": "The serial number has already been used",
       "edit-model": "Edit Model",
       "edit-user": "Edit User",
       "email": "Email",
       "email-description": "Email for the Store",
+      "entity": "Changed",
       "error-adding-key": "Error adding a public key",
       "error-auth": "Unauthorized action",
       "error-created-model": "Cannot find the created model",
@@ -102,11 +133,17 @@ var intlData = {
       "error-updating-store": "Error updating sub-store model",
       "error-updating-user": "Error updating the user",
       "error-user-data": "No user data supplied",
+      "error-validate-duplicate-policy": "The duplicate serial number policy is invalid",
       "error-validate-key": "The public key must be entered",
       "error-validate-model": "The Brand and Model must be supplied",
       "error-validate-new-model": "The Brand, Model and Signing-Keys must be supplied",
+      "error-validate-rollover": "The next signing-key must differ from the current one, and needs a rollover time",
       "error-validate-signingkey": "The Serial Assertion Key must be selected",
       "error-validate-userkey": "The System-User Assertion Key must be selected",
+      "expires": "Expires",
+      "fetch-audit-log": "Error fetching the audit log",
+      "fetch-operations": "Error fetching the pending operations",
+      "find-actor": "find username",
       "find-serialnumber": "find serial number",
       "fingerprint": "Fingerprint",
       "gadget": "Gadget Snap",
@@ -122,6 +159,9 @@ var intlData = {
       "key-name-description": "Unique name for the key in the store",
       "key-name": "Key Name",
       "key-name-missing": "The key name must be entered",
+      "key-rollover": "Signing-Key Rollover",
+      "keypair": "Signing Key",
+      "keypair-policy": "Not allowed by the usage policy of the signing-key",
       "login": "Login",
       "logout": "Logout",
       "makes": "Brands",
@@ -138,20 +178,32 @@ var intlData = {
       "new-model": "New Model",
       "new-public-key-description": "Paste the public key of the machine that needs access to the Serial Vault",
       "new-public-key": "New Public Key",
+      "new-revocation": "Revoke a device-key or serial number",
       "new-signing-key-description": "Paste the signing-key or upload the file",
       "new-signing-key": "Import Signing Key",
       "new-substore-device": "Create a new sub-store mapping for a device",
       "new-user": "New User",
+      "next-private-key": "Next Serial Assertion Key",
       "no": "No",
       "no-assertion-key": "No account key assertion found",
       "no-assertion": "No account assertion found",
       "no-assertions": "No assertions found",
       "no-signing-keys-found": "No signing keys found",
+      "not-after": "Not valid after",
+      "not-before": "Not valid before",
       "not-used-signing": "Not used for signing system-user assertions",
+      "operation": "Operation",
+      "operation-delete": "Delete",
+      "operation-enable": "Enable",
+      "operation-generate": "Generate",
+      "operation-import": "Import",
+      "operation-register": "Store registration",
       "otp": "OTP",
       "otp-description": "One-time password for SSO",
       "password": "Password",
       "password-description": "Password for the Store",
+      "pending": "Pending",
+      "pending-operations": "Operations awaiting approval",
       "private-key-description": "The signing-key that will be used to sign the device identity",
       "private-key-model": "Model Assertion Key",
       "private-key-model-short": "Assertion",
@@ -165,14 +217,30 @@ var intlData = {
       "public-key": "Public Key",
       "public-keys-authorized": "The following keys are authorized",
       "public-keys": "Public Keys",
+      "reason": "Reason",
+      "reason-description": "Why the device-key or serial number is revoked",
       "register-signing-key": "Register Signing Key with the Store",
+      "reject": "Reject",
+      "reject-operation": "Error rejecting the operation",
+      "rejected": "Rejected",
+      "rejected-assertion-type": "Rejected: assertion type not allowed by the signing-key",
+      "rejected-brand": "Rejected: brand not allowed by the signing-key",
+      "rejected-expired": "Rejected: the signing-key has expired",
+      "rejected-model": "Rejected: model not allowed by the signing-key",
+      "rejected-not-yet-valid": "Rejected: the signing-key is not valid yet",
       "remove": "Remove",
+      "request-operation": "Error requesting the operation",
+      "requested-by": "Requested by",
       "required-snaps": "Required Snaps",
       "required-snaps-description": "(optional) List of required snaps - enter a comma-separated list",
       "reseller": "Reseller",
       "reseller-features": "Enable Reseller Features",
       "revision": "Revision",
       "revision-description": "Revision of the assertion",
+      "revocations": "Revocations",
+      "revocations-description": "Revoked device-keys and serial numbers will not be signed or pivoted",
+      "revoke-type": "Revoke",
+      "rollover-at": "Rollover to the next key at",
       "role": "Role",
       "save": "Save",
       "select-accounts": "Select below the accounts this user belongs to:",
@@ -184,6 +252,8 @@ var intlData = {
       "signing-keys": "Signing Keys",
       "signinglog-description": "Log of the serial numbers and device-key fingerprints that have been used",
       "signinglog": "Signing Log",
+      "source-ip": "IP Address",
+      "status": "Status",
       "store": "Store",
       "store-description": "ID of the brand store",
       "store-account-assertion": "Account Assertion from Store",
@@ -196,6 +266,7 @@ var intlData = {
       "systemuser": "System-User",
       "title": "Serial Vault",
       "upload-account-assertion": "Upload Account Assertion",
+      "usage-policy": "Usage Policy",
       "user-accounts": "User Accounts",
       "user-email": "The email address of the user",
       "user-key": "User Key",
@@ -206,6 +277,7 @@ var intlData = {
       "users": "Users",
       "user": "User",
       "user-username": "The nickname of the user",
+      "validity": "Validity",
       "version": "Version",
       "yes": "Yes",
     }
@@ -31,6 +31,14 @@ var Keypair = {
 		return Ajax.put(this.url + '/' + keypair.ID, keypair);
 	},
 
+	policy:  function(keypairId, policy) {
+		return Ajax.put(this.url + '/' + keypairId + '/policy', policy);
+	},
+
+	validity:  function(keypairId, validity) {
+		return Ajax.put(this.url + '/' + keypairId + '/validity', validity);
+	},
+
 	enable:  function(keypairId) {
 		return Ajax.post(this.url + '/' + keypairId + '/enable', {});
 	},
@@ -39,6 +47,10 @@ var Keypair = {
 		return Ajax.post(this.url + '/' + keypairId + '/disable', {});
 	},
 
+	delete:  function(keypairId) {
+		return Ajax.delete(this.url + '/' + keypairId, {});
+	},
+
 	create:  function(authorityId, key, keyName) {
 		return Ajax.post(this.url, {'authority-id': authorityId, 'private-key': key, 'key-name': keyName});
 	},
@@ -40,34 +40,24 @@ var SigningLog = {
 	},
 
 	download: function(authorityID, filter, serialnumber) {
-		Ajax.get(this.url + '/account/' + authorityID , {
-			all: true,
-			filter: filter,
-			serialnumber: serialnumber
-		}).then((response) => {
-			var data = JSON.parse(response.body);
-			// Convert the filtered data to a CSV array format
-			var lines = ['data:text/csv;charset=utf-8,ID,Make,Model,Serial Number,Revision,Fingerprint,Date'];
-
-			data.logs.forEach(function(d, index){
-				var line = [d.id,d.make,d.model,d.serialnumber,d.revision,d.fingerprint,d.created].join(",");
-				lines.push(line);
-			});
-
-			// Convert the lines array to a downloadable URI
-			var csvContent = lines.join("\n");
-			var encodedUri = encodeURI(csvContent);
+		// The export is streamed by the server, so the whole of the search is downloaded
+		var query = [];
+		if (filter) {
+			query.push('filter=' + encodeURIComponent(filter));
+		}
+		if (serialnumber) {
+			query.push('serialnumber=' + encodeURIComponent(serialnumber));
+		}
 
-			// Create a temporary link so we can name the download file
-			var downloadLink = document.createElement("a");
-			downloadLink.href = encodedUri;
-			downloadLink.download = "SigningLogs.csv";
+		// Create a temporary link so the browser downloads the file
+		var downloadLink = document.createElement("a");
+		downloadLink.href = '/v1/' + this.url + '/account/' + authorityID + '/export?' + query.join('&');
+		downloadLink.download = "SigningLogs.csv";
 
-			// Click the link and remove it
-			document.body.appendChild(downloadLink);
-			downloadLink.click();
-			document.body.removeChild(downloadLink);
-		});
+		// Click the link and remove it
+		document.body.appendChild(downloadLink);
+		downloadLink.click();
+		document.body.removeChild(downloadLink);
 	}
 }
 